@@ -622,6 +622,17 @@ func (s *Server) HandleVizierHeartbeat(v2cMsg *cvmsgspb.V2CMessage) {
 	}
 	vizierID := utils.UUIDFromProtoOrNil(req.VizierID)
 
+	// The control plane pod statuses are either a full snapshot, a delta on top of the revision that was last
+	// stored, or left out if they haven't changed. If a delta can't be applied, the stored pod statuses are kept
+	// and the vizier is asked to resend them in full.
+	podStatuses := PodStatuses(req.PodStatuses)
+	updatePodStatuses := req.PodStatuses != nil || req.PodStatusesRevision != 0
+	resyncPodStatuses := false
+	if req.PodStatusesBaseRevision != 0 {
+		podStatuses, updatePodStatuses = s.applyPodStatusDelta(vizierID, req)
+		resyncPodStatuses = !updatePodStatuses
+	}
+
 	// We want to detect when the record changes, so we need to exhaustively list all the columns except the
 	// heartbeat time and status.
 	// Note: We don't compare the json fields because they just contain details of the status fields.
@@ -629,7 +640,8 @@ func (s *Server) HandleVizierHeartbeat(v2cMsg *cvmsgspb.V2CMessage) {
 		UPDATE vizier_cluster_info x
 		SET last_heartbeat = $1, status = $2, control_plane_pod_statuses = CASE WHEN $11 THEN $3::json ELSE y.control_plane_pod_statuses END,
 			num_nodes = $4, num_instrumented_nodes = $5, auto_update_enabled = $6,
-			unhealthy_data_plane_pod_statuses = $7, cluster_version = $8, status_message = $9, operator_version = $12
+			unhealthy_data_plane_pod_statuses = $7, cluster_version = $8, status_message = $9, operator_version = $12,
+			pod_statuses_revision = CASE WHEN $11 THEN $13 ELSE y.pod_statuses_revision END
 		FROM (SELECT * FROM vizier_cluster_info WHERE vizier_cluster_id = $10) y
 		WHERE x.vizier_cluster_id = y.vizier_cluster_id
 		RETURNING (x.status != y.status
@@ -645,9 +657,10 @@ func (s *Server) HandleVizierHeartbeat(v2cMsg *cvmsgspb.V2CMessage) {
 		Version string `db:"vizier_version"`
 	}
 
-	rows, err := s.db.Queryx(query, time.Now(), vizierStatus(req.Status), PodStatuses(req.PodStatuses), req.NumNodes,
+	rows, err := s.db.Queryx(query, time.Now(), vizierStatus(req.Status), podStatuses, req.NumNodes,
 		req.NumInstrumentedNodes, !req.DisableAutoUpdate, PodStatuses(req.UnhealthyDataPlanePodStatuses),
-		req.K8sClusterVersion, req.StatusMessage, vizierID, req.PodStatuses != nil, req.OperatorVersion)
+		req.K8sClusterVersion, req.StatusMessage, vizierID, updatePodStatuses, req.OperatorVersion,
+		req.PodStatusesRevision)
 	if err != nil {
 		log.WithError(err).Error("Could not update vizier heartbeat")
 		return
@@ -666,6 +679,8 @@ func (s *Server) HandleVizierHeartbeat(v2cMsg *cvmsgspb.V2CMessage) {
 	// Release the DB connection early.
 	rows.Close()
 
+	s.sendHeartbeatAck(vizierID, req.SequenceNumber, resyncPodStatuses)
+
 	// Send analytics event for cluster status changes.
 	if info.Changed {
 		events.Client().Enqueue(&analytics.Track{
@@ -693,6 +708,62 @@ func (s *Server) HandleVizierHeartbeat(v2cMsg *cvmsgspb.V2CMessage) {
 	}
 }
 
+// applyPodStatusDelta applies the pod status delta in the heartbeat to the stored pod statuses. It returns false
+// if the stored pod statuses are not at the revision the delta is based on, for example because a heartbeat was
+// dropped or the stored pod statuses were lost, in which case the delta can't be applied.
+func (s *Server) applyPodStatusDelta(vizierID uuid.UUID, req *cvmsgspb.VizierHeartbeat) (PodStatuses, bool) {
+	query := `SELECT control_plane_pod_statuses, pod_statuses_revision FROM vizier_cluster_info WHERE vizier_cluster_id = $1`
+	var stored struct {
+		PodStatuses PodStatuses   `db:"control_plane_pod_statuses"`
+		Revision    sql.NullInt64 `db:"pod_statuses_revision"`
+	}
+	err := s.db.Get(&stored, query, vizierID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithError(err).Error("Could not get stored pod statuses")
+		}
+		return nil, false
+	}
+	if !stored.Revision.Valid || stored.Revision.Int64 != req.PodStatusesBaseRevision {
+		log.WithField("vizier_id", vizierID.String()).
+			WithField("baseRevision", req.PodStatusesBaseRevision).
+			Info("Stored pod statuses are not at the base revision of the delta, requesting a resync")
+		return nil, false
+	}
+	return stored.PodStatuses.ApplyDelta(req.PodStatuses, req.RemovedPodStatuses), true
+}
+
+// sendHeartbeatAck acknowledges the heartbeat with the given sequence number, so that the vizier can tell
+// whether its heartbeats are reaching cloud. If resyncPodStatuses is set, the vizier is asked to send the full
+// pod statuses with its next heartbeat.
+func (s *Server) sendHeartbeatAck(vizierID uuid.UUID, seqNum int64, resyncPodStatuses bool) {
+	if s.nc == nil {
+		return
+	}
+	ackAny, err := types.MarshalAny(&cvmsgspb.VizierHeartbeatAck{
+		Status:                     cvmsgspb.HB_OK,
+		Time:                       time.Now().UnixNano(),
+		SequenceNumber:             seqNum,
+		PodStatusesResyncRequested: resyncPodStatuses,
+	})
+	if err != nil {
+		log.WithError(err).Error("Could not marshal heartbeat ack")
+		return
+	}
+	b, err := (&cvmsgspb.C2VMessage{
+		VizierID: vizierID.String(),
+		Msg:      ackAny,
+	}).Marshal()
+	if err != nil {
+		log.WithError(err).Error("Could not marshal heartbeat ack")
+		return
+	}
+	err = s.nc.Publish(vzshard.C2VTopic("VizierHeartbeatAck", vizierID), b)
+	if err != nil {
+		log.WithError(err).Error("Could not publish heartbeat ack")
+	}
+}
+
 // getServiceCredentials returns JWT credentials for inter-service requests.
 func getServiceCredentials(signingKey string) (string, error) {
 	claims := jwtutils.GenerateJWTForService("vzmgr Service", viper.GetString("domain_name"))
@@ -532,12 +532,34 @@ func TestServer_HandleVizierHeartbeat(t *testing.T) {
 				Msg: nestedAny,
 			}
 
+			ackCh := make(chan *nats.Msg, 1)
+			ackSub, err := nc.ChanSubscribe(fmt.Sprintf("c2v.%s.VizierHeartbeatAck", tc.vizierID), ackCh)
+			require.NoError(t, err)
+			defer func() {
+				require.NoError(t, ackSub.Unsubscribe())
+			}()
+
 			var heartbeatTime time.Time
 			err = db.Get(&heartbeatTime, `SELECT NOW()`)
 			require.NoError(t, err)
 
 			s.HandleVizierHeartbeat(req)
 
+			// Heartbeats that were recorded are acknowledged back to the vizier.
+			if tc.checkDB {
+				select {
+				case msg := <-ackCh:
+					c2vMsg := &cvmsgspb.C2VMessage{}
+					require.NoError(t, c2vMsg.Unmarshal(msg.Data))
+					ack := &cvmsgspb.VizierHeartbeatAck{}
+					require.NoError(t, types.UnmarshalAny(c2vMsg.Msg, ack))
+					assert.Equal(t, cvmsgspb.HB_OK, ack.Status)
+					assert.Equal(t, int64(200), ack.SequenceNumber)
+				case <-time.After(5 * time.Second):
+					t.Fatal("Timed out waiting for heartbeat ack")
+				}
+			}
+
 			// Check database.
 			clusterQuery := `
 			SELECT status, last_heartbeat, control_plane_pod_statuses, num_nodes, num_instrumented_nodes,
@@ -580,6 +602,122 @@ func TestServer_HandleVizierHeartbeat(t *testing.T) {
 	}
 }
 
+func TestServer_HandleVizierHeartbeat_PodStatusDeltas(t *testing.T) {
+	mustLoadTestData(db)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	nc, cleanup := testingutils.MustStartTestNATS(t)
+	defer cleanup()
+
+	updater := mock_controllers.NewMockVzUpdater(ctrl)
+	s := controllers.New(db, "test", nc, updater)
+
+	vizierID := "123e4567-e89b-12d3-a456-426655440001"
+	ackCh := make(chan *nats.Msg, 1)
+	ackSub, err := nc.ChanSubscribe(fmt.Sprintf("c2v.%s.VizierHeartbeatAck", vizierID), ackCh)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, ackSub.Unsubscribe())
+	}()
+
+	tests := []struct {
+		name                            string
+		podStatuses                     controllers.PodStatuses
+		removedPodStatuses              []string
+		podStatusesRevision             int64
+		podStatusesBaseRevision         int64
+		expectedControlPlanePodStatuses controllers.PodStatuses
+		expectedResync                  bool
+	}{
+		{
+			name:                            "full snapshot",
+			podStatuses:                     testPodStatuses,
+			podStatusesRevision:             5,
+			expectedControlPlanePodStatuses: testPodStatuses,
+		},
+		{
+			name: "delta on top of the stored revision",
+			podStatuses: controllers.PodStatuses{
+				"vizier-metadata": {Name: "vizier-metadata", Status: metadatapb.PENDING},
+			},
+			removedPodStatuses:      []string{"vizier-proxy"},
+			podStatusesRevision:     6,
+			podStatusesBaseRevision: 5,
+			expectedControlPlanePodStatuses: controllers.PodStatuses{
+				"vizier-metadata":     {Name: "vizier-metadata", Status: metadatapb.PENDING},
+				"vizier-query-broker": testPodStatuses["vizier-query-broker"],
+			},
+		},
+		{
+			name: "delta on top of another revision",
+			podStatuses: controllers.PodStatuses{
+				"vizier-metadata": {Name: "vizier-metadata", Status: metadatapb.RUNNING},
+			},
+			podStatusesRevision:     8,
+			podStatusesBaseRevision: 7,
+			expectedControlPlanePodStatuses: controllers.PodStatuses{
+				"vizier-metadata":     {Name: "vizier-metadata", Status: metadatapb.PENDING},
+				"vizier-query-broker": testPodStatuses["vizier-query-broker"],
+			},
+			expectedResync: true,
+		},
+		{
+			name: "resync",
+			podStatuses: controllers.PodStatuses{
+				"vizier-metadata": {Name: "vizier-metadata", Status: metadatapb.RUNNING},
+			},
+			podStatusesRevision: 8,
+			expectedControlPlanePodStatuses: controllers.PodStatuses{
+				"vizier-metadata": {Name: "vizier-metadata", Status: metadatapb.RUNNING},
+			},
+		},
+		{
+			name: "unchanged",
+			expectedControlPlanePodStatuses: controllers.PodStatuses{
+				"vizier-metadata": {Name: "vizier-metadata", Status: metadatapb.RUNNING},
+			},
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			nestedAny, err := types.MarshalAny(&cvmsgspb.VizierHeartbeat{
+				VizierID:                utils.ProtoFromUUIDStrOrNil(vizierID),
+				SequenceNumber:          int64(i),
+				Status:                  cvmsgspb.VZ_ST_HEALTHY,
+				PodStatuses:             tc.podStatuses,
+				RemovedPodStatuses:      tc.removedPodStatuses,
+				PodStatusesRevision:     tc.podStatusesRevision,
+				PodStatusesBaseRevision: tc.podStatusesBaseRevision,
+				DisableAutoUpdate:       true,
+			})
+			require.NoError(t, err)
+
+			s.HandleVizierHeartbeat(&cvmsgspb.V2CMessage{Msg: nestedAny})
+
+			select {
+			case msg := <-ackCh:
+				c2vMsg := &cvmsgspb.C2VMessage{}
+				require.NoError(t, c2vMsg.Unmarshal(msg.Data))
+				ack := &cvmsgspb.VizierHeartbeatAck{}
+				require.NoError(t, types.UnmarshalAny(c2vMsg.Msg, ack))
+				assert.Equal(t, int64(i), ack.SequenceNumber)
+				assert.Equal(t, tc.expectedResync, ack.PodStatusesResyncRequested)
+			case <-time.After(5 * time.Second):
+				t.Fatal("Timed out waiting for heartbeat ack")
+			}
+
+			var podStatuses controllers.PodStatuses
+			err = db.Get(&podStatuses, `SELECT control_plane_pod_statuses FROM vizier_cluster_info WHERE vizier_cluster_id=$1`,
+				uuid.FromStringOrNil(vizierID))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedControlPlanePodStatuses, podStatuses)
+		})
+	}
+}
+
 func TestServer_UpdateOrInstallVizier(t *testing.T) {
 	mustLoadTestData(db)
 
@@ -54,3 +54,18 @@ func (p *PodStatuses) Scan(src interface{}) error {
 
 	return nil
 }
+
+// ApplyDelta returns the pod statuses with the given pods updated and removed.
+func (p PodStatuses) ApplyDelta(updated map[string]*cvmsgspb.PodStatus, removed []string) PodStatuses {
+	result := make(PodStatuses, len(p)+len(updated))
+	for name, s := range p {
+		result[name] = s
+	}
+	for _, name := range removed {
+		delete(result, name)
+	}
+	for name, s := range updated {
+		result[name] = s
+	}
+	return result
+}
@@ -74,3 +74,23 @@ func TestPodStatusScan(t *testing.T) {
 
 	assert.Equal(t, inputPodStatuses, outputPodStatuses)
 }
+
+func TestPodStatusesApplyDelta(t *testing.T) {
+	stored := controllers.PodStatuses{
+		"vizier-metadata":     {Name: "vizier-metadata", Status: metadatapb.PENDING},
+		"vizier-query-broker": {Name: "vizier-query-broker", Status: metadatapb.RUNNING},
+	}
+
+	applied := stored.ApplyDelta(map[string]*cvmsgspb.PodStatus{
+		"vizier-metadata": {Name: "vizier-metadata", Status: metadatapb.RUNNING},
+		"vizier-proxy":    {Name: "vizier-proxy", Status: metadatapb.RUNNING},
+	}, []string{"vizier-query-broker"})
+	assert.Equal(t, controllers.PodStatuses{
+		"vizier-metadata": {Name: "vizier-metadata", Status: metadatapb.RUNNING},
+		"vizier-proxy":    {Name: "vizier-proxy", Status: metadatapb.RUNNING},
+	}, applied)
+
+	// The stored pod statuses are left as they were.
+	assert.Len(t, stored, 2)
+	assert.Equal(t, metadatapb.PENDING, stored["vizier-metadata"].Status)
+}
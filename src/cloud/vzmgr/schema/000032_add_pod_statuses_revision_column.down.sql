@@ -0,0 +1,2 @@
+ALTER TABLE vizier_cluster_info
+  DROP COLUMN pod_statuses_revision;
@@ -0,0 +1,2 @@
+ALTER TABLE vizier_cluster_info
+  ADD COLUMN pod_statuses_revision bigint;
@@ -0,0 +1,50 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+)
+
+// accumulationFormats are the stream formats that the results of a run can be accumulated in, by the name that
+// --accumulation selects them with. Counting decodes only enough of each batch to count its rows and bytes, so
+// that the exec time doesn't include the cost of keeping every row in memory. Accumulating in memory times the
+// runs as the tool did before counting, for comparison with older results.
+var accumulationFormats = map[string]string{
+	"counting": vizier.FormatCountOnly,
+	"inmemory": vizier.FormatInMemory,
+}
+
+func addAccumulationFlag(c *cobra.Command) {
+	c.PersistentFlags().String("accumulation", "counting", "How to accumulate the results of each run: 'counting' only counts the rows and bytes, 'inmemory' keeps every row, and adds the cost of doing so to the exec time. Streamed and canceled runs always count")
+}
+
+// accumulationFormatFromFlags returns the stream format selected by the flag added by addAccumulationFlag.
+func accumulationFormatFromFlags(c *cobra.Command) (string, error) {
+	name, _ := c.Flags().GetString("accumulation")
+	format, ok := accumulationFormats[name]
+	if !ok {
+		return "", fmt.Errorf("unknown accumulation %q, expected 'counting' or 'inmemory'", name)
+	}
+	return format, nil
+}
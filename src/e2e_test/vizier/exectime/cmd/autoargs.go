@@ -0,0 +1,223 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+	"px.dev/pixie/src/utils/script"
+)
+
+// DiscoveredArgs are the values that --auto-args discovered on a cloud's clusters for the variables of the
+// scripts, so that a run can be reproduced with the same values.
+type DiscoveredArgs struct {
+	// Values are the discovered values, keyed by variable name.
+	Values map[string]string `json:",omitempty"`
+	// Errors are why no value was discovered for the other variables, keyed by variable name.
+	Errors map[string]string `json:",omitempty"`
+	// SkippedScripts are the scripts that were skipped for want of a value, with the reason.
+	SkippedScripts map[string]string `json:",omitempty"`
+}
+
+// A discoveryQuery finds realistic values for some of the script variables. The query displays a single table,
+// with a column for each of the variables, in order.
+type discoveryQuery struct {
+	name      string
+	variables []string
+	pxl       string
+}
+
+// discoveryQueries find a namespace with traffic, a busy service, and a long-lived pod along with its node.
+// Ties are broken by taking the first row.
+var discoveryQueries = []discoveryQuery{
+	{
+		name:      "discover_namespace",
+		variables: []string{"namespace"},
+		pxl: `
+import px
+df = px.DataFrame('http_events', start_time='-5m')
+df.namespace = df.ctx['namespace']
+df = df[df.namespace != '']
+df = df.groupby('namespace').agg(requests=('latency', px.count))
+max_requests = df.agg(max_requests=('requests', px.max))
+df = df.merge(max_requests, left_on=['requests'], right_on=['max_requests'], how='inner')
+px.display(df[['namespace']])
+`,
+	},
+	{
+		name:      "discover_service",
+		variables: []string{"service"},
+		pxl: `
+import px
+df = px.DataFrame('http_events', start_time='-5m')
+df.service = df.ctx['service']
+df = df[df.service != '']
+df = df.groupby('service').agg(requests=('latency', px.count))
+max_requests = df.agg(max_requests=('requests', px.max))
+df = df.merge(max_requests, left_on=['requests'], right_on=['max_requests'], how='inner')
+px.display(df[['service']])
+`,
+	},
+	{
+		name:      "discover_pod",
+		variables: []string{"pod", "node"},
+		pxl: `
+import px
+df = px.DataFrame('process_stats', start_time='-5m')
+df.pod = df.ctx['pod']
+df.node = df.ctx['node']
+df = df[df.pod != '' and df.node != '']
+df = df.groupby(['pod', 'node']).agg()
+df.pod_start_time = px.pod_name_to_start_time(df.pod)
+min_start_time = df.agg(min_start_time=('pod_start_time', px.min))
+df = df.merge(min_start_time, left_on=['pod_start_time'], right_on=['min_start_time'], how='inner')
+px.display(df[['pod', 'node']])
+`,
+	},
+}
+
+// discoverArgs runs each of the discovery queries against the given Viziers. A query that fails, or finds
+// nothing, is recorded as the error of each of its variables, rather than failing the others.
+func discoverArgs(v []*vizier.Connector) *DiscoveredArgs {
+	d := &DiscoveredArgs{
+		Values:         make(map[string]string),
+		Errors:         make(map[string]string),
+		SkippedScripts: make(map[string]string),
+	}
+	for _, q := range discoveryQueries {
+		row, err := queryFirstRow(v, q.name, q.pxl, len(q.variables))
+		for i, name := range q.variables {
+			if err != nil {
+				d.Errors[name] = err.Error()
+				continue
+			}
+			if row[i] == "" {
+				d.Errors[name] = fmt.Sprintf("%s found an empty %s", q.name, name)
+				continue
+			}
+			d.Values[name] = row[i]
+		}
+	}
+	return d
+}
+
+// queryFirstRow runs the pxl script and returns the first numCols columns of the first row of its output, as
+// strings.
+func queryFirstRow(v []*vizier.Connector, name, pxl string, numCols int) ([]string, error) {
+	execScript := &script.ExecutableScript{
+		ScriptName:   name,
+		ScriptString: pxl,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := vizier.RunScript(ctx, v, execScript, nil)
+	if err != nil {
+		return nil, err
+	}
+	tw := vizier.NewStreamOutputAdapter(ctx, resp, vizier.FormatInMemory, nil)
+	if err := tw.Finish(); err != nil {
+		return nil, fmt.Errorf("%s failed: %s", name, vizier.FormatErrorMessage(err))
+	}
+	views, err := tw.Views()
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range views {
+		if table.Name() != "output" {
+			continue
+		}
+		data := table.Data()
+		if len(data) == 0 {
+			return nil, fmt.Errorf("%s returned no rows", name)
+		}
+		if len(data[0]) < numCols {
+			return nil, fmt.Errorf("%s returned %d columns, expected %d", name, len(data[0]), numCols)
+		}
+		row := make([]string, numCols)
+		for i := range row {
+			row[i] = fmt.Sprint(data[0][i])
+		}
+		return row, nil
+	}
+	return nil, errors.New(name + " returned no output table")
+}
+
+// argDefaults returns the discovered values as script args, along with the default start_time.
+func (d *DiscoveredArgs) argDefaults() map[string]script.Arg {
+	args := map[string]script.Arg{
+		"start_time": {Name: "start_time", Value: "-5m"},
+	}
+	for name, value := range d.Values {
+		args[name] = script.Arg{Name: name, Value: value}
+	}
+	return args
+}
+
+// skipUndiscovered drops the resolved scripts that take a variable that no value was discovered for, and that
+// got no value of their own either, and records why in SkippedScripts. Running them with an empty value
+// would time a query that does nothing.
+func (d *DiscoveredArgs) skipUndiscovered(scripts []*script.ExecutableScript) []*script.ExecutableScript {
+	names := make([]string, 0, len(d.Errors))
+	for name := range d.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	kept := make([]*script.ExecutableScript, 0, len(scripts))
+	for _, s := range scripts {
+		var missing []string
+		for _, name := range names {
+			if hasVariable(s, name) && s.Args[name].Value == "" {
+				missing = append(missing, fmt.Sprintf("%s (%s)", name, d.Errors[name]))
+			}
+		}
+		if len(missing) == 0 {
+			kept = append(kept, s)
+			continue
+		}
+		reason := "no value discovered for " + strings.Join(missing, ", ")
+		d.SkippedScripts[s.ScriptName] = reason
+		log.WithField("script", s.ScriptName).Warnf("Skipping script: %s", reason)
+	}
+	return kept
+}
+
+// endpointAutoArgs returns the args discovered for each endpoint, keyed by its label, or nil if none were.
+func endpointAutoArgs(endpoints []*benchmarkEndpoint) map[string]*DiscoveredArgs {
+	var autoArgs map[string]*DiscoveredArgs
+	for _, ep := range endpoints {
+		if ep.autoArgs == nil {
+			continue
+		}
+		if autoArgs == nil {
+			autoArgs = make(map[string]*DiscoveredArgs)
+		}
+		autoArgs[ep.label()] = ep.autoArgs
+	}
+	return autoArgs
+}
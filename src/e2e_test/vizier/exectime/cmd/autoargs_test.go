@@ -0,0 +1,67 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/api/proto/vispb"
+	"px.dev/pixie/src/utils/script"
+)
+
+func variableScript(name string, variables ...*vispb.Vis_Variable) *script.ExecutableScript {
+	return &script.ExecutableScript{ScriptName: name, Vis: &vispb.Vis{Variables: variables}}
+}
+
+func TestDiscoveredArgsSkipUndiscovered(t *testing.T) {
+	d := &DiscoveredArgs{
+		Values:         map[string]string{"namespace": "default"},
+		Errors:         map[string]string{"pod": "discover_pod returned no rows", "service": "discover_service returned no rows"},
+		SkippedScripts: make(map[string]string),
+	}
+	defaults := d.argDefaults()
+	assert.Equal(t, "-5m", defaults["start_time"].Value)
+	assert.Equal(t, "default", defaults["namespace"].Value)
+
+	scripts := []*script.ExecutableScript{
+		variableScript("px/namespace", &vispb.Vis_Variable{Name: "namespace"}),
+		variableScript("px/pod", &vispb.Vis_Variable{Name: "pod"}, &vispb.Vis_Variable{Name: "service"}),
+		// A script's own valid values are used rather than skipping it.
+		variableScript("px/service", &vispb.Vis_Variable{Name: "service", ValidValues: []string{"px-sock-shop/front-end"}}),
+	}
+	resolved := d.skipUndiscovered(resolveScripts(scripts, nil, defaults, false, false, nil))
+	require.Len(t, resolved, 2)
+	assert.Equal(t, "px/namespace", resolved[0].ScriptName)
+	assert.Equal(t, "default", resolved[0].Args["namespace"].Value)
+	assert.Equal(t, "px/service", resolved[1].ScriptName)
+	assert.Equal(t, map[string]string{
+		"px/pod": "no value discovered for pod (discover_pod returned no rows), service (discover_service returned no rows)",
+	}, d.SkippedScripts)
+}
+
+func TestEndpointAutoArgs(t *testing.T) {
+	assert.Nil(t, endpointAutoArgs([]*benchmarkEndpoint{{cloudAddr: "withpixie.ai:443"}}))
+
+	d := &DiscoveredArgs{Values: map[string]string{"pod": "default/frontend"}}
+	autoArgs := endpointAutoArgs([]*benchmarkEndpoint{{cloudAddr: "withpixie.ai:443", autoArgs: d}, {cloudAddr: "dev.withpixie.dev:443"}})
+	assert.Equal(t, map[string]*DiscoveredArgs{"withpixie.ai:443": d}, autoArgs)
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/gofrs/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// autoCompareRegression is how much slower a script's mean external exec time must get, relative to the previous
+// run, to be flagged as a regression. With enough runs for a U test, the change must also be significant.
+const autoCompareRegression = 0.1
+
+// autoCompareDir returns the directory that the results of runs on the given clusters, with the given bundle,
+// are stashed in.
+func autoCompareDir(cacheDir string, clusterIDs []uuid.UUID, bundleHash string) string {
+	ids := make([]string, len(clusterIDs))
+	for i, id := range clusterIDs {
+		ids[i] = id.String()
+	}
+	sort.Strings(ids)
+	sum := sha256.Sum256([]byte(strings.Join(ids, ",") + "/" + bundleHash))
+	return filepath.Join(cacheDir, "results", hex.EncodeToString(sum[:8]))
+}
+
+// stashedResults returns the paths of the results stashed in dir, oldest first.
+func stashedResults(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	// The file names are timestamps, so they sort by age.
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// stashResults writes the json results of a run to dir, and removes all but the newest keep results.
+func stashResults(dir string, jsonData []byte, keep int, now time.Time) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, now.UTC().Format("20060102T150405.000000000Z")+".json")
+	if err := os.WriteFile(path, jsonData, 0644); err != nil {
+		return err
+	}
+	paths, err := stashedResults(dir)
+	if err != nil {
+		return err
+	}
+	for len(paths) > keep {
+		if err := os.Remove(paths[0]); err != nil {
+			return err
+		}
+		paths = paths[1:]
+	}
+	return nil
+}
+
+// readResults reads the results of a single endpoint from a file written with the json output, with the runs
+// of swept scripts flattened back out.
+func readResults(path string) (map[string]*ScriptExecData, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]*ScriptExecData
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, err
+	}
+	// The metadata isn't a script.
+	delete(data, benchmarkMetadataKey)
+	return flattenSweeps(data), nil
+}
+
+// autoCompareDelta is the change in a script's mean external exec time since the previous run.
+type autoCompareDelta struct {
+	name     string
+	previous time.Duration
+	current  time.Duration
+	// regression is set if the script got slower by more than the threshold.
+	regression bool
+	// scriptChanged is set if the script itself changed since the previous run.
+	scriptChanged bool
+}
+
+// autoCompareDeltas returns the change of every script that has an external exec time in both runs. Scripts whose
+// mean got slower by more than the threshold, as a fraction of the previous mean, are regressions. With enough
+// runs for a U test, the change must also be significant.
+func autoCompareDeltas(previous, current map[string]*ScriptExecData, threshold float64) []*autoCompareDelta {
+	names := make([]string, 0, len(current))
+	for name := range current {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var deltas []*autoCompareDelta
+	for _, name := range names {
+		prev, ok := previous[name]
+		if !ok || prev.Streamed != current[name].Streamed || prev.Canceled != current[name].Canceled {
+			continue
+		}
+		prevTimes, ok := prev.Distributions[execTimeExternalLabel].(*TimeDistribution)
+		if !ok || len(prevTimes.Times) == 0 {
+			continue
+		}
+		curTimes, ok := current[name].Distributions[execTimeExternalLabel].(*TimeDistribution)
+		if !ok || len(curTimes.Times) == 0 {
+			continue
+		}
+		d := &autoCompareDelta{
+			name:          name,
+			previous:      prevTimes.Mean(),
+			current:       curTimes.Mean(),
+			scriptChanged: scriptChanged(prev, current[name]),
+		}
+		if float64(d.current-d.previous) > threshold*float64(d.previous) {
+			pValue, err := UTest(toFloat64Arr(prevTimes.Times), toFloat64Arr(curTimes.Times))
+			d.regression = err != nil || pValue < timeDiffAlpha
+		}
+		deltas = append(deltas, d)
+	}
+	return deltas
+}
+
+// writeAutoCompare writes the deltas since the previous run, from the file at previousPath.
+func writeAutoCompare(w io.Writer, previousPath string, deltas []*autoCompareDelta) {
+	fmt.Fprintf(w, "Since the previous run (%s):\n", filepath.Base(previousPath))
+	for _, d := range deltas {
+		var notes []string
+		if d.regression {
+			notes = append(notes, color.RedString("REGRESSION"))
+		}
+		if d.scriptChanged {
+			notes = append(notes, "script changed")
+		}
+		note := ""
+		if len(notes) > 0 {
+			note = fmt.Sprintf(" [%s]", strings.Join(notes, ", "))
+		}
+		diff := (d.current - d.previous).Round(100 * time.Microsecond)
+		sign := ""
+		if diff > 0 {
+			sign = "+"
+		}
+		change := float64(d.current-d.previous) / float64(d.previous) * 100
+		fmt.Fprintf(w, "  %s: %s%v (%+.1f%%, %v -> %v)%s\n", d.name, sign, diff, change,
+			d.previous.Round(100*time.Microsecond), d.current.Round(100*time.Microsecond), note)
+	}
+}
+
+// endpointClusterIDs returns the IDs of the clusters that the endpoint runs on.
+func endpointClusterIDs(ep *benchmarkEndpoint) []uuid.UUID {
+	ids := make([]uuid.UUID, len(ep.conns))
+	for i, c := range ep.conns {
+		ids[i] = c.ID()
+	}
+	return ids
+}
+
+// autoCompare writes the deltas of the current results since the newest results stashed in dir, if there are
+// any, and then stashes the current results, in json, in their place.
+func autoCompare(w io.Writer, dir string, jsonData []byte, current map[string]*ScriptExecData, keep int, now time.Time) error {
+	paths, err := stashedResults(dir)
+	if err != nil {
+		return err
+	}
+	if len(paths) > 0 {
+		previousPath := paths[len(paths)-1]
+		previous, err := readResults(previousPath)
+		if err != nil {
+			log.WithError(err).WithField("path", previousPath).Warn("Failed to read the previous results, skipping the comparison")
+		} else {
+			writeAutoCompare(w, previousPath, autoCompareDeltas(previous, current, autoCompareRegression))
+		}
+	}
+	return stashResults(dir, jsonData, keep, now)
+}
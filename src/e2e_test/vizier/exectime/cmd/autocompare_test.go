@@ -0,0 +1,89 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func timedScriptExecData(name string, times ...time.Duration) *ScriptExecData {
+	d := newScriptExecData(name)
+	for _, t := range times {
+		recordResults(d, &execResults{externalExecTime: t})
+	}
+	return d
+}
+
+func TestStashResults_KeepsNewest(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, stashResults(dir, []byte("{}"), 2, now.Add(time.Duration(i)*time.Minute)))
+	}
+	paths, err := stashedResults(dir)
+	require.NoError(t, err)
+	require.Len(t, paths, 2)
+	assert.Equal(t, now.Add(2*time.Minute).UTC().Format("20060102T150405.000000000Z")+".json", filepath.Base(paths[1]))
+}
+
+func TestAutoCompare(t *testing.T) {
+	color.NoColor = true
+	dir := t.TempDir()
+	now := time.Now()
+
+	previous := map[string]*ScriptExecData{
+		"px/cluster":   timedScriptExecData("px/cluster", time.Second, time.Second),
+		"px/http_data": timedScriptExecData("px/http_data", time.Second, time.Second),
+	}
+	jsonData, err := json.Marshal(previous)
+	require.NoError(t, err)
+	// There is nothing to compare the first run against.
+	var buf bytes.Buffer
+	require.NoError(t, autoCompare(&buf, dir, jsonData, previous, 1, now))
+	assert.Empty(t, buf.String())
+
+	current := map[string]*ScriptExecData{
+		"px/cluster":   timedScriptExecData("px/cluster", time.Second, 1050*time.Millisecond),
+		"px/http_data": timedScriptExecData("px/http_data", 2*time.Second, 2*time.Second),
+		"px/new":       timedScriptExecData("px/new", time.Second),
+	}
+	jsonData, err = json.Marshal(current)
+	require.NoError(t, err)
+	require.NoError(t, autoCompare(&buf, dir, jsonData, current, 1, now.Add(time.Minute)))
+	assert.Contains(t, buf.String(), "Since the previous run")
+	assert.Contains(t, buf.String(), "  px/cluster: +25ms (+2.5%, 1s -> 1.025s)\n")
+	assert.Contains(t, buf.String(), "  px/http_data: +1s (+100.0%, 1s -> 2s) [REGRESSION]\n")
+	assert.NotContains(t, buf.String(), "px/new")
+
+	// The stash was rotated, so the next run is compared against this one.
+	paths, err := stashedResults(dir)
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+	stashed, err := readResults(paths[0])
+	require.NoError(t, err)
+	assert.Len(t, stashed, 3)
+}
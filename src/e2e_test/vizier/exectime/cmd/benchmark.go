@@ -23,19 +23,28 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/gdamore/tcell"
 	"github.com/gofrs/uuid"
 	"github.com/olekukonko/tablewriter"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 
+	"px.dev/pixie/src/api/proto/cloudpb"
 	"px.dev/pixie/src/api/proto/vispb"
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/pixie_cli/pkg/auth"
 	"px.dev/pixie/src/pixie_cli/pkg/vizier"
 	"px.dev/pixie/src/utils/script"
 )
@@ -47,28 +56,78 @@ var disallowedScripts = map[string]bool{
 var allowedOutputFmts = map[string]bool{
 	"table": true,
 	"json":  true,
+	"csv":   true,
 }
 
 const defaultBundleFile = "https://storage.googleapis.com/pixie-prod-artifacts/script-bundles/bundle-oss.json"
 
+// benchmarkScriptTimeout is how long each benchmark run of a script may take, unless set with --timeout.
+const benchmarkScriptTimeout = 5 * time.Second
+
 const (
 	execTimeExternalLabel = "Exec Time: External"
 	execTimeInternalLabel = "Exec Time: Internal"
 	compTimeLabel         = "Compilation Time"
 	numErrorsLabel        = "Num Errors"
 	numBytesLabel         = "Num Bytes"
+	// The bytes of the gRPC messages on the wire, after compression, and how many times smaller they are than
+	// the decoded table bytes.
+	wireBytesLabel        = "Wire Bytes"
+	compressionRatioLabel = "Compression Ratio"
+	timeToFirstRowLabel   = "Time To First Row"
+	// Runs that fail before any rows arrive are left out of the time to first row, and counted here instead.
+	errorsBeforeFirstRowLabel = "Errors Before First Row"
+	// The largest and the 95th percentile gaps between consecutive row batches in a run, which show stalls in
+	// the middle of the stream that the total time smears out.
+	maxBatchGapLabel = "Max Batch Gap"
+	p95BatchGapLabel = "P95 Batch Gap"
 )
 
 func init() {
 	BenchmarkCmd.PersistentFlags().Int("num_runs", 20, "number of times to run a script ")
-	BenchmarkCmd.PersistentFlags().StringP("cloud_addr", "a", "withpixie.ai:443", "The address of Pixie Cloud")
+	BenchmarkCmd.PersistentFlags().Int("parallelism", 1, "The most scripts to run at once. The runs of each script are still sequential, but their timings include any contention with the other scripts, and garbage collection is no longer forced between runs, so it may land in any run's timings")
+	BenchmarkCmd.PersistentFlags().Int("warmup_runs", 1, "number of times to run each script before its measured runs. The results of the warmup runs are discarded, and their failures are only logged")
+	BenchmarkCmd.PersistentFlags().StringSliceP("cloud_addr", "a", []string{"withpixie.ai:443"}, "The address of Pixie Cloud. Repeat to compare the scripts through several clouds")
+	BenchmarkCmd.PersistentFlags().StringSlice("auth_file", nil, "The auth file to use for each cloud_addr, in the same order. Defaults to the credentials of the logged in user")
 	BenchmarkCmd.PersistentFlags().StringP("bundle", "b", defaultBundleFile, "The bundle file to use")
 	BenchmarkCmd.PersistentFlags().BoolP("all-clusters", "d", false, "Run script across all clusters")
 	BenchmarkCmd.PersistentFlags().BoolP("split-funcs", "p", false, "Run each function from the vis spec separately")
-	BenchmarkCmd.PersistentFlags().StringP("cluster", "c", "", "Run only on selected cluster")
+	BenchmarkCmd.PersistentFlags().StringSliceP("cluster", "c", nil, "Run only on the selected clusters, by cluster ID, name or unique name prefix. Repeat, or separate with commas, to select several")
 	BenchmarkCmd.PersistentFlags().StringSliceP("scripts", "s", nil, "Run only on selected scripts")
-	BenchmarkCmd.PersistentFlags().StringP("output", "o", "table", "Output format to use. Currently supports 'table' or 'json'")
+	BenchmarkCmd.PersistentFlags().StringP("output", "o", "table", "Output format to use. Currently supports 'table', 'json' or 'csv'")
+	BenchmarkCmd.PersistentFlags().Bool("auto-args", false, "Discover a namespace with traffic, a busy service, and a long-lived pod and its node on the clusters, and use them for the namespace, service, pod and node variables. Scripts that take a variable with no discovered value are skipped")
+	BenchmarkCmd.PersistentFlags().Bool("interactive", false, "Prompt for the values of script variables that have no default")
+	BenchmarkCmd.PersistentFlags().Duration("timeout", benchmarkScriptTimeout, "How long each run of a script may take before it fails, and is counted in the Timeout Errors. Streamed and canceled runs are bounded by --stream-duration and --cancel-after instead")
+	BenchmarkCmd.PersistentFlags().Int("drop-after-timeouts", 0, "In all-clusters mode, drop a cluster from the remaining runs after it times out this many times. 0 never drops")
+	BenchmarkCmd.PersistentFlags().Bool("include-mutations", false, "Also run the scripts that deploy tracepoints, timing the deploy, the query and the teardown of each run")
+	BenchmarkCmd.PersistentFlags().Duration("mutation-deadline", 2*time.Minute, "How long the tracepoints of a mutation script may take to become ready, or to be removed")
+	BenchmarkCmd.PersistentFlags().Float64("max-variance", 0.35, "Mark a script's results as noisy if the stddev of its external exec time is more than this fraction of the mean. 0 disables the check")
+	BenchmarkCmd.PersistentFlags().Bool("retry-noisy", false, "Run noisy scripts once more, replacing their first results")
+	BenchmarkCmd.PersistentFlags().Bool("fail-on-noisy", false, "Exit with an error if any script's results are noisy")
+	BenchmarkCmd.PersistentFlags().StringSlice("sweep-start-time", nil, "Run every script that takes a start_time over each of these windows, ex: -1m,-5m,-15m,-60m, and summarize how it scales with the window")
+	BenchmarkCmd.PersistentFlags().Bool("compare-modes", false, "Run every script both through the cloud and directly against the vizier, and measure the passthrough overhead")
+	BenchmarkCmd.PersistentFlags().String("direct_vizier_addr", "", "The address of the vizier to connect to directly, for --compare-modes")
+	BenchmarkCmd.PersistentFlags().String("direct_vizier_key", "", "The key to authenticate with the vizier at direct_vizier_addr")
+	BenchmarkCmd.PersistentFlags().String("save-failures", "", "Save the partial results and the error of every failed run to a script__runN directory under this directory")
+	BenchmarkCmd.PersistentFlags().Int("save-failures-max-bytes", 10<<20, "The most response bytes to save for each failed run")
+	BenchmarkCmd.PersistentFlags().Bool("tui", false, "Show the results table, the progress and the recent errors in a terminal UI as the scripts run, rather than the log. Skipped if stdout is not a terminal")
+	BenchmarkCmd.PersistentFlags().String("schema-file", "", "A yaml file with the expected tables and columns of each script. Each script's schema is checked on its first successful run")
+	BenchmarkCmd.PersistentFlags().Bool("write-schema", false, "Write the schemas observed on this run to --schema-file, rather than checking them")
+	BenchmarkCmd.PersistentFlags().Bool("fail-on-error", false, "Exit with an error if any script has failed or oversized runs, or schema mismatches")
+	BenchmarkCmd.PersistentFlags().Int("max-bytes-per-run", 0, "Cancel any run that receives more than this many bytes, across all clusters, and skip the rest of a script's runs once two runs in a row are canceled. 0 never cancels")
+	BenchmarkCmd.PersistentFlags().Duration("cancel-after", 0, "Cancel every run after this long, and measure how long its stream takes to end. Mutation scripts are run as usual")
+	BenchmarkCmd.PersistentFlags().Duration("stream-duration", 0, "How long to let scripts that stream their results (df.stream()) run before canceling them. Streaming scripts are run like any other script if unset")
+	BenchmarkCmd.PersistentFlags().Bool("no-auto-compare", false, "Don't compare the results against the previous run on the same clusters with the same bundle, or stash them for the next run")
+	BenchmarkCmd.PersistentFlags().Int("auto-compare-keep", 1, "How many previous results to keep for each set of clusters and bundle. The newest is compared against")
+	BenchmarkCmd.PersistentFlags().String("config", "", "A yaml file that sets any of the other flags, by name. Flags set on the command line take precedence")
+	addBundleCacheFlags(BenchmarkCmd)
+	addConnectionFlags(BenchmarkCmd)
+	addAccumulationFlag(BenchmarkCmd)
+	addQuietFlag(BenchmarkCmd)
+	registerFlagCompletion(BenchmarkCmd, "scripts", completeScriptNames)
 	RootCmd.AddCommand(BenchmarkCmd)
+	// The config print command takes the same flags, to print the config of the same invocation.
+	ConfigPrintCmd.Flags().AddFlagSet(BenchmarkCmd.PersistentFlags())
 }
 
 // Distribution is the interface used to make the stats.
@@ -100,6 +159,10 @@ func (t *TimeDistribution) Append(v interface{}) {
 
 // Mean caluates the mean of the time distribution.
 func (t *TimeDistribution) Mean() time.Duration {
+	// Some distributions, such as the time to first row, have no samples if every run failed.
+	if len(t.Times) == 0 {
+		return 0
+	}
 	var sum time.Duration
 	for _, t := range t.Times {
 		sum += t
@@ -109,6 +172,9 @@ func (t *TimeDistribution) Mean() time.Duration {
 
 // Stddev calculates the stddev of the time distribution.
 func (t *TimeDistribution) Stddev() time.Duration {
+	if len(t.Times) == 0 {
+		return 0
+	}
 	var sumOfSquares float64
 	mean := t.Mean()
 	for _, t := range t.Times {
@@ -117,16 +183,79 @@ func (t *TimeDistribution) Stddev() time.Duration {
 	return time.Duration(math.Sqrt(sumOfSquares / float64(len(t.Times))))
 }
 
-// Summarize returns the Mean +/- stddev.
+// Percentile returns the pth percentile of the time distribution, for p between 0 and 1. When the percentile
+// falls between two samples, it is interpolated linearly between them, so the median of an even number of
+// samples is the mean of the middle two. A single sample is every percentile, and an empty distribution has a
+// percentile of 0.
+func (t *TimeDistribution) Percentile(p float64) time.Duration {
+	if len(t.Times) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), t.Times...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := math.Min(math.Max(p, 0), 1) * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return sorted[lo] + time.Duration(math.Round(float64(sorted[hi]-sorted[lo])*(rank-float64(lo))))
+}
+
+// P50 returns the median of the time distribution.
+func (t *TimeDistribution) P50() time.Duration {
+	return t.Percentile(0.5)
+}
+
+// P90 returns the 90th percentile of the time distribution.
+func (t *TimeDistribution) P90() time.Duration {
+	return t.Percentile(0.9)
+}
+
+// P99 returns the 99th percentile of the time distribution.
+func (t *TimeDistribution) P99() time.Duration {
+	return t.Percentile(0.99)
+}
+
+// Summarize returns the Mean +/- stddev, followed by the percentiles.
 func (t *TimeDistribution) Summarize() string {
+	return fmt.Sprintf("%s (%s)", t.summarizeMean(), t.summarizePercentiles())
+}
+
+func (t *TimeDistribution) summarizeMean() string {
 	return fmt.Sprintf("%v +/- %v", t.Mean().Round(time.Duration(10)*time.Microsecond), t.Stddev().Round(time.Duration(10)*time.Microsecond))
 }
 
-// ErrorDistribution contains Errors.
+func (t *TimeDistribution) summarizePercentiles() string {
+	p := t.roundedPercentiles()
+	return fmt.Sprintf("p50 %v, p90 %v, p99 %v", p[0], p[1], p[2])
+}
+
+// roundedPercentiles returns the P50, P90 and P99, rounded like the rest of the summary.
+func (t *TimeDistribution) roundedPercentiles() []time.Duration {
+	round := time.Duration(10) * time.Microsecond
+	return []time.Duration{t.P50().Round(round), t.P90().Round(round), t.P99().Round(round)}
+}
+
+// ErrorDistribution contains Errors. Each sample is nil for a successful run, and a *RunFailure otherwise.
 type ErrorDistribution struct {
 	Errors []error
 }
 
+// UnmarshalJSON restores the samples as *RunFailures, since the error interface can't be unmarshalled.
+func (d *ErrorDistribution) UnmarshalJSON(data []byte) error {
+	var samples struct {
+		Errors []*RunFailure
+	}
+	if err := json.Unmarshal(data, &samples); err != nil {
+		return err
+	}
+	d.Errors = make([]error, len(samples.Errors))
+	for i, f := range samples.Errors {
+		if f != nil {
+			d.Errors[i] = f
+		}
+	}
+	return nil
+}
+
 // Type returns the type of distribution this is, for json marshalling purposes.
 func (d *ErrorDistribution) Type() string {
 	return "Error"
@@ -204,63 +333,225 @@ func (d *BytesDistribution) Stddev() float64 {
 	return math.Sqrt(sumOfSquares / float64(len(d.Bytes)))
 }
 
-func createBundleReader(bundleFile string) (*script.BundleManager, error) {
-	br, err := script.NewBundleManagerWithOrg([]string{bundleFile}, "", "")
+// RateDistribution contains per second Rates and implements the Distribution interface.
+type RateDistribution struct {
+	Rates []float64
+}
+
+// Type returns the type of distribution this is, for json marshalling purposes.
+func (d *RateDistribution) Type() string {
+	return "Rate"
+}
+
+// Append a value to the rate distribution.
+func (d *RateDistribution) Append(v interface{}) {
+	r, ok := v.(float64)
+	if !ok {
+		log.Fatal("failed to append to RateDistribution")
+	}
+	d.Rates = append(d.Rates, r)
+}
+
+// Mean calculates the mean of the rate distribution.
+func (d *RateDistribution) Mean() float64 {
+	if len(d.Rates) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, r := range d.Rates {
+		sum += r
+	}
+	return sum / float64(len(d.Rates))
+}
+
+// Stddev calculates the stddev of the rate distribution.
+func (d *RateDistribution) Stddev() float64 {
+	if len(d.Rates) == 0 {
+		return 0
+	}
+	var sumOfSquares float64
+	mean := d.Mean()
+	for _, r := range d.Rates {
+		sumOfSquares += math.Pow(r-mean, 2)
+	}
+	return math.Sqrt(sumOfSquares / float64(len(d.Rates)))
+}
+
+// Summarize returns the Mean +/- stddev.
+func (d *RateDistribution) Summarize() string {
+	return fmt.Sprintf("%.2f/s +/- %.2f/s", d.Mean(), d.Stddev())
+}
+
+// RatioDistribution contains unitless Ratios and implements the Distribution interface.
+type RatioDistribution struct {
+	Ratios []float64
+}
+
+// Type returns the type of distribution this is, for json marshalling purposes.
+func (d *RatioDistribution) Type() string {
+	return "Ratio"
+}
+
+// Append a value to the ratio distribution.
+func (d *RatioDistribution) Append(v interface{}) {
+	r, ok := v.(float64)
+	if !ok {
+		log.Fatal("failed to append to RatioDistribution")
+	}
+	d.Ratios = append(d.Ratios, r)
+}
+
+// Mean calculates the mean of the ratio distribution.
+func (d *RatioDistribution) Mean() float64 {
+	return (&RateDistribution{d.Ratios}).Mean()
+}
+
+// Stddev calculates the stddev of the ratio distribution.
+func (d *RatioDistribution) Stddev() float64 {
+	return (&RateDistribution{d.Ratios}).Stddev()
+}
+
+// Summarize returns the Mean +/- stddev.
+func (d *RatioDistribution) Summarize() string {
+	return fmt.Sprintf("%.2fx +/- %.2fx", d.Mean(), d.Stddev())
+}
+
+// createBundleReader reads the bundle, fetching it with the given options if it is remote. It also returns the
+// hash of the bundle's contents, cached or fresh.
+func createBundleReader(bundleFile string, opts bundleFetchOptions) (*script.BundleManager, string, error) {
+	path, hash, cleanup, err := fetchBundle(bundleFile, opts)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return br, nil
+	defer cleanup()
+	br, err := script.NewBundleManagerWithOrg([]string{path}, "", "")
+	if err != nil {
+		return nil, "", err
+	}
+	return br, hash, nil
 }
 
 type execResults struct {
 	externalExecTime time.Duration
 	internalExecTime time.Duration
 	compileTime      time.Duration
-	scriptErr        error
-	numBytes         int
+	// The time the query waited before executing. Only set if hasQueueTime is, since not every Vizier
+	// reports it.
+	queueTime    time.Duration
+	hasQueueTime bool
+	scriptErr    error
+	numBytes     int
+	wireBytes    int
+	// The time from the start of the request until the first batch of rows arrived. Only set if
+	// receivedRows is.
+	timeToFirstRow time.Duration
+	receivedRows   bool
+	// The gaps between the arrivals of consecutive row batches.
+	batchGaps []time.Duration
+	// The number of rows and row batches received.
+	numRows    int
+	numBatches int
+	// The IDs that each cluster gave the run's query.
+	queryIDs map[uuid.UUID]string
+	// The clusters whose portion of the run timed out. They are left out of the other measurements.
+	timedOutClusters []uuid.UUID
+	// The metadata of the tables that the run output, from the first cluster that finished.
+	tables []*vizierpb.QueryMetadata
+	// The time from canceling the run until its stream ended. Only set for canceled runs, unless the run
+	// completedBeforeCancel.
+	cancellationLatency   time.Duration
+	completedBeforeCancel bool
+	// The bytes that the run received before it was canceled for exceeding --max-bytes-per-run, if it was.
+	oversizedBytes int
 }
 
-func executeScript(v []*vizier.Connector, execScript *script.ExecutableScript) (*execResults, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	execRes := execResults{}
-	start := time.Now()
-	// Start running the streaming script.
-	resp, err := vizier.RunScript(ctx, v, execScript, nil)
-	if err != nil {
-		return nil, err
+// batchGaps returns the gaps between consecutive receive times.
+func batchGaps(receivedAt []time.Time) []time.Duration {
+	if len(receivedAt) < 2 {
+		return nil
 	}
+	gaps := make([]time.Duration, len(receivedAt)-1)
+	for i := 1; i < len(receivedAt); i++ {
+		gaps[i-1] = receivedAt[i].Sub(receivedAt[i-1])
+	}
+	return gaps
+}
 
-	// Accumulate the streamed data and block until all data is received.
-	tw := vizier.NewStreamOutputAdapter(ctx, resp, vizier.FormatInMemory, nil)
-	err = tw.Finish()
+// maxAndP95 returns the largest and the 95th percentile (nearest-rank) of the durations, which must not be
+// empty.
+func maxAndP95(durations []time.Duration) (time.Duration, time.Duration) {
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(0.95 * float64(len(sorted))))
+	return sorted[len(sorted)-1], sorted[rank-1]
+}
 
-	// Calculate the execution time.
-	execRes.externalExecTime = time.Since(start)
-	if err != nil {
-		log.WithError(err).Infof("Error '%s' on '%s'", vizier.FormatErrorMessage(err), execScript.ScriptName)
-		// Store any error that comes up during execution.
-		execRes.scriptErr = err
-		return &execRes, nil
+// stopStream cancels the stream of a run, and waits for it to shut down, so that a run that timed out doesn't
+// leave its stream running into the next run.
+func stopStream(cancel context.CancelFunc, resp chan *vizier.ExecData) {
+	cancel()
+	for range resp {
 	}
+}
 
-	// Get the exec stats collected during the stream accumulation.
-	execStats, err := tw.ExecStats()
-	if err != nil {
-		execRes.scriptErr = err
-		return &execRes, nil
+// collectGarbage collects the garbage of the previous runs, so that the collection doesn't land in the timings of
+// the next run. When concurrentRuns other runs may be being measured at the same time, the collection is skipped,
+// since its pauses would land in their timings instead.
+func collectGarbage(concurrentRuns int) {
+	if concurrentRuns <= 1 {
+		runtime.GC()
+	}
+}
+
+// executeScript runs the script on every cluster, and combines their results. The run is canceled once it
+// receives more than maxBytes across its clusters, unless maxBytes is 0. The results are accumulated in the
+// given stream format, one of accumulationFormats. concurrentRuns is the most runs that may be measured at the
+// same time as this one.
+func executeScript(v []*vizier.Connector, execScript *script.ExecutableScript, timeout time.Duration, rec *responseRecorder,
+	maxBytes int, format string, concurrentRuns int) (*execResults, error) {
+	collectGarbage(concurrentRuns)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bc := newByteCap(maxBytes, cancel)
+	// Each cluster gets its own stream and deadline, so that a slow or hung cluster only times out its own
+	// portion of the run, rather than holding up the measurement of the others.
+	results := make([]*clusterResult, len(v))
+	errs := make([]error, len(v))
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i, c := range v {
+		wg.Add(1)
+		go func(i int, c *vizier.Connector) {
+			defer wg.Done()
+			results[i], errs[i] = executeOnCluster(ctx, c, execScript, start, timeout, rec, bc, format)
+		}(i, c)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
 	}
-	execRes.internalExecTime = time.Duration(execStats.Timing.ExecutionTimeNs)
-	execRes.compileTime = time.Duration(execStats.Timing.CompilationTimeNs)
-	execRes.numBytes = tw.TotalBytes()
-	return &execRes, nil
+
+	execRes := combineClusterResults(results, start)
+	// The run failed because it was canceled, which is its own outcome.
+	if execRes.oversizedBytes = bc.oversizedBytes(); execRes.oversizedBytes > 0 {
+		log.Infof("Canceled '%s' after it received %d bytes", execScript.ScriptName, execRes.oversizedBytes)
+		execRes.scriptErr = nil
+		execRes.tables = nil
+		return execRes, nil
+	}
+	if execRes.scriptErr != nil {
+		log.WithError(execRes.scriptErr).Infof("Error '%s' on '%s'", vizier.FormatErrorMessage(execRes.scriptErr), execScript.ScriptName)
+	}
+	return execRes, nil
 }
 
-func isAllowed(s *script.ExecutableScript, allowedScripts map[string]bool) bool {
+func isAllowed(s *script.ExecutableScript, allowedScripts map[string]bool, includeMutations bool) bool {
 	if disallowedScripts[s.ScriptName] {
 		return false
 	}
-	if isMutation(s) {
+	if isMutation(s) && !includeMutations {
 		return false
 	}
 	if len(allowedScripts) == 0 {
@@ -275,10 +566,22 @@ func isMutation(s *script.ExecutableScript) bool {
 
 type distributionMap map[string]Distribution
 type distributionContainer struct {
-	Type      string
-	TimeDist  *TimeDistribution  `json:",omitempty"`
-	BytesDist *BytesDistribution `json:",omitempty"`
-	ErrorDist *ErrorDistribution `json:",omitempty"`
+	Type     string
+	TimeDist *TimeDistribution `json:",omitempty"`
+	// The percentiles of the TimeDist, for readers of the json. They are derived from its times, so they are
+	// ignored when the json is read back.
+	Percentiles *timePercentiles   `json:",omitempty"`
+	BytesDist   *BytesDistribution `json:",omitempty"`
+	ErrorDist   *ErrorDistribution `json:",omitempty"`
+	RateDist    *RateDistribution  `json:",omitempty"`
+	RatioDist   *RatioDistribution `json:",omitempty"`
+}
+
+// timePercentiles are the percentiles of a TimeDistribution.
+type timePercentiles struct {
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
 }
 
 func (dm *distributionMap) MarshalJSON() ([]byte, error) {
@@ -291,12 +594,21 @@ func (dm *distributionMap) MarshalJSON() ([]byte, error) {
 		case (&TimeDistribution{}).Type():
 			timeDist, _ := dist.(*TimeDistribution)
 			containers[k].TimeDist = timeDist
+			if len(timeDist.Times) > 0 {
+				containers[k].Percentiles = &timePercentiles{P50: timeDist.P50(), P90: timeDist.P90(), P99: timeDist.P99()}
+			}
 		case (&BytesDistribution{}).Type():
 			byteDist, _ := dist.(*BytesDistribution)
 			containers[k].BytesDist = byteDist
 		case (&ErrorDistribution{}).Type():
 			errorDist, _ := dist.(*ErrorDistribution)
 			containers[k].ErrorDist = errorDist
+		case (&RateDistribution{}).Type():
+			rateDist, _ := dist.(*RateDistribution)
+			containers[k].RateDist = rateDist
+		case (&RatioDistribution{}).Type():
+			ratioDist, _ := dist.(*RatioDistribution)
+			containers[k].RatioDist = ratioDist
 		}
 	}
 	return json.Marshal(containers)
@@ -317,6 +629,10 @@ func (dm *distributionMap) UnmarshalJSON(data []byte) error {
 			(*dm)[k] = container.BytesDist
 		case (&ErrorDistribution{}).Type():
 			(*dm)[k] = container.ErrorDist
+		case (&RateDistribution{}).Type():
+			(*dm)[k] = container.RateDist
+		case (&RatioDistribution{}).Type():
+			(*dm)[k] = container.RatioDist
 		}
 	}
 	return nil
@@ -326,12 +642,59 @@ func (dm *distributionMap) UnmarshalJSON(data []byte) error {
 type ScriptExecData struct {
 	// The Name of the script we're running.
 	Name string
+	// ScriptHash is the sha256 of the PxL and vis spec that were run, to tell whether results with the same name
+	// came from the same version of the script.
+	ScriptHash string `json:",omitempty"`
+	// Streamed is set for scripts that were canceled after streaming for a fixed duration. Their metrics
+	// are over that duration, so they can't be compared with those of scripts that ran to completion.
+	Streamed bool `json:",omitempty"`
+	// Mutation is set for scripts that deploy tracepoints. Their runs are split into deploying the tracepoints,
+	// running the query, and removing the tracepoints, and each phase has its own distributions.
+	Mutation bool `json:",omitempty"`
+	// Canceled is set for scripts that were canceled after --cancel-after, to measure how long they take to stop.
+	// CompletedBeforeCancel counts the runs that ended before they could be canceled.
+	Canceled              bool `json:",omitempty"`
+	CompletedBeforeCancel int  `json:",omitempty"`
+	// PromptedArgs are the values given at the prompt for the script's variables, so that the run can be
+	// reproduced.
+	PromptedArgs map[string]string `json:",omitempty"`
+	// RelativeStddev is the stddev of the external exec time relative to its mean. Noisy is set if it exceeds
+	// --max-variance, in which case the results aren't trustworthy. Retried is set if the runs were repeated
+	// because the first set was noisy.
+	RelativeStddev float64 `json:",omitempty"`
+	Noisy          bool    `json:",omitempty"`
+	Retried        bool    `json:",omitempty"`
+	// Sweep holds the runs of a script over each of the windows of --sweep-start-time, keyed by start time, and
+	// Scaling summarizes how the script scales with the window. The runs themselves are keyed by the names
+	// returned by sweepName while the benchmark runs, and only nested under their script in the json output.
+	Sweep   map[string]*ScriptExecData `json:",omitempty"`
+	Scaling *SweepScaling              `json:",omitempty"`
+	// DroppedClusters are the clusters dropped from the later runs for timing out too often, keyed by
+	// cluster ID, with the index of the run after which they were dropped.
+	DroppedClusters map[string]int `json:",omitempty"`
+	// Schema is the schema of the script's tables, as observed on its first successful run, if --schema-file is
+	// set. SchemaMismatches are its differences from the expected schema.
+	Schema           []TableSchema `json:",omitempty"`
+	SchemaMismatches []string      `json:",omitempty"`
 	// The Distributions of Statistics to record.
 	Distributions distributionMap
+	// Failures records the status of every failed run, for debugging.
+	Failures []*RunFailure `json:",omitempty"`
+	// ErrorCounts counts the runs that failed with each error message, with the parts of the messages that vary
+	// from run to run, such as IDs and timestamps, replaced by placeholders.
+	ErrorCounts map[string]int `json:",omitempty"`
+	// OversizedRuns are the bytes received by each run that was canceled for exceeding --max-bytes-per-run. Those
+	// runs were cut short, so they aren't in the Distributions. SkippedRuns counts the runs that weren't run at
+	// all, once maxConsecutiveOversizedRuns runs in a row were oversized.
+	OversizedRuns   []int `json:",omitempty"`
+	SkippedRuns     int   `json:",omitempty"`
+	oversizedInARow int
 }
 
 // stdoutTableWriter writes the execStats out to a table in stdout. Implements ExecStatsWriter.
 type stdoutTableWriter struct {
+	// out is where the table is written. Defaults to stdout.
+	out io.Writer
 }
 
 func sortByKeys(data *map[string]*ScriptExecData) []*ScriptExecData {
@@ -354,32 +717,82 @@ func (s *stdoutTableWriter) Write(data *[]*ScriptExecData) error {
 		return errors.New("Data has no elements")
 	}
 
-	// Setup keys to use across all distributions.
-	keys := make([]string, 0)
-	for k := range (*data)[0].Distributions {
+	out := s.out
+	if out == nil {
+		out = os.Stdout
+	}
+	header, rows := tableRows(*data)
+	table := tablewriter.NewWriter(out)
+	table.SetHeader(header)
+	table.AppendBulk(rows)
+	table.Render()
+	return nil
+}
+
+// tableRows returns the header and the rows of the table of the scripts' distributions.
+func tableRows(data []*ScriptExecData) ([]string, [][]string) {
+	// Setup keys to use across all distributions. Some distributions, such as the queue time, are only added
+	// once a script's runs report them, so the keys are those of every script.
+	keySet := make(map[string]bool)
+	for _, d := range data {
+		for k := range d.Distributions {
+			keySet[k] = true
+		}
+	}
+	keys := make([]string, 0, len(keySet))
+	for k := range keySet {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
 
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader(append([]string{"Name"}, keys...))
+	// Each percentile of the time distributions goes in a column of its own, next to the mean, so that they
+	// can be sorted and parsed.
+	isTime := make(map[string]bool)
+	for _, d := range data {
+		for k, dist := range d.Distributions {
+			if _, ok := dist.(*TimeDistribution); ok {
+				isTime[k] = true
+			}
+		}
+	}
+	header := []string{"Name"}
+	for _, k := range keys {
+		header = append(header, k)
+		if isTime[k] {
+			header = append(header, k+" P50", k+" P90", k+" P99")
+		}
+	}
 
-	// Iterate through data and create table rows.
-	for _, d := range *data {
+	rows := make([][]string, 0, len(data))
+	for _, d := range data {
 		row := []string{
 			d.Name,
 		}
 		for _, k := range keys {
 			val, ok := d.Distributions[k]
-			if !ok {
-				return fmt.Errorf("Missing key '%s' for script '%s'", k, d.Name)
+			timeDist, isTimeDist := val.(*TimeDistribution)
+			switch {
+			case !ok:
+				row = append(row, "-")
+			case isTimeDist:
+				row = append(row, timeDist.summarizeMean())
+			default:
+				row = append(row, val.Summarize())
+			}
+			if !isTime[k] {
+				continue
+			}
+			if isTimeDist && len(timeDist.Times) > 0 {
+				for _, p := range timeDist.roundedPercentiles() {
+					row = append(row, p.String())
+				}
+			} else {
+				row = append(row, "-", "-", "-")
 			}
-			row = append(row, val.Summarize())
 		}
-		table.Append(row)
+		rows = append(rows, row)
 	}
-	table.Render()
-	return nil
+	return header, rows
 }
 
 func getArgDefaults(v []*vizier.Connector) (map[string]script.Arg, error) {
@@ -440,77 +853,49 @@ px.display(df[['pod', 'service', 'namespace', 'node']])
 	return argMap, nil
 }
 
-func benchmarkCmd(cmd *cobra.Command) {
-	// Set the logger to use stderr so that json output can be consumed without log lines.
-	log.SetOutput(os.Stderr)
-
-	repeatCount, _ := cmd.Flags().GetInt("num_runs")
-	cloudAddr, _ := cmd.Flags().GetString("cloud_addr")
-	bundleFile, _ := cmd.Flags().GetString("bundle")
-	allClusters, _ := cmd.Flags().GetBool("all-clusters")
-	selectedCluster, _ := cmd.Flags().GetString("cluster")
-	selectedScripts, _ := cmd.Flags().GetStringSlice("scripts")
-	outputFmt, _ := cmd.Flags().GetString("output")
-	splitByFunc, _ := cmd.Flags().GetBool("split-funcs")
-
-	clusterID := uuid.FromStringOrNil(selectedCluster)
-
-	if !allowedOutputFmts[outputFmt] {
-		log.WithField("output", outputFmt).Fatal("invalid output format")
-	}
-
-	br, err := createBundleReader(bundleFile)
-	if err != nil {
-		log.WithError(err).Fatal("Failed to read script bundle")
-	}
-
-	scripts := br.GetScripts()
-
-	if !allClusters && clusterID == uuid.Nil {
-		clusterID, err = vizier.FirstHealthyVizier(cloudAddr)
-		if err != nil {
-			log.WithError(err).Fatal("Could not fetch healthy vizier")
-		}
-	}
-
-	allowedScripts := make(map[string]bool)
-	for _, s := range selectedScripts {
-		allowedScripts[s] = true
-	}
-
-	vzrConns := vizier.MustConnectHealthyDefaultVizier(cloudAddr, allClusters, clusterID)
-
-	argDefaults, err := getArgDefaults(vzrConns)
-	if err != nil {
-		log.WithError(err).Fatal("Failed to get arg defaults")
-	}
-
+// resolveScripts returns the allowed scripts, with their args resolved from the defaults, and the variables in
+// their vis specs. Variables that can't be resolved are asked for with the prompter, if there is one. If
+// splitByFunc is set, each function in a script's vis spec becomes a script of its own. The given scripts are
+// not modified, so they can be resolved again with other defaults. Mutation scripts are only included if
+// includeMutations is set.
+func resolveScripts(scripts []*script.ExecutableScript, allowedScripts map[string]bool, argDefaults map[string]script.Arg,
+	splitByFunc bool, includeMutations bool, prompter *argPrompter) []*script.ExecutableScript {
 	viableScripts := make([]*script.ExecutableScript, 0)
-	for _, s := range scripts {
-		if !isAllowed(s, allowedScripts) {
+	for _, orig := range scripts {
+		if !isAllowed(orig, allowedScripts, includeMutations) {
 			continue
 		}
 
+		s := *orig
 		s.Args = make(map[string]script.Arg)
 		for k, v := range argDefaults {
 			s.Args[k] = v
 		}
 
-		for _, v := range s.Vis.Variables {
-			if _, ok := s.Args[v.Name]; ok {
-				continue
-			}
-			value := ""
-			if len(v.ValidValues) > 0 {
-				value = v.ValidValues[0]
-			}
-			if v.DefaultValue != nil {
-				value = v.DefaultValue.Value
+		if s.Vis != nil {
+			for _, v := range s.Vis.Variables {
+				if _, ok := s.Args[v.Name]; ok {
+					continue
+				}
+				value := ""
+				if len(v.ValidValues) > 0 {
+					value = v.ValidValues[0]
+				}
+				if v.DefaultValue != nil {
+					value = v.DefaultValue.Value
+				}
+				if v.DefaultValue == nil && len(v.ValidValues) == 0 && prompter != nil {
+					var err error
+					value, err = prompter.prompt(s.ScriptName, v)
+					if err != nil {
+						log.WithError(err).WithField("variable", v.Name).Fatal("Failed to read the value of the variable")
+					}
+				}
+				s.Args[v.Name] = script.Arg{Name: v.Name, Value: value}
 			}
-			s.Args[v.Name] = script.Arg{Name: v.Name, Value: value}
 		}
 		if !splitByFunc || s.Vis == nil {
-			viableScripts = append(viableScripts, s)
+			viableScripts = append(viableScripts, &s)
 			continue
 		}
 
@@ -534,7 +919,6 @@ func benchmarkCmd(cmd *cobra.Command) {
 			}
 		}
 
-		s.Vis.Widgets = nil
 		for _, f := range scriptFuncs {
 			newScript := &script.ExecutableScript{
 				ScriptString: s.ScriptString,
@@ -548,28 +932,398 @@ func benchmarkCmd(cmd *cobra.Command) {
 			viableScripts = append(viableScripts, newScript)
 		}
 	}
+	return viableScripts
+}
 
-	log.Infof("Running %d scripts %d times each", len(viableScripts), repeatCount)
-	data := make(map[string]*ScriptExecData)
-	scriptsToRun := make([]*script.ExecutableScript, 0)
-	for _, s := range viableScripts {
-		for i := 0; i < repeatCount; i++ {
-			scriptsToRun = append(scriptsToRun, s)
+func newScriptExecData(name string) *ScriptExecData {
+	externalExecTiming := make([]time.Duration, 0)
+	internalExecTiming := make([]time.Duration, 0)
+	compilationTiming := make([]time.Duration, 0)
+	scriptErrors := make([]error, 0)
+	numBytes := make([]int, 0)
+	timeToFirstRow := make([]time.Duration, 0)
+	errorsBeforeFirstRow := make([]error, 0)
+	maxBatchGaps := make([]time.Duration, 0)
+	p95BatchGaps := make([]time.Duration, 0)
+	return &ScriptExecData{
+		Name: name,
+		Distributions: addFailureClassDistributions(distributionMap{
+			execTimeExternalLabel:     &TimeDistribution{externalExecTiming},
+			execTimeInternalLabel:     &TimeDistribution{internalExecTiming},
+			compTimeLabel:             &TimeDistribution{compilationTiming},
+			numErrorsLabel:            &ErrorDistribution{scriptErrors},
+			numBytesLabel:             &BytesDistribution{numBytes},
+			wireBytesLabel:            &BytesDistribution{make([]int, 0)},
+			compressionRatioLabel:     &RatioDistribution{make([]float64, 0)},
+			timeToFirstRowLabel:       &TimeDistribution{timeToFirstRow},
+			errorsBeforeFirstRowLabel: &ErrorDistribution{errorsBeforeFirstRow},
+			maxBatchGapLabel:          &TimeDistribution{maxBatchGaps},
+			p95BatchGapLabel:          &TimeDistribution{p95BatchGaps},
+		}),
+	}
+}
+
+// recordResults appends the results of a run to the script's distributions.
+func recordResults(data *ScriptExecData, res *execResults) {
+	if res.oversizedBytes > 0 {
+		recordOversized(data, res)
+		return
+	}
+	data.oversizedInARow = 0
+	dists := data.Distributions
+	runErr := recordFailure(data, res.scriptErr)
+	dists[numErrorsLabel].Append(runErr)
+	dists[execTimeExternalLabel].Append(res.externalExecTime)
+	dists[compTimeLabel].Append(res.compileTime)
+	dists[execTimeInternalLabel].Append(res.internalExecTime)
+	recordBytes(dists, res)
+	// The queue time distributions are only added once the Vizier reports the queue time.
+	if res.hasQueueTime {
+		if _, ok := dists[queueTimeLabel]; !ok {
+			dists[queueTimeLabel] = &TimeDistribution{make([]time.Duration, 0)}
+			dists[pureExecTimeLabel] = &TimeDistribution{make([]time.Duration, 0)}
 		}
-		externalExecTiming := make([]time.Duration, 0)
-		internalExecTiming := make([]time.Duration, 0)
-		compilationTiming := make([]time.Duration, 0)
-		scriptErrors := make([]error, 0)
-		numBytes := make([]int, 0)
-		data[s.ScriptName] = &ScriptExecData{
-			Name: s.ScriptName,
-			Distributions: distributionMap{
-				execTimeExternalLabel: &TimeDistribution{externalExecTiming},
-				execTimeInternalLabel: &TimeDistribution{internalExecTiming},
-				compTimeLabel:         &TimeDistribution{compilationTiming},
-				numErrorsLabel:        &ErrorDistribution{scriptErrors},
-				numBytesLabel:         &BytesDistribution{numBytes},
-			},
+		dists[queueTimeLabel].Append(res.queueTime)
+		dists[pureExecTimeLabel].Append(res.internalExecTime - res.queueTime)
+	}
+	if res.receivedRows {
+		dists[timeToFirstRowLabel].Append(res.timeToFirstRow)
+		dists[errorsBeforeFirstRowLabel].Append(nil)
+	} else {
+		dists[errorsBeforeFirstRowLabel].Append(runErr)
+	}
+	// Runs with fewer than two batches have no gaps, and contribute nothing.
+	if len(res.batchGaps) > 0 {
+		maxGap, p95Gap := maxAndP95(res.batchGaps)
+		dists[maxBatchGapLabel].Append(maxGap)
+		dists[p95BatchGapLabel].Append(p95Gap)
+	}
+}
+
+// recordBytes appends the decoded and the wire bytes of a run to the distributions. Runs that received nothing
+// have no compression ratio.
+func recordBytes(dists distributionMap, res *execResults) {
+	dists[numBytesLabel].Append(res.numBytes)
+	dists[wireBytesLabel].Append(res.wireBytes)
+	if res.wireBytes > 0 && res.numBytes > 0 {
+		dists[compressionRatioLabel].Append(float64(res.numBytes) / float64(res.wireBytes))
+	}
+}
+
+// benchmarkEndpoint is a Pixie Cloud that the scripts are run through, along with the results of running them.
+type benchmarkEndpoint struct {
+	cloudAddr string
+	// The address of the Vizier, for endpoints that connect to it directly, rather than through the cloud.
+	directAddr string
+	// The connection mode, when comparing the modes with --compare-modes.
+	mode  string
+	conns []*vizier.Connector
+	// The scripts to run through this cloud, keyed by name. Their args are resolved against this cloud's
+	// Vizier, so they may differ between clouds.
+	scripts map[string]*script.ExecutableScript
+	data    map[string]*ScriptExecData
+	// The number of runs in which each cluster timed out.
+	clusterTimeouts map[uuid.UUID]int
+	// The args discovered with --auto-args, if set.
+	autoArgs *DiscoveredArgs
+}
+
+// connectEndpoint connects to the Viziers of the given clusters through the given cloud, as by connectClusters,
+// and resolves the scripts to run through it. If autoArgs is set, the values of the namespace, pod, service and
+// node variables are discovered separately, and scripts that no value is found for are skipped.
+func connectEndpoint(cloudAddr string, allClusters bool, clusters []*cloudpb.ClusterInfo, scripts []*script.ExecutableScript,
+	allowedScripts map[string]bool, splitByFunc bool, includeMutations bool, autoArgs bool, prompter *argPrompter,
+	connOpts []vizier.ConnectorOption) *benchmarkEndpoint {
+	vzrConns, err := connectClusters(cloudAddr, allClusters, clusters, connOpts)
+	if err != nil {
+		log.WithError(err).WithField("cloud_addr", cloudAddr).Fatal("Failed to connect to vizier")
+	}
+
+	var discovered *DiscoveredArgs
+	var argDefaults map[string]script.Arg
+	if autoArgs {
+		discovered = discoverArgs(vzrConns)
+		log.WithField("cloud_addr", cloudAddr).Infof("Discovered args: %v", discovered.Values)
+		argDefaults = discovered.argDefaults()
+	} else {
+		argDefaults, err = getArgDefaults(vzrConns)
+		if err != nil {
+			log.WithError(err).WithField("cloud_addr", cloudAddr).Fatal("Failed to get arg defaults")
+		}
+	}
+
+	ep := &benchmarkEndpoint{
+		cloudAddr:       cloudAddr,
+		conns:           vzrConns,
+		scripts:         make(map[string]*script.ExecutableScript),
+		data:            make(map[string]*ScriptExecData),
+		clusterTimeouts: make(map[uuid.UUID]int),
+		autoArgs:        discovered,
+	}
+	resolved := resolveScripts(scripts, allowedScripts, argDefaults, splitByFunc, includeMutations, prompter)
+	if discovered != nil {
+		resolved = discovered.skipUndiscovered(resolved)
+	}
+	for _, s := range resolved {
+		ep.scripts[s.ScriptName] = s
+		ep.data[s.ScriptName] = newScriptExecData(s.ScriptName)
+		ep.data[s.ScriptName].PromptedArgs = prompter.answersFor(s)
+	}
+	return ep
+}
+
+// label names the endpoint in the output.
+func (ep *benchmarkEndpoint) label() string {
+	switch ep.mode {
+	case modePassthrough:
+		return fmt.Sprintf("%s (%s)", ep.mode, ep.cloudAddr)
+	case modeDirect:
+		return fmt.Sprintf("%s (%s)", ep.mode, ep.directAddr)
+	}
+	return ep.cloudAddr
+}
+
+// resetData replaces the results of the named script with empty ones, with the distributions for how the
+// script is run.
+func (ep *benchmarkEndpoint) resetData(name string, streamDuration, cancelAfter time.Duration) {
+	s := ep.scripts[name]
+	var data *ScriptExecData
+	switch {
+	case isMutation(s):
+		data = newMutationScriptExecData(name)
+	case cancelAfter != 0:
+		data = newCanceledScriptExecData(name)
+	case streamDuration != 0 && isStreaming(s):
+		data = newStreamedScriptExecData(name)
+	default:
+		data = newScriptExecData(name)
+	}
+	data.PromptedArgs = ep.data[name].PromptedArgs
+	data.DroppedClusters = ep.data[name].DroppedClusters
+	// The schema is only checked once, so its check is kept along with it.
+	data.Schema = ep.data[name].Schema
+	data.SchemaMismatches = ep.data[name].SchemaMismatches
+	if dist, ok := ep.data[name].Distributions[schemaMismatchesLabel]; ok {
+		data.Distributions[schemaMismatchesLabel] = dist
+	}
+	ep.data[name] = data
+}
+
+// endpointDiffs diffs the results of each script through the baseline cloud against the results through
+// another cloud.
+func endpointDiffs(baseline, other *benchmarkEndpoint) ([]*scriptExecDiff, error) {
+	names := make([]string, 0, len(baseline.data))
+	for name := range baseline.data {
+		if _, ok := other.data[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	diffs := make([]*scriptExecDiff, 0, len(names))
+	for _, name := range names {
+		d := &scriptExecDiff{
+			Name:     name,
+			Streamed: baseline.data[name].Streamed,
+			Diffs:    make(map[string]DistributionDiff),
+		}
+		for distName, baseDist := range baseline.data[name].Distributions {
+			otherDist, ok := other.data[name].Distributions[distName]
+			if !ok {
+				continue
+			}
+			diff, err := baseDist.Diff(otherDist)
+			if err != nil {
+				return nil, err
+			}
+			d.Diffs[distName] = diff
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs, nil
+}
+
+func benchmarkCmd(cmd *cobra.Command) {
+	start := time.Now()
+	// Set the logger to use stderr so that json output can be consumed without log lines.
+	log.SetOutput(os.Stderr)
+
+	if err := applyConfigFile(cmd.Flags()); err != nil {
+		log.WithError(err).Fatal("Failed to apply the config file")
+	}
+	repeatCount, _ := cmd.Flags().GetInt("num_runs")
+	warmupRuns, _ := cmd.Flags().GetInt("warmup_runs")
+	parallelism, _ := cmd.Flags().GetInt("parallelism")
+	cloudAddrs, _ := cmd.Flags().GetStringSlice("cloud_addr")
+	authFiles, _ := cmd.Flags().GetStringSlice("auth_file")
+	bundleFile, _ := cmd.Flags().GetString("bundle")
+	allClusters, _ := cmd.Flags().GetBool("all-clusters")
+	selectedClusters, _ := cmd.Flags().GetStringSlice("cluster")
+	selectedScripts, _ := cmd.Flags().GetStringSlice("scripts")
+	outputFmt, _ := cmd.Flags().GetString("output")
+	splitByFunc, _ := cmd.Flags().GetBool("split-funcs")
+	streamDuration, _ := cmd.Flags().GetDuration("stream-duration")
+	cancelAfter, _ := cmd.Flags().GetDuration("cancel-after")
+	interactive, _ := cmd.Flags().GetBool("interactive")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	dropAfterTimeouts, _ := cmd.Flags().GetInt("drop-after-timeouts")
+	includeMutations, _ := cmd.Flags().GetBool("include-mutations")
+	mutationDeadline, _ := cmd.Flags().GetDuration("mutation-deadline")
+	maxVariance, _ := cmd.Flags().GetFloat64("max-variance")
+	retryNoisy, _ := cmd.Flags().GetBool("retry-noisy")
+	failOnNoisy, _ := cmd.Flags().GetBool("fail-on-noisy")
+	sweepStartTimes, _ := cmd.Flags().GetStringSlice("sweep-start-time")
+	compareModes, _ := cmd.Flags().GetBool("compare-modes")
+	directVzAddr, _ := cmd.Flags().GetString("direct_vizier_addr")
+	directVzKey, _ := cmd.Flags().GetString("direct_vizier_key")
+	saveFailuresDir, _ := cmd.Flags().GetString("save-failures")
+	saveFailuresMaxBytes, _ := cmd.Flags().GetInt("save-failures-max-bytes")
+	showTUI, _ := cmd.Flags().GetBool("tui")
+	schemaFile, _ := cmd.Flags().GetString("schema-file")
+	writeSchema, _ := cmd.Flags().GetBool("write-schema")
+	failOnError, _ := cmd.Flags().GetBool("fail-on-error")
+	quiet, _ := cmd.Flags().GetBool("quiet")
+	maxBytesPerRun, _ := cmd.Flags().GetInt("max-bytes-per-run")
+	noAutoCompare, _ := cmd.Flags().GetBool("no-auto-compare")
+	autoCompareKeep, _ := cmd.Flags().GetInt("auto-compare-keep")
+	autoArgs, _ := cmd.Flags().GetBool("auto-args")
+	accumulation, err := accumulationFormatFromFlags(cmd)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid --accumulation")
+	}
+
+	var expectations schemaExpectations
+	if writeSchema && schemaFile == "" {
+		log.Fatal("--write-schema requires --schema-file")
+	}
+	if schemaFile != "" && !writeSchema {
+		var err error
+		expectations, err = readSchemaExpectations(schemaFile)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to read the schema file")
+		}
+	}
+
+	if !allowedOutputFmts[outputFmt] {
+		log.WithField("output", outputFmt).Fatal("invalid output format")
+	}
+	if allClusters && len(selectedClusters) > 0 {
+		log.Fatal("--all-clusters can't be combined with --cluster")
+	}
+	if timeout <= 0 {
+		log.Fatal("--timeout must be positive")
+	}
+	if warmupRuns < 0 {
+		log.Fatal("--warmup_runs can't be negative")
+	}
+	if parallelism < 1 {
+		log.Fatal("--parallelism must be at least 1")
+	}
+	if autoCompareKeep < 1 {
+		log.Fatal("--auto-compare-keep must be at least 1")
+	}
+	if len(cloudAddrs) == 0 {
+		log.Fatal("at least one cloud_addr is required")
+	}
+	if len(authFiles) > 0 && len(authFiles) != len(cloudAddrs) {
+		log.Fatal("auth_file must be given once for each cloud_addr")
+	}
+	// Each cloud has its own users, so it needs its own credentials.
+	for i, authFile := range authFiles {
+		creds, err := auth.LoadCredentials(authFile)
+		if err != nil {
+			log.WithError(err).WithField("auth_file", authFile).Fatal("Failed to load credentials")
+		}
+		auth.SetCloudCredentials(cloudAddrs[i], creds)
+	}
+	if compareModes {
+		// A direct connection reaches a single vizier, through no particular cloud.
+		if directVzAddr == "" {
+			log.Fatal("--compare-modes requires direct_vizier_addr")
+		}
+		if len(cloudAddrs) > 1 || allClusters || len(selectedClusters) > 1 {
+			log.Fatal("--compare-modes runs through a single cloud_addr, on a single cluster")
+		}
+	}
+	for _, startTime := range sweepStartTimes {
+		if _, err := windowSize(startTime); err != nil {
+			log.WithError(err).Fatal("sweep-start-time must be relative start times, ex: -5m")
+		}
+	}
+	connOpts, err := connectorOptions(cmd)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid connection flags")
+	}
+	// Every selected cluster is checked before any of them are run on, so that they can all be fixed at once.
+	clusters, err := validateClusters(cloudAddrs, selectedClusters)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid --cluster")
+	}
+	var prompter *argPrompter
+	if interactive {
+		// Without a terminal, nobody can answer the prompts.
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			log.Fatal("--interactive requires stdin to be a terminal")
+		}
+		// Prompt on stderr, which keeps the json output on stdout clean.
+		prompter = newArgPrompter(os.Stdin, os.Stderr)
+	}
+
+	br, bundleHash, err := createBundleReader(bundleFile, bundleFetchOptionsFromFlags(cmd))
+	if err != nil {
+		log.WithError(err).Fatal("Failed to read script bundle")
+	}
+
+	scripts := br.GetScripts()
+
+	allowedScripts := make(map[string]bool)
+	for _, s := range selectedScripts {
+		allowedScripts[s] = true
+	}
+
+	endpoints := make([]*benchmarkEndpoint, len(cloudAddrs))
+	for i, cloudAddr := range cloudAddrs {
+		endpoints[i] = connectEndpoint(cloudAddr, allClusters, clusters[cloudAddr], scripts, allowedScripts, splitByFunc,
+			includeMutations, autoArgs, prompter, connOpts)
+		if len(sweepStartTimes) > 0 {
+			endpoints[i].sweepStartTimes(sweepStartTimes)
+		}
+		for name, s := range endpoints[i].scripts {
+			if isMutation(s) || cancelAfter != 0 || (streamDuration != 0 && isStreaming(s)) {
+				endpoints[i].resetData(name, streamDuration, cancelAfter)
+			}
+		}
+	}
+	if compareModes {
+		direct, err := connectDirectEndpoint(endpoints[0], directVzAddr, directVzKey, streamDuration, cancelAfter, connOpts)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to connect directly to the vizier")
+		}
+		// The run loop alternates between the endpoints, which spreads any drift over time across both modes.
+		endpoints = append(endpoints, direct)
+	}
+	// Runs through a single cloud are compared against the previous run on the same clusters, with the same bundle.
+	stashDir := ""
+	if !noAutoCompare && len(endpoints) == 1 {
+		if cacheDir, err := exectimeCacheDir(); err != nil {
+			log.WithError(err).Warn("No cache dir, skipping the comparison against the previous run")
+		} else {
+			stashDir = autoCompareDir(cacheDir, endpointClusterIDs(endpoints[0]), bundleHash)
+		}
+	}
+
+	// Every cloud runs the same scripts, since they come from the same bundle.
+	scriptNames := make([]string, 0, len(endpoints[0].scripts))
+	for name := range endpoints[0].scripts {
+		scriptNames = append(scriptNames, name)
+	}
+	sort.Strings(scriptNames)
+
+	log.Infof("Running %d scripts %d times each through %d clouds, %d at a time", len(scriptNames), repeatCount, len(endpoints), parallelism)
+	scriptsToRun := make([]string, 0)
+	for _, name := range scriptNames {
+		for i := 0; i < repeatCount; i++ {
+			scriptsToRun = append(scriptsToRun, name)
 		}
 	}
 
@@ -579,38 +1333,373 @@ func benchmarkCmd(cmd *cobra.Command) {
 		scriptsToRun[i], scriptsToRun[j] = scriptsToRun[j], scriptsToRun[i]
 	})
 
-	// Run scripts in shuffled order.
-	for _, s := range scriptsToRun {
-		// Run script.
-		log.WithField("script", s.ScriptName).Infof("Executing script")
-		res, err := executeScript(vzrConns, s)
+	// Warmup runs are run like any other run of the script, but their results are only checked for errors.
+	warmUp(endpoints, scriptNames, warmupRuns, func(ep *benchmarkEndpoint, name string) error {
+		s := ep.scripts[name]
+		var res *execResults
+		var err error
+		switch d := ep.data[name]; {
+		case d.Mutation:
+			var mutationRes *mutationResults
+			mutationRes, err = executeMutationScript(ep.conns, s, timeout, mutationDeadline, maxBytesPerRun, accumulation, 1)
+			if err == nil && mutationRes.mutationErr != nil {
+				return mutationRes.mutationErr
+			}
+			if err == nil {
+				res = mutationRes.query
+			}
+		case d.Canceled:
+			res, err = executeCanceledScript(ep.conns, s, cancelAfter, 1)
+		case d.Streamed:
+			res, err = executeStreamingScript(ep.conns, s, streamDuration, 1)
+		default:
+			res, err = executeScript(ep.conns, s, timeout, nil, maxBytesPerRun, accumulation, 1)
+		}
+		if err != nil || res == nil {
+			return err
+		}
+		return res.scriptErr
+	})
+
+	var tui *benchmarkTUI
+	if showTUI && useTUI() {
+		totalRuns := 0
+		for _, name := range scriptsToRun {
+			for _, ep := range endpoints {
+				if _, ok := ep.scripts[name]; ok {
+					totalRuns++
+				}
+			}
+		}
+		screen, err := tcell.NewScreen()
+		if err == nil {
+			tui, err = newBenchmarkTUI(screen, endpoints, totalRuns)
+		}
 		if err != nil {
-			log.WithError(err).Fatalf("Failed to execute script")
+			log.WithError(err).Warn("Failed to start the TUI, falling back to the log")
+		}
+	} else if showTUI {
+		log.Info("Stdout is not a terminal, skipping the TUI")
+	}
+
+	// With --parallelism, the runs of different scripts overlap. Only the scripts are executed concurrently: the
+	// results, the clusters of each endpoint and the TUI are only touched while holding resultsMu.
+	var resultsMu sync.Mutex
+	runScript := func(ep *benchmarkEndpoint, name string, run int) {
+		resultsMu.Lock()
+		tui.startRun(ep, name, run)
+		s := ep.scripts[name]
+		ep.data[name].ScriptHash = scriptHash(s)
+		skip := skipOversized(ep.data[name])
+		// The runs of other scripts may drop clusters from the endpoint, which replaces its conns.
+		conns := ep.conns
+		resultsMu.Unlock()
+		defer func() {
+			resultsMu.Lock()
+			tui.finishRun(ep, name)
+			resultsMu.Unlock()
+		}()
+		if skip {
+			log.WithField("script", name).Warnf("Skipping run after %d oversized runs in a row", maxConsecutiveOversizedRuns)
+			return
+		}
+		log.WithField("script", name).WithField("cloud_addr", ep.cloudAddr).Infof("Executing script")
+		if ep.data[name].Mutation {
+			res, err := executeMutationScript(conns, s, timeout, mutationDeadline, maxBytesPerRun, accumulation, parallelism)
+			if err != nil {
+				log.WithError(err).WithField("script", name).Fatalf("Failed to execute script")
+			}
+			resultsMu.Lock()
+			defer resultsMu.Unlock()
+			recordMutationResults(ep.data[name], res)
+			if res.query != nil {
+				ep.recordClusterTimeouts(run, res.query.timedOutClusters, dropAfterTimeouts)
+				if schemaFile != "" {
+					recordSchema(ep.data[name], res.query, expectations)
+				}
+			}
+			return
+		}
+		if ep.data[name].Canceled {
+			res, err := executeCanceledScript(conns, s, cancelAfter, parallelism)
+			if err != nil {
+				log.WithError(err).WithField("script", name).Fatalf("Failed to execute script")
+			}
+			resultsMu.Lock()
+			defer resultsMu.Unlock()
+			recordCanceledResults(ep.data[name], res)
+			return
+		}
+		if ep.data[name].Streamed {
+			res, err := executeStreamingScript(conns, s, streamDuration, parallelism)
+			if err != nil {
+				log.WithError(err).WithField("script", name).Fatalf("Failed to execute script")
+			}
+			resultsMu.Lock()
+			defer resultsMu.Unlock()
+			recordStreamedResults(ep.data[name], res)
+			return
+		}
+		var rec *responseRecorder
+		if saveFailuresDir != "" {
+			rec = newResponseRecorder(saveFailuresMaxBytes)
+		}
+		res, err := executeScript(conns, s, timeout, rec, maxBytesPerRun, accumulation, parallelism)
+		if err != nil {
+			log.WithError(err).WithField("script", name).Fatalf("Failed to execute script")
+		}
+		resultsMu.Lock()
+		defer resultsMu.Unlock()
+		recordResults(ep.data[name], res)
+		ep.recordClusterTimeouts(run, res.timedOutClusters, dropAfterTimeouts)
+		if schemaFile != "" {
+			recordSchema(ep.data[name], res, expectations)
+		}
+		// The failure is saved once the run is over, so that the disk writes stay out of its timings.
+		if rec != nil && res.scriptErr != nil {
+			failures := ep.data[name].Failures
+			failure := failures[len(failures)-1]
+			dir := saveFailuresDir
+			if len(endpoints) > 1 {
+				dir = filepath.Join(dir, failureDirName(ep.label()))
+			}
+			runDir := failureDir(dir, name, failure.Run)
+			if err := saveFailedRun(runDir, failure, res.scriptErr, rec); err != nil {
+				log.WithError(err).WithField("script", name).Error("Failed to save the failed run")
+			} else {
+				log.WithField("script", name).WithField("dir", runDir).Info("Saved the failed run")
+			}
+		}
+	}
+
+	// Run scripts in shuffled order. Each run goes through every cloud, starting from a different cloud each
+	// time, so that no cloud is favored by the time its samples are taken.
+	runInParallel(scriptsToRun, parallelism, tui.quitting, func(i int, name string) {
+		for j := range endpoints {
+			ep := endpoints[(i+j)%len(endpoints)]
+			if _, ok := ep.scripts[name]; ok {
+				runScript(ep, name, i)
+			}
+		}
+	})
+
+	// Check whether the runs of each script agree with each other closely enough to be trusted. Noisy scripts
+	// can be run once more, which replaces their first set of results.
+	numNoisy := 0
+	if maxVariance > 0 {
+		run := len(scriptsToRun)
+		for _, ep := range endpoints {
+			noisy := markNoisy(ep.data, maxVariance)
+			if retryNoisy && !tui.quitting() {
+				tui.addRuns(len(noisy) * repeatCount)
+				for _, name := range noisy {
+					if tui.quitting() {
+						break
+					}
+					log.WithField("script", name).WithField("cloud_addr", ep.cloudAddr).Warn("Retrying noisy script")
+					ep.resetData(name, streamDuration, cancelAfter)
+					ep.data[name].Retried = true
+					for k := 0; k < repeatCount; k++ {
+						runScript(ep, name, run)
+						run++
+					}
+				}
+				noisy = markNoisy(ep.data, maxVariance)
+			}
+			numNoisy += len(noisy)
 		}
-		dists := data[s.ScriptName].Distributions
-		dists[numErrorsLabel].Append(res.scriptErr)
-		dists[execTimeExternalLabel].Append(res.externalExecTime)
-		dists[compTimeLabel].Append(res.compileTime)
-		dists[execTimeInternalLabel].Append(res.internalExecTime)
-		dists[numBytesLabel].Append(res.numBytes)
+	}
+	// The final results are written to the configured output, whether the runs finished or the user quit.
+	tui.close()
+	for _, ep := range endpoints {
+		countErrors(ep.data)
 	}
 
 	if outputFmt == "table" {
 		s := &stdoutTableWriter{}
-		// Sort by key names.
-		sortedData := sortByKeys(&data)
-		err = s.Write(&sortedData)
-		if err != nil {
-			log.WithError(err).Fatalf("Failure on writing table")
+		for _, ep := range endpoints {
+			if ep.mode != "" {
+				fmt.Printf("Mode: %s\n", ep.label())
+			} else if len(endpoints) > 1 {
+				fmt.Printf("Cloud: %s\n", ep.label())
+			}
+			// Sort by key names. Streamed scripts have different distributions, so they get their own table.
+			sortedData := sortByKeys(&ep.data)
+			sortSweeps(sortedData)
+			bounded, streamed := splitStreamed(sortedData)
+			bounded, mutations := splitMutations(bounded)
+			bounded, canceled := splitCanceled(bounded)
+			if len(bounded) > 0 || (len(streamed) == 0 && len(mutations) == 0 && len(canceled) == 0) {
+				err = s.Write(&bounded)
+				if err != nil {
+					log.WithError(err).Fatalf("Failure on writing table")
+				}
+			}
+			if len(mutations) > 0 {
+				fmt.Println("Mutations:")
+				err = s.Write(&mutations)
+				if err != nil {
+					log.WithError(err).Fatalf("Failure on writing table")
+				}
+			}
+			if len(canceled) > 0 {
+				fmt.Printf("Canceled after %v:\n", cancelAfter)
+				err = s.Write(&canceled)
+				if err != nil {
+					log.WithError(err).Fatalf("Failure on writing table")
+				}
+				writeCompletedBeforeCancel(canceled, cancelAfter)
+			}
+			if len(streamed) > 0 {
+				fmt.Printf("Streamed for %v:\n", streamDuration)
+				err = s.Write(&streamed)
+				if err != nil {
+					log.WithError(err).Fatalf("Failure on writing table")
+				}
+			}
+			writeSweepScaling(ep.data)
+			writeDroppedClusters(ep)
+			writeNoisyScripts(ep, maxVariance)
+			writeOversizedRuns(ep, maxBytesPerRun)
+			writeErrorCounts(ep)
+		}
+		// Compare every cloud against the first one.
+		for _, ep := range endpoints[1:] {
+			diffs, err := endpointDiffs(endpoints[0], ep)
+			if err != nil {
+				log.WithError(err).Fatal("Failed to diff two distributions")
+			}
+			fmt.Printf("Delta: %s - %s\n", endpoints[0].label(), ep.label())
+			bounded, streamed := splitStreamedDiffs(diffs)
+			if len(bounded) > 0 || len(streamed) == 0 {
+				w := &diffTableWriter{Columns: []string{execTimeExternalLabel, execTimeInternalLabel, timeToFirstRowLabel, numErrorsLabel}}
+				if err := w.Write(bounded); err != nil {
+					log.WithError(err).Fatal("Failed to write diffs to table")
+				}
+			}
+			if len(streamed) > 0 {
+				w := &diffTableWriter{Columns: streamedDiffColumns}
+				if err := w.Write(streamed); err != nil {
+					log.WithError(err).Fatal("Failed to write diffs to table")
+				}
+			}
+		}
+		if compareModes {
+			overhead := passthroughOverhead(endpoints[0], endpoints[1])
+			if len(overhead) > 0 {
+				fmt.Printf("%s: %s - %s\n", passthroughOverheadLabel, endpoints[0].label(), endpoints[1].label())
+				sortedOverhead := sortByKeys(&overhead)
+				if err := s.Write(&sortedOverhead); err != nil {
+					log.WithError(err).Fatalf("Failure on writing table")
+				}
+			}
 		}
 	}
-	if outputFmt == "json" {
-		jsonData, err := json.Marshal(data)
+	if outputFmt == "csv" {
+		w := &csvResultsWriter{out: os.Stdout}
+		data := make([][]*ScriptExecData, len(endpoints))
+		for i, ep := range endpoints {
+			data[i] = sortByKeys(&ep.data)
+			sortSweeps(data[i])
+			if len(endpoints) > 1 {
+				w.endpoints = append(w.endpoints, ep.label())
+			}
+		}
+		if err := w.Write(data...); err != nil {
+			log.WithError(err).Fatal("Failed to write results to csv")
+		}
+	}
+	// Only complete runs are compared against, so the results of a run that was quit early aren't stashed.
+	if tui.quitting() {
+		stashDir = ""
+	}
+	var jsonData []byte
+	if outputFmt == "json" || stashDir != "" {
+		jsonData, err = benchmarkJSON(endpoints, compareModes, &BenchmarkMetadata{
+			Config:     effectiveConfig(cmd.Flags()),
+			BundleHash: bundleHash,
+			Clusters:   selectedClusterNames(clusters),
+			AutoArgs:   endpointAutoArgs(endpoints),
+			// Collections are only forced between runs when no other run is being measured.
+			GCInterference: parallelism > 1,
+		})
 		if err != nil {
 			log.WithError(err).Fatal("Failed to marshal results to json")
 		}
+	}
+	if outputFmt == "json" {
 		os.Stdout.Write(jsonData)
 	}
+	if stashDir != "" {
+		// The deltas go after the table, but stay out of the json and csv output.
+		w := os.Stdout
+		if outputFmt != "table" {
+			w = os.Stderr
+		}
+		if err := autoCompare(w, stashDir, jsonData, endpoints[0].data, autoCompareKeep, time.Now()); err != nil {
+			log.WithError(err).Warn("Failed to compare against the previous run")
+		}
+	}
+	if writeSchema {
+		// Every endpoint runs the same scripts, so the first one has every schema that can be written.
+		if err := writeSchemaExpectations(schemaFile, observedSchemas(endpoints[0].data)); err != nil {
+			log.WithError(err).Fatal("Failed to write the schema file")
+		}
+		log.WithField("schema_file", schemaFile).Info("Wrote the observed schemas")
+	}
+	if !quiet {
+		outcomes := benchmarkOutcomes(endpoints)
+		summary := newRunSummary(outcomes, time.Since(start))
+		summary.outputs = append(summary.outputs, fmt.Sprintf("stdout (%s)", outputFmt))
+		if saveFailuresDir != "" {
+			summary.outputs = append(summary.outputs, saveFailuresDir)
+		}
+		if writeSchema {
+			summary.outputs = append(summary.outputs, schemaFile)
+		}
+		if failOnNoisy {
+			summary.addGate("--fail-on-noisy", trippedBy(outcomes, func(o *scriptOutcome) bool { return o.noisy }))
+		}
+		if failOnError {
+			summary.addGate("--fail-on-error", trippedBy(outcomes, func(o *scriptOutcome) bool { return o.failed }))
+		}
+		summary.write(os.Stderr)
+	}
+	if failOnNoisy && numNoisy > 0 {
+		log.Errorf("%d scripts have noisy results", numNoisy)
+		os.Exit(1)
+	}
+	if failOnError {
+		numFailed := 0
+		for _, ep := range endpoints {
+			numFailed += numFailedScripts(ep.data)
+		}
+		if numFailed > 0 {
+			log.Errorf("%d scripts have failed or oversized runs, or schema mismatches", numFailed)
+			os.Exit(1)
+		}
+	}
+}
+
+// benchmarkJSON returns the results in the json output format. A single cloud keeps the output format that the
+// compare command reads. Several clouds each get a section, keyed by cloud address. Either way, the metadata is
+// kept alongside.
+func benchmarkJSON(endpoints []*benchmarkEndpoint, compareModes bool, metadata *BenchmarkMetadata) ([]byte, error) {
+	results := make(map[string]interface{})
+	if len(endpoints) > 1 {
+		for _, ep := range endpoints {
+			results[ep.label()] = nestSweeps(ep.data)
+		}
+		if compareModes {
+			results[passthroughOverheadLabel] = passthroughOverhead(endpoints[0], endpoints[1])
+		}
+	} else {
+		for name, d := range nestSweeps(endpoints[0].data) {
+			results[name] = d
+		}
+	}
+	results[benchmarkMetadataKey] = metadata
+	return json.Marshal(results)
 }
 
 // RootCmd executes the subcommands.
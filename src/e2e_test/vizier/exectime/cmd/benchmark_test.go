@@ -0,0 +1,95 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeDistributionPercentile(t *testing.T) {
+	d := &TimeDistribution{[]time.Duration{40 * time.Millisecond, 10 * time.Millisecond, 30 * time.Millisecond, 20 * time.Millisecond}}
+	assert.Equal(t, 10*time.Millisecond, d.Percentile(0))
+	assert.Equal(t, 40*time.Millisecond, d.Percentile(1))
+	// The median of an even number of samples is interpolated between the middle two.
+	assert.Equal(t, 25*time.Millisecond, d.P50())
+	assert.Equal(t, 37*time.Millisecond, d.P90())
+	assert.Equal(t, 39700*time.Microsecond, d.P99())
+	// The samples aren't reordered.
+	assert.Equal(t, 40*time.Millisecond, d.Times[0])
+
+	single := &TimeDistribution{[]time.Duration{time.Second}}
+	assert.Equal(t, time.Second, single.P50())
+	assert.Equal(t, time.Second, single.P99())
+
+	assert.Equal(t, time.Duration(0), (&TimeDistribution{}).P50())
+}
+
+func TestDistributionMapMarshalJSON_Percentiles(t *testing.T) {
+	dm := distributionMap{
+		execTimeExternalLabel: &TimeDistribution{[]time.Duration{time.Second, 3 * time.Second}},
+		timeToFirstRowLabel:   &TimeDistribution{make([]time.Duration, 0)},
+	}
+	data, err := json.Marshal(&dm)
+	require.NoError(t, err)
+
+	var containers map[string]*distributionContainer
+	require.NoError(t, json.Unmarshal(data, &containers))
+	require.NotNil(t, containers[execTimeExternalLabel].Percentiles)
+	assert.Equal(t, 2*time.Second, containers[execTimeExternalLabel].Percentiles.P50)
+	// Empty distributions have no percentiles.
+	assert.Nil(t, containers[timeToFirstRowLabel].Percentiles)
+
+	// The percentiles are derived, so reading the json back gives the same distributions.
+	var read distributionMap
+	require.NoError(t, json.Unmarshal(data, &read))
+	assert.Equal(t, dm, read)
+}
+
+func TestStdoutTableWriter_Percentiles(t *testing.T) {
+	d := newScriptExecData("px/cluster")
+	d.Distributions[execTimeExternalLabel].Append(10 * time.Millisecond)
+	d.Distributions[execTimeExternalLabel].Append(30 * time.Millisecond)
+	data := []*ScriptExecData{d}
+
+	header, rows := tableRows(data)
+	require.Len(t, rows, 1)
+	require.Len(t, rows[0], len(header))
+	cells := make(map[string]string)
+	for i, h := range header {
+		cells[h] = rows[0][i]
+	}
+	assert.Equal(t, "20ms +/- 10ms", cells[execTimeExternalLabel])
+	assert.Equal(t, "20ms", cells[execTimeExternalLabel+" P50"])
+	assert.Equal(t, "28ms", cells[execTimeExternalLabel+" P90"])
+	assert.Equal(t, "29.8ms", cells[execTimeExternalLabel+" P99"])
+	// Distributions without samples have no percentiles.
+	assert.Equal(t, "-", cells[timeToFirstRowLabel+" P50"])
+	assert.Equal(t, "-", cells[timeToFirstRowLabel+" P90"])
+	assert.Equal(t, "-", cells[timeToFirstRowLabel+" P99"])
+
+	var buf bytes.Buffer
+	require.NoError(t, (&stdoutTableWriter{out: &buf}).Write(&data))
+	assert.Contains(t, buf.String(), "29.8ms")
+}
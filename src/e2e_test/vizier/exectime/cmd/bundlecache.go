@@ -0,0 +1,248 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// bundleFetchTimeout bounds the request for a remote bundle, so that a bad connection falls back to the cached
+// copy rather than hanging.
+const bundleFetchTimeout = 30 * time.Second
+
+// bundleFetchOptions configures how a remote bundle is fetched.
+type bundleFetchOptions struct {
+	// cacheDir is the directory that remote bundles are cached in. The cache is disabled if it is empty.
+	cacheDir string
+	// refresh fetches the bundle even if the cached copy is up to date.
+	refresh bool
+}
+
+// bundleCacheMeta is stored alongside a cached bundle, to make conditional requests for it.
+type bundleCacheMeta struct {
+	URL          string
+	ETag         string `json:",omitempty"`
+	LastModified string `json:",omitempty"`
+	// ValidatedAt is the last time that the cached copy was known to be up to date.
+	ValidatedAt time.Time
+}
+
+// exectimeCacheDir returns the directory that the benchmark caches its files in.
+func exectimeCacheDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "pixie", "exectime"), nil
+}
+
+func addBundleCacheFlags(c *cobra.Command) {
+	c.PersistentFlags().Bool("no-cache", false, "Download the bundle without reading or writing the local cache of remote bundles")
+	c.PersistentFlags().Bool("refresh-bundle", false, "Download the bundle even if the cached copy is up to date")
+}
+
+// bundleFetchOptionsFromFlags returns the options given by the flags added by addBundleCacheFlags.
+func bundleFetchOptionsFromFlags(c *cobra.Command) bundleFetchOptions {
+	noCache, _ := c.Flags().GetBool("no-cache")
+	refresh, _ := c.Flags().GetBool("refresh-bundle")
+	if noCache {
+		return bundleFetchOptions{refresh: refresh}
+	}
+	opts := defaultBundleFetchOptions()
+	opts.refresh = refresh
+	return opts
+}
+
+// defaultBundleFetchOptions caches remote bundles in the user's cache dir, if there is one.
+func defaultBundleFetchOptions() bundleFetchOptions {
+	cacheDir, err := exectimeCacheDir()
+	if err != nil {
+		log.WithError(err).Warn("No cache dir, the bundle won't be cached")
+		return bundleFetchOptions{}
+	}
+	return bundleFetchOptions{cacheDir: filepath.Join(cacheDir, "bundles")}
+}
+
+func isBundleURL(bundleFile string) bool {
+	u, err := url.Parse(bundleFile)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// fetchBundle returns the path to a local copy of the bundle, and the hash of its contents. Remote bundles are
+// cached, and only downloaded again if they changed. If the bundle can't be fetched, the cached copy is used.
+// The returned cleanup func removes the local copy if it is temporary.
+func fetchBundle(bundleFile string, opts bundleFetchOptions) (string, string, func(), error) {
+	noCleanup := func() {}
+	if !isBundleURL(bundleFile) {
+		hash, err := hashFile(bundleFile)
+		return bundleFile, hash, noCleanup, err
+	}
+	if opts.cacheDir == "" {
+		return downloadBundle(bundleFile)
+	}
+	path, err := fetchCachedBundle(bundleFile, opts)
+	if err != nil {
+		return "", "", noCleanup, err
+	}
+	hash, err := hashFile(path)
+	return path, hash, noCleanup, err
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// downloadBundle downloads the bundle to a temporary file, without the cache.
+func downloadBundle(bundleURL string) (string, string, func(), error) {
+	noCleanup := func() {}
+	resp, err := (&http.Client{Timeout: bundleFetchTimeout}).Get(bundleURL)
+	if err != nil {
+		return "", "", noCleanup, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", noCleanup, fmt.Errorf("failed to download the bundle: %s", resp.Status)
+	}
+	f, err := os.CreateTemp("", "bundle-*.json")
+	if err != nil {
+		return "", "", noCleanup, err
+	}
+	cleanup := func() { os.Remove(f.Name()) }
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", "", noCleanup, err
+	}
+	return f.Name(), hex.EncodeToString(h.Sum(nil)), cleanup, nil
+}
+
+// fetchCachedBundle returns the path of the cached copy of the bundle, after making sure that it is up to date.
+func fetchCachedBundle(bundleURL string, opts bundleFetchOptions) (string, error) {
+	sum := sha256.Sum256([]byte(bundleURL))
+	base := filepath.Join(opts.cacheDir, hex.EncodeToString(sum[:8]))
+	dataPath, metaPath := base+".json", base+".meta.json"
+
+	var meta bundleCacheMeta
+	cached := false
+	if content, err := os.ReadFile(metaPath); err == nil && json.Unmarshal(content, &meta) == nil {
+		_, err := os.Stat(dataPath)
+		cached = err == nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, bundleURL, nil)
+	if err != nil {
+		return "", err
+	}
+	if cached && !opts.refresh {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+
+	// Falls back to the cached copy, unless a fresh copy was asked for.
+	fallback := func(err error) (string, error) {
+		if !cached || opts.refresh {
+			return "", err
+		}
+		log.WithError(err).WithField("cache_age", time.Since(meta.ValidatedAt).Round(time.Second)).
+			Warn("Failed to fetch the bundle, using the cached copy")
+		return dataPath, nil
+	}
+
+	resp, err := (&http.Client{Timeout: bundleFetchTimeout}).Do(req)
+	if err != nil {
+		return fallback(err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached && !opts.refresh:
+		meta.ValidatedAt = time.Now()
+	case resp.StatusCode == http.StatusOK:
+		if err := writeFileAtomic(dataPath, resp.Body); err != nil {
+			return fallback(err)
+		}
+		meta = bundleCacheMeta{
+			URL:          bundleURL,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			ValidatedAt:  time.Now(),
+		}
+	default:
+		return fallback(fmt.Errorf("failed to download the bundle: %s", resp.Status))
+	}
+
+	// The cached copy is still used if its metadata can't be updated.
+	if content, err := json.Marshal(&meta); err == nil {
+		if err := os.WriteFile(metaPath, content, 0644); err != nil {
+			log.WithError(err).Warn("Failed to update the metadata of the cached bundle")
+		}
+	}
+	return dataPath, nil
+}
+
+// writeFileAtomic writes the contents of r to path, so that a failed download never leaves a partial copy.
+func writeFileAtomic(path string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("failed to write the cached bundle: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testBundle = `{"scripts": {"px/cluster": {"pxl": "import px", "vis": ""}}}`
+
+// newBundleServer serves testBundle with an ETag, and counts the requests that downloaded it.
+func newBundleServer(t *testing.T, downloads *int32) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		atomic.AddInt32(downloads, 1)
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(testBundle))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestFetchBundle_ConditionalRequest(t *testing.T) {
+	var downloads int32
+	s := newBundleServer(t, &downloads)
+	opts := bundleFetchOptions{cacheDir: t.TempDir()}
+
+	path, hash, cleanup, err := fetchBundle(s.URL, opts)
+	require.NoError(t, err)
+	cleanup()
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, testBundle, string(content))
+
+	// The cached copy is still current, so it isn't downloaded again.
+	cachedPath, cachedHash, cleanup, err := fetchBundle(s.URL, opts)
+	require.NoError(t, err)
+	cleanup()
+	assert.Equal(t, path, cachedPath)
+	assert.Equal(t, hash, cachedHash)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&downloads))
+
+	opts.refresh = true
+	_, _, cleanup, err = fetchBundle(s.URL, opts)
+	require.NoError(t, err)
+	cleanup()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&downloads))
+}
+
+func TestFetchBundle_FallsBackToCache(t *testing.T) {
+	var downloads int32
+	s := newBundleServer(t, &downloads)
+	opts := bundleFetchOptions{cacheDir: t.TempDir()}
+
+	_, hash, _, err := fetchBundle(s.URL, opts)
+	require.NoError(t, err)
+	s.Close()
+
+	path, cachedHash, _, err := fetchBundle(s.URL, opts)
+	require.NoError(t, err)
+	assert.Equal(t, hash, cachedHash)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, testBundle, string(content))
+
+	// A fresh copy was asked for, so the cached copy isn't good enough.
+	opts.refresh = true
+	_, _, _, err = fetchBundle(s.URL, opts)
+	assert.Error(t, err)
+}
+
+func TestFetchBundle_NoCache(t *testing.T) {
+	var downloads int32
+	s := newBundleServer(t, &downloads)
+
+	path, hash, cleanup, err := fetchBundle(s.URL, bundleFetchOptions{})
+	require.NoError(t, err)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, testBundle, string(content))
+	cleanup()
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	_, localHash, _, err := fetchBundle(writeBundleContent(t, testBundle), bundleFetchOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, localHash, hash)
+}
+
+func writeBundleContent(t *testing.T, content string) string {
+	f, err := os.CreateTemp(t.TempDir(), "bundle-*.json")
+	require.NoError(t, err)
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	return f.Name()
+}
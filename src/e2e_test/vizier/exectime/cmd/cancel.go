@@ -0,0 +1,148 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+	"px.dev/pixie/src/utils/script"
+)
+
+const cancellationLatencyLabel = "Cancellation Latency"
+
+func newCanceledScriptExecData(name string) *ScriptExecData {
+	return &ScriptExecData{
+		Name:     name,
+		Canceled: true,
+		Distributions: addFailureClassDistributions(distributionMap{
+			cancellationLatencyLabel:  &TimeDistribution{make([]time.Duration, 0)},
+			timeToFirstRowLabel:       &TimeDistribution{make([]time.Duration, 0)},
+			errorsBeforeFirstRowLabel: &ErrorDistribution{make([]error, 0)},
+			numErrorsLabel:            &ErrorDistribution{make([]error, 0)},
+		}),
+	}
+}
+
+// executeCanceledScript runs a script and cancels it after the given duration, to measure how long the stream
+// takes to end once it is canceled. That is until the stream is closed, rather than until Finish returns, since
+// the adapter stops reading the stream as soon as the context is done. Runs that end before they are canceled
+// have no cancellation latency, and are marked as completedBeforeCancel.
+func executeCanceledScript(v []*vizier.Connector, execScript *script.ExecutableScript, cancelAfter time.Duration,
+	concurrentRuns int) (*execResults, error) {
+	collectGarbage(concurrentRuns)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	execRes := execResults{}
+	start := time.Now()
+	resp, err := vizier.RunScript(ctx, v, execScript, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var firstBatch time.Time
+	onRowBatch := func(numRows int, receivedAt time.Time) {
+		if firstBatch.IsZero() {
+			firstBatch = receivedAt
+		}
+	}
+	tw := vizier.NewStreamOutputAdapter(ctx, resp, vizier.FormatCountOnly, nil, vizier.WithRowBatchCallback(onRowBatch))
+	canceledAt := make(chan time.Time, 1)
+	timer := time.AfterFunc(cancelAfter, func() {
+		canceledAt <- time.Now()
+		cancel()
+	})
+	err = tw.Finish()
+	canceled := !timer.Stop()
+	if !canceled {
+		// The stream may still be open if the run failed, so it is stopped as any other run.
+		stopStream(cancel, resp)
+	}
+	for range resp {
+	}
+	endedAt := time.Now()
+
+	if !firstBatch.IsZero() {
+		execRes.timeToFirstRow = firstBatch.Sub(start)
+		execRes.receivedRows = true
+	}
+	execRes.externalExecTime = endedAt.Sub(start)
+	switch {
+	case !canceled:
+		execRes.completedBeforeCancel = true
+		execRes.scriptErr = err
+	case err != nil && !isCancellation(err):
+		execRes.scriptErr = err
+	default:
+		execRes.cancellationLatency = endedAt.Sub(<-canceledAt)
+	}
+	if execRes.scriptErr != nil {
+		log.WithError(execRes.scriptErr).Infof("Error '%s' on '%s'", vizier.FormatErrorMessage(execRes.scriptErr), execScript.ScriptName)
+	}
+	return &execRes, nil
+}
+
+// recordCanceledResults appends the results of a canceled run to the script's distributions. Runs that ended
+// before they were canceled are only counted.
+func recordCanceledResults(data *ScriptExecData, res *execResults) {
+	dists := data.Distributions
+	runErr := recordFailure(data, res.scriptErr)
+	dists[numErrorsLabel].Append(runErr)
+	if res.receivedRows {
+		dists[timeToFirstRowLabel].Append(res.timeToFirstRow)
+		dists[errorsBeforeFirstRowLabel].Append(nil)
+	} else {
+		dists[errorsBeforeFirstRowLabel].Append(runErr)
+	}
+	if res.completedBeforeCancel {
+		data.CompletedBeforeCancel++
+		return
+	}
+	if runErr == nil {
+		dists[cancellationLatencyLabel].Append(res.cancellationLatency)
+	}
+}
+
+// splitCanceled splits the script data into the scripts that ran normally, and the canceled scripts.
+func splitCanceled(data []*ScriptExecData) ([]*ScriptExecData, []*ScriptExecData) {
+	var others, canceled []*ScriptExecData
+	for _, d := range data {
+		if d.Canceled {
+			canceled = append(canceled, d)
+		} else {
+			others = append(others, d)
+		}
+	}
+	return others, canceled
+}
+
+// writeCompletedBeforeCancel notes the scripts that had runs end before they could be canceled, under the table
+// of canceled scripts.
+func writeCompletedBeforeCancel(data []*ScriptExecData, cancelAfter time.Duration) {
+	for _, d := range data {
+		if d.CompletedBeforeCancel > 0 {
+			fmt.Printf("%s completed before the cancel at %v in %d runs, which have no %s\n",
+				d.Name, cancelAfter, d.CompletedBeforeCancel, cancellationLatencyLabel)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordCanceledResults(t *testing.T) {
+	data := newCanceledScriptExecData("px/http_data")
+	recordCanceledResults(data, &execResults{cancellationLatency: 20 * time.Millisecond, receivedRows: true, timeToFirstRow: time.Second})
+	recordCanceledResults(data, &execResults{cancellationLatency: 40 * time.Millisecond})
+	// Runs that end before the cancel, or fail, have no latency.
+	recordCanceledResults(data, &execResults{completedBeforeCancel: true})
+	recordCanceledResults(data, &execResults{scriptErr: errors.New("failed")})
+
+	latency := data.Distributions[cancellationLatencyLabel].(*TimeDistribution)
+	assert.Equal(t, []time.Duration{20 * time.Millisecond, 40 * time.Millisecond}, latency.Times)
+	assert.Equal(t, 1, data.CompletedBeforeCancel)
+	assert.Equal(t, 1, data.Distributions[numErrorsLabel].(*ErrorDistribution).Num())
+	assert.Len(t, data.Distributions[numErrorsLabel].(*ErrorDistribution).Errors, 4)
+}
+
+func TestSplitCanceled(t *testing.T) {
+	bounded := newScriptExecData("px/cluster")
+	canceled := newCanceledScriptExecData("px/http_data")
+	others, cs := splitCanceled([]*ScriptExecData{bounded, canceled})
+	assert.Equal(t, []*ScriptExecData{bounded}, others)
+	assert.Equal(t, []*ScriptExecData{canceled}, cs)
+}
@@ -0,0 +1,174 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gofrs/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"px.dev/pixie/src/api/proto/cloudpb"
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+	"px.dev/pixie/src/utils"
+)
+
+func isHealthyCluster(vz *cloudpb.ClusterInfo) bool {
+	return vz.Status == cloudpb.CS_HEALTHY || vz.Status == cloudpb.CS_DEGRADED
+}
+
+// clusterName returns the name that the cluster is shown with.
+func clusterName(vz *cloudpb.ClusterInfo) string {
+	if vz.PrettyClusterName != "" {
+		return vz.PrettyClusterName
+	}
+	return vz.ClusterName
+}
+
+// matchClusters returns the clusters that the selected cluster ID or name refers to. A name that matches no
+// cluster exactly matches the clusters whose names start with it, in which case byPrefix is set.
+func matchClusters(vzInfos []*cloudpb.ClusterInfo, selected string) (matches []*cloudpb.ClusterInfo, byPrefix bool) {
+	id := uuid.FromStringOrNil(selected)
+	for _, vz := range vzInfos {
+		if (id != uuid.Nil && utils.UUIDFromProtoOrNil(vz.ID) == id) || vz.ClusterName == selected || vz.PrettyClusterName == selected {
+			matches = append(matches, vz)
+		}
+	}
+	if len(matches) > 0 {
+		return matches, false
+	}
+	for _, vz := range vzInfos {
+		if strings.HasPrefix(vz.ClusterName, selected) || strings.HasPrefix(vz.PrettyClusterName, selected) {
+			matches = append(matches, vz)
+		}
+	}
+	return matches, true
+}
+
+func clusterNames(vzInfos []*cloudpb.ClusterInfo) string {
+	names := make([]string, len(vzInfos))
+	for i, vz := range vzInfos {
+		names[i] = clusterName(vz)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// resolveClusters finds the viziers of the selected clusters, given by cluster ID, name or unique name prefix,
+// among the cloud's viziers. Every selected cluster that is unknown, ambiguous or unhealthy is reported in the
+// error, along with the names of the available clusters, so that they can all be fixed at once.
+func resolveClusters(vzInfos []*cloudpb.ClusterInfo, selected []string) ([]*cloudpb.ClusterInfo, error) {
+	var resolved []*cloudpb.ClusterInfo
+	seen := make(map[uuid.UUID]bool)
+	var problems []string
+	for _, s := range selected {
+		matches, byPrefix := matchClusters(vzInfos, s)
+		switch {
+		case len(matches) == 0:
+			problems = append(problems, fmt.Sprintf("%s: no such cluster", s))
+		case len(matches) > 1:
+			problems = append(problems, fmt.Sprintf("%s: matches %s", s, clusterNames(matches)))
+		case !isHealthyCluster(matches[0]):
+			problems = append(problems, fmt.Sprintf("%s: cluster is %s", s, matches[0].Status))
+		default:
+			id := utils.UUIDFromProtoOrNil(matches[0].ID)
+			if byPrefix {
+				log.Infof("Selected cluster %s (%s) by the prefix %s", clusterName(matches[0]), id, s)
+			}
+			if !seen[id] {
+				seen[id] = true
+				resolved = append(resolved, matches[0])
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s; available clusters: %s", strings.Join(problems, "; "), clusterNames(vzInfos))
+	}
+	return resolved, nil
+}
+
+// selectedClusterNames returns the names of the selected clusters, keyed by cluster ID, so that results record
+// which clusters they were run on.
+func selectedClusterNames(clusters map[string][]*cloudpb.ClusterInfo) map[string]string {
+	if len(clusters) == 0 {
+		return nil
+	}
+	names := make(map[string]string)
+	for _, vzInfos := range clusters {
+		for _, vz := range vzInfos {
+			names[utils.UUIDFromProtoOrNil(vz.ID).String()] = clusterName(vz)
+		}
+	}
+	return names
+}
+
+// validateClusters resolves the selected clusters through every cloud, before connecting to any of them, and
+// returns them keyed by cloud address. It returns no clusters if none are selected.
+func validateClusters(cloudAddrs []string, selected []string) (map[string][]*cloudpb.ClusterInfo, error) {
+	clusters := make(map[string][]*cloudpb.ClusterInfo, len(cloudAddrs))
+	if len(selected) == 0 {
+		return clusters, nil
+	}
+	var problems []string
+	for _, cloudAddr := range cloudAddrs {
+		vzInfos, err := vizier.GetVizierList(cloudAddr)
+		if err == nil {
+			clusters[cloudAddr], err = resolveClusters(vzInfos, selected)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", cloudAddr, err))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "; "))
+	}
+	return clusters, nil
+}
+
+// connectClusters connects to every healthy vizier if allClusters is set, and otherwise to the viziers of the
+// given clusters, or to the first healthy vizier if none are given.
+func connectClusters(cloudAddr string, allClusters bool, clusters []*cloudpb.ClusterInfo,
+	connOpts []vizier.ConnectorOption) ([]*vizier.Connector, error) {
+	if allClusters {
+		return vizier.ConnectToAllViziers(cloudAddr, connOpts...)
+	}
+	if len(clusters) == 0 {
+		clusterID, err := vizier.FirstHealthyVizier(cloudAddr)
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch healthy vizier: %w", err)
+		}
+		c, err := vizier.ConnectionToHealthyVizierByID(cloudAddr, clusterID, connOpts...)
+		if err != nil {
+			return nil, err
+		}
+		return []*vizier.Connector{c}, nil
+	}
+	conns := make([]*vizier.Connector, 0, len(clusters))
+	for _, vz := range clusters {
+		c, err := vizier.NewConnector(cloudAddr, vz, "", "", connOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", utils.UUIDFromProtoOrNil(vz.ID), err)
+		}
+		conns = append(conns, c)
+	}
+	return conns, nil
+}
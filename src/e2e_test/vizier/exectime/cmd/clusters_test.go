@@ -0,0 +1,68 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/api/proto/cloudpb"
+	"px.dev/pixie/src/utils"
+)
+
+func TestResolveClusters(t *testing.T) {
+	staging1 := uuid.Must(uuid.NewV4())
+	staging2 := uuid.Must(uuid.NewV4())
+	vzInfos := []*cloudpb.ClusterInfo{
+		{ID: utils.ProtoFromUUID(staging1), ClusterName: "gke_staging-1", PrettyClusterName: "staging-1", Status: cloudpb.CS_HEALTHY},
+		{ID: utils.ProtoFromUUID(staging2), ClusterName: "gke_staging-2", PrettyClusterName: "staging-2", Status: cloudpb.CS_DEGRADED},
+		{ID: utils.ProtoFromUUID(uuid.Must(uuid.NewV4())), ClusterName: "gke_prod", PrettyClusterName: "prod", Status: cloudpb.CS_DISCONNECTED},
+		{ID: utils.ProtoFromUUID(uuid.Must(uuid.NewV4())), ClusterName: "dup", Status: cloudpb.CS_HEALTHY},
+		{ID: utils.ProtoFromUUID(uuid.Must(uuid.NewV4())), ClusterName: "dup", Status: cloudpb.CS_HEALTHY},
+	}
+
+	// Clusters can be selected by ID or either name, and are only connected to once.
+	resolved, err := resolveClusters(vzInfos, []string{staging1.String(), "staging-2", "gke_staging-1"})
+	require.NoError(t, err)
+	require.Len(t, resolved, 2)
+	assert.Equal(t, staging1, utils.UUIDFromProtoOrNil(resolved[0].ID))
+	assert.Equal(t, staging2, utils.UUIDFromProtoOrNil(resolved[1].ID))
+
+	// Every problem is reported at once, along with the clusters that can be selected.
+	_, err = resolveClusters(vzInfos, []string{"staging-1", "nope", "prod", "dup", "staging"})
+	require.Error(t, err)
+	assert.Equal(t, "nope: no such cluster; prod: cluster is CS_DISCONNECTED; dup: matches dup, dup; "+
+		"staging: matches staging-1, staging-2; available clusters: dup, dup, prod, staging-1, staging-2", err.Error())
+}
+
+func TestResolveClusters_Prefix(t *testing.T) {
+	id := uuid.Must(uuid.NewV4())
+	vzInfos := []*cloudpb.ClusterInfo{
+		{ID: utils.ProtoFromUUID(id), PrettyClusterName: "prod-us-west", Status: cloudpb.CS_HEALTHY},
+		{ID: utils.ProtoFromUUID(uuid.Must(uuid.NewV4())), PrettyClusterName: "prod-eu", Status: cloudpb.CS_HEALTHY},
+	}
+	resolved, err := resolveClusters(vzInfos, []string{"prod-us"})
+	require.NoError(t, err)
+	require.Len(t, resolved, 1)
+	assert.Equal(t, map[string]string{id.String(): "prod-us-west"},
+		selectedClusterNames(map[string][]*cloudpb.ClusterInfo{"withpixie.ai:443": resolved}))
+}
@@ -19,11 +19,12 @@
 package cmd
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/fatih/color"
@@ -41,8 +42,15 @@ func init() {
 		"The json file with the results from the new changes to compare against the baseline")
 	CompareCmd.PersistentFlags().StringSlice(
 		"columns",
-		[]string{execTimeInternalLabel, numBytesLabel},
+		[]string{execTimeInternalLabel, numBytesLabel, numErrorsLabel},
 		"Comma separated list of distributions to show in the table output")
+	CompareCmd.PersistentFlags().Float64(
+		"threshold",
+		0,
+		"Exit with a non-zero status if the mean external exec time of any script got slower by more than this "+
+			"percentage of the baseline. Disabled if 0")
+	registerFlagCompletion(CompareCmd, "baseline", completeResultsFiles)
+	registerFlagCompletion(CompareCmd, "change", completeResultsFiles)
 	RootCmd.AddCommand(CompareCmd)
 }
 
@@ -78,6 +86,24 @@ func (t *ErrorDistribution) Diff(other Distribution) (DistributionDiff, error) {
 	return &errorDistributionDiff{t, otherErrorDist}, nil
 }
 
+// Diff computes the difference between this distribution and another rate distribution.
+func (t *RateDistribution) Diff(other Distribution) (DistributionDiff, error) {
+	otherRateDist, ok := other.(*RateDistribution)
+	if !ok {
+		return nil, errors.New("RateDistribution.Diff must be called with another RateDistribution as argument")
+	}
+	return &rateDistributionDiff{t, otherRateDist}, nil
+}
+
+// Diff computes the difference between this distribution and another ratio distribution.
+func (t *RatioDistribution) Diff(other Distribution) (DistributionDiff, error) {
+	otherRatioDist, ok := other.(*RatioDistribution)
+	if !ok {
+		return nil, errors.New("RatioDistribution.Diff must be called with another RatioDistribution as argument")
+	}
+	return &ratioDistributionDiff{t, otherRatioDist}, nil
+}
+
 type timeDistributionDiff struct {
 	A *TimeDistribution
 	B *TimeDistribution
@@ -94,6 +120,15 @@ func toFloat64Arr(times []time.Duration) []float64 {
 // Use a significance level of 0.01
 const timeDiffAlpha = 0.01
 
+// percentChange returns the change from the baseline to the change, as a percentage of the baseline, or "N/A" if
+// the baseline is 0.
+func percentChange(baseline, change float64) string {
+	if baseline == 0 {
+		return "N/A"
+	}
+	return fmt.Sprintf("%+.1f%%", (change-baseline)/baseline*100)
+}
+
 // Summarize returns a string summary of the difference between the two distributions.
 func (d *timeDistributionDiff) Summarize() string {
 	meanDiff := d.A.Mean() - d.B.Mean()
@@ -107,8 +142,9 @@ func (d *timeDistributionDiff) Summarize() string {
 	if ignorePValue {
 		pValueStr = "N/A"
 	}
-	summary := fmt.Sprintf("%v (%v vs %v, u: %s)",
+	summary := fmt.Sprintf("%v (%s, %v vs %v, u: %s)",
 		meanDiff.Round(10*time.Microsecond),
+		percentChange(float64(d.A.Mean()), float64(d.B.Mean())),
 		d.A.Mean().Round(100*time.Microsecond),
 		d.B.Mean().Round(100*time.Microsecond),
 		pValueStr)
@@ -132,7 +168,11 @@ const bytesDiffRedPercentThreshold = 0.05
 // Summarize returns a string summary of the difference between the two distributions.
 func (d *bytesDistributionDiff) Summarize() string {
 	meanDiff := d.A.Mean() - d.B.Mean()
-	summary := fmt.Sprintf("%.1fkB (%.1fkB vs %.1fkB)", meanDiff/1024, d.A.Mean()/1024, d.B.Mean()/1024)
+	summary := fmt.Sprintf("%.1fkB (%s, %.1fkB vs %.1fkB)",
+		meanDiff/1024, percentChange(d.A.Mean(), d.B.Mean()), d.A.Mean()/1024, d.B.Mean()/1024)
+	if d.A.Mean() == 0 {
+		return summary
+	}
 	percentDiff := meanDiff / d.A.Mean()
 	if percentDiff > bytesDiffRedPercentThreshold || percentDiff < -bytesDiffRedPercentThreshold {
 		return color.RedString(summary)
@@ -140,6 +180,37 @@ func (d *bytesDistributionDiff) Summarize() string {
 	return summary
 }
 
+type rateDistributionDiff struct {
+	A *RateDistribution
+	B *RateDistribution
+}
+
+const rateDiffRedPercentThreshold = 0.05
+
+// Summarize returns a string summary of the difference between the two distributions.
+func (d *rateDistributionDiff) Summarize() string {
+	meanDiff := d.A.Mean() - d.B.Mean()
+	summary := fmt.Sprintf("%.2f/s (%.2f/s vs %.2f/s)", meanDiff, d.A.Mean(), d.B.Mean())
+	if d.A.Mean() == 0 {
+		return summary
+	}
+	percentDiff := meanDiff / d.A.Mean()
+	if percentDiff > rateDiffRedPercentThreshold || percentDiff < -rateDiffRedPercentThreshold {
+		return color.RedString(summary)
+	}
+	return summary
+}
+
+type ratioDistributionDiff struct {
+	A *RatioDistribution
+	B *RatioDistribution
+}
+
+// Summarize returns a string summary of the difference between the two distributions.
+func (d *ratioDistributionDiff) Summarize() string {
+	return fmt.Sprintf("%.2fx (%.2fx vs %.2fx)", d.A.Mean()-d.B.Mean(), d.A.Mean(), d.B.Mean())
+}
+
 type errorDistributionDiff struct {
 	A *ErrorDistribution
 	B *ErrorDistribution
@@ -149,6 +220,9 @@ type errorDistributionDiff struct {
 func (d *errorDistributionDiff) Summarize() string {
 	numDiff := d.A.Num() - d.B.Num()
 	summary := fmt.Sprintf("%+d", numDiff)
+	if d.A.Num() > 0 {
+		summary = fmt.Sprintf("%s (%s)", summary, percentChange(float64(d.A.Num()), float64(d.B.Num())))
+	}
 	if numDiff != 0 {
 		return color.RedString(summary)
 	}
@@ -156,8 +230,12 @@ func (d *errorDistributionDiff) Summarize() string {
 }
 
 type scriptExecDiff struct {
-	Name  string
-	Diffs map[string]DistributionDiff
+	Name     string
+	Streamed bool
+	// ScriptChanged is set if the script itself changed between the runs, so the diffs may not be due to the
+	// platform.
+	ScriptChanged bool
+	Diffs         map[string]DistributionDiff
 }
 
 // diffTableWriter writes script diffs to a table for comparison.
@@ -179,8 +257,12 @@ func (w *diffTableWriter) Write(data []*scriptExecDiff) error {
 
 	// Iterate through data and create table rows.
 	for _, d := range data {
+		name := d.Name
+		if d.ScriptChanged {
+			name += color.RedString(" (script changed)")
+		}
 		row := []string{
-			d.Name,
+			name,
 		}
 		for _, k := range keys {
 			val, ok := d.Diffs[k]
@@ -195,32 +277,65 @@ func (w *diffTableWriter) Write(data []*scriptExecDiff) error {
 	return nil
 }
 
+// unmatchedScripts returns the sorted names of the scripts that are only in the baseline, and only in the change.
+func unmatchedScripts(baseline, change map[string]*ScriptExecData) ([]string, []string) {
+	var onlyBaseline, onlyChange []string
+	for name := range baseline {
+		if _, ok := change[name]; !ok {
+			onlyBaseline = append(onlyBaseline, name)
+		}
+	}
+	for name := range change {
+		if _, ok := baseline[name]; !ok {
+			onlyChange = append(onlyChange, name)
+		}
+	}
+	sort.Strings(onlyBaseline)
+	sort.Strings(onlyChange)
+	return onlyBaseline, onlyChange
+}
+
+// writeUnmatchedScripts writes the scripts that are only in one of the runs, since they can't be compared.
+func writeUnmatchedScripts(w io.Writer, onlyBaseline, onlyChange []string) {
+	if len(onlyBaseline) > 0 {
+		fmt.Fprintf(w, "Only in the baseline: %s\n", strings.Join(onlyBaseline, ", "))
+	}
+	if len(onlyChange) > 0 {
+		fmt.Fprintf(w, "Only in the change: %s\n", strings.Join(onlyChange, ", "))
+	}
+}
+
+// thresholdRegressions returns the scripts whose mean external exec time got slower than the baseline by more
+// than thresholdPercent of it.
+func thresholdRegressions(baseline, change map[string]*ScriptExecData, thresholdPercent float64) []*autoCompareDelta {
+	var regressions []*autoCompareDelta
+	for _, d := range autoCompareDeltas(baseline, change, thresholdPercent/100) {
+		if d.regression {
+			regressions = append(regressions, d)
+		}
+	}
+	return regressions
+}
+
 func compareCmd(cmd *cobra.Command) {
 	baselineJSONPath, _ := cmd.Flags().GetString("baseline")
 	changeJSONPath, _ := cmd.Flags().GetString("change")
 	columnsToShow, _ := cmd.Flags().GetStringSlice("columns")
+	threshold, _ := cmd.Flags().GetFloat64("threshold")
+	if threshold < 0 {
+		log.Fatal("--threshold must not be negative")
+	}
 
-	baselineContent, err := os.ReadFile(baselineJSONPath)
+	// The runs of swept scripts are compared window by window.
+	baselineData, err := readResults(baselineJSONPath)
 	if err != nil {
 		log.WithError(err).Fatal("Failed to read baseline json file")
 	}
-	changeContent, err := os.ReadFile(changeJSONPath)
+	changeData, err := readResults(changeJSONPath)
 	if err != nil {
 		log.WithError(err).Fatal("Failed to read change json file")
 	}
 
-	var baselineData map[string]*ScriptExecData
-	err = json.Unmarshal(baselineContent, &baselineData)
-	if err != nil {
-		log.WithError(err).Fatal("Failed to unmarshal baseline json data")
-	}
-
-	var changeData map[string]*ScriptExecData
-	err = json.Unmarshal(changeContent, &changeData)
-	if err != nil {
-		log.WithError(err).Fatal("Failed to unmarshal change json data")
-	}
-
 	diffs := make(map[string]*scriptExecDiff, len(baselineData))
 	for k := range baselineData {
 		baseExecData := baselineData[k]
@@ -228,10 +343,30 @@ func compareCmd(cmd *cobra.Command) {
 		if !ok {
 			continue
 		}
+		// The metrics of streamed runs are over a fixed duration, so they mean something else.
+		if baseExecData.Streamed != changeExecData.Streamed {
+			log.WithField("script", k).Warn("Script was streamed in only one of the runs, skipping")
+			continue
+		}
+		// Likewise, canceled runs only measure how long the script takes to stop.
+		if baseExecData.Canceled != changeExecData.Canceled {
+			log.WithField("script", k).Warn("Script was canceled in only one of the runs, skipping")
+			continue
+		}
+
+		changed := scriptChanged(baseExecData, changeExecData)
+		if changed {
+			log.WithField("script", k).
+				WithField("baseline_hash", baseExecData.ScriptHash).
+				WithField("change_hash", changeExecData.ScriptHash).
+				Warn("Script changed between the runs, its diffs may be due to the script rather than the platform")
+		}
 
 		diffs[k] = &scriptExecDiff{
-			Name:  baseExecData.Name,
-			Diffs: make(map[string]DistributionDiff),
+			Name:          baseExecData.Name,
+			Streamed:      baseExecData.Streamed,
+			ScriptChanged: changed,
+			Diffs:         make(map[string]DistributionDiff),
 		}
 		for distName := range baseExecData.Distributions {
 			baseDist := baseExecData.Distributions[distName]
@@ -260,9 +395,37 @@ func compareCmd(cmd *cobra.Command) {
 	for i, name := range sortedNames {
 		sortedDiffs[i] = diffs[name]
 	}
-	err = w.Write(sortedDiffs)
-	if err != nil {
-		log.WithError(err).Fatal("failed to write diffs to table")
+	bounded, streamed := splitStreamedDiffs(sortedDiffs)
+	if len(sortedDiffs) == 0 {
+		log.Warn("The runs have no scripts in common")
+	}
+	if len(bounded) > 0 {
+		err = w.Write(bounded)
+		if err != nil {
+			log.WithError(err).Fatal("failed to write diffs to table")
+		}
+	}
+	if len(streamed) > 0 {
+		err = (&diffTableWriter{streamedDiffColumns}).Write(streamed)
+		if err != nil {
+			log.WithError(err).Fatal("failed to write diffs to table")
+		}
+	}
+	onlyBaseline, onlyChange := unmatchedScripts(baselineData, changeData)
+	writeUnmatchedScripts(os.Stdout, onlyBaseline, onlyChange)
+
+	if threshold == 0 {
+		return
+	}
+	regressions := thresholdRegressions(baselineData, changeData, threshold)
+	for _, d := range regressions {
+		log.WithField("script", d.name).
+			WithField("baseline", d.previous.Round(100*time.Microsecond)).
+			WithField("change", d.current.Round(100*time.Microsecond)).
+			Error("Script regressed by more than the threshold")
+	}
+	if len(regressions) > 0 {
+		log.Fatalf("%d script(s) regressed by more than %.1f%%", len(regressions), threshold)
 	}
 }
 
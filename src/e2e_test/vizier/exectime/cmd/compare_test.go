@@ -0,0 +1,101 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDistributionDiffs_IncludePercentChange(t *testing.T) {
+	color.NoColor = true
+
+	timeDiff := &timeDistributionDiff{
+		A: &TimeDistribution{Times: []time.Duration{time.Second}},
+		B: &TimeDistribution{Times: []time.Duration{1500 * time.Millisecond}},
+	}
+	assert.Equal(t, "-500ms (+50.0%, 1s vs 1.5s, u: N/A)", timeDiff.Summarize())
+
+	bytesDiff := &bytesDistributionDiff{
+		A: &BytesDistribution{Bytes: []int{2048}},
+		B: &BytesDistribution{Bytes: []int{1024}},
+	}
+	assert.Equal(t, "1.0kB (-50.0%, 2.0kB vs 1.0kB)", bytesDiff.Summarize())
+
+	emptyBytesDiff := &bytesDistributionDiff{A: &BytesDistribution{Bytes: []int{0}}, B: &BytesDistribution{Bytes: []int{0}}}
+	assert.Equal(t, "0.0kB (N/A, 0.0kB vs 0.0kB)", emptyBytesDiff.Summarize())
+
+	errDiff := &errorDistributionDiff{
+		A: &ErrorDistribution{Errors: []error{assert.AnError, nil}},
+		B: &ErrorDistribution{Errors: []error{assert.AnError, assert.AnError}},
+	}
+	assert.Equal(t, "-1 (+100.0%)", errDiff.Summarize())
+	// There is no percentage change from no errors.
+	noErrDiff := &errorDistributionDiff{A: &ErrorDistribution{}, B: &ErrorDistribution{Errors: []error{assert.AnError}}}
+	assert.Equal(t, "-1", noErrDiff.Summarize())
+}
+
+func TestUnmatchedScripts(t *testing.T) {
+	baseline := map[string]*ScriptExecData{
+		"px/cluster": timedScriptExecData("px/cluster", time.Second),
+		"px/removed": timedScriptExecData("px/removed", time.Second),
+	}
+	change := map[string]*ScriptExecData{
+		"px/cluster": timedScriptExecData("px/cluster", time.Second),
+		"px/new_b":   timedScriptExecData("px/new_b", time.Second),
+		"px/new_a":   timedScriptExecData("px/new_a", time.Second),
+	}
+	onlyBaseline, onlyChange := unmatchedScripts(baseline, change)
+	assert.Equal(t, []string{"px/removed"}, onlyBaseline)
+	assert.Equal(t, []string{"px/new_a", "px/new_b"}, onlyChange)
+
+	var buf bytes.Buffer
+	writeUnmatchedScripts(&buf, onlyBaseline, onlyChange)
+	assert.Equal(t, "Only in the baseline: px/removed\nOnly in the change: px/new_a, px/new_b\n", buf.String())
+
+	buf.Reset()
+	writeUnmatchedScripts(&buf, nil, nil)
+	assert.Empty(t, buf.String())
+}
+
+func TestThresholdRegressions(t *testing.T) {
+	baseline := map[string]*ScriptExecData{
+		"px/cluster":   timedScriptExecData("px/cluster", time.Second),
+		"px/http_data": timedScriptExecData("px/http_data", time.Second),
+		"px/removed":   timedScriptExecData("px/removed", time.Second),
+	}
+	change := map[string]*ScriptExecData{
+		"px/cluster":   timedScriptExecData("px/cluster", 1050*time.Millisecond),
+		"px/http_data": timedScriptExecData("px/http_data", 1200*time.Millisecond),
+		"px/new":       timedScriptExecData("px/new", 10*time.Second),
+	}
+	regressions := thresholdRegressions(baseline, change, 10)
+	require.Len(t, regressions, 1)
+	assert.Equal(t, "px/http_data", regressions[0].name)
+	assert.Equal(t, time.Second, regressions[0].previous)
+	assert.Equal(t, 1200*time.Millisecond, regressions[0].current)
+
+	assert.Len(t, thresholdRegressions(baseline, change, 1), 2)
+	assert.Empty(t, thresholdRegressions(baseline, change, 50))
+}
@@ -0,0 +1,189 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// How long the script names of a bundle are completed from the cache before the bundle is read again.
+	scriptNamesCacheTTL = 24 * time.Hour
+	// How long completion waits for the bundle to be read, before giving up on completing the script names.
+	scriptNamesLoadTimeout = 5 * time.Second
+)
+
+func init() {
+	RootCmd.CompletionOptions.DisableDefaultCmd = true
+	RootCmd.AddCommand(CompletionCmd)
+}
+
+// registerFlagCompletion registers the completion of the command's flag. It must be called after the flag is
+// defined, from the init of the file that defines it.
+func registerFlagCompletion(c *cobra.Command, flag string, f func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective)) {
+	if err := c.RegisterFlagCompletionFunc(flag, f); err != nil {
+		log.WithError(err).Fatalf("Failed to register the completion of the %s flag", flag)
+	}
+}
+
+// CompletionCmd generates the shell completion script.
+var CompletionCmd = &cobra.Command{
+	Use:       "completion [bash|zsh|fish]",
+	Short:     "Generate the completion script for the given shell",
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	ValidArgs: []string{"bash", "zsh", "fish"},
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		switch args[0] {
+		case "bash":
+			err = RootCmd.GenBashCompletionV2(os.Stdout, true)
+		case "zsh":
+			err = RootCmd.GenZshCompletion(os.Stdout)
+		case "fish":
+			err = RootCmd.GenFishCompletion(os.Stdout, true)
+		}
+		if err != nil {
+			log.WithError(err).Fatal("Failed to generate the completion script")
+		}
+	},
+}
+
+// completeResultsFiles completes the json files written by the benchmark.
+func completeResultsFiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{"json"}, cobra.ShellCompDirectiveFilterFileExt
+}
+
+// completeScriptNames completes the names of the scripts in the command's bundle. It runs inside the shell, so
+// it completes nothing, rather than failing, if the names can't be loaded.
+func completeScriptNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	// The log would be shown as completions.
+	log.SetOutput(io.Discard)
+	bundleFile, _ := cmd.Flags().GetString("bundle")
+	cacheDir, err := exectimeCacheDir()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names, err := loadScriptNames(bundleFile, cacheDir, time.Now())
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	selected, _ := cmd.Flags().GetStringSlice("scripts")
+	return matchScriptNames(names, selected, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// matchScriptNames returns the names that complete toComplete, leaving out the names that are already selected.
+// The flag takes a comma separated list, so only the part after the last comma is completed.
+func matchScriptNames(names []string, selected []string, toComplete string) []string {
+	prefix := ""
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix, toComplete = toComplete[:i+1], toComplete[i+1:]
+	}
+	isSelected := make(map[string]bool)
+	for _, name := range selected {
+		isSelected[name] = true
+	}
+	for _, name := range strings.Split(prefix, ",") {
+		isSelected[name] = true
+	}
+
+	var matches []string
+	for _, name := range names {
+		if strings.HasPrefix(name, toComplete) && !isSelected[name] {
+			matches = append(matches, prefix+name)
+		}
+	}
+	return matches
+}
+
+// scriptNamesCachePath returns the path that the script names of the bundle are cached at, under cacheDir.
+func scriptNamesCachePath(bundleFile string, cacheDir string) string {
+	sum := sha256.Sum256([]byte(bundleFile))
+	return filepath.Join(cacheDir, "scripts-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// loadScriptNames returns the names of the scripts that can be benchmarked from the bundle. They are read from
+// the cache under cacheDir if it was written within scriptNamesCacheTTL of now, and cached otherwise.
+func loadScriptNames(bundleFile string, cacheDir string, now time.Time) ([]string, error) {
+	cachePath := scriptNamesCachePath(bundleFile, cacheDir)
+	if info, err := os.Stat(cachePath); err == nil && now.Sub(info.ModTime()) < scriptNamesCacheTTL {
+		var names []string
+		content, err := os.ReadFile(cachePath)
+		if err == nil && json.Unmarshal(content, &names) == nil {
+			return names, nil
+		}
+	}
+
+	type loaded struct {
+		names []string
+		err   error
+	}
+	done := make(chan loaded, 1)
+	go func() {
+		names, err := readScriptNames(bundleFile)
+		done <- loaded{names, err}
+	}()
+	var names []string
+	select {
+	case l := <-done:
+		if l.err != nil {
+			return nil, l.err
+		}
+		names = l.names
+	case <-time.After(scriptNamesLoadTimeout):
+		return nil, errors.New("timed out reading the bundle")
+	}
+
+	// The names are still completed if they can't be cached.
+	if content, err := json.Marshal(names); err == nil {
+		if err := os.MkdirAll(cacheDir, 0755); err == nil {
+			_ = os.WriteFile(cachePath, content, 0644)
+		}
+	}
+	return names, nil
+}
+
+// readScriptNames reads the names of the scripts that can be benchmarked from the bundle.
+func readScriptNames(bundleFile string) ([]string, error) {
+	br, _, err := createBundleReader(bundleFile, defaultBundleFetchOptions())
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, s := range br.GetOrderedScripts() {
+		if !disallowedScripts[s.ScriptName] {
+			names = append(names, s.ScriptName)
+		}
+	}
+	// Failing to read the bundle leaves it empty, rather than returning an error.
+	if len(names) == 0 {
+		return nil, errors.New("no scripts in the bundle")
+	}
+	return names, nil
+}
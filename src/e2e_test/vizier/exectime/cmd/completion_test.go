@@ -0,0 +1,69 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeBundle(t *testing.T, dir string) string {
+	bundleFile := filepath.Join(dir, "bundle.json")
+	content := `{"scripts": {
+		"px/http_data": {"pxl": "import px", "vis": ""},
+		"px/http_stats": {"pxl": "import px", "vis": ""},
+		"px/cluster": {"pxl": "import px", "vis": ""}
+	}}`
+	require.NoError(t, os.WriteFile(bundleFile, []byte(content), 0644))
+	return bundleFile
+}
+
+func TestLoadScriptNames_UsesFreshCache(t *testing.T) {
+	dir := t.TempDir()
+	bundleFile := writeBundle(t, dir)
+	cacheDir := filepath.Join(dir, "cache")
+	now := time.Now()
+
+	names, err := loadScriptNames(bundleFile, cacheDir, now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"px/cluster", "px/http_data", "px/http_stats"}, names)
+
+	// The bundle isn't read again while the cache is fresh.
+	require.NoError(t, os.Remove(bundleFile))
+	names, err = loadScriptNames(bundleFile, cacheDir, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"px/cluster", "px/http_data", "px/http_stats"}, names)
+
+	// Once the cache is stale, the bundle is read again.
+	_, err = loadScriptNames(bundleFile, cacheDir, now.Add(scriptNamesCacheTTL+time.Hour))
+	assert.Error(t, err)
+}
+
+func TestMatchScriptNames(t *testing.T) {
+	names := []string{"px/cluster", "px/http_data", "px/http_stats"}
+	assert.Equal(t, []string{"px/http_data", "px/http_stats"}, matchScriptNames(names, nil, "px/ht"))
+	assert.Equal(t, []string{"px/cluster,px/http_stats"}, matchScriptNames(names, nil, "px/cluster,px/http_s"))
+	assert.Equal(t, []string{"px/cluster,px/http_stats"}, matchScriptNames(names, []string{"px/http_data"}, "px/cluster,px/h"))
+	assert.Empty(t, matchScriptNames(names, nil, "px/nope"))
+}
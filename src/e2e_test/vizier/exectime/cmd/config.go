@@ -0,0 +1,195 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/yaml"
+)
+
+// benchmarkMetadataKey is the key of the BenchmarkMetadata in the benchmark's json output. It can't collide with
+// a script name, which always has a directory.
+const benchmarkMetadataKey = "_metadata"
+
+// Flags that only make sense on the command line, so they are neither read from nor written to a config file.
+var nonConfigFlags = map[string]bool{
+	"config": true,
+	"help":   true,
+}
+
+func init() {
+	ConfigCmd.AddCommand(ConfigPrintCmd)
+	RootCmd.AddCommand(ConfigCmd)
+}
+
+// BenchmarkMetadata describes how the results in the benchmark's json output were produced.
+type BenchmarkMetadata struct {
+	// Config is the value of every benchmark flag, after the config file is merged with the command line.
+	Config map[string]interface{}
+	// BundleHash is the sha256 of the bundle that the scripts were read from.
+	BundleHash string `json:",omitempty"`
+	// Clusters are the names of the clusters selected with --cluster, keyed by cluster ID.
+	Clusters map[string]string `json:",omitempty"`
+	// AutoArgs are the args discovered with --auto-args, keyed by the cloud they were discovered through.
+	AutoArgs map[string]*DiscoveredArgs `json:",omitempty"`
+	// GCInterference is set if the scripts ran in parallel. Garbage is then no longer collected before each run,
+	// so collections, and their pauses, may land in the timings of any run.
+	GCInterference bool `json:",omitempty"`
+}
+
+// applyConfigFile sets the flags that aren't set on the command line from the config file given by --config, if
+// any. Keys that aren't flags are an error, so that a typo doesn't silently leave a flag at its default.
+func applyConfigFile(flags *pflag.FlagSet) error {
+	path, _ := flags.GetString("config")
+	if path == "" {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	config, err := parseConfig(content)
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	keys := make([]string, 0, len(config))
+	for k := range config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		flag := flags.Lookup(k)
+		if flag == nil || nonConfigFlags[k] {
+			return fmt.Errorf("unknown key %q in config file %s", k, path)
+		}
+		// The command line takes precedence over the config file.
+		if flag.Changed {
+			continue
+		}
+		if err := setFlagFromConfig(flags, flag, config[k]); err != nil {
+			return fmt.Errorf("invalid value for %q in config file %s: %w", k, path, err)
+		}
+	}
+	return nil
+}
+
+// parseConfig parses a yaml config file into the values of its keys. Numbers are kept as json.Numbers, so that
+// large ints keep their formatting.
+func parseConfig(content []byte) (map[string]interface{}, error) {
+	jsonContent, err := yaml.YAMLToJSON(content)
+	if err != nil {
+		return nil, err
+	}
+	config := make(map[string]interface{})
+	dec := json.NewDecoder(bytes.NewReader(jsonContent))
+	dec.UseNumber()
+	if err := dec.Decode(&config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func setFlagFromConfig(flags *pflag.FlagSet, flag *pflag.Flag, value interface{}) error {
+	if list, ok := value.([]interface{}); ok {
+		sv, ok := flag.Value.(pflag.SliceValue)
+		if !ok {
+			return fmt.Errorf("expected a %s, got a list", flag.Value.Type())
+		}
+		vals := make([]string, len(list))
+		for i, v := range list {
+			vals[i] = fmt.Sprint(v)
+		}
+		if err := sv.Replace(vals); err != nil {
+			return err
+		}
+		flag.Changed = true
+		return nil
+	}
+	if _, ok := value.(map[string]interface{}); ok {
+		return fmt.Errorf("expected a %s, got a map", flag.Value.Type())
+	}
+	return flags.Set(flag.Name, fmt.Sprint(value))
+}
+
+// effectiveConfig returns the value of every flag that can be set from a config file.
+func effectiveConfig(flags *pflag.FlagSet) map[string]interface{} {
+	config := make(map[string]interface{})
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if nonConfigFlags[flag.Name] {
+			return
+		}
+		config[flag.Name] = flagConfigValue(flag)
+	})
+	return config
+}
+
+// flagConfigValue returns the flag's value as it would be written in a config file.
+func flagConfigValue(flag *pflag.Flag) interface{} {
+	if sv, ok := flag.Value.(pflag.SliceValue); ok {
+		return sv.GetSlice()
+	}
+	s := flag.Value.String()
+	switch flag.Value.Type() {
+	case "bool":
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	case "int":
+		if i, err := strconv.Atoi(s); err == nil {
+			return i
+		}
+	case "float64":
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}
+
+// ConfigCmd groups the commands for benchmark config files.
+var ConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Work with benchmark config files",
+}
+
+// ConfigPrintCmd prints the effective config of a benchmark invocation.
+var ConfigPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "Print the config that the benchmark would run with, given the same flags",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := applyConfigFile(cmd.Flags()); err != nil {
+			log.WithError(err).Fatal("Failed to apply the config file")
+		}
+		out, err := yaml.Marshal(effectiveConfig(cmd.Flags()))
+		if err != nil {
+			log.WithError(err).Fatal("Failed to marshal the config")
+		}
+		fmt.Print(string(out))
+	},
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFlags(t *testing.T, config string, args ...string) *pflag.FlagSet {
+	path := filepath.Join(t.TempDir(), "bench.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(config), 0644))
+
+	flags := pflag.NewFlagSet("benchmark", pflag.ContinueOnError)
+	flags.String("config", "", "")
+	flags.Int("num_runs", 20, "")
+	flags.StringSlice("scripts", nil, "")
+	flags.Float64("max-variance", 0.35, "")
+	flags.Duration("mutation-deadline", 2*time.Minute, "")
+	flags.Bool("retry-noisy", false, "")
+	require.NoError(t, flags.Parse(append([]string{"--config", path}, args...)))
+	return flags
+}
+
+func TestApplyConfigFile_CommandLineTakesPrecedence(t *testing.T) {
+	flags := testFlags(t, `
+num_runs: 5
+scripts: [px/http_data, px/cluster]
+max-variance: 0.2
+mutation-deadline: 30s
+`, "--num_runs", "7")
+	require.NoError(t, applyConfigFile(flags))
+
+	numRuns, _ := flags.GetInt("num_runs")
+	assert.Equal(t, 7, numRuns)
+	scripts, _ := flags.GetStringSlice("scripts")
+	assert.Equal(t, []string{"px/http_data", "px/cluster"}, scripts)
+	maxVariance, _ := flags.GetFloat64("max-variance")
+	assert.Equal(t, 0.2, maxVariance)
+	deadline, _ := flags.GetDuration("mutation-deadline")
+	assert.Equal(t, 30*time.Second, deadline)
+	// Flags in neither keep their defaults.
+	retryNoisy, _ := flags.GetBool("retry-noisy")
+	assert.False(t, retryNoisy)
+}
+
+func TestApplyConfigFile_UnknownKey(t *testing.T) {
+	flags := testFlags(t, "num_run: 5\n")
+	err := applyConfigFile(flags)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown key "num_run"`)
+
+	flags = testFlags(t, "config: other.yaml\n")
+	assert.Error(t, applyConfigFile(flags))
+}
+
+func TestApplyConfigFile_InvalidValue(t *testing.T) {
+	flags := testFlags(t, "num_runs: [1, 2]\n")
+	assert.Error(t, applyConfigFile(flags))
+}
+
+func TestEffectiveConfig(t *testing.T) {
+	flags := testFlags(t, "scripts: [px/cluster]\n", "--retry-noisy")
+	require.NoError(t, applyConfigFile(flags))
+	assert.Equal(t, map[string]interface{}{
+		"num_runs":          20,
+		"scripts":           []string{"px/cluster"},
+		"max-variance":      0.35,
+		"mutation-deadline": "2m0s",
+		"retry-noisy":       true,
+	}, effectiveConfig(flags))
+}
+
+func TestEffectiveConfig_RecordsScriptTimeout(t *testing.T) {
+	// The timeout is in the json metadata, so that results run with different timeouts aren't compared as is.
+	assert.Equal(t, benchmarkScriptTimeout.String(), effectiveConfig(BenchmarkCmd.PersistentFlags())["timeout"])
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+)
+
+// addConnectionFlags adds the flags that tune the connections to the viziers. Their defaults are the behavior of
+// the connections without them, other than the larger max message size.
+func addConnectionFlags(c *cobra.Command) {
+	c.PersistentFlags().Int("max-grpc-message-size", defaultMaxGRPCMessageSize, "The largest message, in bytes, to accept from the viziers. Scripts whose result batches are larger fail")
+	c.PersistentFlags().Duration("connect-timeout", 5*time.Second, "How long to wait to connect to each vizier")
+	c.PersistentFlags().Duration("keepalive-time", 0, "Ping the viziers after this long without activity, including between runs, so that idle connections aren't dropped by NATs. Servers may close connections that ping more often than every 5m. 0 never pings")
+	c.PersistentFlags().Duration("keepalive-timeout", 20*time.Second, "Close the connection if a keepalive ping isn't acknowledged within this long")
+}
+
+// connectorOptions returns the options of the vizier connectors that the flags added by addConnectionFlags ask for.
+func connectorOptions(c *cobra.Command) ([]vizier.ConnectorOption, error) {
+	size, _ := c.Flags().GetInt("max-grpc-message-size")
+	connectTimeout, _ := c.Flags().GetDuration("connect-timeout")
+	keepaliveTime, _ := c.Flags().GetDuration("keepalive-time")
+	keepaliveTimeout, _ := c.Flags().GetDuration("keepalive-timeout")
+	if size <= 0 {
+		return nil, fmt.Errorf("--max-grpc-message-size must be positive, got %d", size)
+	}
+	if connectTimeout <= 0 {
+		return nil, fmt.Errorf("--connect-timeout must be positive, got %v", connectTimeout)
+	}
+	if keepaliveTime < 0 || keepaliveTimeout <= 0 {
+		return nil, fmt.Errorf("--keepalive-time can't be negative, and --keepalive-timeout must be positive")
+	}
+	return []vizier.ConnectorOption{
+		vizier.WithMaxMessageSize(size),
+		vizier.WithDialTimeout(connectTimeout),
+		vizier.WithKeepalive(keepaliveTime, keepaliveTimeout),
+	}, nil
+}
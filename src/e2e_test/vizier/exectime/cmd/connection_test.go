@@ -0,0 +1,44 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectorOptions(t *testing.T) {
+	c := &cobra.Command{}
+	addConnectionFlags(c)
+	require.NoError(t, c.ParseFlags(nil))
+	opts, err := connectorOptions(c)
+	require.NoError(t, err)
+	assert.Len(t, opts, 3)
+
+	require.NoError(t, c.Flags().Set("keepalive-time", "-1s"))
+	_, err = connectorOptions(c)
+	assert.Error(t, err)
+	require.NoError(t, c.Flags().Set("keepalive-time", "10m"))
+	require.NoError(t, c.Flags().Set("connect-timeout", "0s"))
+	_, err = connectorOptions(c)
+	assert.Error(t, err)
+}
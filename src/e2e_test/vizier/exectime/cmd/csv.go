@@ -0,0 +1,197 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// A csvColumn is one column of the csv output, computed from a script's distribution at key.
+type csvColumn struct {
+	header string
+	key    string
+	// value returns the cell of the distribution. It is only called if the script has the distribution.
+	value func(Distribution) string
+}
+
+func formatCSVFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func formatCSVMillis(d time.Duration) string {
+	return formatCSVFloat(float64(d) / float64(time.Millisecond))
+}
+
+// timeCSVColumn returns a column of a statistic of the time distribution at key, in milliseconds. Distributions
+// without samples, such as the time to first row of a script whose every run failed, have empty cells.
+func timeCSVColumn(key, stat string, f func(*TimeDistribution) time.Duration) csvColumn {
+	return csvColumn{
+		header: fmt.Sprintf("%s %s (ms)", key, stat),
+		key:    key,
+		value: func(dist Distribution) string {
+			t, ok := dist.(*TimeDistribution)
+			if !ok || len(t.Times) == 0 {
+				return ""
+			}
+			return formatCSVMillis(f(t))
+		},
+	}
+}
+
+// meanStddevCSVColumns returns the columns of the mean and stddev of the distribution at key, which has n samples.
+func meanStddevCSVColumns(key string, f func(Distribution) (mean, stddev float64, n int)) []csvColumn {
+	stat := func(useMean bool) func(Distribution) string {
+		return func(dist Distribution) string {
+			mean, stddev, n := f(dist)
+			if n == 0 {
+				return ""
+			}
+			if useMean {
+				return formatCSVFloat(mean)
+			}
+			return formatCSVFloat(stddev)
+		}
+	}
+	return []csvColumn{
+		{header: key + " Mean", key: key, value: stat(true)},
+		{header: key + " Stddev", key: key, value: stat(false)},
+	}
+}
+
+// csvColumns returns the columns of the distribution at key. Time distributions get their mean, stddev and
+// percentiles, error distributions their count, and the other distributions their mean and stddev.
+func csvColumns(key string, dist Distribution) []csvColumn {
+	switch dist.(type) {
+	case *TimeDistribution:
+		return []csvColumn{
+			timeCSVColumn(key, "Mean", (*TimeDistribution).Mean),
+			timeCSVColumn(key, "Stddev", (*TimeDistribution).Stddev),
+			timeCSVColumn(key, "P50", (*TimeDistribution).P50),
+			timeCSVColumn(key, "P90", (*TimeDistribution).P90),
+			timeCSVColumn(key, "P99", (*TimeDistribution).P99),
+		}
+	case *ErrorDistribution:
+		return []csvColumn{{
+			header: key,
+			key:    key,
+			value: func(dist Distribution) string {
+				if e, ok := dist.(*ErrorDistribution); ok {
+					return strconv.Itoa(e.Num())
+				}
+				return ""
+			},
+		}}
+	case *BytesDistribution:
+		return meanStddevCSVColumns(key, func(dist Distribution) (float64, float64, int) {
+			if b, ok := dist.(*BytesDistribution); ok && len(b.Bytes) > 0 {
+				return b.Mean(), b.Stddev(), len(b.Bytes)
+			}
+			return 0, 0, 0
+		})
+	case *RateDistribution:
+		return meanStddevCSVColumns(key, func(dist Distribution) (float64, float64, int) {
+			if r, ok := dist.(*RateDistribution); ok {
+				return r.Mean(), r.Stddev(), len(r.Rates)
+			}
+			return 0, 0, 0
+		})
+	case *RatioDistribution:
+		return meanStddevCSVColumns(key, func(dist Distribution) (float64, float64, int) {
+			if r, ok := dist.(*RatioDistribution); ok {
+				return r.Mean(), r.Stddev(), len(r.Ratios)
+			}
+			return 0, 0, 0
+		})
+	}
+	return []csvColumn{{header: key, key: key, value: Distribution.Summarize}}
+}
+
+// csvResultsWriter writes the results of each script as a row of csv, for spreadsheets and dashboards. The
+// columns are those of every distribution of any script, sorted by key like the table, so that the header
+// only changes if the distributions do. Scripts without a distribution have empty cells in its columns.
+type csvResultsWriter struct {
+	out io.Writer
+	// endpoints are the labels of the endpoints that each set of results ran through. If set, each row starts
+	// with its endpoint.
+	endpoints []string
+}
+
+// Write writes the header, and a row for every script in each set of results.
+func (w *csvResultsWriter) Write(data ...[]*ScriptExecData) error {
+	if len(w.endpoints) > 0 && len(w.endpoints) != len(data) {
+		return fmt.Errorf("got %d sets of results for %d endpoints", len(data), len(w.endpoints))
+	}
+	dists := make(map[string]Distribution)
+	for _, results := range data {
+		for _, d := range results {
+			for k, dist := range d.Distributions {
+				if _, ok := dists[k]; !ok {
+					dists[k] = dist
+				}
+			}
+		}
+	}
+	keys := make([]string, 0, len(dists))
+	for k := range dists {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var columns []csvColumn
+	for _, k := range keys {
+		columns = append(columns, csvColumns(k, dists[k])...)
+	}
+
+	cw := csv.NewWriter(w.out)
+	header := []string{"Name"}
+	if len(w.endpoints) > 0 {
+		header = append([]string{"Endpoint"}, header...)
+	}
+	for _, c := range columns {
+		header = append(header, c.header)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for i, results := range data {
+		for _, d := range results {
+			row := []string{d.Name}
+			if len(w.endpoints) > 0 {
+				row = append([]string{w.endpoints[i]}, row...)
+			}
+			for _, c := range columns {
+				dist, ok := d.Distributions[c.key]
+				if !ok {
+					row = append(row, "")
+					continue
+				}
+				row = append(row, c.value(dist))
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readCSV(t *testing.T, buf *bytes.Buffer) [][]string {
+	records, err := csv.NewReader(buf).ReadAll()
+	require.NoError(t, err)
+	return records
+}
+
+func TestCSVResultsWriter(t *testing.T) {
+	cluster := &ScriptExecData{
+		Name: "px/cluster",
+		Distributions: map[string]Distribution{
+			execTimeExternalLabel: &TimeDistribution{Times: []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}},
+			numErrorsLabel:        &ErrorDistribution{Errors: []error{nil, assert.AnError}},
+			numBytesLabel:         &BytesDistribution{Bytes: []int{100, 300}},
+		},
+	}
+	// The streamed script is missing the bytes, and has no samples of its exec time.
+	streamed := &ScriptExecData{
+		Name: "px/stream",
+		Distributions: map[string]Distribution{
+			execTimeExternalLabel: &TimeDistribution{},
+			numErrorsLabel:        &ErrorDistribution{Errors: []error{nil}},
+			rowsPerSecondLabel:    &RateDistribution{Rates: []float64{2.5}},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, (&csvResultsWriter{out: &buf}).Write([]*ScriptExecData{cluster, streamed}))
+	assert.Equal(t, [][]string{
+		{
+			"Name",
+			"Exec Time: External Mean (ms)", "Exec Time: External Stddev (ms)",
+			"Exec Time: External P50 (ms)", "Exec Time: External P90 (ms)", "Exec Time: External P99 (ms)",
+			"Num Bytes Mean", "Num Bytes Stddev",
+			"Num Errors",
+			"Rows Per Second Mean", "Rows Per Second Stddev",
+		},
+		{"px/cluster", "15", "5", "15", "19", "19.9", "200", "100", "1", "", ""},
+		{"px/stream", "", "", "", "", "", "", "", "0", "2.5", "0"},
+	}, readCSV(t, &buf))
+}
+
+func TestCSVResultsWriter_Endpoints(t *testing.T) {
+	data := func(ms int) []*ScriptExecData {
+		return []*ScriptExecData{timedScriptExecData("px/cluster", time.Duration(ms)*time.Millisecond)}
+	}
+	var buf bytes.Buffer
+	w := &csvResultsWriter{out: &buf, endpoints: []string{"cloud-a:443", "cloud-b:443"}}
+	require.NoError(t, w.Write(data(10), data(30)))
+	records := readCSV(t, &buf)
+	require.Len(t, records, 3)
+	assert.Equal(t, []string{"Endpoint", "Name"}, records[0][:2])
+	assert.Equal(t, []string{"cloud-a:443", "px/cluster"}, records[1][:2])
+	assert.Equal(t, []string{"cloud-b:443", "px/cluster"}, records[2][:2])
+
+	assert.Error(t, w.Write(data(10)))
+}
+
+func TestWriteSmokeCSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeSmokeCSV(&buf, []*SmokeResult{
+		{Script: "px/cluster", OK: true, Duration: "1.2s"},
+		{Script: "px/http_data", Error: "compile error, with a comma", Duration: "10ms"},
+	}))
+	assert.Equal(t, [][]string{
+		{"Name", "OK", "Duration", "Error"},
+		{"px/cluster", "true", "1.2s", ""},
+		{"px/http_data", "false", "10ms", "compile error, with a comma"},
+	}, readCSV(t, &buf))
+}
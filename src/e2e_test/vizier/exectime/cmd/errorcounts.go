@@ -0,0 +1,105 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// errorScrubber replaces a part of an error message that varies from run to run, so that the runs that failed
+// the same way are counted together.
+type errorScrubber struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// errorScrubbers are applied in order, so the more specific patterns come first. Ex: a UUID must be scrubbed
+// before the hex IDs, which would otherwise match its parts, and the hex IDs would match any long number.
+var errorScrubbers = []errorScrubber{
+	// Ex: 2c1e7a9d-6f04-4f4e-9a8e-2f0c8f3d1b5a
+	{regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`), "<uuid>"},
+	// Ex: 2022-03-04T05:06:07.123456Z, 2022-03-04 05:06:07 +0000 UTC
+	{regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?( ?(Z|[+-]\d{2}:?\d{2}))?( [A-Z]{3,4})?`), "<time>"},
+	// Ex: the nanosecond timestamps of the data, 1646370367123456789.
+	{regexp.MustCompile(`\b\d{13,}\b`), "<ts>"},
+	// Ex: the trace and span IDs in the errors of the query broker, 4bf92f3577b34da6a3ce929d0e0e4736.
+	{regexp.MustCompile(`(?i)\b(0x)?[0-9a-f]{16,}\b`), "<id>"},
+}
+
+// scrubErrorMessage replaces the parts of the message that vary from run to run with placeholders.
+func scrubErrorMessage(msg string) string {
+	for _, s := range errorScrubbers {
+		msg = s.re.ReplaceAllString(msg, s.replacement)
+	}
+	return msg
+}
+
+// errorCounts returns the number of runs of the script that failed with each scrubbed error message. This
+// includes the failures to deploy or remove the tracepoints of mutation scripts.
+func errorCounts(d *ScriptExecData) map[string]int {
+	var errs []error
+	for _, f := range d.Failures {
+		errs = append(errs, f)
+	}
+	if dist, ok := d.Distributions[mutationErrorsLabel].(*ErrorDistribution); ok {
+		errs = append(errs, dist.Errors...)
+	}
+	counts := make(map[string]int)
+	for _, err := range errs {
+		if err != nil {
+			counts[scrubErrorMessage(err.Error())]++
+		}
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+	return counts
+}
+
+// countErrors sets the ErrorCounts of every script.
+func countErrors(data map[string]*ScriptExecData) {
+	for _, d := range data {
+		d.ErrorCounts = errorCounts(d)
+	}
+}
+
+// writeErrorCounts lists the distinct errors of each script under its table, most common first.
+func writeErrorCounts(ep *benchmarkEndpoint) {
+	for _, d := range sortByKeys(&ep.data) {
+		if len(d.ErrorCounts) == 0 {
+			continue
+		}
+		msgs := make([]string, 0, len(d.ErrorCounts))
+		for msg := range d.ErrorCounts {
+			msgs = append(msgs, msg)
+		}
+		sort.Slice(msgs, func(i, j int) bool {
+			if d.ErrorCounts[msgs[i]] != d.ErrorCounts[msgs[j]] {
+				return d.ErrorCounts[msgs[i]] > d.ErrorCounts[msgs[j]]
+			}
+			return msgs[i] < msgs[j]
+		})
+		fmt.Printf("Errors of %s:\n", d.Name)
+		for _, msg := range msgs {
+			fmt.Printf("  %dx %s\n", d.ErrorCounts[msg], msg)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrubErrorMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want string
+	}{
+		{
+			name: "uuid",
+			msg:  "agent 2c1e7a9d-6f04-4f4e-9a8e-2f0c8f3d1b5a is not responding",
+			want: "agent <uuid> is not responding",
+		},
+		{
+			name: "upper case uuid",
+			msg:  "query 2C1E7A9D-6F04-4F4E-9A8E-2F0C8F3D1B5A failed",
+			want: "query <uuid> failed",
+		},
+		{
+			name: "rfc3339 timestamp",
+			msg:  "deadline at 2022-03-04T05:06:07.123456Z exceeded",
+			want: "deadline at <time> exceeded",
+		},
+		{
+			name: "go timestamp",
+			msg:  "stream closed at 2022-03-04 05:06:07.123 +0000 UTC",
+			want: "stream closed at <time>",
+		},
+		{
+			name: "trace id",
+			msg:  "rpc failed, trace 4bf92f3577b34da6a3ce929d0e0e4736",
+			want: "rpc failed, trace <id>",
+		},
+		{
+			name: "nanosecond timestamp",
+			msg:  "row with time 1646370367123456789 is out of order",
+			want: "row with time <ts> is out of order",
+		},
+		{
+			name: "stable message",
+			msg:  "Table 'http_events' not found on line 3",
+			want: "Table 'http_events' not found on line 3",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, scrubErrorMessage(tc.msg))
+		})
+	}
+}
+
+func TestErrorCounts(t *testing.T) {
+	data := newMutationScriptExecData("px/http_data")
+	for _, err := range []error{
+		errors.New("agent 2c1e7a9d-6f04-4f4e-9a8e-2f0c8f3d1b5a is not responding"),
+		nil,
+		errors.New("agent 7d3b1e3a-0b1c-4b9e-8c51-0f7e2d9c6a11 is not responding"),
+		errors.New("Table 'http_events' not found"),
+	} {
+		recordResults(data, &execResults{scriptErr: err})
+	}
+	data.Distributions[mutationErrorsLabel].Append(newRunFailure(0, errors.New("probe timed out")))
+
+	assert.Equal(t, map[string]int{
+		"Unknown: agent <uuid> is not responding": 2,
+		"Unknown: Table 'http_events' not found":  1,
+		"Unknown: probe timed out":                1,
+	}, errorCounts(data))
+	assert.Nil(t, errorCounts(newScriptExecData("px/cluster")))
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"reflect"
+	"strings"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+)
+
+// The exec stats, keyed by their path in the QueryExecutionStats message.
+const (
+	execTimeStat    = "timing.execution_time_ns"
+	compileTimeStat = "timing.compilation_time_ns"
+	// Viziers that report how long the query waited in the query broker before executing report it here. Older
+	// Viziers, and the current API, don't, in which case the queue time distributions are omitted.
+	queueTimeStat = "timing.queue_time_ns"
+)
+
+const (
+	// The time the query waited before executing, and the internal exec time without it.
+	queueTimeLabel    = "Queue Time"
+	pureExecTimeLabel = "Pure Exec Time"
+)
+
+// execStatsFields returns every integer field of the exec stats, keyed by its path of proto field names, ex:
+// "timing.execution_time_ns". Fields of the message are included even if they are zero, since proto3 doesn't
+// distinguish zero from unset, so a stat is reported exactly when the Vizier's API has the field.
+func execStatsFields(es *vizierpb.QueryExecutionStats) map[string]int64 {
+	fields := make(map[string]int64)
+	addProtoFields(fields, "", reflect.ValueOf(es))
+	return fields
+}
+
+func addProtoFields(fields map[string]int64, prefix string, v reflect.Value) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < v.NumField(); i++ {
+		name := protoFieldName(v.Type().Field(i).Tag.Get("protobuf"))
+		if name == "" {
+			continue
+		}
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.Int64, reflect.Int32:
+			fields[prefix+name] = f.Int()
+		case reflect.Uint64, reflect.Uint32:
+			fields[prefix+name] = int64(f.Uint())
+		case reflect.Ptr, reflect.Struct:
+			addProtoFields(fields, prefix+name+".", f)
+		}
+	}
+}
+
+// protoFieldName returns the name of the field from its protobuf struct tag, ex:
+// "varint,1,opt,name=execution_time_ns,json=executionTimeNs,proto3".
+func protoFieldName(tag string) string {
+	for _, part := range strings.Split(tag, ",") {
+		if strings.HasPrefix(part, "name=") {
+			return strings.TrimPrefix(part, "name=")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+)
+
+func TestExecStatsFields(t *testing.T) {
+	fields := execStatsFields(&vizierpb.QueryExecutionStats{
+		Timing: &vizierpb.QueryTimingInfo{
+			ExecutionTimeNs:   int64(time.Second),
+			CompilationTimeNs: 0,
+		},
+		BytesProcessed:   100,
+		RecordsProcessed: 10,
+	})
+	assert.Equal(t, map[string]int64{
+		execTimeStat:        int64(time.Second),
+		compileTimeStat:     0,
+		"bytes_processed":   100,
+		"records_processed": 10,
+	}, fields)
+	// The current API doesn't report the queue time.
+	assert.NotContains(t, fields, queueTimeStat)
+}
+
+func TestRecordResults_QueueTime(t *testing.T) {
+	data := newScriptExecData("px/cluster")
+	recordResults(data, &execResults{internalExecTime: time.Second})
+	assert.NotContains(t, data.Distributions, queueTimeLabel)
+	assert.NotContains(t, data.Distributions, pureExecTimeLabel)
+
+	recordResults(data, &execResults{internalExecTime: time.Second, queueTime: 300 * time.Millisecond, hasQueueTime: true})
+	assert.Equal(t, []time.Duration{300 * time.Millisecond}, data.Distributions[queueTimeLabel].(*TimeDistribution).Times)
+	assert.Equal(t, []time.Duration{700 * time.Millisecond}, data.Distributions[pureExecTimeLabel].(*TimeDistribution).Times)
+}
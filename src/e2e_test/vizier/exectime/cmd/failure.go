@@ -0,0 +1,262 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+)
+
+// Bounds on the size of a RunFailure, so that a script that fails with a huge status on every run doesn't
+// blow up the size of the results.
+const (
+	maxFailureMessageBytes = 4096
+	maxFailureDetails      = 10
+	maxFailureDetailBytes  = 1024
+)
+
+// FailureClass is the kind of failure that a run failed with.
+type FailureClass string
+
+const (
+	// FailureClassCompile is a script that failed to compile.
+	FailureClassCompile FailureClass = "Compile"
+	// FailureClassExecution is a script that Vizier failed to execute.
+	FailureClassExecution FailureClass = "Execution"
+	// FailureClassNetwork is a failure to reach Vizier, or to stream the results back.
+	FailureClassNetwork FailureClass = "Network"
+	// FailureClassTimeout is a run that didn't finish in time.
+	FailureClassTimeout FailureClass = "Timeout"
+	// FailureClassUnknown is any other failure.
+	FailureClassUnknown FailureClass = "Unknown"
+)
+
+// failureClassLabels are the labels of the error distributions that count each class of failure. Unknown
+// failures are only counted in the total.
+var failureClassLabels = map[FailureClass]string{
+	FailureClassCompile:   "Compile Errors",
+	FailureClassExecution: "Execution Errors",
+	FailureClassNetwork:   "Network Errors",
+	FailureClassTimeout:   "Timeout Errors",
+}
+
+// networkCodes are the gRPC codes that mean the request or its stream broke, rather than the script failing.
+var networkCodes = map[codes.Code]bool{
+	codes.Unavailable: true,
+	codes.Canceled:    true,
+	codes.Aborted:     true,
+	codes.Internal:    true,
+}
+
+// classifyFailure returns the class of the error returned by running a script.
+func classifyFailure(err error) FailureClass {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return FailureClassTimeout
+	}
+	var scriptErr *vizier.ScriptExecutionError
+	if errors.As(err, &scriptErr) {
+		switch {
+		case scriptErr.Code() == vizier.CodeCompilerError:
+			return FailureClassCompile
+		case scriptErr.Code() == vizier.CodeTimeout:
+			return FailureClassTimeout
+		case scriptErr.Status() != nil:
+			if codes.Code(scriptErr.Status().Code) == codes.DeadlineExceeded {
+				return FailureClassTimeout
+			}
+			return FailureClassExecution
+		case scriptErr.RPCStatus() != nil:
+			return classifyCode(scriptErr.RPCStatus().Code())
+		}
+		return FailureClassUnknown
+	}
+	if s, ok := status.FromError(err); ok {
+		return classifyCode(s.Code())
+	}
+	return FailureClassUnknown
+}
+
+// classifyCode returns the class of a failed gRPC call.
+func classifyCode(code codes.Code) FailureClass {
+	switch {
+	case code == codes.DeadlineExceeded:
+		return FailureClassTimeout
+	case networkCodes[code]:
+		return FailureClassNetwork
+	case code == codes.OK, code == codes.Unknown:
+		return FailureClassUnknown
+	}
+	return FailureClassExecution
+}
+
+// RunFailure is the structured record of a failed run of a script. Unlike the formatted error message, it
+// keeps the status code and error details, such as compiler errors with their line numbers.
+type RunFailure struct {
+	// Run is the index of the failed run, among all runs of the script.
+	Run   int
+	Class FailureClass
+	// Code is the status code that the run failed with. Ex: "InvalidArgument".
+	Code    string
+	Message string
+	// Details are the error details attached to the status, truncated to maxFailureDetailBytes each.
+	Details []string `json:",omitempty"`
+	// DroppedDetails is the number of details beyond maxFailureDetails that were not recorded.
+	DroppedDetails int `json:",omitempty"`
+}
+
+func (f *RunFailure) Error() string {
+	return fmt.Sprintf("%s: %s", f.Code, f.Message)
+}
+
+func (f *RunFailure) addDetail(detail string) {
+	if len(f.Details) >= maxFailureDetails {
+		f.DroppedDetails++
+		return
+	}
+	f.Details = append(f.Details, truncateBytes(detail, maxFailureDetailBytes))
+}
+
+// newRunFailure extracts the status of the given run's error into a RunFailure.
+func newRunFailure(run int, err error) *RunFailure {
+	f := &RunFailure{Run: run, Class: classifyFailure(err), Code: codes.Unknown.String()}
+	message := err.Error()
+
+	var scriptErr *vizier.ScriptExecutionError
+	var rpcStatus *status.Status
+	if errors.As(err, &scriptErr) {
+		switch {
+		case scriptErr.Status() != nil:
+			s := scriptErr.Status()
+			f.Code = codes.Code(s.Code).String()
+			message = s.Message
+			for _, ed := range s.ErrorDetails {
+				f.addDetail(formatErrorDetails(ed))
+			}
+		case scriptErr.RPCStatus() != nil:
+			rpcStatus = scriptErr.RPCStatus()
+		case scriptErr.Code() == vizier.CodeTimeout:
+			f.Code = codes.DeadlineExceeded.String()
+		case scriptErr.Code() == vizier.CodeCanceled:
+			f.Code = codes.Canceled.String()
+		}
+	} else if s, ok := status.FromError(err); ok {
+		// Errors from starting the script, rather than from its stream, are plain gRPC errors.
+		rpcStatus = s
+	}
+	if rpcStatus != nil {
+		f.Code = rpcStatus.Code().String()
+		message = rpcStatus.Message()
+		for _, d := range rpcStatus.Details() {
+			f.addDetail(fmt.Sprintf("%v", d))
+		}
+	}
+	f.Message = truncateBytes(message, maxFailureMessageBytes)
+	if isMessageTooLarge(err) {
+		f.addDetail(messageTooLargeHint)
+	}
+	return f
+}
+
+// recordFailure adds the failure of the next run to data's Failures and to the distribution of its class,
+// and returns it, so that the other error distributions can record the failure with its status, rather than
+// the bare error. Returns nil if err is nil.
+func recordFailure(data *ScriptExecData, err error) error {
+	if err == nil {
+		recordFailureClass(data, nil)
+		return nil
+	}
+	failure := newRunFailure(len(data.Distributions[numErrorsLabel].(*ErrorDistribution).Errors), err)
+	data.Failures = append(data.Failures, failure)
+	recordFailureClass(data, failure)
+	return failure
+}
+
+// numFailedScripts returns the number of scripts that have failed or oversized runs, or whose schema didn't match.
+func numFailedScripts(data map[string]*ScriptExecData) int {
+	n := 0
+	for _, d := range data {
+		if scriptFailed(d) {
+			n++
+		}
+	}
+	return n
+}
+
+// scriptFailed returns whether any run of the script failed or was oversized, or its schema didn't match.
+func scriptFailed(d *ScriptExecData) bool {
+	if len(d.Failures) > 0 || len(d.SchemaMismatches) > 0 || len(d.OversizedRuns) > 0 {
+		return true
+	}
+	// The failures to deploy or remove tracepoints aren't in Failures.
+	dist, ok := d.Distributions[mutationErrorsLabel]
+	return ok && dist.(*ErrorDistribution).Num() > 0
+}
+
+// addFailureClassDistributions adds an error distribution for each class of failure to dists.
+func addFailureClassDistributions(dists distributionMap) distributionMap {
+	for _, label := range failureClassLabels {
+		dists[label] = &ErrorDistribution{make([]error, 0)}
+	}
+	return dists
+}
+
+// recordFailureClass appends the failure of a run, which is nil for a successful run, to the distribution of
+// its class, and a success to the others.
+func recordFailureClass(data *ScriptExecData, failure *RunFailure) {
+	for class, label := range failureClassLabels {
+		dist, ok := data.Distributions[label]
+		if !ok {
+			continue
+		}
+		if failure != nil && failure.Class == class {
+			dist.Append(failure)
+		} else {
+			dist.Append(nil)
+		}
+	}
+}
+
+func formatErrorDetails(ed *vizierpb.ErrorDetails) string {
+	if e, ok := ed.Error.(*vizierpb.ErrorDetails_CompilerError); ok {
+		return fmt.Sprintf("L%d:C%d %s", e.CompilerError.Line, e.CompilerError.Column, e.CompilerError.Message)
+	}
+	return ed.String()
+}
+
+// truncateBytes shortens s to at most n bytes, without splitting a multi-byte character, and marks it as
+// truncated.
+func truncateBytes(s string, n int) string {
+	const marker = "...(truncated)"
+	if len(s) <= n {
+		return s
+	}
+	cut := n - len(marker)
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + marker
+}
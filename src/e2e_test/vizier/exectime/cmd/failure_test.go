@@ -0,0 +1,179 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+)
+
+// streamError returns the error that the stream adapter returns for a stream that ends with msg.
+func streamError(ctx context.Context, t *testing.T, msg *vizier.ExecData) error {
+	stream := make(chan *vizier.ExecData, 1)
+	if msg != nil {
+		stream <- msg
+	}
+	tw := vizier.NewStreamOutputAdapter(ctx, stream, vizier.FormatInMemory, nil)
+	err := tw.Finish()
+	require.Error(t, err)
+	return err
+}
+
+func statusResponse(s *vizierpb.Status) *vizier.ExecData {
+	return &vizier.ExecData{Resp: &vizierpb.ExecuteScriptResponse{Status: s}}
+}
+
+func TestClassifyFailure(t *testing.T) {
+	expiredCtx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	tests := []struct {
+		name     string
+		err      func(t *testing.T) error
+		expected FailureClass
+	}{
+		{
+			name: "compiler error",
+			err: func(t *testing.T) error {
+				return streamError(context.Background(), t, statusResponse(&vizierpb.Status{
+					Code:    int32(codes.InvalidArgument),
+					Message: "compilation failed",
+					ErrorDetails: []*vizierpb.ErrorDetails{{
+						Error: &vizierpb.ErrorDetails_CompilerError{CompilerError: &vizierpb.CompilerError{
+							Line: 3, Column: 5, Message: "name 'foo' is not defined",
+						}},
+					}},
+				}))
+			},
+			expected: FailureClassCompile,
+		},
+		{
+			name: "execution error",
+			err: func(t *testing.T) error {
+				return streamError(context.Background(), t, statusResponse(&vizierpb.Status{
+					Code:    int32(codes.Internal),
+					Message: "failed to execute plan",
+				}))
+			},
+			expected: FailureClassExecution,
+		},
+		{
+			name: "execution deadline exceeded",
+			err: func(t *testing.T) error {
+				return streamError(context.Background(), t, statusResponse(&vizierpb.Status{
+					Code:    int32(codes.DeadlineExceeded),
+					Message: "query timed out",
+				}))
+			},
+			expected: FailureClassTimeout,
+		},
+		{
+			name: "stream unavailable",
+			err: func(t *testing.T) error {
+				return streamError(context.Background(), t, &vizier.ExecData{
+					Err: status.Error(codes.Unavailable, "transport is closing"),
+				})
+			},
+			expected: FailureClassNetwork,
+		},
+		{
+			name: "stream permission denied",
+			err: func(t *testing.T) error {
+				return streamError(context.Background(), t, &vizier.ExecData{
+					Err: status.Error(codes.PermissionDenied, "unauthorized"),
+				})
+			},
+			expected: FailureClassExecution,
+		},
+		{
+			name: "stream deadline exceeded",
+			err: func(t *testing.T) error {
+				return streamError(context.Background(), t, &vizier.ExecData{
+					Err: status.Error(codes.DeadlineExceeded, "context deadline exceeded"),
+				})
+			},
+			expected: FailureClassTimeout,
+		},
+		{
+			name: "script timeout",
+			err: func(t *testing.T) error {
+				return streamError(expiredCtx, t, nil)
+			},
+			expected: FailureClassTimeout,
+		},
+		{
+			name: "unavailable before streaming",
+			err: func(t *testing.T) error {
+				return status.Error(codes.Unavailable, "connection refused")
+			},
+			expected: FailureClassNetwork,
+		},
+		{
+			name: "dial deadline exceeded",
+			err: func(t *testing.T) error {
+				return fmt.Errorf("failed to connect: %w", context.DeadlineExceeded)
+			},
+			expected: FailureClassTimeout,
+		},
+		{
+			name: "missing exec stats",
+			err: func(t *testing.T) error {
+				return errors.New("ExecStats not found")
+			},
+			expected: FailureClassUnknown,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, classifyFailure(test.err(t)))
+		})
+	}
+}
+
+func TestRecordFailure_CountsClasses(t *testing.T) {
+	data := newScriptExecData("px/test")
+	for _, err := range []error{
+		nil,
+		status.Error(codes.Unavailable, "connection refused"),
+		fmt.Errorf("failed to connect: %w", context.DeadlineExceeded),
+		status.Error(codes.Unavailable, "connection refused"),
+	} {
+		data.Distributions[numErrorsLabel].Append(recordFailure(data, err))
+	}
+
+	assert.Equal(t, 3, data.Distributions[numErrorsLabel].(*ErrorDistribution).Num())
+	assert.Equal(t, 2, data.Distributions[failureClassLabels[FailureClassNetwork]].(*ErrorDistribution).Num())
+	assert.Equal(t, 1, data.Distributions[failureClassLabels[FailureClassTimeout]].(*ErrorDistribution).Num())
+	assert.Equal(t, 0, data.Distributions[failureClassLabels[FailureClassCompile]].(*ErrorDistribution).Num())
+	require.Len(t, data.Failures, 3)
+	assert.Equal(t, []int{1, 2, 3}, []int{data.Failures[0].Run, data.Failures[1].Run, data.Failures[2].Run})
+	assert.Equal(t, FailureClassNetwork, data.Failures[0].Class)
+}
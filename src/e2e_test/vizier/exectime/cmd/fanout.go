@@ -0,0 +1,244 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gofrs/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+	"px.dev/pixie/src/utils/script"
+)
+
+// clusterResult is the result of running a script on one of the clusters of a run.
+type clusterResult struct {
+	clusterID uuid.UUID
+	// The time from the start of the run until the cluster's stream finished.
+	elapsed  time.Duration
+	timedOut bool
+	err      error
+	// The times at which the batches of rows from the cluster were received, and the rows in them.
+	batchTimes []time.Time
+	numRows    int
+	// The ID that the cluster gave the run's query.
+	queryID string
+	// Every stat in the cluster's exec stats, keyed as by execStatsFields.
+	execStats map[string]int64
+	numBytes  int
+	wireBytes int
+	// The metadata of the tables that the cluster output.
+	tables []*vizierpb.QueryMetadata
+}
+
+// executeOnCluster runs the script on a single cluster, with its own deadline, so that a slow cluster doesn't
+// hold up the measurement of the others. The messages of the stream are recorded to rec, if it is set, and their
+// bytes are counted towards the run's bc. The results are accumulated in the given stream format.
+func executeOnCluster(ctx context.Context, c *vizier.Connector, execScript *script.ExecutableScript, start time.Time,
+	timeout time.Duration, rec *responseRecorder, bc *byteCap, format string) (*clusterResult, error) {
+	ctx, cancel := context.WithDeadline(ctx, start.Add(timeout))
+	defer cancel()
+	// Count the wire bytes of this run's stream only, apart from any other runs on the same connection.
+	var wire vizier.WireBytesCounter
+	ctx = vizier.WithWireBytesCounter(ctx, &wire)
+	res := &clusterResult{clusterID: c.ID()}
+	resp, err := vizier.RunScript(ctx, []*vizier.Connector{c}, execScript, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	onRowBatch := func(numRows int, receivedAt time.Time) {
+		res.batchTimes = append(res.batchTimes, receivedAt)
+		res.numRows += numRows
+	}
+	onMessage := func(msg *vizier.ExecData) {
+		if res.queryID == "" {
+			res.queryID = msg.Resp.GetQueryID()
+		}
+		if md := msg.Resp.GetMetaData(); md != nil {
+			res.tables = append(res.tables, md)
+		}
+		if rec != nil {
+			rec.record(msg)
+		}
+		// Counted as the messages arrive, so that an oversized run is canceled before it is buffered in full.
+		bc.add(msg.Resp.Size())
+	}
+	opts := []vizier.StreamOutputAdapterOption{vizier.WithRowBatchCallback(onRowBatch), vizier.WithMessageCallback(onMessage)}
+	tw := vizier.NewStreamOutputAdapter(ctx, resp, format, nil, opts...)
+	err = tw.Finish()
+	res.elapsed = time.Since(start)
+	stopStream(cancel, resp)
+	res.wireBytes = int(wire.Sent() + wire.Received())
+
+	var scriptErr *vizier.ScriptExecutionError
+	if errors.As(err, &scriptErr) && scriptErr.Code() == vizier.CodeTimeout {
+		res.timedOut = true
+	}
+	if err != nil {
+		res.err = fmt.Errorf("cluster %s: %w", res.clusterID, explainMessageTooLarge(err))
+		return res, nil
+	}
+
+	// Get the exec stats collected during the stream accumulation.
+	execStats, err := tw.ExecStats()
+	if err != nil {
+		res.err = fmt.Errorf("cluster %s: %w", res.clusterID, err)
+		return res, nil
+	}
+	res.execStats = execStatsFields(execStats)
+	res.numBytes = tw.TotalBytes()
+	return res, nil
+}
+
+// combineClusterResults combines the results of the clusters of a run. Clusters that timed out are left out of
+// the measurements, and only make the run a timeout if no other cluster failed.
+func combineClusterResults(results []*clusterResult, start time.Time) *execResults {
+	execRes := &execResults{}
+	var batchTimes []time.Time
+	var timeoutErr error
+	numFinished := 0
+	numQueueTimes := 0
+	for _, r := range results {
+		if r.timedOut {
+			execRes.timedOutClusters = append(execRes.timedOutClusters, r.clusterID)
+			if timeoutErr == nil {
+				timeoutErr = r.err
+			}
+			continue
+		}
+		if r.elapsed > execRes.externalExecTime {
+			execRes.externalExecTime = r.elapsed
+		}
+		// The bytes on the wire cost the same whether or not the run failed.
+		execRes.wireBytes += r.wireBytes
+		batchTimes = append(batchTimes, r.batchTimes...)
+		execRes.numRows += r.numRows
+		if r.queryID != "" {
+			if execRes.queryIDs == nil {
+				execRes.queryIDs = make(map[uuid.UUID]string)
+			}
+			execRes.queryIDs[r.clusterID] = r.queryID
+		}
+		if r.err != nil {
+			if execRes.scriptErr == nil {
+				execRes.scriptErr = r.err
+			}
+			continue
+		}
+		numFinished++
+		if numFinished == 1 {
+			execRes.tables = r.tables
+		}
+		if d := time.Duration(r.execStats[execTimeStat]); d > execRes.internalExecTime {
+			execRes.internalExecTime = d
+		}
+		if d := time.Duration(r.execStats[compileTimeStat]); d > execRes.compileTime {
+			execRes.compileTime = d
+		}
+		if queueTime, ok := r.execStats[queueTimeStat]; ok {
+			numQueueTimes++
+			if d := time.Duration(queueTime); d > execRes.queueTime {
+				execRes.queueTime = d
+			}
+		}
+		execRes.numBytes += r.numBytes
+	}
+	// The queue time is only meaningful if every cluster reported it.
+	execRes.hasQueueTime = numFinished > 0 && numQueueTimes == numFinished
+
+	if len(execRes.timedOutClusters) == len(results) {
+		// Every cluster timed out, so the run took as long as the deadline.
+		for _, r := range results {
+			if r.elapsed > execRes.externalExecTime {
+				execRes.externalExecTime = r.elapsed
+			}
+		}
+	}
+	if execRes.scriptErr == nil {
+		execRes.scriptErr = timeoutErr
+	}
+	if execRes.scriptErr != nil {
+		// As before, a failed run has no internal times.
+		execRes.internalExecTime = 0
+		execRes.compileTime = 0
+		execRes.queueTime = 0
+		execRes.hasQueueTime = false
+		execRes.numBytes = 0
+		execRes.tables = nil
+	}
+
+	sort.Slice(batchTimes, func(i, j int) bool { return batchTimes[i].Before(batchTimes[j]) })
+	if len(batchTimes) > 0 {
+		execRes.timeToFirstRow = batchTimes[0].Sub(start)
+		execRes.receivedRows = true
+	}
+	execRes.batchGaps = batchGaps(batchTimes)
+	execRes.numBatches = len(batchTimes)
+	return execRes
+}
+
+// recordClusterTimeouts counts the timeouts of the clusters in the given run, and drops the clusters that reach
+// dropAfter timeouts from the later runs. The last cluster is never dropped. A dropAfter of 0 never drops.
+func (ep *benchmarkEndpoint) recordClusterTimeouts(run int, timedOut []uuid.UUID, dropAfter int) {
+	for _, id := range timedOut {
+		ep.clusterTimeouts[id]++
+		if dropAfter == 0 || ep.clusterTimeouts[id] < dropAfter || len(ep.conns) <= 1 {
+			continue
+		}
+		for i, c := range ep.conns {
+			if c.ID() == id {
+				ep.conns = append(ep.conns[:i:i], ep.conns[i+1:]...)
+				break
+			}
+		}
+		log.WithField("cloud_addr", ep.cloudAddr).WithField("cluster_id", id).
+			Warnf("Dropping cluster from the remaining runs after %d timeouts", ep.clusterTimeouts[id])
+		for _, d := range ep.data {
+			if d.DroppedClusters == nil {
+				d.DroppedClusters = make(map[string]int)
+			}
+			d.DroppedClusters[id.String()] = run
+		}
+	}
+}
+
+// writeDroppedClusters notes the clusters that were dropped from the endpoint's runs, under its table.
+func writeDroppedClusters(ep *benchmarkEndpoint) {
+	dropped := make(map[string]int)
+	for _, d := range ep.data {
+		for id, run := range d.DroppedClusters {
+			dropped[id] = run
+		}
+	}
+	ids := make([]string, 0, len(dropped))
+	for id := range dropped {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		fmt.Printf("Dropped cluster %s after run %d: timed out %d times\n", id, dropped[id], ep.clusterTimeouts[uuid.FromStringOrNil(id)])
+	}
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCombineClusterResults_IgnoresTimedOutClusters(t *testing.T) {
+	start := time.Now()
+	fast := uuid.Must(uuid.NewV4())
+	hung := uuid.Must(uuid.NewV4())
+	results := []*clusterResult{
+		{
+			clusterID:  fast,
+			elapsed:    2 * time.Second,
+			batchTimes: []time.Time{start.Add(time.Second), start.Add(2 * time.Second)},
+			execStats:  map[string]int64{execTimeStat: int64(time.Second), compileTimeStat: int64(100 * time.Millisecond)},
+			numBytes:   10,
+		},
+		{
+			clusterID:  hung,
+			elapsed:    time.Minute,
+			timedOut:   true,
+			err:        errors.New("timed out"),
+			batchTimes: []time.Time{start.Add(500 * time.Millisecond)},
+		},
+	}
+
+	res := combineClusterResults(results, start)
+	// The timed out cluster's portion is recorded as the run's failure, without delaying the measurement.
+	require.Error(t, res.scriptErr)
+	assert.Equal(t, []uuid.UUID{hung}, res.timedOutClusters)
+	assert.Equal(t, 2*time.Second, res.externalExecTime)
+	assert.Equal(t, time.Second, res.timeToFirstRow)
+	assert.Equal(t, []time.Duration{time.Second}, res.batchGaps)
+}
+
+func TestCombineClusterResults_AllFinished(t *testing.T) {
+	start := time.Now()
+	first := uuid.Must(uuid.NewV4())
+	second := uuid.Must(uuid.NewV4())
+	results := []*clusterResult{
+		{
+			clusterID:  first,
+			elapsed:    2 * time.Second,
+			batchTimes: []time.Time{start.Add(2 * time.Second)},
+			numRows:    4,
+			queryID:    "query-1",
+			execStats:  map[string]int64{execTimeStat: int64(time.Second), compileTimeStat: int64(300 * time.Millisecond)},
+			numBytes:   10,
+		},
+		{
+			clusterID:  second,
+			elapsed:    3 * time.Second,
+			batchTimes: []time.Time{start.Add(time.Second)},
+			numRows:    6,
+			queryID:    "query-2",
+			execStats:  map[string]int64{execTimeStat: int64(2 * time.Second), compileTimeStat: int64(100 * time.Millisecond)},
+			numBytes:   5,
+		},
+	}
+
+	res := combineClusterResults(results, start)
+	require.NoError(t, res.scriptErr)
+	assert.Empty(t, res.timedOutClusters)
+	assert.Equal(t, 3*time.Second, res.externalExecTime)
+	assert.Equal(t, 2*time.Second, res.internalExecTime)
+	assert.Equal(t, 300*time.Millisecond, res.compileTime)
+	assert.Equal(t, 15, res.numBytes)
+	assert.Equal(t, time.Second, res.timeToFirstRow)
+	assert.Equal(t, 10, res.numRows)
+	assert.Equal(t, 2, res.numBatches)
+	assert.Equal(t, map[uuid.UUID]string{first: "query-1", second: "query-2"}, res.queryIDs)
+}
+
+func TestRecordClusterTimeouts_KeepsLastCluster(t *testing.T) {
+	ep := &benchmarkEndpoint{
+		data:            map[string]*ScriptExecData{"px/cluster": newScriptExecData("px/cluster")},
+		clusterTimeouts: make(map[uuid.UUID]int),
+	}
+	hung := uuid.Must(uuid.NewV4())
+
+	ep.recordClusterTimeouts(0, []uuid.UUID{hung}, 2)
+	ep.recordClusterTimeouts(3, []uuid.UUID{hung}, 2)
+	assert.Equal(t, 2, ep.clusterTimeouts[hung])
+	// Dropping the only cluster would leave nothing to measure.
+	assert.Empty(t, ep.data["px/cluster"].DroppedClusters)
+}
+
+func TestCombineClusterResults_QueueTime(t *testing.T) {
+	start := time.Now()
+	reporting := &clusterResult{
+		clusterID: uuid.Must(uuid.NewV4()),
+		execStats: map[string]int64{execTimeStat: int64(time.Second), queueTimeStat: int64(200 * time.Millisecond)},
+	}
+	res := combineClusterResults([]*clusterResult{reporting}, start)
+	assert.True(t, res.hasQueueTime)
+	assert.Equal(t, 200*time.Millisecond, res.queueTime)
+
+	// A cluster that doesn't report the queue time makes the run's queue time unknown.
+	silent := &clusterResult{
+		clusterID: uuid.Must(uuid.NewV4()),
+		execStats: map[string]int64{execTimeStat: int64(time.Second)},
+	}
+	res = combineClusterResults([]*clusterResult{reporting, silent}, start)
+	assert.False(t, res.hasQueueTime)
+}
+
+func TestCombineClusterResults_WireBytes(t *testing.T) {
+	start := time.Now()
+	res := combineClusterResults([]*clusterResult{
+		{clusterID: uuid.Must(uuid.NewV4()), numBytes: 1000, wireBytes: 200},
+		{clusterID: uuid.Must(uuid.NewV4()), numBytes: 500, wireBytes: 100},
+	}, start)
+	assert.Equal(t, 1500, res.numBytes)
+	assert.Equal(t, 300, res.wireBytes)
+
+	data := newScriptExecData("px/cluster")
+	recordResults(data, res)
+	assert.Equal(t, []int{300}, data.Distributions[wireBytesLabel].(*BytesDistribution).Bytes)
+	assert.Equal(t, []float64{5}, data.Distributions[compressionRatioLabel].(*RatioDistribution).Ratios)
+}
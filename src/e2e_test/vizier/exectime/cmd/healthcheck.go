@@ -0,0 +1,139 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+)
+
+const healthCheckLatencyLabel = "Health Check Latency"
+
+func init() {
+	HealthCheckCmd.PersistentFlags().Int("num_runs", 20, "number of times to health check each vizier")
+	HealthCheckCmd.PersistentFlags().StringP("cloud_addr", "a", "withpixie.ai:443", "The address of Pixie Cloud")
+	HealthCheckCmd.PersistentFlags().BoolP("all-clusters", "d", false, "Health check all clusters")
+	HealthCheckCmd.PersistentFlags().StringSliceP("cluster", "c", nil, "Health check only the selected clusters, by cluster ID, name or unique name prefix. Repeat, or separate with commas, to select several")
+	HealthCheckCmd.PersistentFlags().StringP("output", "o", "table", "Output format to use. Currently supports 'table', 'json' or 'csv'")
+	HealthCheckCmd.PersistentFlags().Duration("timeout", 5*time.Second, "How long each health check may take before it fails")
+	RootCmd.AddCommand(HealthCheckCmd)
+}
+
+func newHealthCheckData(name string) *ScriptExecData {
+	return &ScriptExecData{
+		Name: name,
+		Distributions: addFailureClassDistributions(distributionMap{
+			healthCheckLatencyLabel: &TimeDistribution{make([]time.Duration, 0)},
+			numErrorsLabel:          &ErrorDistribution{make([]error, 0)},
+		}),
+	}
+}
+
+// healthCheck sends one health check to the vizier, and returns how long it took to respond.
+func healthCheck(v *vizier.Connector, timeout time.Duration) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	err := v.HealthCheck(ctx)
+	return time.Since(start), err
+}
+
+func healthCheckCmd(cmd *cobra.Command) {
+	// Set the logger to use stderr so that json output can be consumed without log lines.
+	log.SetOutput(os.Stderr)
+
+	repeatCount, _ := cmd.Flags().GetInt("num_runs")
+	cloudAddr, _ := cmd.Flags().GetString("cloud_addr")
+	allClusters, _ := cmd.Flags().GetBool("all-clusters")
+	selectedClusters, _ := cmd.Flags().GetStringSlice("cluster")
+	outputFmt, _ := cmd.Flags().GetString("output")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+
+	if !allowedOutputFmts[outputFmt] {
+		log.WithField("output", outputFmt).Fatal("invalid output format")
+	}
+	if allClusters && len(selectedClusters) > 0 {
+		log.Fatal("--all-clusters can't be combined with --cluster")
+	}
+	clusters, err := validateClusters([]string{cloudAddr}, selectedClusters)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid --cluster")
+	}
+	conns, err := connectClusters(cloudAddr, allClusters, clusters[cloudAddr], nil)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to connect to vizier")
+	}
+
+	data := make(map[string]*ScriptExecData, len(conns))
+	for _, c := range conns {
+		data[c.ID().String()] = newHealthCheckData(c.ID().String())
+	}
+
+	// Each run goes through every vizier, so that a slow period in the cloud affects them all alike. Failures
+	// are recorded, rather than aborting, since a vizier with a broken control path is what we're looking for.
+	for i := 0; i < repeatCount; i++ {
+		for _, c := range conns {
+			d := data[c.ID().String()]
+			latency, err := healthCheck(c, timeout)
+			if err != nil {
+				log.WithError(err).WithField("cluster_id", c.ID()).Info("Health check failed")
+			} else {
+				d.Distributions[healthCheckLatencyLabel].Append(latency)
+			}
+			d.Distributions[numErrorsLabel].Append(recordFailure(d, err))
+		}
+	}
+
+	if outputFmt == "table" {
+		sortedData := sortByKeys(&data)
+		s := &stdoutTableWriter{}
+		if err := s.Write(&sortedData); err != nil {
+			log.WithError(err).Fatal("Failure on writing table")
+		}
+	}
+	if outputFmt == "csv" {
+		sortedData := sortByKeys(&data)
+		if err := (&csvResultsWriter{out: os.Stdout}).Write(sortedData); err != nil {
+			log.WithError(err).Fatal("Failed to write results to csv")
+		}
+	}
+	if outputFmt == "json" {
+		jsonData, err := json.Marshal(data)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to marshal results to json")
+		}
+		os.Stdout.Write(jsonData)
+	}
+}
+
+// HealthCheckCmd measures the latency of the health check through the cloud to each vizier.
+var HealthCheckCmd = &cobra.Command{
+	Use:   "healthcheck",
+	Short: "Run health check latency benchmarks",
+	Run: func(cmd *cobra.Command, args []string) {
+		healthCheckCmd(cmd)
+	},
+}
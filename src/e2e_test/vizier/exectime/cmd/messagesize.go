@@ -0,0 +1,58 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+)
+
+// defaultMaxGRPCMessageSize is the default limit on the size of the messages from the viziers. gRPC's own
+// default of 4MiB is smaller than the batches that some scripts with large result tables send.
+const defaultMaxGRPCMessageSize = 64 << 20
+
+// isMessageTooLarge returns whether err is gRPC refusing a message that is larger than the max message size.
+func isMessageTooLarge(err error) bool {
+	var s *status.Status
+	var scriptErr *vizier.ScriptExecutionError
+	if errors.As(err, &scriptErr) && scriptErr.RPCStatus() != nil {
+		s = scriptErr.RPCStatus()
+	} else if st, ok := status.FromError(err); ok {
+		s = st
+	}
+	return s != nil && s.Code() == codes.ResourceExhausted && strings.Contains(s.Message(), "larger than max")
+}
+
+// messageTooLargeHint names the flag that raises the limit a message exceeded, since gRPC's error only gives the
+// sizes.
+const messageTooLargeHint = "a message exceeded --max-grpc-message-size"
+
+// explainMessageTooLarge adds the hint to err, if it is a message that was too large.
+func explainMessageTooLarge(err error) error {
+	if err == nil || !isMessageTooLarge(err) {
+		return err
+	}
+	return fmt.Errorf("%s: %w", messageTooLargeHint, err)
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+)
+
+func TestExplainMessageTooLarge(t *testing.T) {
+	tooLarge := status.Error(codes.ResourceExhausted, "grpc: received message larger than max (5000000 vs. 4194304)")
+	err := streamError(context.Background(), t, &vizier.ExecData{Err: tooLarge})
+	require.True(t, isMessageTooLarge(err))
+	explained := explainMessageTooLarge(err)
+	assert.Contains(t, explained.Error(), messageTooLargeHint)
+	assert.Contains(t, explained.Error(), "(5000000 vs. 4194304)")
+	assert.ErrorIs(t, explained, err)
+	assert.Contains(t, newRunFailure(0, err).Details, messageTooLargeHint)
+
+	// Other errors, even with the same code, are left alone.
+	quota := status.Error(codes.ResourceExhausted, "quota exceeded")
+	assert.False(t, isMessageTooLarge(quota))
+	assert.Equal(t, quota, explainMessageTooLarge(quota))
+	other := errors.New("failed")
+	assert.Equal(t, other, explainMessageTooLarge(other))
+	assert.NoError(t, explainMessageTooLarge(nil))
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+	"px.dev/pixie/src/utils/script"
+)
+
+// The connection modes that --compare-modes runs the scripts through.
+const (
+	modePassthrough = "passthrough"
+	modeDirect      = "direct"
+)
+
+// passthroughOverheadLabel is the difference between the external exec times of matched passthrough and direct
+// runs.
+const passthroughOverheadLabel = "Passthrough Overhead"
+
+// getVizierID returns the ID of the Vizier that the connectors run scripts on, as the Vizier itself reports it.
+func getVizierID(v []*vizier.Connector) (uuid.UUID, error) {
+	pxl := `
+import px
+df = px.DataFrame(table='process_stats', start_time='-1m')
+df.vizier_id = px.vizier_id()
+df = df.groupby('vizier_id').agg()
+px.display(df, 'output')
+`
+	execScript := &script.ExecutableScript{
+		ScriptName:   "get_vizier_id",
+		ScriptString: pxl,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := vizier.RunScript(ctx, v, execScript, nil)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	tw := vizier.NewStreamOutputAdapter(ctx, resp, vizier.FormatInMemory, nil)
+	err = tw.Finish()
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	views, err := tw.Views()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	for _, table := range views {
+		if table.Name() != "output" || len(table.Data()) == 0 {
+			continue
+		}
+		return uuid.FromString(table.Data()[0][0].(string))
+	}
+	return uuid.Nil, errors.New("couldn't get the vizier ID from pxl script")
+}
+
+// connectDirectEndpoint connects directly to the Vizier that the passthrough endpoint runs its scripts on, to
+// run the same scripts through it. It fails if the direct connection reaches a different Vizier.
+func connectDirectEndpoint(passthrough *benchmarkEndpoint, directAddr, directKey string,
+	streamDuration, cancelAfter time.Duration, connOpts []vizier.ConnectorOption) (*benchmarkEndpoint, error) {
+	conn, err := vizier.NewConnector(passthrough.cloudAddr, nil, directAddr, directKey, connOpts...)
+	if err != nil {
+		return nil, err
+	}
+	directID, err := getVizierID([]*vizier.Connector{conn})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the ID of the direct vizier: %w", err)
+	}
+	passthroughID, err := getVizierID(passthrough.conns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the ID of the passthrough vizier: %w", err)
+	}
+	if directID != passthroughID {
+		return nil, fmt.Errorf("direct vizier %s is not the passthrough vizier %s", directID, passthroughID)
+	}
+
+	passthrough.mode = modePassthrough
+	direct := &benchmarkEndpoint{
+		cloudAddr:       passthrough.cloudAddr,
+		directAddr:      directAddr,
+		mode:            modeDirect,
+		conns:           []*vizier.Connector{conn},
+		scripts:         passthrough.scripts,
+		data:            make(map[string]*ScriptExecData),
+		clusterTimeouts: make(map[uuid.UUID]int),
+	}
+	for name, d := range passthrough.data {
+		direct.data[name] = &ScriptExecData{PromptedArgs: d.PromptedArgs}
+		direct.resetData(name, streamDuration, cancelAfter)
+	}
+	return direct, nil
+}
+
+// passthroughOverhead returns the overhead of running each script through the cloud, rather than directly,
+// computed from the passthrough and direct runs with the same index. Pairs where either run failed are
+// skipped, and scripts whose runs can't be matched up are left out.
+func passthroughOverhead(passthrough, direct *benchmarkEndpoint) map[string]*ScriptExecData {
+	overhead := make(map[string]*ScriptExecData)
+	for name, p := range passthrough.data {
+		d, ok := direct.data[name]
+		// The queries of mutation scripts only run once their tracepoints are ready, so their run indices
+		// don't line up.
+		if !ok || p.Mutation {
+			continue
+		}
+		pTimes, pOK := p.Distributions[execTimeExternalLabel].(*TimeDistribution)
+		dTimes, dOK := d.Distributions[execTimeExternalLabel].(*TimeDistribution)
+		if !pOK || !dOK || len(pTimes.Times) != len(dTimes.Times) {
+			continue
+		}
+		pErrs := p.Distributions[numErrorsLabel].(*ErrorDistribution).Errors
+		dErrs := d.Distributions[numErrorsLabel].(*ErrorDistribution).Errors
+		if len(pErrs) != len(pTimes.Times) || len(dErrs) != len(dTimes.Times) {
+			log.WithField("script", name).Warn("Runs can't be matched up, skipping passthrough overhead")
+			continue
+		}
+
+		dist := &TimeDistribution{make([]time.Duration, 0)}
+		for i := range pTimes.Times {
+			if pErrs[i] != nil || dErrs[i] != nil {
+				continue
+			}
+			dist.Append(pTimes.Times[i] - dTimes.Times[i])
+		}
+		overhead[name] = &ScriptExecData{
+			Name:          name,
+			Distributions: distributionMap{passthroughOverheadLabel: dist},
+		}
+	}
+	return overhead
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPassthroughOverhead(t *testing.T) {
+	passthrough := &benchmarkEndpoint{data: map[string]*ScriptExecData{"px/cluster": newScriptExecData("px/cluster")}}
+	direct := &benchmarkEndpoint{data: map[string]*ScriptExecData{"px/cluster": newScriptExecData("px/cluster")}}
+	runs := []struct {
+		passthrough time.Duration
+		direct      time.Duration
+		directErr   error
+	}{
+		{passthrough: 150 * time.Millisecond, direct: 100 * time.Millisecond},
+		// A pair where either run failed says nothing about the overhead.
+		{passthrough: 150 * time.Millisecond, direct: 5 * time.Second, directErr: errors.New("timed out")},
+		{passthrough: 130 * time.Millisecond, direct: 100 * time.Millisecond},
+	}
+	for _, r := range runs {
+		recordResults(passthrough.data["px/cluster"], &execResults{externalExecTime: r.passthrough})
+		recordResults(direct.data["px/cluster"], &execResults{externalExecTime: r.direct, scriptErr: r.directErr})
+	}
+
+	overhead := passthroughOverhead(passthrough, direct)
+	require.Contains(t, overhead, "px/cluster")
+	assert.Equal(t, []time.Duration{50 * time.Millisecond, 30 * time.Millisecond},
+		overhead["px/cluster"].Distributions[passthroughOverheadLabel].(*TimeDistribution).Times)
+}
+
+func TestPassthroughOverhead_SkipsUnmatchedRuns(t *testing.T) {
+	passthrough := &benchmarkEndpoint{data: map[string]*ScriptExecData{"px/cluster": newScriptExecData("px/cluster")}}
+	direct := &benchmarkEndpoint{data: map[string]*ScriptExecData{"px/cluster": newScriptExecData("px/cluster")}}
+	recordResults(passthrough.data["px/cluster"], &execResults{externalExecTime: time.Second})
+
+	assert.Empty(t, passthroughOverhead(passthrough, direct))
+}
@@ -0,0 +1,222 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+	"px.dev/pixie/src/utils/script"
+)
+
+const (
+	// The time from submitting the mutation until its tracepoints are ready, and the time to remove them.
+	mutationDeployLabel   = "Mutation Deploy Time"
+	mutationTeardownLabel = "Mutation Teardown Time"
+	// Runs whose tracepoints fail to deploy or to be removed are counted here, apart from the query failures.
+	mutationErrorsLabel = "Mutation Errors"
+)
+
+// mutationPollInterval is how long to wait before polling a mutation that is still pending.
+const mutationPollInterval = time.Second
+
+// mutationResults are the results of the phases of a run of a mutation script.
+type mutationResults struct {
+	deployTime time.Duration
+	deployed   bool
+	// The results of the query, which only runs once the tracepoints are ready.
+	query        *execResults
+	teardownTime time.Duration
+	tornDown     bool
+	mutationErr  error
+}
+
+func newMutationScriptExecData(name string) *ScriptExecData {
+	data := newScriptExecData(name)
+	data.Mutation = true
+	data.Distributions[mutationDeployLabel] = &TimeDistribution{make([]time.Duration, 0)}
+	data.Distributions[mutationTeardownLabel] = &TimeDistribution{make([]time.Duration, 0)}
+	data.Distributions[mutationErrorsLabel] = &ErrorDistribution{make([]error, 0)}
+	return data
+}
+
+// pollMutation runs the script once, and returns its last mutation info, along with when its mutations became
+// ready on every cluster. The query that follows the mutations is canceled once they are ready, rather than
+// waited for. The ready time is zero if the mutations are still pending.
+func pollMutation(v []*vizier.Connector, execScript *script.ExecutableScript, deadline time.Time) (*vizierpb.MutationInfo, time.Time, error) {
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	resp, err := vizier.RunScript(ctx, v, execScript, nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var lastInfo *vizierpb.MutationInfo
+	var readyAt time.Time
+	numReady := 0
+	onMutationInfo := func(mi *vizierpb.MutationInfo) {
+		lastInfo = mi
+		if mi.Status == nil || mi.Status.Code != int32(codes.OK) {
+			return
+		}
+		numReady++
+		if numReady == len(v) {
+			readyAt = time.Now()
+			cancel()
+		}
+	}
+	tw := vizier.NewStreamOutputAdapter(ctx, resp, vizier.FormatCountOnly, nil, vizier.WithMutationInfoCallback(onMutationInfo))
+	err = tw.Finish()
+	stopStream(cancel, resp)
+
+	switch {
+	case !readyAt.IsZero():
+		return lastInfo, readyAt, nil
+	case isPending(lastInfo):
+		return lastInfo, time.Time{}, nil
+	case err != nil:
+		return lastInfo, time.Time{}, err
+	default:
+		return lastInfo, time.Time{}, fmt.Errorf("'%s' returned no mutation info", execScript.ScriptName)
+	}
+}
+
+// isPending returns whether the mutation is still waiting for its tracepoints to deploy.
+func isPending(mi *vizierpb.MutationInfo) bool {
+	return mi != nil && mi.Status != nil && mi.Status.Code == int32(codes.Unavailable)
+}
+
+// awaitMutation polls the script until its mutations are ready, and returns when they became ready, along with
+// the number of polls it took. The last mutation info is returned even on failure, so that whatever was
+// deployed can be removed.
+func awaitMutation(v []*vizier.Connector, execScript *script.ExecutableScript, deadline time.Time) (*vizierpb.MutationInfo, time.Time, int, error) {
+	for polls := 1; ; polls++ {
+		mi, readyAt, err := pollMutation(v, execScript, deadline)
+		if err != nil {
+			return mi, time.Time{}, polls, err
+		}
+		if !readyAt.IsZero() {
+			return mi, readyAt, polls, nil
+		}
+		for _, s := range mi.States {
+			if s.State == vizierpb.FAILED_STATE {
+				return mi, time.Time{}, polls, fmt.Errorf("tracepoint '%s' failed to deploy", s.Name)
+			}
+		}
+		if time.Now().Add(mutationPollInterval).After(deadline) {
+			return mi, time.Time{}, polls, fmt.Errorf("tracepoints not ready after %d polls: %w", polls, context.DeadlineExceeded)
+		}
+		time.Sleep(mutationPollInterval)
+	}
+}
+
+// removeTracepointsScript returns a script that deletes the given tracepoints.
+func removeTracepointsScript(name string, states []*vizierpb.MutationInfo_MutationState) *script.ExecutableScript {
+	var sb strings.Builder
+	sb.WriteString("import pxtrace\n")
+	for _, s := range states {
+		sb.WriteString(fmt.Sprintf("pxtrace.DeleteTracepoint(%q)\n", s.Name))
+	}
+	return &script.ExecutableScript{
+		ScriptName:   name + " (teardown)",
+		ScriptString: sb.String(),
+	}
+}
+
+// executeMutationScript times the phases of a run of a mutation script: deploying its tracepoints, running its
+// query, and removing the tracepoints again. The tracepoints are removed in every run, even if they never
+// became ready, so that the next run deploys them from scratch rather than reusing them.
+func executeMutationScript(v []*vizier.Connector, execScript *script.ExecutableScript, timeout time.Duration,
+	deadline time.Duration, maxBytes int, format string, concurrentRuns int) (*mutationResults, error) {
+	collectGarbage(concurrentRuns)
+	res := &mutationResults{}
+	start := time.Now()
+	mi, readyAt, polls, err := awaitMutation(v, execScript, start.Add(deadline))
+	if err != nil {
+		log.WithError(err).Infof("Failed to deploy the tracepoints of '%s'", execScript.ScriptName)
+		res.mutationErr = err
+	} else {
+		if polls == 1 {
+			log.WithField("script", execScript.ScriptName).
+				Warn("Tracepoints were ready on the first poll, so they may not have been deployed from scratch")
+		}
+		res.deployTime = readyAt.Sub(start)
+		res.deployed = true
+		res.query, err = executeScript(v, execScript, timeout, nil, maxBytes, format, concurrentRuns)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if mi == nil || len(mi.States) == 0 {
+		return res, nil
+	}
+	teardownStart := time.Now()
+	_, _, _, err = awaitMutation(v, removeTracepointsScript(execScript.ScriptName, mi.States), teardownStart.Add(deadline))
+	if err != nil {
+		log.WithError(err).Infof("Failed to remove the tracepoints of '%s'", execScript.ScriptName)
+		if res.mutationErr == nil {
+			res.mutationErr = err
+		}
+		return res, nil
+	}
+	res.teardownTime = time.Since(teardownStart)
+	res.tornDown = true
+	return res, nil
+}
+
+// recordMutationResults appends the results of the phases of a mutation script run to the script's
+// distributions. The query's distributions only cover the runs whose tracepoints became ready.
+func recordMutationResults(data *ScriptExecData, res *mutationResults) {
+	dists := data.Distributions
+	var mutationErr error
+	if res.mutationErr != nil {
+		mutationErr = newRunFailure(len(dists[mutationErrorsLabel].(*ErrorDistribution).Errors), res.mutationErr)
+	}
+	dists[mutationErrorsLabel].Append(mutationErr)
+	if res.deployed {
+		dists[mutationDeployLabel].Append(res.deployTime)
+	}
+	if res.tornDown {
+		dists[mutationTeardownLabel].Append(res.teardownTime)
+	}
+	if res.query != nil {
+		recordResults(data, res.query)
+	}
+}
+
+// splitMutations splits the script data into the mutation scripts, and the other scripts.
+func splitMutations(data []*ScriptExecData) ([]*ScriptExecData, []*ScriptExecData) {
+	var others, mutations []*ScriptExecData
+	for _, d := range data {
+		if d.Mutation {
+			mutations = append(mutations, d)
+		} else {
+			others = append(others, d)
+		}
+	}
+	return others, mutations
+}
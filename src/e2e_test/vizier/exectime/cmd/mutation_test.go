@@ -0,0 +1,68 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+)
+
+func TestRemoveTracepointsScript(t *testing.T) {
+	s := removeTracepointsScript("px/http_probe", []*vizierpb.MutationInfo_MutationState{
+		{Name: "http_probe"},
+		{Name: "grpc_probe"},
+	})
+	assert.Equal(t, "px/http_probe (teardown)", s.ScriptName)
+	assert.Equal(t, "import pxtrace\npxtrace.DeleteTracepoint(\"http_probe\")\npxtrace.DeleteTracepoint(\"grpc_probe\")\n",
+		s.ScriptString)
+}
+
+func TestRecordMutationResults_SeparatesMutationFailures(t *testing.T) {
+	data := newMutationScriptExecData("px/http_probe")
+
+	recordMutationResults(data, &mutationResults{
+		deployTime:   10 * time.Second,
+		deployed:     true,
+		query:        &execResults{externalExecTime: time.Second},
+		teardownTime: 2 * time.Second,
+		tornDown:     true,
+	})
+	// The tracepoints never became ready, so the query didn't run.
+	recordMutationResults(data, &mutationResults{
+		mutationErr:  fmt.Errorf("tracepoints not ready after 3 polls: %w", context.DeadlineExceeded),
+		teardownTime: time.Second,
+		tornDown:     true,
+	})
+
+	dists := data.Distributions
+	assert.Equal(t, []time.Duration{10 * time.Second}, dists[mutationDeployLabel].(*TimeDistribution).Times)
+	assert.Equal(t, []time.Duration{2 * time.Second, time.Second}, dists[mutationTeardownLabel].(*TimeDistribution).Times)
+	assert.Equal(t, 1, dists[mutationErrorsLabel].(*ErrorDistribution).Num())
+	failure := dists[mutationErrorsLabel].(*ErrorDistribution).Errors[1].(*RunFailure)
+	assert.Equal(t, FailureClassTimeout, failure.Class)
+	// Only the query that ran is recorded, and the mutation failure isn't counted as a query failure.
+	assert.Equal(t, []time.Duration{time.Second}, dists[execTimeExternalLabel].(*TimeDistribution).Times)
+	assert.Equal(t, 0, dists[numErrorsLabel].(*ErrorDistribution).Num())
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// maxConsecutiveOversizedRuns is how many runs of a script in a row may exceed --max-bytes-per-run before the
+// script's remaining runs are skipped.
+const maxConsecutiveOversizedRuns = 2
+
+// byteCap cancels a run once the bytes that it received, across all of its clusters, exceed max. A nil byteCap
+// never cancels.
+type byteCap struct {
+	max    int64
+	cancel context.CancelFunc
+	// The bytes received so far, and whether they exceeded max. Updated atomically, since the clusters of a run
+	// stream concurrently.
+	bytes    int64
+	exceeded int32
+}
+
+// newByteCap returns a byteCap that calls cancel once more than maxBytes are received, or nil if maxBytes is 0.
+func newByteCap(maxBytes int, cancel context.CancelFunc) *byteCap {
+	if maxBytes <= 0 {
+		return nil
+	}
+	return &byteCap{max: int64(maxBytes), cancel: cancel}
+}
+
+// add counts n more bytes received by the run.
+func (c *byteCap) add(n int) {
+	if c == nil {
+		return
+	}
+	if atomic.AddInt64(&c.bytes, int64(n)) > c.max && atomic.CompareAndSwapInt32(&c.exceeded, 0, 1) {
+		c.cancel()
+	}
+}
+
+// oversizedBytes returns the bytes that the run had received by the time it was canceled, or 0 if it wasn't.
+// More may have arrived after the cancellation, from other clusters or messages already in flight.
+func (c *byteCap) oversizedBytes() int {
+	if c == nil || atomic.LoadInt32(&c.exceeded) == 0 {
+		return 0
+	}
+	return int(atomic.LoadInt64(&c.bytes))
+}
+
+// recordOversized records a run that was canceled for exceeding --max-bytes-per-run. It was cut short, so none of
+// its measurements are recorded.
+func recordOversized(data *ScriptExecData, res *execResults) {
+	data.OversizedRuns = append(data.OversizedRuns, res.oversizedBytes)
+	data.oversizedInARow++
+}
+
+// skipOversized returns whether the script's remaining runs should be skipped, because too many of its runs in a
+// row exceeded --max-bytes-per-run, and counts the skipped run.
+func skipOversized(data *ScriptExecData) bool {
+	if data.oversizedInARow < maxConsecutiveOversizedRuns {
+		return false
+	}
+	data.SkippedRuns++
+	return true
+}
+
+// writeOversizedRuns notes the scripts with runs that exceeded --max-bytes-per-run, under the endpoint's table.
+func writeOversizedRuns(ep *benchmarkEndpoint, maxBytes int) {
+	for _, d := range sortByKeys(&ep.data) {
+		if len(d.OversizedRuns) == 0 {
+			continue
+		}
+		largest := 0
+		for _, b := range d.OversizedRuns {
+			if b > largest {
+				largest = b
+			}
+		}
+		skipped := ""
+		if d.SkippedRuns > 0 {
+			skipped = fmt.Sprintf(", %d runs skipped", d.SkippedRuns)
+		}
+		fmt.Printf("Oversized runs of %s: %d runs exceeded %d bytes, reaching up to %d bytes%s\n",
+			d.Name, len(d.OversizedRuns), maxBytes, largest, skipped)
+	}
+}
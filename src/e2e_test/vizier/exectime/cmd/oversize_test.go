@@ -0,0 +1,67 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestByteCap(t *testing.T) {
+	var nilCap *byteCap
+	nilCap.add(100)
+	assert.Equal(t, 0, nilCap.oversizedBytes())
+	assert.Nil(t, newByteCap(0, func() {}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := newByteCap(100, cancel)
+	c.add(60)
+	c.add(40)
+	assert.NoError(t, ctx.Err())
+	assert.Equal(t, 0, c.oversizedBytes())
+
+	c.add(1)
+	assert.Error(t, ctx.Err())
+	assert.Equal(t, 101, c.oversizedBytes())
+}
+
+func TestRecordResults_Oversized(t *testing.T) {
+	d := newScriptExecData("px/http_data")
+	recordResults(d, &execResults{oversizedBytes: 2048})
+	assert.False(t, skipOversized(d))
+	// A run that finishes breaks the streak.
+	recordResults(d, &execResults{externalExecTime: time.Second})
+	recordResults(d, &execResults{oversizedBytes: 4096})
+	assert.False(t, skipOversized(d))
+	recordResults(d, &execResults{oversizedBytes: 8192})
+
+	assert.True(t, skipOversized(d))
+	assert.True(t, skipOversized(d))
+	assert.Equal(t, []int{2048, 4096, 8192}, d.OversizedRuns)
+	assert.Equal(t, 2, d.SkippedRuns)
+	// Only the run that finished is measured, but every run is counted.
+	assert.Len(t, d.Distributions[execTimeExternalLabel].(*TimeDistribution).Times, 1)
+	assert.Empty(t, d.Failures)
+	assert.Equal(t, 4, numRuns(d))
+	assert.True(t, scriptFailed(d))
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"sync"
+)
+
+// runInParallel calls run with each of the names, and its index, on up to parallelism goroutines at once.
+// Runs of the same name never overlap, and every name's runs start in the order that they are given, so that
+// the runs of a script stay sequential while the runs of different scripts overlap. No more runs are started
+// once quitting returns true. Returns once every started run has returned.
+func runInParallel(names []string, parallelism int, quitting func() bool, run func(i int, name string)) {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	var mu sync.Mutex
+	cond := sync.NewCond(&mu)
+	busy := make(map[string]bool)
+	started := make([]bool, len(names))
+	// Every name before next has started.
+	next := 0
+
+	// claim returns the index of the first run that can start, or -1 if every run has started. Waits while the
+	// runs that are left are all of busy names. Must be called with mu held.
+	claim := func() int {
+		for {
+			for next < len(names) && started[next] {
+				next++
+			}
+			if next == len(names) || quitting() {
+				return -1
+			}
+			for i := next; i < len(names); i++ {
+				if !started[i] && !busy[names[i]] {
+					started[i] = true
+					busy[names[i]] = true
+					return i
+				}
+			}
+			cond.Wait()
+		}
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				i := claim()
+				mu.Unlock()
+				if i < 0 {
+					return
+				}
+				run(i, names[i])
+
+				mu.Lock()
+				busy[names[i]] = false
+				cond.Broadcast()
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunInParallel(t *testing.T) {
+	names := []string{"px/a", "px/b", "px/a", "px/c", "px/a", "px/b", "px/d", "px/c"}
+
+	var mu sync.Mutex
+	running := make(map[string]int)
+	numRunning, maxRunning := 0, 0
+	order := make(map[string][]int)
+	runInParallel(names, 3, func() bool { return false }, func(i int, name string) {
+		mu.Lock()
+		running[name]++
+		assert.Equal(t, 1, running[name], "runs of %s overlap", name)
+		numRunning++
+		if numRunning > maxRunning {
+			maxRunning = numRunning
+		}
+		order[name] = append(order[name], i)
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running[name]--
+		numRunning--
+		mu.Unlock()
+	})
+
+	assert.Equal(t, map[string][]int{
+		"px/a": {0, 2, 4},
+		"px/b": {1, 5},
+		"px/c": {3, 7},
+		"px/d": {6},
+	}, order)
+	assert.LessOrEqual(t, maxRunning, 3)
+	assert.Greater(t, maxRunning, 1)
+}
+
+func TestRunInParallel_Sequential(t *testing.T) {
+	names := []string{"px/a", "px/b", "px/a"}
+	var ran []int
+	runInParallel(names, 1, func() bool { return false }, func(i int, name string) {
+		ran = append(ran, i)
+	})
+	assert.Equal(t, []int{0, 1, 2}, ran)
+}
+
+func TestRunInParallel_StopsWhenQuitting(t *testing.T) {
+	names := []string{"px/a", "px/b", "px/c", "px/d"}
+	var mu sync.Mutex
+	quit := false
+	numRuns := 0
+	runInParallel(names, 2, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return quit
+	}, func(i int, name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		numRuns++
+		quit = true
+	})
+	// Both workers may have started a run before the first one quit.
+	assert.LessOrEqual(t, numRuns, 2)
+	assert.GreaterOrEqual(t, numRuns, 1)
+}
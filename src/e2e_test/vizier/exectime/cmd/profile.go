@@ -0,0 +1,508 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+const (
+	// The number of equal-width buckets in the histogram of the profiled exec times.
+	profileHistogramBuckets = 10
+	// The width of the histogram's bar for the fullest bucket.
+	profileHistogramWidth = 40
+)
+
+func init() {
+	ProfileCmd.Flags().StringP("cloud_addr", "a", "withpixie.ai:443", "The address of Pixie Cloud")
+	ProfileCmd.Flags().StringP("bundle", "b", defaultBundleFile, "The bundle file to use")
+	ProfileCmd.Flags().BoolP("all-clusters", "d", false, "Run script across all clusters")
+	ProfileCmd.Flags().StringSliceP("cluster", "c", nil, "Run only on the selected clusters, by cluster ID, name or unique name prefix. Repeat, or separate with commas, to select several")
+	ProfileCmd.Flags().Bool("auto-args", false, "Discover a namespace with traffic, a busy service, and a long-lived pod and its node on the clusters, and use them for the namespace, service, pod and node variables")
+	ProfileCmd.Flags().Bool("interactive", false, "Prompt for the values of script variables that have no default")
+	ProfileCmd.Flags().Int("runs", 200, "The number of times to run the script. 0 runs until --duration is up")
+	ProfileCmd.Flags().Int("concurrency", 1, "The number of runs in flight at once")
+	ProfileCmd.Flags().Duration("duration", 0, "Stop starting runs after this long, even if fewer than --runs have run. 0 never stops early")
+	ProfileCmd.Flags().Duration("timeout", benchmarkScriptTimeout, "How long each run may take before it fails")
+	addBundleCacheFlags(ProfileCmd)
+	addConnectionFlags(ProfileCmd)
+	addAccumulationFlag(ProfileCmd)
+	addQuietFlag(ProfileCmd)
+	RootCmd.AddCommand(ProfileCmd)
+}
+
+// ProfileRun is everything measured in a single run of the profiled script. Each run is written as a line of
+// NDJSON as soon as it finishes.
+type ProfileRun struct {
+	Run              int
+	Start            time.Time
+	ExternalExecTime time.Duration
+	InternalExecTime time.Duration
+	CompileTime      time.Duration
+	// QueueTime is only set if every cluster reported it.
+	QueueTime *time.Duration `json:",omitempty"`
+	// TimeToFirstRow is only set if the run received any rows.
+	TimeToFirstRow *time.Duration  `json:",omitempty"`
+	BatchGaps      []time.Duration `json:",omitempty"`
+	NumBytes       int
+	WireBytes      int
+	NumRows        int
+	NumBatches     int
+	// QueryIDs are the IDs that each cluster gave the run's query, keyed by cluster ID.
+	QueryIDs         map[string]string `json:",omitempty"`
+	TimedOutClusters []string          `json:",omitempty"`
+	Failure          *RunFailure       `json:",omitempty"`
+}
+
+// newProfileRun returns the record of the given run. err is the error that kept the run from starting, if any,
+// in which case res is ignored.
+func newProfileRun(run int, start time.Time, res *execResults, err error) *ProfileRun {
+	r := &ProfileRun{Run: run, Start: start}
+	if err != nil {
+		r.Failure = newRunFailure(run, err)
+		return r
+	}
+	r.ExternalExecTime = res.externalExecTime
+	r.InternalExecTime = res.internalExecTime
+	r.CompileTime = res.compileTime
+	if res.hasQueueTime {
+		queueTime := res.queueTime
+		r.QueueTime = &queueTime
+	}
+	if res.receivedRows {
+		timeToFirstRow := res.timeToFirstRow
+		r.TimeToFirstRow = &timeToFirstRow
+	}
+	r.BatchGaps = res.batchGaps
+	r.NumBytes = res.numBytes
+	r.WireBytes = res.wireBytes
+	r.NumRows = res.numRows
+	r.NumBatches = res.numBatches
+	for clusterID, queryID := range res.queryIDs {
+		if r.QueryIDs == nil {
+			r.QueryIDs = make(map[string]string)
+		}
+		r.QueryIDs[clusterID.String()] = queryID
+	}
+	for _, clusterID := range res.timedOutClusters {
+		r.TimedOutClusters = append(r.TimedOutClusters, clusterID.String())
+	}
+	if res.scriptErr != nil {
+		r.Failure = newRunFailure(run, res.scriptErr)
+	}
+	return r
+}
+
+// profileRuns calls run with concurrency calls in flight at once, until numRuns calls have started, or until
+// duration has passed, whichever is first. A zero numRuns or duration is no limit, but one of them must be set.
+// Runs in flight when the duration passes are finished. Each run is passed to emit as it finishes, and all of
+// them are returned in the order they were started.
+func profileRuns(numRuns, concurrency int, duration time.Duration, run func(i int) *ProfileRun,
+	emit func(*ProfileRun)) []*ProfileRun {
+	var deadline time.Time
+	if duration > 0 {
+		deadline = time.Now().Add(duration)
+	}
+	var mu sync.Mutex
+	var runs []*ProfileRun
+	next := 0
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				if (numRuns > 0 && next >= numRuns) || (!deadline.IsZero() && !time.Now().Before(deadline)) {
+					mu.Unlock()
+					return
+				}
+				i := next
+				next++
+				mu.Unlock()
+
+				r := run(i)
+				// The runs are emitted one at a time, so that their lines don't interleave.
+				mu.Lock()
+				runs = append(runs, r)
+				emit(r)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Run < runs[j].Run })
+	return runs
+}
+
+// percentile returns the pth percentile (nearest-rank) of the sorted durations, which must not be empty.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// profileStats are the order statistics and the moments of one of the measurements of the profiled runs.
+type profileStats struct {
+	label                                      string
+	min, p50, p90, p95, p99, max, mean, stddev time.Duration
+}
+
+// newProfileStats returns the stats of the given measurements, or nil if there are none.
+func newProfileStats(label string, values []time.Duration) *profileStats {
+	if len(values) == 0 {
+		return nil
+	}
+	sorted := append([]time.Duration(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	dist := &TimeDistribution{sorted}
+	return &profileStats{
+		label:  label,
+		min:    sorted[0],
+		p50:    percentile(sorted, 0.5),
+		p90:    percentile(sorted, 0.9),
+		p95:    percentile(sorted, 0.95),
+		p99:    percentile(sorted, 0.99),
+		max:    sorted[len(sorted)-1],
+		mean:   dist.Mean(),
+		stddev: dist.Stddev(),
+	}
+}
+
+// histogramBucket counts the measurements in [lo, hi), or in [lo, hi] for the last bucket.
+type histogramBucket struct {
+	lo, hi time.Duration
+	count  int
+}
+
+// profileHistogram splits the range of the measurements, which must not be empty, into numBuckets
+// equal-width buckets, and counts the measurements in each of them.
+func profileHistogram(values []time.Duration, numBuckets int) []*histogramBucket {
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		if v < lo {
+			lo = v
+		}
+		if v > hi {
+			hi = v
+		}
+	}
+	// Identical measurements all go in a single bucket.
+	if lo == hi {
+		return []*histogramBucket{{lo: lo, hi: hi, count: len(values)}}
+	}
+	width := (hi - lo + time.Duration(numBuckets) - 1) / time.Duration(numBuckets)
+	buckets := make([]*histogramBucket, numBuckets)
+	for i := range buckets {
+		buckets[i] = &histogramBucket{lo: lo + time.Duration(i)*width, hi: lo + time.Duration(i+1)*width}
+	}
+	for _, v := range values {
+		i := int((v - lo) / width)
+		if i >= numBuckets {
+			i = numBuckets - 1
+		}
+		buckets[i].count++
+	}
+	return buckets
+}
+
+// profileSummary is the summary of the distribution of the profiled runs that is printed once they are over.
+type profileSummary struct {
+	script      string
+	concurrency int
+	wallTime    time.Duration
+	runs        int
+	// The number of runs that failed, by failure class.
+	failures map[FailureClass]int
+	stats    []*profileStats
+	// The histogram of the external exec times of the successful runs.
+	histogram []*histogramBucket
+	// Outliers are the successful runs whose external exec time is above the fence, at 1.5 times the
+	// interquartile range above the third quartile.
+	fence    time.Duration
+	outliers []*ProfileRun
+	// The mean bytes and rows that the successful runs received.
+	meanBytes float64
+	meanRows  float64
+}
+
+// newProfileSummary summarizes the given runs. Only the successful runs are considered for the distribution,
+// since a failed run's time measures its failure.
+func newProfileSummary(script string, concurrency int, runs []*ProfileRun, wallTime time.Duration) *profileSummary {
+	s := &profileSummary{
+		script:      script,
+		concurrency: concurrency,
+		wallTime:    wallTime,
+		runs:        len(runs),
+		failures:    make(map[FailureClass]int),
+	}
+	var external, internal, compile, queue, firstRow, maxGap []time.Duration
+	var ok []*ProfileRun
+	for _, r := range runs {
+		if r.Failure != nil {
+			s.failures[r.Failure.Class]++
+			continue
+		}
+		ok = append(ok, r)
+		external = append(external, r.ExternalExecTime)
+		internal = append(internal, r.InternalExecTime)
+		compile = append(compile, r.CompileTime)
+		if r.QueueTime != nil {
+			queue = append(queue, *r.QueueTime)
+		}
+		if r.TimeToFirstRow != nil {
+			firstRow = append(firstRow, *r.TimeToFirstRow)
+		}
+		if len(r.BatchGaps) > 0 {
+			maxGap = append(maxGap, maxDuration(r.BatchGaps))
+		}
+		s.meanBytes += float64(r.NumBytes)
+		s.meanRows += float64(r.NumRows)
+	}
+	if len(ok) == 0 {
+		return s
+	}
+	s.meanBytes /= float64(len(ok))
+	s.meanRows /= float64(len(ok))
+
+	for _, st := range []*profileStats{
+		newProfileStats(execTimeExternalLabel, external),
+		newProfileStats(execTimeInternalLabel, internal),
+		newProfileStats(compTimeLabel, compile),
+		newProfileStats(queueTimeLabel, queue),
+		newProfileStats(timeToFirstRowLabel, firstRow),
+		newProfileStats(maxBatchGapLabel, maxGap),
+	} {
+		if st != nil {
+			s.stats = append(s.stats, st)
+		}
+	}
+	s.histogram = profileHistogram(external, profileHistogramBuckets)
+
+	sorted := append([]time.Duration(nil), external...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	q1, q3 := percentile(sorted, 0.25), percentile(sorted, 0.75)
+	s.fence = q3 + 3*(q3-q1)/2
+	for _, r := range ok {
+		if r.ExternalExecTime > s.fence {
+			s.outliers = append(s.outliers, r)
+		}
+	}
+	return s
+}
+
+func maxDuration(durations []time.Duration) time.Duration {
+	max := durations[0]
+	for _, d := range durations[1:] {
+		if d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+func (s *profileSummary) write(w io.Writer) {
+	numFailed := 0
+	classes := make([]string, 0, len(s.failures))
+	for class, n := range s.failures {
+		numFailed += n
+		classes = append(classes, fmt.Sprintf("%s: %d", class, n))
+	}
+	sort.Strings(classes)
+	fmt.Fprintf(w, "Profiled %s: %d runs in %v, %d at a time\n", s.script, s.runs, s.wallTime.Round(time.Second), s.concurrency)
+	if numFailed > 0 {
+		fmt.Fprintf(w, "Failed runs: %d (%s)\n", numFailed, strings.Join(classes, ", "))
+	}
+	if len(s.stats) == 0 {
+		fmt.Fprintln(w, "No successful runs to summarize")
+		return
+	}
+	fmt.Fprintf(w, "Received per run: %.1fkB in %.0f rows on average\n", s.meanBytes/1024, s.meanRows)
+
+	round := func(d time.Duration) string {
+		return d.Round(10 * time.Microsecond).String()
+	}
+	table := tablewriter.NewWriter(w)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Measurement", "Min", "P50", "P90", "P95", "P99", "Max", "Mean", "Stddev"})
+	for _, st := range s.stats {
+		table.Append([]string{st.label, round(st.min), round(st.p50), round(st.p90), round(st.p95), round(st.p99),
+			round(st.max), round(st.mean), round(st.stddev)})
+	}
+	table.Render()
+
+	fmt.Fprintf(w, "%s histogram:\n", execTimeExternalLabel)
+	fullest := 0
+	for _, b := range s.histogram {
+		if b.count > fullest {
+			fullest = b.count
+		}
+	}
+	for _, b := range s.histogram {
+		bar := strings.Repeat("#", b.count*profileHistogramWidth/fullest)
+		fmt.Fprintf(w, "  %10s - %-10s %-*s %d\n", round(b.lo), round(b.hi), profileHistogramWidth, bar, b.count)
+	}
+
+	if len(s.outliers) == 0 {
+		fmt.Fprintf(w, "No outliers above %s\n", round(s.fence))
+		return
+	}
+	fmt.Fprintf(w, "Outliers above %s:\n", round(s.fence))
+	for _, r := range s.outliers {
+		queryIDs := make([]string, 0, len(r.QueryIDs))
+		for _, queryID := range r.QueryIDs {
+			queryIDs = append(queryIDs, queryID)
+		}
+		sort.Strings(queryIDs)
+		fmt.Fprintf(w, "  run %d at %s: %s", r.Run, r.Start.Format(time.RFC3339), round(r.ExternalExecTime))
+		if len(queryIDs) > 0 {
+			fmt.Fprintf(w, " (query %s)", strings.Join(queryIDs, ", "))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func profileCmd(cmd *cobra.Command, name string) {
+	start := time.Now()
+	// The runs are written to stdout, so the log goes to stderr.
+	log.SetOutput(os.Stderr)
+
+	cloudAddr, _ := cmd.Flags().GetString("cloud_addr")
+	bundleFile, _ := cmd.Flags().GetString("bundle")
+	allClusters, _ := cmd.Flags().GetBool("all-clusters")
+	selectedClusters, _ := cmd.Flags().GetStringSlice("cluster")
+	autoArgs, _ := cmd.Flags().GetBool("auto-args")
+	interactive, _ := cmd.Flags().GetBool("interactive")
+	numRuns, _ := cmd.Flags().GetInt("runs")
+	concurrency, _ := cmd.Flags().GetInt("concurrency")
+	duration, _ := cmd.Flags().GetDuration("duration")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	quiet, _ := cmd.Flags().GetBool("quiet")
+	accumulation, err := accumulationFormatFromFlags(cmd)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid --accumulation")
+	}
+
+	if numRuns < 0 || duration < 0 {
+		log.Fatal("--runs and --duration can't be negative")
+	}
+	if numRuns == 0 && duration == 0 {
+		log.Fatal("--runs 0 requires --duration, to bound the runs")
+	}
+	if concurrency < 1 {
+		log.Fatal("--concurrency must be at least 1")
+	}
+	if allClusters && len(selectedClusters) > 0 {
+		log.Fatal("--all-clusters can't be combined with --cluster")
+	}
+	connOpts, err := connectorOptions(cmd)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid connection flags")
+	}
+	clusters, err := validateClusters([]string{cloudAddr}, selectedClusters)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid --cluster")
+	}
+	var prompter *argPrompter
+	if interactive {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			log.Fatal("--interactive requires stdin to be a terminal")
+		}
+		prompter = newArgPrompter(os.Stdin, os.Stderr)
+	}
+
+	br, _, err := createBundleReader(bundleFile, bundleFetchOptionsFromFlags(cmd))
+	if err != nil {
+		log.WithError(err).Fatal("Failed to read script bundle")
+	}
+	found := false
+	for _, s := range br.GetScripts() {
+		if s.ScriptName != name {
+			continue
+		}
+		found = true
+		if isMutation(s) || disallowedScripts[name] {
+			log.WithField("script", name).Fatal("Script can't be profiled, since it deploys tracepoints or is never benchmarked")
+		}
+	}
+	if !found {
+		log.WithField("script", name).Fatal("Script is not in the bundle")
+	}
+
+	ep := connectEndpoint(cloudAddr, allClusters, clusters[cloudAddr], br.GetScripts(), map[string]bool{name: true},
+		false, false, autoArgs, prompter, connOpts)
+	s, ok := ep.scripts[name]
+	if !ok {
+		log.WithField("script", name).Fatalf("Script can't be profiled: %s", ep.autoArgs.SkippedScripts[name])
+	}
+
+	log.Infof("Profiling '%s' %d at a time", name, concurrency)
+	if concurrency > 1 {
+		log.Warn("Garbage collection isn't forced between concurrent runs, so it may land in their timings")
+	}
+	enc := json.NewEncoder(os.Stdout)
+	runs := profileRuns(numRuns, concurrency, duration, func(i int) *ProfileRun {
+		runStart := time.Now()
+		res, err := executeScript(ep.conns, s, timeout, nil, 0, accumulation, concurrency)
+		return newProfileRun(i, runStart, res, err)
+	}, func(r *ProfileRun) {
+		if err := enc.Encode(r); err != nil {
+			log.WithError(err).Fatal("Failed to write the run")
+		}
+	})
+
+	summary := newProfileSummary(name, concurrency, runs, time.Since(start))
+	if !quiet {
+		summary.write(os.Stderr)
+	}
+	if len(summary.stats) == 0 {
+		os.Exit(1)
+	}
+}
+
+// ProfileCmd runs a single script many times, and records everything that is measured in each run.
+var ProfileCmd = &cobra.Command{
+	Use:   "profile <script>",
+	Short: "Run a single script many times, and write every measurement of every run",
+	Args:  cobra.ExactArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completeScriptNames(cmd, args, toComplete)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		profileCmd(cmd, args[0])
+	},
+}
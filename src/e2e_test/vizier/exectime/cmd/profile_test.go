@@ -0,0 +1,136 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileRuns(t *testing.T) {
+	var inFlight, maxInFlight int32
+	var emitted []int
+	runs := profileRuns(20, 4, 0, func(i int) *ProfileRun {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return &ProfileRun{Run: i}
+	}, func(r *ProfileRun) {
+		emitted = append(emitted, r.Run)
+	})
+
+	require.Len(t, runs, 20)
+	assert.Len(t, emitted, 20)
+	for i, r := range runs {
+		assert.Equal(t, i, r.Run)
+	}
+	assert.LessOrEqual(t, maxInFlight, int32(4))
+}
+
+func TestProfileRuns_Duration(t *testing.T) {
+	runs := profileRuns(0, 1, 20*time.Millisecond, func(i int) *ProfileRun {
+		time.Sleep(5 * time.Millisecond)
+		return &ProfileRun{Run: i}
+	}, func(*ProfileRun) {})
+	// The run in flight at the deadline is finished, so a few runs fit either way.
+	assert.NotEmpty(t, runs)
+	assert.LessOrEqual(t, len(runs), 5)
+}
+
+func TestNewProfileRun(t *testing.T) {
+	clusterID := uuid.Must(uuid.NewV4())
+	start := time.Now()
+	r := newProfileRun(3, start, &execResults{
+		externalExecTime: 30 * time.Millisecond,
+		timeToFirstRow:   10 * time.Millisecond,
+		receivedRows:     true,
+		numRows:          5,
+		numBatches:       2,
+		batchGaps:        []time.Duration{time.Millisecond},
+		queryIDs:         map[uuid.UUID]string{clusterID: "query-1"},
+	}, nil)
+	assert.Equal(t, 3, r.Run)
+	assert.Equal(t, 30*time.Millisecond, r.ExternalExecTime)
+	require.NotNil(t, r.TimeToFirstRow)
+	assert.Equal(t, 10*time.Millisecond, *r.TimeToFirstRow)
+	assert.Nil(t, r.QueueTime)
+	assert.Equal(t, map[string]string{clusterID.String(): "query-1"}, r.QueryIDs)
+	assert.Nil(t, r.Failure)
+
+	// A run that failed to start has only its failure.
+	r = newProfileRun(4, start, nil, errors.New("connection refused"))
+	require.NotNil(t, r.Failure)
+	assert.Equal(t, 4, r.Failure.Run)
+}
+
+func TestProfileHistogram(t *testing.T) {
+	buckets := profileHistogram([]time.Duration{0, 1, 2, 9, 10}, 5)
+	require.Len(t, buckets, 5)
+	counts := make([]int, len(buckets))
+	for i, b := range buckets {
+		counts[i] = b.count
+	}
+	// The largest value goes in the last bucket.
+	assert.Equal(t, []int{2, 1, 0, 0, 2}, counts)
+
+	buckets = profileHistogram([]time.Duration{5, 5}, 5)
+	require.Len(t, buckets, 1)
+	assert.Equal(t, 2, buckets[0].count)
+}
+
+func TestNewProfileSummary(t *testing.T) {
+	var runs []*ProfileRun
+	for i := 0; i < 20; i++ {
+		runs = append(runs, &ProfileRun{Run: i, ExternalExecTime: time.Duration(10+i%3) * time.Millisecond})
+	}
+	runs = append(runs, &ProfileRun{Run: 20, ExternalExecTime: 100 * time.Millisecond, QueryIDs: map[string]string{"c": "slow-query"}})
+	runs = append(runs, &ProfileRun{Run: 21, Failure: &RunFailure{Class: FailureClassTimeout}})
+
+	s := newProfileSummary("px/cluster", 2, runs, time.Second)
+	assert.Equal(t, 22, s.runs)
+	assert.Equal(t, map[FailureClass]int{FailureClassTimeout: 1}, s.failures)
+	require.NotEmpty(t, s.stats)
+	external := s.stats[0]
+	assert.Equal(t, execTimeExternalLabel, external.label)
+	assert.Equal(t, 10*time.Millisecond, external.min)
+	assert.Equal(t, 11*time.Millisecond, external.p50)
+	assert.Equal(t, 100*time.Millisecond, external.max)
+	require.Len(t, s.outliers, 1)
+	assert.Equal(t, 20, s.outliers[0].Run)
+
+	var buf bytes.Buffer
+	s.write(&buf)
+	assert.Contains(t, buf.String(), "Profiled px/cluster: 22 runs")
+	assert.Contains(t, buf.String(), "Failed runs: 1 (Timeout: 1)")
+	assert.Contains(t, buf.String(), "run 20")
+	assert.Contains(t, buf.String(), "slow-query")
+}
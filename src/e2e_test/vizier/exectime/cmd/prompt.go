@@ -0,0 +1,113 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"px.dev/pixie/src/api/proto/vispb"
+	"px.dev/pixie/src/utils/script"
+)
+
+// argPrompter asks for the values of script variables that have no default, no valid values to pick from,
+// and no value from the Vizier. Each variable is asked for once, and its answer is reused by every script
+// that has a variable with the same name.
+type argPrompter struct {
+	in  *bufio.Scanner
+	out io.Writer
+	// The answers given, keyed by variable name.
+	answers map[string]string
+}
+
+func newArgPrompter(in io.Reader, out io.Writer) *argPrompter {
+	return &argPrompter{
+		in:      bufio.NewScanner(in),
+		out:     out,
+		answers: make(map[string]string),
+	}
+}
+
+// validateArg returns an error if the value can't be parsed as the variable's type.
+func validateArg(t vispb.PXType, value string) error {
+	var err error
+	switch t {
+	case vispb.PX_BOOLEAN:
+		_, err = strconv.ParseBool(value)
+	case vispb.PX_INT64:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case vispb.PX_FLOAT64:
+		_, err = strconv.ParseFloat(value, 64)
+	default:
+		if value == "" {
+			err = errors.New("a value is required")
+		}
+	}
+	return err
+}
+
+// prompt asks for the value of the variable until a valid one is given.
+func (p *argPrompter) prompt(scriptName string, v *vispb.Vis_Variable) (string, error) {
+	if answer, ok := p.answers[v.Name]; ok {
+		return answer, nil
+	}
+	fmt.Fprintf(p.out, "%s: %s (%s)\n", scriptName, v.Name, strings.TrimPrefix(v.Type.String(), "PX_"))
+	if v.Description != "" {
+		fmt.Fprintf(p.out, "  %s\n", v.Description)
+	}
+	for {
+		fmt.Fprintf(p.out, "%s: ", v.Name)
+		if !p.in.Scan() {
+			if err := p.in.Err(); err != nil {
+				return "", err
+			}
+			return "", io.ErrUnexpectedEOF
+		}
+		value := strings.TrimSpace(p.in.Text())
+		if err := validateArg(v.Type, value); err != nil {
+			fmt.Fprintf(p.out, "Invalid value for %s: %v\n", v.Name, err)
+			continue
+		}
+		p.answers[v.Name] = value
+		return value, nil
+	}
+}
+
+// answersFor returns the answers given for the script's variables.
+func (p *argPrompter) answersFor(s *script.ExecutableScript) map[string]string {
+	if p == nil || s.Vis == nil {
+		return nil
+	}
+	var answers map[string]string
+	for _, v := range s.Vis.Variables {
+		answer, ok := p.answers[v.Name]
+		if !ok {
+			continue
+		}
+		if answers == nil {
+			answers = make(map[string]string)
+		}
+		answers[v.Name] = answer
+	}
+	return answers
+}
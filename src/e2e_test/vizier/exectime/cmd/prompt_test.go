@@ -0,0 +1,57 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/api/proto/vispb"
+	"px.dev/pixie/src/utils/script"
+)
+
+func TestArgPrompter(t *testing.T) {
+	limit := &vispb.Vis_Variable{Name: "limit", Type: vispb.PX_INT64, Description: "The number of rows"}
+	var out bytes.Buffer
+	p := newArgPrompter(strings.NewReader("ten\n10\n"), &out)
+
+	value, err := p.prompt("px/test", limit)
+	require.NoError(t, err)
+	assert.Equal(t, "10", value)
+	assert.Contains(t, out.String(), "px/test: limit (INT64)")
+	assert.Contains(t, out.String(), "The number of rows")
+	assert.Contains(t, out.String(), "Invalid value for limit")
+
+	// Another script with the same variable gets the same answer, without asking again.
+	value, err = p.prompt("px/other", limit)
+	require.NoError(t, err)
+	assert.Equal(t, "10", value)
+
+	s := &script.ExecutableScript{Vis: &vispb.Vis{Variables: []*vispb.Vis_Variable{limit, {Name: "start_time"}}}}
+	assert.Equal(t, map[string]string{"limit": "10"}, p.answersFor(s))
+
+	// Running out of input fails, rather than using an empty value.
+	_, err = p.prompt("px/test", &vispb.Vis_Variable{Name: "pod", Type: vispb.PX_POD})
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+)
+
+// responseRecorder keeps the raw messages of a run, up to maxBytes of them, so that the partial results of a
+// failed run can be saved once the run is over. The messages are only decoded when they are saved, so that
+// recording them costs the measured run as little as possible.
+type responseRecorder struct {
+	maxBytes int
+
+	mu   sync.Mutex
+	size int
+	msgs []*vizier.ExecData
+	// The number of messages that didn't fit under maxBytes.
+	dropped int
+}
+
+func newResponseRecorder(maxBytes int) *responseRecorder {
+	return &responseRecorder{maxBytes: maxBytes}
+}
+
+// record is a vizier.MessageCallback. It is shared by the streams of every cluster of the run.
+func (r *responseRecorder) record(msg *vizier.ExecData) {
+	if msg.Resp == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	size := msg.Resp.Size()
+	if r.size+size > r.maxBytes {
+		r.dropped++
+		return
+	}
+	r.size += size
+	r.msgs = append(r.msgs, msg)
+}
+
+// savedTable is a table of the partial results of a failed run, as written to tables.json.
+type savedTable struct {
+	ClusterID string
+	Name      string
+	Header    []string
+	Rows      [][]interface{}
+}
+
+// savedFailure is the error of a failed run, as written to error.json.
+type savedFailure struct {
+	Failure *RunFailure
+	// Error is the full error of the run, which Failure may have truncated.
+	Error string
+	// The size of the recorded messages, and the number that were dropped for going over the cap.
+	RecordedBytes    int
+	DroppedResponses int `json:",omitempty"`
+	// ReplayErrors are the errors from decoding the recorded messages into tables, by cluster.
+	ReplayErrors map[string]string `json:",omitempty"`
+}
+
+// replayTables decodes the recorded messages of each cluster into tables. Messages that carry an error are
+// skipped, so that the data received before the error is kept.
+func (r *responseRecorder) replayTables() ([]savedTable, map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var clusters []string
+	byCluster := make(map[string][]*vizier.ExecData)
+	for _, msg := range r.msgs {
+		if msg.Err != nil || msg.Resp.Result == nil || (msg.Resp.Status != nil && msg.Resp.Status.Code != 0) {
+			continue
+		}
+		id := msg.ClusterID.String()
+		if _, ok := byCluster[id]; !ok {
+			clusters = append(clusters, id)
+		}
+		byCluster[id] = append(byCluster[id], msg)
+	}
+
+	tables := make([]savedTable, 0)
+	var errs map[string]string
+	for _, id := range clusters {
+		msgs := byCluster[id]
+		stream := make(chan *vizier.ExecData, len(msgs))
+		for _, msg := range msgs {
+			stream <- msg
+		}
+		close(stream)
+		tw := vizier.NewStreamOutputAdapter(context.Background(), stream, vizier.FormatInMemory, nil)
+		err := tw.Finish()
+		if err == nil {
+			views, viewsErr := tw.Views()
+			for _, v := range views {
+				tables = append(tables, savedTable{ClusterID: id, Name: v.Name(), Header: v.Header(), Rows: v.Data()})
+			}
+			err = viewsErr
+		}
+		if err != nil {
+			if errs == nil {
+				errs = make(map[string]string)
+			}
+			errs[id] = err.Error()
+		}
+	}
+	return tables, errs
+}
+
+// failureDirName makes the given name safe to use as a single directory name.
+func failureDirName(name string) string {
+	return strings.NewReplacer("/", "_", string(filepath.Separator), "_", ":", "_").Replace(name)
+}
+
+// failureDir returns the directory under dir that the given run of the script is saved to.
+func failureDir(dir string, scriptName string, run int) string {
+	return filepath.Join(dir, fmt.Sprintf("%s__run%d", failureDirName(scriptName), run))
+}
+
+// saveFailedRun writes the partial tables and the error of a failed run to runDir, as tables.json and
+// error.json.
+func saveFailedRun(runDir string, failure *RunFailure, runErr error, rec *responseRecorder) error {
+	if err := os.MkdirAll(runDir, 0755); err != nil {
+		return err
+	}
+	tables, replayErrs := rec.replayTables()
+	rec.mu.Lock()
+	saved := &savedFailure{
+		Failure:          failure,
+		Error:            runErr.Error(),
+		RecordedBytes:    rec.size,
+		DroppedResponses: rec.dropped,
+		ReplayErrors:     replayErrs,
+	}
+	rec.mu.Unlock()
+	if err := writeJSONFile(filepath.Join(runDir, "tables.json"), tables); err != nil {
+		return err
+	}
+	return writeJSONFile(filepath.Join(runDir, "error.json"), saved)
+}
+
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+)
+
+func partialRunMessages(clusterID uuid.UUID) []*vizier.ExecData {
+	return []*vizier.ExecData{
+		{ClusterID: clusterID, Resp: &vizierpb.ExecuteScriptResponse{
+			Result: &vizierpb.ExecuteScriptResponse_MetaData{MetaData: &vizierpb.QueryMetadata{
+				Name: "output",
+				ID:   "table-1",
+				Relation: &vizierpb.Relation{Columns: []*vizierpb.Relation_ColumnInfo{
+					{ColumnName: "count", ColumnType: vizierpb.INT64},
+				}},
+			}},
+		}},
+		{ClusterID: clusterID, Resp: &vizierpb.ExecuteScriptResponse{
+			Result: &vizierpb.ExecuteScriptResponse_Data{Data: &vizierpb.QueryData{
+				Batch: &vizierpb.RowBatchData{
+					TableID: "table-1",
+					Cols: []*vizierpb.Column{
+						{ColData: &vizierpb.Column_Int64Data{Int64Data: &vizierpb.Int64Column{Data: []int64{1, 2}}}},
+					},
+				},
+			}},
+		}},
+		{ClusterID: clusterID, Resp: &vizierpb.ExecuteScriptResponse{
+			Status: &vizierpb.Status{Code: 13, Message: "kelvin went away"},
+		}},
+	}
+}
+
+func TestSaveFailedRun_WritesPartialTablesAndError(t *testing.T) {
+	clusterID := uuid.Must(uuid.NewV4())
+	rec := newResponseRecorder(1 << 20)
+	for _, msg := range partialRunMessages(clusterID) {
+		rec.record(msg)
+	}
+	runErr := errors.New("kelvin went away")
+	runDir := failureDir(t.TempDir(), "px/http_data", 3)
+	assert.Equal(t, "px_http_data__run3", filepath.Base(runDir))
+	require.NoError(t, saveFailedRun(runDir, newRunFailure(3, runErr), runErr, rec))
+
+	var tables []savedTable
+	readJSONFile(t, filepath.Join(runDir, "tables.json"), &tables)
+	require.Len(t, tables, 1)
+	assert.Equal(t, clusterID.String(), tables[0].ClusterID)
+	assert.Equal(t, "output", tables[0].Name)
+	assert.Equal(t, []string{"count"}, tables[0].Header)
+	assert.Len(t, tables[0].Rows, 2)
+
+	var saved savedFailure
+	readJSONFile(t, filepath.Join(runDir, "error.json"), &saved)
+	assert.Equal(t, 3, saved.Failure.Run)
+	assert.Equal(t, "kelvin went away", saved.Error)
+	assert.Zero(t, saved.DroppedResponses)
+	assert.Empty(t, saved.ReplayErrors)
+}
+
+func TestResponseRecorder_DropsMessagesOverTheCap(t *testing.T) {
+	msgs := partialRunMessages(uuid.Must(uuid.NewV4()))
+	rec := newResponseRecorder(msgs[0].Resp.Size())
+	for _, msg := range msgs {
+		rec.record(msg)
+	}
+	assert.Equal(t, msgs[:1], rec.msgs)
+	assert.Equal(t, 2, rec.dropped)
+	assert.Equal(t, msgs[0].Resp.Size(), rec.size)
+}
+
+func readJSONFile(t *testing.T, path string, v interface{}) {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, v))
+}
@@ -0,0 +1,180 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+)
+
+// schemaMismatchesLabel is the distribution that counts a script's schema mismatches. It only has a sample once
+// the script's schema was checked, which happens on its first successful run.
+const schemaMismatchesLabel = "Schema Mismatches"
+
+// FailureClassSchema is a script whose tables don't match the expected schema.
+const FailureClassSchema FailureClass = "Schema"
+
+// TableSchema is the schema of one of the tables that a script outputs.
+type TableSchema struct {
+	Name    string         `json:"name"`
+	Columns []ColumnSchema `json:"columns"`
+}
+
+// ColumnSchema is the name and type of a column. The type is that of the vizierpb.DataType. Ex: "INT64".
+type ColumnSchema struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// schemaExpectations are the expected tables of each script, keyed by script name.
+type schemaExpectations map[string][]TableSchema
+
+func readSchemaExpectations(path string) (schemaExpectations, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	expectations := make(schemaExpectations)
+	if err := yaml.UnmarshalStrict(content, &expectations); err != nil {
+		return nil, fmt.Errorf("failed to parse schema file %s: %w", path, err)
+	}
+	return expectations, nil
+}
+
+func writeSchemaExpectations(path string, expectations schemaExpectations) error {
+	content, err := yaml.Marshal(expectations)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0644)
+}
+
+// tableSchemas returns the schemas of the tables described by the metadata of a run, sorted by table name.
+func tableSchemas(tables []*vizierpb.QueryMetadata) []TableSchema {
+	schemas := make([]TableSchema, 0, len(tables))
+	for _, md := range tables {
+		s := TableSchema{Name: md.Name}
+		for _, c := range md.Relation.GetColumns() {
+			s.Columns = append(s.Columns, ColumnSchema{Name: c.ColumnName, Type: c.ColumnType.String()})
+		}
+		schemas = append(schemas, s)
+	}
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].Name < schemas[j].Name })
+	return schemas
+}
+
+// compareSchemas describes every difference between the expected and the observed tables of a script. The
+// order of the tables and of their columns doesn't matter.
+func compareSchemas(expected, observed []TableSchema) []string {
+	var mismatches []string
+	observedTables := make(map[string]TableSchema, len(observed))
+	for _, t := range observed {
+		observedTables[t.Name] = t
+	}
+	expectedTables := make(map[string]bool, len(expected))
+	for _, e := range expected {
+		expectedTables[e.Name] = true
+		o, ok := observedTables[e.Name]
+		if !ok {
+			mismatches = append(mismatches, fmt.Sprintf("missing table %s", e.Name))
+			continue
+		}
+		observedCols := make(map[string]string, len(o.Columns))
+		for _, c := range o.Columns {
+			observedCols[c.Name] = c.Type
+		}
+		expectedCols := make(map[string]bool, len(e.Columns))
+		for _, c := range e.Columns {
+			expectedCols[c.Name] = true
+			typ, ok := observedCols[c.Name]
+			switch {
+			case !ok:
+				mismatches = append(mismatches, fmt.Sprintf("table %s is missing column %s", e.Name, c.Name))
+			case typ != c.Type:
+				mismatches = append(mismatches, fmt.Sprintf("table %s column %s is %s, expected %s", e.Name, c.Name, typ, c.Type))
+			}
+		}
+		for _, c := range o.Columns {
+			if !expectedCols[c.Name] {
+				mismatches = append(mismatches, fmt.Sprintf("table %s has unexpected column %s", e.Name, c.Name))
+			}
+		}
+	}
+	for _, o := range observed {
+		if !expectedTables[o.Name] {
+			mismatches = append(mismatches, fmt.Sprintf("unexpected table %s", o.Name))
+		}
+	}
+	return mismatches
+}
+
+// recordSchema records the schema observed on the script's first successful run, and checks it against the
+// expected schema, if there is one. Later runs, and runs that failed, are ignored.
+func recordSchema(data *ScriptExecData, res *execResults, expectations schemaExpectations) {
+	if data.Schema != nil || res.scriptErr != nil {
+		return
+	}
+	data.Schema = tableSchemas(res.tables)
+	expected, ok := expectations[schemaScriptName(data.Name)]
+	if !ok {
+		return
+	}
+	dist := &ErrorDistribution{make([]error, 0)}
+	data.Distributions[schemaMismatchesLabel] = dist
+	data.SchemaMismatches = compareSchemas(expected, data.Schema)
+	if len(data.SchemaMismatches) == 0 {
+		dist.Append(nil)
+		return
+	}
+	failure := &RunFailure{
+		Run:     len(data.Distributions[numErrorsLabel].(*ErrorDistribution).Errors) - 1,
+		Class:   FailureClassSchema,
+		Code:    "SchemaMismatch",
+		Message: truncateBytes(strings.Join(data.SchemaMismatches, "; "), maxFailureMessageBytes),
+	}
+	data.Failures = append(data.Failures, failure)
+	dist.Append(failure)
+}
+
+// observedSchemas returns the schema observed for each script that ran successfully, to write as the expected
+// schemas.
+func observedSchemas(data map[string]*ScriptExecData) schemaExpectations {
+	expectations := make(schemaExpectations)
+	for name, d := range data {
+		if d.Schema != nil {
+			expectations[schemaScriptName(name)] = d.Schema
+		}
+	}
+	return expectations
+}
+
+// schemaScriptName returns the name of the script that the schema of the given runs is expected under. Every
+// window of a swept script shares the script's schema.
+func schemaScriptName(name string) string {
+	if script, _, ok := parseSweepName(name); ok {
+		return script
+	}
+	return name
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+)
+
+func httpEventsMetadata(columns ...*vizierpb.Relation_ColumnInfo) []*vizierpb.QueryMetadata {
+	return []*vizierpb.QueryMetadata{{
+		Name:     "http_events",
+		ID:       "table-1",
+		Relation: &vizierpb.Relation{Columns: columns},
+	}}
+}
+
+func TestCompareSchemas(t *testing.T) {
+	expected := []TableSchema{
+		{Name: "http_events", Columns: []ColumnSchema{{"time_", "TIME64NS"}, {"latency", "INT64"}, {"service", "STRING"}}},
+		{Name: "stats", Columns: []ColumnSchema{{"count", "INT64"}}},
+	}
+	observed := tableSchemas(append(httpEventsMetadata(
+		&vizierpb.Relation_ColumnInfo{ColumnName: "latency", ColumnType: vizierpb.FLOAT64},
+		&vizierpb.Relation_ColumnInfo{ColumnName: "time_", ColumnType: vizierpb.TIME64NS},
+		&vizierpb.Relation_ColumnInfo{ColumnName: "pod", ColumnType: vizierpb.STRING},
+	), &vizierpb.QueryMetadata{Name: "extra"}))
+
+	assert.Equal(t, []string{
+		"table http_events column latency is FLOAT64, expected INT64",
+		"table http_events is missing column service",
+		"table http_events has unexpected column pod",
+		"missing table stats",
+		"unexpected table extra",
+	}, compareSchemas(expected, observed))
+	assert.Empty(t, compareSchemas(observed, observed))
+}
+
+func TestRecordSchema_ChecksFirstSuccessfulRun(t *testing.T) {
+	expectations := schemaExpectations{
+		"px/http_data": {{Name: "http_events", Columns: []ColumnSchema{{"latency", "INT64"}}}},
+	}
+	data := newScriptExecData("px/http_data")
+
+	failed := &execResults{scriptErr: errors.New("timeout")}
+	recordResults(data, failed)
+	recordSchema(data, failed, expectations)
+	assert.Nil(t, data.Schema)
+
+	dropped := &execResults{tables: httpEventsMetadata()}
+	recordResults(data, dropped)
+	recordSchema(data, dropped, expectations)
+	assert.Equal(t, []string{"table http_events is missing column latency"}, data.SchemaMismatches)
+	require.Len(t, data.Failures, 2)
+	assert.Equal(t, FailureClassSchema, data.Failures[1].Class)
+	assert.Equal(t, 1, data.Failures[1].Run)
+	assert.Equal(t, 1, data.Distributions[schemaMismatchesLabel].(*ErrorDistribution).Num())
+
+	// Later runs aren't checked again.
+	fixed := &execResults{tables: httpEventsMetadata(&vizierpb.Relation_ColumnInfo{ColumnName: "latency", ColumnType: vizierpb.INT64})}
+	recordResults(data, fixed)
+	recordSchema(data, fixed, expectations)
+	assert.Len(t, data.Failures, 2)
+	assert.Equal(t, 1, numFailedScripts(map[string]*ScriptExecData{"px/http_data": data}))
+}
+
+func TestSchemaExpectations_RoundTrip(t *testing.T) {
+	data := map[string]*ScriptExecData{
+		"px/http_data":              newScriptExecData("px/http_data"),
+		sweepName("px/pods", "-5m"): newScriptExecData(sweepName("px/pods", "-5m")),
+		"px/cluster":                newScriptExecData("px/cluster"),
+	}
+	res := &execResults{tables: httpEventsMetadata(&vizierpb.Relation_ColumnInfo{ColumnName: "latency", ColumnType: vizierpb.INT64})}
+	recordSchema(data["px/http_data"], res, nil)
+	recordSchema(data[sweepName("px/pods", "-5m")], res, nil)
+
+	path := filepath.Join(t.TempDir(), "expectations.yaml")
+	require.NoError(t, writeSchemaExpectations(path, observedSchemas(data)))
+	expectations, err := readSchemaExpectations(path)
+	require.NoError(t, err)
+	assert.Equal(t, schemaExpectations{
+		"px/http_data": {{Name: "http_events", Columns: []ColumnSchema{{"latency", "INT64"}}}},
+		"px/pods":      {{Name: "http_events", Columns: []ColumnSchema{{"latency", "INT64"}}}},
+	}, expectations)
+}
@@ -0,0 +1,49 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"px.dev/pixie/src/utils/script"
+)
+
+// scriptHash returns the sha256 of the script's PxL and vis spec, which identifies the version of the script
+// that was run, even if the bundle it came from was republished under the same name.
+func scriptHash(s *script.ExecutableScript) string {
+	h := sha256.New()
+	h.Write([]byte(s.ScriptString))
+	// json sorts map keys, so the same vis spec always has the same encoding.
+	if s.Vis != nil {
+		vis, err := json.Marshal(s.Vis)
+		if err == nil {
+			h.Write([]byte{0})
+			h.Write(vis)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// scriptChanged returns whether the two results were produced by different versions of the script. Results
+// from before the hash was recorded are assumed to be from the same version.
+func scriptChanged(a, b *ScriptExecData) bool {
+	return a.ScriptHash != "" && b.ScriptHash != "" && a.ScriptHash != b.ScriptHash
+}
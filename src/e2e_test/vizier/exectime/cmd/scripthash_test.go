@@ -0,0 +1,54 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"px.dev/pixie/src/api/proto/vispb"
+	"px.dev/pixie/src/utils/script"
+)
+
+func TestScriptHash(t *testing.T) {
+	s := &script.ExecutableScript{ScriptName: "px/http_data", ScriptString: "import px"}
+	hash := scriptHash(s)
+	assert.Len(t, hash, 64)
+
+	// The name doesn't affect the hash, only what is run.
+	renamed := *s
+	renamed.ScriptName = "px/http_data (start_time=-5m)"
+	assert.Equal(t, hash, scriptHash(&renamed))
+
+	edited := *s
+	edited.ScriptString = "import px\n"
+	assert.NotEqual(t, hash, scriptHash(&edited))
+
+	withVis := *s
+	withVis.Vis = &vispb.Vis{Variables: []*vispb.Vis_Variable{{Name: "start_time"}}}
+	assert.NotEqual(t, hash, scriptHash(&withVis))
+}
+
+func TestScriptChanged(t *testing.T) {
+	assert.False(t, scriptChanged(&ScriptExecData{ScriptHash: "a"}, &ScriptExecData{ScriptHash: "a"}))
+	assert.True(t, scriptChanged(&ScriptExecData{ScriptHash: "a"}, &ScriptExecData{ScriptHash: "b"}))
+	// Results from before the hash was recorded can't be checked.
+	assert.False(t, scriptChanged(&ScriptExecData{}, &ScriptExecData{ScriptHash: "b"}))
+}
@@ -0,0 +1,200 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+)
+
+func init() {
+	SmokeCmd.PersistentFlags().StringP("cloud_addr", "a", "withpixie.ai:443", "The address of Pixie Cloud")
+	SmokeCmd.PersistentFlags().StringP("bundle", "b", defaultBundleFile, "The bundle file to use")
+	SmokeCmd.PersistentFlags().BoolP("all-clusters", "d", false, "Run script across all clusters")
+	SmokeCmd.PersistentFlags().BoolP("split-funcs", "p", false, "Run each function from the vis spec separately")
+	SmokeCmd.PersistentFlags().StringSliceP("cluster", "c", nil, "Run only on the selected clusters, by cluster ID, name or unique name prefix. Repeat, or separate with commas, to select several")
+	SmokeCmd.PersistentFlags().StringSliceP("scripts", "s", nil, "Run only on selected scripts")
+	SmokeCmd.PersistentFlags().StringP("output", "o", "table", "Output format to use. Currently supports 'table', 'json' or 'csv'")
+	SmokeCmd.PersistentFlags().Duration("timeout", 3*time.Second, "How long each script may take before it fails")
+	addBundleCacheFlags(SmokeCmd)
+	addConnectionFlags(SmokeCmd)
+	addQuietFlag(SmokeCmd)
+	registerFlagCompletion(SmokeCmd, "scripts", completeScriptNames)
+	RootCmd.AddCommand(SmokeCmd)
+}
+
+// SmokeResult is the result of running a script once.
+type SmokeResult struct {
+	Script   string `json:"script"`
+	OK       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+func writeSmokeTable(results []*SmokeResult) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Name", "Result", "Duration", "Error"})
+	for _, r := range results {
+		result := "PASS"
+		if !r.OK {
+			result = "FAIL"
+		}
+		table.Append([]string{r.Script, result, r.Duration, r.Error})
+	}
+	table.Render()
+}
+
+func writeSmokeCSV(out io.Writer, results []*SmokeResult) error {
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{"Name", "OK", "Duration", "Error"}); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if err := w.Write([]string{r.Script, strconv.FormatBool(r.OK), r.Duration, r.Error}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func smokeCmd(cmd *cobra.Command) {
+	start := time.Now()
+	// Set the logger to use stderr so that json output can be consumed without log lines.
+	log.SetOutput(os.Stderr)
+
+	cloudAddr, _ := cmd.Flags().GetString("cloud_addr")
+	bundleFile, _ := cmd.Flags().GetString("bundle")
+	allClusters, _ := cmd.Flags().GetBool("all-clusters")
+	selectedClusters, _ := cmd.Flags().GetStringSlice("cluster")
+	selectedScripts, _ := cmd.Flags().GetStringSlice("scripts")
+	outputFmt, _ := cmd.Flags().GetString("output")
+	splitByFunc, _ := cmd.Flags().GetBool("split-funcs")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	quiet, _ := cmd.Flags().GetBool("quiet")
+
+	if !allowedOutputFmts[outputFmt] {
+		log.WithField("output", outputFmt).Fatal("invalid output format")
+	}
+	if allClusters && len(selectedClusters) > 0 {
+		log.Fatal("--all-clusters can't be combined with --cluster")
+	}
+	connOpts, err := connectorOptions(cmd)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid connection flags")
+	}
+	clusters, err := validateClusters([]string{cloudAddr}, selectedClusters)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid --cluster")
+	}
+
+	br, _, err := createBundleReader(bundleFile, bundleFetchOptionsFromFlags(cmd))
+	if err != nil {
+		log.WithError(err).Fatal("Failed to read script bundle")
+	}
+
+	allowedScripts := make(map[string]bool)
+	for _, s := range selectedScripts {
+		allowedScripts[s] = true
+	}
+
+	ep := connectEndpoint(cloudAddr, allClusters, clusters[cloudAddr], br.GetScripts(), allowedScripts, splitByFunc, false, false, nil, connOpts)
+	names := make([]string, 0, len(ep.scripts))
+	for name := range ep.scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	log.Infof("Running %d scripts once each", len(names))
+	results := make([]*SmokeResult, 0, len(names))
+	outcomes := make([]*scriptOutcome, 0, len(names))
+	numFailed := 0
+	for _, name := range names {
+		log.WithField("script", name).Infof("Executing script")
+		runStart := time.Now()
+		res, err := executeScript(ep.conns, ep.scripts[name], timeout, nil, 0, vizier.FormatCountOnly, 1)
+		o := &scriptOutcome{name: name, runs: 1, meanTime: time.Since(runStart)}
+		if err == nil {
+			err = res.scriptErr
+			o.bytes = res.numBytes
+		}
+		r := &SmokeResult{
+			Script:   name,
+			OK:       err == nil,
+			Duration: o.meanTime.Round(time.Millisecond).String(),
+		}
+		if err != nil {
+			r.Error = vizier.FormatErrorMessage(err)
+			o.failed = true
+			numFailed++
+		}
+		results = append(results, r)
+		outcomes = append(outcomes, o)
+	}
+
+	if outputFmt == "table" {
+		writeSmokeTable(results)
+	}
+	if outputFmt == "csv" {
+		if err := writeSmokeCSV(os.Stdout, results); err != nil {
+			log.WithError(err).Fatal("Failed to write results to csv")
+		}
+	}
+	if outputFmt == "json" {
+		jsonData, err := json.Marshal(results)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to marshal results to json")
+		}
+		os.Stdout.Write(jsonData)
+	}
+
+	if !quiet {
+		summary := newRunSummary(outcomes, time.Since(start))
+		summary.outputs = append(summary.outputs, fmt.Sprintf("stdout (%s)", outputFmt))
+		// Any failure fails the smoke test.
+		summary.addGate("smoke test", trippedBy(outcomes, func(o *scriptOutcome) bool { return o.failed }))
+		summary.write(os.Stderr)
+	}
+	if numFailed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d scripts failed\n", numFailed, len(results))
+		os.Exit(1)
+	}
+}
+
+// SmokeCmd runs every script once, and reports which ones fail.
+var SmokeCmd = &cobra.Command{
+	Use:   "smoke",
+	Short: "Run every script once and report whether it passed",
+	Run: func(cmd *cobra.Command, args []string) {
+		smokeCmd(cmd)
+	},
+}
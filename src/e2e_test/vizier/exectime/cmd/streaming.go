@@ -0,0 +1,164 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+	"px.dev/pixie/src/utils/script"
+)
+
+const (
+	rowsPerSecondLabel    = "Rows Per Second"
+	batchesPerSecondLabel = "Batches Per Second"
+)
+
+// streamedDiffColumns are the distributions shown when diffing streamed scripts, which don't have exec times.
+var streamedDiffColumns = []string{timeToFirstRowLabel, rowsPerSecondLabel, batchesPerSecondLabel, numErrorsLabel}
+
+// isStreaming returns whether the script streams its results until it is canceled, instead of ending.
+func isStreaming(s *script.ExecutableScript) bool {
+	return strings.Contains(s.ScriptString, "stream()")
+}
+
+func newStreamedScriptExecData(name string) *ScriptExecData {
+	return &ScriptExecData{
+		Name:     name,
+		Streamed: true,
+		Distributions: addFailureClassDistributions(distributionMap{
+			timeToFirstRowLabel:       &TimeDistribution{make([]time.Duration, 0)},
+			errorsBeforeFirstRowLabel: &ErrorDistribution{make([]error, 0)},
+			numErrorsLabel:            &ErrorDistribution{make([]error, 0)},
+			numBytesLabel:             &BytesDistribution{make([]int, 0)},
+			wireBytesLabel:            &BytesDistribution{make([]int, 0)},
+			compressionRatioLabel:     &RatioDistribution{make([]float64, 0)},
+			rowsPerSecondLabel:        &RateDistribution{make([]float64, 0)},
+			batchesPerSecondLabel:     &RateDistribution{make([]float64, 0)},
+		}),
+	}
+}
+
+// isCancellation returns whether the error is the result of canceling the script.
+func isCancellation(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return true
+	}
+	var scriptErr *vizier.ScriptExecutionError
+	if !errors.As(err, &scriptErr) {
+		return false
+	}
+	if scriptErr.Code() == vizier.CodeCanceled {
+		return true
+	}
+	return scriptErr.RPCStatus() != nil && scriptErr.RPCStatus().Code() == codes.Canceled
+}
+
+// executeStreamingScript runs a script that streams its results, and cancels it after the given duration.
+// Being canceled is how the run ends, so it is not an error.
+func executeStreamingScript(v []*vizier.Connector, execScript *script.ExecutableScript, duration time.Duration,
+	concurrentRuns int) (*execResults, error) {
+	collectGarbage(concurrentRuns)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wire vizier.WireBytesCounter
+	ctx = vizier.WithWireBytesCounter(ctx, &wire)
+	execRes := execResults{}
+	start := time.Now()
+	resp, err := vizier.RunScript(ctx, v, execScript, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var batchTimes []time.Time
+	onRowBatch := func(numRows int, receivedAt time.Time) {
+		batchTimes = append(batchTimes, receivedAt)
+		execRes.numRows += numRows
+	}
+	tw := vizier.NewStreamOutputAdapter(ctx, resp, vizier.FormatCountOnly, nil, vizier.WithRowBatchCallback(onRowBatch))
+	timer := time.AfterFunc(duration, cancel)
+	err = tw.Finish()
+	canceled := !timer.Stop()
+
+	execRes.externalExecTime = time.Since(start)
+	stopStream(cancel, resp)
+	if len(batchTimes) > 0 {
+		execRes.timeToFirstRow = batchTimes[0].Sub(start)
+		execRes.receivedRows = true
+	}
+	execRes.numBatches = len(batchTimes)
+	execRes.numBytes = tw.TotalBytes()
+	execRes.wireBytes = int(wire.Sent() + wire.Received())
+	if err != nil && !(canceled && isCancellation(err)) {
+		log.WithError(err).Infof("Error '%s' on '%s'", vizier.FormatErrorMessage(err), execScript.ScriptName)
+		execRes.scriptErr = err
+	}
+	return &execRes, nil
+}
+
+// recordStreamedResults appends the results of a streamed run to the script's distributions. The rates are
+// over the whole run, so that runs of different durations can be compared.
+func recordStreamedResults(data *ScriptExecData, res *execResults) {
+	dists := data.Distributions
+	runErr := recordFailure(data, res.scriptErr)
+	dists[numErrorsLabel].Append(runErr)
+	recordBytes(dists, res)
+	if res.receivedRows {
+		dists[timeToFirstRowLabel].Append(res.timeToFirstRow)
+		dists[errorsBeforeFirstRowLabel].Append(nil)
+	} else {
+		dists[errorsBeforeFirstRowLabel].Append(runErr)
+	}
+	seconds := res.externalExecTime.Seconds()
+	dists[rowsPerSecondLabel].Append(float64(res.numRows) / seconds)
+	dists[batchesPerSecondLabel].Append(float64(res.numBatches) / seconds)
+}
+
+// splitStreamed splits the script data into the scripts that ran to completion, and the streamed scripts.
+func splitStreamed(data []*ScriptExecData) ([]*ScriptExecData, []*ScriptExecData) {
+	var bounded, streamed []*ScriptExecData
+	for _, d := range data {
+		if d.Streamed {
+			streamed = append(streamed, d)
+		} else {
+			bounded = append(bounded, d)
+		}
+	}
+	return bounded, streamed
+}
+
+// splitStreamedDiffs splits the diffs into the diffs of the scripts that ran to completion, and of the streamed
+// scripts.
+func splitStreamedDiffs(diffs []*scriptExecDiff) ([]*scriptExecDiff, []*scriptExecDiff) {
+	var bounded, streamed []*scriptExecDiff
+	for _, d := range diffs {
+		if d.Streamed {
+			streamed = append(streamed, d)
+		} else {
+			bounded = append(bounded, d)
+		}
+	}
+	return bounded, streamed
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func addQuietFlag(c *cobra.Command) {
+	c.PersistentFlags().BoolP("quiet", "q", false, "Don't print the summary of the invocation to stderr")
+}
+
+// scriptOutcome is how a script fared over all of its runs.
+type scriptOutcome struct {
+	name   string
+	runs   int
+	failed bool
+	noisy  bool
+	bytes  int
+	// meanTime is the mean exec time of the script's runs. Zero for scripts without an exec time, such as the
+	// streamed ones, which aren't considered for the slowest script.
+	meanTime time.Duration
+}
+
+// runSummary is the short summary of an invocation that is printed to stderr once it is over.
+type runSummary struct {
+	attempted int
+	succeeded int
+	failed    int
+	// skipped are the scripts that were never run, such as because the benchmark was quit early.
+	skipped  int
+	runs     int
+	bytes    int
+	wallTime time.Duration
+	slowest  *scriptOutcome
+	// outputs describes where the results were written.
+	outputs []string
+	// gates are the failure gates that were enabled, with the scripts that tripped each of them.
+	gates map[string][]string
+}
+
+func newRunSummary(outcomes []*scriptOutcome, wallTime time.Duration) *runSummary {
+	s := &runSummary{wallTime: wallTime, gates: make(map[string][]string)}
+	for _, o := range outcomes {
+		if o.runs == 0 {
+			s.skipped++
+			continue
+		}
+		s.attempted++
+		if o.failed {
+			s.failed++
+		} else {
+			s.succeeded++
+		}
+		s.runs += o.runs
+		s.bytes += o.bytes
+		if o.meanTime > 0 && (s.slowest == nil || o.meanTime > s.slowest.meanTime) {
+			s.slowest = o
+		}
+	}
+	return s
+}
+
+// addGate records that the named failure gate was enabled, and which scripts tripped it.
+func (s *runSummary) addGate(name string, tripped []string) {
+	sort.Strings(tripped)
+	s.gates[name] = tripped
+}
+
+func (s *runSummary) write(w io.Writer) {
+	fmt.Fprintf(w, "Scripts: %d attempted, %d succeeded, %d failed, %d skipped\n",
+		s.attempted, s.succeeded, s.failed, s.skipped)
+	fmt.Fprintf(w, "Runs: %d in %v, %.1fkB received\n", s.runs, s.wallTime.Round(time.Second), float64(s.bytes)/1024)
+	if s.slowest != nil {
+		fmt.Fprintf(w, "Slowest script: %s (%v mean)\n", s.slowest.name, s.slowest.meanTime.Round(time.Millisecond))
+	}
+	if len(s.outputs) > 0 {
+		fmt.Fprintf(w, "Results written to: %s\n", strings.Join(s.outputs, ", "))
+	}
+	gates := make([]string, 0, len(s.gates))
+	for name := range s.gates {
+		gates = append(gates, name)
+	}
+	sort.Strings(gates)
+	for _, name := range gates {
+		tripped := s.gates[name]
+		if len(tripped) == 0 {
+			fmt.Fprintf(w, "%s: passed\n", name)
+			continue
+		}
+		fmt.Fprintf(w, "%s: tripped by %s\n", name, strings.Join(tripped, ", "))
+	}
+}
+
+// benchmarkOutcomes returns the outcome of every script on every endpoint. With several endpoints, the
+// scripts are named after their endpoint too.
+func benchmarkOutcomes(endpoints []*benchmarkEndpoint) []*scriptOutcome {
+	var outcomes []*scriptOutcome
+	for _, ep := range endpoints {
+		for _, d := range sortByKeys(&ep.data) {
+			name := d.Name
+			if len(endpoints) > 1 {
+				name = fmt.Sprintf("%s (%s)", d.Name, ep.label())
+			}
+			o := &scriptOutcome{name: name, runs: numRuns(d), failed: scriptFailed(d), noisy: d.Noisy}
+			// A mutation script's query isn't run if its tracepoints fail to deploy.
+			if dist, ok := d.Distributions[mutationErrorsLabel].(*ErrorDistribution); ok && len(dist.Errors) > o.runs {
+				o.runs = len(dist.Errors)
+			}
+			if dist, ok := d.Distributions[numBytesLabel].(*BytesDistribution); ok {
+				for _, b := range dist.Bytes {
+					o.bytes += b
+				}
+			}
+			if dist, ok := d.Distributions[execTimeExternalLabel].(*TimeDistribution); ok {
+				o.meanTime = dist.Mean()
+			}
+			outcomes = append(outcomes, o)
+		}
+	}
+	return outcomes
+}
+
+// trippedBy returns the names of the scripts whose outcomes trip a failure gate.
+func trippedBy(outcomes []*scriptOutcome, trips func(*scriptOutcome) bool) []string {
+	tripped := make([]string, 0)
+	for _, o := range outcomes {
+		if trips(o) {
+			tripped = append(tripped, o.name)
+		}
+	}
+	return tripped
+}
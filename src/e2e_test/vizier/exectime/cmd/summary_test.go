@@ -0,0 +1,70 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunSummary(t *testing.T) {
+	outcomes := []*scriptOutcome{
+		{name: "px/cluster", runs: 3, bytes: 2048, meanTime: 2 * time.Second},
+		{name: "px/http_data", runs: 3, failed: true, bytes: 1024, meanTime: 5 * time.Second},
+		{name: "px/http_stream", runs: 3, noisy: true},
+		{name: "px/namespace"},
+	}
+	s := newRunSummary(outcomes, 90*time.Second)
+	assert.Equal(t, 3, s.attempted)
+	assert.Equal(t, 2, s.succeeded)
+	assert.Equal(t, 1, s.failed)
+	assert.Equal(t, 1, s.skipped)
+	assert.Equal(t, 9, s.runs)
+	assert.Equal(t, "px/http_data", s.slowest.name)
+
+	s.outputs = []string{"stdout (json)"}
+	s.addGate("--fail-on-error", trippedBy(outcomes, func(o *scriptOutcome) bool { return o.failed }))
+	s.addGate("--fail-on-noisy", trippedBy(outcomes, func(o *scriptOutcome) bool { return false }))
+	var buf bytes.Buffer
+	s.write(&buf)
+	assert.Equal(t, `Scripts: 3 attempted, 2 succeeded, 1 failed, 1 skipped
+Runs: 9 in 1m30s, 3.0kB received
+Slowest script: px/http_data (5s mean)
+Results written to: stdout (json)
+--fail-on-error: tripped by px/http_data
+--fail-on-noisy: passed
+`, buf.String())
+}
+
+func TestBenchmarkOutcomes(t *testing.T) {
+	d := newScriptExecData("px/cluster")
+	recordResults(d, &execResults{externalExecTime: time.Second, numBytes: 10})
+	recordResults(d, &execResults{externalExecTime: 3 * time.Second, numBytes: 20, scriptErr: assert.AnError})
+	ep := &benchmarkEndpoint{cloudAddr: "withpixie.ai:443", data: map[string]*ScriptExecData{"px/cluster": d}}
+
+	outcomes := benchmarkOutcomes([]*benchmarkEndpoint{ep})
+	assert.Equal(t, []*scriptOutcome{
+		{name: "px/cluster", runs: 2, failed: true, bytes: 30, meanTime: 2 * time.Second},
+	}, outcomes)
+	// The summary agrees with the gate.
+	assert.Equal(t, numFailedScripts(ep.data), newRunSummary(outcomes, 0).failed)
+}
@@ -0,0 +1,245 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"gonum.org/v1/gonum/stat"
+
+	"px.dev/pixie/src/utils/script"
+)
+
+const startTimeArg = "start_time"
+
+// sweepName returns the name of the run of the named script over the given window.
+func sweepName(name, startTime string) string {
+	return fmt.Sprintf("%s[%s=%s]", name, startTimeArg, startTime)
+}
+
+// parseSweepName returns the name of the script and the window of a sweep run's name, or false if the name
+// isn't that of a sweep run.
+func parseSweepName(name string) (string, string, bool) {
+	prefix := "[" + startTimeArg + "="
+	i := strings.LastIndex(name, prefix)
+	if i < 0 || !strings.HasSuffix(name, "]") {
+		return "", "", false
+	}
+	return name[:i], name[i+len(prefix) : len(name)-1], true
+}
+
+// windowSize returns the size of the window queried by a relative start time, such as "-5m".
+func windowSize(startTime string) (time.Duration, error) {
+	d, err := time.ParseDuration(startTime)
+	if err != nil {
+		return 0, err
+	}
+	if d >= 0 {
+		return 0, fmt.Errorf("start time '%s' must be in the past", startTime)
+	}
+	return -d, nil
+}
+
+func hasVariable(s *script.ExecutableScript, name string) bool {
+	if s.Vis == nil {
+		return false
+	}
+	for _, v := range s.Vis.Variables {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sweepStartTimes replaces each of the endpoint's scripts that takes a start time with a run over each of the
+// given windows. Scripts without a start time aren't affected by the window, so they are run once, as usual.
+func (ep *benchmarkEndpoint) sweepStartTimes(startTimes []string) {
+	for name, s := range ep.scripts {
+		if !hasVariable(s, startTimeArg) {
+			continue
+		}
+		for _, startTime := range startTimes {
+			swept := *s
+			swept.ScriptName = sweepName(name, startTime)
+			swept.Args = make(map[string]script.Arg, len(s.Args))
+			for k, v := range s.Args {
+				swept.Args[k] = v
+			}
+			swept.Args[startTimeArg] = script.Arg{Name: startTimeArg, Value: startTime}
+			ep.scripts[swept.ScriptName] = &swept
+			ep.data[swept.ScriptName] = newScriptExecData(swept.ScriptName)
+			ep.data[swept.ScriptName].PromptedArgs = ep.data[name].PromptedArgs
+		}
+		delete(ep.scripts, name)
+		delete(ep.data, name)
+	}
+}
+
+// sortSweeps sorts the script data by name, with the runs of each swept script in order of window size.
+func sortSweeps(data []*ScriptExecData) {
+	sort.SliceStable(data, func(i, j int) bool {
+		iName, iStart, iSwept := parseSweepName(data[i].Name)
+		if !iSwept {
+			iName = data[i].Name
+		}
+		jName, jStart, jSwept := parseSweepName(data[j].Name)
+		if !jSwept {
+			jName = data[j].Name
+		}
+		if iName != jName || !iSwept || !jSwept {
+			return iName < jName
+		}
+		iSize, _ := windowSize(iStart)
+		jSize, _ := windowSize(jStart)
+		return iSize < jSize
+	})
+}
+
+// SweepScaling summarizes how a script's exec time and bytes scale with the size of the queried window.
+type SweepScaling struct {
+	// The start times of the windows, from the smallest to the largest window.
+	StartTimes []string
+	// The mean external exec time and bytes of the runs over each window.
+	ExecTimes []time.Duration
+	Bytes     []float64
+	// The slopes of linear fits of the exec time and bytes to the window size, per minute of window.
+	ExecTimeSlope time.Duration
+	BytesSlope    float64
+}
+
+// newSweepScaling summarizes the runs of a swept script, which are keyed by start time.
+func newSweepScaling(runs map[string]*ScriptExecData) *SweepScaling {
+	scaling := &SweepScaling{}
+	for startTime := range runs {
+		scaling.StartTimes = append(scaling.StartTimes, startTime)
+	}
+	sizes := make(map[string]time.Duration, len(runs))
+	for _, startTime := range scaling.StartTimes {
+		sizes[startTime], _ = windowSize(startTime)
+	}
+	sort.Slice(scaling.StartTimes, func(i, j int) bool {
+		return sizes[scaling.StartTimes[i]] < sizes[scaling.StartTimes[j]]
+	})
+
+	// Runs that failed every time have no samples, and are left out of the fits.
+	var minutes, execTimes, bytes []float64
+	for _, startTime := range scaling.StartTimes {
+		execTime := runs[startTime].Distributions[execTimeExternalLabel].(*TimeDistribution).Mean()
+		var meanBytes float64
+		if b := runs[startTime].Distributions[numBytesLabel].(*BytesDistribution); len(b.Bytes) > 0 {
+			meanBytes = b.Mean()
+		}
+		scaling.ExecTimes = append(scaling.ExecTimes, execTime)
+		scaling.Bytes = append(scaling.Bytes, meanBytes)
+		if execTime == 0 {
+			continue
+		}
+		minutes = append(minutes, sizes[startTime].Minutes())
+		execTimes = append(execTimes, float64(execTime))
+		bytes = append(bytes, meanBytes)
+	}
+	if len(minutes) >= 2 {
+		_, execTimeSlope := stat.LinearRegression(minutes, execTimes, nil, false)
+		_, scaling.BytesSlope = stat.LinearRegression(minutes, bytes, nil, false)
+		scaling.ExecTimeSlope = time.Duration(execTimeSlope)
+	}
+	return scaling
+}
+
+// nestSweeps returns the script data with the runs of each swept script nested under an entry for the
+// script, keyed by start time, along with a summary of how the script scales with the window.
+func nestSweeps(data map[string]*ScriptExecData) map[string]*ScriptExecData {
+	nested := make(map[string]*ScriptExecData, len(data))
+	for name, d := range data {
+		base, startTime, ok := parseSweepName(name)
+		if !ok {
+			nested[name] = d
+			continue
+		}
+		parent, ok := nested[base]
+		if !ok {
+			parent = &ScriptExecData{
+				Name:          base,
+				Distributions: make(distributionMap),
+				Sweep:         make(map[string]*ScriptExecData),
+			}
+			nested[base] = parent
+		}
+		parent.Sweep[startTime] = d
+		// Every window runs the same script.
+		parent.ScriptHash = d.ScriptHash
+	}
+	for _, d := range nested {
+		if d.Sweep != nil {
+			d.Scaling = newSweepScaling(d.Sweep)
+		}
+	}
+	return nested
+}
+
+// flattenSweeps undoes nestSweeps, so that the runs over each window can be compared like for like.
+func flattenSweeps(data map[string]*ScriptExecData) map[string]*ScriptExecData {
+	flat := make(map[string]*ScriptExecData, len(data))
+	for name, d := range data {
+		if d.Sweep == nil {
+			flat[name] = d
+			continue
+		}
+		for startTime, run := range d.Sweep {
+			flat[sweepName(name, startTime)] = run
+		}
+	}
+	return flat
+}
+
+// writeSweepScaling writes the scaling summary of every swept script out to a table in stdout.
+func writeSweepScaling(data map[string]*ScriptExecData) {
+	nested := nestSweeps(data)
+	names := make([]string, 0)
+	for name, d := range nested {
+		if d.Scaling != nil {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+	sort.Strings(names)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Name", "Start Time", execTimeExternalLabel, numBytesLabel, "Exec Time Slope (per min)", "Bytes Slope (per min)"})
+	for _, name := range names {
+		scaling := nested[name].Scaling
+		for i, startTime := range scaling.StartTimes {
+			row := []string{name, startTime, scaling.ExecTimes[i].Round(10 * time.Microsecond).String(), fmt.Sprintf("%.2f", scaling.Bytes[i]), "", ""}
+			if i == 0 {
+				row[4] = scaling.ExecTimeSlope.Round(10 * time.Microsecond).String()
+				row[5] = fmt.Sprintf("%.2f", scaling.BytesSlope)
+			}
+			table.Append(row)
+		}
+	}
+	table.Render()
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sweepRun returns the data of a run over a window whose exec time and bytes grow linearly with the window.
+func sweepRun(t *testing.T, startTime string) *ScriptExecData {
+	size, err := windowSize(startTime)
+	require.NoError(t, err)
+	d := newScriptExecData(sweepName("px/cluster", startTime))
+	d.Distributions[execTimeExternalLabel].Append(time.Duration(size.Minutes()) * 10 * time.Millisecond)
+	d.Distributions[numBytesLabel].Append(int(size.Minutes()) * 100)
+	return d
+}
+
+func TestParseSweepName(t *testing.T) {
+	name, startTime, ok := parseSweepName(sweepName("px/cluster", "-5m"))
+	require.True(t, ok)
+	assert.Equal(t, "px/cluster", name)
+	assert.Equal(t, "-5m", startTime)
+
+	_, _, ok = parseSweepName("px/cluster")
+	assert.False(t, ok)
+}
+
+func TestNestSweeps(t *testing.T) {
+	data := map[string]*ScriptExecData{"px/namespaces": newScriptExecData("px/namespaces")}
+	for _, startTime := range []string{"-15m", "-1m", "-5m"} {
+		d := sweepRun(t, startTime)
+		data[d.Name] = d
+	}
+
+	nested := nestSweeps(data)
+	require.Len(t, nested, 2)
+	assert.Nil(t, nested["px/namespaces"].Sweep)
+	scaling := nested["px/cluster"].Scaling
+	require.NotNil(t, scaling)
+	assert.Equal(t, []string{"-1m", "-5m", "-15m"}, scaling.StartTimes)
+	assert.Equal(t, []time.Duration{10 * time.Millisecond, 50 * time.Millisecond, 150 * time.Millisecond}, scaling.ExecTimes)
+	assert.Equal(t, 10*time.Millisecond, scaling.ExecTimeSlope.Round(time.Microsecond))
+	assert.InDelta(t, 100, scaling.BytesSlope, 1e-6)
+
+	// Flattening restores the runs under their own names, so they can be compared window by window.
+	assert.Equal(t, data, flattenSweeps(nested))
+}
+
+func TestSortSweeps(t *testing.T) {
+	data := []*ScriptExecData{
+		sweepRun(t, "-15m"),
+		newScriptExecData("px/namespaces"),
+		sweepRun(t, "-1m"),
+		newScriptExecData("px/a"),
+		sweepRun(t, "-5m"),
+	}
+	sortSweeps(data)
+	var names []string
+	for _, d := range data {
+		names = append(names, d.Name)
+	}
+	assert.Equal(t, []string{
+		"px/a",
+		"px/cluster[start_time=-1m]",
+		"px/cluster[start_time=-5m]",
+		"px/cluster[start_time=-15m]",
+		"px/namespaces",
+	}, names)
+}
@@ -0,0 +1,327 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/term"
+)
+
+const (
+	// The number of recent errors kept for the errors pane.
+	maxTUIErrors = 100
+	// The most lines that the errors pane takes up at the bottom of the screen.
+	maxTUIErrorLines = 8
+	// The number of log lines kept to print if the benchmark exits while the TUI is up.
+	maxTUILogLines      = 20
+	tuiProgressBarWidth = 40
+)
+
+// benchmarkTUI shows the results of the benchmark as they are recorded, in place of the log. It reads the
+// results from the same ScriptExecData that the final output is written from. A nil *benchmarkTUI does nothing,
+// so that the benchmark doesn't need to check whether it is running with a TUI.
+//
+// The results are only read from the goroutine that runs the scripts, which renders them as each run finishes,
+// so that redrawing the screen never races with the recording of a run.
+type benchmarkTUI struct {
+	screen    tcell.Screen
+	endpoints []*benchmarkEndpoint
+	start     time.Time
+	logOut    io.Writer
+
+	mu       sync.Mutex
+	total    int
+	finished int
+	current  string
+	results  []string
+	errors   []string
+	logTail  []string
+	quit     bool
+	closed   bool
+}
+
+// useTUI returns whether the TUI can be shown, which requires stdout to be a terminal.
+func useTUI() bool {
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// newBenchmarkTUI takes over the screen to show the progress of totalRuns runs over the given endpoints. The
+// log is captured until the TUI is closed.
+func newBenchmarkTUI(screen tcell.Screen, endpoints []*benchmarkEndpoint, totalRuns int) (*benchmarkTUI, error) {
+	if err := screen.Init(); err != nil {
+		return nil, err
+	}
+	t := &benchmarkTUI{
+		screen:    screen,
+		endpoints: endpoints,
+		start:     time.Now(),
+		total:     totalRuns,
+		logOut:    log.StandardLogger().Out,
+	}
+	t.results = t.resultLines()
+	log.SetOutput(t)
+	// Restore the terminal if the benchmark fails while the TUI is up, and show why.
+	log.RegisterExitHandler(func() {
+		t.mu.Lock()
+		closed := t.closed
+		logTail := strings.Join(t.logTail, "")
+		t.mu.Unlock()
+		if closed {
+			return
+		}
+		t.close()
+		fmt.Fprint(os.Stderr, logTail)
+	})
+	go t.handleEvents()
+	t.draw()
+	return t, nil
+}
+
+// Write keeps the tail of the log, which would otherwise draw over the TUI.
+func (t *benchmarkTUI) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.logTail = append(t.logTail, string(p))
+	if len(t.logTail) > maxTUILogLines {
+		t.logTail = t.logTail[len(t.logTail)-maxTUILogLines:]
+	}
+	return len(p), nil
+}
+
+func (t *benchmarkTUI) handleEvents() {
+	for {
+		switch ev := t.screen.PollEvent().(type) {
+		case nil:
+			// The screen was closed.
+			return
+		case *tcell.EventKey:
+			if ev.Key() == tcell.KeyCtrlC || (ev.Key() == tcell.KeyRune && ev.Rune() == 'q') {
+				t.mu.Lock()
+				t.quit = true
+				t.mu.Unlock()
+				t.draw()
+			}
+		case *tcell.EventResize:
+			t.screen.Sync()
+			t.draw()
+		}
+	}
+}
+
+// quitting returns whether the user asked to quit. The benchmark stops starting runs once they do.
+func (t *benchmarkTUI) quitting() bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.quit
+}
+
+// addRuns adds runs, such as the retries of noisy scripts, to the total.
+func (t *benchmarkTUI) addRuns(n int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.total += n
+	t.mu.Unlock()
+	t.draw()
+}
+
+// startRun shows the run that is in progress.
+func (t *benchmarkTUI) startRun(ep *benchmarkEndpoint, name string, run int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.current = fmt.Sprintf("%s (run %d)", name, run)
+	if len(t.endpoints) > 1 {
+		t.current = fmt.Sprintf("%s on %s", t.current, ep.label())
+	}
+	t.mu.Unlock()
+	t.draw()
+}
+
+// finishRun counts the run of the script, and adds its failure, if any, to the errors pane.
+func (t *benchmarkTUI) finishRun(ep *benchmarkEndpoint, name string) {
+	if t == nil {
+		return
+	}
+	results := t.resultLines()
+	t.mu.Lock()
+	t.finished++
+	t.results = results
+	t.current = ""
+	if err := lastRunError(ep.data[name]); err != nil {
+		line := fmt.Sprintf("%s %s: %s", time.Now().Format("15:04:05"), name, err)
+		if len(t.endpoints) > 1 {
+			line = fmt.Sprintf("%s [%s]", line, ep.label())
+		}
+		t.errors = append(t.errors, line)
+		if len(t.errors) > maxTUIErrors {
+			t.errors = t.errors[len(t.errors)-maxTUIErrors:]
+		}
+	}
+	t.mu.Unlock()
+	t.draw()
+}
+
+// close gives the screen back, so that the final results can be written to the configured output.
+func (t *benchmarkTUI) close() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return
+	}
+	t.closed = true
+	t.screen.Fini()
+	// The logger holds its own lock while it writes to the TUI, so it's set back without holding ours.
+	t.mu.Unlock()
+	log.SetOutput(t.logOut)
+}
+
+// lastRunError returns the error of the script's latest run, or nil if it succeeded or hasn't run.
+func lastRunError(d *ScriptExecData) error {
+	dist, ok := d.Distributions[numErrorsLabel].(*ErrorDistribution)
+	if !ok || len(dist.Errors) == 0 {
+		return nil
+	}
+	return dist.Errors[len(dist.Errors)-1]
+}
+
+// numRuns returns the number of runs recorded for the script, including the oversized ones.
+func numRuns(d *ScriptExecData) int {
+	dist, ok := d.Distributions[numErrorsLabel].(*ErrorDistribution)
+	if !ok {
+		return len(d.OversizedRuns)
+	}
+	return len(dist.Errors) + len(d.OversizedRuns)
+}
+
+// progressLine renders a progress bar for finished out of total runs, with the estimated time remaining.
+func progressLine(finished, total int, elapsed time.Duration) string {
+	if total <= 0 {
+		total = 1
+	}
+	done := finished * tuiProgressBarWidth / total
+	if done > tuiProgressBarWidth {
+		done = tuiProgressBarWidth
+	}
+	bar := strings.Repeat("#", done) + strings.Repeat(".", tuiProgressBarWidth-done)
+	eta := "unknown"
+	if finished > 0 && finished <= total {
+		eta = (elapsed / time.Duration(finished) * time.Duration(total-finished)).Round(time.Second).String()
+	}
+	return fmt.Sprintf("[%s] %d/%d runs, %d%%, ETA %s", bar, finished, total, finished*100/total, eta)
+}
+
+// resultLines renders the results table of each endpoint, for the scripts that have finished a run. It reads
+// the results, so it must only be called from the goroutine that records them.
+func (t *benchmarkTUI) resultLines() []string {
+	var lines []string
+	for _, ep := range t.endpoints {
+		var ran []*ScriptExecData
+		for _, d := range sortByKeys(&ep.data) {
+			if numRuns(d) > 0 {
+				ran = append(ran, d)
+			}
+		}
+		if len(t.endpoints) > 1 {
+			lines = append(lines, ep.label())
+		}
+		if len(ran) == 0 {
+			lines = append(lines, "No runs have finished yet.")
+			continue
+		}
+		var buf bytes.Buffer
+		if err := (&stdoutTableWriter{out: &buf}).Write(&ran); err != nil {
+			lines = append(lines, err.Error())
+			continue
+		}
+		lines = append(lines, strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")...)
+	}
+	return lines
+}
+
+// lines renders the whole screen, of the given height: the progress at the top, the errors pane at the
+// bottom, and as much of the results table as fits in between.
+func (t *benchmarkTUI) lines(height int) []string {
+	status := "Press q to quit."
+	if t.quit {
+		status = "Quitting after the current run..."
+	} else if t.current != "" {
+		status = "Running " + t.current + ". Press q to quit."
+	}
+	top := []string{status, progressLine(t.finished, t.total, time.Since(t.start)), ""}
+
+	var bottom []string
+	if len(t.errors) > 0 {
+		errs := t.errors
+		if len(errs) > maxTUIErrorLines {
+			errs = errs[len(errs)-maxTUIErrorLines:]
+		}
+		bottom = append([]string{"", fmt.Sprintf("Recent errors (%d):", len(t.errors))}, errs...)
+	}
+
+	results := t.results
+	if room := height - len(top) - len(bottom); len(results) > room {
+		if room < 0 {
+			room = 0
+		}
+		results = results[:room]
+	}
+	return append(append(top, results...), bottom...)
+}
+
+func (t *benchmarkTUI) draw() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	t.screen.Clear()
+	width, height := t.screen.Size()
+	for y, line := range t.lines(height) {
+		if y >= height {
+			break
+		}
+		x := 0
+		for _, r := range line {
+			if x >= width {
+				break
+			}
+			t.screen.SetContent(x, y, r, nil, tcell.StyleDefault)
+			x++
+		}
+	}
+	t.screen.Show()
+}
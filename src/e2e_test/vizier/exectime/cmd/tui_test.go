@@ -0,0 +1,97 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func screenText(s tcell.SimulationScreen) string {
+	cells, width, _ := s.GetContents()
+	var b strings.Builder
+	for i, c := range cells {
+		if len(c.Runes) > 0 {
+			b.WriteRune(c.Runes[0])
+		}
+		if (i+1)%width == 0 {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+func TestBenchmarkTUI_ShowsResultsAndErrors(t *testing.T) {
+	screen := tcell.NewSimulationScreen("")
+	ep := &benchmarkEndpoint{data: map[string]*ScriptExecData{
+		"px/cluster": newScriptExecData("px/cluster"),
+		"px/pods":    newScriptExecData("px/pods"),
+	}}
+	tui, err := newBenchmarkTUI(screen, []*benchmarkEndpoint{ep}, 4)
+	require.NoError(t, err)
+	defer tui.close()
+	screen.SetSize(200, 40)
+
+	tui.startRun(ep, "px/cluster", 0)
+	assert.Contains(t, screenText(screen), "Running px/cluster (run 0)")
+	recordResults(ep.data["px/cluster"], &execResults{externalExecTime: time.Second})
+	tui.finishRun(ep, "px/cluster")
+
+	tui.startRun(ep, "px/pods", 1)
+	recordResults(ep.data["px/pods"], &execResults{scriptErr: errors.New("kelvin went away")})
+	tui.finishRun(ep, "px/pods")
+
+	text := screenText(screen)
+	assert.Contains(t, text, "2/4 runs, 50%")
+	assert.Contains(t, text, "px/cluster")
+	assert.Contains(t, text, "Recent errors (1):")
+	assert.Contains(t, text, "px/pods: Unknown: kelvin went away")
+}
+
+func TestBenchmarkTUI_Quit(t *testing.T) {
+	screen := tcell.NewSimulationScreen("")
+	tui, err := newBenchmarkTUI(screen, nil, 1)
+	require.NoError(t, err)
+	defer tui.close()
+
+	assert.False(t, tui.quitting())
+	screen.InjectKey(tcell.KeyRune, 'q', tcell.ModNone)
+	assert.Eventually(t, tui.quitting, time.Second, time.Millisecond)
+}
+
+func TestBenchmarkTUI_NilDoesNothing(t *testing.T) {
+	var tui *benchmarkTUI
+	tui.startRun(nil, "px/cluster", 0)
+	tui.finishRun(nil, "px/cluster")
+	tui.addRuns(1)
+	assert.False(t, tui.quitting())
+	tui.close()
+}
+
+func TestProgressLine(t *testing.T) {
+	assert.Equal(t, "["+strings.Repeat("#", 10)+strings.Repeat(".", 30)+"] 1/4 runs, 25%, ETA 30s",
+		progressLine(1, 4, 10*time.Second))
+	assert.Contains(t, progressLine(0, 4, 0), "ETA unknown")
+}
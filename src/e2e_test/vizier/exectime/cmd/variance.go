@@ -0,0 +1,68 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+)
+
+// relativeStddev returns the stddev of the external exec time of the script's runs, relative to its mean. It
+// returns false for scripts without external exec times, such as the streamed ones.
+func relativeStddev(d *ScriptExecData) (float64, bool) {
+	dist, ok := d.Distributions[execTimeExternalLabel].(*TimeDistribution)
+	if !ok || len(dist.Times) < 2 || dist.Mean() == 0 {
+		return 0, false
+	}
+	return float64(dist.Stddev()) / float64(dist.Mean()), true
+}
+
+// markNoisy records the relative stddev of every script's external exec time, marks the scripts whose relative
+// stddev exceeds maxVariance as noisy, and returns their names, sorted.
+func markNoisy(data map[string]*ScriptExecData, maxVariance float64) []string {
+	var noisy []string
+	for name, d := range data {
+		v, ok := relativeStddev(d)
+		if !ok {
+			continue
+		}
+		d.RelativeStddev = v
+		d.Noisy = v > maxVariance
+		if d.Noisy {
+			noisy = append(noisy, name)
+		}
+	}
+	sort.Strings(noisy)
+	return noisy
+}
+
+// writeNoisyScripts notes the scripts with noisy results, under the endpoint's table.
+func writeNoisyScripts(ep *benchmarkEndpoint, maxVariance float64) {
+	for _, d := range sortByKeys(&ep.data) {
+		if !d.Noisy {
+			continue
+		}
+		retried := ""
+		if d.Retried {
+			retried = ", after a retry"
+		}
+		fmt.Printf("Noisy results for %s: relative stddev of %s is %.2f, above %.2f%s\n",
+			d.Name, execTimeExternalLabel, d.RelativeStddev, maxVariance, retried)
+	}
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkNoisy(t *testing.T) {
+	steady := newScriptExecData("px/steady")
+	for _, d := range []time.Duration{100, 101, 99, 100} {
+		steady.Distributions[execTimeExternalLabel].Append(d * time.Millisecond)
+	}
+	noisy := newScriptExecData("px/noisy")
+	for _, d := range []time.Duration{100, 300, 50, 500} {
+		noisy.Distributions[execTimeExternalLabel].Append(d * time.Millisecond)
+	}
+	// Streamed scripts have no external exec time, so their variance can't be checked.
+	streamed := newStreamedScriptExecData("px/stream")
+
+	data := map[string]*ScriptExecData{
+		steady.Name:   steady,
+		noisy.Name:    noisy,
+		streamed.Name: streamed,
+	}
+	assert.Equal(t, []string{"px/noisy"}, markNoisy(data, 0.35))
+	assert.False(t, steady.Noisy)
+	assert.Less(t, steady.RelativeStddev, 0.35)
+	assert.True(t, noisy.Noisy)
+	assert.Greater(t, noisy.RelativeStddev, 0.35)
+	assert.False(t, streamed.Noisy)
+}
@@ -0,0 +1,50 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// warmUp runs each of the named scripts n times through every endpoint that has it, before the measured runs,
+// so that the first measured run doesn't pay for compilation cache misses and connection setup. The results
+// of the warmup runs are discarded, and their failures are only logged. Returns the number of failed warmup
+// runs.
+func warmUp(endpoints []*benchmarkEndpoint, names []string, n int, run func(ep *benchmarkEndpoint, name string) error) int {
+	if n <= 0 {
+		return 0
+	}
+	log.Infof("Warming up %d scripts with %d runs each", len(names), n)
+	numFailed := 0
+	for _, name := range names {
+		for _, ep := range endpoints {
+			if _, ok := ep.scripts[name]; !ok {
+				continue
+			}
+			for i := 0; i < n; i++ {
+				if err := run(ep, name); err != nil {
+					log.WithError(err).WithField("script", name).WithField("cloud_addr", ep.cloudAddr).
+						WithField("warmup_run", i).Warn("Warmup run failed")
+					numFailed++
+				}
+			}
+		}
+	}
+	return numFailed
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"px.dev/pixie/src/utils/script"
+)
+
+func TestWarmUp(t *testing.T) {
+	newEndpoint := func(cloudAddr string, names ...string) *benchmarkEndpoint {
+		ep := &benchmarkEndpoint{
+			cloudAddr: cloudAddr,
+			scripts:   make(map[string]*script.ExecutableScript),
+			data:      make(map[string]*ScriptExecData),
+		}
+		for _, name := range names {
+			ep.scripts[name] = &script.ExecutableScript{ScriptName: name}
+			ep.data[name] = newScriptExecData(name)
+		}
+		return ep
+	}
+	a := newEndpoint("a", "px/cluster", "px/http_data")
+	// Only the first endpoint has the script, such as when it is missing from the other cloud's bundle.
+	b := newEndpoint("b", "px/cluster")
+
+	runs := make(map[string]int)
+	numFailed := warmUp([]*benchmarkEndpoint{a, b}, []string{"px/cluster", "px/http_data"}, 2,
+		func(ep *benchmarkEndpoint, name string) error {
+			runs[ep.cloudAddr+" "+name]++
+			if name == "px/http_data" {
+				return errors.New("compile error")
+			}
+			return nil
+		})
+	assert.Equal(t, map[string]int{"a px/cluster": 2, "b px/cluster": 2, "a px/http_data": 2}, runs)
+	assert.Equal(t, 2, numFailed)
+	// The failed warmups aren't counted in the results.
+	assert.Empty(t, a.data["px/http_data"].Distributions[numErrorsLabel].(*ErrorDistribution).Errors)
+	assert.Empty(t, a.data["px/http_data"].Failures)
+}
+
+func TestWarmUp_NoRuns(t *testing.T) {
+	called := false
+	assert.Equal(t, 0, warmUp([]*benchmarkEndpoint{{}}, []string{"px/cluster"}, 0, func(*benchmarkEndpoint, string) error {
+		called = true
+		return nil
+	}))
+	assert.False(t, called)
+}
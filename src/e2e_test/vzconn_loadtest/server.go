@@ -19,6 +19,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"time"
@@ -92,10 +93,26 @@ func (f *fakeVZInfo) GetJob(name string) (*batchv1.Job, error) {
 	return nil, nil
 }
 
-func (f *fakeVZInfo) GetClusterUID() (string, error) {
+func (f *fakeVZInfo) GetClusterUID(context.Context) (string, error) {
 	return f.clusterUID.String(), nil
 }
 
+func (f *fakeVZInfo) SelfTest(context.Context) *controllers.SelfTestReport {
+	return &controllers.SelfTestReport{}
+}
+
+func (f *fakeVZInfo) ForceUpdate(context.Context) (*controllers.K8sState, error) {
+	return f.GetK8sState(), nil
+}
+
+func (f *fakeVZInfo) GetPodStatusChangesSince(revision int64) *controllers.PodStatusDelta {
+	return &controllers.PodStatusDelta{FromRevision: revision, Resync: true}
+}
+
+func (f *fakeVZInfo) RecordStatusDelivered(time.Time) {}
+
+func (f *fakeVZInfo) RecordConnected(time.Time) {}
+
 func (f *fakeVZInfo) GetClusterID() (string, error) {
 	return f.vzID, nil
 }
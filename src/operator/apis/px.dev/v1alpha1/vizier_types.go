@@ -134,6 +134,37 @@ type VizierStatus struct {
 	Checksum []byte `json:"checksum,omitempty"`
 	// OperatorVersion is the actual version of the Operator instance.
 	OperatorVersion string `json:"operatorVersion,omitempty"`
+	// ConnectorStatus is the detailed state of the Vizier, as observed by the cloud connector.
+	ConnectorStatus *VizierConnectorStatus `json:"connectorStatus,omitempty"`
+}
+
+// VizierConnectorStatus is the detailed state of the Vizier, as observed by the cloud connector. It is
+// written by the cloud connector, separately from the rest of the status which is owned by the operator.
+type VizierConnectorStatus struct {
+	// Health is the aggregate health of the Vizier computed from its components.
+	Health string `json:"health,omitempty"`
+	// Reasons describes why the Vizier is not healthy.
+	Reasons []string `json:"reasons,omitempty"`
+	// Components summarizes the state of the Vizier pods tracked by the cloud connector.
+	Components []VizierComponentStatus `json:"components,omitempty"`
+	// LastUpdated is the last time the cloud connector observed this state.
+	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
+}
+
+// VizierComponentStatus is a summary of the state of a single Vizier pod.
+type VizierComponentStatus struct {
+	// Name is the name of the pod.
+	Name string `json:"name"`
+	// Phase is the phase of the pod.
+	Phase string `json:"phase,omitempty"`
+	// Reason is a short string describing why the pod is not running, if it isn't.
+	Reason string `json:"reason,omitempty"`
+	// RestartCount is the number of times the pod's containers have restarted.
+	RestartCount int64 `json:"restartCount,omitempty"`
+	// StartTime is when the pod was started, as reported by the API server.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// LastRestartTime is when the most recently restarted container of the pod started running again.
+	LastRestartTime *metav1.Time `json:"lastRestartTime,omitempty"`
 }
 
 // VizierPhase is a high-level summary of where the Vizier is in its lifecycle.
@@ -130,6 +130,60 @@ func (in *Vizier) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VizierComponentStatus) DeepCopyInto(out *VizierComponentStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastRestartTime != nil {
+		in, out := &in.LastRestartTime, &out.LastRestartTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VizierComponentStatus.
+func (in *VizierComponentStatus) DeepCopy() *VizierComponentStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VizierComponentStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VizierConnectorStatus) DeepCopyInto(out *VizierConnectorStatus) {
+	*out = *in
+	if in.Reasons != nil {
+		in, out := &in.Reasons, &out.Reasons
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Components != nil {
+		in, out := &in.Components, &out.Components
+		*out = make([]VizierComponentStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastUpdated != nil {
+		in, out := &in.LastUpdated, &out.LastUpdated
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VizierConnectorStatus.
+func (in *VizierConnectorStatus) DeepCopy() *VizierConnectorStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VizierConnectorStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VizierList) DeepCopyInto(out *VizierList) {
 	*out = *in
@@ -211,6 +265,11 @@ func (in *VizierStatus) DeepCopyInto(out *VizierStatus) {
 		*out = make([]byte, len(*in))
 		copy(*out, *in)
 	}
+	if in.ConnectorStatus != nil {
+		in, out := &in.ConnectorStatus, &out.ConnectorStatus
+		*out = new(VizierConnectorStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VizierStatus.
@@ -29,6 +29,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -55,6 +56,12 @@ var localServerRedirectURL = "http://localhost:8085/auth_complete"
 var localServerPort = int32(8085)
 var sentSegmentAlias = false
 
+var (
+	cloudCredentialsMu sync.Mutex
+	// The credentials to use for specific clouds, instead of the default credentials. Keyed by cloud address.
+	cloudCredentials = make(map[string]*RefreshToken)
+)
+
 // SaveRefreshToken saves the refresh token in default spot.
 func SaveRefreshToken(token *RefreshToken) error {
 	pixieAuthFilePath, err := utils.EnsureDefaultAuthFilePath()
@@ -71,13 +78,9 @@ func SaveRefreshToken(token *RefreshToken) error {
 	return json.NewEncoder(f).Encode(token)
 }
 
-// LoadDefaultCredentials loads the default credentials for the user.
-func LoadDefaultCredentials() (*RefreshToken, error) {
-	pixieAuthFilePath, err := utils.EnsureDefaultAuthFilePath()
-	if err != nil {
-		return nil, err
-	}
-	f, err := os.Open(pixieAuthFilePath)
+// LoadCredentials loads the credentials from the given auth file.
+func LoadCredentials(authFilePath string) (*RefreshToken, error) {
+	f, err := os.Open(authFilePath)
 	if err != nil {
 		return nil, err
 	}
@@ -87,6 +90,19 @@ func LoadDefaultCredentials() (*RefreshToken, error) {
 	if err := json.NewDecoder(f).Decode(token); err != nil {
 		return nil, err
 	}
+	return token, nil
+}
+
+// LoadDefaultCredentials loads the default credentials for the user.
+func LoadDefaultCredentials() (*RefreshToken, error) {
+	pixieAuthFilePath, err := utils.EnsureDefaultAuthFilePath()
+	if err != nil {
+		return nil, err
+	}
+	token, err := LoadCredentials(pixieAuthFilePath)
+	if err != nil {
+		return nil, err
+	}
 
 	if parsed, _ := jwt.Parse([]byte(token.Token)); parsed != nil {
 		userID := srvutils.GetUserID(parsed)
@@ -157,6 +173,27 @@ func CtxWithCreds(ctx context.Context) context.Context {
 	return ctxWithCreds
 }
 
+// SetCloudCredentials makes requests to the given cloud use the given credentials, instead of the default
+// credentials. This lets a single process talk to clouds that the user is logged into separately.
+func SetCloudCredentials(cloudAddr string, token *RefreshToken) {
+	cloudCredentialsMu.Lock()
+	defer cloudCredentialsMu.Unlock()
+	cloudCredentials[cloudAddr] = token
+}
+
+// CtxWithCredsForCloud returns a context with the credentials set for the given cloud, or else with the
+// default credentials for the user, in which case a lack of credentials will cause an os.Exit.
+func CtxWithCredsForCloud(ctx context.Context, cloudAddr string) context.Context {
+	cloudCredentialsMu.Lock()
+	creds, ok := cloudCredentials[cloudAddr]
+	cloudCredentialsMu.Unlock()
+	if !ok {
+		return CtxWithCreds(ctx)
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization",
+		fmt.Sprintf("bearer %s", creds.Token))
+}
+
 // PixieCloudLogin performs login on the pixie cloud.
 type PixieCloudLogin struct {
 	ManualMode bool
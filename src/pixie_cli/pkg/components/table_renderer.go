@@ -53,7 +53,7 @@ func CreateStreamWriter(format string, w io.Writer) OutputStreamWriter {
 		return NewTableStreamWriter(w)
 	case "csv":
 		return NewCSVStreamWriter(w)
-	case "null":
+	case "null", "countonly":
 		return &NullStreamWriter{}
 	case "inmemory":
 		return NewTableAccumulator()
@@ -32,6 +32,7 @@ import (
 	"github.com/gofrs/uuid"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
@@ -46,7 +47,7 @@ import (
 )
 
 const (
-	dialTimeout = 5 * time.Second
+	defaultDialTimeout = 5 * time.Second
 	// We need an extra long retryTimeout because in passthrough mode the query broker
 	// takes a while to receive the cancel message from the ptproxy.
 	retryTimeout        = 30 * time.Second
@@ -63,11 +64,51 @@ type Connector struct {
 	cloudAddr    string
 	directVzAddr string
 	directVzKey  string
+	// The largest message, in bytes, that the connector accepts. 0 leaves gRPC's default.
+	maxMessageSize int
+	// How long to wait to connect.
+	dialTimeout time.Duration
+	// The keepalive of the connection. Disabled if keepaliveTime is 0.
+	keepaliveTime    time.Duration
+	keepaliveTimeout time.Duration
+}
+
+// ConnectorOption configures a Connector.
+type ConnectorOption func(*Connector)
+
+// WithMaxMessageSize sets the largest message, in bytes, that the connector accepts. Scripts with large result
+// tables can send batches beyond gRPC's default limit. 0 leaves the default.
+func WithMaxMessageSize(bytes int) ConnectorOption {
+	return func(c *Connector) {
+		c.maxMessageSize = bytes
+	}
+}
+
+// WithDialTimeout sets how long the connector waits to connect. 0 leaves the default of 5s.
+func WithDialTimeout(timeout time.Duration) ConnectorOption {
+	return func(c *Connector) {
+		if timeout > 0 {
+			c.dialTimeout = timeout
+		}
+	}
+}
+
+// WithKeepalive makes the connector ping the vizier after each interval of inactivity, and close the connection
+// if a ping isn't acknowledged within timeout. The pings are sent between RPCs too, which keeps NATs from dropping
+// the connection during long gaps. An interval of 0 disables the pings, which is the default.
+func WithKeepalive(interval, timeout time.Duration) ConnectorOption {
+	return func(c *Connector) {
+		c.keepaliveTime = interval
+		c.keepaliveTimeout = timeout
+	}
 }
 
 // NewConnector returns a new connector.
-func NewConnector(cloudAddr string, vzInfo *cloudpb.ClusterInfo, directVzAddr string, directVzKey string) (*Connector, error) {
-	c := &Connector{}
+func NewConnector(cloudAddr string, vzInfo *cloudpb.ClusterInfo, directVzAddr string, directVzKey string, opts ...ConnectorOption) (*Connector, error) {
+	c := &Connector{dialTimeout: defaultDialTimeout}
+	for _, opt := range opts {
+		opt(c)
+	}
 	if vzInfo != nil {
 		c.id = utils.UUIDFromProtoOrNil(vzInfo.ID)
 	}
@@ -90,9 +131,14 @@ func NewConnector(cloudAddr string, vzInfo *cloudpb.ClusterInfo, directVzAddr st
 	return c, nil
 }
 
+// ID returns the ID of the Vizier that this connector is connected to.
+func (c *Connector) ID() uuid.UUID {
+	return c.id
+}
+
 // Connect connects to Vizier (blocking)
 func (c *Connector) connect(addr string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), c.dialTimeout)
 	defer cancel()
 
 	// Cancel dial on ctrl-c. Otherwise, it just hangs.
@@ -109,7 +155,18 @@ func (c *Connector) connect(addr string) error {
 		return err
 	}
 
-	dialOpts = append(dialOpts, grpc.WithBlock())
+	// Count the wire bytes of the RPCs whose contexts ask for it.
+	dialOpts = append(dialOpts, grpc.WithBlock(), grpc.WithStatsHandler(wireBytesHandler{}))
+	if c.maxMessageSize > 0 {
+		dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(c.maxMessageSize)))
+	}
+	if c.keepaliveTime > 0 {
+		dialOpts = append(dialOpts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                c.keepaliveTime,
+			Timeout:             c.keepaliveTimeout,
+			PermitWithoutStream: true,
+		}))
+	}
 	// Try to dial with a time out (ctrl-c can be used to cancel)
 	conn, err := grpc.DialContext(ctx, addr, dialOpts...)
 	if err != nil {
@@ -278,7 +335,12 @@ func (c *Connector) handleStream(ctx context.Context, state *streamState, first
 				return doNotRetry
 			}
 
-			state.results <- &ExecData{ClusterID: c.id, Resp: msg, Err: err}
+			// Nothing reads the results once the context is done, so don't block on sending them.
+			select {
+			case state.results <- &ExecData{ClusterID: c.id, Resp: msg, Err: err}:
+			case <-ctx.Done():
+				return doNotRetry
+			}
 			if err != nil || msg == nil {
 				return doNotRetry
 			}
@@ -319,7 +381,7 @@ func (c *Connector) ExecuteScriptStream(ctx context.Context, script *script.Exec
 	if c.directVzAddr != "" {
 		ctx = metadata.AppendToOutgoingContext(ctx, "X-DIRECT-VIZIER-KEY", c.directVzKey)
 	} else {
-		ctx = auth.CtxWithCreds(ctx)
+		ctx = auth.CtxWithCredsForCloud(ctx, c.cloudAddr)
 	}
 
 	resp, err := c.vz.ExecuteScript(ctx, reqPB)
@@ -339,7 +401,11 @@ func (c *Connector) ExecuteScriptStream(ctx context.Context, script *script.Exec
 		shouldRetry := c.handleStream(ctx, s, true)
 		for shouldRetry {
 			// Wait some time between retries since the query might not be paused immediately on the query broker side after a failure.
-			time.Sleep(sleepBetweenRetries)
+			select {
+			case <-time.After(sleepBetweenRetries):
+			case <-ctx.Done():
+				return
+			}
 			if !s.lastSuccessfulRetry.IsZero() && time.Since(s.lastSuccessfulRetry) > retryTimeout {
 				if s.firstErr != nil {
 					cliUtils.Errorf("Timedout trying to restart the connection after error: %s", s.firstErr.Error())
@@ -372,7 +438,7 @@ func (c *Connector) DebugLogRequest(ctx context.Context, podName string, prev bo
 		Previous:  prev,
 		Container: container,
 	}
-	ctx = auth.CtxWithCreds(ctx)
+	ctx = auth.CtxWithCredsForCloud(ctx, c.cloudAddr)
 	resp, err := c.vzDebug.DebugLog(ctx, reqPB)
 	if err != nil {
 		return nil, err
@@ -419,12 +485,41 @@ type DebugPodsResponse struct {
 	Err              error
 }
 
+// HealthCheck sends a health check request and waits for the first response. It returns an error if the
+// request fails, or if Vizier reports that it is unhealthy.
+func (c *Connector) HealthCheck(ctx context.Context) error {
+	// The health check streams responses until it is canceled.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	reqPB := &vizierpb.HealthCheckRequest{
+		ClusterID: c.id.String(),
+	}
+	if c.directVzAddr != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "X-DIRECT-VIZIER-KEY", c.directVzKey)
+	} else {
+		ctx = auth.CtxWithCredsForCloud(ctx, c.cloudAddr)
+	}
+	resp, err := c.vz.HealthCheck(ctx, reqPB)
+	if err != nil {
+		return err
+	}
+	msg, err := resp.Recv()
+	if err != nil {
+		return err
+	}
+	if msg.Status != nil && msg.Status.Code != 0 {
+		return status.Error(codes.Code(msg.Status.Code), msg.Status.Message)
+	}
+	return nil
+}
+
 // DebugPodsRequest sends a debug pods request and returns data in a chan.
 func (c *Connector) DebugPodsRequest(ctx context.Context) (chan *DebugPodsResponse, error) {
 	reqPB := &vizierpb.DebugPodsRequest{
 		ClusterID: c.id.String(),
 	}
-	ctx = auth.CtxWithCreds(ctx)
+	ctx = auth.CtxWithCredsForCloud(ctx, c.cloudAddr)
 	resp, err := c.vzDebug.DebugPods(ctx, reqPB)
 	if err != nil {
 		return nil, err
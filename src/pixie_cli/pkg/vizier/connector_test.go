@@ -0,0 +1,45 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package vizier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectorOptions(t *testing.T) {
+	c := &Connector{dialTimeout: defaultDialTimeout}
+	for _, opt := range []ConnectorOption{
+		WithMaxMessageSize(64 << 20),
+		WithDialTimeout(0),
+		WithKeepalive(time.Minute, 20*time.Second),
+	} {
+		opt(c)
+	}
+	assert.Equal(t, 64<<20, c.maxMessageSize)
+	// A dial timeout of 0 keeps the default.
+	assert.Equal(t, defaultDialTimeout, c.dialTimeout)
+	assert.Equal(t, time.Minute, c.keepaliveTime)
+	assert.Equal(t, 20*time.Second, c.keepaliveTimeout)
+
+	WithDialTimeout(time.Second)(c)
+	assert.Equal(t, time.Second, c.dialTimeout)
+}
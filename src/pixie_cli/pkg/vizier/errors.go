@@ -22,6 +22,9 @@ import (
 	"strings"
 
 	"github.com/fatih/color"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
 )
 
 // ErrorCode is the base type for vizier error codes.
@@ -47,6 +50,10 @@ type ScriptExecutionError struct {
 	code           ErrorCode
 	s              string
 	compilerErrors []string
+	// The status that Vizier failed the script with, if any.
+	status *vizierpb.Status
+	// The status of the failed gRPC call, if any.
+	rpcStatus *status.Status
 }
 
 // Error returns the errors message.
@@ -64,6 +71,18 @@ func (s *ScriptExecutionError) CompilerErrors() []string {
 	return s.compilerErrors
 }
 
+// Status returns the status that Vizier failed the script with, including its error details, or nil if the
+// error did not come from Vizier.
+func (s *ScriptExecutionError) Status() *vizierpb.Status {
+	return s.status
+}
+
+// RPCStatus returns the status of the failed gRPC call, including its details, or nil if the error did not
+// come from a gRPC call.
+func (s *ScriptExecutionError) RPCStatus() *status.Status {
+	return s.rpcStatus
+}
+
 // GetErrorCode gets the error code for vizier errors.
 func GetErrorCode(err error) ErrorCode {
 	if e, ok := err.(*ScriptExecutionError); ok {
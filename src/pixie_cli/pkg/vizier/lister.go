@@ -30,7 +30,8 @@ import (
 
 // Lister allows fetching information about Viziers from the cloud.
 type Lister struct {
-	vc cloudpb.VizierClusterInfoClient
+	vc        cloudpb.VizierClusterInfoClient
+	cloudAddr string
 }
 
 // NewLister returns a Lister.
@@ -39,12 +40,12 @@ func NewLister(cloudAddr string) (*Lister, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Lister{vc: vc}, nil
+	return &Lister{vc: vc, cloudAddr: cloudAddr}, nil
 }
 
 // GetViziersInfo returns information about connected viziers.
 func (l *Lister) GetViziersInfo() ([]*cloudpb.ClusterInfo, error) {
-	ctx := auth.CtxWithCreds(context.Background())
+	ctx := auth.CtxWithCredsForCloud(context.Background(), l.cloudAddr)
 
 	c, err := l.vc.GetClusterInfo(ctx, &cloudpb.GetClusterInfoRequest{})
 	if err != nil {
@@ -55,7 +56,7 @@ func (l *Lister) GetViziersInfo() ([]*cloudpb.ClusterInfo, error) {
 
 // GetVizierInfo returns information about a connected vizier.
 func (l *Lister) GetVizierInfo(id uuid.UUID) ([]*cloudpb.ClusterInfo, error) {
-	ctx := auth.CtxWithCreds(context.Background())
+	ctx := auth.CtxWithCredsForCloud(context.Background(), l.cloudAddr)
 	clusterIDPb := utils.ProtoFromUUID(id)
 
 	c, err := l.vc.GetClusterInfo(ctx, &cloudpb.GetClusterInfoRequest{ID: clusterIDPb})
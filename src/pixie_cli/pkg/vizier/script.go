@@ -195,7 +195,9 @@ func runScript(ctx context.Context, conns []*Connector, execScript *script.Execu
 	return tw, err
 }
 
-// RunScript runs the script and return the data channel
+// RunScript runs the script and return the data channel. Once ctx is done, the channel is closed after the
+// streams from every Vizier have shut down, so callers that stop reading early can cancel ctx and drain the
+// channel to wait for the cleanup.
 func RunScript(ctx context.Context, conns []*Connector, execScript *script.ExecutableScript, encOpts *vizierpb.ExecuteScriptRequest_EncryptionOptions) (chan *ExecData, error) {
 	// TODO(zasgar): Refactor this when we change to the new API to make analytics cleaner.
 	_ = pxanalytics.Client().Enqueue(&analytics.Track{
@@ -222,7 +224,14 @@ func RunScript(ctx context.Context, conns []*Connector, execScript *script.Execu
 
 		eg.Go(func() error {
 			for v := range resp {
-				mergedResponses <- v
+				select {
+				case mergedResponses <- v:
+				case <-ctx.Done():
+					// The responses aren't read anymore. Wait for the stream to shut down.
+					for range resp {
+					}
+					return ctx.Err()
+				}
 				if v.Err != nil && v.Err == io.EOF {
 					return nil
 				}
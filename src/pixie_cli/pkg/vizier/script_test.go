@@ -0,0 +1,116 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package vizier
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/pixie_cli/pkg/auth"
+	"px.dev/pixie/src/utils/script"
+)
+
+const testCloudAddr = "test.withpixie.dev:443"
+
+// endlessVizierServer streams row batches for every script until the client goes away.
+type endlessVizierServer struct {
+	vizierpb.UnimplementedVizierServiceServer
+}
+
+func (s *endlessVizierServer) ExecuteScript(req *vizierpb.ExecuteScriptRequest, srv vizierpb.VizierService_ExecuteScriptServer) error {
+	err := srv.Send(&vizierpb.ExecuteScriptResponse{
+		QueryID: "query-1",
+		Result: &vizierpb.ExecuteScriptResponse_MetaData{MetaData: &vizierpb.QueryMetadata{
+			Name: "output",
+			ID:   "table-1",
+			Relation: &vizierpb.Relation{Columns: []*vizierpb.Relation_ColumnInfo{
+				{ColumnName: "count", ColumnType: vizierpb.INT64},
+			}},
+		}},
+	})
+	for err == nil {
+		err = srv.Send(&vizierpb.ExecuteScriptResponse{
+			QueryID: "query-1",
+			Result: &vizierpb.ExecuteScriptResponse_Data{Data: &vizierpb.QueryData{
+				Batch: &vizierpb.RowBatchData{
+					TableID: "table-1",
+					Cols: []*vizierpb.Column{{
+						ColData: &vizierpb.Column_Int64Data{Int64Data: &vizierpb.Int64Column{Data: []int64{1}}},
+					}},
+				},
+			}},
+		})
+	}
+	return err
+}
+
+func TestRunScript_TimeoutsDontLeak(t *testing.T) {
+	viper.Set("do_not_track", true)
+	auth.SetCloudCredentials(testCloudAddr, &auth.RefreshToken{Token: "test-token"})
+
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer()
+	vizierpb.RegisterVizierServiceServer(s, &endlessVizierServer{})
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	defer s.Stop()
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	c := &Connector{conn: conn, vz: vizierpb.NewVizierServiceClient(conn), cloudAddr: testCloudAddr}
+
+	// Like the exec time benchmark, give up on each run after a timeout, without reading the rest of its stream.
+	run := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		resp, err := RunScript(ctx, []*Connector{c}, &script.ExecutableScript{ScriptString: "px.display(df)"}, nil)
+		require.NoError(t, err)
+		tw := NewStreamOutputAdapter(ctx, resp, FormatInMemory, nil)
+		err = tw.Finish()
+		require.Error(t, err)
+		assert.Equal(t, CodeTimeout, err.(*ScriptExecutionError).Code())
+	}
+
+	// The first run starts the goroutines of the connection, which outlive the runs.
+	run()
+	time.Sleep(100 * time.Millisecond)
+	ignoreConn := goleak.IgnoreCurrent()
+
+	for i := 0; i < 100; i++ {
+		run()
+	}
+	goleak.VerifyNone(t, ignoreConn)
+}
@@ -78,6 +78,52 @@ type StreamOutputAdapter struct {
 	err error
 
 	totalBytes int
+
+	// Called for every batch of rows received, if set.
+	onRowBatch RowBatchCallback
+	// Called for every mutation info received, if set.
+	onMutationInfo MutationInfoCallback
+	// Called for every message received, if set.
+	onMessage MessageCallback
+}
+
+// RowBatchCallback is called with the number of rows in a batch, and when the message carrying the batch was
+// received. It is called from the goroutine that handles the stream, so it must not block.
+type RowBatchCallback func(numRows int, receivedAt time.Time)
+
+// MutationInfoCallback is called with the mutation info of a script, as it is received. It is called from the
+// goroutine that handles the stream, so it must not block.
+type MutationInfoCallback func(mi *vizierpb.MutationInfo)
+
+// MessageCallback is called with every message of the stream, as it is received, before it is handled. It is
+// called from the goroutine that handles the stream, so it must not block.
+type MessageCallback func(msg *ExecData)
+
+// StreamOutputAdapterOption configures a StreamOutputAdapter.
+type StreamOutputAdapterOption func(*StreamOutputAdapter)
+
+// WithRowBatchCallback sets a callback that is called for every non-empty batch of rows, as it is received.
+// This shows how the results are streamed, which Finish alone does not.
+func WithRowBatchCallback(cb RowBatchCallback) StreamOutputAdapterOption {
+	return func(v *StreamOutputAdapter) {
+		v.onRowBatch = cb
+	}
+}
+
+// WithMessageCallback sets a callback that is called for every message of the stream, as it is received. This
+// lets the caller keep the raw messages, without paying for them to be formatted.
+func WithMessageCallback(cb MessageCallback) StreamOutputAdapterOption {
+	return func(v *StreamOutputAdapter) {
+		v.onMessage = cb
+	}
+}
+
+// WithMutationInfoCallback sets a callback that is called for every mutation info, as it is received. This
+// shows when the mutations of a script become ready, without waiting for the query that follows them.
+func WithMutationInfoCallback(cb MutationInfoCallback) StreamOutputAdapterOption {
+	return func(v *StreamOutputAdapter) {
+		v.onMutationInfo = cb
+	}
 }
 
 var (
@@ -90,11 +136,16 @@ var (
 // FormatInMemory denotes the inmemory format.
 const FormatInMemory string = "inmemory"
 
+// FormatCountOnly denotes a format that counts the rows without decoding them, which keeps the allocations
+// for accumulating the results from skewing measurements of script execution.
+const FormatCountOnly string = "countonly"
+
 // NewStreamOutputAdapterWithFactory creates a new vizier output adapter factory.
 func NewStreamOutputAdapterWithFactory(ctx context.Context, stream chan *ExecData, format string,
 	decOpts *vizierpb.ExecuteScriptRequest_EncryptionOptions,
-	factoryFunc func(*vizierpb.ExecuteScriptResponse_MetaData) components.OutputStreamWriter) *StreamOutputAdapter {
-	enableFormat := format != "json" && format != FormatInMemory
+	factoryFunc func(*vizierpb.ExecuteScriptResponse_MetaData) components.OutputStreamWriter,
+	opts ...StreamOutputAdapterOption) *StreamOutputAdapter {
+	enableFormat := format != "json" && format != FormatInMemory && format != FormatCountOnly
 
 	adapter := &StreamOutputAdapter{
 		tableNameToInfo:     make(map[string]*TableInfo),
@@ -105,6 +156,9 @@ func NewStreamOutputAdapterWithFactory(ctx context.Context, stream chan *ExecDat
 		tabledIDToName:      make(map[string]string),
 		decOpts:             decOpts,
 	}
+	for _, opt := range opts {
+		opt(adapter)
+	}
 
 	adapter.wg.Add(1)
 	go adapter.handleStream(ctx, stream)
@@ -113,11 +167,12 @@ func NewStreamOutputAdapterWithFactory(ctx context.Context, stream chan *ExecDat
 }
 
 // NewStreamOutputAdapter creates a new vizier output adapter.
-func NewStreamOutputAdapter(ctx context.Context, stream chan *ExecData, format string, decOpts *vizierpb.ExecuteScriptRequest_EncryptionOptions) *StreamOutputAdapter {
+func NewStreamOutputAdapter(ctx context.Context, stream chan *ExecData, format string, decOpts *vizierpb.ExecuteScriptRequest_EncryptionOptions,
+	opts ...StreamOutputAdapterOption) *StreamOutputAdapter {
 	factoryFunc := func(md *vizierpb.ExecuteScriptResponse_MetaData) components.OutputStreamWriter {
 		return components.CreateStreamWriter(format, os.Stdout)
 	}
-	return NewStreamOutputAdapterWithFactory(ctx, stream, format, decOpts, factoryFunc)
+	return NewStreamOutputAdapterWithFactory(ctx, stream, format, decOpts, factoryFunc, opts...)
 }
 
 // Finish must be called to wait for the output and flush all the data.
@@ -143,7 +198,7 @@ func (v *StreamOutputAdapter) WaitForCompletion() error {
 	return nil
 }
 
-// ExecStats returns the reported execution stats. This function is only valid with format = inmemory and after Finish.
+// ExecStats returns the reported execution stats. This function is only valid with format = inmemory or countonly and after Finish.
 func (v *StreamOutputAdapter) ExecStats() (*vizierpb.QueryExecutionStats, error) {
 	if v.execStats == nil {
 		return nil, fmt.Errorf("ExecStats not found")
@@ -214,16 +269,27 @@ func (v *StreamOutputAdapter) handleStream(ctx context.Context, stream chan *Exe
 			}
 			return
 		case msg := <-stream:
+			// Both cases may be ready once the context is done, and the stream is closed soon after. Report
+			// the context's error, rather than handling the rest of the stream.
+			if ctx.Err() != nil {
+				continue
+			}
 			if msg == nil {
 				return
 			}
+			receivedAt := time.Now()
+			if v.onMessage != nil {
+				v.onMessage(msg)
+			}
 			if msg.Err != nil {
 				if msg.Err == io.EOF {
 					return
 				}
 				grpcErr, ok := status.FromError(msg.Err)
 				if ok {
-					v.err = newScriptExecutionError(CodeGRPCError, "Failed to execute script: "+grpcErr.Message())
+					err := newScriptExecutionError(CodeGRPCError, "Failed to execute script: "+grpcErr.Message())
+					err.rpcStatus = grpcErr
+					v.err = err
 					return
 				}
 				v.err = newScriptExecutionError(CodeUnknown, "failed to execute script")
@@ -252,9 +318,9 @@ func (v *StreamOutputAdapter) handleStream(ctx context.Context, stream chan *Exe
 			case *vizierpb.ExecuteScriptResponse_MetaData:
 				err = v.handleMetadata(ctx, res)
 			case *vizierpb.ExecuteScriptResponse_Data:
-				err = v.handleData(ctx, res)
+				err = v.handleData(ctx, res, receivedAt)
 			default:
-				err = fmt.Errorf("unhandled response type" + reflect.TypeOf(msg.Resp.Result).String())
+				err = fmt.Errorf("unhandled response type %s", reflect.TypeOf(msg.Resp.Result).String())
 			}
 			if err != nil {
 				v.err = newScriptExecutionError(CodeBadData, "failed to handle data from Vizier: "+err.Error())
@@ -342,11 +408,14 @@ func (v *StreamOutputAdapter) parseError(ctx context.Context, s *vizierpb.Status
 		err := newScriptExecutionError(CodeCompilerError,
 			fmt.Sprintf("Script compilation failed: %s", strings.Join(compilerErrors, ", ")))
 		err.compilerErrors = compilerErrors
+		err.status = s
 		return err
 	}
 
 	utils.Errorf("Script execution error: %s", s.Message)
-	return newScriptExecutionError(CodeUnknown, "Script execution error:"+s.Message)
+	err := newScriptExecutionError(CodeUnknown, "Script execution error:"+s.Message)
+	err.status = s
+	return err
 }
 
 func (v *StreamOutputAdapter) handleExecutionStats(ctx context.Context, es *vizierpb.QueryExecutionStats) error {
@@ -356,9 +425,12 @@ func (v *StreamOutputAdapter) handleExecutionStats(ctx context.Context, es *vizi
 
 func (v *StreamOutputAdapter) handleMutationInfo(ctx context.Context, mi *vizierpb.MutationInfo) {
 	v.mutationInfo = mi
+	if v.onMutationInfo != nil {
+		v.onMutationInfo(mi)
+	}
 }
 
-func (v *StreamOutputAdapter) handleData(ctx context.Context, d *vizierpb.ExecuteScriptResponse_Data) error {
+func (v *StreamOutputAdapter) handleData(ctx context.Context, d *vizierpb.ExecuteScriptResponse_Data, receivedAt time.Time) error {
 	if d.Data.ExecutionStats != nil {
 		err := v.handleExecutionStats(ctx, d.Data.ExecutionStats)
 		if err != nil {
@@ -399,6 +471,12 @@ func (v *StreamOutputAdapter) handleData(ctx context.Context, d *vizierpb.Execut
 		// No records.
 		return nil
 	}
+	if v.onRowBatch != nil && numRows > 0 {
+		v.onRowBatch(numRows, receivedAt)
+	}
+	if v.format == FormatCountOnly {
+		return nil
+	}
 
 	cols := d.Data.Batch.Cols
 	for rowIdx := 0; rowIdx < numRows; rowIdx++ {
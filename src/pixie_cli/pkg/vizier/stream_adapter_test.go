@@ -0,0 +1,231 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package vizier_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+)
+
+func int64Batch(tableID string, values ...int64) *vizier.ExecData {
+	return &vizier.ExecData{Resp: &vizierpb.ExecuteScriptResponse{
+		Result: &vizierpb.ExecuteScriptResponse_Data{Data: &vizierpb.QueryData{
+			Batch: &vizierpb.RowBatchData{
+				TableID: tableID,
+				Cols: []*vizierpb.Column{{
+					ColData: &vizierpb.Column_Int64Data{Int64Data: &vizierpb.Int64Column{Data: values}},
+				}},
+			},
+		}},
+	}}
+}
+
+func TestStreamOutputAdapter_RowBatchCallback(t *testing.T) {
+	stream := make(chan *vizier.ExecData, 5)
+	stream <- &vizier.ExecData{Resp: &vizierpb.ExecuteScriptResponse{
+		Result: &vizierpb.ExecuteScriptResponse_MetaData{MetaData: &vizierpb.QueryMetadata{
+			Name: "output",
+			ID:   "table-1",
+			Relation: &vizierpb.Relation{Columns: []*vizierpb.Relation_ColumnInfo{
+				{ColumnName: "count", ColumnType: vizierpb.INT64},
+			}},
+		}},
+	}}
+	stream <- int64Batch("table-1", 1, 2)
+	// Empty batches, such as the one that ends a table, aren't rows.
+	stream <- int64Batch("table-1")
+	stream <- int64Batch("table-1", 3)
+	stream <- &vizier.ExecData{Err: io.EOF}
+
+	start := time.Now()
+	var numRows []int
+	var receivedAt []time.Time
+	tw := vizier.NewStreamOutputAdapter(context.Background(), stream, vizier.FormatInMemory, nil,
+		vizier.WithRowBatchCallback(func(n int, t time.Time) {
+			numRows = append(numRows, n)
+			receivedAt = append(receivedAt, t)
+		}))
+	require.NoError(t, tw.Finish())
+
+	assert.Equal(t, []int{2, 1}, numRows)
+	require.Len(t, receivedAt, 2)
+	assert.False(t, receivedAt[0].Before(start))
+	assert.False(t, receivedAt[1].Before(receivedAt[0]))
+}
+
+func TestStreamOutputAdapter_KeepsErrorStatus(t *testing.T) {
+	s := &vizierpb.Status{
+		Code:    int32(codes.InvalidArgument),
+		Message: "compilation failed",
+		ErrorDetails: []*vizierpb.ErrorDetails{{
+			Error: &vizierpb.ErrorDetails_CompilerError{CompilerError: &vizierpb.CompilerError{
+				Line: 3, Column: 5, Message: "name 'foo' is not defined",
+			}},
+		}},
+	}
+	stream := make(chan *vizier.ExecData, 1)
+	stream <- &vizier.ExecData{Resp: &vizierpb.ExecuteScriptResponse{Status: s}}
+
+	tw := vizier.NewStreamOutputAdapter(context.Background(), stream, vizier.FormatInMemory, nil)
+	err := tw.Finish()
+	require.Error(t, err)
+	scriptErr, ok := err.(*vizier.ScriptExecutionError)
+	require.True(t, ok)
+	assert.Equal(t, vizier.CodeCompilerError, scriptErr.Code())
+	assert.Equal(t, s, scriptErr.Status())
+	assert.Nil(t, scriptErr.RPCStatus())
+}
+
+func TestStreamOutputAdapter_KeepsRPCStatus(t *testing.T) {
+	stream := make(chan *vizier.ExecData, 1)
+	stream <- &vizier.ExecData{Err: status.Error(codes.Unavailable, "connection refused")}
+
+	tw := vizier.NewStreamOutputAdapter(context.Background(), stream, vizier.FormatInMemory, nil)
+	err := tw.Finish()
+	require.Error(t, err)
+	scriptErr, ok := err.(*vizier.ScriptExecutionError)
+	require.True(t, ok)
+	assert.Equal(t, vizier.CodeGRPCError, scriptErr.Code())
+	assert.Nil(t, scriptErr.Status())
+	require.NotNil(t, scriptErr.RPCStatus())
+	assert.Equal(t, codes.Unavailable, scriptErr.RPCStatus().Code())
+	assert.Equal(t, "connection refused", scriptErr.RPCStatus().Message())
+}
+
+// resultMessages returns the messages of a script that returns numBatches batches of batchSize rows.
+func resultMessages(numBatches, batchSize int) []*vizier.ExecData {
+	msgs := []*vizier.ExecData{{Resp: &vizierpb.ExecuteScriptResponse{
+		Result: &vizierpb.ExecuteScriptResponse_MetaData{MetaData: &vizierpb.QueryMetadata{
+			Name: "output",
+			ID:   "table-1",
+			Relation: &vizierpb.Relation{Columns: []*vizierpb.Relation_ColumnInfo{
+				{ColumnName: "count", ColumnType: vizierpb.INT64},
+				{ColumnName: "service", ColumnType: vizierpb.STRING},
+			}},
+		}},
+	}}}
+	counts := make([]int64, batchSize)
+	services := make([][]byte, batchSize)
+	for i := range services {
+		counts[i] = int64(i)
+		services[i] = []byte(fmt.Sprintf("pl/service-%d", i))
+	}
+	for i := 0; i < numBatches; i++ {
+		msgs = append(msgs, &vizier.ExecData{Resp: &vizierpb.ExecuteScriptResponse{
+			Result: &vizierpb.ExecuteScriptResponse_Data{Data: &vizierpb.QueryData{
+				Batch: &vizierpb.RowBatchData{
+					TableID: "table-1",
+					Cols: []*vizierpb.Column{
+						{ColData: &vizierpb.Column_Int64Data{Int64Data: &vizierpb.Int64Column{Data: counts}}},
+						{ColData: &vizierpb.Column_StringData{StringData: &vizierpb.StringColumn{Data: services}}},
+					},
+				},
+			}},
+		}})
+	}
+	return append(msgs, &vizier.ExecData{Err: io.EOF})
+}
+
+func TestStreamOutputAdapter_MutationInfoCallback(t *testing.T) {
+	pending := &vizierpb.MutationInfo{
+		Status: &vizierpb.Status{Code: int32(codes.Unavailable), Message: "probe installation in progress"},
+		States: []*vizierpb.MutationInfo_MutationState{{Name: "http_probe", State: vizierpb.PENDING_STATE}},
+	}
+	stream := make(chan *vizier.ExecData, 1)
+	stream <- &vizier.ExecData{Resp: &vizierpb.ExecuteScriptResponse{MutationInfo: pending}}
+	close(stream)
+
+	var received []*vizierpb.MutationInfo
+	tw := vizier.NewStreamOutputAdapter(context.Background(), stream, vizier.FormatCountOnly, nil,
+		vizier.WithMutationInfoCallback(func(mi *vizierpb.MutationInfo) { received = append(received, mi) }))
+	require.NoError(t, tw.Finish())
+	assert.Equal(t, []*vizierpb.MutationInfo{pending}, received)
+}
+
+func TestStreamOutputAdapter_MessageCallback(t *testing.T) {
+	msgs := resultMessages(3, 10)
+	stream := make(chan *vizier.ExecData, len(msgs))
+	for _, msg := range msgs {
+		stream <- msg
+	}
+	close(stream)
+
+	var received []*vizier.ExecData
+	tw := vizier.NewStreamOutputAdapter(context.Background(), stream, vizier.FormatCountOnly, nil,
+		vizier.WithMessageCallback(func(msg *vizier.ExecData) { received = append(received, msg) }))
+	require.NoError(t, tw.Finish())
+	assert.Equal(t, msgs, received)
+}
+
+func runAdapter(msgs []*vizier.ExecData, format string) error {
+	stream := make(chan *vizier.ExecData, len(msgs))
+	for _, msg := range msgs {
+		stream <- msg
+	}
+	tw := vizier.NewStreamOutputAdapter(context.Background(), stream, format, nil)
+	return tw.Finish()
+}
+
+func TestStreamOutputAdapter_CountOnly(t *testing.T) {
+	msgs := resultMessages(10, 1000)
+
+	var numRows int
+	stream := make(chan *vizier.ExecData, len(msgs))
+	for _, msg := range msgs {
+		stream <- msg
+	}
+	tw := vizier.NewStreamOutputAdapter(context.Background(), stream, vizier.FormatCountOnly, nil,
+		vizier.WithRowBatchCallback(func(n int, _ time.Time) { numRows += n }))
+	require.NoError(t, tw.Finish())
+	assert.Equal(t, 10000, numRows)
+
+	inMemory := testing.AllocsPerRun(10, func() {
+		require.NoError(t, runAdapter(msgs, vizier.FormatInMemory))
+	})
+	countOnly := testing.AllocsPerRun(10, func() {
+		require.NoError(t, runAdapter(msgs, vizier.FormatCountOnly))
+	})
+	// Accumulating allocates for every row, while counting doesn't.
+	assert.Less(t, countOnly*100, inMemory)
+}
+
+func BenchmarkStreamOutputAdapter(b *testing.B) {
+	msgs := resultMessages(10, 1000)
+	for _, format := range []string{vizier.FormatInMemory, vizier.FormatCountOnly} {
+		b.Run(format, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := runAdapter(msgs, format); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
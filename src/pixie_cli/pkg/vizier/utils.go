@@ -134,8 +134,8 @@ func GetVizierList(cloudAddr string) ([]*cloudpb.ClusterInfo, error) {
 	return vzInfo, nil
 }
 
-func createVizierConnection(cloudAddr string, vzInfo *cloudpb.ClusterInfo) (*Connector, error) {
-	v, err := NewConnector(cloudAddr, vzInfo, "", "")
+func createVizierConnection(cloudAddr string, vzInfo *cloudpb.ClusterInfo, opts ...ConnectorOption) (*Connector, error) {
+	v, err := NewConnector(cloudAddr, vzInfo, "", "", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -251,7 +251,7 @@ func GetCurrentOrFirstHealthyVizier(cloudAddr string) (uuid.UUID, error) {
 
 // ConnectionToHealthyVizierByID connects to the input clusterID if it is healthy.
 // It returns an error if the clusterID provided corresponds to a cluster that is not healthy.
-func ConnectionToHealthyVizierByID(cloudAddr string, clusterID uuid.UUID) (*Connector, error) {
+func ConnectionToHealthyVizierByID(cloudAddr string, clusterID uuid.UUID, opts ...ConnectorOption) (*Connector, error) {
 	clusterInfo, err := GetVizierInfo(cloudAddr, clusterID)
 	if err != nil {
 		return nil, errors.New("Could not fetch vizier")
@@ -267,12 +267,12 @@ func ConnectionToHealthyVizierByID(cloudAddr string, clusterID uuid.UUID) (*Conn
 	if clusterInfo.Status == cloudpb.CS_DEGRADED {
 		cliUtils.Infof("Data may not be complete.\nCluster '%s' is in a degraded state: %s", clusterID.String(), clusterInfo.StatusMessage)
 	}
-	return ConnectionToVizierByID(cloudAddr, clusterID)
+	return ConnectionToVizierByID(cloudAddr, clusterID, opts...)
 }
 
 // ConnectionToVizierByID connects to the vizier on specified ID.
 // It will not check for Vizier health.
-func ConnectionToVizierByID(cloudAddr string, clusterID uuid.UUID) (*Connector, error) {
+func ConnectionToVizierByID(cloudAddr string, clusterID uuid.UUID, opts ...ConnectorOption) (*Connector, error) {
 	vzInfos, err := GetVizierList(cloudAddr)
 	if err != nil {
 		return nil, err
@@ -280,7 +280,7 @@ func ConnectionToVizierByID(cloudAddr string, clusterID uuid.UUID) (*Connector,
 
 	for _, vzInfo := range vzInfos {
 		if utils.UUIDFromProtoOrNil(vzInfo.ID) == clusterID {
-			return createVizierConnection(cloudAddr, vzInfo)
+			return createVizierConnection(cloudAddr, vzInfo, opts...)
 		}
 	}
 
@@ -304,7 +304,7 @@ func GetVizierInfo(cloudAddr string, clusterID uuid.UUID) (*cloudpb.ClusterInfo,
 }
 
 // ConnectToAllViziers connects to all available viziers.
-func ConnectToAllViziers(cloudAddr string) ([]*Connector, error) {
+func ConnectToAllViziers(cloudAddr string, opts ...ConnectorOption) ([]*Connector, error) {
 	vzInfos, err := GetVizierList(cloudAddr)
 	if err != nil {
 		return nil, err
@@ -315,7 +315,7 @@ func ConnectToAllViziers(cloudAddr string) ([]*Connector, error) {
 		if vzInfo.Status != cloudpb.CS_HEALTHY && vzInfo.Status != cloudpb.CS_DEGRADED {
 			continue
 		}
-		c, err := createVizierConnection(cloudAddr, vzInfo)
+		c, err := createVizierConnection(cloudAddr, vzInfo, opts...)
 		if err != nil {
 			return nil, err
 		}
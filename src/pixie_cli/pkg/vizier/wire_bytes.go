@@ -0,0 +1,80 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package vizier
+
+import (
+	"context"
+	"sync/atomic"
+
+	"google.golang.org/grpc/stats"
+)
+
+// WireBytesCounter counts the bytes sent and received on the wire by the RPCs made with a context from
+// WithWireBytesCounter. The counts are of the gRPC messages as sent, after compression, so they show the
+// network cost of the RPCs, rather than the size of the data they carry.
+type WireBytesCounter struct {
+	sent     int64
+	received int64
+}
+
+// Sent returns the number of bytes sent so far.
+func (c *WireBytesCounter) Sent() int64 {
+	return atomic.LoadInt64(&c.sent)
+}
+
+// Received returns the number of bytes received so far.
+func (c *WireBytesCounter) Received() int64 {
+	return atomic.LoadInt64(&c.received)
+}
+
+type wireBytesCounterKey struct{}
+
+// WithWireBytesCounter returns a context that counts the wire bytes of the RPCs made with it in c. Each RPC
+// counts towards the counter of its own context, so RPCs that run concurrently on the same connection can be
+// told apart.
+func WithWireBytesCounter(ctx context.Context, c *WireBytesCounter) context.Context {
+	return context.WithValue(ctx, wireBytesCounterKey{}, c)
+}
+
+// wireBytesHandler is a gRPC stats handler that adds the wire bytes of each RPC to the counter in its context,
+// if there is one.
+type wireBytesHandler struct{}
+
+func (wireBytesHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (wireBytesHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	c, ok := ctx.Value(wireBytesCounterKey{}).(*WireBytesCounter)
+	if !ok {
+		return
+	}
+	switch s := s.(type) {
+	case *stats.InPayload:
+		atomic.AddInt64(&c.received, int64(s.WireLength))
+	case *stats.OutPayload:
+		atomic.AddInt64(&c.sent, int64(s.WireLength))
+	}
+}
+
+func (wireBytesHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (wireBytesHandler) HandleConn(context.Context, stats.ConnStats) {}
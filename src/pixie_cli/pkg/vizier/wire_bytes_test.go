@@ -0,0 +1,94 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package vizier
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/pixie_cli/pkg/auth"
+	"px.dev/pixie/src/utils/script"
+)
+
+func TestWireBytesCounter_CountsEachRun(t *testing.T) {
+	viper.Set("do_not_track", true)
+	auth.SetCloudCredentials(testCloudAddr, &auth.RefreshToken{Token: "test-token"})
+
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer()
+	vizierpb.RegisterVizierServiceServer(s, &endlessVizierServer{})
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	defer s.Stop()
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(wireBytesHandler{}))
+	require.NoError(t, err)
+	defer conn.Close()
+	c := &Connector{conn: conn, vz: vizierpb.NewVizierServiceClient(conn), cloudAddr: testCloudAddr}
+
+	// Runs that share the connection each count only their own bytes.
+	run := func(counter *WireBytesCounter, numBatches int) {
+		ctx, cancel := context.WithTimeout(WithWireBytesCounter(context.Background(), counter), 10*time.Second)
+		defer cancel()
+		resp, err := RunScript(ctx, []*Connector{c}, &script.ExecutableScript{ScriptString: "px.display(df)"}, nil)
+		require.NoError(t, err)
+		batches := 0
+		for msg := range resp {
+			if msg.Resp != nil && msg.Resp.GetData() != nil {
+				batches++
+			}
+			if batches == numBatches {
+				cancel()
+			}
+		}
+	}
+	var small, large WireBytesCounter
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		run(&small, 10)
+	}()
+	go func() {
+		defer wg.Done()
+		run(&large, 1000)
+	}()
+	wg.Wait()
+
+	assert.Greater(t, small.Received(), int64(0))
+	assert.Greater(t, large.Received(), 10*small.Received())
+	assert.Greater(t, small.Sent(), int64(0))
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// The default client-side rate limit on calls to the K8s API. Each K8s state update makes about one call per
+// collector, plus one call per unhealthy pod for its events, so an update needs a burst of a few dozen calls
+// every update period. The default QPS refills that burst well within the period.
+const (
+	defaultK8sAPIQPS   = 10
+	defaultK8sAPIBurst = 30
+)
+
+// essentialCollectors are needed for the pod statuses and PEM coverage, and always run. The other collectors
+// are skipped for the rest of an update once it is throttled by the rate limiter.
+var essentialCollectors = map[string]bool{
+	collectorPods:  true,
+	collectorNodes: true,
+}
+
+// apiCallBudget tracks the calls made to the K8s API through the client-side rate limiter, so that the
+// optional collectors can be skipped rather than queue up behind it when it is throttling. A nil budget never
+// throttles.
+type apiCallBudget struct {
+	// The number of calls made, in total.
+	calls int64
+	// Set when a call had to wait for the rate limiter during the current update.
+	throttled int32
+}
+
+// startUpdate resets the throttling state at the start of a K8s state update, and returns the number of calls
+// made so far.
+func (b *apiCallBudget) startUpdate() int64 {
+	if b == nil {
+		return 0
+	}
+	atomic.StoreInt32(&b.throttled, 0)
+	return atomic.LoadInt64(&b.calls)
+}
+
+// recordCall records a call to the K8s API, and whether it had to wait for the rate limiter.
+func (b *apiCallBudget) recordCall(waited bool) {
+	if b == nil {
+		return
+	}
+	atomic.AddInt64(&b.calls, 1)
+	if waited {
+		atomic.StoreInt32(&b.throttled, 1)
+	}
+}
+
+// callsSince returns the number of calls made since the given call count.
+func (b *apiCallBudget) callsSince(start int64) int64 {
+	if b == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&b.calls) - start
+}
+
+// isThrottled returns whether any call had to wait for the rate limiter during the current update.
+func (b *apiCallBudget) isThrottled() bool {
+	return b != nil && atomic.LoadInt32(&b.throttled) == 1
+}
+
+// budgetRateLimiter is the client-side rate limiter of the K8s clients, which records each call in the budget.
+type budgetRateLimiter struct {
+	flowcontrol.RateLimiter
+	budget *apiCallBudget
+}
+
+// newBudgetRateLimiter returns a rate limiter that allows the given QPS and burst, and records each call in the
+// budget.
+func newBudgetRateLimiter(qps float32, burst int, budget *apiCallBudget) flowcontrol.RateLimiter {
+	return &budgetRateLimiter{
+		RateLimiter: flowcontrol.NewTokenBucketRateLimiter(qps, burst),
+		budget:      budget,
+	}
+}
+
+// Wait is called by the K8s clients before each request.
+func (l *budgetRateLimiter) Wait(ctx context.Context) error {
+	if l.RateLimiter.TryAccept() {
+		l.budget.recordCall(false)
+		return nil
+	}
+	l.budget.recordCall(true)
+	return l.RateLimiter.Wait(ctx)
+}
+
+// collectorThrottled returns whether the collector should be skipped because the current update is throttled.
+func (v *K8sVizierInfo) collectorThrottled(collector string) bool {
+	if essentialCollectors[collector] || !v.apiBudget.isThrottled() {
+		return false
+	}
+	k8sStateCollectorSkipsCounter.WithLabelValues(collector).Inc()
+	log.WithField("collector", collector).Debug("K8s API calls are being throttled, skipping the collector for this update")
+	return true
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestBudgetRateLimiter(t *testing.T) {
+	budget := &apiCallBudget{}
+	limiter := newBudgetRateLimiter(100, 2, budget)
+	defer limiter.Stop()
+
+	start := budget.startUpdate()
+	require.NoError(t, limiter.Wait(context.Background()))
+	require.NoError(t, limiter.Wait(context.Background()))
+	assert.False(t, budget.isThrottled())
+
+	// The burst is used up, so the next call has to wait for the rate limiter.
+	require.NoError(t, limiter.Wait(context.Background()))
+	assert.True(t, budget.isThrottled())
+	assert.Equal(t, int64(3), budget.callsSince(start))
+
+	// Each update starts out unthrottled.
+	start = budget.startUpdate()
+	assert.False(t, budget.isThrottled())
+	assert.Equal(t, int64(0), budget.callsSince(start))
+}
+
+func TestBudgetRateLimiter_ContextCanceled(t *testing.T) {
+	budget := &apiCallBudget{}
+	limiter := newBudgetRateLimiter(0.001, 1, budget)
+	defer limiter.Stop()
+
+	require.NoError(t, limiter.Wait(context.Background()))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Error(t, limiter.Wait(ctx))
+}
+
+func TestCollectorAvailable_Throttled(t *testing.T) {
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		apiBudget: &apiCallBudget{},
+	}
+	assert.True(t, vzInfo.collectorAvailable(collectorStatefulSets))
+
+	vzInfo.apiBudget.recordCall(true)
+	skips := testutil.ToFloat64(k8sStateCollectorSkipsCounter.WithLabelValues(collectorStatefulSets))
+	assert.False(t, vzInfo.collectorAvailable(collectorStatefulSets))
+	assert.Equal(t, skips+1, testutil.ToFloat64(k8sStateCollectorSkipsCounter.WithLabelValues(collectorStatefulSets)))
+	// The pod statuses and PEM coverage are always collected.
+	assert.True(t, vzInfo.collectorAvailable(collectorPods))
+	assert.True(t, vzInfo.collectorAvailable(collectorNodes))
+
+	// A nil budget never throttles.
+	assert.True(t, (&K8sVizierInfo{ns: testNamespace}).collectorAvailable(collectorStatefulSets))
+}
+
+func TestUpdateK8sState_Throttled(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		makePod("vizier-metadata-0", map[string]string{"plane": "control"}),
+		makeStatefulSet("pl-nats", 1, 1),
+	)
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+		apiBudget: &apiCallBudget{},
+	}
+	vzInfo.UpdateK8sState(context.Background())
+	require.Len(t, vzInfo.GetK8sState().StatefulSetStatuses, 1)
+
+	// The rate limiter starts throttling while the pods are listed.
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		vzInfo.apiBudget.recordCall(true)
+		return false, nil, nil
+	})
+	vzInfo.UpdateK8sState(context.Background())
+
+	state := vzInfo.GetK8sState()
+	assert.Contains(t, state.ControlPlanePodStatuses, "vizier-metadata-0")
+	assert.Empty(t, state.StatefulSetStatuses)
+}
@@ -0,0 +1,198 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// How often the permissions of the collectors are re-probed, so that newly granted permissions are picked up.
+const capabilityRefreshPeriod = 5 * time.Minute
+
+// The collectors of the K8s state that can be disabled by missing permissions.
+const (
+	collectorPods             = "pods"
+	collectorNodes            = "nodes"
+	collectorEvents           = "events"
+	collectorJobs             = "jobs"
+	collectorResourceQuotas   = "resource quotas"
+	collectorLimitRanges      = "limit ranges"
+	collectorCertExpiries     = "TLS certs"
+	collectorDaemonSets       = "PEM DaemonSet"
+	collectorSecrets          = "secrets"
+	collectorStatefulSets     = "StatefulSets"
+	collectorServiceEndpoints = "service endpoints"
+	collectorReplicaSets      = "pod owners"
+)
+
+// k8sCapability is the access to the K8s API that a collector needs.
+type k8sCapability struct {
+	verb     string
+	group    string
+	resource string
+	// Whether the access is needed in the Vizier namespace, rather than cluster-wide.
+	namespaced bool
+}
+
+// description returns the permission in a human-readable form. Ex: "list pods in namespace pl".
+func (c k8sCapability) description(ns string) string {
+	resource := c.resource
+	if c.group != "" {
+		resource = fmt.Sprintf("%s.%s", c.resource, c.group)
+	}
+	if c.namespaced {
+		return fmt.Sprintf("%s %s in namespace %s", c.verb, resource, ns)
+	}
+	return fmt.Sprintf("%s %s", c.verb, resource)
+}
+
+// collectorCapabilities is the access each of the collectors needs.
+var collectorCapabilities = map[string]k8sCapability{
+	collectorPods:             {verb: "list", resource: "pods", namespaced: true},
+	collectorNodes:            {verb: "list", resource: "nodes"},
+	collectorEvents:           {verb: "list", resource: "events", namespaced: true},
+	collectorJobs:             {verb: "list", group: "batch", resource: "jobs", namespaced: true},
+	collectorResourceQuotas:   {verb: "list", resource: "resourcequotas", namespaced: true},
+	collectorLimitRanges:      {verb: "list", resource: "limitranges", namespaced: true},
+	collectorCertExpiries:     {verb: "get", resource: "secrets", namespaced: true},
+	collectorDaemonSets:       {verb: "get", group: "apps", resource: "daemonsets", namespaced: true},
+	collectorSecrets:          {verb: "get", resource: "secrets", namespaced: true},
+	collectorStatefulSets:     {verb: "list", group: "apps", resource: "statefulsets", namespaced: true},
+	collectorServiceEndpoints: {verb: "list", group: "discovery.k8s.io", resource: "endpointslices", namespaced: true},
+	collectorReplicaSets:      {verb: "get", group: "apps", resource: "replicasets", namespaced: true},
+}
+
+// k8sCapabilities records which of the collectors are missing the permissions they need.
+type k8sCapabilities struct {
+	// The missing permission of each collector that is not allowed, keyed by collector.
+	missing     map[string]string
+	lastUpdated time.Time
+}
+
+// probeCapabilities asks the API server which of the collectors' permissions the cloud connector has. If a
+// permission can't be checked, the collector is assumed to be allowed, and left to fail on its own.
+func (v *K8sVizierInfo) probeCapabilities(ctx context.Context) map[string]string {
+	collectors := make([]string, 0, len(collectorCapabilities))
+	for collector := range collectorCapabilities {
+		collectors = append(collectors, collector)
+	}
+	sort.Strings(collectors)
+
+	missing := make(map[string]string)
+	for _, collector := range collectors {
+		c := collectorCapabilities[collector]
+		attrs := &authorizationv1.ResourceAttributes{
+			Verb:     c.verb,
+			Group:    c.group,
+			Resource: c.resource,
+		}
+		if c.namespaced {
+			attrs.Namespace = v.ns
+		}
+		review, err := v.clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: attrs},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			recordK8sAPIError("selfsubjectaccessreviews")
+			log.WithError(err).WithField("collector", collector).Warn("Failed to check the permissions of the collector")
+			continue
+		}
+		if !review.Status.Allowed {
+			missing[collector] = c.description(v.ns)
+		}
+	}
+	return missing
+}
+
+// refreshCapabilities re-probes the permissions of the collectors, if they have not been probed within the
+// refresh period. This is a no-op if capability probing is disabled.
+func (v *K8sVizierInfo) refreshCapabilities(ctx context.Context, now time.Time) {
+	v.mu.Lock()
+	stale := v.capabilities != nil && now.Sub(v.capabilities.lastUpdated) >= capabilityRefreshPeriod
+	v.mu.Unlock()
+	if !stale {
+		return
+	}
+
+	missing := v.probeCapabilities(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for collector, permission := range missing {
+		if _, ok := v.capabilities.missing[collector]; !ok {
+			log.WithField("collector", collector).Warnf("Missing permission to %s, marking it as unavailable", permission)
+		}
+	}
+	v.capabilities.missing = missing
+	v.capabilities.lastUpdated = now
+}
+
+// collectorAvailable returns whether the collector has the permissions it needs, and is not skipped because the
+// current update is throttled. All collectors have their permissions if capability probing is disabled, or has
+// not run yet.
+func (v *K8sVizierInfo) collectorAvailable(collector string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.capabilities != nil {
+		if _, missing := v.capabilities.missing[collector]; missing {
+			return false
+		}
+	}
+	return !v.collectorThrottled(collector)
+}
+
+// getUnavailableCollectors returns the missing permission of each unavailable collector, keyed by collector.
+// The caller must hold the lock.
+func (v *K8sVizierInfo) getUnavailableCollectors() map[string]string {
+	if v.capabilities == nil || len(v.capabilities.missing) == 0 {
+		return nil
+	}
+	unavailable := make(map[string]string, len(v.capabilities.missing))
+	for collector, permission := range v.capabilities.missing {
+		unavailable[collector] = permission
+	}
+	return unavailable
+}
+
+// Collectors without the permissions they need leave their part of the state out. Restricting the cloud
+// connector's permissions is deliberate, so this is reported without affecting the health.
+func checkUnavailableCollectors(s *K8sState) (VizierHealth, []string) {
+	collectors := make([]string, 0, len(s.UnavailableCollectors))
+	for collector := range s.UnavailableCollectors {
+		collectors = append(collectors, collector)
+	}
+	sort.Strings(collectors)
+
+	var reasons []string
+	for _, collector := range collectors {
+		reasons = append(reasons, fmt.Sprintf("%s unavailable: missing permission to %s", collector, s.UnavailableCollectors[collector]))
+	}
+	return VizierHealthHealthy, reasons
+}
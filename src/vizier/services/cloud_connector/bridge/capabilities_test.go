@@ -0,0 +1,129 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// denyResources makes the access reviews of the fake clientset deny the given resources, and allow the rest.
+func denyResources(clientset *fake.Clientset, denied map[string]bool) {
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		review.Status.Allowed = !denied[review.Spec.ResourceAttributes.Resource]
+		return true, review, nil
+	})
+}
+
+func TestProbeCapabilities(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	denyResources(clientset, map[string]bool{"nodes": true, "jobs": true})
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	assert.Equal(t, map[string]string{
+		collectorNodes: "list nodes",
+		collectorJobs:  "list jobs.batch in namespace pl",
+	}, vzInfo.probeCapabilities(context.Background()))
+}
+
+func TestRefreshCapabilities(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	denied := map[string]bool{"events": true}
+	denyResources(clientset, denied)
+	vzInfo := &K8sVizierInfo{
+		ns:           testNamespace,
+		clientset:    clientset,
+		capabilities: &k8sCapabilities{},
+	}
+
+	now := time.Now()
+	vzInfo.refreshCapabilities(context.Background(), now)
+	assert.False(t, vzInfo.collectorAvailable(collectorEvents))
+	assert.True(t, vzInfo.collectorAvailable(collectorPods))
+
+	// Permissions granted since the last probe are picked up once the refresh period passes.
+	delete(denied, "events")
+	vzInfo.refreshCapabilities(context.Background(), now.Add(time.Minute))
+	assert.False(t, vzInfo.collectorAvailable(collectorEvents))
+	vzInfo.refreshCapabilities(context.Background(), now.Add(capabilityRefreshPeriod))
+	assert.True(t, vzInfo.collectorAvailable(collectorEvents))
+
+	// Without capability probing, all of the collectors are available.
+	vzInfo = &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+	denied["events"] = true
+	vzInfo.refreshCapabilities(context.Background(), now)
+	assert.True(t, vzInfo.collectorAvailable(collectorEvents))
+}
+
+func TestUpdateK8sState_UnavailableCollectors(t *testing.T) {
+	selector, err := getPodSelector("app=pl-monitoring", false)
+	require.NoError(t, err)
+	clientset := fake.NewSimpleClientset(
+		makePod("vizier-metadata-0", map[string]string{"app": "pl-monitoring", "plane": "control"}),
+		makeNode("node-1", "4", "16Gi"),
+	)
+	denied := map[string]bool{"nodes": true}
+	denyResources(clientset, denied)
+	vzInfo := &K8sVizierInfo{
+		ns:           testNamespace,
+		clientset:    clientset,
+		podSelector:  selector,
+		capabilities: &k8sCapabilities{},
+	}
+
+	vzInfo.UpdateK8sState(context.Background())
+
+	state := vzInfo.GetK8sState()
+	assert.False(t, state.LastUpdated.IsZero())
+	assert.Contains(t, state.ControlPlanePodStatuses, "vizier-metadata-0")
+	assert.Equal(t, int32(0), state.NumNodes)
+	assert.True(t, state.ClusterStats.Incomplete)
+	assert.Equal(t, map[string]string{collectorNodes: "list nodes"}, state.UnavailableCollectors)
+	assert.Contains(t, state.VizierState.Reasons, "nodes unavailable: missing permission to list nodes")
+
+	// Without permission to list pods, the pod statuses are left out instead of the update failing.
+	vzInfo = &K8sVizierInfo{
+		ns:           testNamespace,
+		clientset:    clientset,
+		podSelector:  selector,
+		capabilities: &k8sCapabilities{},
+	}
+	denied["pods"] = true
+	vzInfo.UpdateK8sState(context.Background())
+
+	state = vzInfo.GetK8sState()
+	assert.False(t, state.LastUpdated.IsZero())
+	assert.Empty(t, state.ControlPlanePodStatuses)
+	assert.Contains(t, state.VizierState.Reasons, "pods unavailable: missing permission to list pods in namespace pl")
+}
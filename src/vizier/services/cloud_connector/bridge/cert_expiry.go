@@ -0,0 +1,222 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Certificates are valid for months, so their expiry only needs to be checked occasionally.
+const certExpiryRefreshPeriod = 5 * time.Minute
+
+// tlsCertRef identifies a PEM-encoded certificate stored in a secret.
+type tlsCertRef struct {
+	secret string
+	key    string
+}
+
+// vizierTLSCerts are the certificates that Vizier components use to talk to each other.
+// The private keys stored alongside them are never read.
+var vizierTLSCerts = []tlsCertRef{
+	{secret: "service-tls-certs", key: "ca.crt"},
+	{secret: "service-tls-certs", key: "server.crt"},
+	{secret: "service-tls-certs", key: "client.crt"},
+	{secret: "proxy-tls-certs", key: "tls.crt"},
+	{secret: "etcd-peer-tls-certs", key: "peer.crt"},
+	{secret: "etcd-client-tls-certs", key: "etcd-client.crt"},
+	{secret: "etcd-server-tls-certs", key: "server.crt"},
+}
+
+// CertExpiryState describes how close a certificate is to expiring.
+type CertExpiryState int
+
+const (
+	// CertExpiryUnknown indicates that the certificate could not be read or parsed.
+	CertExpiryUnknown CertExpiryState = iota
+	// CertExpiryValid indicates that the certificate expires after the warning window.
+	CertExpiryValid
+	// CertExpiryExpiring indicates that the certificate expires within the warning window.
+	CertExpiryExpiring
+	// CertExpiryExpired indicates that the certificate has expired.
+	CertExpiryExpired
+)
+
+func (s CertExpiryState) String() string {
+	switch s {
+	case CertExpiryValid:
+		return "Valid"
+	case CertExpiryExpiring:
+		return "Expiring"
+	case CertExpiryExpired:
+		return "Expired"
+	default:
+		return "Unknown"
+	}
+}
+
+// CertExpiry is the expiry of a TLS certificate stored in a secret in the Vizier namespace.
+type CertExpiry struct {
+	// The name of the secret, and the key within the secret that holds the certificate.
+	Secret string
+	Key    string
+	State  CertExpiryState
+	// The time that the certificate expires. Unset if the state is unknown.
+	NotAfter time.Time
+	// The number of whole days until the certificate expires. Negative once it has expired.
+	DaysUntilExpiry int32
+	// Why the state is unknown. Ex: "secret is not readable".
+	UnknownReason string
+}
+
+// Name returns the name of the certificate. Ex: "service-tls-certs/server.crt".
+func (c *CertExpiry) Name() string {
+	return fmt.Sprintf("%s/%s", c.Secret, c.Key)
+}
+
+// parseCertNotAfter returns the expiry of the first certificate in the PEM data.
+func parseCertNotAfter(data []byte) (time.Time, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return time.Time{}, errors.New("no PEM data found")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cert.NotAfter, nil
+}
+
+func getCertExpiryState(now, notAfter time.Time, warningWindow time.Duration) CertExpiryState {
+	if !now.Before(notAfter) {
+		return CertExpiryExpired
+	}
+	if notAfter.Sub(now) <= warningWindow {
+		return CertExpiryExpiring
+	}
+	return CertExpiryValid
+}
+
+// collectCertExpiries reads the expiry of each of the Vizier TLS certificates. Secrets that do not exist,
+// such as those for a Vizier that does not run etcd, are skipped. Secrets that exist but cannot be read or
+// parsed are reported with an unknown state.
+func (v *K8sVizierInfo) collectCertExpiries(ctx context.Context, now time.Time) []*CertExpiry {
+	if !v.collectorAvailable(collectorCertExpiries) {
+		return nil
+	}
+	expiries := make([]*CertExpiry, 0, len(vizierTLSCerts))
+	// Errors are cached per secret, so that each secret is only fetched once.
+	secretData := make(map[string]map[string][]byte)
+	secretErrs := make(map[string]error)
+
+	for _, ref := range vizierTLSCerts {
+		data, fetched := secretData[ref.secret]
+		err := secretErrs[ref.secret]
+		if !fetched && err == nil {
+			secret, getErr := v.clientset.CoreV1().Secrets(v.ns).Get(ctx, ref.secret, metav1.GetOptions{})
+			if getErr != nil {
+				err = getErr
+				secretErrs[ref.secret] = err
+				if !k8sErrors.IsNotFound(err) {
+					recordK8sAPIError("secrets")
+				}
+			} else {
+				data = secret.Data
+				secretData[ref.secret] = data
+			}
+		}
+
+		if k8sErrors.IsNotFound(err) {
+			continue
+		}
+
+		expiry := &CertExpiry{Secret: ref.secret, Key: ref.key}
+		expiries = append(expiries, expiry)
+		if err != nil {
+			// Only the status is reported, since the error may include details of the request.
+			if k8sErrors.IsForbidden(err) {
+				expiry.UnknownReason = "secret is not readable"
+			} else {
+				expiry.UnknownReason = "failed to get secret"
+			}
+			log.WithField("cert", expiry.Name()).WithField("reason", k8sErrors.ReasonForError(err)).Warn("Failed to get TLS cert secret")
+			continue
+		}
+
+		certData, ok := data[ref.key]
+		if !ok {
+			expiry.UnknownReason = "certificate is missing from secret"
+			continue
+		}
+		notAfter, err := parseCertNotAfter(certData)
+		if err != nil {
+			expiry.UnknownReason = "certificate could not be parsed"
+			log.WithField("cert", expiry.Name()).Warn("Failed to parse TLS cert")
+			continue
+		}
+
+		expiry.NotAfter = notAfter
+		expiry.DaysUntilExpiry = int32(math.Floor(notAfter.Sub(now).Hours() / 24))
+		expiry.State = getCertExpiryState(now, notAfter, v.certExpiryWarningWindow)
+	}
+
+	return expiries
+}
+
+// refreshCertExpiries collects the TLS certificate expiries, if they have not been collected within the refresh period.
+func (v *K8sVizierInfo) refreshCertExpiries(ctx context.Context, now time.Time) {
+	v.mu.Lock()
+	stale := v.certExpiriesLastUpdated.IsZero() || now.Sub(v.certExpiriesLastUpdated) >= certExpiryRefreshPeriod
+	v.mu.Unlock()
+	if !stale {
+		return
+	}
+
+	expiries := v.collectCertExpiries(ctx, now)
+	if ctx.Err() != nil {
+		// Keep the previous results rather than those of a partial collection.
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.certExpiries = expiries
+	v.certExpiriesLastUpdated = now
+}
+
+func copyCertExpiries(expiries []*CertExpiry) []*CertExpiry {
+	if expiries == nil {
+		return nil
+	}
+	clone := make([]*CertExpiry, len(expiries))
+	for i, e := range expiries {
+		c := *e
+		clone[i] = &c
+	}
+	return clone
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func makeCertPEM(t *testing.T, notAfter time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "pixie"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestCollectCertExpiries(t *testing.T) {
+	// Certs only store their expiry to the second.
+	now := time.Now().Truncate(time.Second)
+	clientset := fake.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "service-tls-certs", Namespace: testNamespace},
+			Data: map[string][]byte{
+				"ca.crt":     makeCertPEM(t, now.Add(365*24*time.Hour)),
+				"server.crt": makeCertPEM(t, now.Add(3*24*time.Hour+time.Hour)),
+				"server.key": []byte("not a real key"),
+				"client.crt": []byte("not a cert"),
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "proxy-tls-certs", Namespace: testNamespace},
+		},
+	)
+	clientset.PrependReactor("get", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.GetAction).GetName() != "proxy-tls-certs" {
+			return false, nil, nil
+		}
+		return true, nil, k8serrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "proxy-tls-certs", nil)
+	})
+	vzInfo := &K8sVizierInfo{
+		ns:                      testNamespace,
+		clientset:               clientset,
+		certExpiryWarningWindow: 14 * 24 * time.Hour,
+	}
+
+	expiries := vzInfo.collectCertExpiries(context.Background(), now)
+
+	// The etcd secrets do not exist, so they are skipped.
+	require.Len(t, expiries, 4)
+	assert.Equal(t, "service-tls-certs/ca.crt", expiries[0].Name())
+	assert.Equal(t, CertExpiryValid, expiries[0].State)
+	assert.Equal(t, int32(365), expiries[0].DaysUntilExpiry)
+
+	assert.Equal(t, "service-tls-certs/server.crt", expiries[1].Name())
+	assert.Equal(t, CertExpiryExpiring, expiries[1].State)
+	assert.Equal(t, int32(3), expiries[1].DaysUntilExpiry)
+
+	assert.Equal(t, "service-tls-certs/client.crt", expiries[2].Name())
+	assert.Equal(t, CertExpiryUnknown, expiries[2].State)
+	assert.Equal(t, "certificate could not be parsed", expiries[2].UnknownReason)
+
+	assert.Equal(t, "proxy-tls-certs/tls.crt", expiries[3].Name())
+	assert.Equal(t, CertExpiryUnknown, expiries[3].State)
+	assert.Equal(t, "secret is not readable", expiries[3].UnknownReason)
+
+	state := computeVizierState(&K8sState{LastUpdated: now, CertExpiries: expiries})
+	assert.Equal(t, VizierHealthDegraded, state.Health)
+	assert.Equal(t, []string{
+		"TLS cert service-tls-certs/server.crt expires in 3 days",
+		"TLS cert service-tls-certs/client.crt expiry unknown: certificate could not be parsed",
+		"TLS cert proxy-tls-certs/tls.crt expiry unknown: secret is not readable",
+	}, state.Reasons)
+	assert.NotContains(t, state.Message(), "not a real key")
+}
+
+func TestGetCertExpiryState(t *testing.T) {
+	now := time.Now()
+	window := 14 * 24 * time.Hour
+	assert.Equal(t, CertExpiryValid, getCertExpiryState(now, now.Add(window+time.Minute), window))
+	assert.Equal(t, CertExpiryExpiring, getCertExpiryState(now, now.Add(window), window))
+	assert.Equal(t, CertExpiryExpired, getCertExpiryState(now, now, window))
+	assert.Equal(t, CertExpiryExpired, getCertExpiryState(now, now.Add(-time.Hour), window))
+}
+
+func TestCheckCertExpiries_Expired(t *testing.T) {
+	health, reasons := checkCertExpiries(&K8sState{
+		CertExpiries: []*CertExpiry{
+			{Secret: "service-tls-certs", Key: "server.crt", State: CertExpiryExpired, DaysUntilExpiry: -2},
+		},
+	})
+	assert.Equal(t, VizierHealthUnhealthy, health)
+	assert.Equal(t, []string{"TLS cert service-tls-certs/server.crt expired 2 days ago"}, reasons)
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Cluster-scale statistics change slowly and are expensive to collect on large clusters.
+const clusterStatsRefreshPeriod = 5 * time.Minute
+
+// ClusterStats describes the scale of the cluster that the Vizier is running on.
+type ClusterStats struct {
+	// The number of nodes on the cluster.
+	NumNodes int32
+	// The number of pods on the cluster, across all namespaces.
+	NumPods int32
+	// The number of namespaces on the cluster.
+	NumNamespaces int32
+	// The total allocatable CPU across all nodes, in millicores.
+	AllocatableCPUMillis int64
+	// The total allocatable memory across all nodes, in bytes.
+	AllocatableMemoryBytes int64
+	// The kernel, OS and container runtime versions of each node.
+	Nodes []NodeInfo
+	// The number of nodes running each kernel version.
+	KernelVersions map[string]int32
+	// Incomplete is set if any of the statistics could not be collected. Those statistics are zero.
+	Incomplete bool
+	// The last time these statistics were collected.
+	LastUpdated time.Time
+}
+
+// collectClusterStats collects the cluster-scale statistics. Failing to list one resource, for example due
+// to missing permissions, only zeroes the statistics derived from that resource.
+func (v *K8sVizierInfo) collectClusterStats(ctx context.Context, now time.Time) *ClusterStats {
+	stats := &ClusterStats{LastUpdated: now}
+
+	if !v.collectorAvailable(collectorNodes) {
+		stats.Incomplete = true
+	} else if nodes, err := v.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{}); err != nil {
+		log.WithError(err).Warn("Failed to list nodes for cluster stats")
+		stats.Incomplete = true
+	} else {
+		stats.NumNodes = int32(len(nodes.Items))
+		for i, n := range nodes.Items {
+			stats.AllocatableCPUMillis += n.Status.Allocatable.Cpu().MilliValue()
+			stats.AllocatableMemoryBytes += n.Status.Allocatable.Memory().Value()
+			stats.Nodes = append(stats.Nodes, getNodeInfo(&nodes.Items[i]))
+		}
+		stats.KernelVersions = countKernelVersions(stats.Nodes)
+	}
+
+	pods, err := v.clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.WithError(err).Warn("Failed to list pods for cluster stats")
+		stats.Incomplete = true
+	} else {
+		stats.NumPods = int32(len(pods.Items))
+	}
+
+	namespaces, err := v.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.WithError(err).Warn("Failed to list namespaces for cluster stats")
+		stats.Incomplete = true
+	} else {
+		stats.NumNamespaces = int32(len(namespaces.Items))
+	}
+
+	return stats
+}
+
+// refreshClusterStats collects the cluster-scale statistics, if they have not been collected within the refresh period.
+func (v *K8sVizierInfo) refreshClusterStats(ctx context.Context, now time.Time) {
+	v.mu.Lock()
+	stale := v.clusterStats == nil || v.clusterStatsInvalidated || now.Sub(v.clusterStats.LastUpdated) >= clusterStatsRefreshPeriod
+	v.clusterStatsInvalidated = false
+	v.mu.Unlock()
+	if !stale {
+		return
+	}
+
+	stats := v.collectClusterStats(ctx, now)
+	if ctx.Err() != nil {
+		// Keep the previous results rather than those of a partial collection, and retry on the next update.
+		v.invalidateClusterStats()
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.clusterStats = stats
+}
+
+// GetClusterStats gets the cluster-scale statistics, as of the last time they were collected.
+func (v *K8sVizierInfo) GetClusterStats() *ClusterStats {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.getClusterStats()
+}
+
+// getClusterStats returns a copy of the current cluster stats. The caller must hold the lock.
+func (v *K8sVizierInfo) getClusterStats() *ClusterStats {
+	if v.clusterStats == nil {
+		return nil
+	}
+	stats := *v.clusterStats
+	return &stats
+}
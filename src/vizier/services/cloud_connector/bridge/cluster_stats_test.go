@@ -0,0 +1,121 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func makeNode(name, cpu, memory string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse(cpu),
+				corev1.ResourceMemory: resource.MustParse(memory),
+			},
+		},
+	}
+}
+
+func makeClusterStatsClientset() *fake.Clientset {
+	return fake.NewSimpleClientset(
+		makeNode("node-1", "2", "4Gi"),
+		makeNode("node-2", "1500m", "2Gi"),
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "pl"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		makePod("vizier-metadata-0", nil),
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app-0", Namespace: "default"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app-1", Namespace: "default"}},
+	)
+}
+
+func TestCollectClusterStats(t *testing.T) {
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: makeClusterStatsClientset(),
+	}
+
+	now := time.Now()
+	stats := vzInfo.collectClusterStats(context.Background(), now)
+	assert.Equal(t, &ClusterStats{
+		NumNodes:               2,
+		NumPods:                3,
+		NumNamespaces:          2,
+		AllocatableCPUMillis:   3500,
+		AllocatableMemoryBytes: 6 * 1024 * 1024 * 1024,
+		Nodes:                  []NodeInfo{{Name: "node-1"}, {Name: "node-2"}},
+		KernelVersions:         map[string]int32{"": 2},
+		LastUpdated:            now,
+	}, stats)
+}
+
+func TestCollectClusterStats_Forbidden(t *testing.T) {
+	clientset := makeClusterStatsClientset()
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "", nil)
+	})
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	stats := vzInfo.collectClusterStats(context.Background(), time.Now())
+	assert.True(t, stats.Incomplete)
+	assert.Equal(t, int32(0), stats.NumPods)
+	assert.Equal(t, int32(2), stats.NumNodes)
+	assert.Equal(t, int32(2), stats.NumNamespaces)
+}
+
+func TestRefreshClusterStats(t *testing.T) {
+	clientset := makeClusterStatsClientset()
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+	assert.Nil(t, vzInfo.GetClusterStats())
+
+	now := time.Now()
+	vzInfo.refreshClusterStats(context.Background(), now)
+	stats := vzInfo.GetClusterStats()
+	require.NotNil(t, stats)
+	assert.Equal(t, int32(2), stats.NumNamespaces)
+
+	// The cached stats should be used until the refresh period has passed.
+	err := clientset.Tracker().Add(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "new"}})
+	require.NoError(t, err)
+	vzInfo.refreshClusterStats(context.Background(), now.Add(time.Minute))
+	assert.Equal(t, int32(2), vzInfo.GetClusterStats().NumNamespaces)
+
+	vzInfo.refreshClusterStats(context.Background(), now.Add(clusterStatsRefreshPeriod))
+	assert.Equal(t, int32(3), vzInfo.GetClusterStats().NumNamespaces)
+}
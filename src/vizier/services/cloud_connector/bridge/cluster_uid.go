@@ -0,0 +1,158 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gofrs/uuid"
+	log "github.com/sirupsen/logrus"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	clusterSecretsName = "pl-cluster-secrets"
+	// The keys in the cluster secrets that the cluster UID, and where it came from, are persisted under.
+	clusterUIDKey       = "cluster-uid"
+	clusterUIDSourceKey = "cluster-uid-source"
+)
+
+// ClusterUIDSource describes where the cluster UID came from.
+type ClusterUIDSource string
+
+const (
+	// ClusterUIDSourceKubeSystem is the UID of the kube-system namespace.
+	ClusterUIDSourceKubeSystem ClusterUIDSource = "kube-system"
+	// ClusterUIDSourceVizierNamespace is the UID of the Vizier namespace, used when kube-system can't be read.
+	ClusterUIDSourceVizierNamespace ClusterUIDSource = "vizier-namespace"
+	// ClusterUIDSourceGenerated is a randomly generated UID, used when neither namespace can be read.
+	ClusterUIDSourceGenerated ClusterUIDSource = "generated"
+)
+
+// isUnreadable returns whether the error means that the object can't be read at all, rather than that reading it
+// failed this time.
+func isUnreadable(err error) bool {
+	return k8sErrors.IsForbidden(err) || k8sErrors.IsNotFound(err)
+}
+
+// resolveClusterUID finds a UID for the cluster, trying each source in order of preference. A source is only
+// skipped if it can't be read at all. The UID is persisted, so falling back after a transient error, such as a
+// timeout, would change the identity of the cluster for good.
+func (v *K8sVizierInfo) resolveClusterUID(ctx context.Context) (string, ClusterUIDSource, error) {
+	ksNS, err := v.clientset.CoreV1().Namespaces().Get(ctx, "kube-system", metav1.GetOptions{})
+	if err == nil {
+		return string(ksNS.UID), ClusterUIDSourceKubeSystem, nil
+	}
+	if !isUnreadable(err) {
+		return "", "", err
+	}
+	log.WithError(err).Warn("Failed to get kube-system namespace for the cluster UID, falling back to the vizier namespace")
+
+	vzNS, err := v.clientset.CoreV1().Namespaces().Get(ctx, v.ns, metav1.GetOptions{})
+	if err == nil {
+		return string(vzNS.UID), ClusterUIDSourceVizierNamespace, nil
+	}
+	if !isUnreadable(err) {
+		return "", "", err
+	}
+	log.WithError(err).Warn("Failed to get vizier namespace for the cluster UID, falling back to a generated UID")
+
+	uid, err := uuid.NewV4()
+	if err != nil {
+		return "", "", err
+	}
+	return uid.String(), ClusterUIDSourceGenerated, nil
+}
+
+// GetClusterUID gets UID for the cluster. This is usually the kube-system namespace UID. The UID is persisted in
+// the cluster secrets, and any persisted UID is preferred, so that the identity of the cluster doesn't change
+// if the UID sources that are readable change.
+func (v *K8sVizierInfo) GetClusterUID(ctx context.Context) (string, error) {
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+
+	secrets := v.clientset.CoreV1().Secrets(v.ns)
+	s, err := secrets.Get(ctx, clusterSecretsName, metav1.GetOptions{})
+	if err != nil {
+		// Installs whose RBAC doesn't allow reading the cluster secrets can still resolve the UID, as they
+		// did before it was persisted.
+		if !isUnreadable(err) {
+			return "", err
+		}
+		if k8sErrors.IsForbidden(err) {
+			log.WithError(err).Warn("Failed to read the cluster secrets, not using the persisted cluster UID")
+		}
+		s = nil
+	}
+	if s != nil {
+		if uid := string(s.Data[clusterUIDKey]); uid != "" {
+			v.setClusterUIDSource(ClusterUIDSource(s.Data[clusterUIDSourceKey]))
+			return uid, nil
+		}
+	}
+
+	uid, source, err := v.resolveClusterUID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// A generated UID is only useful if it is the same across restarts.
+	if s == nil {
+		if source == ClusterUIDSourceGenerated {
+			return "", fmt.Errorf("cannot persist generated cluster UID, secret %s is unavailable", clusterSecretsName)
+		}
+		log.WithField("source", source).Warn("Cluster secrets are unavailable, not persisting the cluster UID")
+	} else {
+		if s.Data == nil {
+			s.Data = make(map[string][]byte)
+		}
+		s.Data[clusterUIDKey] = []byte(uid)
+		s.Data[clusterUIDSourceKey] = []byte(source)
+		if _, err := secrets.Update(ctx, s, metav1.UpdateOptions{}); err != nil {
+			if source == ClusterUIDSourceGenerated {
+				return "", fmt.Errorf("failed to persist generated cluster UID: %w", err)
+			}
+			log.WithError(err).WithField("source", source).Warn("Failed to persist the cluster UID")
+		}
+	}
+
+	v.setClusterUIDSource(source)
+	return uid, nil
+}
+
+func (v *K8sVizierInfo) setClusterUIDSource(source ClusterUIDSource) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.clusterUIDSource != source {
+		log.WithField("source", source).Info("Using cluster UID")
+	}
+	v.clusterUIDSource = source
+}
+
+// ClusterUIDSource returns where the cluster UID came from, as of the last time it was fetched. This is empty if
+// the cluster UID has not been fetched yet.
+func (v *K8sVizierInfo) ClusterUIDSource() ClusterUIDSource {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.clusterUIDSource
+}
@@ -0,0 +1,197 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func makeClusterUIDClientset(withSecret bool, forbiddenNamespaces ...string) *fake.Clientset {
+	objs := []runtime.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system", UID: types.UID("kube-system-uid")}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNamespace, UID: types.UID("pl-uid")}},
+	}
+	if withSecret {
+		objs = append(objs, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: clusterSecretsName, Namespace: testNamespace},
+			Data:       map[string][]byte{"cluster-id": []byte("abcd")},
+		})
+	}
+	clientset := fake.NewSimpleClientset(objs...)
+	clientset.PrependReactor("get", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		name := action.(k8stesting.GetAction).GetName()
+		for _, ns := range forbiddenNamespaces {
+			if ns == name {
+				return true, nil, k8serrors.NewForbidden(corev1.Resource("namespaces"), name, nil)
+			}
+		}
+		return false, nil, nil
+	})
+	return clientset
+}
+
+func getPersistedClusterUID(t *testing.T, clientset *fake.Clientset) (string, string) {
+	s, err := clientset.CoreV1().Secrets(testNamespace).Get(context.Background(), clusterSecretsName, metav1.GetOptions{})
+	require.NoError(t, err)
+	return string(s.Data[clusterUIDKey]), string(s.Data[clusterUIDSourceKey])
+}
+
+func TestGetClusterUID(t *testing.T) {
+	tests := []struct {
+		name                string
+		forbiddenNamespaces []string
+		expectedUID         string
+		expectedSource      ClusterUIDSource
+	}{
+		{
+			name:           "kube-system",
+			expectedUID:    "kube-system-uid",
+			expectedSource: ClusterUIDSourceKubeSystem,
+		},
+		{
+			name:                "vizier namespace",
+			forbiddenNamespaces: []string{"kube-system"},
+			expectedUID:         "pl-uid",
+			expectedSource:      ClusterUIDSourceVizierNamespace,
+		},
+		{
+			name:                "generated",
+			forbiddenNamespaces: []string{"kube-system", testNamespace},
+			expectedSource:      ClusterUIDSourceGenerated,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clientset := makeClusterUIDClientset(true, test.forbiddenNamespaces...)
+			vzInfo := &K8sVizierInfo{
+				ns:        testNamespace,
+				clientset: clientset,
+			}
+
+			uid, err := vzInfo.GetClusterUID(context.Background())
+			require.NoError(t, err)
+			require.NotEmpty(t, uid)
+			if test.expectedUID != "" {
+				assert.Equal(t, test.expectedUID, uid)
+			}
+			assert.Equal(t, test.expectedSource, vzInfo.ClusterUIDSource())
+
+			persistedUID, persistedSource := getPersistedClusterUID(t, clientset)
+			assert.Equal(t, uid, persistedUID)
+			assert.Equal(t, string(test.expectedSource), persistedSource)
+
+			// The UID is stable across restarts.
+			restarted := &K8sVizierInfo{
+				ns:        testNamespace,
+				clientset: clientset,
+			}
+			uidAfterRestart, err := restarted.GetClusterUID(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, uid, uidAfterRestart)
+			assert.Equal(t, test.expectedSource, restarted.ClusterUIDSource())
+		})
+	}
+}
+
+func TestGetClusterUID_PrefersPersisted(t *testing.T) {
+	// The UID was persisted while kube-system was unreadable.
+	clientset := makeClusterUIDClientset(true, "kube-system")
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+	uid, err := vzInfo.GetClusterUID(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "pl-uid", uid)
+
+	// Now that kube-system is readable, the cluster keeps its identity.
+	clientset.ReactionChain = clientset.ReactionChain[1:]
+	uid, err = vzInfo.GetClusterUID(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "pl-uid", uid)
+	assert.Equal(t, ClusterUIDSourceVizierNamespace, vzInfo.ClusterUIDSource())
+}
+
+func TestGetClusterUID_NoSecret(t *testing.T) {
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: makeClusterUIDClientset(false),
+	}
+	uid, err := vzInfo.GetClusterUID(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "kube-system-uid", uid)
+
+	// A generated UID that can't be persisted would change on every restart.
+	vzInfo = &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: makeClusterUIDClientset(false, "kube-system", testNamespace),
+	}
+	_, err = vzInfo.GetClusterUID(context.Background())
+	assert.Error(t, err)
+}
+
+func TestGetClusterUID_TransientErrorDoesNotFallBack(t *testing.T) {
+	clientset := makeClusterUIDClientset(true)
+	clientset.PrependReactor("get", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.GetAction).GetName() == "kube-system" {
+			return true, nil, k8serrors.NewServiceUnavailable("etcd is unavailable")
+		}
+		return false, nil, nil
+	})
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+	_, err := vzInfo.GetClusterUID(context.Background())
+	require.Error(t, err)
+	assert.True(t, k8serrors.IsServiceUnavailable(err))
+
+	// Nothing is persisted, so the next attempt can still use kube-system.
+	persistedUID, persistedSource := getPersistedClusterUID(t, clientset)
+	assert.Empty(t, persistedUID)
+	assert.Empty(t, persistedSource)
+	assert.Empty(t, vzInfo.ClusterUIDSource())
+}
+
+func TestGetClusterUID_ForbiddenSecret(t *testing.T) {
+	clientset := makeClusterUIDClientset(true)
+	clientset.PrependReactor("get", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewForbidden(corev1.Resource("secrets"), clusterSecretsName, nil)
+	})
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+	uid, err := vzInfo.GetClusterUID(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "kube-system-uid", uid)
+	assert.Equal(t, ClusterUIDSourceKubeSystem, vzInfo.ClusterUIDSource())
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// setLastUpdateError records the error of the K8s state update that started at the given time. A nil error
+// clears the last error.
+func (v *K8sVizierInfo) setLastUpdateError(err error, start time.Time) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.lastUpdateError = err
+	if err != nil {
+		v.lastUpdateErrorTime = start
+	}
+}
+
+// PodStatusSummary is the part of a pod's status shown by the debug status endpoint.
+type PodStatusSummary struct {
+	Phase         string `json:"phase"`
+	Reason        string `json:"reason,omitempty"`
+	StatusMessage string `json:"statusMessage,omitempty"`
+	RestartCount  int64  `json:"restartCount"`
+}
+
+// CollectorStatus is the state of the K8s state collector, as shown by the debug status endpoint. It only
+// holds what is already reported to cloud or logged, and never the contents of any secret.
+type CollectorStatus struct {
+	// LastCollected is when the K8s state was last updated, and LastDelivered is when cloud last acknowledged
+	// a heartbeat. A LastDelivered that falls behind means the state is collected, but not reaching cloud.
+	LastCollected time.Time `json:"lastCollected"`
+	LastDelivered time.Time `json:"lastDelivered,omitempty"`
+	Stale         bool      `json:"stale"`
+	// The error of the last K8s state update, if it failed.
+	LastError     string    `json:"lastError,omitempty"`
+	LastErrorTime time.Time `json:"lastErrorTime,omitempty"`
+	Health        string    `json:"health"`
+	Reasons       []string  `json:"reasons"`
+	// The statuses of the control plane pods and the unhealthy data plane pods, keyed by pod name.
+	PodStatuses map[string]*PodStatusSummary `json:"podStatuses"`
+	// The components of the Vizier pods on each node, keyed by node name.
+	PodPlacement             map[string][]string `json:"podPlacement,omitempty"`
+	PodPlacementOmittedNodes int                 `json:"podPlacementOmittedNodes,omitempty"`
+	ClusterUIDSource         ClusterUIDSource    `json:"clusterUIDSource,omitempty"`
+	DeployMethod             DeployMethod        `json:"deployMethod,omitempty"`
+	DeployVersion            string              `json:"deployVersion,omitempty"`
+	// The cluster networking parameters, which are "unknown" if they could not be detected.
+	PodCIDRs            []string `json:"podCIDRs,omitempty"`
+	ServiceCIDR         string   `json:"serviceCIDR,omitempty"`
+	KubernetesServiceIP string   `json:"kubernetesServiceIP,omitempty"`
+	CNI                 string   `json:"cni,omitempty"`
+	// When the Vizier was installed, and first connected to cloud. The ages are never negative, even if the
+	// clocks are skewed.
+	InstalledAt       time.Time         `json:"installedAt,omitempty"`
+	InstalledAtSource InstallTimeSource `json:"installedAtSource,omitempty"`
+	InstallAge        string            `json:"installAge,omitempty"`
+	FirstConnectedAt  time.Time         `json:"firstConnectedAt,omitempty"`
+	ConnectedAge      string            `json:"connectedAge,omitempty"`
+}
+
+// GetCollectorStatus returns the current state of the K8s state collector.
+func (v *K8sVizierInfo) GetCollectorStatus() *CollectorStatus {
+	state := v.GetK8sState()
+
+	status := &CollectorStatus{
+		LastCollected: state.LastUpdated,
+		Stale:         state.Stale,
+		Health:        state.VizierState.Health.String(),
+		Reasons:       state.VizierState.Reasons,
+		PodStatuses:   make(map[string]*PodStatusSummary),
+	}
+	if state.PodPlacement != nil {
+		status.PodPlacement = state.PodPlacement.Nodes
+		status.PodPlacementOmittedNodes = state.PodPlacement.OmittedNodes
+	}
+	for name, p := range mergePodStatuses(state.ControlPlanePodStatuses, state.UnhealthyDataPlanePodStatuses) {
+		status.PodStatuses[name] = &PodStatusSummary{
+			Phase:         p.Status.String(),
+			Reason:        p.Reason,
+			StatusMessage: p.StatusMessage,
+			RestartCount:  p.RestartCount,
+		}
+	}
+
+	v.mu.Lock()
+	if v.lastUpdateError != nil {
+		status.LastError = v.lastUpdateError.Error()
+		status.LastErrorTime = v.lastUpdateErrorTime
+	}
+	status.LastDelivered = v.lastStatusDelivered
+	status.ClusterUIDSource = v.clusterUIDSource
+	if v.deployInfo != nil {
+		status.DeployMethod = v.deployInfo.Method
+		status.DeployVersion = v.deployInfo.Version
+	}
+	if v.networkInfo != nil {
+		status.PodCIDRs = v.networkInfo.PodCIDRs
+		status.ServiceCIDR = v.networkInfo.ServiceCIDR
+		status.KubernetesServiceIP = v.networkInfo.KubernetesServiceIP
+		status.CNI = v.networkInfo.CNI
+	}
+	if v.installInfo != nil {
+		now := time.Now()
+		status.InstalledAt = v.installInfo.InstalledAt
+		status.InstalledAtSource = v.installInfo.InstalledAtSource
+		if !v.installInfo.InstalledAt.IsZero() {
+			status.InstallAge = v.installInfo.InstallAge(now).Round(time.Second).String()
+		}
+		status.FirstConnectedAt = v.installInfo.FirstConnectedAt
+		if !v.installInfo.FirstConnectedAt.IsZero() {
+			status.ConnectedAge = v.installInfo.ConnectedAge(now).Round(time.Second).String()
+		}
+	}
+	v.mu.Unlock()
+	return status
+}
+
+// NewDebugStatusHandler returns a handler for debugging the K8s state collector in the field. /statusz serves
+// the collector status as JSON, and /healthz succeeds only while the K8s state is fresh.
+func NewDebugStatusHandler(v *K8sVizierInfo) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/statusz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(v.GetCollectorStatus()); err != nil {
+			log.WithError(err).Error("Failed to write the collector status")
+		}
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		state := v.GetK8sState()
+		switch {
+		case state.LastUpdated.IsZero():
+			http.Error(w, "K8s state has not been collected yet", http.StatusServiceUnavailable)
+		case state.Stale:
+			http.Error(w, fmt.Sprintf("K8s state is stale, last updated at %s", state.LastUpdated.UTC().Format(time.RFC3339)), http.StatusServiceUnavailable)
+		default:
+			fmt.Fprintln(w, "ok")
+		}
+	})
+	return mux
+}
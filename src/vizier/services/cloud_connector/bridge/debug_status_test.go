@@ -0,0 +1,162 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"px.dev/pixie/src/shared/cvmsgspb"
+	"px.dev/pixie/src/shared/k8s/metadatapb"
+)
+
+func getDebugStatus(t *testing.T, vzInfo *K8sVizierInfo, path string) (int, string) {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	rec := httptest.NewRecorder()
+	NewDebugStatusHandler(vzInfo).ServeHTTP(rec, req)
+	body, err := io.ReadAll(rec.Result().Body)
+	require.NoError(t, err)
+	return rec.Code, string(body)
+}
+
+func TestDebugStatusHandler_Statusz(t *testing.T) {
+	lastUpdated := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	errorTime := lastUpdated.Add(10 * time.Second)
+	deliveredTime := lastUpdated.Add(-5 * time.Second)
+	vzInfo := &K8sVizierInfo{
+		ns:                  testNamespace,
+		k8sStateLastUpdated: lastUpdated,
+		controlPlanePodStatuses: map[string]*cvmsgspb.PodStatus{
+			"vizier-metadata-0": {Name: "vizier-metadata-0", Status: metadatapb.RUNNING, RestartCount: 2},
+		},
+		unhealthyDataPlanePodStatuses: map[string]*cvmsgspb.PodStatus{
+			"vizier-pem-abcde": {Name: "vizier-pem-abcde", Status: metadatapb.PENDING, Reason: "ImagePullBackOff", StatusMessage: "failed to pull image"},
+		},
+		vizierState:         &VizierState{Health: VizierHealthDegraded, Reasons: []string{"PEM coverage 2/3"}},
+		lastUpdateError:     errors.New("etcd unavailable"),
+		lastUpdateErrorTime: errorTime,
+		lastStatusDelivered: deliveredTime,
+		clusterUIDSource:    ClusterUIDSourceKubeSystem,
+		deployInfo:          &DeployInfo{Method: DeployMethodHelm, Version: "0.11.2"},
+		// Far enough in the past that the state is stale.
+		staleAfter: time.Minute,
+	}
+
+	code, body := getDebugStatus(t, vzInfo, "/statusz")
+	assert.Equal(t, http.StatusOK, code)
+
+	var status CollectorStatus
+	require.NoError(t, json.Unmarshal([]byte(body), &status))
+	assert.Equal(t, CollectorStatus{
+		LastCollected: lastUpdated,
+		LastDelivered: deliveredTime,
+		Stale:         true,
+		LastError:     "etcd unavailable",
+		LastErrorTime: errorTime,
+		Health:        "Degraded",
+		Reasons:       []string{"PEM coverage 2/3"},
+		PodStatuses: map[string]*PodStatusSummary{
+			"vizier-metadata-0": {Phase: "RUNNING", RestartCount: 2},
+			"vizier-pem-abcde":  {Phase: "PENDING", Reason: "ImagePullBackOff", StatusMessage: "failed to pull image"},
+		},
+		ClusterUIDSource: ClusterUIDSourceKubeSystem,
+		DeployMethod:     DeployMethodHelm,
+		DeployVersion:    "0.11.2",
+	}, status)
+
+	// The field names are part of the endpoint's output.
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(body), &fields))
+	for _, name := range []string{"lastCollected", "lastDelivered", "stale", "lastError", "lastErrorTime", "health", "reasons", "podStatuses", "clusterUIDSource", "deployMethod", "deployVersion"} {
+		assert.Contains(t, fields, name)
+	}
+}
+
+func TestDebugStatusHandler_NoSecrets(t *testing.T) {
+	secretValue := "super-secret-signing-key"
+	selector, err := getPodSelector("app=pl-monitoring", false)
+	require.NoError(t, err)
+	objs := expectedSecretObjects(makePod("vizier-metadata-0", map[string]string{"app": "pl-monitoring", "plane": "control"}))
+	for _, obj := range objs {
+		if s, ok := obj.(*corev1.Secret); ok {
+			for key := range s.Data {
+				s.Data[key] = []byte(secretValue)
+			}
+		}
+	}
+	vzInfo := &K8sVizierInfo{
+		ns:          testNamespace,
+		clientset:   fake.NewSimpleClientset(objs...),
+		podSelector: selector,
+	}
+	vzInfo.UpdateK8sState(context.Background())
+
+	code, body := getDebugStatus(t, vzInfo, "/statusz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "vizier-metadata-0")
+	assert.NotContains(t, body, secretValue)
+}
+
+func TestDebugStatusHandler_Healthz(t *testing.T) {
+	clientset := fake.NewSimpleClientset(makePod("vizier-metadata-0", map[string]string{"plane": "control"}))
+	vzInfo := &K8sVizierInfo{
+		ns:         testNamespace,
+		clientset:  clientset,
+		staleAfter: time.Minute,
+	}
+
+	code, body := getDebugStatus(t, vzInfo, "/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, body, "not been collected yet")
+
+	vzInfo.UpdateK8sState(context.Background())
+	code, body = getDebugStatus(t, vzInfo, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok\n", body)
+
+	// A failed update is shown in the status, but the state stays fresh until it is stale.
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewInternalError(errors.New("etcd unavailable"))
+	})
+	vzInfo.UpdateK8sState(context.Background())
+	assert.Contains(t, vzInfo.GetCollectorStatus().LastError, "etcd unavailable")
+	code, _ = getDebugStatus(t, vzInfo, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+
+	vzInfo.mu.Lock()
+	vzInfo.k8sStateLastUpdated = time.Now().Add(-2 * time.Minute)
+	vzInfo.mu.Unlock()
+	code, body = getDebugStatus(t, vzInfo, "/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, body, "K8s state is stale")
+}
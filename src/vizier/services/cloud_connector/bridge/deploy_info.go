@@ -0,0 +1,156 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"px.dev/pixie/src/operator/apis/px.dev/v1alpha1"
+)
+
+// DeployMethod is how the Vizier was installed.
+type DeployMethod string
+
+const (
+	// DeployMethodUnknown indicates that the install method could not be detected.
+	DeployMethodUnknown DeployMethod = "unknown"
+	// DeployMethodHelm indicates that the Vizier was installed with the Helm chart.
+	DeployMethodHelm DeployMethod = "helm"
+	// DeployMethodOperator indicates that the Vizier is managed by the Vizier operator.
+	DeployMethodOperator DeployMethod = "operator"
+	// DeployMethodManifests indicates that the Vizier was installed by applying its manifests directly.
+	DeployMethodManifests DeployMethod = "manifests"
+)
+
+// The annotations and labels that Helm adds to the objects it installs.
+const (
+	helmReleaseNameAnnotation = "meta.helm.sh/release-name"
+	helmChartLabel            = "helm.sh/chart"
+	managedByLabel            = "app.kubernetes.io/managed-by"
+)
+
+// DeployInfo describes how the Vizier was installed.
+type DeployInfo struct {
+	Method DeployMethod
+	// The version of the chart, operator, or manifests that installed the Vizier. Empty if unknown.
+	Version string
+}
+
+// isHelmManaged returns whether the object was installed by Helm.
+func isHelmManaged(meta *metav1.ObjectMeta) bool {
+	return meta.Annotations[helmReleaseNameAnnotation] != "" || meta.Labels[managedByLabel] == "Helm" || meta.Labels[helmChartLabel] != ""
+}
+
+// parseHelmChartVersion returns the version from a helm.sh/chart label, which has the form <name>-<version>.
+// Ex: "vizier-chart-0.11.2" has the version "0.11.2".
+func parseHelmChartVersion(chart string) string {
+	for i := 0; i < len(chart)-1; i++ {
+		if chart[i] == '-' && unicode.IsDigit(rune(chart[i+1])) {
+			return chart[i+1:]
+		}
+	}
+	return ""
+}
+
+// isOwnedByVizier returns whether the object is managed by the Vizier operator, through a Vizier CRD.
+func isOwnedByVizier(meta *metav1.ObjectMeta) bool {
+	for _, ref := range meta.OwnerReferences {
+		if ref.Kind == "Vizier" && strings.HasPrefix(ref.APIVersion, v1alpha1.SchemeGroupVersion.Group+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// detectDeployInfo works out how the Vizier was installed from the Vizier namespace and deployments. Helm is
+// checked first, since the Helm chart installs the operator, which then manages the Vizier. The operator
+// version comes from the Vizier CRD, and is empty if there is none.
+func detectDeployInfo(ns *corev1.Namespace, deployments []appsv1.Deployment, operatorVersion string) *DeployInfo {
+	if ns != nil && isHelmManaged(&ns.ObjectMeta) {
+		return &DeployInfo{Method: DeployMethodHelm, Version: parseHelmChartVersion(ns.Labels[helmChartLabel])}
+	}
+	for i := range deployments {
+		if isHelmManaged(&deployments[i].ObjectMeta) {
+			return &DeployInfo{Method: DeployMethodHelm, Version: parseHelmChartVersion(deployments[i].Labels[helmChartLabel])}
+		}
+	}
+	for i := range deployments {
+		if isOwnedByVizier(&deployments[i].ObjectMeta) {
+			return &DeployInfo{Method: DeployMethodOperator, Version: operatorVersion}
+		}
+	}
+	// Otherwise, the manifests are versioned along with the images they reference.
+	for i := range deployments {
+		for _, c := range deployments[i].Spec.Template.Spec.Containers {
+			repository, tag, _ := parseImageRef(c.Image)
+			if !isPixieImage(repository) {
+				continue
+			}
+			return &DeployInfo{Method: DeployMethodManifests, Version: tag}
+		}
+	}
+	return &DeployInfo{Method: DeployMethodUnknown}
+}
+
+// getDeployInfo detects how the Vizier was installed. Anything that can't be read is left out of the detection,
+// so the method is DeployMethodUnknown if nothing can be read.
+func (v *K8sVizierInfo) getDeployInfo(ctx context.Context) *DeployInfo {
+	ns, err := v.clientset.CoreV1().Namespaces().Get(ctx, v.ns, metav1.GetOptions{})
+	if err != nil {
+		log.WithError(err).Debug("Failed to get the Vizier namespace to detect the deploy method")
+		ns = nil
+	}
+
+	var deployments []appsv1.Deployment
+	deploymentList, err := v.clientset.AppsV1().Deployments(v.ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		recordK8sAPIError("deployments")
+		log.WithError(err).Warn("Failed to list deployments to detect the deploy method")
+	} else {
+		deployments = deploymentList.Items
+	}
+
+	operatorVersion := ""
+	if v.vzClient != nil {
+		if vz, err := v.GetVizierCRD(); err == nil {
+			operatorVersion = vz.Status.OperatorVersion
+		}
+	}
+	return detectDeployInfo(ns, deployments, operatorVersion)
+}
+
+// DeployInfo returns how the Vizier was installed, as of the last time the cluster info was fetched. This is nil
+// if the cluster info has not been fetched yet.
+func (v *K8sVizierInfo) DeployInfo() *DeployInfo {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.deployInfo == nil {
+		return nil
+	}
+	info := *v.deployInfo
+	return &info
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"px.dev/pixie/src/operator/apis/px.dev/v1alpha1"
+	vzfake "px.dev/pixie/src/operator/client/versioned/fake"
+)
+
+func makeDeployment(name, image string, labels map[string]string, annotations map[string]string, owners ...metav1.OwnerReference) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       testNamespace,
+			Labels:          labels,
+			Annotations:     annotations,
+			OwnerReferences: owners,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "app", Image: image}},
+				},
+			},
+		},
+	}
+}
+
+func TestParseHelmChartVersion(t *testing.T) {
+	assert.Equal(t, "0.11.2", parseHelmChartVersion("vizier-chart-0.11.2"))
+	assert.Equal(t, "1.0.0-rc1", parseHelmChartVersion("pixie-operator-chart-1.0.0-rc1"))
+	assert.Equal(t, "", parseHelmChartVersion("vizier-chart"))
+	assert.Equal(t, "", parseHelmChartVersion(""))
+}
+
+func TestGetDeployInfo(t *testing.T) {
+	pemImage := publicImageRepo + "/vizier/pem_image:0.12.0"
+	vizierOwner := metav1.OwnerReference{APIVersion: "px.dev/v1alpha1", Kind: "Vizier", Name: "pixie"}
+
+	tests := []struct {
+		name            string
+		objs            []runtime.Object
+		expectedMethod  DeployMethod
+		expectedVersion string
+	}{
+		{
+			name: "helm namespace",
+			objs: []runtime.Object{
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+					Name:        testNamespace,
+					Labels:      map[string]string{managedByLabel: "Helm", helmChartLabel: "vizier-chart-0.11.2"},
+					Annotations: map[string]string{helmReleaseNameAnnotation: "pixie"},
+				}},
+				makeDeployment("vizier-query-broker", pemImage, nil, nil, vizierOwner),
+			},
+			expectedMethod:  DeployMethodHelm,
+			expectedVersion: "0.11.2",
+		},
+		{
+			name: "helm deployment",
+			objs: []runtime.Object{
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNamespace}},
+				makeDeployment("vizier-query-broker", pemImage, map[string]string{helmChartLabel: "vizier-chart-0.10.0"}, nil),
+			},
+			expectedMethod:  DeployMethodHelm,
+			expectedVersion: "0.10.0",
+		},
+		{
+			name: "operator",
+			objs: []runtime.Object{
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNamespace}},
+				makeDeployment("vizier-query-broker", pemImage, nil, nil, vizierOwner),
+			},
+			expectedMethod:  DeployMethodOperator,
+			expectedVersion: "0.0.30",
+		},
+		{
+			name: "manifests",
+			objs: []runtime.Object{
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNamespace}},
+				makeDeployment("kelvin", "nats:2.8", nil, nil),
+				makeDeployment("vizier-query-broker", pemImage, nil, nil),
+			},
+			expectedMethod:  DeployMethodManifests,
+			expectedVersion: "0.12.0",
+		},
+		{
+			name: "unknown",
+			objs: []runtime.Object{
+				makeDeployment("something-else", "nginx:1.21", nil, nil),
+			},
+			expectedMethod: DeployMethodUnknown,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vz := &v1alpha1.Vizier{
+				ObjectMeta: metav1.ObjectMeta{Name: "pixie", Namespace: testNamespace},
+				Status:     v1alpha1.VizierStatus{OperatorVersion: "0.0.30"},
+			}
+			vzInfo := &K8sVizierInfo{
+				ns:        testNamespace,
+				clientset: fake.NewSimpleClientset(test.objs...),
+				vzClient:  vzfake.NewSimpleClientset(vz),
+			}
+
+			info := vzInfo.getDeployInfo(context.Background())
+			require.NotNil(t, info)
+			assert.Equal(t, test.expectedMethod, info.Method)
+			assert.Equal(t, test.expectedVersion, info.Version)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"time"
+)
+
+// ForceUpdate collects the K8s state now, rather than waiting for the next periodic update, and returns it.
+// Concurrent callers share a single collection. If a periodic update is running, the collection waits for it
+// to finish, so that the state is always collected after the call. The collection is bounded by the deadline
+// of the caller that started it, capped at the update period, but canceling that caller doesn't cancel it for
+// the callers that joined it.
+func (v *K8sVizierInfo) ForceUpdate(ctx context.Context) (*K8sState, error) {
+	deadline := forcedUpdateDeadline(ctx, time.Now())
+	ch := v.forcedUpdates.DoChan("k8s-state", func() (interface{}, error) {
+		v.updateMu.Lock()
+		defer v.updateMu.Unlock()
+
+		updateCtx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+		v.updateK8sState(updateCtx)
+
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		v.lastForcedUpdate = time.Now()
+		return nil, v.lastUpdateError
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return v.GetK8sState(), nil
+	}
+}
+
+// forcedUpdateDeadline returns the deadline for a forced update started by a caller with the given context: the
+// caller's deadline, capped at the update period.
+func forcedUpdateDeadline(ctx context.Context, now time.Time) time.Time {
+	deadline := now.Add(k8sStateUpdatePeriod)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		return d
+	}
+	return deadline
+}
+
+// recentlyForced returns whether a forced update collected the K8s state within the last half update period,
+// in which case the next periodic update can be skipped.
+func (v *K8sVizierInfo) recentlyForced(now time.Time) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return !v.lastForcedUpdate.IsZero() && now.Sub(v.lastForcedUpdate) < k8sStateUpdatePeriod/2
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestForceUpdate(t *testing.T) {
+	vzInfo := &K8sVizierInfo{
+		ns: testNamespace,
+		clientset: fake.NewSimpleClientset(
+			makePod("vizier-metadata-0", map[string]string{"plane": "control"}),
+			makePod("vizier-pem-abcde", map[string]string{"name": "vizier-pem", "plane": "data"}),
+			makePod("kelvin-0", map[string]string{"name": "kelvin", "plane": "data"}),
+		),
+	}
+	assert.False(t, vzInfo.recentlyForced(time.Now()))
+
+	state, err := vzInfo.ForceUpdate(context.Background())
+	require.NoError(t, err)
+	assert.Contains(t, state.ControlPlanePodStatuses, "vizier-metadata-0")
+	assert.False(t, state.LastUpdated.IsZero())
+
+	// The Vizier pods are returned in the same form as GetVizierPods, sorted by name.
+	var controlPods, dataPods []string
+	for _, p := range state.ControlPlanePods {
+		controlPods = append(controlPods, p.Name)
+	}
+	for _, p := range state.DataPlanePods {
+		dataPods = append(dataPods, p.Name)
+	}
+	assert.Equal(t, []string{testNamespace + "/vizier-metadata-0"}, controlPods)
+	assert.Equal(t, []string{testNamespace + "/kelvin-0", testNamespace + "/vizier-pem-abcde"}, dataPods)
+
+	// The next periodic update is skipped, but not the one after it.
+	assert.True(t, vzInfo.recentlyForced(time.Now()))
+	assert.False(t, vzInfo.recentlyForced(time.Now().Add(k8sStateUpdatePeriod)))
+}
+
+func TestForceUpdate_Error(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewInternalError(errors.New("etcd unavailable"))
+	})
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	state, err := vzInfo.ForceUpdate(context.Background())
+	assert.Nil(t, state)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "etcd unavailable")
+}
+
+func TestForceUpdate_CoalescesCallers(t *testing.T) {
+	clientset := fake.NewSimpleClientset(makePod("vizier-metadata-0", map[string]string{"plane": "control"}))
+	// Hold up the first sweep until the other callers have joined it.
+	var sweeps int32
+	listing := make(chan struct{}, 1)
+	release := make(chan struct{})
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if !strings.Contains(action.(k8stesting.ListAction).GetListRestrictions().Labels.String(), "plane=control") {
+			return false, nil, nil
+		}
+		atomic.AddInt32(&sweeps, 1)
+		select {
+		case listing <- struct{}{}:
+			<-release
+		default:
+		}
+		return false, nil, nil
+	})
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	type result struct {
+		state *K8sState
+		err   error
+	}
+	first := make(chan result)
+	go func() {
+		state, err := vzInfo.ForceUpdate(context.Background())
+		first <- result{state, err}
+	}()
+	<-listing
+
+	// Callers that give up before the sweep completes join it rather than starting their own.
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := vzInfo.ForceUpdate(canceled)
+	assert.ErrorIs(t, err, context.Canceled)
+	short, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = vzInfo.ForceUpdate(short)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Periodic updates are skipped while the forced update runs.
+	vzInfo.UpdateK8sState(context.Background())
+
+	close(release)
+	res := <-first
+	require.NoError(t, res.err)
+	assert.Contains(t, res.state.ControlPlanePodStatuses, "vizier-metadata-0")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&sweeps))
+}
+
+func TestForcedUpdateDeadline(t *testing.T) {
+	now := time.Unix(1646136000, 0)
+
+	// Without a deadline of its own, the caller gets the update period.
+	assert.Equal(t, now.Add(k8sStateUpdatePeriod), forcedUpdateDeadline(context.Background(), now))
+
+	// A tighter deadline bounds the update.
+	short, cancel := context.WithDeadline(context.Background(), now.Add(time.Second))
+	defer cancel()
+	assert.Equal(t, now.Add(time.Second), forcedUpdateDeadline(short, now))
+
+	// A looser one is capped at the update period.
+	long, cancel := context.WithDeadline(context.Background(), now.Add(time.Hour))
+	defer cancel()
+	assert.Equal(t, now.Add(k8sStateUpdatePeriod), forcedUpdateDeadline(long, now))
+}
@@ -0,0 +1,188 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// The registry that images without a registry host are pulled from.
+const defaultImageRegistry = "docker.io"
+
+// imagePullFailureReasons are the container waiting reasons which mean that the container's image can't be
+// pulled. These are most often caused by a registry that is unreachable from the cluster, or by missing pull
+// credentials.
+var imagePullFailureReasons = map[string]bool{
+	"ErrImagePull":      true,
+	"ImagePullBackOff":  true,
+	"InvalidImageName":  true,
+	"ErrImageNeverPull": true,
+}
+
+// ImagePullFailure describes a container in a Vizier pod whose image can't be pulled.
+type ImagePullFailure struct {
+	// The key of the pod in the pod statuses.
+	Pod       string
+	Container string
+	// The image reference. Ex: gcr.io/pixie-oss/pixie-prod/vizier/pem_image:0.12.0
+	Image string
+	// The host of the registry the image is pulled from. Ex: gcr.io
+	Registry string
+	// Ex: "ImagePullBackOff".
+	Reason  string
+	Message string
+}
+
+// Description returns a short description of the failure, for the pod's status message.
+func (f *ImagePullFailure) Description() string {
+	desc := fmt.Sprintf("failed to pull image %s from %s", f.Image, f.Registry)
+	if f.Message != "" {
+		desc = fmt.Sprintf("%s: %s", desc, f.Message)
+	}
+	return desc
+}
+
+// parseImageRegistry returns the host of the registry in the image reference. The first component of the
+// reference is only a registry host if it looks like one, otherwise the image is pulled from Docker Hub.
+func parseImageRegistry(image string) string {
+	i := strings.Index(image, "/")
+	if i < 0 {
+		return defaultImageRegistry
+	}
+	host := image[:i]
+	if strings.ContainsAny(host, ".:") || host == "localhost" {
+		return host
+	}
+	return defaultImageRegistry
+}
+
+// getPodImagePullFailures returns the containers in the pod, including init containers, whose images can't
+// be pulled.
+func getPodImagePullFailures(key string, pod *corev1.Pod) []*ImagePullFailure {
+	specImages := make(map[string]string)
+	for _, c := range pod.Spec.InitContainers {
+		specImages[c.Name] = c.Image
+	}
+	for _, c := range pod.Spec.Containers {
+		specImages[c.Name] = c.Image
+	}
+
+	var failures []*ImagePullFailure
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, c := range statuses {
+		if c.State.Waiting == nil || !imagePullFailureReasons[c.State.Waiting.Reason] {
+			continue
+		}
+		image := c.Image
+		if image == "" {
+			image = specImages[c.Name]
+		}
+		failures = append(failures, &ImagePullFailure{
+			Pod:       key,
+			Container: c.Name,
+			Image:     image,
+			Registry:  parseImageRegistry(image),
+			Reason:    c.State.Waiting.Reason,
+			Message:   c.State.Waiting.Message,
+		})
+	}
+	return failures
+}
+
+// getImagePullFailures returns the image pull failures of each of the pods, sorted by pod and container.
+func getImagePullFailures(pods map[string]*corev1.Pod) []*ImagePullFailure {
+	var failures []*ImagePullFailure
+	for name, p := range pods {
+		failures = append(failures, getPodImagePullFailures(name, p)...)
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		if failures[i].Pod != failures[j].Pod {
+			return failures[i].Pod < failures[j].Pod
+		}
+		return failures[i].Container < failures[j].Container
+	})
+	return failures
+}
+
+func copyImagePullFailures(failures []*ImagePullFailure) []*ImagePullFailure {
+	if failures == nil {
+		return nil
+	}
+	clone := make([]*ImagePullFailure, len(failures))
+	for i, f := range failures {
+		c := *f
+		clone[i] = &c
+	}
+	return clone
+}
+
+// Image pull failures are reported once per registry rather than once per pod, since a registry that is
+// unreachable or missing credentials fails every pull from it. The pods themselves are reported by the pod
+// rules, which determine the health.
+func checkImagePullFailures(s *K8sState) (VizierHealth, []string) {
+	type registryFailures struct {
+		pods    map[string]bool
+		images  map[string]bool
+		message string
+	}
+	byRegistry := make(map[string]*registryFailures)
+	for _, f := range s.ImagePullFailures {
+		r, ok := byRegistry[f.Registry]
+		if !ok {
+			r = &registryFailures{pods: make(map[string]bool), images: make(map[string]bool)}
+			byRegistry[f.Registry] = r
+		}
+		r.pods[f.Pod] = true
+		r.images[f.Image] = true
+		if r.message == "" {
+			r.message = f.Message
+		}
+	}
+
+	registries := make([]string, 0, len(byRegistry))
+	for registry := range byRegistry {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+
+	var reasons []string
+	for _, registry := range registries {
+		r := byRegistry[registry]
+		images := make([]string, 0, len(r.images))
+		for image := range r.images {
+			images = append(images, image)
+		}
+		sort.Strings(images)
+
+		pods := "1 pod"
+		if len(r.pods) != 1 {
+			pods = fmt.Sprintf("%d pods", len(r.pods))
+		}
+		reason := fmt.Sprintf("image pulls from %s failing for %s (%s)", registry, pods, strings.Join(images, ", "))
+		if r.message != "" {
+			reason = fmt.Sprintf("%s: %s", reason, r.message)
+		}
+		reasons = append(reasons, reason)
+	}
+	return VizierHealthHealthy, reasons
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// imagePullFailingPod returns a pending pod whose container can't pull the given image.
+func imagePullFailingPod(name string, labels map[string]string, image string) *corev1.Pod {
+	pod := makePod(name, labels)
+	pod.Status.Phase = corev1.PodPending
+	pod.Spec.Containers = []corev1.Container{{Name: "app", Image: image}}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{
+			Name:  "app",
+			Image: image,
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+				Reason:  "ImagePullBackOff",
+				Message: "Back-off pulling image \"" + image + "\"",
+			}},
+		},
+	}
+	return pod
+}
+
+func TestParseImageRegistry(t *testing.T) {
+	assert.Equal(t, "gcr.io", parseImageRegistry("gcr.io/pixie-oss/pixie-prod/vizier/pem_image:0.12.0"))
+	assert.Equal(t, "registry.internal:5000", parseImageRegistry("registry.internal:5000/vizier/pem_image:0.12.0"))
+	assert.Equal(t, "localhost", parseImageRegistry("localhost/pem_image"))
+	assert.Equal(t, "docker.io", parseImageRegistry("library/nats:2.8"))
+	assert.Equal(t, "docker.io", parseImageRegistry("nats:2.8"))
+}
+
+func TestGetPodImagePullFailures(t *testing.T) {
+	pod := imagePullFailingPod("vizier-metadata-0", nil, "gcr.io/pixie-oss/pixie-prod/vizier/metadata_server_image:0.12.0")
+	// The image is taken from the spec if the container status doesn't report it.
+	pod.Spec.InitContainers = []corev1.Container{{Name: "wait", Image: "quay.io/pixie/wait:1.0"}}
+	pod.Status.InitContainerStatuses = []corev1.ContainerStatus{
+		{
+			Name:  "wait",
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ErrImagePull", Message: "unauthorized"}},
+		},
+	}
+
+	failures := getPodImagePullFailures("vizier-metadata-0", pod)
+	require.Len(t, failures, 2)
+	assert.Equal(t, &ImagePullFailure{
+		Pod:       "vizier-metadata-0",
+		Container: "wait",
+		Image:     "quay.io/pixie/wait:1.0",
+		Registry:  "quay.io",
+		Reason:    "ErrImagePull",
+		Message:   "unauthorized",
+	}, failures[0])
+	assert.Equal(t, "app", failures[1].Container)
+	assert.Equal(t, "gcr.io", failures[1].Registry)
+	assert.Equal(t, "failed to pull image quay.io/pixie/wait:1.0 from quay.io: unauthorized", failures[0].Description())
+
+	assert.Empty(t, getPodImagePullFailures("vizier-metadata-1", makePod("vizier-metadata-1", nil)))
+}
+
+func TestCheckImagePullFailures(t *testing.T) {
+	state := &K8sState{
+		ImagePullFailures: []*ImagePullFailure{
+			{Pod: "kelvin-5f7b6d4c9-abcde", Container: "app", Image: "gcr.io/pixie/kelvin:0.12.0", Registry: "gcr.io", Message: "i/o timeout"},
+			{Pod: "vizier-metadata-0", Container: "app", Image: "gcr.io/pixie/metadata:0.12.0", Registry: "gcr.io", Message: "i/o timeout"},
+			{Pod: "vizier-pem-abcde", Container: "pem", Image: "gcr.io/pixie/pem:0.12.0", Registry: "gcr.io"},
+			{Pod: "vizier-pem-abcde", Container: "init", Image: "busybox:1.34", Registry: "docker.io"},
+		},
+	}
+
+	health, reasons := checkImagePullFailures(state)
+	assert.Equal(t, VizierHealthHealthy, health)
+	assert.Equal(t, []string{
+		"image pulls from docker.io failing for 1 pod (busybox:1.34)",
+		"image pulls from gcr.io failing for 3 pods (gcr.io/pixie/kelvin:0.12.0, gcr.io/pixie/metadata:0.12.0, gcr.io/pixie/pem:0.12.0): i/o timeout",
+	}, reasons)
+}
+
+func TestUpdateK8sState_ImagePullFailures(t *testing.T) {
+	selector, err := getPodSelector("app=pl-monitoring", false)
+	require.NoError(t, err)
+	vzInfo := &K8sVizierInfo{
+		ns: testNamespace,
+		clientset: fake.NewSimpleClientset(expectedSecretObjects(
+			imagePullFailingPod("vizier-metadata-0", map[string]string{"app": "pl-monitoring", "plane": "control"}, "gcr.io/pixie/metadata:0.12.0"),
+			imagePullFailingPod("vizier-query-broker-0", map[string]string{"app": "pl-monitoring", "plane": "control"}, "gcr.io/pixie/query_broker:0.12.0"),
+		)...),
+		podSelector: selector,
+	}
+
+	vzInfo.UpdateK8sState(context.Background())
+
+	state := vzInfo.GetK8sState()
+	require.Contains(t, state.ControlPlanePodStatuses, "vizier-metadata-0")
+	s := state.ControlPlanePodStatuses["vizier-metadata-0"]
+	assert.Equal(t, "ImagePullBackOff", s.Reason)
+	assert.Equal(t, "failed to pull image gcr.io/pixie/metadata:0.12.0 from gcr.io: Back-off pulling image \"gcr.io/pixie/metadata:0.12.0\"", s.StatusMessage)
+	assert.Len(t, state.ImagePullFailures, 2)
+
+	// Both pods are reported by a single reason for the registry.
+	assert.Equal(t, VizierHealthUnhealthy, state.VizierState.Health)
+	assert.Contains(t, state.VizierState.Reasons,
+		"image pulls from gcr.io failing for 2 pods (gcr.io/pixie/metadata:0.12.0, gcr.io/pixie/query_broker:0.12.0): Back-off pulling image \"gcr.io/pixie/metadata:0.12.0\"")
+	for _, reason := range state.VizierState.Reasons {
+		assert.NotContains(t, reason, "ImagePullBackOff")
+	}
+}
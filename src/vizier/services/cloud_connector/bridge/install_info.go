@@ -0,0 +1,255 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// The ConfigMap that the first successful connection to cloud is persisted in, so that it survives restarts
+	// of the cloud connector. It is deleted along with the Vizier namespace, so a reinstall starts over.
+	installInfoConfigMapName = "pl-cloud-connector-install-info"
+	firstConnectedAtKey      = "first-connected-at"
+)
+
+// InstallTimeSource describes where the install time came from.
+type InstallTimeSource string
+
+const (
+	// InstallTimeSourceNamespace is the creation time of the Vizier namespace.
+	InstallTimeSourceNamespace InstallTimeSource = "namespace"
+	// InstallTimeSourceDeployment is the creation time of the oldest Pixie deployment, used when the Vizier
+	// namespace can't be read.
+	InstallTimeSourceDeployment InstallTimeSource = "deployment"
+)
+
+// InstallInfo describes how long the Vizier has been installed and connected to cloud, which tells apart a
+// Vizier that broke after running for months from one that never worked.
+type InstallInfo struct {
+	// When the Vizier was installed, according to the API server. Zero if unknown.
+	InstalledAt       time.Time
+	InstalledAtSource InstallTimeSource
+	// When the cloud connector first registered with cloud, according to the cloud connector. Zero if it
+	// has not yet.
+	FirstConnectedAt time.Time
+}
+
+// sinceClamped returns how long ago t was, or zero if t is unknown. The timestamps come from the API server
+// and from whichever cloud connector pod persisted them, so clock skew may put them in the future.
+func sinceClamped(t time.Time, now time.Time) time.Duration {
+	if t.IsZero() || now.Before(t) {
+		return 0
+	}
+	return now.Sub(t)
+}
+
+// InstallAge returns how long the Vizier has been installed, or zero if the install time is unknown.
+func (i *InstallInfo) InstallAge(now time.Time) time.Duration {
+	return sinceClamped(i.InstalledAt, now)
+}
+
+// ConnectedAge returns how long ago the Vizier first connected to cloud, or zero if it has not yet.
+func (i *InstallInfo) ConnectedAge(now time.Time) time.Duration {
+	return sinceClamped(i.FirstConnectedAt, now)
+}
+
+// oldestPixieDeployment returns the creation time of the oldest deployment that runs a Pixie image, or the
+// zero time if there are none.
+func oldestPixieDeployment(deployments []appsv1.Deployment) time.Time {
+	var oldest time.Time
+	for i := range deployments {
+		created := deployments[i].CreationTimestamp.Time
+		if created.IsZero() || (!oldest.IsZero() && !created.Before(oldest)) {
+			continue
+		}
+		for _, c := range deployments[i].Spec.Template.Spec.Containers {
+			repository, _, _ := parseImageRef(c.Image)
+			if isPixieImage(repository) {
+				oldest = created
+				break
+			}
+		}
+	}
+	return oldest
+}
+
+// getInstalledAt returns when the Vizier was installed, from the creation time of the Vizier namespace, or
+// else of the oldest Pixie deployment. It returns the zero time if neither can be read.
+func (v *K8sVizierInfo) getInstalledAt(ctx context.Context) (time.Time, InstallTimeSource) {
+	ns, err := v.clientset.CoreV1().Namespaces().Get(ctx, v.ns, metav1.GetOptions{})
+	if err == nil && !ns.CreationTimestamp.IsZero() {
+		return ns.CreationTimestamp.Time, InstallTimeSourceNamespace
+	}
+	if err != nil {
+		log.WithError(err).Debug("Failed to get the Vizier namespace for the install time, falling back to the deployments")
+	}
+
+	deployments, err := v.clientset.AppsV1().Deployments(v.ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		recordK8sAPIError("deployments")
+		log.WithError(err).Warn("Failed to list deployments for the install time")
+		return time.Time{}, ""
+	}
+	if oldest := oldestPixieDeployment(deployments.Items); !oldest.IsZero() {
+		return oldest, InstallTimeSourceDeployment
+	}
+	return time.Time{}, ""
+}
+
+// parseFirstConnectedAt returns the first connection time persisted in the ConfigMap, or the zero time if
+// there is none.
+func parseFirstConnectedAt(cm *corev1.ConfigMap) time.Time {
+	if cm == nil {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, cm.Data[firstConnectedAtKey])
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// getFirstConnectedAt returns the first connection time persisted in the ConfigMap, or the zero time if there
+// is none, or it can't be read.
+func (v *K8sVizierInfo) getFirstConnectedAt(ctx context.Context) time.Time {
+	cm, err := v.clientset.CoreV1().ConfigMaps(v.ns).Get(ctx, installInfoConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			log.WithError(err).Warn("Failed to get the first connection time")
+		}
+		return time.Time{}
+	}
+	return parseFirstConnectedAt(cm)
+}
+
+// getInstallInfo detects when the Vizier was installed and first connected. Anything that can't be read is
+// left as the zero time, and never causes an error.
+func (v *K8sVizierInfo) getInstallInfo(ctx context.Context) *InstallInfo {
+	installedAt, source := v.getInstalledAt(ctx)
+	info := &InstallInfo{
+		InstalledAt:       installedAt,
+		InstalledAtSource: source,
+	}
+
+	v.mu.Lock()
+	if v.installInfo != nil {
+		info.FirstConnectedAt = v.installInfo.FirstConnectedAt
+	}
+	v.mu.Unlock()
+	if info.FirstConnectedAt.IsZero() {
+		info.FirstConnectedAt = v.getFirstConnectedAt(ctx)
+	}
+	return info
+}
+
+// persistFirstConnectedAt stores the first connection time in the ConfigMap, unless one is already stored
+// there. It returns the time that is stored.
+func (v *K8sVizierInfo) persistFirstConnectedAt(ctx context.Context, t time.Time) (time.Time, error) {
+	configMaps := v.clientset.CoreV1().ConfigMaps(v.ns)
+	cm, err := configMaps.Get(ctx, installInfoConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			return time.Time{}, err
+		}
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: installInfoConfigMapName, Namespace: v.ns},
+			Data:       map[string]string{firstConnectedAtKey: t.UTC().Format(time.RFC3339)},
+		}
+		if _, err := configMaps.Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+			return time.Time{}, err
+		}
+		return parseFirstConnectedAt(cm), nil
+	}
+	// Another cloud connector pod may have connected first.
+	if existing := parseFirstConnectedAt(cm); !existing.IsZero() {
+		return existing, nil
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[firstConnectedAtKey] = t.UTC().Format(time.RFC3339)
+	if _, err := configMaps.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		return time.Time{}, err
+	}
+	return parseFirstConnectedAt(cm), nil
+}
+
+// RecordConnected records that the cloud connector registered with cloud at the given time. Only the first
+// connection is kept. It is persisted, so that it survives restarts of the cloud connector.
+func (v *K8sVizierInfo) RecordConnected(t time.Time) {
+	v.mu.Lock()
+	recorded := v.installInfo != nil && !v.installInfo.FirstConnectedAt.IsZero()
+	v.mu.Unlock()
+	if recorded {
+		return
+	}
+
+	ctx, cancel := withDefaultTimeout(context.Background())
+	defer cancel()
+	firstConnectedAt, err := v.persistFirstConnectedAt(ctx, t)
+	if err != nil {
+		// The connection will be recorded again the next time the cloud connector registers.
+		log.WithError(err).Warn("Failed to persist the first connection time")
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.installInfo == nil {
+		v.installInfo = &InstallInfo{}
+	}
+	if v.installInfo.FirstConnectedAt.IsZero() {
+		v.installInfo.FirstConnectedAt = firstConnectedAt
+		log.WithField("firstConnectedAt", firstConnectedAt).Info("Recorded the first connection to cloud")
+	}
+}
+
+// InstallInfo returns when the Vizier was installed and first connected, as of the last time the cluster info
+// was fetched, or the connection was recorded. This is nil if neither has happened yet.
+func (v *K8sVizierInfo) InstallInfo() *InstallInfo {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return copyInstallInfo(v.installInfo)
+}
+
+func copyInstallInfo(info *InstallInfo) *InstallInfo {
+	if info == nil {
+		return nil
+	}
+	c := *info
+	return &c
+}
+
+// unixNanosOrZero returns t as unix nanoseconds, or 0 if t is unset, which is how the heartbeat marks unknown
+// times.
+func unixNanosOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
@@ -0,0 +1,185 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestInstallInfo_Ages(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	info := &InstallInfo{
+		InstalledAt:      now.Add(-180 * 24 * time.Hour),
+		FirstConnectedAt: now.Add(-time.Hour),
+	}
+	assert.Equal(t, 180*24*time.Hour, info.InstallAge(now))
+	assert.Equal(t, time.Hour, info.ConnectedAge(now))
+
+	// Clock skew between the API server and the cloud connector never makes an age negative.
+	info.InstalledAt = now.Add(time.Minute)
+	assert.Equal(t, time.Duration(0), info.InstallAge(now))
+	assert.Equal(t, time.Duration(0), (&InstallInfo{}).ConnectedAge(now))
+
+	// The heartbeat reports unknown times as 0, rather than the unix time of the zero time.
+	assert.Equal(t, now.UnixNano(), unixNanosOrZero(now))
+	assert.Equal(t, int64(0), unixNanosOrZero(time.Time{}))
+}
+
+func TestGetInstallInfo_Namespace(t *testing.T) {
+	installedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	firstConnectedAt := time.Date(2024, 1, 2, 3, 10, 0, 0, time.UTC)
+	clientset := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNamespace, CreationTimestamp: metav1.NewTime(installedAt)}},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: installInfoConfigMapName, Namespace: testNamespace},
+			Data:       map[string]string{firstConnectedAtKey: firstConnectedAt.Format(time.RFC3339)},
+		},
+	)
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	assert.Equal(t, &InstallInfo{
+		InstalledAt:       installedAt,
+		InstalledAtSource: InstallTimeSourceNamespace,
+		FirstConnectedAt:  firstConnectedAt,
+	}, vzInfo.getInstallInfo(context.Background()))
+}
+
+func TestGetInstallInfo_DeploymentFallback(t *testing.T) {
+	oldest := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sidecar := makeDeployment("sidecar", "busybox:1.36", nil, nil)
+	sidecar.CreationTimestamp = metav1.NewTime(oldest.Add(-time.Hour))
+	kelvin := makeDeployment("kelvin", "gcr.io/pixie-oss/pixie-prod/vizier/kelvin_image:0.14.1", nil, nil)
+	kelvin.CreationTimestamp = metav1.NewTime(oldest.Add(time.Minute))
+	metadata := makeDeployment("vizier-metadata", "gcr.io/pixie-oss/pixie-prod/vizier/metadata_server_image:0.14.1", nil, nil)
+	metadata.CreationTimestamp = metav1.NewTime(oldest)
+
+	clientset := fake.NewSimpleClientset(sidecar, kelvin, metadata)
+	clientset.PrependReactor("get", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewForbidden(schema.GroupResource{Resource: "namespaces"}, testNamespace, nil)
+	})
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	// Deployments that don't run a Pixie image, such as sidecars added by the user, don't count.
+	info := vzInfo.getInstallInfo(context.Background())
+	assert.Equal(t, oldest, info.InstalledAt)
+	assert.Equal(t, InstallTimeSourceDeployment, info.InstalledAtSource)
+	assert.True(t, info.FirstConnectedAt.IsZero())
+}
+
+func TestGetInstallInfo_Unknown(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	assert.Equal(t, &InstallInfo{}, vzInfo.getInstallInfo(context.Background()))
+}
+
+func TestRecordConnected(t *testing.T) {
+	first := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	clientset := fake.NewSimpleClientset()
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+	assert.Nil(t, vzInfo.InstallInfo())
+
+	vzInfo.RecordConnected(first)
+	vzInfo.RecordConnected(first.Add(time.Hour))
+	require.NotNil(t, vzInfo.InstallInfo())
+	assert.Equal(t, first, vzInfo.InstallInfo().FirstConnectedAt)
+	// The heartbeat reads it from the K8s state.
+	require.NotNil(t, vzInfo.GetK8sState().InstallInfo)
+	assert.Equal(t, first, vzInfo.GetK8sState().InstallInfo.FirstConnectedAt)
+
+	cm, err := clientset.CoreV1().ConfigMaps(testNamespace).Get(context.Background(), installInfoConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "2024-01-02T03:04:05Z", cm.Data[firstConnectedAtKey])
+
+	// A restarted cloud connector keeps the first connection time.
+	restarted := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+	restarted.RecordConnected(first.Add(24 * time.Hour))
+	assert.Equal(t, first, restarted.InstallInfo().FirstConnectedAt)
+	assert.Equal(t, first, restarted.getInstallInfo(context.Background()).FirstConnectedAt)
+}
+
+func TestRecordConnected_PersistFailure(t *testing.T) {
+	first := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, installInfoConfigMapName, nil)
+	})
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	// The connection isn't recorded until it can be persisted, so that it is retried on the next connection.
+	vzInfo.RecordConnected(first)
+	assert.Nil(t, vzInfo.InstallInfo())
+}
+
+func TestGetVizierClusterInfo_InstallInfo(t *testing.T) {
+	installedAt := time.Now().Add(-48 * time.Hour)
+	clientset := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system", UID: "084cb5f0-ff69-11e9-a63e-42010a8a0193"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNamespace, CreationTimestamp: metav1.NewTime(installedAt)}},
+	)
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	connectedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	vzInfo.RecordConnected(connectedAt)
+	_, err := vzInfo.GetVizierClusterInfo()
+	require.NoError(t, err)
+
+	info := vzInfo.InstallInfo()
+	require.NotNil(t, info)
+	assert.True(t, installedAt.Equal(info.InstalledAt))
+	assert.True(t, connectedAt.Equal(info.FirstConnectedAt))
+
+	status := vzInfo.GetCollectorStatus()
+	assert.Equal(t, InstallTimeSourceNamespace, status.InstalledAtSource)
+	assert.NotEmpty(t, status.InstallAge)
+	assert.NotEmpty(t, status.ConnectedAge)
+}
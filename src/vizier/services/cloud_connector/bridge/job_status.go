@@ -0,0 +1,117 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	jobKind     = "Job"
+	cronJobKind = "CronJob"
+)
+
+// The name of the job that provisions the Vizier certs. Vizier can't become healthy if this job fails.
+const certProvisionerJobName = "cert-provisioner-job"
+
+// JobStatus describes the status of a Job or CronJob in the Vizier namespace.
+type JobStatus struct {
+	Name string
+	// Either "Job" or "CronJob".
+	Kind string
+	// The number of active pods. For CronJobs, this is the number of active jobs.
+	Active int32
+	// The number of pods which succeeded. Always zero for CronJobs.
+	Succeeded int32
+	// The number of pods which failed. Always zero for CronJobs.
+	Failed int32
+	// The reason and message from the job's Failed condition, if the job failed.
+	FailureMessage string
+}
+
+func getJobFailureMessage(j *batchv1.Job) string {
+	for _, c := range j.Status.Conditions {
+		if c.Type != batchv1.JobFailed || c.Status != corev1.ConditionTrue {
+			continue
+		}
+		if c.Message == "" {
+			return c.Reason
+		}
+		return fmt.Sprintf("%s: %s", c.Reason, c.Message)
+	}
+	return ""
+}
+
+// getJobStatuses gets the statuses of the Jobs and CronJobs in the Vizier namespace. This collector is
+// optional: if either list fails, for example due to missing RBAC, those statuses are left out.
+func (v *K8sVizierInfo) getJobStatuses(ctx context.Context) []*JobStatus {
+	if !v.collectorAvailable(collectorJobs) {
+		return nil
+	}
+	var statuses []*JobStatus
+
+	jobs, err := v.clientset.BatchV1().Jobs(v.ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		recordK8sAPIError("jobs")
+		log.WithError(err).Warn("Failed to list jobs, leaving them out of the K8s state")
+	} else {
+		for i := range jobs.Items {
+			j := &jobs.Items[i]
+			statuses = append(statuses, &JobStatus{
+				Name:           j.Name,
+				Kind:           jobKind,
+				Active:         j.Status.Active,
+				Succeeded:      j.Status.Succeeded,
+				Failed:         j.Status.Failed,
+				FailureMessage: getJobFailureMessage(j),
+			})
+		}
+	}
+
+	cronJobs, err := v.clientset.BatchV1().CronJobs(v.ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		recordK8sAPIError("cronjobs")
+		log.WithError(err).Warn("Failed to list cronjobs, leaving them out of the K8s state")
+	} else {
+		for _, cj := range cronJobs.Items {
+			statuses = append(statuses, &JobStatus{
+				Name:   cj.Name,
+				Kind:   cronJobKind,
+				Active: int32(len(cj.Status.Active)),
+			})
+		}
+	}
+
+	return statuses
+}
+
+func copyJobStatuses(jobStatuses []*JobStatus) []*JobStatus {
+	if jobStatuses == nil {
+		return nil
+	}
+	clone := make([]*JobStatus, len(jobStatuses))
+	copy(clone, jobStatuses)
+	return clone
+}
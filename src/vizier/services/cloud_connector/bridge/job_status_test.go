@@ -0,0 +1,106 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func makeJobStatusClientset() *fake.Clientset {
+	return fake.NewSimpleClientset(
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "cert-provisioner-job", Namespace: testNamespace},
+			Status: batchv1.JobStatus{
+				Failed: 6,
+				Conditions: []batchv1.JobCondition{
+					{
+						Type:    batchv1.JobFailed,
+						Status:  corev1.ConditionTrue,
+						Reason:  "BackoffLimitExceeded",
+						Message: "Job has reached the specified backoff limit",
+					},
+				},
+			},
+		},
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "vizier-upgrade-job", Namespace: testNamespace},
+			Status:     batchv1.JobStatus{Active: 1},
+		},
+		&batchv1.CronJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "cleanup-cronjob", Namespace: testNamespace},
+			Status: batchv1.CronJobStatus{
+				Active: []corev1.ObjectReference{{Name: "cleanup-cronjob-1234"}},
+			},
+		},
+	)
+}
+
+func TestGetJobStatuses(t *testing.T) {
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: makeJobStatusClientset(),
+	}
+
+	assert.ElementsMatch(t, []*JobStatus{
+		{
+			Name:           "cert-provisioner-job",
+			Kind:           "Job",
+			Failed:         6,
+			FailureMessage: "BackoffLimitExceeded: Job has reached the specified backoff limit",
+		},
+		{
+			Name:   "vizier-upgrade-job",
+			Kind:   "Job",
+			Active: 1,
+		},
+		{
+			Name:   "cleanup-cronjob",
+			Kind:   "CronJob",
+			Active: 1,
+		},
+	}, vzInfo.getJobStatuses(context.Background()))
+}
+
+func TestGetJobStatuses_Forbidden(t *testing.T) {
+	clientset := makeJobStatusClientset()
+	clientset.PrependReactor("list", "cronjobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewForbidden(schema.GroupResource{Group: "batch", Resource: "cronjobs"}, "", nil)
+	})
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	statuses := vzInfo.getJobStatuses(context.Background())
+	assert.Len(t, statuses, 2)
+	for _, s := range statuses {
+		assert.Equal(t, "Job", s.Kind)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The API group served by metrics-server.
+const metricsAPIGroup = "metrics.k8s.io"
+
+// metrics-server is rarely installed or removed, so its availability is only checked occasionally.
+const metricsServerRefreshPeriod = 5 * time.Minute
+
+// MetricsServerStatus describes whether the metrics API, usually served by metrics-server, is available.
+type MetricsServerStatus struct {
+	Available bool
+	// The preferred version of the metrics API. Ex: "v1beta1". Empty if the API is not registered.
+	Version string
+	// The last time the metrics API was checked.
+	LastUpdated time.Time
+}
+
+// probeMetricsServer checks whether the metrics API is registered and available. An aggregated API that is
+// registered but whose backing service is down fails discovery of its resources.
+func (v *K8sVizierInfo) probeMetricsServer(now time.Time) (*MetricsServerStatus, error) {
+	status := &MetricsServerStatus{LastUpdated: now}
+
+	groups, err := v.clientset.Discovery().ServerGroups()
+	if err != nil {
+		return nil, err
+	}
+	var group *metav1.APIGroup
+	for i := range groups.Groups {
+		if groups.Groups[i].Name == metricsAPIGroup {
+			group = &groups.Groups[i]
+			break
+		}
+	}
+	if group == nil {
+		return status, nil
+	}
+
+	status.Version = group.PreferredVersion.Version
+	if _, err := v.clientset.Discovery().ServerResourcesForGroupVersion(group.PreferredVersion.GroupVersion); err != nil {
+		log.WithError(err).WithField("groupVersion", group.PreferredVersion.GroupVersion).Debug("Metrics API is registered but not available")
+		return status, nil
+	}
+	status.Available = true
+	return status, nil
+}
+
+// refreshMetricsServerStatus checks whether the metrics API is available, if it has not been checked within
+// the refresh period. If the check itself fails, the last known status is kept.
+func (v *K8sVizierInfo) refreshMetricsServerStatus(now time.Time) {
+	v.mu.Lock()
+	stale := v.metricsServerStatus == nil || now.Sub(v.metricsServerStatus.LastUpdated) >= metricsServerRefreshPeriod
+	v.mu.Unlock()
+	if !stale {
+		return
+	}
+
+	status, err := v.probeMetricsServer(now)
+	if err != nil {
+		recordK8sAPIError("discovery")
+		log.WithError(err).Warn("Failed to check whether the metrics API is available")
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.metricsServerStatus = status
+}
+
+// MetricsAPIAvailable returns whether the metrics API was available the last time it was checked. Collectors
+// that depend on the metrics API should check this, rather than discovering its absence through failed requests.
+func (v *K8sVizierInfo) MetricsAPIAvailable() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.metricsServerStatus != nil && v.metricsServerStatus.Available
+}
+
+// getMetricsServerStatus returns a copy of the current metrics server status. The caller must hold the lock.
+func (v *K8sVizierInfo) getMetricsServerStatus() *MetricsServerStatus {
+	if v.metricsServerStatus == nil {
+		return nil
+	}
+	status := *v.metricsServerStatus
+	return &status
+}
+
+// Features that depend on the metrics API show partial results without it. This doesn't affect the health
+// of the Vizier itself, so the reason is informational.
+func checkMetricsServer(s *K8sState) (VizierHealth, []string) {
+	if s.MetricsServer == nil || s.MetricsServer.Available {
+		return VizierHealthHealthy, nil
+	}
+	if s.MetricsServer.Version == "" {
+		return VizierHealthHealthy, []string{"metrics API is not installed"}
+	}
+	return VizierHealthHealthy, []string{"metrics API " + s.MetricsServer.Version + " is not available"}
+}
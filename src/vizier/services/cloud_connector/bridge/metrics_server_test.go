@@ -0,0 +1,99 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRefreshMetricsServerStatus(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	fakeDiscovery := clientset.Discovery().(*fakediscovery.FakeDiscovery)
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	now := time.Now()
+	vzInfo.refreshMetricsServerStatus(now)
+	assert.False(t, vzInfo.MetricsAPIAvailable())
+	assert.Equal(t, &MetricsServerStatus{LastUpdated: now}, vzInfo.getMetricsServerStatus())
+
+	fakeDiscovery.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "metrics.k8s.io/v1beta1",
+			APIResources: []metav1.APIResource{{Name: "pods"}, {Name: "nodes"}},
+		},
+	}
+
+	// The status is only checked once per refresh period.
+	vzInfo.refreshMetricsServerStatus(now.Add(time.Minute))
+	assert.False(t, vzInfo.MetricsAPIAvailable())
+
+	later := now.Add(metricsServerRefreshPeriod)
+	vzInfo.refreshMetricsServerStatus(later)
+	assert.True(t, vzInfo.MetricsAPIAvailable())
+	assert.Equal(t, &MetricsServerStatus{Available: true, Version: "v1beta1", LastUpdated: later}, vzInfo.getMetricsServerStatus())
+}
+
+func TestCheckMetricsServer(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        *MetricsServerStatus
+		expectedNotes []string
+	}{
+		{
+			name: "not checked yet",
+		},
+		{
+			name:   "available",
+			status: &MetricsServerStatus{Available: true, Version: "v1beta1"},
+		},
+		{
+			name:          "not installed",
+			status:        &MetricsServerStatus{},
+			expectedNotes: []string{"metrics API is not installed"},
+		},
+		{
+			name:          "registered but unavailable",
+			status:        &MetricsServerStatus{Version: "v1beta1"},
+			expectedNotes: []string{"metrics API v1beta1 is not available"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state := computeVizierState(&K8sState{LastUpdated: time.Now(), MetricsServer: test.status})
+			// The metrics API is informational, so it never affects the health.
+			require.Equal(t, VizierHealthHealthy, state.Health)
+			if test.expectedNotes == nil {
+				assert.Empty(t, state.Reasons)
+			} else {
+				assert.Equal(t, test.expectedNotes, state.Reasons)
+			}
+		})
+	}
+}
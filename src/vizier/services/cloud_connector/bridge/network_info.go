@@ -0,0 +1,203 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// networkInfoUnknown is the value of any networking parameter that could not be detected.
+const networkInfoUnknown = "unknown"
+
+const (
+	// The service that fronts the API server. Its cluster IP is the first address in the service CIDR.
+	kubernetesServiceNamespace = "default"
+	kubernetesServiceName      = "kubernetes"
+	// The flag that sets the service CIDR on the API server.
+	serviceClusterIPRangeFlag = "--service-cluster-ip-range="
+)
+
+// A cniDaemonSet is a well-known DaemonSet that is deployed by a CNI plugin.
+type cniDaemonSet struct {
+	namespace string
+	name      string
+	cni       string
+}
+
+// knownCNIDaemonSets are checked in order to identify the CNI plugin. Each name must also be listed in the
+// cloud connector's cluster role.
+var knownCNIDaemonSets = []cniDaemonSet{
+	{namespace: "kube-system", name: "aws-node", cni: "aws-vpc-cni"},
+	{namespace: "kube-system", name: "anetd", cni: "gke-dataplane-v2"},
+	{namespace: "kube-system", name: "antrea-agent", cni: "antrea"},
+	{namespace: "kube-system", name: "calico-node", cni: "calico"},
+	{namespace: "calico-system", name: "calico-node", cni: "calico"},
+	{namespace: "kube-system", name: "canal", cni: "canal"},
+	{namespace: "kube-system", name: "cilium", cni: "cilium"},
+	{namespace: "kube-system", name: "kube-flannel-ds", cni: "flannel"},
+	{namespace: "kube-flannel", name: "kube-flannel-ds", cni: "flannel"},
+	{namespace: "kube-system", name: "kube-router", cni: "kube-router"},
+	{namespace: "openshift-ovn-kubernetes", name: "ovnkube-node", cni: "ovn-kubernetes"},
+	{namespace: "kube-system", name: "weave-net", cni: "weave"},
+}
+
+// NetworkInfo describes the cluster networking parameters that commonly cause tracing and passthrough issues.
+// Every field is networkInfoUnknown if it could not be detected.
+type NetworkInfo struct {
+	// The pod CIDRs assigned to the nodes, sorted.
+	PodCIDRs []string
+	// The service CIDR, as passed to the API server.
+	ServiceCIDR string
+	// The cluster IP of the kubernetes service, which is the first address in the service CIDR. This is known
+	// even when the service CIDR itself isn't.
+	KubernetesServiceIP string
+	// The CNI plugins identified from their DaemonSets, comma-separated.
+	CNI string
+}
+
+// getPodCIDRs returns the pod CIDRs assigned to the nodes.
+func (v *K8sVizierInfo) getPodCIDRs(ctx context.Context) []string {
+	nodes, err := v.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		recordK8sAPIError("nodes")
+		log.WithError(err).Warn("Failed to list nodes to detect the pod CIDRs")
+		return []string{networkInfoUnknown}
+	}
+	seen := make(map[string]bool)
+	for _, n := range nodes.Items {
+		cidrs := n.Spec.PodCIDRs
+		if len(cidrs) == 0 && n.Spec.PodCIDR != "" {
+			cidrs = []string{n.Spec.PodCIDR}
+		}
+		for _, cidr := range cidrs {
+			seen[cidr] = true
+		}
+	}
+	if len(seen) == 0 {
+		return []string{networkInfoUnknown}
+	}
+	podCIDRs := make([]string, 0, len(seen))
+	for cidr := range seen {
+		podCIDRs = append(podCIDRs, cidr)
+	}
+	sort.Strings(podCIDRs)
+	return podCIDRs
+}
+
+// parseServiceClusterIPRange returns the service CIDR from the API server's command line, or an empty string if
+// it isn't set there.
+func parseServiceClusterIPRange(args []string) string {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, serviceClusterIPRangeFlag) {
+			continue
+		}
+		cidr := strings.TrimPrefix(arg, serviceClusterIPRangeFlag)
+		// Dual-stack clusters have one CIDR per IP family.
+		for _, c := range strings.Split(cidr, ",") {
+			if _, _, err := net.ParseCIDR(c); err != nil {
+				return ""
+			}
+		}
+		return cidr
+	}
+	return ""
+}
+
+// getServiceCIDR returns the service CIDR from the flags of the API server, which is only visible on clusters
+// where the API server runs as a pod, such as kubeadm clusters.
+func (v *K8sVizierInfo) getServiceCIDR(ctx context.Context) string {
+	pods, err := v.clientset.CoreV1().Pods("kube-system").List(ctx, metav1.ListOptions{LabelSelector: "component=kube-apiserver"})
+	if err != nil {
+		log.WithError(err).Debug("Failed to list API server pods to detect the service CIDR")
+		return networkInfoUnknown
+	}
+	for _, p := range pods.Items {
+		for _, c := range p.Spec.Containers {
+			args := append(append([]string{}, c.Command...), c.Args...)
+			if cidr := parseServiceClusterIPRange(args); cidr != "" {
+				return cidr
+			}
+		}
+	}
+	return networkInfoUnknown
+}
+
+// getKubernetesServiceIP returns the cluster IP of the kubernetes service.
+func (v *K8sVizierInfo) getKubernetesServiceIP(ctx context.Context) string {
+	svc, err := v.clientset.CoreV1().Services(kubernetesServiceNamespace).Get(ctx, kubernetesServiceName, metav1.GetOptions{})
+	if err != nil {
+		log.WithError(err).Debug("Failed to get the kubernetes service to detect the service CIDR")
+		return networkInfoUnknown
+	}
+	if svc.Spec.ClusterIP == "" || svc.Spec.ClusterIP == "None" {
+		return networkInfoUnknown
+	}
+	return svc.Spec.ClusterIP
+}
+
+// getCNI identifies the CNI plugins from their well-known DaemonSets.
+func (v *K8sVizierInfo) getCNI(ctx context.Context) string {
+	var cnis []string
+	seen := make(map[string]bool)
+	for _, ds := range knownCNIDaemonSets {
+		if seen[ds.cni] {
+			continue
+		}
+		if _, err := v.clientset.AppsV1().DaemonSets(ds.namespace).Get(ctx, ds.name, metav1.GetOptions{}); err != nil {
+			continue
+		}
+		seen[ds.cni] = true
+		cnis = append(cnis, ds.cni)
+	}
+	if len(cnis) == 0 {
+		return networkInfoUnknown
+	}
+	return strings.Join(cnis, ",")
+}
+
+// getNetworkInfo detects the cluster networking parameters. This is best-effort: anything that can't be read is
+// networkInfoUnknown, and never causes an error.
+func (v *K8sVizierInfo) getNetworkInfo(ctx context.Context) *NetworkInfo {
+	return &NetworkInfo{
+		PodCIDRs:            v.getPodCIDRs(ctx),
+		ServiceCIDR:         v.getServiceCIDR(ctx),
+		KubernetesServiceIP: v.getKubernetesServiceIP(ctx),
+		CNI:                 v.getCNI(ctx),
+	}
+}
+
+// NetworkInfo returns the cluster networking parameters, as of the last time the cluster info was fetched. This
+// is nil if the cluster info has not been fetched yet.
+func (v *K8sVizierInfo) NetworkInfo() *NetworkInfo {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.networkInfo == nil {
+		return nil
+	}
+	info := *v.networkInfo
+	info.PodCIDRs = append([]string(nil), v.networkInfo.PodCIDRs...)
+	return &info
+}
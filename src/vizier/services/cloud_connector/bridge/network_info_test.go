@@ -0,0 +1,131 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestParseServiceClusterIPRange(t *testing.T) {
+	assert.Equal(t, "10.96.0.0/12", parseServiceClusterIPRange([]string{"kube-apiserver", "--advertise-address=10.0.0.2", "--service-cluster-ip-range=10.96.0.0/12"}))
+	assert.Equal(t, "10.96.0.0/12,fd00:10:96::/112", parseServiceClusterIPRange([]string{"--service-cluster-ip-range=10.96.0.0/12,fd00:10:96::/112"}))
+	assert.Equal(t, "", parseServiceClusterIPRange([]string{"--service-cluster-ip-range=not-a-cidr"}))
+	assert.Equal(t, "", parseServiceClusterIPRange([]string{"kube-apiserver"}))
+}
+
+func TestGetNetworkInfo(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+			Spec:       corev1.NodeSpec{PodCIDR: "10.244.1.0/24", PodCIDRs: []string{"10.244.1.0/24", "fd00:10:244:1::/64"}},
+		},
+		// Older nodes only set the single pod CIDR.
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-0"},
+			Spec:       corev1.NodeSpec{PodCIDR: "10.244.0.0/24"},
+		},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "kube-apiserver-node-0", Namespace: "kube-system", Labels: map[string]string{"component": "kube-apiserver"}},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name:    "kube-apiserver",
+				Command: []string{"kube-apiserver", "--service-cluster-ip-range=10.96.0.0/12"},
+			}}},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "kubernetes", Namespace: "default"},
+			Spec:       corev1.ServiceSpec{ClusterIP: "10.96.0.1"},
+		},
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "calico-node", Namespace: "calico-system"}},
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "kube-flannel-ds", Namespace: "kube-system"}},
+		// DaemonSets that share a well-known name, but are in some other namespace, don't count.
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "cilium", Namespace: "default"}},
+	)
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	assert.Equal(t, &NetworkInfo{
+		PodCIDRs:            []string{"10.244.0.0/24", "10.244.1.0/24", "fd00:10:244:1::/64"},
+		ServiceCIDR:         "10.96.0.0/12",
+		KubernetesServiceIP: "10.96.0.1",
+		CNI:                 "calico,flannel",
+	}, vzInfo.getNetworkInfo(context.Background()))
+}
+
+func TestGetNetworkInfo_Unknown(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-0"}})
+	// The cloud connector may not be allowed to read anything outside of the Vizier namespace.
+	for _, resource := range []string{"pods", "services", "daemonsets"} {
+		resource := resource
+		clientset.PrependReactor("*", resource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, k8serrors.NewForbidden(schema.GroupResource{Resource: resource}, "", nil)
+		})
+	}
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	info := vzInfo.getNetworkInfo(context.Background())
+	assert.Equal(t, &NetworkInfo{
+		PodCIDRs:            []string{networkInfoUnknown},
+		ServiceCIDR:         networkInfoUnknown,
+		KubernetesServiceIP: networkInfoUnknown,
+		CNI:                 networkInfoUnknown,
+	}, info)
+}
+
+func TestGetVizierClusterInfo_NetworkInfo(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system", UID: "084cb5f0-ff69-11e9-a63e-42010a8a0193"}},
+	)
+	clientset.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewForbidden(schema.GroupResource{Resource: "nodes"}, "", nil)
+	})
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+	assert.Nil(t, vzInfo.NetworkInfo())
+
+	// Networking introspection failing never fails the cluster info.
+	clusterInfo, err := vzInfo.GetVizierClusterInfo()
+	require.NoError(t, err)
+	assert.Equal(t, "084cb5f0-ff69-11e9-a63e-42010a8a0193", clusterInfo.ClusterUID)
+
+	info := vzInfo.NetworkInfo()
+	require.NotNil(t, info)
+	assert.Equal(t, []string{networkInfoUnknown}, info.PodCIDRs)
+	assert.Equal(t, networkInfoUnknown, info.CNI)
+	assert.Equal(t, info.PodCIDRs, vzInfo.GetCollectorStatus().PodCIDRs)
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// The oldest kernel version that the PEM supports.
+var kernelMinVersion = semver.Version{Major: 4, Minor: 14, Patch: 0}
+
+// NodeInfo describes the software running on a node, which determines whether the PEM can run on it.
+type NodeInfo struct {
+	Name          string
+	KernelVersion string
+	OSImage       string
+	// Ex: "containerd://1.4.13".
+	ContainerRuntimeVersion string
+	Architecture            string
+}
+
+func getNodeInfo(n *corev1.Node) NodeInfo {
+	return NodeInfo{
+		Name:                    n.Name,
+		KernelVersion:           n.Status.NodeInfo.KernelVersion,
+		OSImage:                 n.Status.NodeInfo.OSImage,
+		ContainerRuntimeVersion: n.Status.NodeInfo.ContainerRuntimeVersion,
+		Architecture:            n.Status.NodeInfo.Architecture,
+	}
+}
+
+// parseKernelVersion parses the release of a kernel version. Ex: "5.4.0-1069-gke" is 5.4.0.
+func parseKernelVersion(version string) (semver.Version, error) {
+	// We don't care about the distribution suffix, and it often fails to parse as a pre-release tag.
+	version = strings.SplitN(version, "-", 2)[0]
+	version = strings.TrimPrefix(version, "v")
+	// The minor version sometimes has a "+" suffix.
+	version = strings.TrimSuffix(version, "+")
+	return semver.Make(version)
+}
+
+// isKernelSupported returns whether the PEM supports the kernel version. Versions that can't be parsed are
+// assumed to be supported, rather than flagging clusters that we know nothing about.
+func isKernelSupported(version string) bool {
+	v, err := parseKernelVersion(version)
+	if err != nil {
+		return true
+	}
+	return v.GE(kernelMinVersion)
+}
+
+// countKernelVersions returns the number of nodes running each kernel version.
+func countKernelVersions(nodes []NodeInfo) map[string]int32 {
+	counts := make(map[string]int32)
+	for _, n := range nodes {
+		counts[n.KernelVersion]++
+	}
+	return counts
+}
+
+// Nodes with an unsupported kernel can't run the PEM, so there is no data from them.
+func checkKernelVersions(s *K8sState) (VizierHealth, []string) {
+	if s.ClusterStats == nil {
+		return VizierHealthHealthy, nil
+	}
+	var unsupported []string
+	numUnsupported := int32(0)
+	for version, count := range s.ClusterStats.KernelVersions {
+		if isKernelSupported(version) {
+			continue
+		}
+		unsupported = append(unsupported, fmt.Sprintf("%s (%d)", version, count))
+		numUnsupported += count
+	}
+	if len(unsupported) == 0 {
+		return VizierHealthHealthy, nil
+	}
+	sort.Strings(unsupported)
+	return VizierHealthDegraded, []string{fmt.Sprintf("%d/%d nodes run a kernel older than %s: %s",
+		numUnsupported, len(s.ClusterStats.Nodes), kernelMinVersion, strings.Join(unsupported, ", "))}
+}
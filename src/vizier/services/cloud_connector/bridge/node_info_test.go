@@ -0,0 +1,90 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestIsKernelSupported(t *testing.T) {
+	tests := []struct {
+		version   string
+		supported bool
+	}{
+		{version: "5.4.0-1069-gke", supported: true},
+		{version: "4.14.0", supported: true},
+		{version: "5.10.102+", supported: true},
+		{version: "4.9.0-16-amd64", supported: false},
+		{version: "3.10.0-1160.el7.x86_64", supported: false},
+		// Versions that can't be parsed are assumed to be supported.
+		{version: "", supported: true},
+		{version: "unknown", supported: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.version, func(t *testing.T) {
+			assert.Equal(t, test.supported, isKernelSupported(test.version))
+		})
+	}
+}
+
+func makeNodeWithInfo(name, kernelVersion string) *corev1.Node {
+	n := makeNode(name, "1", "1Gi")
+	n.Status.NodeInfo = corev1.NodeSystemInfo{
+		KernelVersion:           kernelVersion,
+		OSImage:                 "Ubuntu 20.04.4 LTS",
+		ContainerRuntimeVersion: "containerd://1.5.9",
+		Architecture:            "amd64",
+	}
+	return n
+}
+
+func TestCollectClusterStats_NodeInfo(t *testing.T) {
+	vzInfo := &K8sVizierInfo{
+		ns: testNamespace,
+		clientset: fake.NewSimpleClientset(
+			makeNodeWithInfo("node-1", "5.4.0-1069-gke"),
+			makeNodeWithInfo("node-2", "5.4.0-1069-gke"),
+			makeNodeWithInfo("node-3", "4.9.0-16-amd64"),
+		),
+	}
+
+	now := time.Now()
+	stats := vzInfo.collectClusterStats(context.Background(), now)
+	require.Len(t, stats.Nodes, 3)
+	assert.Equal(t, NodeInfo{
+		Name:                    "node-1",
+		KernelVersion:           "5.4.0-1069-gke",
+		OSImage:                 "Ubuntu 20.04.4 LTS",
+		ContainerRuntimeVersion: "containerd://1.5.9",
+		Architecture:            "amd64",
+	}, stats.Nodes[0])
+	assert.Equal(t, map[string]int32{"5.4.0-1069-gke": 2, "4.9.0-16-amd64": 1}, stats.KernelVersions)
+
+	state := computeVizierState(&K8sState{LastUpdated: now, ClusterStats: stats})
+	assert.Equal(t, VizierHealthDegraded, state.Health)
+	assert.Equal(t, []string{"1/3 nodes run a kernel older than 4.14.0: 4.9.0-16-amd64 (1)"}, state.Reasons)
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const pemDaemonSetName = "vizier-pem"
+
+// The number of uninstrumented nodes named in the PEM coverage reason, to keep it short on large clusters.
+const maxReportedUninstrumentedNodes = 5
+
+// The node conditions that cause the kubelet to evict pods.
+var nodePressureConditions = []corev1.NodeConditionType{
+	corev1.NodeMemoryPressure,
+	corev1.NodeDiskPressure,
+	corev1.NodePIDPressure,
+}
+
+// The tolerations that the DaemonSet controller adds to all DaemonSet pods, in addition to those in the spec.
+var daemonSetDefaultTolerations = []corev1.Toleration{
+	{Key: corev1.TaintNodeNotReady, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+	{Key: corev1.TaintNodeUnreachable, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+	{Key: corev1.TaintNodeDiskPressure, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	{Key: corev1.TaintNodeMemoryPressure, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	{Key: corev1.TaintNodePIDPressure, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	{Key: corev1.TaintNodeUnschedulable, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+}
+
+// NodeStatus describes the state of a node that affects whether it can run a PEM.
+type NodeStatus struct {
+	Name string
+	// Whether a running PEM is scheduled on the node.
+	Instrumented bool
+	// The pressure conditions that are true on the node. Ex: "DiskPressure".
+	Pressures []string
+	// The taints on the node that would evict or block the PEM. Ex: "dedicated=gpu:NoExecute".
+	BlockingTaints []string
+}
+
+// Problem returns a description of why the node may not be able to run a PEM, or an empty string if there is
+// no known reason.
+func (n *NodeStatus) Problem() string {
+	var problems []string
+	problems = append(problems, n.Pressures...)
+	for _, t := range n.BlockingTaints {
+		problems = append(problems, fmt.Sprintf("untolerated taint %s", t))
+	}
+	return strings.Join(problems, ", ")
+}
+
+// getPEMTolerations returns the tolerations of the PEM DaemonSet's pods. The second return value is false if
+// the DaemonSet could not be read, in which case taints can't be checked against it.
+func (v *K8sVizierInfo) getPEMTolerations(ctx context.Context) ([]corev1.Toleration, bool) {
+	if !v.collectorAvailable(collectorDaemonSets) {
+		return nil, false
+	}
+	ds, err := v.clientset.AppsV1().DaemonSets(v.ns).Get(ctx, pemDaemonSetName, metav1.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		return nil, false
+	}
+	if err != nil {
+		recordK8sAPIError("daemonsets")
+		log.WithError(err).Warn("Failed to get the PEM DaemonSet, not checking node taints")
+		return nil, false
+	}
+	tolerations := append([]corev1.Toleration{}, ds.Spec.Template.Spec.Tolerations...)
+	return append(tolerations, daemonSetDefaultTolerations...), true
+}
+
+// getBlockingTaints returns the taints on the node that none of the tolerations tolerate, and that would
+// keep a pod off of the node.
+func getBlockingTaints(node *corev1.Node, tolerations []corev1.Toleration) []string {
+	var blocking []string
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for j := range tolerations {
+			if tolerations[j].ToleratesTaint(taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			blocking = append(blocking, taint.ToString())
+		}
+	}
+	return blocking
+}
+
+// getNodePressures returns the pressure conditions that are true on the node.
+func getNodePressures(node *corev1.Node) []string {
+	var pressures []string
+	for _, t := range nodePressureConditions {
+		for _, c := range node.Status.Conditions {
+			if c.Type == t && c.Status == corev1.ConditionTrue {
+				pressures = append(pressures, string(t))
+			}
+		}
+	}
+	return pressures
+}
+
+// getNodeStatuses combines the nodes with the PEM pods scheduled on them, and with the tolerations of the PEM
+// DaemonSet. Taints are only checked if checkTaints is set. The statuses are sorted by node name.
+func getNodeStatuses(nodes map[string]*corev1.Node, pods map[string]*corev1.Pod, tolerations []corev1.Toleration, checkTaints bool) []*NodeStatus {
+	instrumented := make(map[string]bool)
+	for _, p := range pods {
+		if p.Labels["name"] == pemDaemonSetName && p.Spec.NodeName != "" && isPodRunning(p) {
+			instrumented[p.Spec.NodeName] = true
+		}
+	}
+
+	statuses := make([]*NodeStatus, 0, len(nodes))
+	for name, n := range nodes {
+		s := &NodeStatus{
+			Name:         name,
+			Instrumented: instrumented[name],
+			Pressures:    getNodePressures(n),
+		}
+		if checkTaints {
+			s.BlockingTaints = getBlockingTaints(n, tolerations)
+		}
+		statuses = append(statuses, s)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+func copyNodeStatuses(statuses []*NodeStatus) []*NodeStatus {
+	if statuses == nil {
+		return nil
+	}
+	clone := make([]*NodeStatus, len(statuses))
+	for i, s := range statuses {
+		c := *s
+		c.Pressures = append([]string(nil), s.Pressures...)
+		c.BlockingTaints = append([]string(nil), s.BlockingTaints...)
+		clone[i] = &c
+	}
+	return clone
+}
+
+// describeUninstrumentedNodes returns the nodes without a PEM that have a known reason for it, along with the
+// reason. Ex: "node-3 DiskPressure, node-7 untolerated taint dedicated=gpu:NoExecute".
+func describeUninstrumentedNodes(statuses []*NodeStatus) string {
+	var descriptions []string
+	more := 0
+	for _, s := range statuses {
+		problem := s.Problem()
+		if s.Instrumented || problem == "" {
+			continue
+		}
+		if len(descriptions) == maxReportedUninstrumentedNodes {
+			more++
+			continue
+		}
+		descriptions = append(descriptions, fmt.Sprintf("%s %s", s.Name, problem))
+	}
+	if more > 0 {
+		descriptions = append(descriptions, fmt.Sprintf("%d more", more))
+	}
+	return strings.Join(descriptions, ", ")
+}
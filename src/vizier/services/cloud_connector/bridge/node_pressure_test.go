@@ -0,0 +1,125 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func pemDaemonSet(tolerations ...corev1.Toleration) *appsv1.DaemonSet {
+	ds := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: pemDaemonSetName, Namespace: testNamespace},
+	}
+	ds.Spec.Template.Spec.Tolerations = tolerations
+	return ds
+}
+
+func pemPodOnNode(name, node string) *corev1.Pod {
+	pod := makePod(name, map[string]string{"app": "pl-monitoring", "name": "vizier-pem"})
+	pod.Spec.NodeName = node
+	return pod
+}
+
+func TestGetBlockingTaints(t *testing.T) {
+	node := makeNode("node-1", "4", "16Gi")
+	node.Spec.Taints = []corev1.Taint{
+		{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoExecute},
+		{Key: "dedicated", Value: "db", Effect: corev1.TaintEffectNoSchedule},
+		{Key: "spot", Effect: corev1.TaintEffectPreferNoSchedule},
+		{Key: corev1.TaintNodeDiskPressure, Effect: corev1.TaintEffectNoSchedule},
+	}
+	tolerations := append([]corev1.Toleration{
+		{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "db"},
+	}, daemonSetDefaultTolerations...)
+
+	assert.Equal(t, []string{"dedicated=gpu:NoExecute"}, getBlockingTaints(node, tolerations))
+	assert.Empty(t, getBlockingTaints(node, []corev1.Toleration{{Operator: corev1.TolerationOpExists}}))
+}
+
+func TestGetNodeStatuses(t *testing.T) {
+	healthy := makeNode("node-1", "4", "16Gi")
+	pressured := makeNode("node-2", "4", "16Gi")
+	pressured.Status.Conditions = []corev1.NodeCondition{
+		{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionFalse},
+		{Type: corev1.NodeDiskPressure, Status: corev1.ConditionTrue},
+		{Type: corev1.NodePIDPressure, Status: corev1.ConditionTrue},
+	}
+	tainted := makeNode("node-3", "4", "16Gi")
+	tainted.Spec.Taints = []corev1.Taint{{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoExecute}}
+
+	nodes := map[string]*corev1.Node{"node-1": healthy, "node-2": pressured, "node-3": tainted}
+	pods := map[string]*corev1.Pod{"vizier-pem-abcde": pemPodOnNode("vizier-pem-abcde", "node-1")}
+
+	statuses := getNodeStatuses(nodes, pods, daemonSetDefaultTolerations, true)
+	assert.Equal(t, []*NodeStatus{
+		{Name: "node-1", Instrumented: true},
+		{Name: "node-2", Pressures: []string{"DiskPressure", "PIDPressure"}},
+		{Name: "node-3", BlockingTaints: []string{"dedicated=gpu:NoExecute"}},
+	}, statuses)
+	assert.Equal(t, "node-2 DiskPressure, PIDPressure, node-3 untolerated taint dedicated=gpu:NoExecute",
+		describeUninstrumentedNodes(statuses))
+
+	// Without the DaemonSet's tolerations, taints are not reported.
+	statuses = getNodeStatuses(nodes, pods, nil, false)
+	assert.Empty(t, statuses[2].BlockingTaints)
+}
+
+func TestDescribeUninstrumentedNodes_Limit(t *testing.T) {
+	var statuses []*NodeStatus
+	for _, name := range []string{"node-1", "node-2", "node-3", "node-4", "node-5", "node-6", "node-7"} {
+		statuses = append(statuses, &NodeStatus{Name: name, Pressures: []string{"DiskPressure"}})
+	}
+	statuses = append(statuses, &NodeStatus{Name: "node-8"})
+	assert.Equal(t, "node-1 DiskPressure, node-2 DiskPressure, node-3 DiskPressure, node-4 DiskPressure, "+
+		"node-5 DiskPressure, 2 more", describeUninstrumentedNodes(statuses))
+}
+
+func TestUpdateK8sState_NodePressure(t *testing.T) {
+	selector, err := getPodSelector("app=pl-monitoring", false)
+	require.NoError(t, err)
+	tainted := makeNode("node-2", "4", "16Gi")
+	tainted.Spec.Taints = []corev1.Taint{{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoExecute}}
+	vzInfo := &K8sVizierInfo{
+		ns: testNamespace,
+		clientset: fake.NewSimpleClientset(expectedSecretObjects(
+			makeNode("node-1", "4", "16Gi"),
+			tainted,
+			pemPodOnNode("vizier-pem-abcde", "node-1"),
+			pemDaemonSet(corev1.Toleration{Key: "dedicated", Value: "db", Operator: corev1.TolerationOpEqual}),
+		)...),
+		podSelector: selector,
+	}
+
+	vzInfo.UpdateK8sState(context.Background())
+
+	state := vzInfo.GetK8sState()
+	require.Len(t, state.NodeStatuses, 2)
+	assert.True(t, state.NodeStatuses[0].Instrumented)
+	assert.Equal(t, []string{"dedicated=gpu:NoExecute"}, state.NodeStatuses[1].BlockingTaints)
+	assert.Equal(t, VizierHealthDegraded, state.VizierState.Health)
+	assert.Contains(t, state.VizierState.Reasons, "PEM coverage 1/2, uninstrumented nodes: node-2 untolerated taint dedicated=gpu:NoExecute")
+}
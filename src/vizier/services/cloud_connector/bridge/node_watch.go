@@ -0,0 +1,109 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Node changes are collected for this long before the node-dependent state is recomputed, so that the
+// autoscaler adding or removing many nodes at once only causes a single update.
+const nodeChangeDebounce = 5 * time.Second
+
+// invalidateClusterStats makes the next update recollect the cluster stats, rather than waiting for them to
+// be refreshed.
+func (v *K8sVizierInfo) invalidateClusterStats() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.clusterStatsInvalidated = true
+}
+
+// refreshNodeState recomputes the state that depends on the nodes, such as the PEM coverage and the cluster
+// stats, after nodes were added or removed.
+func (v *K8sVizierInfo) refreshNodeState() {
+	v.invalidateClusterStats()
+	ctx, cancel := context.WithTimeout(context.Background(), k8sStateUpdatePeriod)
+	defer cancel()
+	if _, err := v.ForceUpdate(ctx); err != nil {
+		log.WithError(err).Warn("Failed to update the K8s state after nodes changed")
+	}
+}
+
+// debounceNodeChanges calls refresh once for each burst of changes, debounce after the first change of the
+// burst. Further changes don't delay the refresh, so that constant churn can't hold it off forever.
+func debounceNodeChanges(changes <-chan struct{}, debounce time.Duration, refresh func(), stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-changes:
+		}
+
+		t := time.NewTimer(debounce)
+		select {
+		case <-stopCh:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		// Changes made during the wait are covered by this refresh.
+		select {
+		case <-changes:
+		default:
+		}
+		refresh()
+	}
+}
+
+// watchNodes recomputes the node-dependent state shortly after nodes are added or removed, rather than at the
+// next periodic update. It runs until stopCh is closed.
+func (v *K8sVizierInfo) watchNodes(stopCh <-chan struct{}, debounce time.Duration) {
+	changes := make(chan struct{}, 1)
+	// The initial list of nodes is already covered by the periodic updates.
+	var synced int32
+	notify := func(interface{}) {
+		if atomic.LoadInt32(&synced) == 0 {
+			return
+		}
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	}
+
+	factory := informers.NewSharedInformerFactory(v.clientset, 0)
+	informer := factory.Core().V1().Nodes().Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    notify,
+		DeleteFunc: notify,
+	})
+	factory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, informer.HasSynced) {
+		return
+	}
+	atomic.StoreInt32(&synced, 1)
+
+	debounceNodeChanges(changes, debounce, v.refreshNodeState, stopCh)
+}
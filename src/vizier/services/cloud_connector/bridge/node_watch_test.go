@@ -0,0 +1,109 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDebounceNodeChanges(t *testing.T) {
+	changes := make(chan struct{}, 1)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	var refreshes int32
+	go debounceNodeChanges(changes, 50*time.Millisecond, func() { atomic.AddInt32(&refreshes, 1) }, stopCh)
+
+	// A burst of changes only causes one refresh.
+	for i := 0; i < 5; i++ {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&refreshes) == 1 }, time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&refreshes))
+
+	// Later changes cause another refresh.
+	changes <- struct{}{}
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&refreshes) == 2 }, time.Second, 10*time.Millisecond)
+}
+
+func TestWatchNodes(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-0"}},
+		makePod("vizier-metadata-0", map[string]string{"plane": "control"}),
+	)
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+	vzInfo.UpdateK8sState(context.Background())
+	require.Equal(t, int32(1), vzInfo.GetK8sState().NumNodes)
+	require.Equal(t, int32(1), vzInfo.GetClusterStats().NumNodes)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go vzInfo.watchNodes(stopCh, 10*time.Millisecond)
+
+	// The autoscaler adds nodes. Keep adding them until the watch has started and picked them up.
+	added := 0
+	require.Eventually(t, func() bool {
+		added++
+		_, err := clientset.CoreV1().Nodes().Create(context.Background(), &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("node-%d", added)}}, metav1.CreateOptions{})
+		require.NoError(t, err)
+		time.Sleep(50 * time.Millisecond)
+		return vzInfo.GetK8sState().NumNodes > 1
+	}, 5*time.Second, 10*time.Millisecond)
+	// The cluster stats are recollected along with the rest of the node-dependent state.
+	assert.Greater(t, vzInfo.GetClusterStats().NumNodes, int32(1))
+}
+
+func TestRefreshClusterStats_Invalidated(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-0"}})
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+	now := time.Now()
+	vzInfo.refreshClusterStats(context.Background(), now)
+	require.Equal(t, int32(1), vzInfo.GetClusterStats().NumNodes)
+
+	_, err := clientset.CoreV1().Nodes().Create(context.Background(), &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// The stats are not due for a refresh yet.
+	vzInfo.refreshClusterStats(context.Background(), now.Add(time.Second))
+	assert.Equal(t, int32(1), vzInfo.GetClusterStats().NumNodes)
+
+	vzInfo.invalidateClusterStats()
+	vzInfo.refreshClusterStats(context.Background(), now.Add(2*time.Second))
+	assert.Equal(t, int32(2), vzInfo.GetClusterStats().NumNodes)
+}
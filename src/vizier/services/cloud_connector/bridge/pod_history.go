@@ -0,0 +1,117 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"px.dev/pixie/src/shared/cvmsgspb"
+	"px.dev/pixie/src/shared/k8s/metadatapb"
+)
+
+// The number of transitions kept for each pod.
+const podStatusHistoryLength = 10
+
+// How long the history of a pod is kept after the pod was last seen.
+const podStatusHistoryRetention = time.Hour
+
+// PodStatusTransition is a change in the phase or reason of a pod.
+type PodStatusTransition struct {
+	Time   time.Time
+	Phase  metadatapb.PodPhase
+	Reason string
+}
+
+// PodStatusHistory is the most recent status transitions of a single pod.
+type PodStatusHistory struct {
+	PodName string
+	UID     string
+	// Transitions are ordered from oldest to newest.
+	Transitions []PodStatusTransition
+	// The last time the pod was present in the K8s state.
+	LastSeen time.Time
+}
+
+// podStatusReason gets the reason for a pod's status. A waiting container's reason, such as CrashLoopBackOff,
+// is more specific than the pod's reason, which is typically empty while the pod is running.
+func podStatusReason(p *cvmsgspb.PodStatus) string {
+	for _, c := range p.Containers {
+		if c.State == metadatapb.CONTAINER_STATE_WAITING && c.Reason != "" {
+			return c.Reason
+		}
+	}
+	return p.StatusMessage
+}
+
+// podStatusHistories tracks the status history of each pod across updates, keyed by pod name and UID so
+// that a recreated pod with the same name starts a new history.
+type podStatusHistories map[string]*PodStatusHistory
+
+func (h podStatusHistories) update(now time.Time, podStatuses map[string]*cvmsgspb.PodStatus, podUIDs map[string]string) {
+	for name, status := range podStatuses {
+		uid := podUIDs[name]
+		key := fmt.Sprintf("%s/%s", name, uid)
+		history, ok := h[key]
+		if !ok {
+			history = &PodStatusHistory{PodName: name, UID: uid}
+			h[key] = history
+		}
+		history.LastSeen = now
+
+		transition := PodStatusTransition{Time: now, Phase: status.Status, Reason: podStatusReason(status)}
+		if n := len(history.Transitions); n > 0 {
+			last := history.Transitions[n-1]
+			if last.Phase == transition.Phase && last.Reason == transition.Reason {
+				continue
+			}
+		}
+		history.Transitions = append(history.Transitions, transition)
+		if len(history.Transitions) > podStatusHistoryLength {
+			history.Transitions = history.Transitions[len(history.Transitions)-podStatusHistoryLength:]
+		}
+	}
+
+	for key, history := range h {
+		if now.Sub(history.LastSeen) > podStatusHistoryRetention {
+			delete(h, key)
+		}
+	}
+}
+
+// GetPodStatusHistory gets the recent status transitions of each pod, ordered by pod name.
+func (v *K8sVizierInfo) GetPodStatusHistory() []*PodStatusHistory {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	histories := make([]*PodStatusHistory, 0, len(v.podHistories))
+	for _, history := range v.podHistories {
+		clone := *history
+		clone.Transitions = append([]PodStatusTransition{}, history.Transitions...)
+		histories = append(histories, &clone)
+	}
+	sort.Slice(histories, func(i, j int) bool {
+		if histories[i].PodName != histories[j].PodName {
+			return histories[i].PodName < histories[j].PodName
+		}
+		return histories[i].LastSeen.Before(histories[j].LastSeen)
+	})
+	return histories
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"px.dev/pixie/src/shared/cvmsgspb"
+	"px.dev/pixie/src/shared/k8s/metadatapb"
+)
+
+func TestPodStatusHistories_RecordsTransitions(t *testing.T) {
+	h := make(podStatusHistories)
+	uids := map[string]string{"kelvin-0": "uid-1"}
+	start := time.Now()
+
+	// Flap between running and crashlooping, with a repeated status in between.
+	statuses := []*cvmsgspb.PodStatus{
+		runningPod("kelvin-0"),
+		runningPod("kelvin-0"),
+		crashingPod("kelvin-0"),
+		runningPod("kelvin-0"),
+	}
+	for i, s := range statuses {
+		h.update(start.Add(time.Duration(i)*time.Minute), map[string]*cvmsgspb.PodStatus{"kelvin-0": s}, uids)
+	}
+
+	require.Contains(t, h, "kelvin-0/uid-1")
+	history := h["kelvin-0/uid-1"]
+	assert.Equal(t, []PodStatusTransition{
+		{Time: start, Phase: metadatapb.RUNNING},
+		{Time: start.Add(2 * time.Minute), Phase: metadatapb.RUNNING, Reason: "CrashLoopBackOff"},
+		{Time: start.Add(3 * time.Minute), Phase: metadatapb.RUNNING},
+	}, history.Transitions)
+	assert.Equal(t, start.Add(3*time.Minute), history.LastSeen)
+}
+
+func TestPodStatusHistories_Bounded(t *testing.T) {
+	h := make(podStatusHistories)
+	uids := map[string]string{"kelvin-0": "uid-1"}
+	start := time.Now()
+
+	for i := 0; i < podStatusHistoryLength+5; i++ {
+		s := runningPod("kelvin-0")
+		if i%2 == 1 {
+			s = crashingPod("kelvin-0")
+		}
+		h.update(start.Add(time.Duration(i)*time.Minute), map[string]*cvmsgspb.PodStatus{"kelvin-0": s}, uids)
+	}
+
+	transitions := h["kelvin-0/uid-1"].Transitions
+	require.Len(t, transitions, podStatusHistoryLength)
+	// The most recent transitions should be kept.
+	assert.Equal(t, start.Add(time.Duration(podStatusHistoryLength+4)*time.Minute), transitions[podStatusHistoryLength-1].Time)
+}
+
+func TestPodStatusHistories_RecreatedPodAndEviction(t *testing.T) {
+	h := make(podStatusHistories)
+	start := time.Now()
+	pods := map[string]*cvmsgspb.PodStatus{"vizier-metadata-0": runningPod("vizier-metadata-0")}
+
+	h.update(start, pods, map[string]string{"vizier-metadata-0": "uid-1"})
+	// The pod is recreated with the same name, so it should get a separate history.
+	h.update(start.Add(time.Minute), pods, map[string]string{"vizier-metadata-0": "uid-2"})
+	assert.Len(t, h, 2)
+
+	// The history of the deleted pod should be kept for the retention period.
+	h.update(start.Add(podStatusHistoryRetention), pods, map[string]string{"vizier-metadata-0": "uid-2"})
+	assert.Len(t, h, 2)
+
+	h.update(start.Add(podStatusHistoryRetention+time.Minute), pods, map[string]string{"vizier-metadata-0": "uid-2"})
+	assert.Len(t, h, 1)
+	assert.Contains(t, h, "vizier-metadata-0/uid-2")
+}
+
+func TestGetPodStatusHistory(t *testing.T) {
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: makeClusterStatsClientset(),
+	}
+	pod := makePod("vizier-query-broker-0", map[string]string{"plane": "control"})
+	pod.UID = "uid-1"
+	_, err := vzInfo.clientset.CoreV1().Pods(testNamespace).Create(context.Background(), pod, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	vzInfo.UpdateK8sState(context.Background())
+	histories := vzInfo.GetPodStatusHistory()
+	require.Len(t, histories, 1)
+	assert.Equal(t, "vizier-query-broker-0", histories[0].PodName)
+	assert.Equal(t, "uid-1", histories[0].UID)
+	require.Len(t, histories[0].Transitions, 1)
+	assert.Equal(t, metadatapb.RUNNING, histories[0].Transitions[0].Phase)
+}
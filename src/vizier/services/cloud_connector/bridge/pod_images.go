@@ -0,0 +1,121 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// pixieImageRepos are the repositories that Vizier component images are published to. Images pulled through
+// a custom registry have their path flattened with dashes, so the repos are matched in that form.
+var pixieImageRepos = []string{
+	privateImageRepo + "/vizier/",
+	publicImageRepo + "/vizier/",
+}
+
+// ContainerImage is the image that a container in a pod is running.
+type ContainerImage struct {
+	Container string
+	// The full image reference. Ex: gcr.io/pixie-oss/pixie-prod/vizier/pem_image:0.12.0
+	Image      string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// Version returns the tag of the image, or its digest if it has no tag.
+func (c ContainerImage) Version() string {
+	if c.Tag != "" {
+		return c.Tag
+	}
+	return c.Digest
+}
+
+// parseImageRef splits an image reference of the form [registry/]repository[:tag][@digest].
+func parseImageRef(image string) (repository, tag, digest string) {
+	repository = image
+	if i := strings.Index(repository, "@"); i >= 0 {
+		repository, digest = repository[:i], repository[i+1:]
+	}
+	// A colon before the last slash separates the registry host from its port, rather than the tag.
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository, tag = repository[:i], repository[i+1:]
+	}
+	return repository, tag, digest
+}
+
+func getPodImages(pod *corev1.Pod) []ContainerImage {
+	images := make([]ContainerImage, 0, len(pod.Spec.Containers))
+	for _, c := range pod.Spec.Containers {
+		repository, tag, digest := parseImageRef(c.Image)
+		images = append(images, ContainerImage{
+			Container:  c.Name,
+			Image:      c.Image,
+			Repository: repository,
+			Tag:        tag,
+			Digest:     digest,
+		})
+	}
+	return images
+}
+
+// isPixieImage returns whether the image repository belongs to a Vizier component, rather than a dependency
+// or sidecar.
+func isPixieImage(repository string) bool {
+	flattened := strings.ReplaceAll(repository, "/", "-")
+	for _, repo := range pixieImageRepos {
+		if strings.Contains(flattened, strings.ReplaceAll(repo, "/", "-")) {
+			return true
+		}
+	}
+	return false
+}
+
+// getPixieImageVersions returns the distinct versions of the Vizier component images in use, sorted.
+func getPixieImageVersions(podImages map[string][]ContainerImage) []string {
+	versionSet := make(map[string]struct{})
+	for _, images := range podImages {
+		for _, img := range images {
+			if !isPixieImage(img.Repository) || img.Version() == "" {
+				continue
+			}
+			versionSet[img.Version()] = struct{}{}
+		}
+	}
+	versions := make([]string, 0, len(versionSet))
+	for v := range versionSet {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+func copyPodImages(podImages map[string][]ContainerImage) map[string][]ContainerImage {
+	if podImages == nil {
+		return nil
+	}
+	clone := make(map[string][]ContainerImage, len(podImages))
+	for k, v := range podImages {
+		clone[k] = v
+	}
+	return clone
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestParseImageRef(t *testing.T) {
+	tests := []struct {
+		name           string
+		image          string
+		wantRepository string
+		wantTag        string
+		wantDigest     string
+	}{
+		{
+			name:           "tag",
+			image:          "gcr.io/pixie-oss/pixie-prod/vizier/pem_image:0.12.0",
+			wantRepository: "gcr.io/pixie-oss/pixie-prod/vizier/pem_image",
+			wantTag:        "0.12.0",
+		},
+		{
+			name:           "digest",
+			image:          "gcr.io/pixie-oss/pixie-prod/vizier/pem_image@sha256:abcd",
+			wantRepository: "gcr.io/pixie-oss/pixie-prod/vizier/pem_image",
+			wantDigest:     "sha256:abcd",
+		},
+		{
+			name:           "tag and digest",
+			image:          "gcr.io/pixie-oss/pixie-prod/vizier/pem_image:0.12.0@sha256:abcd",
+			wantRepository: "gcr.io/pixie-oss/pixie-prod/vizier/pem_image",
+			wantTag:        "0.12.0",
+			wantDigest:     "sha256:abcd",
+		},
+		{
+			name:           "registry port without tag",
+			image:          "localhost:5000/vizier/pem_image",
+			wantRepository: "localhost:5000/vizier/pem_image",
+		},
+		{
+			name:           "registry port with tag",
+			image:          "localhost:5000/vizier/pem_image:0.12.0",
+			wantRepository: "localhost:5000/vizier/pem_image",
+			wantTag:        "0.12.0",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repository, tag, digest := parseImageRef(test.image)
+			assert.Equal(t, test.wantRepository, repository)
+			assert.Equal(t, test.wantTag, tag)
+			assert.Equal(t, test.wantDigest, digest)
+		})
+	}
+}
+
+func TestIsPixieImage(t *testing.T) {
+	assert.True(t, isPixieImage("gcr.io/pixie-oss/pixie-prod/vizier/pem_image"))
+	assert.True(t, isPixieImage("gcr.io/pixie-oss/pixie-dev/vizier/kelvin_image"))
+	// Images pulled through a custom registry are flattened.
+	assert.True(t, isPixieImage("my.registry.io/gcr.io-pixie-oss-pixie-prod-vizier-pem_image"))
+	assert.False(t, isPixieImage("gcr.io/pixie-oss/pixie-dev-public/curl"))
+	assert.False(t, isPixieImage("nats"))
+	assert.False(t, isPixieImage("istio/proxyv2"))
+}
+
+func makeImagePod(name string, images ...string) *corev1.Pod {
+	pod := makePod(name, map[string]string{})
+	for i, image := range images {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{
+			Name:  "container-" + string(rune('a'+i)),
+			Image: image,
+		})
+	}
+	return pod
+}
+
+func TestGetPixieImageVersions(t *testing.T) {
+	podImages := map[string][]ContainerImage{
+		"vizier-pem-abcd": getPodImages(makeImagePod("vizier-pem-abcd",
+			"gcr.io/pixie-oss/pixie-prod/vizier/pem_image:0.12.0",
+			// Sidecars are ignored.
+			"istio/proxyv2:1.13.0",
+		)),
+		"kelvin-abcd": getPodImages(makeImagePod("kelvin-abcd",
+			"gcr.io/pixie-oss/pixie-prod/vizier/kelvin_image:0.12.0",
+		)),
+	}
+	assert.Equal(t, []string{"0.12.0"}, getPixieImageVersions(podImages))
+	assert.Equal(t, VizierHealthHealthy, computeVizierState(&K8sState{PodImages: podImages, LastUpdated: time.Now()}).Health)
+
+	podImages["vizier-pem-efgh"] = getPodImages(makeImagePod("vizier-pem-efgh",
+		"gcr.io/pixie-oss/pixie-prod/vizier/pem_image:0.11.9",
+	))
+	assert.Equal(t, []string{"0.11.9", "0.12.0"}, getPixieImageVersions(podImages))
+
+	state := computeVizierState(&K8sState{PodImages: podImages, LastUpdated: time.Now()})
+	assert.Equal(t, VizierHealthDegraded, state.Health)
+	assert.Contains(t, state.Reasons, "version skew detected: 0.11.9, 0.12.0")
+}
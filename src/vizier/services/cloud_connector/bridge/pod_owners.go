@@ -0,0 +1,170 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// PodOwner is the workload that manages a pod, such as the Deployment of a pod that is owned by a ReplicaSet.
+type PodOwner struct {
+	Kind string
+	Name string
+}
+
+// replicaSetOwners caches the owner of each ReplicaSet, keyed by ReplicaSet UID. A ReplicaSet's owner never
+// changes, so it only has to be looked up once.
+type replicaSetOwners map[types.UID]*PodOwner
+
+// guessReplicaSetOwner returns the Deployment that a ReplicaSet named by the Deployment controller belongs to.
+// Such ReplicaSets are named <deployment>-<pod-template-hash>. Otherwise, the ReplicaSet is its own owner.
+func guessReplicaSetOwner(pod *corev1.Pod, replicaSet string) *PodOwner {
+	hash := pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+	if hash != "" && strings.HasSuffix(replicaSet, "-"+hash) {
+		return &PodOwner{Kind: "Deployment", Name: strings.TrimSuffix(replicaSet, "-"+hash)}
+	}
+	return &PodOwner{Kind: "ReplicaSet", Name: replicaSet}
+}
+
+// getReplicaSetOwner returns the owner of the given ReplicaSet, looking it up if it isn't cached yet.
+func (v *K8sVizierInfo) getReplicaSetOwner(ctx context.Context, pod *corev1.Pod, ref *metav1.OwnerReference, cache replicaSetOwners) *PodOwner {
+	if owner, ok := cache[ref.UID]; ok {
+		return owner
+	}
+	if !v.collectorAvailable(collectorReplicaSets) {
+		return guessReplicaSetOwner(pod, ref.Name)
+	}
+	rs, err := v.clientset.AppsV1().ReplicaSets(pod.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		// A ReplicaSet that is being deleted along with its pods is expected to go missing.
+		if !k8sErrors.IsNotFound(err) {
+			recordK8sAPIError("replicasets")
+			log.WithError(err).WithField("replicaSet", ref.Name).Debug("Failed to get ReplicaSet to find the owner of its pods")
+		}
+		return guessReplicaSetOwner(pod, ref.Name)
+	}
+	owner := &PodOwner{Kind: "ReplicaSet", Name: rs.Name}
+	if rsRef := metav1.GetControllerOf(rs); rsRef != nil {
+		owner = &PodOwner{Kind: rsRef.Kind, Name: rsRef.Name}
+	}
+	cache[ref.UID] = owner
+	return owner
+}
+
+// getPodOwners returns the workload that manages each of the pods, keyed by pod name. Pods that are not managed
+// by a controller are left out. The ReplicaSets that are still in use are kept in the cache for the next update,
+// and the rest are dropped.
+func (v *K8sVizierInfo) getPodOwners(ctx context.Context, pods map[string]*corev1.Pod, cache replicaSetOwners) (map[string]*PodOwner, replicaSetOwners) {
+	owners := make(map[string]*PodOwner, len(pods))
+	used := make(replicaSetOwners)
+	for name, p := range pods {
+		ref := metav1.GetControllerOf(p)
+		if ref == nil {
+			continue
+		}
+		if ref.Kind != "ReplicaSet" {
+			owners[name] = &PodOwner{Kind: ref.Kind, Name: ref.Name}
+			continue
+		}
+		owner := v.getReplicaSetOwner(ctx, p, ref, cache)
+		if cached, ok := cache[ref.UID]; ok {
+			used[ref.UID] = cached
+		}
+		owners[name] = owner
+	}
+	return owners, used
+}
+
+func copyPodOwners(owners map[string]*PodOwner) map[string]*PodOwner {
+	if owners == nil {
+		return nil
+	}
+	clone := make(map[string]*PodOwner, len(owners))
+	for name, o := range owners {
+		owner := *o
+		clone[name] = &owner
+	}
+	return clone
+}
+
+// A podProblem is why a pod is not healthy.
+type podProblem struct {
+	// The key of the pod in the K8s state.
+	key  string
+	pod  string
+	desc string
+}
+
+// podOwnerKey returns the pod's owner, qualified by the pod's namespace if the pod is outside of the Vizier
+// namespace. Pods keyed with a namespace are outside of it.
+func podOwnerKey(podKey string, owner *PodOwner) string {
+	if i := strings.Index(podKey, "/"); i >= 0 {
+		return fmt.Sprintf("%s/%s %s", podKey[:i], owner.Name, owner.Kind)
+	}
+	return fmt.Sprintf("%s %s", owner.Name, owner.Kind)
+}
+
+// describePodProblems returns a reason for each of the problems. Pods of the same workload with the same problem
+// are described together, ex: "vizier-pem DaemonSet: 2 pods CrashLoopBackOff", since their hashed names tell the
+// user nothing more.
+func describePodProblems(owners map[string]*PodOwner, problems []podProblem) []string {
+	type group struct {
+		owner string
+		desc  string
+	}
+	groupOf := func(p podProblem) (group, bool) {
+		owner, ok := owners[p.key]
+		if !ok {
+			return group{}, false
+		}
+		return group{owner: podOwnerKey(p.key, owner), desc: p.desc}, true
+	}
+
+	counts := make(map[group]int)
+	for _, p := range problems {
+		if g, ok := groupOf(p); ok {
+			counts[g]++
+		}
+	}
+
+	var reasons []string
+	described := make(map[group]bool)
+	for _, p := range problems {
+		g, ok := groupOf(p)
+		if !ok || counts[g] < 2 {
+			reasons = append(reasons, fmt.Sprintf("%s %s", p.pod, p.desc))
+			continue
+		}
+		if described[g] {
+			continue
+		}
+		described[g] = true
+		reasons = append(reasons, fmt.Sprintf("%s: %d pods %s", g.owner, counts[g], g.desc))
+	}
+	return reasons
+}
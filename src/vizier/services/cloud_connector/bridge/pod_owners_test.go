@@ -0,0 +1,173 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"px.dev/pixie/src/shared/cvmsgspb"
+	"px.dev/pixie/src/shared/k8s/metadatapb"
+)
+
+func controllerRef(kind, name string, uid types.UID) metav1.OwnerReference {
+	isController := true
+	return metav1.OwnerReference{Kind: kind, Name: name, UID: uid, Controller: &isController}
+}
+
+func makeOwnedPod(name string, labels map[string]string, owner metav1.OwnerReference) *corev1.Pod {
+	pod := makePod(name, labels)
+	pod.OwnerReferences = []metav1.OwnerReference{owner}
+	return pod
+}
+
+func countReplicaSetGets(clientset *fake.Clientset) int {
+	count := 0
+	for _, a := range clientset.Actions() {
+		if a.GetVerb() == "get" && a.GetResource().Resource == "replicasets" {
+			count++
+		}
+	}
+	return count
+}
+
+func TestGetPodOwners(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "vizier-query-broker-5f7b6d4c9",
+			Namespace:       testNamespace,
+			UID:             "rs-1",
+			OwnerReferences: []metav1.OwnerReference{controllerRef("Deployment", "vizier-query-broker", "deploy-1")},
+		},
+	})
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+	pods := map[string]*corev1.Pod{
+		"vizier-query-broker-5f7b6d4c9-abcde": makeOwnedPod("vizier-query-broker-5f7b6d4c9-abcde", nil, controllerRef("ReplicaSet", "vizier-query-broker-5f7b6d4c9", "rs-1")),
+		"vizier-query-broker-5f7b6d4c9-fghij": makeOwnedPod("vizier-query-broker-5f7b6d4c9-fghij", nil, controllerRef("ReplicaSet", "vizier-query-broker-5f7b6d4c9", "rs-1")),
+		// The ReplicaSet was deleted, but it is named after its Deployment.
+		"kelvin-6c8d9b7f4-abcde": makeOwnedPod("kelvin-6c8d9b7f4-abcde", map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: "6c8d9b7f4"}, controllerRef("ReplicaSet", "kelvin-6c8d9b7f4", "rs-2")),
+		"vizier-pem-abcde":       makeOwnedPod("vizier-pem-abcde", nil, controllerRef("DaemonSet", "vizier-pem", "ds-1")),
+		"pl-nats-0":              makeOwnedPod("pl-nats-0", nil, controllerRef("StatefulSet", "pl-nats", "sts-1")),
+		"debug-pod":              makePod("debug-pod", nil),
+	}
+
+	owners, cache := vzInfo.getPodOwners(context.Background(), pods, make(replicaSetOwners))
+	assert.Equal(t, map[string]*PodOwner{
+		"vizier-query-broker-5f7b6d4c9-abcde": {Kind: "Deployment", Name: "vizier-query-broker"},
+		"vizier-query-broker-5f7b6d4c9-fghij": {Kind: "Deployment", Name: "vizier-query-broker"},
+		"kelvin-6c8d9b7f4-abcde":              {Kind: "Deployment", Name: "kelvin"},
+		"vizier-pem-abcde":                    {Kind: "DaemonSet", Name: "vizier-pem"},
+		"pl-nats-0":                           {Kind: "StatefulSet", Name: "pl-nats"},
+	}, owners)
+	// Both of the query broker pods are resolved with one lookup. The missing ReplicaSet isn't cached, so
+	// that it is looked up again if it shows up.
+	assert.Equal(t, 2, countReplicaSetGets(clientset))
+	assert.Equal(t, replicaSetOwners{"rs-1": {Kind: "Deployment", Name: "vizier-query-broker"}}, cache)
+
+	// The next update uses the cache.
+	clientset.ClearActions()
+	owners, cache = vzInfo.getPodOwners(context.Background(), pods, cache)
+	assert.Equal(t, "vizier-query-broker", owners["vizier-query-broker-5f7b6d4c9-abcde"].Name)
+	assert.Equal(t, 1, countReplicaSetGets(clientset))
+
+	// ReplicaSets that no longer own any of the pods are dropped from the cache.
+	delete(pods, "vizier-query-broker-5f7b6d4c9-abcde")
+	delete(pods, "vizier-query-broker-5f7b6d4c9-fghij")
+	_, cache = vzInfo.getPodOwners(context.Background(), pods, cache)
+	assert.Empty(t, cache)
+}
+
+func TestGuessReplicaSetOwner(t *testing.T) {
+	pod := makePod("kelvin-6c8d9b7f4-abcde", map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: "6c8d9b7f4"})
+	assert.Equal(t, &PodOwner{Kind: "Deployment", Name: "kelvin"}, guessReplicaSetOwner(pod, "kelvin-6c8d9b7f4"))
+	assert.Equal(t, &PodOwner{Kind: "ReplicaSet", Name: "kelvin"}, guessReplicaSetOwner(makePod("kelvin-abcde", nil), "kelvin"))
+}
+
+func TestDescribePodProblems(t *testing.T) {
+	owners := map[string]*PodOwner{
+		"vizier-pem-abcde":                    {Kind: "DaemonSet", Name: "vizier-pem"},
+		"vizier-pem-fghij":                    {Kind: "DaemonSet", Name: "vizier-pem"},
+		"vizier-pem-klmno":                    {Kind: "DaemonSet", Name: "vizier-pem"},
+		"px-operator/vizier-operator-6f8b9-a": {Kind: "Deployment", Name: "vizier-operator"},
+		"px-operator/vizier-operator-6f8b9-b": {Kind: "Deployment", Name: "vizier-operator"},
+	}
+	assert.Equal(t, []string{
+		"kelvin-0 CrashLoopBackOff",
+		"px-operator/vizier-operator Deployment: 2 pods Pending",
+		"vizier-pem DaemonSet: 2 pods CrashLoopBackOff",
+		"vizier-pem-klmno OOMKilled",
+	}, describePodProblems(owners, []podProblem{
+		{key: "kelvin-0", pod: "kelvin-0", desc: "CrashLoopBackOff"},
+		{key: "px-operator/vizier-operator-6f8b9-a", pod: "vizier-operator-6f8b9-a", desc: "Pending"},
+		{key: "px-operator/vizier-operator-6f8b9-b", pod: "vizier-operator-6f8b9-b", desc: "Pending"},
+		{key: "vizier-pem-abcde", pod: "vizier-pem-abcde", desc: "CrashLoopBackOff"},
+		{key: "vizier-pem-fghij", pod: "vizier-pem-fghij", desc: "CrashLoopBackOff"},
+		{key: "vizier-pem-klmno", pod: "vizier-pem-klmno", desc: "OOMKilled"},
+	}))
+	assert.Nil(t, describePodProblems(owners, nil))
+}
+
+func TestCheckDataPlanePods_GroupsByOwner(t *testing.T) {
+	crashLooping := func(name string) *cvmsgspb.PodStatus {
+		return &cvmsgspb.PodStatus{
+			Name:   name,
+			Status: metadatapb.RUNNING,
+			Containers: []*cvmsgspb.ContainerStatus{
+				{Name: "pem", State: metadatapb.CONTAINER_STATE_WAITING, Reason: "CrashLoopBackOff"},
+			},
+		}
+	}
+	health, reasons := checkDataPlanePods(&K8sState{
+		UnhealthyDataPlanePodStatuses: map[string]*cvmsgspb.PodStatus{
+			"vizier-pem-abcde": crashLooping("vizier-pem-abcde"),
+			"vizier-pem-fghij": crashLooping("vizier-pem-fghij"),
+		},
+		PodOwners: map[string]*PodOwner{
+			"vizier-pem-abcde": {Kind: "DaemonSet", Name: "vizier-pem"},
+			"vizier-pem-fghij": {Kind: "DaemonSet", Name: "vizier-pem"},
+		},
+	})
+	assert.Equal(t, VizierHealthDegraded, health)
+	assert.Equal(t, []string{"vizier-pem DaemonSet: 2 pods CrashLoopBackOff"}, reasons)
+}
+
+func TestUpdateK8sState_PodOwners(t *testing.T) {
+	vzInfo := &K8sVizierInfo{
+		ns: testNamespace,
+		clientset: fake.NewSimpleClientset(
+			makeOwnedPod("vizier-metadata-0", map[string]string{"plane": "control"}, controllerRef("StatefulSet", "vizier-metadata", "sts-1")),
+		),
+	}
+	vzInfo.UpdateK8sState(context.Background())
+
+	state := vzInfo.GetK8sState()
+	require.NotNil(t, state.PodOwners)
+	assert.Equal(t, &PodOwner{Kind: "StatefulSet", Name: "vizier-metadata"}, state.PodOwners["vizier-metadata-0"])
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// The pod placement lists at most this many nodes, so that it stays small on large clusters.
+const maxPodPlacementNodes = 20
+
+// unscheduledNode is the node that pods which have not been scheduled yet are listed under.
+const unscheduledNode = "unscheduled"
+
+// PodPlacement describes which of the Vizier components are running on each node, for finding components that
+// share an overloaded node.
+type PodPlacement struct {
+	// The components of the Vizier pods on each node, sorted and keyed by node name. A component that has
+	// several pods on the node is listed once for each pod. Pods that are not scheduled yet are listed under
+	// "unscheduled".
+	Nodes map[string][]string
+	// The number of nodes that were left out because there were too many to list. Nodes that only run
+	// DaemonSet pods, such as the PEMs, are left out first.
+	OmittedNodes int
+}
+
+// podComponent returns the component that the pod belongs to, which is the workload that manages it, or the pod
+// itself if it has none. Pods outside of the Vizier namespace keep their namespace prefix.
+func podComponent(key string, owners map[string]*PodOwner) string {
+	owner, ok := owners[key]
+	if !ok {
+		return key
+	}
+	if i := strings.Index(key, "/"); i >= 0 {
+		return key[:i+1] + owner.Name
+	}
+	return owner.Name
+}
+
+// getPodPlacement returns the components running on each node. If there are more than maxPodPlacementNodes
+// nodes, the nodes that only run DaemonSet pods are summarized by OmittedNodes, followed by the nodes that sort
+// last by name.
+func getPodPlacement(pods map[string]*corev1.Pod, owners map[string]*PodOwner) *PodPlacement {
+	nodes := make(map[string][]string)
+	// Whether each node runs any pod that isn't managed by a DaemonSet.
+	interesting := make(map[string]bool)
+	for key, p := range pods {
+		node := p.Spec.NodeName
+		if node == "" {
+			node = unscheduledNode
+		}
+		nodes[node] = append(nodes[node], podComponent(key, owners))
+		if owner, ok := owners[key]; !ok || owner.Kind != "DaemonSet" || node == unscheduledNode {
+			interesting[node] = true
+		}
+	}
+	for _, components := range nodes {
+		sort.Strings(components)
+	}
+
+	placement := &PodPlacement{Nodes: nodes}
+	if len(nodes) <= maxPodPlacementNodes {
+		return placement
+	}
+
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// List the nodes running the components that can share a node first, then fill up with the rest.
+	sort.SliceStable(names, func(i, j int) bool {
+		return interesting[names[i]] && !interesting[names[j]]
+	})
+	placement.Nodes = make(map[string][]string, maxPodPlacementNodes)
+	for i, name := range names {
+		if i >= maxPodPlacementNodes {
+			placement.OmittedNodes++
+			continue
+		}
+		placement.Nodes[name] = nodes[name]
+	}
+	return placement
+}
+
+func copyPodPlacement(placement *PodPlacement) *PodPlacement {
+	if placement == nil {
+		return nil
+	}
+	clone := &PodPlacement{
+		Nodes:        make(map[string][]string, len(placement.Nodes)),
+		OmittedNodes: placement.OmittedNodes,
+	}
+	for node, components := range placement.Nodes {
+		clone.Nodes[node] = append([]string(nil), components...)
+	}
+	return clone
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func makeScheduledPod(name, node string) *corev1.Pod {
+	pod := makePod(name, nil)
+	pod.Spec.NodeName = node
+	return pod
+}
+
+func TestGetPodPlacement(t *testing.T) {
+	pods := map[string]*corev1.Pod{
+		"kelvin-6c8d9b7f4-abcde":              makeScheduledPod("kelvin-6c8d9b7f4-abcde", "node-1"),
+		"vizier-metadata-0":                   makeScheduledPod("vizier-metadata-0", "node-1"),
+		"vizier-pem-abcde":                    makeScheduledPod("vizier-pem-abcde", "node-1"),
+		"vizier-pem-fghij":                    makeScheduledPod("vizier-pem-fghij", "node-2"),
+		"vizier-query-broker-5f7b6d4c9-abcde": makeScheduledPod("vizier-query-broker-5f7b6d4c9-abcde", ""),
+		"px-operator/vizier-operator-6f8b9-a": makeScheduledPod("vizier-operator-6f8b9-a", "node-2"),
+		"debug-pod":                           makeScheduledPod("debug-pod", "node-2"),
+	}
+	owners := map[string]*PodOwner{
+		"kelvin-6c8d9b7f4-abcde":              {Kind: "Deployment", Name: "kelvin"},
+		"vizier-metadata-0":                   {Kind: "StatefulSet", Name: "vizier-metadata"},
+		"vizier-pem-abcde":                    {Kind: "DaemonSet", Name: "vizier-pem"},
+		"vizier-pem-fghij":                    {Kind: "DaemonSet", Name: "vizier-pem"},
+		"vizier-query-broker-5f7b6d4c9-abcde": {Kind: "Deployment", Name: "vizier-query-broker"},
+		"px-operator/vizier-operator-6f8b9-a": {Kind: "Deployment", Name: "vizier-operator"},
+	}
+
+	assert.Equal(t, &PodPlacement{
+		Nodes: map[string][]string{
+			"node-1":      {"kelvin", "vizier-metadata", "vizier-pem"},
+			"node-2":      {"debug-pod", "px-operator/vizier-operator", "vizier-pem"},
+			"unscheduled": {"vizier-query-broker"},
+		},
+	}, getPodPlacement(pods, owners))
+}
+
+func TestGetPodPlacement_SizeGuard(t *testing.T) {
+	pods := make(map[string]*corev1.Pod)
+	owners := make(map[string]*PodOwner)
+	for i := 0; i < maxPodPlacementNodes+5; i++ {
+		name := fmt.Sprintf("vizier-pem-%02d", i)
+		pods[name] = makeScheduledPod(name, fmt.Sprintf("node-%02d", i))
+		owners[name] = &PodOwner{Kind: "DaemonSet", Name: "vizier-pem"}
+	}
+	// Kelvin and the metadata store are on the nodes that would be left out if the nodes were only sorted by name.
+	pods["kelvin-0"] = makeScheduledPod("kelvin-0", "node-23")
+	pods["vizier-metadata-0"] = makeScheduledPod("vizier-metadata-0", "node-24")
+	pods["vizier-cloud-connector-0"] = makeScheduledPod("vizier-cloud-connector-0", "")
+
+	placement := getPodPlacement(pods, owners)
+	assert.Len(t, placement.Nodes, maxPodPlacementNodes)
+	// 25 nodes, and the unscheduled pods.
+	assert.Equal(t, 6, placement.OmittedNodes)
+	assert.Equal(t, []string{"kelvin-0", "vizier-pem"}, placement.Nodes["node-23"])
+	assert.Equal(t, []string{"vizier-metadata-0", "vizier-pem"}, placement.Nodes["node-24"])
+	assert.Equal(t, []string{"vizier-cloud-connector-0"}, placement.Nodes[unscheduledNode])
+	assert.Contains(t, placement.Nodes, "node-00")
+	assert.NotContains(t, placement.Nodes, "node-22")
+}
+
+func TestUpdateK8sState_PodPlacement(t *testing.T) {
+	pod := makePod("vizier-metadata-0", map[string]string{"plane": "control"})
+	pod.Spec.NodeName = "node-1"
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: fake.NewSimpleClientset(pod),
+	}
+	vzInfo.UpdateK8sState(context.Background())
+
+	state := vzInfo.GetK8sState()
+	require.NotNil(t, state.PodPlacement)
+	assert.Equal(t, map[string][]string{"node-1": {"vizier-metadata-0"}}, state.PodPlacement.Nodes)
+	assert.Equal(t, state.PodPlacement.Nodes, vzInfo.GetCollectorStatus().PodPlacement)
+}
@@ -0,0 +1,185 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"px.dev/pixie/src/shared/cvmsgspb"
+)
+
+// How long a running pod can be unready before it degrades the Vizier. This avoids flagging pods that are
+// still starting up, or that fail a single readiness probe.
+const podUnreadyDegradedAfter = 5 * time.Minute
+
+// The prefix of the events K8s records when a readiness probe fails.
+const readinessProbeFailedPrefix = "Readiness probe failed"
+
+// PodReadiness describes whether a running pod is ready to serve, as reported by its PodReady and
+// ContainersReady conditions.
+type PodReadiness struct {
+	Ready           bool
+	ReadyContainers int32
+	TotalContainers int32
+	// When the pod last became unready. Only set if the pod is not ready.
+	NotReadySince time.Time
+	// Why the pod is not ready. Ex: "Readiness probe failed: HTTP probe failed with statuscode: 503".
+	Message string
+}
+
+// getPodCondition returns the condition of the given type, or nil if the pod does not report it.
+func getPodCondition(pod *corev1.Pod, t corev1.PodConditionType) *corev1.PodCondition {
+	for i := range pod.Status.Conditions {
+		if pod.Status.Conditions[i].Type == t {
+			return &pod.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// countReadyContainers returns the number of ready containers in the pod, and its total number of containers.
+func countReadyContainers(pod *corev1.Pod) (int32, int32) {
+	ready := int32(0)
+	for _, c := range pod.Status.ContainerStatuses {
+		if c.Ready {
+			ready++
+		}
+	}
+	return ready, int32(len(pod.Spec.Containers))
+}
+
+// getPodReadiness returns the readiness of the pod, or nil if the pod is not running. Pods that don't report a
+// PodReady condition are considered ready.
+func getPodReadiness(pod *corev1.Pod) *PodReadiness {
+	if pod.Status.Phase != corev1.PodRunning {
+		return nil
+	}
+
+	readyContainers, totalContainers := countReadyContainers(pod)
+	r := &PodReadiness{
+		Ready:           true,
+		ReadyContainers: readyContainers,
+		TotalContainers: totalContainers,
+	}
+	podReady := getPodCondition(pod, corev1.PodReady)
+	if podReady == nil || podReady.Status == corev1.ConditionTrue {
+		return r
+	}
+
+	r.Ready = false
+	r.NotReadySince = podReady.LastTransitionTime.Time
+	r.Message = podReady.Message
+	// Unready containers explain more than the pod condition, which only lists their names.
+	if containersReady := getPodCondition(pod, corev1.ContainersReady); containersReady != nil && containersReady.Status != corev1.ConditionTrue {
+		for _, c := range pod.Status.ContainerStatuses {
+			if c.Ready {
+				continue
+			}
+			if c.State.Waiting != nil && c.State.Waiting.Message != "" {
+				r.Message = c.State.Waiting.Message
+				break
+			}
+			if c.State.Terminated != nil && c.State.Terminated.Message != "" {
+				r.Message = c.State.Terminated.Message
+				break
+			}
+		}
+	}
+	return r
+}
+
+// getReadinessProbeMessage returns the message of the most recent failed readiness probe in the events, if any.
+func getReadinessProbeMessage(events []*cvmsgspb.K8SEvent) string {
+	for i := len(events) - 1; i >= 0; i-- {
+		if strings.HasPrefix(events[i].Message, readinessProbeFailedPrefix) {
+			return strings.TrimSpace(events[i].Message)
+		}
+	}
+	return ""
+}
+
+// getPodReadinesses returns the readiness of each of the running pods. The events in the pod statuses are
+// used to explain why a pod is not ready, since the pod itself does not record probe failures. Terminating
+// pods are left out, since they are expected to stop being ready.
+func getPodReadinesses(pods map[string]*corev1.Pod, podStatuses map[string]*cvmsgspb.PodStatus) map[string]*PodReadiness {
+	readinesses := make(map[string]*PodReadiness)
+	for name, p := range pods {
+		if p.DeletionTimestamp != nil {
+			continue
+		}
+		r := getPodReadiness(p)
+		if r == nil {
+			continue
+		}
+		if s, ok := podStatuses[name]; ok && !r.Ready {
+			if msg := getReadinessProbeMessage(s.Events); msg != "" {
+				r.Message = msg
+			}
+		}
+		readinesses[name] = r
+	}
+	return readinesses
+}
+
+func copyPodReadinesses(readinesses map[string]*PodReadiness) map[string]*PodReadiness {
+	if readinesses == nil {
+		return nil
+	}
+	clone := make(map[string]*PodReadiness, len(readinesses))
+	for name, r := range readinesses {
+		c := *r
+		clone[name] = &c
+	}
+	return clone
+}
+
+// Pods that have been running but unready for a while degrade the Vizier, since they are not serving.
+func checkPodReadiness(s *K8sState) (VizierHealth, []string) {
+	names := make([]string, 0, len(s.PodReadiness))
+	for name := range s.PodReadiness {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var reasons []string
+	for _, name := range names {
+		r := s.PodReadiness[name]
+		if r.Ready || r.NotReadySince.IsZero() {
+			continue
+		}
+		unreadyFor := s.LastUpdated.Sub(r.NotReadySince)
+		if unreadyFor < podUnreadyDegradedAfter {
+			continue
+		}
+		reason := fmt.Sprintf("%s not ready for %s (%d/%d containers ready)", name, unreadyFor.Round(time.Minute), r.ReadyContainers, r.TotalContainers)
+		if r.Message != "" {
+			reason = fmt.Sprintf("%s: %s", reason, r.Message)
+		}
+		reasons = append(reasons, reason)
+	}
+	if len(reasons) == 0 {
+		return VizierHealthHealthy, nil
+	}
+	return VizierHealthDegraded, reasons
+}
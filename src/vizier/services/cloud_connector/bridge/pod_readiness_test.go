@@ -0,0 +1,185 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"px.dev/pixie/src/shared/cvmsgspb"
+)
+
+func readyPod(name string) *corev1.Pod {
+	pod := makePod(name, map[string]string{"app": "pl-monitoring", "plane": "control"})
+	pod.Spec.Containers = []corev1.Container{{Name: "app"}}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "app", Ready: true, State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+	}
+	pod.Status.Conditions = []corev1.PodCondition{
+		{Type: corev1.ContainersReady, Status: corev1.ConditionTrue},
+		{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+	}
+	return pod
+}
+
+func unreadyPod(name string, since time.Time) *corev1.Pod {
+	pod := makePod(name, map[string]string{"app": "pl-monitoring", "plane": "control"})
+	pod.Spec.Containers = []corev1.Container{{Name: "app"}, {Name: "proxy"}}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "app", Ready: false, State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+		{Name: "proxy", Ready: true, State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+	}
+	pod.Status.Conditions = []corev1.PodCondition{
+		{
+			Type:               corev1.ContainersReady,
+			Status:             corev1.ConditionFalse,
+			LastTransitionTime: metav1.NewTime(since),
+			Message:            "containers with unready status: [app]",
+		},
+		{
+			Type:               corev1.PodReady,
+			Status:             corev1.ConditionFalse,
+			LastTransitionTime: metav1.NewTime(since),
+			Message:            "containers with unready status: [app]",
+		},
+	}
+	return pod
+}
+
+// probelessPod is a running pod that does not report any conditions.
+func probelessPod(name string) *corev1.Pod {
+	pod := makePod(name, map[string]string{"app": "pl-monitoring", "plane": "control"})
+	pod.Spec.Containers = []corev1.Container{{Name: "app"}}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "app", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+	}
+	return pod
+}
+
+func TestGetPodReadiness(t *testing.T) {
+	since := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, &PodReadiness{Ready: true, ReadyContainers: 1, TotalContainers: 1}, getPodReadiness(readyPod("vizier-metadata-0")))
+	assert.Equal(t, &PodReadiness{Ready: true, ReadyContainers: 0, TotalContainers: 1}, getPodReadiness(probelessPod("vizier-metadata-0")))
+	assert.Equal(t, &PodReadiness{
+		Ready:           false,
+		ReadyContainers: 1,
+		TotalContainers: 2,
+		NotReadySince:   since,
+		Message:         "containers with unready status: [app]",
+	}, getPodReadiness(unreadyPod("vizier-metadata-0", since)))
+
+	pending := readyPod("vizier-metadata-0")
+	pending.Status.Phase = corev1.PodPending
+	assert.Nil(t, getPodReadiness(pending))
+}
+
+func TestGetPodReadinesses_ProbeMessage(t *testing.T) {
+	since := time.Now().Add(-time.Hour)
+	pods := map[string]*corev1.Pod{
+		"vizier-metadata-0":        unreadyPod("vizier-metadata-0", since),
+		"vizier-cloud-connector-0": readyPod("vizier-cloud-connector-0"),
+	}
+	podStatuses := map[string]*cvmsgspb.PodStatus{
+		"vizier-metadata-0": {
+			Name: "vizier-metadata-0",
+			Events: []*cvmsgspb.K8SEvent{
+				{Message: "Readiness probe failed: HTTP probe failed with statuscode: 500"},
+				{Message: "Readiness probe failed: HTTP probe failed with statuscode: 503\n"},
+				{Message: "Pulled container image"},
+			},
+		},
+	}
+
+	readinesses := getPodReadinesses(pods, podStatuses)
+	require.Len(t, readinesses, 2)
+	assert.True(t, readinesses["vizier-cloud-connector-0"].Ready)
+	assert.False(t, readinesses["vizier-metadata-0"].Ready)
+	assert.Equal(t, "Readiness probe failed: HTTP probe failed with statuscode: 503", readinesses["vizier-metadata-0"].Message)
+}
+
+func TestCheckPodReadiness(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	state := &K8sState{
+		LastUpdated: now,
+		PodReadiness: map[string]*PodReadiness{
+			"vizier-cloud-connector-0": {Ready: true, ReadyContainers: 1, TotalContainers: 1},
+			"vizier-metadata-0": {
+				ReadyContainers: 0,
+				TotalContainers: 1,
+				NotReadySince:   now.Add(-time.Hour),
+				Message:         "Readiness probe failed: HTTP probe failed with statuscode: 503",
+			},
+			// Pods that only just became unready may still be starting up.
+			"vizier-query-broker-0": {
+				ReadyContainers: 0,
+				TotalContainers: 1,
+				NotReadySince:   now.Add(-time.Minute),
+			},
+		},
+	}
+
+	health, reasons := checkPodReadiness(state)
+	assert.Equal(t, VizierHealthDegraded, health)
+	assert.Equal(t, []string{
+		"vizier-metadata-0 not ready for 1h0m0s (0/1 containers ready): Readiness probe failed: HTTP probe failed with statuscode: 503",
+	}, reasons)
+
+	state.PodReadiness["vizier-metadata-0"].Ready = true
+	health, reasons = checkPodReadiness(state)
+	assert.Equal(t, VizierHealthHealthy, health)
+	assert.Empty(t, reasons)
+}
+
+func TestUpdateK8sState_UnreadyPod(t *testing.T) {
+	selector, err := getPodSelector("app=pl-monitoring", false)
+	require.NoError(t, err)
+	since := time.Now().Add(-time.Hour)
+	vzInfo := &K8sVizierInfo{
+		ns: testNamespace,
+		clientset: fake.NewSimpleClientset(expectedSecretObjects(
+			unreadyPod("vizier-metadata-0", since),
+			&corev1.Event{
+				ObjectMeta:     metav1.ObjectMeta{Name: "vizier-metadata-0.1", Namespace: testNamespace},
+				InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "vizier-metadata-0", Namespace: testNamespace},
+				Message:        "Readiness probe failed: HTTP probe failed with statuscode: 503",
+			},
+		)...),
+		podSelector: selector,
+	}
+
+	vzInfo.UpdateK8sState(context.Background())
+
+	state := vzInfo.GetK8sState()
+	require.Contains(t, state.ControlPlanePodStatuses, "vizier-metadata-0")
+	assert.Equal(t, "1/2 containers ready: Readiness probe failed: HTTP probe failed with statuscode: 503",
+		state.ControlPlanePodStatuses["vizier-metadata-0"].StatusMessage)
+	require.Contains(t, state.PodReadiness, "vizier-metadata-0")
+	assert.False(t, state.PodReadiness["vizier-metadata-0"].Ready)
+	assert.Equal(t, VizierHealthDegraded, state.VizierState.Health)
+	assert.Contains(t, state.VizierState.Reasons,
+		"vizier-metadata-0 not ready for 1h0m0s (1/2 containers ready): Readiness probe failed: HTTP probe failed with statuscode: 503")
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// getUnschedulableCondition returns the PodScheduled condition of the pod if the scheduler could not find a
+// node for it, or nil otherwise. The condition's message explains why, ex: "0/5 nodes are available: 5
+// Insufficient memory.".
+func getUnschedulableCondition(pod *corev1.Pod) *corev1.PodCondition {
+	if pod.Status.Phase != corev1.PodPending {
+		return nil
+	}
+	c := getPodCondition(pod, corev1.PodScheduled)
+	if c == nil || c.Status != corev1.ConditionFalse || c.Reason != corev1.PodReasonUnschedulable {
+		return nil
+	}
+	return c
+}
+
+// getUnschedulableSince returns when each of the unschedulable pods became unschedulable, keyed by pod name.
+func getUnschedulableSince(pods map[string]*corev1.Pod) map[string]time.Time {
+	since := make(map[string]time.Time)
+	for name, p := range pods {
+		if c := getUnschedulableCondition(p); c != nil {
+			since[name] = c.LastTransitionTime.Time
+		}
+	}
+	return since
+}
+
+func copyUnschedulableSince(since map[string]time.Time) map[string]time.Time {
+	if since == nil {
+		return nil
+	}
+	clone := make(map[string]time.Time, len(since))
+	for name, t := range since {
+		clone[name] = t
+	}
+	return clone
+}
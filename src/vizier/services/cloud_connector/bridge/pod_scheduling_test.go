@@ -0,0 +1,117 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"px.dev/pixie/src/shared/cvmsgspb"
+	"px.dev/pixie/src/shared/k8s/metadatapb"
+)
+
+const insufficientMemoryMessage = "0/5 nodes are available: 5 Insufficient memory."
+
+func unschedulablePod(name string, since time.Time) *corev1.Pod {
+	pod := makePod(name, map[string]string{"app": "pl-monitoring", "name": "kelvin"})
+	pod.Status.Phase = corev1.PodPending
+	pod.Status.Conditions = []corev1.PodCondition{
+		{
+			Type:               corev1.PodScheduled,
+			Status:             corev1.ConditionFalse,
+			Reason:             corev1.PodReasonUnschedulable,
+			Message:            insufficientMemoryMessage,
+			LastTransitionTime: metav1.NewTime(since),
+		},
+	}
+	return pod
+}
+
+func TestGetUnschedulableCondition(t *testing.T) {
+	since := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := getUnschedulableCondition(unschedulablePod("kelvin-0", since))
+	require.NotNil(t, c)
+	assert.Equal(t, insufficientMemoryMessage, c.Message)
+
+	// Pods pending on image pulls have been scheduled.
+	pulling := makePod("kelvin-0", nil)
+	pulling.Status.Phase = corev1.PodPending
+	pulling.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodScheduled, Status: corev1.ConditionTrue}}
+	assert.Nil(t, getUnschedulableCondition(pulling))
+
+	// Pods the scheduler has not looked at yet have no condition.
+	pending := makePod("kelvin-0", nil)
+	pending.Status.Phase = corev1.PodPending
+	assert.Nil(t, getUnschedulableCondition(pending))
+
+	assert.Equal(t, map[string]time.Time{"kelvin-0": since}, getUnschedulableSince(map[string]*corev1.Pod{
+		"kelvin-0":          unschedulablePod("kelvin-0", since),
+		"vizier-metadata-0": pulling,
+	}))
+}
+
+func TestGetPodProblem_Pending(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	state := &K8sState{
+		LastUpdated:        now,
+		UnschedulableSince: map[string]time.Time{"kelvin-0": now.Add(-12 * time.Minute)},
+	}
+
+	assert.Equal(t, "kelvin-0 Unschedulable for 12m0s: "+insufficientMemoryMessage, getPodProblem(state, &cvmsgspb.PodStatus{
+		Name:          "kelvin-0",
+		Status:        metadatapb.PENDING,
+		Reason:        corev1.PodReasonUnschedulable,
+		StatusMessage: insufficientMemoryMessage,
+	}))
+	assert.Equal(t, "vizier-pem-abcde ErrImagePull", getPodProblem(state, &cvmsgspb.PodStatus{
+		Name:   "vizier-pem-abcde",
+		Status: metadatapb.PENDING,
+		Containers: []*cvmsgspb.ContainerStatus{
+			{Name: "pem", State: metadatapb.CONTAINER_STATE_WAITING, Reason: "ErrImagePull"},
+		},
+	}))
+}
+
+func TestUpdateK8sState_UnschedulableKelvin(t *testing.T) {
+	selector, err := getPodSelector("app=pl-monitoring", false)
+	require.NoError(t, err)
+	vzInfo := &K8sVizierInfo{
+		ns:          testNamespace,
+		clientset:   fake.NewSimpleClientset(unschedulablePod("kelvin-abcde", time.Now().Add(-time.Hour))),
+		podSelector: selector,
+	}
+
+	vzInfo.UpdateK8sState(context.Background())
+
+	state := vzInfo.GetK8sState()
+	require.Contains(t, state.UnhealthyDataPlanePodStatuses, "kelvin-abcde")
+	status := state.UnhealthyDataPlanePodStatuses["kelvin-abcde"]
+	assert.Equal(t, corev1.PodReasonUnschedulable, status.Reason)
+	assert.Equal(t, insufficientMemoryMessage, status.StatusMessage)
+	assert.Contains(t, state.UnschedulableSince, "kelvin-abcde")
+	assert.Equal(t, VizierHealthUnhealthy, state.VizierState.Health)
+	assert.Contains(t, state.VizierState.Reasons, "kelvin-abcde Unschedulable for 1h0m0s: "+insufficientMemoryMessage)
+}
@@ -0,0 +1,209 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"sort"
+	"time"
+
+	"px.dev/pixie/src/shared/cvmsgspb"
+)
+
+// The number of revisions of pod status changes that are kept. Consumers that fall further behind than this
+// need a full resync.
+const podStatusRevisionHistoryLength = 64
+
+// How often the full pod statuses are resent to the cloud, even if they have not changed.
+const podStatusResyncPeriod = 10 * time.Minute
+
+// PodStatusDelta describes the changes to the pod statuses between two revisions. If Resync is set, the
+// changes since the requested revision are no longer known, and Updated holds a full snapshot instead.
+type PodStatusDelta struct {
+	FromRevision int64
+	ToRevision   int64
+	Resync       bool
+	// The pods which were added or whose status changed, keyed by pod name.
+	Updated map[string]*cvmsgspb.PodStatus
+	// The names of the pods which are no longer tracked.
+	Removed []string
+}
+
+// Empty returns whether the delta has no changes.
+func (d *PodStatusDelta) Empty() bool {
+	return !d.Resync && len(d.Updated) == 0 && len(d.Removed) == 0
+}
+
+// Apply applies the delta to the pod statuses, returning the resulting pod statuses.
+func (d *PodStatusDelta) Apply(podStatuses map[string]*cvmsgspb.PodStatus) map[string]*cvmsgspb.PodStatus {
+	result := make(map[string]*cvmsgspb.PodStatus)
+	if !d.Resync {
+		for name, s := range podStatuses {
+			result[name] = s
+		}
+	}
+	for _, name := range d.Removed {
+		delete(result, name)
+	}
+	for name, s := range d.Updated {
+		result[name] = s
+	}
+	return result
+}
+
+// podStatusRevision records the changes made to the pod statuses by a single revision.
+type podStatusRevision struct {
+	revision int64
+	updated  map[string]*cvmsgspb.PodStatus
+	removed  []string
+}
+
+// podStatusRevisions tracks a bounded history of the revisions to the pod statuses.
+type podStatusRevisions struct {
+	current   int64
+	revisions []*podStatusRevision
+}
+
+// update records a new revision if the pod statuses changed, and returns the current revision.
+func (r *podStatusRevisions) update(oldStatuses, newStatuses map[string]*cvmsgspb.PodStatus) int64 {
+	rev := &podStatusRevision{updated: make(map[string]*cvmsgspb.PodStatus)}
+	for name, s := range newStatuses {
+		if old, ok := oldStatuses[name]; !ok || !old.Equal(s) {
+			rev.updated[name] = s
+		}
+	}
+	for name := range oldStatuses {
+		if _, ok := newStatuses[name]; !ok {
+			rev.removed = append(rev.removed, name)
+		}
+	}
+	if len(rev.updated) == 0 && len(rev.removed) == 0 {
+		return r.current
+	}
+	sort.Strings(rev.removed)
+
+	r.current++
+	rev.revision = r.current
+	r.revisions = append(r.revisions, rev)
+	if len(r.revisions) > podStatusRevisionHistoryLength {
+		r.revisions = r.revisions[len(r.revisions)-podStatusRevisionHistoryLength:]
+	}
+	return r.current
+}
+
+// changesSince returns the changes made after the given revision. If those changes are no longer known, the
+// delta is a resync holding the given snapshot of the current pod statuses.
+func (r *podStatusRevisions) changesSince(revision int64, snapshot map[string]*cvmsgspb.PodStatus) *PodStatusDelta {
+	delta := &PodStatusDelta{
+		FromRevision: revision,
+		ToRevision:   r.current,
+		Updated:      make(map[string]*cvmsgspb.PodStatus),
+	}
+
+	oldest := r.current
+	if len(r.revisions) > 0 {
+		oldest = r.revisions[0].revision - 1
+	}
+	if revision < oldest || revision > r.current {
+		delta.Resync = true
+		for name, s := range snapshot {
+			delta.Updated[name] = s
+		}
+		return delta
+	}
+
+	removed := make(map[string]bool)
+	for _, rev := range r.revisions {
+		if rev.revision <= revision {
+			continue
+		}
+		for _, name := range rev.removed {
+			delete(delta.Updated, name)
+			removed[name] = true
+		}
+		for name, s := range rev.updated {
+			delta.Updated[name] = s
+			delete(removed, name)
+		}
+	}
+	for name := range removed {
+		delta.Removed = append(delta.Removed, name)
+	}
+	sort.Strings(delta.Removed)
+	return delta
+}
+
+// GetPodStatusChangesSince returns the changes to the control plane pod statuses made after the given
+// revision. These are the pod statuses that are sent to the cloud in the heartbeat. Consumers should start from
+// revision 0, which always results in a resync, and then pass the ToRevision of the last delta they applied.
+func (v *K8sVizierInfo) GetPodStatusChangesSince(revision int64) *PodStatusDelta {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if revision == 0 {
+		// Revision 0 is before anything was collected, so there is nothing to diff against.
+		revision = -1
+	}
+	delta := v.podStatusRevisions.changesSince(revision, v.controlPlanePodStatuses)
+	if revision == -1 {
+		delta.FromRevision = 0
+	}
+	return delta
+}
+
+// podStatusSync tracks the revision of the pod statuses that were last sent to the cloud, so that only the
+// changes since then are sent. The full pod statuses are resent every resync period, and whenever the cloud
+// asks for them because it could not apply a delta. A new podStatusSync is created for each stream to the
+// cloud, so the full pod statuses are always sent after reconnecting.
+type podStatusSync struct {
+	lastRevision int64
+	lastResync   time.Time
+}
+
+// changesToSend returns the changes to the pod statuses to send to the cloud, or nil if there are none. The
+// changes are fetched with getChanges. If resync is set, the full pod statuses are returned.
+func (p *podStatusSync) changesToSend(getChanges func(int64) *PodStatusDelta, resync bool, now time.Time) *PodStatusDelta {
+	revision := p.lastRevision
+	if resync || p.lastResync.IsZero() || now.Sub(p.lastResync) >= podStatusResyncPeriod {
+		revision = 0
+	}
+	delta := getChanges(revision)
+	if delta.Empty() {
+		return nil
+	}
+	return delta
+}
+
+// sent records that the given changes were sent to the cloud.
+func (p *podStatusSync) sent(delta *PodStatusDelta, now time.Time) {
+	p.lastRevision = delta.ToRevision
+	if delta.Resync {
+		p.lastResync = now
+	}
+}
+
+// setPodStatuses sets the changes to the pod statuses in the heartbeat. A resync is sent as a full snapshot,
+// and any other changes as a delta on top of the revision the cloud last received.
+func setPodStatuses(hb *cvmsgspb.VizierHeartbeat, delta *PodStatusDelta) {
+	hb.PodStatuses = delta.Updated
+	hb.PodStatusesRevision = delta.ToRevision
+	if !delta.Resync {
+		hb.PodStatusesBaseRevision = delta.FromRevision
+		hb.RemovedPodStatuses = delta.Removed
+	}
+}
@@ -0,0 +1,260 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/shared/cvmsgspb"
+	"px.dev/pixie/src/shared/k8s/metadatapb"
+)
+
+func TestPodStatusRevisions_DeltasReconstructSnapshot(t *testing.T) {
+	snapshots := []map[string]*cvmsgspb.PodStatus{
+		{
+			"vizier-metadata-0": pendingPod("vizier-metadata-0"),
+			"kelvin-0":          pendingPod("kelvin-0"),
+		},
+		{
+			"vizier-metadata-0": runningPod("vizier-metadata-0"),
+			"kelvin-0":          pendingPod("kelvin-0"),
+		},
+		{
+			"vizier-metadata-0": runningPod("vizier-metadata-0"),
+		},
+		{
+			"vizier-metadata-0": runningPod("vizier-metadata-0"),
+			"kelvin-0":          runningPod("kelvin-0"),
+			"vizier-pem-abcde":  {Name: "vizier-pem-abcde", Status: metadatapb.FAILED, Reason: "CrashLoopBackOff"},
+		},
+	}
+
+	var revisions podStatusRevisions
+	var old map[string]*cvmsgspb.PodStatus
+	var applied map[string]*cvmsgspb.PodStatus
+	var revision int64 = -1
+	for _, snapshot := range snapshots {
+		revisions.update(old, snapshot)
+		old = snapshot
+
+		delta := revisions.changesSince(revision, snapshot)
+		applied = delta.Apply(applied)
+		revision = delta.ToRevision
+		assert.Equal(t, snapshot, applied)
+	}
+	assert.Equal(t, int64(len(snapshots)), revision)
+
+	// Deltas spanning several revisions reconstruct the snapshot too.
+	delta := revisions.changesSince(1, old)
+	assert.False(t, delta.Resync)
+	assert.Equal(t, old, delta.Apply(snapshots[0]))
+}
+
+func TestPodStatusRevisions_Unchanged(t *testing.T) {
+	statuses := map[string]*cvmsgspb.PodStatus{
+		"vizier-metadata-0": runningPod("vizier-metadata-0"),
+	}
+
+	var revisions podStatusRevisions
+	assert.Equal(t, int64(1), revisions.update(nil, statuses))
+	assert.Equal(t, int64(1), revisions.update(statuses, map[string]*cvmsgspb.PodStatus{
+		"vizier-metadata-0": runningPod("vizier-metadata-0"),
+	}))
+
+	delta := revisions.changesSince(1, statuses)
+	assert.True(t, delta.Empty())
+	assert.Equal(t, int64(1), delta.ToRevision)
+}
+
+func TestPodStatusRevisions_Resync(t *testing.T) {
+	var revisions podStatusRevisions
+	var old map[string]*cvmsgspb.PodStatus
+	for i := 0; i < podStatusRevisionHistoryLength+2; i++ {
+		pod := pendingPod("kelvin-0")
+		if i%2 == 1 {
+			pod = runningPod("kelvin-0")
+		}
+		statuses := map[string]*cvmsgspb.PodStatus{"kelvin-0": pod}
+		revisions.update(old, statuses)
+		old = statuses
+	}
+
+	// The changes after revision 1 have been dropped from the history.
+	delta := revisions.changesSince(1, old)
+	assert.True(t, delta.Resync)
+	assert.Equal(t, old, delta.Updated)
+	assert.Equal(t, old, delta.Apply(map[string]*cvmsgspb.PodStatus{
+		"vizier-metadata-0": runningPod("vizier-metadata-0"),
+	}))
+
+	// Revisions from the future, e.g. from before a restart, also need a resync.
+	delta = revisions.changesSince(revisions.current+1, old)
+	assert.True(t, delta.Resync)
+
+	delta = revisions.changesSince(revisions.current-1, old)
+	assert.False(t, delta.Resync)
+	assert.Equal(t, old, delta.Updated)
+}
+
+func TestGetPodStatusChangesSince(t *testing.T) {
+	vzInfo := &K8sVizierInfo{
+		controlPlanePodStatuses: map[string]*cvmsgspb.PodStatus{
+			"vizier-metadata-0": runningPod("vizier-metadata-0"),
+		},
+		unhealthyDataPlanePodStatuses: map[string]*cvmsgspb.PodStatus{
+			"vizier-pem-abcde": pendingPod("vizier-pem-abcde"),
+		},
+	}
+	vzInfo.podStatusRevisions.update(nil, vzInfo.controlPlanePodStatuses)
+
+	// Only the control plane pod statuses are tracked, since those are the ones sent to the cloud.
+	delta := vzInfo.GetPodStatusChangesSince(0)
+	require.True(t, delta.Resync)
+	assert.Equal(t, int64(0), delta.FromRevision)
+	assert.Equal(t, int64(1), delta.ToRevision)
+	assert.Equal(t, vzInfo.controlPlanePodStatuses, delta.Updated)
+
+	assert.True(t, vzInfo.GetPodStatusChangesSince(delta.ToRevision).Empty())
+}
+
+func TestPodStatusSync(t *testing.T) {
+	now := time.Now()
+	var revisions podStatusRevisions
+	statuses := map[string]*cvmsgspb.PodStatus{
+		"vizier-metadata-0": runningPod("vizier-metadata-0"),
+	}
+	revisions.update(nil, statuses)
+	getChanges := func(revision int64) *PodStatusDelta {
+		if revision == 0 {
+			revision = -1
+		}
+		return revisions.changesSince(revision, statuses)
+	}
+
+	// The first send is always a resync.
+	var sync podStatusSync
+	delta := sync.changesToSend(getChanges, false, now)
+	require.NotNil(t, delta)
+	assert.True(t, delta.Resync)
+	sync.sent(delta, now)
+
+	// Nothing is sent until the pod statuses change.
+	assert.Nil(t, sync.changesToSend(getChanges, false, now.Add(time.Minute)))
+
+	newStatuses := map[string]*cvmsgspb.PodStatus{
+		"kelvin-0": pendingPod("kelvin-0"),
+	}
+	revisions.update(statuses, newStatuses)
+	statuses = newStatuses
+	delta = sync.changesToSend(getChanges, false, now.Add(time.Minute))
+	require.NotNil(t, delta)
+	assert.False(t, delta.Resync)
+	assert.Equal(t, int64(1), delta.FromRevision)
+	assert.Equal(t, []string{"vizier-metadata-0"}, delta.Removed)
+	sync.sent(delta, now.Add(time.Minute))
+
+	// The full pod statuses are resent when the cloud asks for them, and every resync period.
+	delta = sync.changesToSend(getChanges, true, now.Add(2*time.Minute))
+	require.NotNil(t, delta)
+	assert.True(t, delta.Resync)
+	delta = sync.changesToSend(getChanges, false, now.Add(podStatusResyncPeriod))
+	require.NotNil(t, delta)
+	assert.True(t, delta.Resync)
+}
+
+// fakeCloudPodStatuses applies the pod statuses in the heartbeats the way the cloud does.
+type fakeCloudPodStatuses struct {
+	statuses map[string]*cvmsgspb.PodStatus
+	revision int64
+}
+
+// apply applies the pod statuses in the heartbeat, and returns whether the cloud needs a resync.
+func (c *fakeCloudPodStatuses) apply(hb *cvmsgspb.VizierHeartbeat) bool {
+	if hb.PodStatusesBaseRevision == 0 {
+		if hb.PodStatuses != nil || hb.PodStatusesRevision != 0 {
+			c.statuses = hb.PodStatuses
+			c.revision = hb.PodStatusesRevision
+		}
+		return false
+	}
+	if hb.PodStatusesBaseRevision != c.revision {
+		return true
+	}
+	delta := &PodStatusDelta{Updated: hb.PodStatuses, Removed: hb.RemovedPodStatuses}
+	c.statuses = delta.Apply(c.statuses)
+	c.revision = hb.PodStatusesRevision
+	return false
+}
+
+func TestPodStatusSync_HeartbeatsReconstructSnapshot(t *testing.T) {
+	snapshots := []map[string]*cvmsgspb.PodStatus{
+		{
+			"vizier-metadata-0":        pendingPod("vizier-metadata-0"),
+			"vizier-cloud-connector-0": runningPod("vizier-cloud-connector-0"),
+		},
+		{
+			"vizier-metadata-0":        runningPod("vizier-metadata-0"),
+			"vizier-cloud-connector-0": runningPod("vizier-cloud-connector-0"),
+		},
+		{
+			"vizier-metadata-0":        runningPod("vizier-metadata-0"),
+			"vizier-cloud-connector-0": runningPod("vizier-cloud-connector-0"),
+		},
+		{
+			"vizier-metadata-0":     runningPod("vizier-metadata-0"),
+			"vizier-query-broker-0": {Name: "vizier-query-broker-0", Status: metadatapb.FAILED, Reason: "CrashLoopBackOff"},
+		},
+		{
+			"vizier-metadata-0": runningPod("vizier-metadata-0"),
+		},
+	}
+
+	vzInfo := &K8sVizierInfo{}
+	var sync podStatusSync
+	var cloud fakeCloudPodStatuses
+	now := time.Now()
+	for i, snapshot := range snapshots {
+		vzInfo.podStatusRevisions.update(vzInfo.controlPlanePodStatuses, snapshot)
+		vzInfo.controlPlanePodStatuses = snapshot
+		if i == 3 {
+			// The cloud loses its state, so it can't apply the next delta.
+			cloud = fakeCloudPodStatuses{}
+		}
+
+		resync := false
+		for attempt := 0; attempt < 2; attempt++ {
+			hb := &cvmsgspb.VizierHeartbeat{}
+			if delta := sync.changesToSend(vzInfo.GetPodStatusChangesSince, resync, now); delta != nil {
+				setPodStatuses(hb, delta)
+				sync.sent(delta, now)
+			}
+			resync = cloud.apply(hb)
+			if !resync {
+				break
+			}
+		}
+		require.False(t, resync, "the cloud still needs a resync after one was sent")
+		assert.Equal(t, snapshot, cloud.statuses)
+		now = now.Add(time.Minute)
+	}
+}
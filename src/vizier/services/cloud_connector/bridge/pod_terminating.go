@@ -0,0 +1,64 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// podReasonTerminating is the reason reported for a pod that is shutting down within its grace period. Such
+	// pods still report the Running phase, but are expected to go away, so they are not a problem.
+	podReasonTerminating = "Terminating"
+	// podReasonStuckTerminating is the reason reported for a pod that is still around well after its grace
+	// period ended. This is usually a finalizer that is never removed, or a node that is unreachable.
+	podReasonStuckTerminating = "StuckTerminating"
+	// How long after the end of its grace period a pod may take to go away, before it is stuck. The kubelet
+	// needs some time to report that the containers were killed.
+	stuckTerminatingMargin = 2 * time.Minute
+)
+
+// isPodStuckTerminating returns whether the pod is still around well after the end of its grace period.
+func isPodStuckTerminating(pod *corev1.Pod, now time.Time) bool {
+	// The deletion timestamp is set to the end of the grace period when the pod is deleted.
+	return pod.DeletionTimestamp != nil && now.After(pod.DeletionTimestamp.Add(stuckTerminatingMargin))
+}
+
+// getTerminatingStatus returns the reason and message to report for a pod that is being deleted, or empty
+// strings if it is not.
+func getTerminatingStatus(pod *corev1.Pod, now time.Time) (string, string) {
+	if pod.DeletionTimestamp == nil {
+		return "", ""
+	}
+	deadline := pod.DeletionTimestamp.UTC().Format(time.RFC3339)
+	if !isPodStuckTerminating(pod, now) {
+		return podReasonTerminating, fmt.Sprintf("grace period ends at %s", deadline)
+	}
+	msg := fmt.Sprintf("grace period ended at %s", deadline)
+	if len(pod.Finalizers) > 0 {
+		msg = fmt.Sprintf("%s, waiting on finalizers %s", msg, strings.Join(pod.Finalizers, ", "))
+	} else if pod.Spec.NodeName != "" {
+		msg = fmt.Sprintf("%s, node %s may be unreachable", msg, pod.Spec.NodeName)
+	}
+	return podReasonStuckTerminating, msg
+}
@@ -0,0 +1,160 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// terminatingPod is a pod that was deleted, with a grace period that ends at the given time.
+func terminatingPod(pod *corev1.Pod, gracePeriodEnd time.Time) *corev1.Pod {
+	deletionTimestamp := metav1.NewTime(gracePeriodEnd)
+	pod.DeletionTimestamp = &deletionTimestamp
+	return pod
+}
+
+func TestGetTerminatingStatus(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		pod        *corev1.Pod
+		wantReason string
+		wantMsg    string
+	}{
+		{
+			name: "not deleted",
+			pod:  makePod("vizier-metadata-0", nil),
+		},
+		{
+			name:       "within the grace period",
+			pod:        terminatingPod(makePod("vizier-metadata-0", nil), now.Add(10*time.Second)),
+			wantReason: podReasonTerminating,
+			wantMsg:    "grace period ends at 2024-01-02T03:04:10Z",
+		},
+		{
+			name:       "within the margin after the grace period",
+			pod:        terminatingPod(makePod("vizier-metadata-0", nil), now.Add(-time.Minute)),
+			wantReason: podReasonTerminating,
+			wantMsg:    "grace period ends at 2024-01-02T03:03:00Z",
+		},
+		{
+			name: "stuck on a finalizer",
+			pod: func() *corev1.Pod {
+				pod := terminatingPod(makePod("vizier-metadata-0", nil), now.Add(-time.Hour))
+				pod.Finalizers = []string{"example.com/cleanup"}
+				pod.Spec.NodeName = "node-0"
+				return pod
+			}(),
+			wantReason: podReasonStuckTerminating,
+			wantMsg:    "grace period ended at 2024-01-02T02:04:00Z, waiting on finalizers example.com/cleanup",
+		},
+		{
+			name: "stuck on its node",
+			pod: func() *corev1.Pod {
+				pod := terminatingPod(makePod("vizier-metadata-0", nil), now.Add(-time.Hour))
+				pod.Spec.NodeName = "node-0"
+				return pod
+			}(),
+			wantReason: podReasonStuckTerminating,
+			wantMsg:    "grace period ended at 2024-01-02T02:04:00Z, node node-0 may be unreachable",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reason, msg := getTerminatingStatus(test.pod, now)
+			assert.Equal(t, test.wantReason, reason)
+			assert.Equal(t, test.wantMsg, msg)
+		})
+	}
+}
+
+// rollingUpdateObjects are the objects of a Vizier partway through a rolling update, where the old pods
+// are terminating within their grace period, alongside their replacements.
+func rollingUpdateObjects(gracePeriodEnd time.Time) []runtime.Object {
+	oldMetadata := terminatingPod(unreadyPod("vizier-metadata-0", gracePeriodEnd.Add(-time.Hour)), gracePeriodEnd)
+	oldKelvin := terminatingPod(makePod("kelvin-5f7b6d4c9-old", map[string]string{"name": "kelvin"}), gracePeriodEnd)
+	newKelvin := makePod("kelvin-7c9d8b6f5-new", map[string]string{"name": "kelvin"})
+	var objs []runtime.Object
+	objs = append(objs, oldMetadata, oldKelvin, newKelvin)
+	for _, node := range []string{"node-0", "node-1"} {
+		objs = append(objs, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: node}})
+	}
+	// node-0 runs both its old PEM and the replacement.
+	oldPEM := terminatingPod(makePod("vizier-pem-old0", map[string]string{"name": "vizier-pem"}), gracePeriodEnd)
+	oldPEM.Spec.NodeName = "node-0"
+	newPEM0 := makePod("vizier-pem-new0", map[string]string{"name": "vizier-pem"})
+	newPEM0.Spec.NodeName = "node-0"
+	newPEM1 := makePod("vizier-pem-new1", map[string]string{"name": "vizier-pem"})
+	newPEM1.Spec.NodeName = "node-1"
+	return append(objs, oldPEM, newPEM0, newPEM1)
+}
+
+func TestUpdateK8sState_RollingUpdate(t *testing.T) {
+	gracePeriodEnd := time.Now().Add(30 * time.Second)
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: fake.NewSimpleClientset(expectedSecretObjects(rollingUpdateObjects(gracePeriodEnd)...)...),
+	}
+
+	vzInfo.UpdateK8sState(context.Background())
+
+	state := vzInfo.GetK8sState()
+	require.Contains(t, state.ControlPlanePodStatuses, "vizier-metadata-0")
+	metadata := state.ControlPlanePodStatuses["vizier-metadata-0"]
+	assert.Equal(t, podReasonTerminating, metadata.Reason)
+	assert.Equal(t, "grace period ends at "+gracePeriodEnd.UTC().Format(time.RFC3339), metadata.StatusMessage)
+	// The old pods are expected to go away, so they neither count twice nor make the Vizier unhealthy.
+	assert.Empty(t, state.UnhealthyDataPlanePodStatuses)
+	assert.NotContains(t, state.PodReadiness, "vizier-metadata-0")
+	assert.Equal(t, int32(2), state.NumNodes)
+	assert.Equal(t, int32(2), state.NumInstrumentedNodes)
+	assert.Equal(t, VizierHealthHealthy, state.VizierState.Health, state.VizierState.Reasons)
+}
+
+func TestUpdateK8sState_StuckTerminating(t *testing.T) {
+	gracePeriodEnd := time.Now().Add(-time.Hour)
+	objs := rollingUpdateObjects(gracePeriodEnd)
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: fake.NewSimpleClientset(expectedSecretObjects(objs...)...),
+	}
+
+	vzInfo.UpdateK8sState(context.Background())
+
+	state := vzInfo.GetK8sState()
+	require.Contains(t, state.UnhealthyDataPlanePodStatuses, "kelvin-5f7b6d4c9-old")
+	assert.Equal(t, podReasonStuckTerminating, state.UnhealthyDataPlanePodStatuses["kelvin-5f7b6d4c9-old"].Reason)
+	assert.Equal(t, podReasonStuckTerminating, state.ControlPlanePodStatuses["vizier-metadata-0"].Reason)
+	// A PEM stuck terminating doesn't instrument its node, but the replacement does.
+	assert.Equal(t, int32(2), state.NumInstrumentedNodes)
+	assert.Equal(t, VizierHealthUnhealthy, state.VizierState.Health)
+	deadline := gracePeriodEnd.UTC().Format(time.RFC3339)
+	assert.Contains(t, state.VizierState.Reasons, "kelvin-5f7b6d4c9-old StuckTerminating: grace period ended at "+deadline)
+}
@@ -0,0 +1,177 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// The default for how long container terminations are reported after the container finished, so that
+// containers which are killed and restart between updates are still noticed.
+const defaultContainerTerminationRetention = time.Hour
+
+// The termination reason K8s reports when a container is killed for exceeding its memory limit.
+const oomKilledReason = "OOMKilled"
+
+// ContainerTermination describes the last abnormal termination of a container in a Vizier pod.
+type ContainerTermination struct {
+	// The key of the pod in the pod statuses.
+	Pod       string
+	Container string
+	// Ex: "OOMKilled", "Error".
+	Reason     string
+	ExitCode   int32
+	FinishedAt time.Time
+	// The memory limit of the container. Ex: "512Mi". Empty if the container has no memory limit.
+	MemoryLimit string
+}
+
+// Description returns a short description of the termination. Ex: "OOMKilled (exit code 137)".
+func (t *ContainerTermination) Description() string {
+	return fmt.Sprintf("%s (exit code %d)", t.Reason, t.ExitCode)
+}
+
+// isAbnormalTermination returns whether the container was killed for running out of memory, or exited with an
+// error.
+func isAbnormalTermination(t *corev1.ContainerStateTerminated) bool {
+	if t == nil {
+		return false
+	}
+	return t.Reason == oomKilledReason || (t.Reason == "Error" && t.ExitCode != 0)
+}
+
+// getContainerMemoryLimit returns the memory limit of the named container, or an empty string if it has none.
+func getContainerMemoryLimit(pod *corev1.Pod, container string) string {
+	for _, c := range pod.Spec.Containers {
+		if c.Name != container {
+			continue
+		}
+		if limit, ok := c.Resources.Limits[corev1.ResourceMemory]; ok {
+			return limit.String()
+		}
+	}
+	return ""
+}
+
+// getPodTerminations returns the last abnormal termination of each of the pod's containers, if any.
+func getPodTerminations(key string, pod *corev1.Pod) []*ContainerTermination {
+	var terminations []*ContainerTermination
+	for _, c := range pod.Status.ContainerStatuses {
+		t := c.LastTerminationState.Terminated
+		if !isAbnormalTermination(t) {
+			continue
+		}
+		terminations = append(terminations, &ContainerTermination{
+			Pod:         key,
+			Container:   c.Name,
+			Reason:      t.Reason,
+			ExitCode:    t.ExitCode,
+			FinishedAt:  t.FinishedAt.Time,
+			MemoryLimit: getContainerMemoryLimit(pod, c.Name),
+		})
+	}
+	return terminations
+}
+
+// getLatestTermination returns the most recent abnormal termination of the pod's containers that finished
+// within the retention window, or nil if there is none.
+func getLatestTermination(key string, pod *corev1.Pod, now time.Time, retention time.Duration) *ContainerTermination {
+	var latest *ContainerTermination
+	for _, t := range getPodTerminations(key, pod) {
+		if now.Sub(t.FinishedAt) >= retention {
+			continue
+		}
+		if latest == nil || t.FinishedAt.After(latest.FinishedAt) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+func containerTerminationKey(t *ContainerTermination) string {
+	return fmt.Sprintf("%s/%s", t.Pod, t.Container)
+}
+
+// mergeContainerTerminations adds the terminations of the listed pods to those that were already retained,
+// and drops any that finished outside of the retention window. Terminations are retained even after their
+// pod is gone, since the pod may have been replaced because of them.
+func mergeContainerTerminations(retained map[string]*ContainerTermination, pods map[string]*corev1.Pod, now time.Time, retention time.Duration) map[string]*ContainerTermination {
+	merged := make(map[string]*ContainerTermination)
+	for key, t := range retained {
+		if now.Sub(t.FinishedAt) < retention {
+			merged[key] = t
+		}
+	}
+	for name, p := range pods {
+		for _, t := range getPodTerminations(name, p) {
+			if now.Sub(t.FinishedAt) < retention {
+				merged[containerTerminationKey(t)] = t
+			}
+		}
+	}
+	return merged
+}
+
+// sortedContainerTerminations returns copies of the terminations, sorted by pod and container.
+func sortedContainerTerminations(terminations map[string]*ContainerTermination) []*ContainerTermination {
+	keys := make([]string, 0, len(terminations))
+	for key := range terminations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	sorted := make([]*ContainerTermination, 0, len(keys))
+	for _, key := range keys {
+		c := *terminations[key]
+		sorted = append(sorted, &c)
+	}
+	return sorted
+}
+
+// getContainerTerminationRetention returns how long container terminations are reported for.
+func (v *K8sVizierInfo) getContainerTerminationRetention() time.Duration {
+	if v.containerTerminationRetention <= 0 {
+		return defaultContainerTerminationRetention
+	}
+	return v.containerTerminationRetention
+}
+
+// Containers that were recently killed for running out of memory degrade the Vizier, since their data is lost
+// when they restart. The memory limit is included, since raising it is usually the fix.
+func checkRecentOOMKills(s *K8sState) (VizierHealth, []string) {
+	var reasons []string
+	for _, t := range s.ContainerTerminations {
+		if t.Reason != oomKilledReason {
+			continue
+		}
+		limit := "no memory limit"
+		if t.MemoryLimit != "" {
+			limit = fmt.Sprintf("memory limit %s", t.MemoryLimit)
+		}
+		reasons = append(reasons, fmt.Sprintf("%s container %s OOMKilled %s ago (%s)", t.Pod, t.Container, s.LastUpdated.Sub(t.FinishedAt).Round(time.Minute), limit))
+	}
+	if len(reasons) == 0 {
+		return VizierHealthHealthy, nil
+	}
+	return VizierHealthDegraded, reasons
+}
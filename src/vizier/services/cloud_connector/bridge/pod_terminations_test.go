@@ -0,0 +1,185 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// oomKilledPod returns a running control plane pod whose app container was OOMKilled at the given time, and
+// has since restarted.
+func oomKilledPod(name string, finishedAt time.Time) *corev1.Pod {
+	pod := makePod(name, map[string]string{"app": "pl-monitoring", "plane": "control"})
+	pod.Spec.Containers = []corev1.Container{
+		{
+			Name: "app",
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+			},
+		},
+		{Name: "proxy"},
+	}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{
+			Name:         "app",
+			Ready:        true,
+			RestartCount: 1,
+			State:        corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			LastTerminationState: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{Reason: oomKilledReason, ExitCode: 137, FinishedAt: metav1.NewTime(finishedAt)},
+			},
+		},
+		{
+			Name:  "proxy",
+			Ready: true,
+			State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			// Containers that completed normally are not reported.
+			LastTerminationState: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{Reason: "Completed", FinishedAt: metav1.NewTime(finishedAt)},
+			},
+		},
+	}
+	return pod
+}
+
+func TestGetPodTerminations(t *testing.T) {
+	finishedAt := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	pod := oomKilledPod("vizier-metadata-0", finishedAt)
+	pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses,
+		corev1.ContainerStatus{
+			Name: "sidecar",
+			LastTerminationState: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 1, FinishedAt: metav1.NewTime(finishedAt)},
+			},
+		},
+		corev1.ContainerStatus{
+			Name: "exited",
+			LastTerminationState: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 0, FinishedAt: metav1.NewTime(finishedAt)},
+			},
+		},
+	)
+
+	assert.Equal(t, []*ContainerTermination{
+		{
+			Pod:         "vizier-metadata-0",
+			Container:   "app",
+			Reason:      oomKilledReason,
+			ExitCode:    137,
+			FinishedAt:  finishedAt,
+			MemoryLimit: "512Mi",
+		},
+		{
+			Pod:        "vizier-metadata-0",
+			Container:  "sidecar",
+			Reason:     "Error",
+			ExitCode:   1,
+			FinishedAt: finishedAt,
+		},
+	}, getPodTerminations("vizier-metadata-0", pod))
+}
+
+func TestMergeContainerTerminations(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	retained := map[string]*ContainerTermination{
+		// The pod has been replaced, but its termination is still reported.
+		"vizier-pem-abcde/pem": {Pod: "vizier-pem-abcde", Container: "pem", Reason: oomKilledReason, FinishedAt: now.Add(-30 * time.Minute)},
+		"vizier-pem-fghij/pem": {Pod: "vizier-pem-fghij", Container: "pem", Reason: oomKilledReason, FinishedAt: now.Add(-2 * time.Hour)},
+	}
+	pods := map[string]*corev1.Pod{
+		"vizier-metadata-0": oomKilledPod("vizier-metadata-0", now.Add(-time.Minute)),
+		"vizier-metadata-1": oomKilledPod("vizier-metadata-1", now.Add(-3*time.Hour)),
+	}
+
+	merged := mergeContainerTerminations(retained, pods, now, time.Hour)
+	sorted := sortedContainerTerminations(merged)
+	require.Len(t, sorted, 2)
+	assert.Equal(t, "vizier-metadata-0", sorted[0].Pod)
+	assert.Equal(t, "app", sorted[0].Container)
+	assert.Equal(t, "vizier-pem-abcde", sorted[1].Pod)
+
+	// The sorted terminations are copies.
+	sorted[0].Reason = "Error"
+	assert.Equal(t, oomKilledReason, merged["vizier-metadata-0/app"].Reason)
+}
+
+func TestCheckRecentOOMKills(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	state := &K8sState{
+		LastUpdated: now,
+		ContainerTerminations: []*ContainerTermination{
+			{Pod: "kelvin-5f7b6d4c9-abcde", Container: "app", Reason: "Error", ExitCode: 1, FinishedAt: now.Add(-time.Minute)},
+			{Pod: "vizier-metadata-0", Container: "app", Reason: oomKilledReason, ExitCode: 137, FinishedAt: now.Add(-5 * time.Minute), MemoryLimit: "512Mi"},
+			{Pod: "vizier-pem-abcde", Container: "pem", Reason: oomKilledReason, ExitCode: 137, FinishedAt: now.Add(-20 * time.Minute)},
+		},
+	}
+
+	health, reasons := checkRecentOOMKills(state)
+	assert.Equal(t, VizierHealthDegraded, health)
+	assert.Equal(t, []string{
+		"vizier-metadata-0 container app OOMKilled 5m0s ago (memory limit 512Mi)",
+		"vizier-pem-abcde container pem OOMKilled 20m0s ago (no memory limit)",
+	}, reasons)
+
+	state.ContainerTerminations = state.ContainerTerminations[:1]
+	health, reasons = checkRecentOOMKills(state)
+	assert.Equal(t, VizierHealthHealthy, health)
+	assert.Empty(t, reasons)
+}
+
+func TestUpdateK8sState_OOMKilledPod(t *testing.T) {
+	selector, err := getPodSelector("app=pl-monitoring", false)
+	require.NoError(t, err)
+	finishedAt := time.Now().Add(-10 * time.Minute).Truncate(time.Second)
+	clientset := fake.NewSimpleClientset(expectedSecretObjects(oomKilledPod("vizier-metadata-0", finishedAt))...)
+	vzInfo := &K8sVizierInfo{
+		ns:          testNamespace,
+		clientset:   clientset,
+		podSelector: selector,
+	}
+
+	vzInfo.UpdateK8sState(context.Background())
+
+	state := vzInfo.GetK8sState()
+	require.Contains(t, state.ControlPlanePodStatuses, "vizier-metadata-0")
+	assert.Equal(t, "container app last terminated: OOMKilled (exit code 137) at "+finishedAt.UTC().Format(time.RFC3339),
+		state.ControlPlanePodStatuses["vizier-metadata-0"].StatusMessage)
+	require.Len(t, state.ContainerTerminations, 1)
+	assert.Equal(t, "512Mi", state.ContainerTerminations[0].MemoryLimit)
+	assert.Equal(t, VizierHealthDegraded, state.VizierState.Health)
+	assert.Contains(t, state.VizierState.Reasons, "vizier-metadata-0 container app OOMKilled 10m0s ago (memory limit 512Mi)")
+
+	// The termination is still reported after the pod is replaced.
+	require.NoError(t, clientset.CoreV1().Pods(testNamespace).Delete(context.Background(), "vizier-metadata-0", metav1.DeleteOptions{}))
+	vzInfo.UpdateK8sState(context.Background())
+
+	state = vzInfo.GetK8sState()
+	assert.NotContains(t, state.ControlPlanePodStatuses, "vizier-metadata-0")
+	require.Len(t, state.ContainerTerminations, 1)
+	assert.Equal(t, VizierHealthDegraded, state.VizierState.Health)
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Components that restarted more recently than this are reported, even if they are running again.
+const recentRestartWindow = 10 * time.Minute
+
+// PodUptime describes when a pod and its containers started. All of the timestamps are taken from the pod as
+// reported by the API server, and are left unset if the pod does not report them.
+type PodUptime struct {
+	CreatedAt time.Time
+	// When the kubelet started the pod.
+	StartedAt time.Time
+	// When the most recently restarted container started running again. Unset if no container has restarted.
+	LastRestartedAt time.Time
+	RestartCount    int32
+}
+
+// Uptime returns how long the pod has been up at the given time, or 0 if it has not started.
+func (u *PodUptime) Uptime(now time.Time) time.Duration {
+	if u.StartedAt.IsZero() || now.Before(u.StartedAt) {
+		return 0
+	}
+	return now.Sub(u.StartedAt)
+}
+
+// getPodUptime returns the start times of the pod and its containers.
+func getPodUptime(pod *corev1.Pod) *PodUptime {
+	u := &PodUptime{
+		CreatedAt: pod.CreationTimestamp.Time,
+	}
+	if pod.Status.StartTime != nil {
+		u.StartedAt = pod.Status.StartTime.Time
+	}
+	for _, c := range pod.Status.ContainerStatuses {
+		u.RestartCount += c.RestartCount
+		if c.RestartCount == 0 {
+			continue
+		}
+		// A container that is not running now last restarted when its previous run finished.
+		restartedAt := time.Time{}
+		if c.State.Running != nil {
+			restartedAt = c.State.Running.StartedAt.Time
+		} else if c.LastTerminationState.Terminated != nil {
+			restartedAt = c.LastTerminationState.Terminated.FinishedAt.Time
+		}
+		if restartedAt.After(u.LastRestartedAt) {
+			u.LastRestartedAt = restartedAt
+		}
+	}
+	return u
+}
+
+// getPodUptimes returns the start times of each of the pods, keyed by pod name.
+func getPodUptimes(pods map[string]*corev1.Pod) map[string]*PodUptime {
+	uptimes := make(map[string]*PodUptime, len(pods))
+	for name, p := range pods {
+		uptimes[name] = getPodUptime(p)
+	}
+	return uptimes
+}
+
+func copyPodUptimes(uptimes map[string]*PodUptime) map[string]*PodUptime {
+	if uptimes == nil {
+		return nil
+	}
+	clone := make(map[string]*PodUptime, len(uptimes))
+	for name, u := range uptimes {
+		c := *u
+		clone[name] = &c
+	}
+	return clone
+}
+
+// Components that restarted recently are reported, since they may be crash looping between updates.
+func checkRecentRestarts(s *K8sState) (VizierHealth, []string) {
+	names := make([]string, 0, len(s.PodUptimes))
+	for name := range s.PodUptimes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var reasons []string
+	for _, name := range names {
+		u := s.PodUptimes[name]
+		if u.LastRestartedAt.IsZero() {
+			continue
+		}
+		ago := s.LastUpdated.Sub(u.LastRestartedAt)
+		if ago < 0 || ago >= recentRestartWindow {
+			continue
+		}
+		reasons = append(reasons, fmt.Sprintf("%s restarted %s ago (%d restarts)", name, ago.Round(time.Minute), u.RestartCount))
+	}
+	return VizierHealthHealthy, reasons
+}
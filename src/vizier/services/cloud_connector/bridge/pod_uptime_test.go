@@ -0,0 +1,97 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetPodUptime(t *testing.T) {
+	created := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	started := created.Add(5 * time.Second)
+	restarted := created.Add(time.Hour)
+	terminated := created.Add(2 * time.Hour)
+
+	pod := makePod("vizier-metadata-0", nil)
+	pod.CreationTimestamp = metav1.NewTime(created)
+	pod.Status.StartTime = &metav1.Time{Time: started}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{
+			Name:  "app",
+			State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: metav1.NewTime(started)}},
+		},
+		{
+			Name:         "proxy",
+			RestartCount: 2,
+			State:        corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: metav1.NewTime(restarted)}},
+		},
+		{
+			Name:         "sidecar",
+			RestartCount: 1,
+			State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+			LastTerminationState: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{FinishedAt: metav1.NewTime(terminated)},
+			},
+		},
+	}
+
+	u := getPodUptime(pod)
+	assert.Equal(t, &PodUptime{
+		CreatedAt:       created,
+		StartedAt:       started,
+		LastRestartedAt: terminated,
+		RestartCount:    3,
+	}, u)
+	assert.Equal(t, time.Hour, u.Uptime(started.Add(time.Hour)))
+	// Local clocks that are behind the API server don't produce a negative uptime.
+	assert.Equal(t, time.Duration(0), u.Uptime(started.Add(-time.Second)))
+
+	// Pods that haven't started have no start time, and don't pick up the local time.
+	pending := makePod("vizier-metadata-1", nil)
+	pending.CreationTimestamp = metav1.NewTime(created)
+	pending.Status.Phase = corev1.PodPending
+	u = getPodUptime(pending)
+	assert.Equal(t, &PodUptime{CreatedAt: created}, u)
+	assert.Equal(t, time.Duration(0), u.Uptime(created.Add(time.Hour)))
+}
+
+func TestCheckRecentRestarts(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	state := &K8sState{
+		LastUpdated: now,
+		PodUptimes: map[string]*PodUptime{
+			"vizier-metadata-0":     {StartedAt: now.Add(-time.Hour), LastRestartedAt: now.Add(-3 * time.Minute), RestartCount: 2},
+			"vizier-query-broker-0": {StartedAt: now.Add(-time.Hour), LastRestartedAt: now.Add(-time.Hour), RestartCount: 1},
+			"kelvin-0":              {StartedAt: now.Add(-time.Hour)},
+		},
+	}
+
+	health, reasons := checkRecentRestarts(state)
+	assert.Equal(t, VizierHealthHealthy, health)
+	assert.Equal(t, []string{"vizier-metadata-0 restarted 3m0s ago (2 restarts)"}, reasons)
+
+	state.VizierState = computeVizierState(state)
+	assert.Equal(t, VizierHealthHealthy, state.VizierState.Health)
+	assert.Contains(t, state.VizierState.Reasons, "vizier-metadata-0 restarted 3m0s ago (2 restarts)")
+}
@@ -0,0 +1,201 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"px.dev/pixie/src/shared/cvmsgspb"
+	"px.dev/pixie/src/shared/k8s/metadatapb"
+)
+
+// The number of pod status changes buffered for each watcher before the oldest changes are dropped.
+const podStatusChangeBufferSize = 64
+
+// PodStatusChangeType is the type of change to a pod's status.
+type PodStatusChangeType int
+
+const (
+	// PodStatusAdded indicates that a pod is now being tracked.
+	PodStatusAdded PodStatusChangeType = iota
+	// PodStatusModified indicates that the phase or reason of a tracked pod changed.
+	PodStatusModified
+	// PodStatusDeleted indicates that a pod is no longer being tracked.
+	PodStatusDeleted
+)
+
+func (t PodStatusChangeType) String() string {
+	switch t {
+	case PodStatusAdded:
+		return "Added"
+	case PodStatusModified:
+		return "Modified"
+	case PodStatusDeleted:
+		return "Deleted"
+	default:
+		return "Unknown"
+	}
+}
+
+// PodStatusChange describes a change to the status of a pod in the K8s state. Data plane pods are only
+// tracked while they are unhealthy, so a data plane pod that recovers is reported as deleted.
+type PodStatusChange struct {
+	Type      PodStatusChangeType
+	PodName   string
+	OldPhase  metadatapb.PodPhase
+	NewPhase  metadatapb.PodPhase
+	OldReason string
+	NewReason string
+}
+
+// podStatusWatcher is a subscriber to pod status changes.
+type podStatusWatcher struct {
+	ch chan PodStatusChange
+}
+
+// podStatusWatchers fans out pod status changes to all of the current watchers. Slow watchers never block
+// the publisher: when a watcher's buffer is full, its oldest change is dropped.
+type podStatusWatchers struct {
+	mu       sync.Mutex
+	watchers map[*podStatusWatcher]struct{}
+	dropped  int64
+}
+
+// Watch returns a channel of changes to the pod statuses in the K8s state, along with a function that
+// unsubscribes from the changes and closes the channel.
+func (v *K8sVizierInfo) Watch() (<-chan PodStatusChange, func()) {
+	return v.podWatchers.watch()
+}
+
+// DroppedPodStatusChanges returns the number of pod status changes that were dropped because a watcher
+// was not keeping up.
+func (v *K8sVizierInfo) DroppedPodStatusChanges() int64 {
+	return atomic.LoadInt64(&v.podWatchers.dropped)
+}
+
+func (w *podStatusWatchers) watch() (<-chan PodStatusChange, func()) {
+	watcher := &podStatusWatcher{ch: make(chan PodStatusChange, podStatusChangeBufferSize)}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.watchers == nil {
+		w.watchers = make(map[*podStatusWatcher]struct{})
+	}
+	w.watchers[watcher] = struct{}{}
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			delete(w.watchers, watcher)
+			close(watcher.ch)
+		})
+	}
+	return watcher.ch, unsubscribe
+}
+
+func (w *podStatusWatchers) publish(changes []PodStatusChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for watcher := range w.watchers {
+		for _, change := range changes {
+			for !trySend(watcher.ch, change) {
+				// The buffer is full, so make room by dropping the oldest change.
+				select {
+				case <-watcher.ch:
+					atomic.AddInt64(&w.dropped, 1)
+				default:
+				}
+			}
+		}
+	}
+}
+
+func trySend(ch chan PodStatusChange, change PodStatusChange) bool {
+	select {
+	case ch <- change:
+		return true
+	default:
+		return false
+	}
+}
+
+// diffPodStatuses returns the changes in phase or reason between the old and new pod statuses,
+// ordered by pod name.
+func diffPodStatuses(oldStatuses, newStatuses map[string]*cvmsgspb.PodStatus) []PodStatusChange {
+	var changes []PodStatusChange
+	for name, newStatus := range newStatuses {
+		oldStatus, ok := oldStatuses[name]
+		if !ok {
+			changes = append(changes, PodStatusChange{
+				Type:      PodStatusAdded,
+				PodName:   name,
+				NewPhase:  newStatus.Status,
+				NewReason: podStatusReason(newStatus),
+			})
+			continue
+		}
+		oldReason, newReason := podStatusReason(oldStatus), podStatusReason(newStatus)
+		if oldStatus.Status == newStatus.Status && oldReason == newReason {
+			continue
+		}
+		changes = append(changes, PodStatusChange{
+			Type:      PodStatusModified,
+			PodName:   name,
+			OldPhase:  oldStatus.Status,
+			NewPhase:  newStatus.Status,
+			OldReason: oldReason,
+			NewReason: newReason,
+		})
+	}
+	for name, oldStatus := range oldStatuses {
+		if _, ok := newStatuses[name]; ok {
+			continue
+		}
+		changes = append(changes, PodStatusChange{
+			Type:      PodStatusDeleted,
+			PodName:   name,
+			OldPhase:  oldStatus.Status,
+			OldReason: podStatusReason(oldStatus),
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].PodName < changes[j].PodName
+	})
+	return changes
+}
+
+// mergePodStatuses merges the given pod status maps into a single map.
+func mergePodStatuses(podStatuses ...map[string]*cvmsgspb.PodStatus) map[string]*cvmsgspb.PodStatus {
+	merged := make(map[string]*cvmsgspb.PodStatus)
+	for _, m := range podStatuses {
+		for k, v := range m {
+			merged[k] = v
+		}
+	}
+	return merged
+}
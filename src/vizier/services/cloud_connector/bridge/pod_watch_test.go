@@ -0,0 +1,124 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"px.dev/pixie/src/shared/cvmsgspb"
+	"px.dev/pixie/src/shared/k8s/metadatapb"
+)
+
+func TestDiffPodStatuses(t *testing.T) {
+	oldStatuses := map[string]*cvmsgspb.PodStatus{
+		"vizier-metadata-0":     runningPod("vizier-metadata-0"),
+		"vizier-query-broker-0": runningPod("vizier-query-broker-0"),
+		"kelvin-0":              pendingPod("kelvin-0"),
+	}
+	evictedPod := &cvmsgspb.PodStatus{
+		Name:          "vizier-query-broker-0",
+		Status:        metadatapb.FAILED,
+		StatusMessage: "Evicted",
+	}
+	newStatuses := map[string]*cvmsgspb.PodStatus{
+		"vizier-metadata-0":     runningPod("vizier-metadata-0"),
+		"vizier-query-broker-0": evictedPod,
+		"vizier-pem-abcde":      pendingPod("vizier-pem-abcde"),
+	}
+
+	assert.Equal(t, []PodStatusChange{
+		{
+			Type:     PodStatusDeleted,
+			PodName:  "kelvin-0",
+			OldPhase: metadatapb.PENDING,
+		},
+		{
+			Type:     PodStatusAdded,
+			PodName:  "vizier-pem-abcde",
+			NewPhase: metadatapb.PENDING,
+		},
+		{
+			Type:      PodStatusModified,
+			PodName:   "vizier-query-broker-0",
+			OldPhase:  metadatapb.RUNNING,
+			NewPhase:  metadatapb.FAILED,
+			NewReason: "Evicted",
+		},
+	}, diffPodStatuses(oldStatuses, newStatuses))
+	assert.Empty(t, diffPodStatuses(newStatuses, newStatuses))
+}
+
+func TestPodStatusWatchers_DropsOldest(t *testing.T) {
+	var w podStatusWatchers
+	ch, unsubscribe := w.watch()
+	defer unsubscribe()
+
+	var changes []PodStatusChange
+	for i := 0; i < podStatusChangeBufferSize+2; i++ {
+		changes = append(changes, PodStatusChange{PodName: string(rune('a' + i))})
+	}
+	w.publish(changes)
+
+	assert.Equal(t, int64(2), w.dropped)
+	require.Len(t, ch, podStatusChangeBufferSize)
+	// The oldest changes should have been dropped.
+	assert.Equal(t, changes[2], <-ch)
+}
+
+func TestPodStatusWatchers_Unsubscribe(t *testing.T) {
+	var w podStatusWatchers
+	ch, unsubscribe := w.watch()
+	unsubscribe()
+	// Unsubscribing twice should be safe.
+	unsubscribe()
+
+	_, ok := <-ch
+	assert.False(t, ok)
+	// Publishing without any watchers should not block or panic.
+	w.publish([]PodStatusChange{{PodName: "vizier-metadata-0"}})
+}
+
+func TestUpdateK8sState_PublishesPodStatusChanges(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		makePod("vizier-metadata-0", map[string]string{"plane": "control"}),
+	)
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+	ch, unsubscribe := vzInfo.Watch()
+	defer unsubscribe()
+
+	vzInfo.UpdateK8sState(context.Background())
+	require.Len(t, ch, 1)
+	change := <-ch
+	assert.Equal(t, PodStatusAdded, change.Type)
+	assert.Equal(t, "vizier-metadata-0", change.PodName)
+	assert.Equal(t, metadatapb.RUNNING, change.NewPhase)
+
+	// Nothing changed, so nothing should be published.
+	vzInfo.UpdateK8sState(context.Background())
+	assert.Len(t, ch, 0)
+	assert.Equal(t, int64(0), vzInfo.DroppedPodStatusChanges())
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Secrets rarely change, so they are only checked occasionally.
+const secretStatusRefreshPeriod = time.Minute
+
+// expectedSecret is a secret that Vizier expects to find in its namespace.
+type expectedSecret struct {
+	name string
+	// The keys that must be present for Vizier to work.
+	requiredKeys []string
+	// The keys that are reported if present, but that Vizier can work without.
+	optionalKeys []string
+	// Whether Vizier can work without the secret.
+	optional bool
+}
+
+// expectedSecrets are the secrets that Vizier expects to find in its namespace. Only the names of their keys
+// are ever read.
+var expectedSecrets = []expectedSecret{
+	{
+		name:         "pl-cluster-secrets",
+		requiredKeys: []string{"jwt-signing-key"},
+		optionalKeys: []string{"cluster-id", "cluster-name"},
+	},
+	{
+		name:         "pl-deploy-secrets",
+		optionalKeys: []string{"deploy-key"},
+		optional:     true,
+	},
+	{
+		name:         "service-tls-certs",
+		requiredKeys: []string{"ca.crt", "client.crt", "client.key", "server.crt", "server.key"},
+	},
+}
+
+// SecretStatus describes whether an expected secret exists, and which of its expected keys it has. The values
+// of the secret are never included.
+type SecretStatus struct {
+	Name     string
+	Optional bool
+	Present  bool
+	// When the secret was created. Ex: to tell how old a deploy key is.
+	CreatedAt time.Time
+	// The expected keys that are present, sorted.
+	Keys []string
+	// The required keys that are missing, sorted.
+	MissingKeys []string
+	// Set if the secret could not be read, in which case it is not known whether it is present.
+	Error string
+}
+
+// getSecretStatus builds the status of the expected secret from the names of the keys that it has.
+func getSecretStatus(expected expectedSecret, createdAt time.Time, keys map[string][]byte) *SecretStatus {
+	s := &SecretStatus{
+		Name:      expected.name,
+		Optional:  expected.optional,
+		Present:   true,
+		CreatedAt: createdAt,
+	}
+	for _, key := range expected.requiredKeys {
+		if _, ok := keys[key]; ok {
+			s.Keys = append(s.Keys, key)
+		} else {
+			s.MissingKeys = append(s.MissingKeys, key)
+		}
+	}
+	for _, key := range expected.optionalKeys {
+		if _, ok := keys[key]; ok {
+			s.Keys = append(s.Keys, key)
+		}
+	}
+	sort.Strings(s.Keys)
+	sort.Strings(s.MissingKeys)
+	return s
+}
+
+// collectSecretStatuses checks which of the expected secrets exist in the Vizier namespace.
+func (v *K8sVizierInfo) collectSecretStatuses(ctx context.Context) []*SecretStatus {
+	if !v.collectorAvailable(collectorSecrets) {
+		return nil
+	}
+
+	statuses := make([]*SecretStatus, 0, len(expectedSecrets))
+	for _, expected := range expectedSecrets {
+		secret, err := v.clientset.CoreV1().Secrets(v.ns).Get(ctx, expected.name, metav1.GetOptions{})
+		switch {
+		case k8sErrors.IsNotFound(err):
+			statuses = append(statuses, &SecretStatus{Name: expected.name, Optional: expected.optional})
+		case err != nil:
+			recordK8sAPIError("secrets")
+			log.WithError(err).WithField("secret", expected.name).Warn("Failed to check for expected secret")
+			statuses = append(statuses, &SecretStatus{Name: expected.name, Optional: expected.optional, Error: err.Error()})
+		default:
+			statuses = append(statuses, getSecretStatus(expected, secret.CreationTimestamp.Time, secret.Data))
+		}
+	}
+	return statuses
+}
+
+// refreshSecretStatuses checks for the expected secrets, if they have not been checked within the refresh
+// period.
+func (v *K8sVizierInfo) refreshSecretStatuses(ctx context.Context, now time.Time) {
+	v.mu.Lock()
+	stale := now.Sub(v.secretStatusesLastUpdated) >= secretStatusRefreshPeriod
+	v.mu.Unlock()
+	if !stale {
+		return
+	}
+
+	statuses := v.collectSecretStatuses(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.secretStatuses = statuses
+	v.secretStatusesLastUpdated = now
+}
+
+func copySecretStatuses(statuses []*SecretStatus) []*SecretStatus {
+	if statuses == nil {
+		return nil
+	}
+	clone := make([]*SecretStatus, len(statuses))
+	for i, s := range statuses {
+		c := *s
+		c.Keys = append([]string(nil), s.Keys...)
+		c.MissingKeys = append([]string(nil), s.MissingKeys...)
+		clone[i] = &c
+	}
+	return clone
+}
+
+// A missing required secret or key keeps Vizier from starting or connecting, so it makes Vizier unhealthy.
+// Secrets that could not be read are left to the collectors' own reporting.
+func checkSecrets(s *K8sState) (VizierHealth, []string) {
+	var reasons []string
+	for _, secret := range s.SecretStatuses {
+		if secret.Error != "" || secret.Optional {
+			continue
+		}
+		if !secret.Present {
+			reasons = append(reasons, fmt.Sprintf("secret %s is missing", secret.Name))
+			continue
+		}
+		for _, key := range secret.MissingKeys {
+			reasons = append(reasons, fmt.Sprintf("secret %s is missing key %s", secret.Name, key))
+		}
+	}
+	if len(reasons) == 0 {
+		return VizierHealthHealthy, nil
+	}
+	return VizierHealthUnhealthy, reasons
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// expectedSecretObjects returns the required secrets with all of their required keys, followed by the objs.
+func expectedSecretObjects(objs ...runtime.Object) []runtime.Object {
+	var secrets []runtime.Object
+	for _, expected := range expectedSecrets {
+		if expected.optional {
+			continue
+		}
+		data := make(map[string][]byte)
+		for _, key := range expected.requiredKeys {
+			data[key] = []byte("value")
+		}
+		secrets = append(secrets, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: expected.name, Namespace: testNamespace},
+			Data:       data,
+		})
+	}
+	return append(secrets, objs...)
+}
+
+func TestGetSecretStatus(t *testing.T) {
+	created := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := getSecretStatus(expectedSecrets[0], created, map[string][]byte{
+		"cluster-name": []byte("my-cluster"),
+		"unrelated":    []byte("value"),
+	})
+	assert.Equal(t, &SecretStatus{
+		Name:        "pl-cluster-secrets",
+		Present:     true,
+		CreatedAt:   created,
+		Keys:        []string{"cluster-name"},
+		MissingKeys: []string{"jwt-signing-key"},
+	}, s)
+}
+
+func TestCollectSecretStatuses(t *testing.T) {
+	created := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	clientset := fake.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "pl-cluster-secrets", Namespace: testNamespace, CreationTimestamp: metav1.NewTime(created)},
+			Data: map[string][]byte{
+				"jwt-signing-key": []byte("secret-value"),
+				"cluster-id":      []byte("secret-value"),
+			},
+		},
+	)
+	clientset.PrependReactor("get", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.GetAction).GetName() != "service-tls-certs" {
+			return false, nil, nil
+		}
+		return true, nil, k8serrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "service-tls-certs", nil)
+	})
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	statuses := vzInfo.collectSecretStatuses(context.Background())
+	require.Len(t, statuses, 3)
+	assert.Equal(t, &SecretStatus{
+		Name:      "pl-cluster-secrets",
+		Present:   true,
+		CreatedAt: created,
+		Keys:      []string{"cluster-id", "jwt-signing-key"},
+	}, statuses[0])
+	assert.Equal(t, &SecretStatus{Name: "pl-deploy-secrets", Optional: true}, statuses[1])
+	assert.False(t, statuses[2].Present)
+	assert.NotEmpty(t, statuses[2].Error)
+
+	// Only the names of the keys are kept, never their values.
+	for _, s := range statuses {
+		for _, key := range s.Keys {
+			assert.NotContains(t, key, "secret-value")
+		}
+	}
+}
+
+func TestCheckSecrets(t *testing.T) {
+	health, reasons := checkSecrets(&K8sState{SecretStatuses: []*SecretStatus{
+		{Name: "pl-cluster-secrets", Present: true, Keys: []string{"jwt-signing-key"}},
+		{Name: "pl-deploy-secrets", Optional: true},
+		{Name: "proxy-tls-certs", Error: "forbidden"},
+	}})
+	assert.Equal(t, VizierHealthHealthy, health)
+	assert.Empty(t, reasons)
+
+	health, reasons = checkSecrets(&K8sState{SecretStatuses: []*SecretStatus{
+		{Name: "pl-cluster-secrets"},
+		{Name: "service-tls-certs", Present: true, Keys: []string{"ca.crt"}, MissingKeys: []string{"server.crt", "server.key"}},
+	}})
+	assert.Equal(t, VizierHealthUnhealthy, health)
+	assert.Equal(t, []string{
+		"secret pl-cluster-secrets is missing",
+		"secret service-tls-certs is missing key server.crt",
+		"secret service-tls-certs is missing key server.key",
+	}, reasons)
+}
+
+func TestRefreshSecretStatuses(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	now := time.Now()
+	vzInfo.refreshSecretStatuses(context.Background(), now)
+	assert.False(t, vzInfo.GetK8sState().SecretStatuses[0].Present)
+
+	for _, obj := range expectedSecretObjects() {
+		_, err := clientset.CoreV1().Secrets(testNamespace).Create(context.Background(), obj.(*corev1.Secret), metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+	vzInfo.refreshSecretStatuses(context.Background(), now.Add(time.Second))
+	assert.False(t, vzInfo.GetK8sState().SecretStatuses[0].Present)
+	vzInfo.refreshSecretStatuses(context.Background(), now.Add(secretStatusRefreshPeriod))
+	assert.True(t, vzInfo.GetK8sState().SecretStatuses[0].Present)
+}
@@ -0,0 +1,180 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Quota usage above this fraction of the hard limit is reported as a health reason.
+const resourceQuotaUsageWarningFraction = 0.9
+
+// quotaResources are the quota resources that can block Vizier pods from being scheduled.
+var quotaResources = []corev1.ResourceName{
+	corev1.ResourceCPU,
+	corev1.ResourceMemory,
+	corev1.ResourcePods,
+	corev1.ResourceRequestsCPU,
+	corev1.ResourceRequestsMemory,
+	corev1.ResourceLimitsCPU,
+	corev1.ResourceLimitsMemory,
+}
+
+// ResourceQuotaUsage describes the usage of a resource limited by a ResourceQuota in the Vizier namespace.
+type ResourceQuotaUsage struct {
+	// The name of the ResourceQuota.
+	Quota string
+	// The limited resource. Ex: "requests.memory".
+	Resource string
+	// The used and hard limit quantities. Ex: "3Gi".
+	Used string
+	Hard string
+	// The fraction of the hard limit that is in use.
+	UsedFraction float64
+}
+
+// LimitRangeItem describes the limits that a LimitRange in the Vizier namespace places on a resource.
+// Unset limits are empty.
+type LimitRangeItem struct {
+	// The name of the LimitRange.
+	LimitRange string
+	// The kind of object that the limits apply to. Ex: "Container".
+	Type     string
+	Resource string
+	Min      string
+	Max      string
+	// The default limit and request applied to containers which don't specify one.
+	Default        string
+	DefaultRequest string
+}
+
+func formatQuantity(quantities corev1.ResourceList, name corev1.ResourceName) string {
+	q, ok := quantities[name]
+	if !ok {
+		return ""
+	}
+	return q.String()
+}
+
+// getResourceQuotaUsages gets the usage of the quota-limited resources in the Vizier namespace. This
+// collector is optional: if the list fails, for example due to missing RBAC, the quotas are left out.
+func (v *K8sVizierInfo) getResourceQuotaUsages(ctx context.Context) []*ResourceQuotaUsage {
+	if !v.collectorAvailable(collectorResourceQuotas) {
+		return nil
+	}
+	quotas, err := v.clientset.CoreV1().ResourceQuotas(v.ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		recordK8sAPIError("resourcequotas")
+		log.WithError(err).Warn("Failed to list resource quotas, leaving them out of the K8s state")
+		return nil
+	}
+
+	var usages []*ResourceQuotaUsage
+	for _, q := range quotas.Items {
+		for _, name := range quotaResources {
+			hard, ok := q.Status.Hard[name]
+			if !ok {
+				continue
+			}
+			used := q.Status.Used[name]
+			usage := &ResourceQuotaUsage{
+				Quota:    q.Name,
+				Resource: string(name),
+				Used:     used.String(),
+				Hard:     hard.String(),
+			}
+			if hard.Sign() > 0 {
+				usage.UsedFraction = used.AsApproximateFloat64() / hard.AsApproximateFloat64()
+			} else if used.Sign() > 0 {
+				// Nothing more can be created under a zero quota.
+				usage.UsedFraction = 1
+			}
+			usages = append(usages, usage)
+		}
+	}
+	return usages
+}
+
+// getLimitRangeItems gets the limits set by the LimitRanges in the Vizier namespace. This collector is
+// optional: if the list fails, for example due to missing RBAC, the limits are left out.
+func (v *K8sVizierInfo) getLimitRangeItems(ctx context.Context) []*LimitRangeItem {
+	if !v.collectorAvailable(collectorLimitRanges) {
+		return nil
+	}
+	limitRanges, err := v.clientset.CoreV1().LimitRanges(v.ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		recordK8sAPIError("limitranges")
+		log.WithError(err).Warn("Failed to list limit ranges, leaving them out of the K8s state")
+		return nil
+	}
+
+	var items []*LimitRangeItem
+	for _, lr := range limitRanges.Items {
+		for _, l := range lr.Spec.Limits {
+			resourceSet := make(map[corev1.ResourceName]struct{})
+			for _, list := range []corev1.ResourceList{l.Min, l.Max, l.Default, l.DefaultRequest} {
+				for name := range list {
+					resourceSet[name] = struct{}{}
+				}
+			}
+			resources := make([]string, 0, len(resourceSet))
+			for name := range resourceSet {
+				resources = append(resources, string(name))
+			}
+			sort.Strings(resources)
+
+			for _, r := range resources {
+				name := corev1.ResourceName(r)
+				items = append(items, &LimitRangeItem{
+					LimitRange:     lr.Name,
+					Type:           string(l.Type),
+					Resource:       r,
+					Min:            formatQuantity(l.Min, name),
+					Max:            formatQuantity(l.Max, name),
+					Default:        formatQuantity(l.Default, name),
+					DefaultRequest: formatQuantity(l.DefaultRequest, name),
+				})
+			}
+		}
+	}
+	return items
+}
+
+func copyResourceQuotaUsages(usages []*ResourceQuotaUsage) []*ResourceQuotaUsage {
+	if usages == nil {
+		return nil
+	}
+	clone := make([]*ResourceQuotaUsage, len(usages))
+	copy(clone, usages)
+	return clone
+}
+
+func copyLimitRangeItems(items []*LimitRangeItem) []*LimitRangeItem {
+	if items == nil {
+		return nil
+	}
+	clone := make([]*LimitRangeItem, len(items))
+	copy(clone, items)
+	return clone
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestGetResourceQuotaUsages(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "pl-quota", Namespace: testNamespace},
+		Status: corev1.ResourceQuotaStatus{
+			Hard: corev1.ResourceList{
+				corev1.ResourceRequestsMemory: resource.MustParse("10Gi"),
+				corev1.ResourcePods:           resource.MustParse("20"),
+				corev1.ResourceServices:       resource.MustParse("5"),
+			},
+			Used: corev1.ResourceList{
+				corev1.ResourceRequestsMemory: resource.MustParse("9500Mi"),
+				corev1.ResourcePods:           resource.MustParse("10"),
+				corev1.ResourceServices:       resource.MustParse("5"),
+			},
+		},
+	})
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	usages := vzInfo.getResourceQuotaUsages(context.Background())
+	// Services can't block Vizier pods, so they are not reported.
+	require.Len(t, usages, 2)
+	assert.Equal(t, "pods", usages[0].Resource)
+	assert.Equal(t, "10", usages[0].Used)
+	assert.Equal(t, "20", usages[0].Hard)
+	assert.InDelta(t, 0.5, usages[0].UsedFraction, 0.001)
+	assert.Equal(t, "requests.memory", usages[1].Resource)
+	assert.InDelta(t, 0.928, usages[1].UsedFraction, 0.001)
+
+	state := computeVizierState(&K8sState{LastUpdated: time.Now(), ResourceQuotaUsages: usages})
+	assert.Equal(t, VizierHealthDegraded, state.Health)
+	assert.Equal(t, []string{"resource quota pl-quota has used 9500Mi of 10Gi requests.memory"}, state.Reasons)
+}
+
+func TestGetLimitRangeItems(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{Name: "pl-limits", Namespace: testNamespace},
+		Spec: corev1.LimitRangeSpec{
+			Limits: []corev1.LimitRangeItem{
+				{
+					Type:    corev1.LimitTypeContainer,
+					Max:     corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+					Default: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+					DefaultRequest: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("100m"),
+						corev1.ResourceMemory: resource.MustParse("256Mi"),
+					},
+				},
+			},
+		},
+	})
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	assert.Equal(t, []*LimitRangeItem{
+		{
+			LimitRange:     "pl-limits",
+			Type:           "Container",
+			Resource:       "cpu",
+			DefaultRequest: "100m",
+		},
+		{
+			LimitRange:     "pl-limits",
+			Type:           "Container",
+			Resource:       "memory",
+			Max:            "1Gi",
+			Default:        "512Mi",
+			DefaultRequest: "256Mi",
+		},
+	}, vzInfo.getLimitRangeItems(context.Background()))
+}
+
+func TestResourceQuotas_Forbidden(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	for _, r := range []string{"resourcequotas", "limitranges"} {
+		clientset.PrependReactor("list", r, func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, k8serrors.NewForbidden(schema.GroupResource{Resource: action.GetResource().Resource}, "", nil)
+		})
+	}
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	assert.Nil(t, vzInfo.getResourceQuotaUsages(context.Background()))
+	assert.Nil(t, vzInfo.getLimitRangeItems(context.Background()))
+}
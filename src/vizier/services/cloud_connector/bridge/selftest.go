@@ -0,0 +1,151 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The service that fronts the query broker and its proxy, which the cloud connects to for queries.
+const queryBrokerServiceName = "vizier-query-broker-svc"
+
+// SelfTestCheck is the result of a single self-test check.
+type SelfTestCheck struct {
+	Name   string
+	Passed bool
+	// The permission the cloud connector is missing, if the check was forbidden. Ex: "list pods in namespace pl".
+	MissingPermission string
+	// The object that does not exist, if the check could not find it. Ex: "service pl/vizier-query-broker-svc".
+	MissingObject string
+	// The error from any other failure.
+	Error string
+	// Set if the check passed using a fallback, and describes why the fallback was needed.
+	Warning string
+}
+
+// Problem returns a description of why the check failed, or an empty string if it passed.
+func (c *SelfTestCheck) Problem() string {
+	switch {
+	case c.Passed:
+		return ""
+	case c.MissingPermission != "":
+		return fmt.Sprintf("%s: missing permission to %s", c.Name, c.MissingPermission)
+	case c.MissingObject != "":
+		return fmt.Sprintf("%s: %s not found", c.Name, c.MissingObject)
+	default:
+		return fmt.Sprintf("%s: %s", c.Name, c.Error)
+	}
+}
+
+// SelfTestReport is the result of checking that the cloud connector has the access and objects it needs.
+type SelfTestReport struct {
+	Checks []*SelfTestCheck
+	RanAt  time.Time
+}
+
+// Passed returns whether all of the checks passed.
+func (r *SelfTestReport) Passed() bool {
+	for _, c := range r.Checks {
+		if !c.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// Summary returns a description of the failed checks, or an empty string if all of them passed.
+func (r *SelfTestReport) Summary() string {
+	var problems []string
+	for _, c := range r.Checks {
+		if p := c.Problem(); p != "" {
+			problems = append(problems, p)
+		}
+	}
+	return strings.Join(problems, "; ")
+}
+
+// runSelfTestCheck runs the check, classifying any error as a missing permission to perform the verb on the
+// resource, or as the named object not existing.
+func runSelfTestCheck(name, verb, resource, ns, object string, check func() error) *SelfTestCheck {
+	c := &SelfTestCheck{Name: name}
+	err := check()
+	switch {
+	case err == nil:
+		c.Passed = true
+	case k8sErrors.IsForbidden(err) || k8sErrors.IsUnauthorized(err):
+		c.MissingPermission = fmt.Sprintf("%s %s", verb, resource)
+		if ns != "" {
+			c.MissingPermission = fmt.Sprintf("%s in namespace %s", c.MissingPermission, ns)
+		}
+	case k8sErrors.IsNotFound(err) && object != "":
+		c.MissingObject = fmt.Sprintf("%s %s", strings.TrimSuffix(resource, "s"), object)
+		if ns != "" {
+			c.MissingObject = fmt.Sprintf("%s %s/%s", strings.TrimSuffix(resource, "s"), ns, object)
+		}
+	default:
+		c.Error = err.Error()
+	}
+	return c
+}
+
+// SelfTest checks that the cloud connector can reach the K8s API and has the permissions and objects that
+// it needs, so that failed installs report why they failed instead of the errors that follow from it.
+func (v *K8sVizierInfo) SelfTest(ctx context.Context) *SelfTestReport {
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+
+	report := &SelfTestReport{RanAt: time.Now()}
+	report.Checks = append(report.Checks, runSelfTestCheck("list pods", "list", "pods", v.ns, "", func() error {
+		_, err := v.clientset.CoreV1().Pods(v.ns).List(ctx, metav1.ListOptions{Limit: 1})
+		return err
+	}))
+	report.Checks = append(report.Checks, runSelfTestCheck("get query broker service", "get", "services", v.ns, queryBrokerServiceName, func() error {
+		_, err := v.clientset.CoreV1().Services(v.ns).Get(ctx, queryBrokerServiceName, metav1.GetOptions{})
+		return err
+	}))
+
+	// The cluster UID falls back to the Vizier namespace when kube-system can't be read.
+	kubeSystem := runSelfTestCheck("get kube-system namespace", "get", "namespaces", "", "kube-system", func() error {
+		_, err := v.clientset.CoreV1().Namespaces().Get(ctx, "kube-system", metav1.GetOptions{})
+		return err
+	})
+	if !kubeSystem.Passed {
+		fallback := runSelfTestCheck("get kube-system namespace", "get", "namespaces", "", v.ns, func() error {
+			_, err := v.clientset.CoreV1().Namespaces().Get(ctx, v.ns, metav1.GetOptions{})
+			return err
+		})
+		if fallback.Passed {
+			fallback.Warning = fmt.Sprintf("%s, using namespace %s for the cluster UID", strings.TrimPrefix(kubeSystem.Problem(), kubeSystem.Name+": "), v.ns)
+			kubeSystem = fallback
+		}
+	}
+	report.Checks = append(report.Checks, kubeSystem)
+
+	report.Checks = append(report.Checks, runSelfTestCheck("list nodes", "list", "nodes", "", "", func() error {
+		_, err := v.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{Limit: 1})
+		return err
+	}))
+	return report
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestSelfTest_Passed(t *testing.T) {
+	vzInfo := &K8sVizierInfo{
+		ns: testNamespace,
+		clientset: fake.NewSimpleClientset(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: queryBrokerServiceName, Namespace: testNamespace}},
+		),
+	}
+
+	report := vzInfo.SelfTest(context.Background())
+	assert.True(t, report.Passed())
+	assert.Empty(t, report.Summary())
+	assert.Len(t, report.Checks, 4)
+	for _, c := range report.Checks {
+		assert.Empty(t, c.Warning)
+	}
+}
+
+func TestSelfTest_Failures(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNamespace}},
+	)
+	clientset.PrependReactor("list", "nodes", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewForbidden(schema.GroupResource{Resource: "nodes"}, "", nil)
+	})
+	clientset.PrependReactor("get", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.GetAction).GetName() != "kube-system" {
+			return false, nil, nil
+		}
+		return true, nil, k8serrors.NewForbidden(schema.GroupResource{Resource: "namespaces"}, "kube-system", nil)
+	})
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	report := vzInfo.SelfTest(context.Background())
+	require.Len(t, report.Checks, 4)
+	assert.False(t, report.Passed())
+
+	assert.True(t, report.Checks[0].Passed)
+	assert.Equal(t, "service pl/vizier-query-broker-svc", report.Checks[1].MissingObject)
+	// kube-system is forbidden, but the cluster UID can fall back to the Vizier namespace.
+	assert.True(t, report.Checks[2].Passed)
+	assert.Equal(t, "missing permission to get namespaces, using namespace pl for the cluster UID", report.Checks[2].Warning)
+	assert.Equal(t, "list nodes", report.Checks[3].MissingPermission)
+
+	assert.Equal(t, "get query broker service: service pl/vizier-query-broker-svc not found; "+
+		"list nodes: missing permission to list nodes", report.Summary())
+}
+
+func TestSelfTestMessage(t *testing.T) {
+	failed := &SelfTestReport{Checks: []*SelfTestCheck{
+		{Name: "list pods", MissingPermission: "list pods in namespace pl"},
+	}}
+	assert.Equal(t, "Healthy", selfTestMessage("Healthy", &SelfTestReport{}))
+	assert.Equal(t, "Healthy", selfTestMessage("Healthy", nil))
+	assert.Equal(t, "self-test failed: list pods: missing permission to list pods in namespace pl", selfTestMessage("", failed))
+	assert.Equal(t, "kelvin-0 PENDING: self-test failed: list pods: missing permission to list pods in namespace pl",
+		selfTestMessage("kelvin-0 PENDING", failed))
+}
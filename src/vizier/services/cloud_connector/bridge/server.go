@@ -131,6 +131,8 @@ const (
 	registrationTimeout           = 30 * time.Second
 	passthroughReplySubjectPrefix = "v2c.reply-"
 	vizStatusCheckFailInterval    = 10 * time.Second
+	// How long a debug pods request waits for a fresh K8s state, before it falls back to listing the pods.
+	debugPodsTimeout = 5 * time.Second
 )
 
 // ErrRegistrationTimeout is the registration timeout error.
@@ -148,12 +150,17 @@ type VizierInfo interface {
 	WaitForJobCompletion(string) (bool, error)
 	DeleteJob(string) error
 	GetJob(string) (*batchv1.Job, error)
-	GetClusterUID() (string, error)
+	GetClusterUID(context.Context) (string, error)
 	UpdateClusterID(string) error
 	UpdateClusterName(string) error
 	UpdateClusterIDAnnotation(string) error
 	GetVizierPodLogs(string, bool, string) (string, error)
 	GetVizierPods() ([]*vizierpb.VizierPodStatus, []*vizierpb.VizierPodStatus, error)
+	SelfTest(context.Context) *SelfTestReport
+	ForceUpdate(context.Context) (*K8sState, error)
+	GetPodStatusChangesSince(int64) *PodStatusDelta
+	RecordStatusDelivered(time.Time)
+	RecordConnected(time.Time)
 }
 
 // VizierOperatorInfo updates and fetches info about the Vizier CRD.
@@ -206,8 +213,13 @@ type Bridge struct {
 	wdWg   sync.WaitGroup // Tracks all the active goroutines.
 
 	updateRunning atomic.Value // True if an update is running
+	selfTest      atomic.Value // The *SelfTestReport from when the stream was last started.
 	updateFailed  bool         // True if an update has failed (sticky).
 
+	podStatusesRequested     int32 // Set when the next heartbeat should include the pod statuses. Only accessed atomically.
+	podStatusResyncRequested int32 // Set when cloud asked for the full pod statuses. Only accessed atomically.
+	lastHeartbeatAck         int64 // The time, in Unix ns, that cloud last acknowledged a heartbeat. Only accessed atomically.
+
 	droppedMessagesBeforeResume int64 // Number of messages dropped before successful resume.
 
 	natsMetricsCh chan *nats.Msg
@@ -605,7 +617,10 @@ func (s *Bridge) handleDebugPodsRequest(reqID string, req *vizierpb.DebugPodsReq
 		return err
 	}
 
-	ctrlPods, dataPods, err := s.vzInfo.GetVizierPods()
+	// The passthrough request doesn't carry a deadline of its own, so it gets the default one.
+	ctx, cancel := context.WithTimeout(context.Background(), debugPodsTimeout)
+	defer cancel()
+	ctrlPods, dataPods, err := s.getDebugPods(ctx)
 	if err != nil {
 		return err
 	}
@@ -624,6 +639,19 @@ func (s *Bridge) handleDebugPodsRequest(reqID string, req *vizierpb.DebugPodsReq
 	return s.sendDebugStreamResponse(reqID, resps)
 }
 
+// getDebugPods forces an update of the K8s state and returns the Vizier pods from it. The fresh pod statuses are
+// also sent with the next heartbeat, since otherwise the pod statuses the cloud has can be up to a minute old.
+// If the update fails or doesn't finish before the context is done, the pods are listed directly instead.
+func (s *Bridge) getDebugPods(ctx context.Context) ([]*vizierpb.VizierPodStatus, []*vizierpb.VizierPodStatus, error) {
+	state, err := s.vzInfo.ForceUpdate(ctx)
+	if err != nil {
+		log.WithError(err).Warn("Failed to force an update of the K8s state, listing the Vizier pods instead")
+		return s.vzInfo.GetVizierPods()
+	}
+	atomic.StoreInt32(&s.podStatusesRequested, 1)
+	return state.ControlPlanePods, state.DataPlanePods, nil
+}
+
 func (s *Bridge) handleMetricsMessage(msg *messagespb.MetricsMessage) error {
 	promWriteReq, err := vzmetrics.ParsePrometheusTextToWriteReq(msg.PromMetricsText, s.vizierID.String(), msg.PodName)
 	if err != nil {
@@ -678,6 +706,7 @@ func (s *Bridge) doRegistrationHandshake(stream vzconnpb.VZConnService_NATSBridg
 			default:
 				return errors.New("registration unsuccessful: " + err.Error())
 			}
+			s.vzInfo.RecordConnected(time.Now())
 
 			if s.assignedClusterName == "" {
 				// Deliberately not returning the error. We don't want to kill a cluster
@@ -704,6 +733,8 @@ func (s *Bridge) StartStream() error {
 	done := make(chan bool)
 	defer close(done)
 
+	s.runSelfTest()
+
 	// We backoff-retry the registration logic but immediately fail the core-logic.
 	backOffOpts := backoff.NewExponentialBackOff()
 	backOffOpts.InitialInterval = 30 * time.Second
@@ -943,6 +974,14 @@ func (s *Bridge) HandleNATSBridging(stream vzconnpb.VZConnService_NATSBridgeClie
 				return nil
 			}
 
+			if bridgeMsg.Topic == HeartbeatAckTopic {
+				err := s.handleHeartbeatAck(bridgeMsg.Msg, time.Now())
+				if err != nil {
+					log.WithError(err).Error("Failed to handle heartbeat ack")
+				}
+				continue
+			}
+
 			if bridgeMsg.Topic == "VizierUpdate" {
 				err := s.handleUpdateMessage(bridgeMsg.Msg)
 				if err != nil && !k8sErrors.IsAlreadyExists(err) {
@@ -1113,9 +1152,46 @@ func crdPhaseToHeartbeatStatus(phase v1alpha1.VizierPhase) cvmsgspb.VizierStatus
 	}
 }
 
+// runSelfTest checks that the cloud connector has the access it needs, so that the failures of broken
+// installs are reported alongside the status.
+func (s *Bridge) runSelfTest() {
+	report := s.vzInfo.SelfTest(context.Background())
+	for _, c := range report.Checks {
+		if c.Warning != "" {
+			log.WithField("check", c.Name).Warn(c.Warning)
+		}
+		if !c.Passed {
+			log.WithField("check", c.Name).Error("Self-test failed: " + c.Problem())
+		}
+	}
+	s.selfTest.Store(report)
+}
+
+// selfTestMessage adds the failures of the self-test, if any, to the status message.
+func selfTestMessage(msg string, report *SelfTestReport) string {
+	if report == nil || report.Passed() {
+		return msg
+	}
+	failed := fmt.Sprintf("self-test failed: %s", report.Summary())
+	if msg == "" {
+		return failed
+	}
+	return fmt.Sprintf("%s: %s", msg, failed)
+}
+
+// staleK8sStateMessage marks the status message as being based on K8s state that was last updated age ago.
+func staleK8sStateMessage(msg string, age time.Duration) string {
+	stale := fmt.Sprintf("K8s state is stale, last updated %s ago", age.Round(time.Second))
+	if msg == "" {
+		return stale
+	}
+	return fmt.Sprintf("%s: %s", stale, msg)
+}
+
 func (s *Bridge) generateHeartbeats(done <-chan bool) chan *cvmsgspb.VizierHeartbeat {
 	hbCh := make(chan *cvmsgspb.VizierHeartbeat)
 	crdSeen := false
+	podSync := &podStatusSync{}
 
 	sendHeartbeat := func() {
 		state := s.vzInfo.GetK8sState()
@@ -1147,6 +1223,33 @@ func (s *Bridge) generateHeartbeats(done <-chan bool) chan *cvmsgspb.VizierHeart
 			msg = operatorMessage
 		}
 
+		// Without a CRD, fall back to the health computed from the K8s state to explain why
+		// the vizier is not healthy. Updates are expected to disrupt pods, so leave those alone.
+		if vz == nil && state.VizierState != nil && status != cvmsgspb.VZ_ST_UPDATING && status != cvmsgspb.VZ_ST_UPDATE_FAILED {
+			switch state.VizierState.Health {
+			case VizierHealthUnhealthy:
+				status = cvmsgspb.VZ_ST_UNHEALTHY
+				msg = state.VizierState.Message()
+			case VizierHealthDegraded:
+				if status == cvmsgspb.VZ_ST_HEALTHY || status == cvmsgspb.VZ_ST_DEGRADED {
+					status = cvmsgspb.VZ_ST_DEGRADED
+					msg = state.VizierState.Message()
+				}
+			}
+		}
+
+		// Don't present frozen K8s state as current.
+		if state.Stale {
+			msg = staleK8sStateMessage(msg, time.Since(state.LastUpdated))
+		}
+		if report, ok := s.selfTest.Load().(*SelfTestReport); ok {
+			msg = selfTestMessage(msg, report)
+		}
+		// Tell cloud when its acks stop arriving, since it can't see that from its side of the connection.
+		if age, ok := s.deliveryAge(time.Now()); ok && age > undeliveredStatusThreshold {
+			msg = undeliveredStatusMessage(msg, age)
+		}
+
 		hbMsg := &cvmsgspb.VizierHeartbeat{
 			VizierID:                      utils.ProtoFromUUID(s.vizierID),
 			Time:                          time.Now().UnixNano(),
@@ -1161,10 +1264,26 @@ func (s *Bridge) generateHeartbeats(done <-chan bool) chan *cvmsgspb.VizierHeart
 			DisableAutoUpdate:             viper.GetBool("disable_auto_update"),
 			OperatorVersion:               operatorVersion,
 		}
+		if state.ClusterStats != nil {
+			hbMsg.NodeKernelVersions = state.ClusterStats.KernelVersions
+		}
+		if state.InstallInfo != nil {
+			hbMsg.InstalledAtNs = unixNanosOrZero(state.InstallInfo.InstalledAt)
+			hbMsg.FirstConnectedAtNs = unixNanosOrZero(state.InstallInfo.FirstConnectedAt)
+		}
 
-		// Only send the control plane pod statuses every 1 min.
-		if atomic.LoadInt64(&s.hbSeqNum)%12 == 0 {
-			hbMsg.PodStatuses = state.ControlPlanePodStatuses
+		// Only send the control plane pod statuses every 1 min, and only the changes since they were last sent,
+		// with a full resync every resync period. The cloud keeps the previous statuses if none are sent.
+		// Statuses that were refreshed because the cloud asked for them, and full statuses that the cloud asked
+		// for because it could not apply a delta, are sent right away.
+		requested := atomic.SwapInt32(&s.podStatusesRequested, 0) == 1
+		resync := atomic.SwapInt32(&s.podStatusResyncRequested, 0) == 1
+		var podDelta *PodStatusDelta
+		if requested || resync || atomic.LoadInt64(&s.hbSeqNum)%12 == 0 {
+			podDelta = podSync.changesToSend(s.vzInfo.GetPodStatusChangesSince, resync, time.Now())
+		}
+		if podDelta != nil {
+			setPodStatuses(hbMsg, podDelta)
 		}
 
 		select {
@@ -1174,6 +1293,9 @@ func (s *Bridge) generateHeartbeats(done <-chan bool) chan *cvmsgspb.VizierHeart
 			return
 		case hbCh <- hbMsg:
 			atomic.AddInt64(&s.hbSeqNum, 1)
+			if podDelta != nil {
+				podSync.sent(podDelta, time.Now())
+			}
 		}
 	}
 
@@ -1240,7 +1362,9 @@ func (s *Bridge) DebugLog(req *vizierpb.DebugLogRequest, srv vizierpb.VizierDebu
 
 // DebugPods is the GRPC method to fetch the list of Vizier pods (and statuses) from a cluster.
 func (s *Bridge) DebugPods(req *vizierpb.DebugPodsRequest, srv vizierpb.VizierDebugService_DebugPodsServer) error {
-	ctrlPods, dataPods, err := s.vzInfo.GetVizierPods()
+	ctx, cancel := context.WithTimeout(srv.Context(), debugPodsTimeout)
+	defer cancel()
+	ctrlPods, dataPods, err := s.getDebugPods(ctx)
 	if err != nil {
 		return err
 	}
@@ -147,6 +147,7 @@ func (f *FakeVZChecker) GetStatus() (time.Time, error) {
 
 type FakeVZInfo struct {
 	lastClusterName string
+	forceUpdateErr  error
 }
 
 func (f *FakeVZInfo) UpdateClusterIDAnnotation(string) error {
@@ -200,10 +201,37 @@ func (f *FakeVZInfo) GetJob(name string) (*batchv1.Job, error) {
 	return nil, nil
 }
 
-func (f *FakeVZInfo) GetClusterUID() (string, error) {
+func (f *FakeVZInfo) GetClusterUID(context.Context) (string, error) {
 	return "fake-uid", nil
 }
 
+func (f *FakeVZInfo) SelfTest(context.Context) *bridge.SelfTestReport {
+	return &bridge.SelfTestReport{}
+}
+
+func (f *FakeVZInfo) ForceUpdate(context.Context) (*bridge.K8sState, error) {
+	if f.forceUpdateErr != nil {
+		return nil, f.forceUpdateErr
+	}
+	state := f.GetK8sState()
+	state.ControlPlanePods = []*vizierpb.VizierPodStatus{{Name: "pl/vizier-query-broker"}}
+	state.DataPlanePods = []*vizierpb.VizierPodStatus{{Name: "pl/vizier-pem-abcde"}}
+	return state, nil
+}
+
+func (f *FakeVZInfo) GetPodStatusChangesSince(revision int64) *bridge.PodStatusDelta {
+	return &bridge.PodStatusDelta{
+		FromRevision: revision,
+		ToRevision:   1,
+		Resync:       true,
+		Updated:      f.GetK8sState().ControlPlanePodStatuses,
+	}
+}
+
+func (f *FakeVZInfo) RecordStatusDelivered(time.Time) {}
+
+func (f *FakeVZInfo) RecordConnected(time.Time) {}
+
 func (f *FakeVZInfo) UpdateClusterID(string) error {
 	return nil
 }
@@ -494,3 +522,52 @@ func TestNATSGRPCBridgeTest_TestRegisterDeployment(t *testing.T) {
 		assert.Equal(t, "fakeName", vzInfo.lastClusterName)
 	}()
 }
+
+type fakeDebugPodsServer struct {
+	grpc.ServerStream
+	ctx   context.Context
+	resps []*vizierpb.DebugPodsResponse
+}
+
+func (f *fakeDebugPodsServer) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeDebugPodsServer) Send(resp *vizierpb.DebugPodsResponse) error {
+	f.resps = append(f.resps, resp)
+	return nil
+}
+
+func TestDebugPods(t *testing.T) {
+	tests := []struct {
+		name             string
+		forceUpdateErr   error
+		expectedCtrlPods []*vizierpb.VizierPodStatus
+		expectedDataPods []*vizierpb.VizierPodStatus
+	}{
+		{
+			name:             "fresh state",
+			expectedCtrlPods: []*vizierpb.VizierPodStatus{{Name: "pl/vizier-query-broker"}},
+			expectedDataPods: []*vizierpb.VizierPodStatus{{Name: "pl/vizier-pem-abcde"}},
+		},
+		{
+			name:             "update times out",
+			forceUpdateErr:   context.DeadlineExceeded,
+			expectedCtrlPods: []*vizierpb.VizierPodStatus{{Name: "Another pod"}},
+			expectedDataPods: []*vizierpb.VizierPodStatus{{Name: "A pod"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vzInfo := &FakeVZInfo{forceUpdateErr: test.forceUpdateErr}
+			b := bridge.New(uuid.Nil, "", "", "", 0, nil, vzInfo, &FakeVZOperatorInfo{}, nil, &FakeVZChecker{}, nil)
+			srv := &fakeDebugPodsServer{ctx: context.Background()}
+
+			require.NoError(t, b.DebugPods(&vizierpb.DebugPodsRequest{}, srv))
+			require.Len(t, srv.resps, 1)
+			assert.Equal(t, test.expectedCtrlPods, srv.resps[0].ControlPlanePods)
+			assert.Equal(t, test.expectedDataPods, srv.resps[0].DataPlanePods)
+		})
+	}
+}
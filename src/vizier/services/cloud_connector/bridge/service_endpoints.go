@@ -0,0 +1,164 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ServiceEndpoints describes how many of the addresses backing a service in the Vizier namespace are ready.
+type ServiceEndpoints struct {
+	Service  string
+	Ready    int32
+	NotReady int32
+}
+
+// countEndpointSliceAddresses counts the ready and not ready addresses in the EndpointSlices of each service,
+// keyed by service name. Endpoints that don't report whether they are ready are considered ready.
+func countEndpointSliceAddresses(slices []discoveryv1.EndpointSlice) map[string]*ServiceEndpoints {
+	counts := make(map[string]*ServiceEndpoints)
+	for _, slice := range slices {
+		name := slice.Labels[discoveryv1.LabelServiceName]
+		if name == "" {
+			continue
+		}
+		c, ok := counts[name]
+		if !ok {
+			c = &ServiceEndpoints{Service: name}
+			counts[name] = c
+		}
+		for _, e := range slice.Endpoints {
+			n := int32(len(e.Addresses))
+			if e.Conditions.Ready == nil || *e.Conditions.Ready {
+				c.Ready += n
+			} else {
+				c.NotReady += n
+			}
+		}
+	}
+	return counts
+}
+
+// countEndpointsAddresses counts the ready and not ready addresses in the Endpoints of each service, keyed by
+// service name.
+func countEndpointsAddresses(endpoints []corev1.Endpoints) map[string]*ServiceEndpoints {
+	counts := make(map[string]*ServiceEndpoints)
+	for _, ep := range endpoints {
+		c := &ServiceEndpoints{Service: ep.Name}
+		for _, subset := range ep.Subsets {
+			c.Ready += int32(len(subset.Addresses))
+			c.NotReady += int32(len(subset.NotReadyAddresses))
+		}
+		counts[ep.Name] = c
+	}
+	return counts
+}
+
+// countServiceAddresses counts the ready and not ready addresses of each service in the Vizier namespace, from
+// its EndpointSlices. Clusters that are too old to serve EndpointSlices fall back to Endpoints.
+func (v *K8sVizierInfo) countServiceAddresses(ctx context.Context) (map[string]*ServiceEndpoints, error) {
+	slices, err := v.clientset.DiscoveryV1().EndpointSlices(v.ns).List(ctx, metav1.ListOptions{})
+	if err == nil {
+		return countEndpointSliceAddresses(slices.Items), nil
+	}
+	if !k8sErrors.IsNotFound(err) {
+		recordK8sAPIError("endpointslices")
+		return nil, err
+	}
+
+	endpoints, err := v.clientset.CoreV1().Endpoints(v.ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		recordK8sAPIError("endpoints")
+		return nil, err
+	}
+	return countEndpointsAddresses(endpoints.Items), nil
+}
+
+// getServiceEndpoints gets how many of the addresses backing each service in the Vizier namespace are ready,
+// sorted by service name. Services without a selector don't have their endpoints managed by K8s, so they are
+// left out. This collector is optional: if a list fails, for example due to missing RBAC, the services are
+// left out.
+func (v *K8sVizierInfo) getServiceEndpoints(ctx context.Context) []*ServiceEndpoints {
+	if !v.collectorAvailable(collectorServiceEndpoints) {
+		return nil
+	}
+	services, err := v.clientset.CoreV1().Services(v.ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		recordK8sAPIError("services")
+		log.WithError(err).Warn("Failed to list services, leaving their endpoints out of the K8s state")
+		return nil
+	}
+	counts, err := v.countServiceAddresses(ctx)
+	if err != nil {
+		log.WithError(err).Warn("Failed to list service endpoints, leaving them out of the K8s state")
+		return nil
+	}
+
+	var serviceEndpoints []*ServiceEndpoints
+	for _, svc := range services.Items {
+		if len(svc.Spec.Selector) == 0 || svc.Spec.Type == corev1.ServiceTypeExternalName {
+			continue
+		}
+		c, ok := counts[svc.Name]
+		if !ok {
+			c = &ServiceEndpoints{Service: svc.Name}
+		}
+		serviceEndpoints = append(serviceEndpoints, c)
+	}
+	sort.Slice(serviceEndpoints, func(i, j int) bool {
+		return serviceEndpoints[i].Service < serviceEndpoints[j].Service
+	})
+	return serviceEndpoints
+}
+
+func copyServiceEndpoints(serviceEndpoints []*ServiceEndpoints) []*ServiceEndpoints {
+	if serviceEndpoints == nil {
+		return nil
+	}
+	clone := make([]*ServiceEndpoints, len(serviceEndpoints))
+	for i, e := range serviceEndpoints {
+		c := *e
+		clone[i] = &c
+	}
+	return clone
+}
+
+// A service with no ready endpoints can't be reached, even if the pods behind it look healthy, for example
+// because of a selector typo. This makes the Vizier unhealthy.
+func checkServiceEndpoints(s *K8sState) (VizierHealth, []string) {
+	var reasons []string
+	for _, e := range s.ServiceEndpoints {
+		if e.Ready > 0 {
+			continue
+		}
+		reasons = append(reasons, fmt.Sprintf("service %s has no ready endpoints (%d not ready)", e.Service, e.NotReady))
+	}
+	if len(reasons) == 0 {
+		return VizierHealthHealthy, nil
+	}
+	return VizierHealthUnhealthy, reasons
+}
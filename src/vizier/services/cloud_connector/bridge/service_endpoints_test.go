@@ -0,0 +1,142 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func makeService(name string, selector map[string]string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Spec:       corev1.ServiceSpec{Selector: selector},
+	}
+}
+
+func makeEndpointSlice(name, service string, ready ...*bool) *discoveryv1.EndpointSlice {
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+			Labels:    map[string]string{discoveryv1.LabelServiceName: service},
+		},
+	}
+	for _, r := range ready {
+		slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{
+			Addresses:  []string{"10.0.0.1"},
+			Conditions: discoveryv1.EndpointConditions{Ready: r},
+		})
+	}
+	return slice
+}
+
+func TestGetServiceEndpoints_EndpointSlices(t *testing.T) {
+	ready, notReady := true, false
+	vzInfo := &K8sVizierInfo{
+		ns: testNamespace,
+		clientset: fake.NewSimpleClientset(
+			makeService("vizier-query-broker-svc", map[string]string{"name": "vizier-query-broker"}),
+			makeService("vizier-metadata-svc", map[string]string{"name": "vizier-metadata"}),
+			makeService("pl-nats", map[string]string{"name": "pl-nats"}),
+			// Services without a selector have their endpoints managed by something else.
+			makeService("kelvin-external", nil),
+			makeEndpointSlice("vizier-query-broker-svc-abcde", "vizier-query-broker-svc", &ready, nil),
+			makeEndpointSlice("vizier-query-broker-svc-fghij", "vizier-query-broker-svc", &notReady),
+			makeEndpointSlice("vizier-metadata-svc-abcde", "vizier-metadata-svc", &notReady, &notReady),
+		),
+	}
+
+	assert.Equal(t, []*ServiceEndpoints{
+		{Service: "pl-nats"},
+		{Service: "vizier-metadata-svc", NotReady: 2},
+		{Service: "vizier-query-broker-svc", Ready: 2, NotReady: 1},
+	}, vzInfo.getServiceEndpoints(context.Background()))
+}
+
+func TestGetServiceEndpoints_FallsBackToEndpoints(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		makeService("vizier-query-broker-svc", map[string]string{"name": "vizier-query-broker"}),
+		&corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Name: "vizier-query-broker-svc", Namespace: testNamespace},
+			Subsets: []corev1.EndpointSubset{
+				{
+					Addresses:         []corev1.EndpointAddress{{IP: "10.0.0.1"}},
+					NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.0.0.2"}, {IP: "10.0.0.3"}},
+				},
+			},
+		},
+	)
+	// Clusters that are too old to serve EndpointSlices don't have the resource at all.
+	clientset.PrependReactor("list", "endpointslices", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewNotFound(schema.GroupResource{Group: "discovery.k8s.io", Resource: "endpointslices"}, "")
+	})
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	assert.Equal(t, []*ServiceEndpoints{
+		{Service: "vizier-query-broker-svc", Ready: 1, NotReady: 2},
+	}, vzInfo.getServiceEndpoints(context.Background()))
+}
+
+func TestGetServiceEndpoints_Forbidden(t *testing.T) {
+	clientset := fake.NewSimpleClientset(makeService("vizier-query-broker-svc", map[string]string{"name": "vizier-query-broker"}))
+	clientset.PrependReactor("list", "endpointslices", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewForbidden(schema.GroupResource{Group: "discovery.k8s.io", Resource: "endpointslices"}, "", nil)
+	})
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	assert.Nil(t, vzInfo.getServiceEndpoints(context.Background()))
+}
+
+func TestCheckServiceEndpoints(t *testing.T) {
+	health, reasons := checkServiceEndpoints(&K8sState{
+		ServiceEndpoints: []*ServiceEndpoints{
+			{Service: "pl-nats", Ready: 3},
+			{Service: "vizier-metadata-svc", NotReady: 2},
+			{Service: "vizier-query-broker-svc"},
+		},
+	})
+	assert.Equal(t, VizierHealthUnhealthy, health)
+	assert.Equal(t, []string{
+		"service vizier-metadata-svc has no ready endpoints (2 not ready)",
+		"service vizier-query-broker-svc has no ready endpoints (0 not ready)",
+	}, reasons)
+
+	health, reasons = checkServiceEndpoints(&K8sState{
+		ServiceEndpoints: []*ServiceEndpoints{{Service: "pl-nats", Ready: 3}},
+	})
+	assert.Equal(t, VizierHealthHealthy, health)
+	assert.Empty(t, reasons)
+}
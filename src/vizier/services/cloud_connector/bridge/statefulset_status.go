@@ -0,0 +1,142 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// How long a StatefulSet can have fewer ready replicas than desired before it affects the Vizier health. This
+// avoids flagging the normal restart of a pod, which briefly takes a single replica StatefulSet to zero.
+const statefulSetUnderReplicatedGracePeriod = 2 * time.Minute
+
+// StatefulSetStatus describes the status of a StatefulSet in the Vizier namespace, such as NATS or the
+// metadata store.
+type StatefulSetStatus struct {
+	Name string
+	// The number of desired replicas.
+	Replicas      int32
+	ReadyReplicas int32
+	// The revisions of the existing and desired pods. These differ while a rolling update is in progress.
+	CurrentRevision string
+	UpdateRevision  string
+	// When the StatefulSet last went from fully ready to having fewer ready replicas than desired. Unset if all
+	// of its replicas are ready.
+	UnderReplicatedSince time.Time
+}
+
+// UnderReplicated returns whether the StatefulSet has fewer ready replicas than desired.
+func (s *StatefulSetStatus) UnderReplicated() bool {
+	return s.ReadyReplicas < s.Replicas
+}
+
+// getStatefulSetStatuses gets the statuses of the StatefulSets in the Vizier namespace, sorted by name. Each
+// StatefulSet that was already under-replicated keeps the time it became under-replicated from the previous
+// statuses. This collector is optional: if the list fails, for example due to missing RBAC, the statuses are
+// left out.
+func (v *K8sVizierInfo) getStatefulSetStatuses(ctx context.Context, previous []*StatefulSetStatus, now time.Time) []*StatefulSetStatus {
+	if !v.collectorAvailable(collectorStatefulSets) {
+		return nil
+	}
+	statefulSets, err := v.clientset.AppsV1().StatefulSets(v.ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		recordK8sAPIError("statefulsets")
+		log.WithError(err).Warn("Failed to list statefulsets, leaving them out of the K8s state")
+		return nil
+	}
+
+	underReplicatedSince := make(map[string]time.Time, len(previous))
+	for _, s := range previous {
+		underReplicatedSince[s.Name] = s.UnderReplicatedSince
+	}
+
+	statuses := make([]*StatefulSetStatus, 0, len(statefulSets.Items))
+	for i := range statefulSets.Items {
+		ss := &statefulSets.Items[i]
+		// The desired replicas default to 1 when unset.
+		replicas := int32(1)
+		if ss.Spec.Replicas != nil {
+			replicas = *ss.Spec.Replicas
+		}
+		s := &StatefulSetStatus{
+			Name:            ss.Name,
+			Replicas:        replicas,
+			ReadyReplicas:   ss.Status.ReadyReplicas,
+			CurrentRevision: ss.Status.CurrentRevision,
+			UpdateRevision:  ss.Status.UpdateRevision,
+		}
+		if s.UnderReplicated() {
+			s.UnderReplicatedSince = underReplicatedSince[ss.Name]
+			if s.UnderReplicatedSince.IsZero() {
+				s.UnderReplicatedSince = now
+			}
+		}
+		statuses = append(statuses, s)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+func copyStatefulSetStatuses(statuses []*StatefulSetStatus) []*StatefulSetStatus {
+	if statuses == nil {
+		return nil
+	}
+	clone := make([]*StatefulSetStatus, len(statuses))
+	for i, s := range statuses {
+		c := *s
+		clone[i] = &c
+	}
+	return clone
+}
+
+// StatefulSets with fewer ready replicas than desired break messaging and metadata in ways the pod phases
+// don't show. One with no ready replicas makes the Vizier unhealthy, while one with only some of its
+// replicas ready degrades it. Either is only reported once it has lasted longer than the grace period.
+func checkStatefulSets(s *K8sState) (VizierHealth, []string) {
+	health := VizierHealthHealthy
+	var reasons []string
+	for _, ss := range s.StatefulSetStatuses {
+		if !ss.UnderReplicated() || ss.UnderReplicatedSince.IsZero() {
+			continue
+		}
+		underReplicatedFor := s.LastUpdated.Sub(ss.UnderReplicatedSince)
+		if underReplicatedFor < statefulSetUnderReplicatedGracePeriod {
+			continue
+		}
+		reason := fmt.Sprintf("%s %d/%d replicas ready for %s", ss.Name, ss.ReadyReplicas, ss.Replicas, underReplicatedFor.Round(time.Minute))
+		if ss.UpdateRevision != "" && ss.CurrentRevision != ss.UpdateRevision {
+			reason = fmt.Sprintf("%s (rolling update to %s in progress)", reason, ss.UpdateRevision)
+		}
+		reasons = append(reasons, reason)
+		if ss.ReadyReplicas == 0 {
+			health = VizierHealthUnhealthy
+		} else if health < VizierHealthDegraded {
+			health = VizierHealthDegraded
+		}
+	}
+	return health, reasons
+}
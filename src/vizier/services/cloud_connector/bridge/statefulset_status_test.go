@@ -0,0 +1,140 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func makeStatefulSet(name string, replicas, readyReplicas int32) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+		Status: appsv1.StatefulSetStatus{
+			ReadyReplicas:   readyReplicas,
+			CurrentRevision: name + "-1",
+			UpdateRevision:  name + "-1",
+		},
+	}
+}
+
+func TestGetStatefulSetStatuses(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	clientset := fake.NewSimpleClientset(
+		makeStatefulSet("vizier-metadata", 1, 1),
+		makeStatefulSet("pl-nats", 3, 1),
+	)
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	statuses := vzInfo.getStatefulSetStatuses(context.Background(), nil, now)
+	assert.Equal(t, []*StatefulSetStatus{
+		{
+			Name:                 "pl-nats",
+			Replicas:             3,
+			ReadyReplicas:        1,
+			CurrentRevision:      "pl-nats-1",
+			UpdateRevision:       "pl-nats-1",
+			UnderReplicatedSince: now,
+		},
+		{
+			Name:            "vizier-metadata",
+			Replicas:        1,
+			ReadyReplicas:   1,
+			CurrentRevision: "vizier-metadata-1",
+			UpdateRevision:  "vizier-metadata-1",
+		},
+	}, statuses)
+
+	// A StatefulSet that stays under-replicated keeps the time it became under-replicated.
+	later := now.Add(time.Minute)
+	statuses = vzInfo.getStatefulSetStatuses(context.Background(), statuses, later)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, now, statuses[0].UnderReplicatedSince)
+	assert.True(t, statuses[1].UnderReplicatedSince.IsZero())
+}
+
+func TestCheckStatefulSets(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name            string
+		statuses        []*StatefulSetStatus
+		expectedHealth  VizierHealth
+		expectedReasons []string
+	}{
+		{
+			name: "fully ready",
+			statuses: []*StatefulSetStatus{
+				{Name: "pl-nats", Replicas: 3, ReadyReplicas: 3},
+			},
+			expectedHealth: VizierHealthHealthy,
+		},
+		{
+			name: "single replica restarting within the grace period",
+			statuses: []*StatefulSetStatus{
+				{Name: "vizier-metadata", Replicas: 1, UnderReplicatedSince: now.Add(-30 * time.Second)},
+			},
+			expectedHealth: VizierHealthHealthy,
+		},
+		{
+			name: "partially ready",
+			statuses: []*StatefulSetStatus{
+				{Name: "pl-nats", Replicas: 3, ReadyReplicas: 1, UnderReplicatedSince: now.Add(-5 * time.Minute)},
+			},
+			expectedHealth:  VizierHealthDegraded,
+			expectedReasons: []string{"pl-nats 1/3 replicas ready for 5m0s"},
+		},
+		{
+			name: "no ready replicas during a rolling update",
+			statuses: []*StatefulSetStatus{
+				{Name: "pl-nats", Replicas: 3, ReadyReplicas: 2, UnderReplicatedSince: now.Add(-5 * time.Minute)},
+				{
+					Name:                 "vizier-metadata",
+					Replicas:             1,
+					CurrentRevision:      "vizier-metadata-1",
+					UpdateRevision:       "vizier-metadata-2",
+					UnderReplicatedSince: now.Add(-10 * time.Minute),
+				},
+			},
+			expectedHealth: VizierHealthUnhealthy,
+			expectedReasons: []string{
+				"pl-nats 2/3 replicas ready for 5m0s",
+				"vizier-metadata 0/1 replicas ready for 10m0s (rolling update to vizier-metadata-2 in progress)",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			health, reasons := checkStatefulSets(&K8sState{LastUpdated: now, StatefulSetStatuses: test.statuses})
+			assert.Equal(t, test.expectedHealth, health)
+			assert.Equal(t, test.expectedReasons, reasons)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	log "github.com/sirupsen/logrus"
+
+	"px.dev/pixie/src/shared/cvmsgspb"
+)
+
+const (
+	// HeartbeatAckTopic is the topic that cloud acknowledges heartbeats on.
+	HeartbeatAckTopic = "VizierHeartbeatAck"
+	// Heartbeats are sent every 5 seconds, so this many unacknowledged heartbeats means that acks are not
+	// reaching the Vizier, even if the heartbeats are reaching cloud.
+	undeliveredStatusThreshold = time.Minute
+)
+
+// RecordStatusDelivered records that cloud acknowledged a heartbeat at the given time.
+func (v *K8sVizierInfo) RecordStatusDelivered(t time.Time) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if t.Before(v.lastStatusDelivered) {
+		return
+	}
+	v.lastStatusDelivered = t
+	statusLastDeliveredGauge.Set(float64(t.Unix()))
+}
+
+// handleHeartbeatAck records the delivery of a heartbeat that cloud acknowledged at the given time.
+func (s *Bridge) handleHeartbeatAck(msg *types.Any, now time.Time) error {
+	ack := &cvmsgspb.VizierHeartbeatAck{}
+	if err := types.UnmarshalAny(msg, ack); err != nil {
+		return err
+	}
+	if ack.Status != cvmsgspb.HB_OK {
+		log.WithField("sequenceNumber", ack.SequenceNumber).WithField("error", ack.ErrorMessage).Warn("Cloud failed to process heartbeat")
+		return nil
+	}
+	atomic.StoreInt64(&s.lastHeartbeatAck, now.UnixNano())
+	s.vzInfo.RecordStatusDelivered(now)
+	if ack.PodStatusesResyncRequested {
+		log.WithField("sequenceNumber", ack.SequenceNumber).Info("Cloud requested the full pod statuses")
+		atomic.StoreInt32(&s.podStatusResyncRequested, 1)
+	}
+	return nil
+}
+
+// deliveryAge returns how long ago cloud last acknowledged a heartbeat. It returns false if cloud has not
+// acknowledged any heartbeats, such as when it doesn't send acks at all.
+func (s *Bridge) deliveryAge(now time.Time) (time.Duration, bool) {
+	acked := atomic.LoadInt64(&s.lastHeartbeatAck)
+	if acked == 0 {
+		return 0, false
+	}
+	return now.Sub(time.Unix(0, acked)), true
+}
+
+// undeliveredStatusMessage adds the delivery age to the heartbeat's status message, so that cloud can tell
+// its acks are not reaching the Vizier. The age is rounded to the minute to keep the message from changing
+// on every heartbeat.
+func undeliveredStatusMessage(msg string, age time.Duration) string {
+	undelivered := fmt.Sprintf("heartbeats not acknowledged by cloud for %s", age.Round(time.Minute))
+	if msg == "" {
+		return undelivered
+	}
+	return fmt.Sprintf("%s: %s", undelivered, msg)
+}
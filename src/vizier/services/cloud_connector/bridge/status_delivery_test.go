@@ -0,0 +1,122 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/shared/cvmsgspb"
+)
+
+func makeHeartbeatAck(t *testing.T, status cvmsgspb.VizierHeartbeatAck_HeartbeatStatus, seq int64) *types.Any {
+	msg, err := types.MarshalAny(&cvmsgspb.VizierHeartbeatAck{
+		Status:         status,
+		SequenceNumber: seq,
+	})
+	require.NoError(t, err)
+	return msg
+}
+
+func TestHandleHeartbeatAck(t *testing.T) {
+	vzInfo := &K8sVizierInfo{ns: testNamespace}
+	s := &Bridge{vzInfo: vzInfo}
+	now := time.Unix(1646136000, 0)
+
+	// Nothing has been acknowledged yet.
+	_, ok := s.deliveryAge(now)
+	assert.False(t, ok)
+
+	require.NoError(t, s.handleHeartbeatAck(makeHeartbeatAck(t, cvmsgspb.HB_OK, 1), now))
+	age, ok := s.deliveryAge(now.Add(10 * time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, 10*time.Second, age)
+	assert.Equal(t, now, vzInfo.GetCollectorStatus().LastDelivered)
+	assert.Equal(t, float64(now.Unix()), testutil.ToFloat64(statusLastDeliveredGauge))
+
+	// Heartbeats that cloud failed to process were not delivered.
+	require.NoError(t, s.handleHeartbeatAck(makeHeartbeatAck(t, cvmsgspb.HB_ERROR, 2), now.Add(5*time.Second)))
+	assert.Equal(t, now, vzInfo.GetCollectorStatus().LastDelivered)
+
+	assert.Error(t, s.handleHeartbeatAck(&types.Any{TypeUrl: "invalid"}, now))
+}
+
+func TestHandleHeartbeatAck_PodStatusesResync(t *testing.T) {
+	s := &Bridge{vzInfo: &K8sVizierInfo{ns: testNamespace}}
+	require.NoError(t, s.handleHeartbeatAck(makeHeartbeatAck(t, cvmsgspb.HB_OK, 1), time.Now()))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&s.podStatusResyncRequested))
+
+	// Cloud asks for the full pod statuses when it can't apply a delta.
+	msg, err := types.MarshalAny(&cvmsgspb.VizierHeartbeatAck{
+		Status:                     cvmsgspb.HB_OK,
+		SequenceNumber:             2,
+		PodStatusesResyncRequested: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.handleHeartbeatAck(msg, time.Now()))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&s.podStatusResyncRequested))
+}
+
+func TestHandleHeartbeatAck_AckLoss(t *testing.T) {
+	start := time.Now().Add(-10 * time.Minute)
+	vzInfo := &K8sVizierInfo{ns: testNamespace}
+	s := &Bridge{vzInfo: vzInfo}
+
+	require.NoError(t, s.handleHeartbeatAck(makeHeartbeatAck(t, cvmsgspb.HB_OK, 1), start))
+
+	// The K8s state keeps being collected, but none of the later acks arrive.
+	vzInfo.k8sStateLastUpdated = start.Add(9 * time.Minute)
+
+	status := vzInfo.GetCollectorStatus()
+	assert.Equal(t, start.Add(9*time.Minute), status.LastCollected)
+	assert.Equal(t, start, status.LastDelivered)
+
+	age, ok := s.deliveryAge(time.Now())
+	require.True(t, ok)
+	assert.Greater(t, age, undeliveredStatusThreshold)
+	assert.Equal(t, "heartbeats not acknowledged by cloud for 10m0s", undeliveredStatusMessage("", age))
+
+	// Once acks arrive again, the delivery catches up with the collection.
+	acked := time.Now()
+	require.NoError(t, s.handleHeartbeatAck(makeHeartbeatAck(t, cvmsgspb.HB_OK, 120), acked))
+	age, ok = s.deliveryAge(acked)
+	require.True(t, ok)
+	assert.Zero(t, age)
+	assert.Equal(t, acked, vzInfo.GetCollectorStatus().LastDelivered)
+}
+
+func TestRecordStatusDelivered_IgnoresOlderAcks(t *testing.T) {
+	vzInfo := &K8sVizierInfo{ns: testNamespace}
+	now := time.Unix(1646136000, 0)
+
+	vzInfo.RecordStatusDelivered(now)
+	vzInfo.RecordStatusDelivered(now.Add(-time.Minute))
+	assert.Equal(t, now, vzInfo.GetCollectorStatus().LastDelivered)
+}
+
+func TestUndeliveredStatusMessage(t *testing.T) {
+	assert.Equal(t, "heartbeats not acknowledged by cloud for 3m0s", undeliveredStatusMessage("", 2*time.Minute+40*time.Second))
+	assert.Equal(t, "heartbeats not acknowledged by cloud for 2m0s: PEM coverage 2/3", undeliveredStatusMessage("PEM coverage 2/3", 2*time.Minute+10*time.Second))
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"px.dev/pixie/src/operator/apis/px.dev/v1alpha1"
+)
+
+// The field manager used when applying the connector status, so that it never conflicts with the fields
+// of the status owned by the operator.
+const vizierCRDStatusFieldManager = "pl-cloud-connector"
+
+// The connector status is rewritten when it changes, or at least this often to refresh its timestamp.
+const vizierCRDStatusRefreshPeriod = time.Minute
+
+// buildVizierConnectorStatus summarizes the K8s state for the Vizier CRD status.
+func buildVizierConnectorStatus(state *K8sState) *v1alpha1.VizierConnectorStatus {
+	status := &v1alpha1.VizierConnectorStatus{
+		LastUpdated: &metav1.Time{Time: state.LastUpdated},
+	}
+	if state.VizierState != nil {
+		status.Health = state.VizierState.Health.String()
+		status.Reasons = state.VizierState.Reasons
+	}
+
+	podStatuses := mergePodStatuses(state.ControlPlanePodStatuses, state.UnhealthyDataPlanePodStatuses)
+	for _, name := range sortedPodNames(podStatuses) {
+		p := podStatuses[name]
+		c := v1alpha1.VizierComponentStatus{
+			Name:         name,
+			Phase:        p.Status.String(),
+			Reason:       podStatusReason(p),
+			RestartCount: p.RestartCount,
+		}
+		if u, ok := state.PodUptimes[name]; ok {
+			if !u.StartedAt.IsZero() {
+				c.StartTime = &metav1.Time{Time: u.StartedAt}
+			}
+			if !u.LastRestartedAt.IsZero() {
+				c.LastRestartTime = &metav1.Time{Time: u.LastRestartedAt}
+			}
+		}
+		status.Components = append(status.Components, c)
+	}
+	return status
+}
+
+// vizierCRDStatusPatch builds the server-side apply patch that sets the connector status on the given Vizier.
+func vizierCRDStatusPatch(vz *v1alpha1.Vizier, status *v1alpha1.VizierConnectorStatus) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"apiVersion": v1alpha1.SchemeGroupVersion.String(),
+		"kind":       "Vizier",
+		"metadata": map[string]interface{}{
+			"name":      vz.Name,
+			"namespace": vz.Namespace,
+		},
+		"status": map[string]interface{}{
+			"connectorStatus": status,
+		},
+	})
+}
+
+// connectorStatusChanged returns whether the two statuses differ in anything other than their timestamps.
+func connectorStatusChanged(prev, cur *v1alpha1.VizierConnectorStatus) bool {
+	if prev == nil {
+		return true
+	}
+	p, c := *prev, *cur
+	p.LastUpdated, c.LastUpdated = nil, nil
+	return !reflect.DeepEqual(p, c)
+}
+
+// writeVizierCRDStatus writes the K8s state into the status of the Vizier CRD. This is a no-op if there is
+// no Vizier CRD, such as when Vizier was not deployed by the operator. This is only called from UpdateK8sState.
+func (v *K8sVizierInfo) writeVizierCRDStatus(ctx context.Context, state *K8sState) {
+	status := buildVizierConnectorStatus(state)
+	if !connectorStatusChanged(v.lastCRDStatus, status) && state.LastUpdated.Sub(v.lastCRDStatusWrite) < vizierCRDStatusRefreshPeriod {
+		return
+	}
+
+	vz, err := v.GetVizierCRD()
+	if err != nil {
+		log.WithError(err).Trace("No Vizier CRD to write status to")
+		return
+	}
+
+	patch, err := vizierCRDStatusPatch(vz, status)
+	if err != nil {
+		log.WithError(err).Error("Failed to build Vizier CRD status patch")
+		return
+	}
+	force := true
+	_, err = v.vzClient.PxV1alpha1().Viziers(v.ns).Patch(ctx, vz.Name, types.ApplyPatchType, patch, metav1.PatchOptions{
+		FieldManager: vizierCRDStatusFieldManager,
+		Force:        &force,
+	}, "status")
+	if err != nil {
+		recordK8sAPIError("viziers")
+		log.WithError(err).Error("Failed to write Vizier CRD status")
+		return
+	}
+	v.lastCRDStatus = status
+	v.lastCRDStatusWrite = state.LastUpdated
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	k8stesting "k8s.io/client-go/testing"
+
+	"px.dev/pixie/src/operator/apis/px.dev/v1alpha1"
+	vzfake "px.dev/pixie/src/operator/client/versioned/fake"
+	"px.dev/pixie/src/shared/cvmsgspb"
+)
+
+func makeCRDStatusState(now time.Time) *K8sState {
+	state := &K8sState{
+		ControlPlanePodStatuses: map[string]*cvmsgspb.PodStatus{
+			"vizier-query-broker-0": runningPod("vizier-query-broker-0"),
+			"vizier-metadata-0":     crashingPod("vizier-metadata-0"),
+		},
+		UnhealthyDataPlanePodStatuses: map[string]*cvmsgspb.PodStatus{
+			"vizier-pem-abcde": pendingPod("vizier-pem-abcde"),
+		},
+		NumNodes:             2,
+		NumInstrumentedNodes: 1,
+		LastUpdated:          now,
+	}
+	state.VizierState = computeVizierState(state)
+	return state
+}
+
+func TestBuildVizierConnectorStatus(t *testing.T) {
+	now := time.Now()
+	status := buildVizierConnectorStatus(makeCRDStatusState(now))
+
+	assert.Equal(t, &v1alpha1.VizierConnectorStatus{
+		Health: "Unhealthy",
+		Reasons: []string{
+			"vizier-metadata-0 CrashLoopBackOff",
+			"vizier-pem-abcde PENDING",
+			"PEM coverage 1/2",
+		},
+		Components: []v1alpha1.VizierComponentStatus{
+			{Name: "vizier-metadata-0", Phase: "RUNNING", Reason: "CrashLoopBackOff"},
+			{Name: "vizier-pem-abcde", Phase: "PENDING"},
+			{Name: "vizier-query-broker-0", Phase: "RUNNING"},
+		},
+		LastUpdated: &metav1.Time{Time: now},
+	}, status)
+}
+
+func TestBuildVizierConnectorStatus_StartTimes(t *testing.T) {
+	now := time.Now()
+	state := makeCRDStatusState(now)
+	state.PodUptimes = map[string]*PodUptime{
+		"vizier-metadata-0":     {StartedAt: now.Add(-time.Hour), LastRestartedAt: now.Add(-time.Minute), RestartCount: 5},
+		"vizier-query-broker-0": {StartedAt: now.Add(-time.Hour)},
+		"vizier-pem-abcde":      {},
+	}
+
+	status := buildVizierConnectorStatus(state)
+	require.Len(t, status.Components, 3)
+	assert.Equal(t, &metav1.Time{Time: now.Add(-time.Hour)}, status.Components[0].StartTime)
+	assert.Equal(t, &metav1.Time{Time: now.Add(-time.Minute)}, status.Components[0].LastRestartTime)
+	assert.Nil(t, status.Components[1].StartTime)
+	assert.Nil(t, status.Components[1].LastRestartTime)
+	assert.Equal(t, &metav1.Time{Time: now.Add(-time.Hour)}, status.Components[2].StartTime)
+	assert.Nil(t, status.Components[2].LastRestartTime)
+}
+
+func TestWriteVizierCRDStatus(t *testing.T) {
+	vz := &v1alpha1.Vizier{
+		ObjectMeta: metav1.ObjectMeta{Name: "pixie", Namespace: testNamespace},
+	}
+	vzClient := vzfake.NewSimpleClientset(vz)
+	var patches []k8stesting.PatchAction
+	vzClient.PrependReactor("patch", "viziers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patches = append(patches, action.(k8stesting.PatchAction))
+		return true, vz, nil
+	})
+	vzInfo := &K8sVizierInfo{
+		ns:       testNamespace,
+		vzClient: vzClient,
+	}
+
+	now := time.Now()
+	vzInfo.writeVizierCRDStatus(context.Background(), makeCRDStatusState(now))
+	require.Len(t, patches, 1)
+	assert.Equal(t, types.ApplyPatchType, patches[0].GetPatchType())
+	assert.Equal(t, "status", patches[0].GetSubresource())
+	assert.Equal(t, "pixie", patches[0].GetName())
+	assert.Contains(t, string(patches[0].GetPatch()), `"connectorStatus":{"health":"Unhealthy"`)
+	assert.NotContains(t, string(patches[0].GetPatch()), "vizierPhase")
+
+	// An unchanged status should not be rewritten until the refresh period has passed.
+	vzInfo.writeVizierCRDStatus(context.Background(), makeCRDStatusState(now.Add(10*time.Second)))
+	assert.Len(t, patches, 1)
+	vzInfo.writeVizierCRDStatus(context.Background(), makeCRDStatusState(now.Add(vizierCRDStatusRefreshPeriod)))
+	assert.Len(t, patches, 2)
+
+	// A changed status should be written immediately.
+	state := makeCRDStatusState(now.Add(vizierCRDStatusRefreshPeriod + 10*time.Second))
+	state.NumInstrumentedNodes = 2
+	state.VizierState = computeVizierState(state)
+	vzInfo.writeVizierCRDStatus(context.Background(), state)
+	assert.Len(t, patches, 3)
+}
+
+func TestWriteVizierCRDStatus_NoCRD(t *testing.T) {
+	vzClient := vzfake.NewSimpleClientset()
+	vzInfo := &K8sVizierInfo{
+		ns:       testNamespace,
+		vzClient: vzClient,
+	}
+
+	vzInfo.writeVizierCRDStatus(context.Background(), makeCRDStatusState(time.Now()))
+	for _, action := range vzClient.Actions() {
+		assert.NotEqual(t, "patch", action.GetVerb())
+	}
+}
@@ -0,0 +1,303 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"px.dev/pixie/src/shared/cvmsgspb"
+	"px.dev/pixie/src/shared/k8s/metadatapb"
+)
+
+// VizierHealth is the aggregate health of the Vizier, as derived from its K8s state.
+type VizierHealth int
+
+const (
+	// VizierHealthUnknown indicates that the K8s state has not been collected yet.
+	VizierHealthUnknown VizierHealth = iota
+	// VizierHealthHealthy indicates that all of the Vizier components are running.
+	VizierHealthHealthy
+	// VizierHealthDegraded indicates that the Vizier is queryable, but data may be missing.
+	VizierHealthDegraded
+	// VizierHealthUnhealthy indicates that a component required to query the Vizier is not running.
+	VizierHealthUnhealthy
+)
+
+func (h VizierHealth) String() string {
+	switch h {
+	case VizierHealthHealthy:
+		return "Healthy"
+	case VizierHealthDegraded:
+		return "Degraded"
+	case VizierHealthUnhealthy:
+		return "Unhealthy"
+	default:
+		return "Unknown"
+	}
+}
+
+// VizierState is the aggregate health of the Vizier, along with the reasons for that health.
+type VizierState struct {
+	Health VizierHealth
+	// Reasons describes why the Vizier is not healthy. Ex: "kelvin-5f7b6d4c9-abcde CrashLoopBackOff".
+	Reasons []string
+}
+
+// Message returns a human-readable summary of the reasons for the current health.
+func (s *VizierState) Message() string {
+	return strings.Join(s.Reasons, ", ")
+}
+
+// A vizierStateRule inspects part of the K8s state and returns the health it implies, along with the
+// reasons for any health other than VizierHealthHealthy.
+type vizierStateRule struct {
+	name  string
+	check func(*K8sState) (VizierHealth, []string)
+	// Informational rules always report their reasons, and never affect the aggregate health.
+	informational bool
+}
+
+// vizierStateRules are the rules used to reduce the K8s state into the aggregate Vizier health. The
+// aggregate health is the worst health returned by any of the rules.
+var vizierStateRules = []vizierStateRule{
+	{name: "control plane pods", check: checkControlPlanePods},
+	{name: "data plane pods", check: checkDataPlanePods},
+	{name: "pod readiness", check: checkPodReadiness},
+	{name: "OOMKills", check: checkRecentOOMKills},
+	{name: "PEM coverage", check: checkPEMCoverage},
+	{name: "StatefulSets", check: checkStatefulSets},
+	{name: "service endpoints", check: checkServiceEndpoints},
+	{name: "jobs", check: checkJobs},
+	{name: "version skew", check: checkVersionSkew},
+	{name: "TLS certs", check: checkCertExpiries},
+	{name: "secrets", check: checkSecrets},
+	{name: "resource quotas", check: checkResourceQuotas},
+	{name: "kernel versions", check: checkKernelVersions},
+	{name: "image pulls", check: checkImagePullFailures, informational: true},
+	{name: "metrics server", check: checkMetricsServer, informational: true},
+	{name: "recent restarts", check: checkRecentRestarts, informational: true},
+	{name: "unavailable collectors", check: checkUnavailableCollectors, informational: true},
+}
+
+// computeVizierState reduces the given K8s state into the aggregate Vizier health.
+func computeVizierState(s *K8sState) *VizierState {
+	if s == nil || s.LastUpdated.IsZero() {
+		return &VizierState{
+			Health:  VizierHealthUnknown,
+			Reasons: []string{"K8s state has not been collected yet"},
+		}
+	}
+
+	state := &VizierState{
+		Health:  VizierHealthHealthy,
+		Reasons: make([]string, 0),
+	}
+	for _, rule := range vizierStateRules {
+		health, reasons := rule.check(s)
+		if rule.informational {
+			state.Reasons = append(state.Reasons, reasons...)
+			continue
+		}
+		if health > state.Health {
+			state.Health = health
+		}
+		if health != VizierHealthHealthy {
+			state.Reasons = append(state.Reasons, reasons...)
+		}
+	}
+	return state
+}
+
+// getPodProblem returns a short description of why the pod is not healthy, or an empty string if it is.
+func getPodProblem(s *K8sState, p *cvmsgspb.PodStatus) string {
+	problem := getPodProblemReason(s, p)
+	if problem == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s %s", p.Name, problem)
+}
+
+// getPodProblemReason returns why the pod is not healthy, without the pod name, or an empty string if it is.
+func getPodProblemReason(s *K8sState, p *cvmsgspb.PodStatus) string {
+	// Unschedulable pods are pending until the scheduler's problem is fixed, so say what it is, and for how long.
+	if p.Reason == corev1.PodReasonUnschedulable {
+		problem := p.Reason
+		if since, ok := s.UnschedulableSince[p.Name]; ok && !since.IsZero() {
+			problem = fmt.Sprintf("%s for %s", problem, s.LastUpdated.Sub(since).Round(time.Minute))
+		}
+		if p.StatusMessage != "" {
+			problem = fmt.Sprintf("%s: %s", problem, p.StatusMessage)
+		}
+		return problem
+	}
+	// Terminating pods are expected to go away, unless they are stuck.
+	if p.Reason == podReasonTerminating {
+		return ""
+	}
+	if p.Reason == podReasonStuckTerminating {
+		return fmt.Sprintf("%s: %s", p.Reason, p.StatusMessage)
+	}
+	// A container failing to start makes the pod unhealthy, regardless of the pod phase.
+	if p.Reason != "" {
+		return p.Reason
+	}
+	for _, c := range p.Containers {
+		if c.State == metadatapb.CONTAINER_STATE_WAITING && c.Reason != "" {
+			return c.Reason
+		}
+	}
+	if p.Status == metadatapb.RUNNING || p.Status == metadatapb.SUCCEEDED {
+		return ""
+	}
+	if p.StatusMessage != "" {
+		return p.StatusMessage
+	}
+	return p.Status.String()
+}
+
+func sortedPodNames(pods map[string]*cvmsgspb.PodStatus) []string {
+	names := make([]string, 0, len(pods))
+	for name := range pods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Any control plane pod that is not running makes the Vizier unhealthy. Pods in other namespaces, such as the
+// operator, are keyed by namespace and don't serve queries, so they only degrade it.
+func checkControlPlanePods(s *K8sState) (VizierHealth, []string) {
+	health := VizierHealthHealthy
+	var problems []podProblem
+	for _, name := range sortedPodNames(s.ControlPlanePodStatuses) {
+		p := s.ControlPlanePodStatuses[name]
+		problem := getPodProblemReason(s, p)
+		if problem == "" {
+			continue
+		}
+		// Image pull failures are reported once per registry by checkImagePullFailures.
+		if !imagePullFailureReasons[p.Reason] {
+			problems = append(problems, podProblem{key: name, pod: p.Name, desc: problem})
+		}
+		if !strings.Contains(name, "/") {
+			health = VizierHealthUnhealthy
+		} else if health < VizierHealthDegraded {
+			health = VizierHealthDegraded
+		}
+	}
+	return health, describePodProblems(s.PodOwners, problems)
+}
+
+// An unhealthy Kelvin makes the Vizier unhealthy, while unhealthy PEMs only degrade it.
+func checkDataPlanePods(s *K8sState) (VizierHealth, []string) {
+	health := VizierHealthHealthy
+	var problems []podProblem
+	for _, name := range sortedPodNames(s.UnhealthyDataPlanePodStatuses) {
+		p := s.UnhealthyDataPlanePodStatuses[name]
+		problem := getPodProblemReason(s, p)
+		if problem == "" {
+			continue
+		}
+		if !imagePullFailureReasons[p.Reason] {
+			problems = append(problems, podProblem{key: name, pod: p.Name, desc: problem})
+		}
+		if strings.HasPrefix(name, "kelvin") {
+			health = VizierHealthUnhealthy
+		} else if health < VizierHealthDegraded {
+			health = VizierHealthDegraded
+		}
+	}
+	return health, describePodProblems(s.PodOwners, problems)
+}
+
+// Nodes without a running PEM degrade the Vizier. If no nodes have a running PEM, there is no data to query.
+func checkPEMCoverage(s *K8sState) (VizierHealth, []string) {
+	if s.NumNodes == 0 || s.NumInstrumentedNodes >= s.NumNodes {
+		return VizierHealthHealthy, nil
+	}
+	reason := fmt.Sprintf("PEM coverage %d/%d", s.NumInstrumentedNodes, s.NumNodes)
+	if nodes := describeUninstrumentedNodes(s.NodeStatuses); nodes != "" {
+		reason = fmt.Sprintf("%s, uninstrumented nodes: %s", reason, nodes)
+	}
+	reasons := []string{reason}
+	if s.NumInstrumentedNodes == 0 {
+		return VizierHealthUnhealthy, reasons
+	}
+	return VizierHealthDegraded, reasons
+}
+
+// Vizier can't become healthy without its certs, so a failed cert provisioner job makes it unhealthy.
+func checkJobs(s *K8sState) (VizierHealth, []string) {
+	for _, j := range s.JobStatuses {
+		if j.Kind == jobKind && j.Name == certProvisionerJobName && j.FailureMessage != "" {
+			return VizierHealthUnhealthy, []string{fmt.Sprintf("%s %s", j.Name, j.FailureMessage)}
+		}
+	}
+	return VizierHealthHealthy, nil
+}
+
+// Components running mixed versions, such as after a partial upgrade, may not interoperate correctly.
+func checkVersionSkew(s *K8sState) (VizierHealth, []string) {
+	versions := getPixieImageVersions(s.PodImages)
+	if len(versions) <= 1 {
+		return VizierHealthHealthy, nil
+	}
+	return VizierHealthDegraded, []string{fmt.Sprintf("version skew detected: %s", strings.Join(versions, ", "))}
+}
+
+// Expired certs break communication between the Vizier components. Certs whose expiry is unknown are
+// reported alongside any other cert problems, but do not affect the health on their own.
+func checkCertExpiries(s *K8sState) (VizierHealth, []string) {
+	health := VizierHealthHealthy
+	var reasons []string
+	for _, c := range s.CertExpiries {
+		switch c.State {
+		case CertExpiryExpired:
+			health = VizierHealthUnhealthy
+			reasons = append(reasons, fmt.Sprintf("TLS cert %s expired %d days ago", c.Name(), -c.DaysUntilExpiry))
+		case CertExpiryExpiring:
+			if health < VizierHealthDegraded {
+				health = VizierHealthDegraded
+			}
+			reasons = append(reasons, fmt.Sprintf("TLS cert %s expires in %d days", c.Name(), c.DaysUntilExpiry))
+		case CertExpiryUnknown:
+			reasons = append(reasons, fmt.Sprintf("TLS cert %s expiry unknown: %s", c.Name(), c.UnknownReason))
+		}
+	}
+	return health, reasons
+}
+
+// Pods that would exceed a quota are rejected, so Vizier pods may fail to be created or restarted.
+func checkResourceQuotas(s *K8sState) (VizierHealth, []string) {
+	var reasons []string
+	for _, u := range s.ResourceQuotaUsages {
+		if u.UsedFraction > resourceQuotaUsageWarningFraction {
+			reasons = append(reasons, fmt.Sprintf("resource quota %s has used %s of %s %s", u.Quota, u.Used, u.Hard, u.Resource))
+		}
+	}
+	if len(reasons) == 0 {
+		return VizierHealthHealthy, nil
+	}
+	return VizierHealthDegraded, reasons
+}
@@ -0,0 +1,177 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"px.dev/pixie/src/shared/cvmsgspb"
+	"px.dev/pixie/src/shared/k8s/metadatapb"
+)
+
+func runningPod(name string) *cvmsgspb.PodStatus {
+	return &cvmsgspb.PodStatus{
+		Name:   name,
+		Status: metadatapb.RUNNING,
+		Containers: []*cvmsgspb.ContainerStatus{
+			{Name: "app", State: metadatapb.CONTAINER_STATE_RUNNING},
+		},
+	}
+}
+
+func crashingPod(name string) *cvmsgspb.PodStatus {
+	return &cvmsgspb.PodStatus{
+		Name:   name,
+		Status: metadatapb.RUNNING,
+		Containers: []*cvmsgspb.ContainerStatus{
+			{Name: "app", State: metadatapb.CONTAINER_STATE_WAITING, Reason: "CrashLoopBackOff"},
+		},
+	}
+}
+
+func pendingPod(name string) *cvmsgspb.PodStatus {
+	return &cvmsgspb.PodStatus{
+		Name:   name,
+		Status: metadatapb.PENDING,
+	}
+}
+
+func TestComputeVizierState(t *testing.T) {
+	tests := []struct {
+		name            string
+		state           *K8sState
+		expectedHealth  VizierHealth
+		expectedReasons []string
+	}{
+		{
+			name:            "not yet collected",
+			state:           &K8sState{},
+			expectedHealth:  VizierHealthUnknown,
+			expectedReasons: []string{"K8s state has not been collected yet"},
+		},
+		{
+			name: "healthy",
+			state: &K8sState{
+				ControlPlanePodStatuses: map[string]*cvmsgspb.PodStatus{
+					"vizier-metadata-0":           runningPod("vizier-metadata-0"),
+					"vizier-query-broker-abcdefg": runningPod("vizier-query-broker-abcdefg"),
+				},
+				NumNodes:             3,
+				NumInstrumentedNodes: 3,
+			},
+			expectedHealth:  VizierHealthHealthy,
+			expectedReasons: []string{},
+		},
+		{
+			name: "control plane pod pending",
+			state: &K8sState{
+				ControlPlanePodStatuses: map[string]*cvmsgspb.PodStatus{
+					"vizier-metadata-0":           pendingPod("vizier-metadata-0"),
+					"vizier-query-broker-abcdefg": runningPod("vizier-query-broker-abcdefg"),
+				},
+				NumNodes:             3,
+				NumInstrumentedNodes: 3,
+			},
+			expectedHealth:  VizierHealthUnhealthy,
+			expectedReasons: []string{"vizier-metadata-0 PENDING"},
+		},
+		{
+			name: "kelvin crashlooping",
+			state: &K8sState{
+				UnhealthyDataPlanePodStatuses: map[string]*cvmsgspb.PodStatus{
+					"kelvin-abcdefg": crashingPod("kelvin-abcdefg"),
+				},
+				NumNodes:             3,
+				NumInstrumentedNodes: 3,
+			},
+			expectedHealth:  VizierHealthUnhealthy,
+			expectedReasons: []string{"kelvin-abcdefg CrashLoopBackOff"},
+		},
+		{
+			name: "partial PEM coverage",
+			state: &K8sState{
+				UnhealthyDataPlanePodStatuses: map[string]*cvmsgspb.PodStatus{
+					"vizier-pem-abcde": crashingPod("vizier-pem-abcde"),
+				},
+				NumNodes:             10,
+				NumInstrumentedNodes: 7,
+			},
+			expectedHealth:  VizierHealthDegraded,
+			expectedReasons: []string{"vizier-pem-abcde CrashLoopBackOff", "PEM coverage 7/10"},
+		},
+		{
+			name: "no PEM coverage",
+			state: &K8sState{
+				NumNodes:             10,
+				NumInstrumentedNodes: 0,
+			},
+			expectedHealth:  VizierHealthUnhealthy,
+			expectedReasons: []string{"PEM coverage 0/10"},
+		},
+		{
+			name: "cert provisioner failed",
+			state: &K8sState{
+				JobStatuses: []*JobStatus{
+					{Name: "cert-provisioner-job", Kind: "Job", Failed: 6, FailureMessage: "BackoffLimitExceeded"},
+					{Name: "vizier-upgrade-job", Kind: "Job", Failed: 1, FailureMessage: "DeadlineExceeded"},
+				},
+				NumNodes:             3,
+				NumInstrumentedNodes: 3,
+			},
+			expectedHealth:  VizierHealthUnhealthy,
+			expectedReasons: []string{"cert-provisioner-job BackoffLimitExceeded"},
+		},
+		{
+			name: "worst health wins",
+			state: &K8sState{
+				ControlPlanePodStatuses: map[string]*cvmsgspb.PodStatus{
+					"vizier-query-broker-abcdefg": crashingPod("vizier-query-broker-abcdefg"),
+				},
+				NumNodes:             10,
+				NumInstrumentedNodes: 9,
+			},
+			expectedHealth:  VizierHealthUnhealthy,
+			expectedReasons: []string{"vizier-query-broker-abcdefg CrashLoopBackOff", "PEM coverage 9/10"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.expectedHealth != VizierHealthUnknown {
+				test.state.LastUpdated = time.Now()
+			}
+			state := computeVizierState(test.state)
+			assert.Equal(t, test.expectedHealth, state.Health)
+			assert.Equal(t, test.expectedReasons, state.Reasons)
+		})
+	}
+}
+
+func TestVizierStateRules_EmptyStateIsHealthy(t *testing.T) {
+	for _, rule := range vizierStateRules {
+		t.Run(rule.name, func(t *testing.T) {
+			health, reasons := rule.check(&K8sState{LastUpdated: time.Now()})
+			assert.Equal(t, VizierHealthHealthy, health)
+			assert.Empty(t, reasons)
+		})
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"sort"
 	"strings"
 	"sync"
@@ -30,15 +31,20 @@ import (
 	"github.com/blang/semver"
 	"github.com/gogo/protobuf/types"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"golang.org/x/sync/singleflight"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
-	"k8s.io/client-go/discovery"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
 
 	"px.dev/pixie/src/api/proto/vizierpb"
 	"px.dev/pixie/src/operator/apis/px.dev/v1alpha1"
@@ -50,8 +56,40 @@ import (
 	"px.dev/pixie/src/utils/shared/k8s"
 )
 
+func init() {
+	pflag.Bool("allow_kubeconfig_fallback", false, "Fall back to the local kubeconfig when not running inside a K8s cluster. Intended for development and testing only")
+	vls := k8s.VizierLabelSelector()
+	pflag.String("pod_status_label_selector", metav1.FormatLabelSelector(&vls), "The label selector used to pick which pods in the namespace are included in the pod statuses reported to cloud")
+	pflag.Bool("pod_status_select_all_pods", false, "Include every pod in the namespace in the pod statuses reported to cloud, ignoring pod_status_label_selector")
+	pflag.Bool("write_vizier_crd_status", true, "Write the state collected by the cloud connector into the status of the Vizier CRD, if there is one")
+	pflag.StringSlice("pod_status_namespaces", nil, "Additional namespaces with Pixie components, such as the operator, whose pod statuses are reported to cloud. Defaults to px-operator")
+	pflag.Int("k8s_state_stale_update_periods", defaultK8sStateStaleUpdatePeriods, "The number of K8s state update periods without a successful update, after which the state reported to cloud is marked as stale")
+	pflag.Duration("cert_expiry_warning_window", 14*24*time.Hour, "Report the Vizier as degraded when one of its TLS certs expires within this window")
+	pflag.Duration("container_termination_retention", defaultContainerTerminationRetention, "How long a container that was OOMKilled or exited with an error is reported for, after it terminated")
+	pflag.Float64("k8s_api_qps", defaultK8sAPIQPS, "The sustained rate of K8s API calls the cloud connector is allowed to make, across all of its collectors")
+	pflag.Int("k8s_api_burst", defaultK8sAPIBurst, "The number of K8s API calls the cloud connector is allowed to make in a burst. Optional collectors are skipped for the rest of an update once it exceeds the burst")
+}
+
 const k8sStateUpdatePeriod = 10 * time.Second
 
+// The timeout applied to calls to the K8s API when the caller's context has no deadline.
+const defaultK8sAPITimeout = 30 * time.Second
+
+// The K8s state is stale once this many update periods pass without a successful update.
+const defaultK8sStateStaleUpdatePeriods = 3
+
+// The cluster version only changes on control plane upgrades, so it is refreshed much less often than the rest of the state.
+const clusterVersionRefreshPeriod = time.Hour
+
+// The number of pods requested per page when listing pods, to bound the size of each API response.
+const podListPageSize = 500
+
+// The maximum number of bytes of logs returned for a single pod.
+const maxPodLogBytes = 10 * 1024 * 1024
+
+// The namespace that the Vizier operator is deployed to by default.
+const defaultOperatorNamespace = "px-operator"
+
 const privateImageRepo = "gcr.io/pixie-oss/pixie-dev"
 const publicImageRepo = "gcr.io/pixie-oss/pixie-prod"
 
@@ -61,6 +99,9 @@ type K8sState struct {
 	ControlPlanePodStatuses map[string]*cvmsgspb.PodStatus
 	// Pod statuses for a sample (10) of unhealthy Vizier pods.
 	UnhealthyDataPlanePodStatuses map[string]*cvmsgspb.PodStatus
+	// All of the Vizier control plane and data plane pods, sorted by name, as returned by GetVizierPods.
+	ControlPlanePods []*vizierpb.VizierPodStatus
+	DataPlanePods    []*vizierpb.VizierPodStatus
 	// The current K8s version of Vizier.
 	K8sClusterVersion string
 	// The number of nodes on the cluster.
@@ -69,6 +110,57 @@ type K8sState struct {
 	NumInstrumentedNodes int32
 	// The last time this information was updated.
 	LastUpdated time.Time
+	// Stale is set if the information has not been updated for longer than the staleness threshold, for
+	// example because the K8s API server is unreachable.
+	Stale bool
+	// The aggregate health of Vizier, computed from the rest of the state.
+	VizierState *VizierState
+	// Statistics about the scale of the cluster. These are collected less often than the rest of the state.
+	ClusterStats *ClusterStats
+	// When the Vizier was installed and first connected to cloud. Nil until the cluster info is fetched or
+	// the connection is recorded.
+	InstallInfo *InstallInfo
+	// Statuses of the Jobs and CronJobs in the Vizier namespace.
+	JobStatuses []*JobStatus
+	// Statuses of the StatefulSets in the Vizier namespace, such as NATS and the metadata store, sorted by name.
+	StatefulSetStatuses []*StatefulSetStatus
+	// How many of the addresses backing each service in the Vizier namespace are ready, sorted by service name.
+	ServiceEndpoints []*ServiceEndpoints
+	// The images of the containers in each Vizier pod, keyed by pod name.
+	PodImages map[string][]ContainerImage
+	// Whether the Vizier components are running a mix of versions.
+	VersionSkew bool
+	// The expiry of each of the Vizier TLS certs. These are collected less often than the rest of the state.
+	CertExpiries []*CertExpiry
+	// Whether the metrics API is available. This is checked less often than the rest of the state.
+	MetricsServer *MetricsServerStatus
+	// The usage of the resources limited by ResourceQuotas in the Vizier namespace.
+	ResourceQuotaUsages []*ResourceQuotaUsage
+	// The limits set by LimitRanges in the Vizier namespace.
+	LimitRangeItems []*LimitRangeItem
+	// The readiness of each of the running Vizier pods, keyed by pod name.
+	PodReadiness map[string]*PodReadiness
+	// When each of the Vizier pods that the scheduler cannot place became unschedulable, keyed by pod name.
+	UnschedulableSince map[string]time.Time
+	// When each of the Vizier pods and their containers started, keyed by pod name.
+	PodUptimes map[string]*PodUptime
+	// The workload that manages each of the Vizier pods, keyed by pod name. Pods without a controller are left out.
+	PodOwners map[string]*PodOwner
+	// The Vizier components running on each node.
+	PodPlacement *PodPlacement
+	// The containers that were OOMKilled or exited with an error within the retention window, sorted by pod
+	// and container. These are kept after the container or its pod recovers.
+	ContainerTerminations []*ContainerTermination
+	// The containers in the Vizier pods whose images can't be pulled, sorted by pod and container.
+	ImagePullFailures []*ImagePullFailure
+	// Whether each of the secrets Vizier expects exists, and which of its expected keys it has. These are
+	// checked less often than the rest of the state.
+	SecretStatuses []*SecretStatus
+	// The state of each node that affects whether it can run a PEM, sorted by node name.
+	NodeStatuses []*NodeStatus
+	// The missing permission of each collector that is not allowed to collect its part of the state, keyed by
+	// collector. These are probed less often than the rest of the state.
+	UnavailableCollectors map[string]string
 }
 
 // K8sJobHandler manages k8s jobs.
@@ -79,46 +171,156 @@ type K8sJobHandler interface {
 // K8sVizierInfo is responsible for fetching Vizier information through K8s.
 type K8sVizierInfo struct {
 	ns                            string
-	clientset                     *kubernetes.Clientset
-	vzClient                      *versioned.Clientset
+	clientset                     kubernetes.Interface
+	vzClient                      versioned.Interface
 	clusterVersion                string
+	clusterVersionLastUpdated     time.Time
 	clusterName                   string
+	clusterUIDSource              ClusterUIDSource
 	controlPlanePodStatuses       map[string]*cvmsgspb.PodStatus
 	unhealthyDataPlanePodStatuses map[string]*cvmsgspb.PodStatus
+	controlPlanePods              []*vizierpb.VizierPodStatus
+	dataPlanePods                 []*vizierpb.VizierPodStatus
 	k8sStateLastUpdated           time.Time
 	numNodes                      int32
 	numInstrumentedNodes          int32
+	podSelector                   labels.Selector
+	vizierState                   *VizierState
+	clusterStats                  *ClusterStats
+	clusterStatsInvalidated       bool
+	jobStatuses                   []*JobStatus
+	statefulSetStatuses           []*StatefulSetStatus
+	serviceEndpoints              []*ServiceEndpoints
+	podImages                     map[string][]ContainerImage
+	podReadiness                  map[string]*PodReadiness
+	unschedulableSince            map[string]time.Time
+	podUptimes                    map[string]*PodUptime
+	podOwners                     map[string]*PodOwner
+	podPlacement                  *PodPlacement
+	replicaSetOwners              replicaSetOwners // Only accessed while updating the K8s state.
+	containerTerminations         map[string]*ContainerTermination
+	containerTerminationRetention time.Duration
+	imagePullFailures             []*ImagePullFailure
+	capabilities                  *k8sCapabilities
+	nodeStatuses                  []*NodeStatus
+	secretStatuses                []*SecretStatus
+	secretStatusesLastUpdated     time.Time
+	deployInfo                    *DeployInfo
+	networkInfo                   *NetworkInfo
+	installInfo                   *InstallInfo
+	versionSkew                   bool
+	certExpiries                  []*CertExpiry
+	certExpiriesLastUpdated       time.Time
+	certExpiryWarningWindow       time.Duration
+	resourceQuotaUsages           []*ResourceQuotaUsage
+	extraNamespaces               []string
+	skippedNamespaces             map[string]bool
+	staleAfter                    time.Duration
+	metricsServerStatus           *MetricsServerStatus
+	limitRangeItems               []*LimitRangeItem
+	podWatchers                   podStatusWatchers
+	podHistories                  podStatusHistories
+	podStatusRevisions            podStatusRevisions
+	writeCRDStatus                bool
+	lastCRDStatus                 *v1alpha1.VizierConnectorStatus
+	lastCRDStatusWrite            time.Time
+	updateMu                      sync.Mutex // Held while the K8s state is being updated.
+	forcedUpdates                 singleflight.Group
+	lastForcedUpdate              time.Time
+	resetConnections              func()
+	apiBudget                     *apiCallBudget
+	lastUpdateError               error
+	lastUpdateErrorTime           time.Time
+	lastStatusDelivered           time.Time
 	mu                            sync.Mutex
 }
 
-func getK8sVersion() (string, error) {
-	kubeConfig, err := rest.InClusterConfig()
+func getK8sVersion(clientset kubernetes.Interface) (string, error) {
+	version, err := clientset.Discovery().ServerVersion()
 	if err != nil {
 		return "", err
 	}
+	return version.GitVersion, nil
+}
+
+// getKubeConfig gets the config used to talk to the K8s API server. If we are not running in a cluster and
+// allowKubeconfig is set, the config is loaded from $KUBECONFIG or $HOME/.kube/config instead.
+func getKubeConfig(allowKubeconfig bool) (*rest.Config, error) {
+	kubeConfig, err := rest.InClusterConfig()
+	if err == nil {
+		log.Info("Using in-cluster K8s config")
+		return kubeConfig, nil
+	}
+	if !errors.Is(err, rest.ErrNotInCluster) || !allowKubeconfig {
+		return nil, err
+	}
 
-	discoveryClient, err := discovery.NewDiscoveryClientForConfig(kubeConfig)
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	kubeConfig, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{}).ClientConfig()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	log.WithField("kubeconfig", loadingRules.GetDefaultFilename()).Warn("Not running in a K8s cluster, using kubeconfig")
+	return kubeConfig, nil
+}
 
-	version, err := discoveryClient.ServerVersion()
+// getPodSelector parses the selector for the pods that should be included in the K8s state. Selecting all
+// pods ignores the label selector entirely.
+func getPodSelector(labelSelector string, selectAll bool) (labels.Selector, error) {
+	if selectAll {
+		return labels.Everything(), nil
+	}
+	selector, err := labels.Parse(labelSelector)
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("invalid pod status label selector %q: %w", labelSelector, err)
 	}
-	return version.GitVersion, nil
+	return selector, nil
+}
+
+// getExtraNamespaces returns the namespaces to report pod statuses for, other than the Vizier namespace.
+func getExtraNamespaces(vizierNS string, namespaces []string) []string {
+	if len(namespaces) == 0 {
+		namespaces = []string{defaultOperatorNamespace}
+	}
+	var extra []string
+	seen := map[string]bool{vizierNS: true}
+	for _, ns := range namespaces {
+		ns = strings.TrimSpace(ns)
+		if ns == "" || seen[ns] {
+			continue
+		}
+		seen[ns] = true
+		extra = append(extra, ns)
+	}
+	return extra
 }
 
 // NewK8sVizierInfo creates a new K8sVizierInfo.
 func NewK8sVizierInfo(clusterName, ns string) (*K8sVizierInfo, error) {
-	// There is a specific config for services running in the cluster.
-	kubeConfig, err := rest.InClusterConfig()
+	// Validate the selector before doing anything else, so that a typo fails fast rather than
+	// silently selecting nothing.
+	podSelector, err := getPodSelector(viper.GetString("pod_status_label_selector"), viper.GetBool("pod_status_select_all_pods"))
 	if err != nil {
 		return nil, err
 	}
 
-	// Create k8s client.
-	clientset, err := kubernetes.NewForConfig(kubeConfig)
+	// There is a specific config for services running in the cluster. Outside of the cluster, only fall
+	// back to the kubeconfig when explicitly requested so that production behavior can't silently change.
+	kubeConfig, err := getKubeConfig(viper.GetBool("allow_kubeconfig_fallback"))
+	if err != nil {
+		return nil, err
+	}
+
+	// The clients share a rate limiter, which records the calls made by each update in the budget.
+	apiBudget := &apiCallBudget{}
+	kubeConfig.RateLimiter = newBudgetRateLimiter(float32(viper.GetFloat64("k8s_api_qps")), viper.GetInt("k8s_api_burst"), apiBudget)
+
+	// Create k8s client. The HTTP client is kept so that the watchdog can reset its connections.
+	httpClient, err := rest.HTTPClientFor(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfigAndClient(kubeConfig, httpClient)
 	if err != nil {
 		return nil, err
 	}
@@ -130,29 +332,78 @@ func NewK8sVizierInfo(clusterName, ns string) (*K8sVizierInfo, error) {
 	}
 
 	vzInfo := &K8sVizierInfo{
-		ns:          ns,
-		clientset:   clientset,
-		vzClient:    vzCrdClient,
-		clusterName: clusterName,
+		ns:                            ns,
+		extraNamespaces:               getExtraNamespaces(ns, viper.GetStringSlice("pod_status_namespaces")),
+		staleAfter:                    time.Duration(viper.GetInt("k8s_state_stale_update_periods")) * k8sStateUpdatePeriod,
+		clientset:                     clientset,
+		vzClient:                      vzCrdClient,
+		clusterName:                   clusterName,
+		podSelector:                   podSelector,
+		writeCRDStatus:                viper.GetBool("write_vizier_crd_status"),
+		certExpiryWarningWindow:       viper.GetDuration("cert_expiry_warning_window"),
+		containerTerminationRetention: viper.GetDuration("container_termination_retention"),
+		capabilities:                  &k8sCapabilities{},
+		resetConnections:              httpClient.CloseIdleConnections,
+		apiBudget:                     apiBudget,
 	}
+	probeCtx, cancel := context.WithTimeout(context.Background(), defaultK8sAPITimeout)
+	vzInfo.refreshCapabilities(probeCtx, time.Now())
+	cancel()
+	vzInfo.refreshClusterVersion(time.Now())
+	vzInfo.refreshMetricsServerStatus(time.Now())
 
 	go func() {
 		t := time.NewTicker(k8sStateUpdatePeriod)
 		defer t.Stop()
 		for range t.C {
-			vzInfo.UpdateK8sState()
+			// A forced update just collected the state, so there is no need to collect it again yet.
+			if vzInfo.recentlyForced(time.Now()) {
+				continue
+			}
+			// Bound each update by the update period, so that a hung API server can't stall the updates.
+			ctx, cancel := context.WithTimeout(context.Background(), k8sStateUpdatePeriod)
+			vzInfo.UpdateK8sState(ctx)
+			cancel()
 		}
 	}()
+	go vzInfo.runWatchdog()
+	if vzInfo.collectorAvailable(collectorNodes) {
+		go vzInfo.watchNodes(nil, nodeChangeDebounce)
+	}
 
 	return vzInfo, nil
 }
 
-// GetVizierClusterInfo gets the K8s cluster info for the current running vizier.
+// GetVizierClusterInfo gets the K8s cluster info for the current running vizier. It also detects how and when
+// the Vizier was installed and the cluster networking parameters, which are available from DeployInfo,
+// InstallInfo and NetworkInfo.
 func (v *K8sVizierInfo) GetVizierClusterInfo() (*cvmsgspb.VizierClusterInfo, error) {
-	clusterUID, err := v.GetClusterUID()
+	clusterUID, err := v.GetClusterUID(context.Background())
 	if err != nil {
 		return nil, err
 	}
+
+	ctx, cancel := withDefaultTimeout(context.Background())
+	defer cancel()
+	deployInfo := v.getDeployInfo(ctx)
+	log.WithField("method", deployInfo.Method).WithField("version", deployInfo.Version).Info("Detected Vizier deploy method")
+	networkInfo := v.getNetworkInfo(ctx)
+	log.WithField("podCIDRs", networkInfo.PodCIDRs).WithField("serviceCIDR", networkInfo.ServiceCIDR).
+		WithField("kubernetesServiceIP", networkInfo.KubernetesServiceIP).WithField("cni", networkInfo.CNI).
+		Info("Detected cluster networking parameters")
+	installInfo := v.getInstallInfo(ctx)
+	now := time.Now()
+	log.WithField("installedAt", installInfo.InstalledAt).WithField("installAge", installInfo.InstallAge(now).Round(time.Minute)).
+		WithField("firstConnectedAt", installInfo.FirstConnectedAt).Info("Detected Vizier install time")
+	v.mu.Lock()
+	v.deployInfo = deployInfo
+	v.networkInfo = networkInfo
+	// The first connection may have been recorded while the install time was being detected.
+	if installInfo.FirstConnectedAt.IsZero() && v.installInfo != nil {
+		installInfo.FirstConnectedAt = v.installInfo.FirstConnectedAt
+	}
+	v.installInfo = installInfo
+	v.mu.Unlock()
 	return &cvmsgspb.VizierClusterInfo{
 		ClusterUID:    clusterUID,
 		ClusterName:   v.clusterName,
@@ -160,13 +411,12 @@ func (v *K8sVizierInfo) GetVizierClusterInfo() (*cvmsgspb.VizierClusterInfo, err
 	}, nil
 }
 
-// GetClusterUID gets UID for the cluster, represented by the kube-system namespace UID.
-func (v *K8sVizierInfo) GetClusterUID() (string, error) {
-	ksNS, err := v.clientset.CoreV1().Namespaces().Get(context.Background(), "kube-system", metav1.GetOptions{})
-	if err != nil {
-		return "", err
+// withDefaultTimeout applies defaultK8sAPITimeout to the context, if it has no deadline.
+func withDefaultTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
 	}
-	return string(ksNS.UID), nil
+	return context.WithTimeout(ctx, defaultK8sAPITimeout)
 }
 
 const nanosPerSecond = int64(1000 * 1000 * 1000)
@@ -182,12 +432,121 @@ func nanosToTimestampProto(nanos int64) *types.Timestamp {
 
 // GetVizierPodLogs gets the k8s logs for the Vizier pod with the given name.
 func (v *K8sVizierInfo) GetVizierPodLogs(podName string, previous bool, container string) (string, error) {
-	resp := v.clientset.CoreV1().Pods(v.ns).GetLogs(podName, &corev1.PodLogOptions{Previous: previous, Container: container}).Do(context.Background())
-	rawResp, err := resp.Raw()
+	return v.GetPodLogs(podName, container, 0, previous)
+}
+
+// isMonitoredNamespace returns whether pod statuses are reported for the namespace.
+func (v *K8sVizierInfo) isMonitoredNamespace(ns string) bool {
+	if ns == v.ns {
+		return true
+	}
+	for _, extra := range v.extraNamespaces {
+		if ns == extra {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPodLogs gets the k8s logs for a container in the Vizier pod with the given name. The name may be qualified
+// with the namespace, as in "px-operator/vizier-operator-0", but only pods in monitored namespaces are allowed. If tailLines
+// is positive, only that many lines from the end of the logs are returned. The logs are truncated to maxPodLogBytes.
+func (v *K8sVizierInfo) GetPodLogs(podName, containerName string, tailLines int64, previous bool) (string, error) {
+	ns := v.ns
+	if prefix, name, ok := strings.Cut(podName, "/"); ok {
+		if !v.isMonitoredNamespace(prefix) {
+			return "", fmt.Errorf("pod %s is not in a monitored namespace", podName)
+		}
+		ns = prefix
+		podName = name
+	}
+	if podName == "" {
+		return "", errors.New("pod name must be specified")
+	}
+
+	limitBytes := int64(maxPodLogBytes)
+	opts := &corev1.PodLogOptions{
+		Container:  containerName,
+		Previous:   previous,
+		LimitBytes: &limitBytes,
+	}
+	if tailLines > 0 {
+		opts.TailLines = &tailLines
+	}
+
+	stream, err := v.clientset.CoreV1().Pods(ns).GetLogs(podName, opts).Stream(context.Background())
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+
+	// LimitBytes is best-effort on the API server side, so enforce the cap while reading as well.
+	logs, err := io.ReadAll(io.LimitReader(stream, maxPodLogBytes))
 	if err != nil {
 		return "", err
 	}
-	return string(rawResp), nil
+	return string(logs), nil
+}
+
+// The outcomes of a remote request to delete a pod, as recorded in its audit log line.
+const (
+	deletePodRefused = "refused"
+	deletePodFailed  = "failed"
+	deletePodDeleted = "deleted"
+)
+
+// auditDeletePod writes the audit log line of a remote request to delete a pod. Every attempt is recorded,
+// including the refused and failed ones. The UID is left out if the pod couldn't be read.
+func auditDeletePod(podName string, uid string, gracePeriod time.Duration, outcome string, err error) {
+	entry := log.WithField("audit", "delete_pod").
+		WithField("pod", podName).
+		WithField("gracePeriod", gracePeriod).
+		WithField("outcome", outcome)
+	if uid != "" {
+		entry = entry.WithField("uid", uid)
+	}
+	if err != nil {
+		entry = entry.WithError(err)
+	}
+	entry.Warn("Remote request to delete pod")
+}
+
+// DeletePod deletes the Vizier pod with the given name, so that its controller recreates it. Only pods in the
+// Vizier namespace that carry the Pixie component labels may be deleted. Returns the UID of the deleted pod.
+// Every attempt is audit logged.
+func (v *K8sVizierInfo) DeletePod(ctx context.Context, podName string, gracePeriod time.Duration) (string, error) {
+	pod, err := v.clientset.CoreV1().Pods(v.ns).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		auditDeletePod(podName, "", gracePeriod, deletePodFailed, err)
+		return "", err
+	}
+	uid := string(pod.UID)
+
+	vls := k8s.VizierLabelSelector()
+	selector, err := metav1.LabelSelectorAsSelector(&vls)
+	if err != nil {
+		auditDeletePod(podName, uid, gracePeriod, deletePodFailed, err)
+		return "", err
+	}
+	if !selector.Matches(labels.Set(pod.Labels)) {
+		err := fmt.Errorf("pod %s is not a Pixie component", podName)
+		auditDeletePod(podName, uid, gracePeriod, deletePodRefused, err)
+		return "", err
+	}
+
+	gracePeriodSeconds := int64(gracePeriod.Seconds())
+	// Only delete the pod we inspected, in case it was recreated in the meantime.
+	err = v.clientset.CoreV1().Pods(v.ns).Delete(ctx, podName, metav1.DeleteOptions{
+		GracePeriodSeconds: &gracePeriodSeconds,
+		Preconditions:      metav1.NewUIDPreconditions(uid),
+	})
+	if err != nil {
+		auditDeletePod(podName, uid, gracePeriod, deletePodFailed, err)
+		return "", err
+	}
+
+	auditDeletePod(podName, uid, gracePeriod, deletePodDeleted, nil)
+	return uid, nil
 }
 
 func convertPodPhase(p metadatapb.PodPhase) vizierpb.PodPhase {
@@ -291,9 +650,99 @@ func (v *K8sVizierInfo) GetVizierPods() ([]*vizierpb.VizierPodStatus, []*vizierp
 	return controlPods, dataPods, err
 }
 
+// getVizierPodStatuses converts the listed control plane and data plane pods in the Vizier namespace, in the
+// same way as GetVizierPods, so that they can be returned without listing the pods again.
+func (v *K8sVizierInfo) getVizierPodStatuses(listedPods map[string]*corev1.Pod) ([]*vizierpb.VizierPodStatus, []*vizierpb.VizierPodStatus) {
+	var controlPods []*vizierpb.VizierPodStatus
+	var dataPods []*vizierpb.VizierPodStatus
+	for _, p := range listedPods {
+		if p.Namespace != "" && p.Namespace != v.ns {
+			continue
+		}
+		plane := p.Labels["plane"]
+		if plane != "control" && plane != "data" {
+			continue
+		}
+		pod, err := v.toVizierPodStatus(p)
+		if err != nil {
+			log.WithError(err).WithField("pod", p.Name).Error("Failed to convert Vizier pod status")
+			continue
+		}
+		if plane == "control" {
+			controlPods = append(controlPods, pod)
+		} else {
+			dataPods = append(dataPods, pod)
+		}
+	}
+	sortVizierPodStatuses(controlPods)
+	sortVizierPodStatuses(dataPods)
+	return controlPods, dataPods
+}
+
+func sortVizierPodStatuses(pods []*vizierpb.VizierPodStatus) {
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Name < pods[j].Name
+	})
+}
+
+// copyVizierPodStatuses copies the list of pods. The pods themselves are not modified after they are collected,
+// so they are shared.
+func copyVizierPodStatuses(pods []*vizierpb.VizierPodStatus) []*vizierpb.VizierPodStatus {
+	if pods == nil {
+		return nil
+	}
+	return append([]*vizierpb.VizierPodStatus(nil), pods...)
+}
+
+// failingWaitingReasons are the container waiting reasons which mean that a container keeps failing to start.
+// A pod with such a container is not healthy, even though its phase is usually Running.
+var failingWaitingReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"CreateContainerConfigError": true,
+	"RunContainerError":          true,
+}
+
+// getWorstFailingContainer returns the container which is waiting for one of the failingWaitingReasons with
+// the most restarts, or nil if there is none.
+func getWorstFailingContainer(containers []*cvmsgspb.ContainerStatus) *cvmsgspb.ContainerStatus {
+	var worst *cvmsgspb.ContainerStatus
+	for _, c := range containers {
+		if c.State != metadatapb.CONTAINER_STATE_WAITING || !failingWaitingReasons[c.Reason] {
+			continue
+		}
+		if worst == nil || c.RestartCount > worst.RestartCount {
+			worst = c
+		}
+	}
+	return worst
+}
+
+// isPodRunning returns whether the pod is running, and none of its containers are failing to start.
+func isPodRunning(p *corev1.Pod) bool {
+	if p.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	for _, c := range p.Status.ContainerStatuses {
+		if c.State.Waiting != nil && failingWaitingReasons[c.State.Waiting.Reason] {
+			return false
+		}
+	}
+	return true
+}
+
 // Convert a list of K8s pod information to our internal (cloud) representation of PodStatus.
-func (v *K8sVizierInfo) getPodStatuses(podList []corev1.Pod) (map[string]*cvmsgspb.PodStatus, error) {
+// If a container is failing to start, its waiting reason and message are promoted into the pod's
+// Reason and StatusMessage, since the pod phase alone does not show the failure. Likewise for a container whose
+// image can't be pulled, and for the scheduler's message if a pending pod is unschedulable. Running pods that are not
+// ready report their ready container count and any readiness probe failure as the StatusMessage. Otherwise,
+// a container that was recently OOMKilled or exited with an error is reported in the StatusMessage, since it
+// has usually restarted and looks Running by the time the pod is listed. Pods that are being deleted still
+// look Running, so they are reported as Terminating, with the end of their grace period, instead.
+func (v *K8sVizierInfo) getPodStatuses(ctx context.Context, podList []corev1.Pod) (map[string]*cvmsgspb.PodStatus, error) {
 	podMap := make(map[string]*cvmsgspb.PodStatus)
+	eventsAvailable := v.collectorAvailable(collectorEvents)
+	now := time.Now()
+	terminationRetention := v.getContainerTerminationRetention()
 
 	for _, p := range podList {
 		podPb := protoutils.PodToProto(&p)
@@ -316,102 +765,242 @@ func (v *K8sVizierInfo) getPodStatuses(podList []corev1.Pod) (map[string]*cvmsgs
 				})
 			}
 		}
+		reason := ""
+		if c := getWorstFailingContainer(containers); c != nil {
+			reason = c.Reason
+			msg = c.Message
+		} else if f := getPodImagePullFailures(v.podKey(&p), &p); len(f) > 0 {
+			reason = f[0].Reason
+			msg = f[0].Description()
+		} else if c := getUnschedulableCondition(&p); c != nil {
+			reason = c.Reason
+			msg = c.Message
+		}
+
 		name := podPb.Metadata.Name
-		ns := v.ns
+		ns := p.Namespace
+		if ns == "" {
+			ns = v.ns
+		}
 		events := make([]*cvmsgspb.K8SEvent, 0)
 
-		eventsInterface := v.clientset.CoreV1().Events(v.ns)
-		selector := eventsInterface.GetFieldSelector(&name, &ns, nil, nil)
-		options := metav1.ListOptions{FieldSelector: selector.String()}
-		evs, err := eventsInterface.List(context.Background(), options)
-
-		if err == nil {
-			// Limit to last 5 events.
-			start := len(evs.Items) - 5
-			if start < 0 {
-				start = 0
+		if eventsAvailable {
+			eventsInterface := v.clientset.CoreV1().Events(ns)
+			selector := eventsInterface.GetFieldSelector(&name, &ns, nil, nil)
+			options := metav1.ListOptions{FieldSelector: selector.String()}
+			evs, err := eventsInterface.List(ctx, options)
+
+			if err == nil {
+				// Limit to last 5 events.
+				start := len(evs.Items) - 5
+				if start < 0 {
+					start = 0
+				}
+				end := len(evs.Items)
+
+				for start < end {
+					e := evs.Items[start]
+					events = append(events, &cvmsgspb.K8SEvent{
+						Message:   e.Message,
+						FirstTime: nanosToTimestampProto(e.FirstTimestamp.UnixNano()),
+						LastTime:  nanosToTimestampProto(e.LastTimestamp.UnixNano()),
+					})
+					start++
+				}
+			} else if ns != v.ns {
+				// Events are best-effort for pods outside of the Vizier namespace, which may not be readable.
+				recordK8sAPIError("events")
+			} else {
+				recordK8sAPIError("events")
+				return nil, err
 			}
-			end := len(evs.Items)
-
-			for start < end {
-				e := evs.Items[start]
-				events = append(events, &cvmsgspb.K8SEvent{
-					Message:   e.Message,
-					FirstTime: nanosToTimestampProto(e.FirstTimestamp.UnixNano()),
-					LastTime:  nanosToTimestampProto(e.LastTimestamp.UnixNano()),
-				})
-				start++
+		}
+
+		// A running pod can still be failing its readiness probes, which the phase does not show.
+		if r := getPodReadiness(&p); reason == "" && r != nil && !r.Ready {
+			msg = fmt.Sprintf("%d/%d containers ready", r.ReadyContainers, r.TotalContainers)
+			if probeMsg := getReadinessProbeMessage(events); probeMsg != "" {
+				msg = fmt.Sprintf("%s: %s", msg, probeMsg)
 			}
-		} else {
-			return nil, err
 		}
 
+		key := v.podKey(&p)
+		if t := getLatestTermination(key, &p, now, terminationRetention); reason == "" && msg == "" && t != nil {
+			msg = fmt.Sprintf("container %s last terminated: %s at %s", t.Container, t.Description(), t.FinishedAt.UTC().Format(time.RFC3339))
+		}
+		// The containers of a terminating pod are expected to be shutting down, so their state doesn't matter.
+		if terminatingReason, terminatingMsg := getTerminatingStatus(&p, now); terminatingReason != "" {
+			reason = terminatingReason
+			msg = terminatingMsg
+		}
 		s := &cvmsgspb.PodStatus{
-			Name:          name,
+			Name:          key,
 			Status:        status,
 			StatusMessage: msg,
+			Reason:        reason,
 			Containers:    containers,
 			CreatedAt:     nanosToTimestampProto(podPb.Metadata.CreationTimestampNS),
 			Events:        events,
 			RestartCount:  podPb.Status.RestartCount,
 		}
-		podMap[name] = s
+		podMap[key] = s
 	}
 	return podMap, nil
 }
 
-func (v *K8sVizierInfo) getControlPlanePodStatuses() (map[string]*cvmsgspb.PodStatus, error) {
+// podLabelSelector restricts the given label selector to the pods selected by the pod selector.
+func (v *K8sVizierInfo) podLabelSelector(selector string) string {
+	if v.podSelector == nil || v.podSelector.Empty() {
+		return selector
+	}
+	return fmt.Sprintf("%s,%s", selector, v.podSelector.String())
+}
+
+// listPods lists the pods in the namespace that match the label selector, one page at a time. If the
+// continue token expires partway through, the listing is restarted once from the beginning.
+func (v *K8sVizierInfo) listPods(ctx context.Context, ns, labelSelector string) ([]corev1.Pod, error) {
+	pods, err := v.listPodPages(ctx, ns, labelSelector)
+	if k8sErrors.IsResourceExpired(err) {
+		log.WithError(err).Info("Pod list continue token expired, restarting the listing")
+		pods, err = v.listPodPages(ctx, ns, labelSelector)
+	}
+	return pods, err
+}
+
+func (v *K8sVizierInfo) listPodPages(ctx context.Context, ns, labelSelector string) ([]corev1.Pod, error) {
+	var pods []corev1.Pod
+	opts := metav1.ListOptions{
+		LabelSelector: labelSelector,
+		Limit:         podListPageSize,
+	}
+	for {
+		page, err := v.clientset.CoreV1().Pods(ns).List(ctx, opts)
+		if err != nil {
+			recordK8sAPIError("pods")
+			return nil, err
+		}
+		pods = append(pods, page.Items...)
+		if page.Continue == "" {
+			return pods, nil
+		}
+		opts.Continue = page.Continue
+	}
+}
+
+// podKey returns the key of the pod in the reported pod statuses. Pods outside of the Vizier namespace are
+// prefixed with their namespace. Ex: "px-operator/vizier-operator-6f8b9c7d5-abcde".
+func (v *K8sVizierInfo) podKey(p *corev1.Pod) string {
+	if p.Namespace == "" || p.Namespace == v.ns {
+		return p.Name
+	}
+	return fmt.Sprintf("%s/%s", p.Namespace, p.Name)
+}
+
+// recordListedPods records the given pods in listedPods, keyed by pod key.
+func (v *K8sVizierInfo) recordListedPods(listedPods map[string]*corev1.Pod, pods []corev1.Pod) {
+	for i := range pods {
+		listedPods[v.podKey(&pods[i])] = &pods[i]
+	}
+}
+
+// listExtraNamespacePods lists every pod in the extra namespaces. Namespaces that can't be listed, for
+// example due to missing RBAC, are skipped, and the failure is only logged the first time.
+func (v *K8sVizierInfo) listExtraNamespacePods(ctx context.Context) []corev1.Pod {
+	var pods []corev1.Pod
+	for _, ns := range v.extraNamespaces {
+		nsPods, err := v.listPods(ctx, ns, "")
+		v.mu.Lock()
+		if v.skippedNamespaces == nil {
+			v.skippedNamespaces = make(map[string]bool)
+		}
+		logSkip := err != nil && !v.skippedNamespaces[ns]
+		v.skippedNamespaces[ns] = err != nil
+		v.mu.Unlock()
+
+		if logSkip {
+			log.WithError(err).WithField("namespace", ns).Warn("Failed to list pods, skipping namespace")
+		}
+		pods = append(pods, nsPods...)
+	}
+	return pods
+}
+
+// getControlPlanePodStatuses gets the statuses of the control plane pods, and of every pod in the extra
+// namespaces. Every listed pod is recorded in listedPods.
+func (v *K8sVizierInfo) getControlPlanePodStatuses(ctx context.Context, listedPods map[string]*corev1.Pod) (map[string]*cvmsgspb.PodStatus, error) {
+	if !v.collectorAvailable(collectorPods) {
+		return make(map[string]*cvmsgspb.PodStatus), nil
+	}
 	// Get only control-plane pods.
-	cpPodsList, err := v.clientset.CoreV1().Pods(v.ns).List(context.Background(), metav1.ListOptions{
-		LabelSelector: "plane=control",
-	})
+	cpPods, err := v.listPods(ctx, v.ns, v.podLabelSelector("plane=control"))
 	if err != nil {
 		return nil, err
 	}
-	return v.getPodStatuses(cpPodsList.Items)
+	cpPods = append(cpPods, v.listExtraNamespacePods(ctx)...)
+	v.recordListedPods(listedPods, cpPods)
+	return v.getPodStatuses(ctx, cpPods)
 }
 
-// Capture K8s state related to the data plane (num nodes, num instrumented nodes, unhealthy data plane pods)
-func (v *K8sVizierInfo) getDataPlaneState() (int32, int32, map[string]*cvmsgspb.PodStatus, error) {
-	nodesList, err := v.clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
-	if err != nil {
-		log.WithError(err).Error("Error fetching nodes")
-		return 0, 0, nil, err
+// Capture K8s state related to the data plane (num nodes, num instrumented nodes, unhealthy data plane pods).
+// Every listed pod is recorded in listedPods, and every listed node in listedNodes.
+func (v *K8sVizierInfo) getDataPlaneState(ctx context.Context, listedPods map[string]*corev1.Pod, listedNodes map[string]*corev1.Node) (int32, int32, map[string]*cvmsgspb.PodStatus, error) {
+	numNodes := int32(0)
+	if v.collectorAvailable(collectorNodes) {
+		nodesList, err := v.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			recordK8sAPIError("nodes")
+			log.WithError(err).Error("Error fetching nodes")
+			return 0, 0, nil, err
+		}
+		numNodes = int32(len(nodesList.Items))
+		for i := range nodesList.Items {
+			listedNodes[nodesList.Items[i].Name] = &nodesList.Items[i]
+		}
+	}
+	if !v.collectorAvailable(collectorPods) {
+		return numNodes, 0, make(map[string]*cvmsgspb.PodStatus), nil
 	}
 
 	var unhealthyDataPlanePods []corev1.Pod
+	now := time.Now()
 
-	kelvinPodsList, err := v.clientset.CoreV1().Pods(v.ns).List(context.Background(), metav1.ListOptions{
-		LabelSelector: "name=kelvin",
-	})
+	kelvinPods, err := v.listPods(ctx, v.ns, v.podLabelSelector("name=kelvin"))
 	if err != nil {
 		log.WithError(err).Error("Error fetching Kelvin pods")
 		return 0, 0, nil, err
 	}
-	for _, kelvinPod := range kelvinPodsList.Items {
-		if kelvinPod.Status.Phase != corev1.PodRunning {
+	v.recordListedPods(listedPods, kelvinPods)
+	for i, kelvinPod := range kelvinPods {
+		if !isPodRunning(&kelvinPods[i]) || isPodStuckTerminating(&kelvinPods[i], now) {
 			unhealthyDataPlanePods = append(unhealthyDataPlanePods, kelvinPod)
 		}
 	}
 
 	var unhealthyPEMPods []corev1.Pod
-	pemPodsList, err := v.clientset.CoreV1().Pods(v.ns).List(context.Background(), metav1.ListOptions{
-		LabelSelector: "name=vizier-pem",
-	})
+	pemPods, err := v.listPods(ctx, v.ns, v.podLabelSelector("name=vizier-pem"))
 	if err != nil {
 		log.WithError(err).Error("Error fetching PEM pods")
 		return 0, 0, nil, err
 	}
+	v.recordListedPods(listedPods, pemPods)
 
-	// Get the count of healthy PEMs.
-	healthyPemCount := 0
-	for _, pemPod := range pemPodsList.Items {
-		if pemPod.Status.Phase == corev1.PodRunning {
-			healthyPemCount++
+	// Get the count of nodes with a healthy PEM. During a rolling update, a node briefly runs both the
+	// terminating PEM and its replacement, so the PEMs are counted once per node.
+	instrumentedNodes := make(map[string]bool)
+	for i, pemPod := range pemPods {
+		if isPodStuckTerminating(&pemPods[i], now) {
+			unhealthyPEMPods = append(unhealthyPEMPods, pemPod)
+		} else if isPodRunning(&pemPods[i]) {
+			node := pemPod.Spec.NodeName
+			if node == "" {
+				node = pemPod.Name
+			}
+			instrumentedNodes[node] = true
 		} else {
 			unhealthyPEMPods = append(unhealthyPEMPods, pemPod)
 		}
 	}
+	healthyPemCount := len(instrumentedNodes)
 
 	// Sort the unhealthy PEM pods. Get the first N.
 	maxUnhealthyDataPlanePods := 10
@@ -419,49 +1008,206 @@ func (v *K8sVizierInfo) getDataPlaneState() (int32, int32, map[string]*cvmsgspb.
 		return unhealthyPEMPods[i].ObjectMeta.Name < unhealthyPEMPods[j].ObjectMeta.Name
 	})
 	for i := 0; i < len(unhealthyPEMPods); i++ {
-		if len(unhealthyDataPlanePods) <= maxUnhealthyDataPlanePods {
+		if len(unhealthyDataPlanePods) >= maxUnhealthyDataPlanePods {
 			break
 		}
 		unhealthyDataPlanePods = append(unhealthyDataPlanePods, unhealthyPEMPods[i])
 	}
 
-	unhealthyDataPlanePodStatuses, err := v.getPodStatuses(unhealthyDataPlanePods)
+	unhealthyDataPlanePodStatuses, err := v.getPodStatuses(ctx, unhealthyDataPlanePods)
 	if err != nil {
 		return 0, 0, nil, err
 	}
-	return int32(len(nodesList.Items)), int32(healthyPemCount), unhealthyDataPlanePodStatuses, nil
+	return numNodes, int32(healthyPemCount), unhealthyDataPlanePodStatuses, nil
+}
+
+// refreshClusterVersion fetches the K8s version of the cluster, if it has not been fetched within the refresh period.
+// If the version can't be fetched, the last known version is kept.
+func (v *K8sVizierInfo) refreshClusterVersion(now time.Time) {
+	v.mu.Lock()
+	stale := now.Sub(v.clusterVersionLastUpdated) >= clusterVersionRefreshPeriod
+	v.mu.Unlock()
+	if !stale {
+		return
+	}
+
+	clusterVersion, err := getK8sVersion(v.clientset)
+	if err != nil {
+		recordK8sAPIError("version")
+		log.WithError(err).Error("Failed to get Kubernetes version for cluster, using last known version")
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.clusterVersion = clusterVersion
+	v.clusterVersionLastUpdated = now
 }
 
 // UpdateK8sState gets the relevant state of the cluster, such as pod statuses, at the current moment in time.
-func (v *K8sVizierInfo) UpdateK8sState() {
-	controlPlanePods, err := v.getControlPlanePodStatuses()
+// If the context is canceled or times out partway through, the previously collected state is kept. If another
+// update is still running, for example because the API server is slow, this update is skipped.
+func (v *K8sVizierInfo) UpdateK8sState(ctx context.Context) {
+	if !v.updateMu.TryLock() {
+		k8sStateUpdateSkipsCounter.Inc()
+		log.Warn("Skipping K8s state update, the previous update is still running")
+		return
+	}
+	defer v.updateMu.Unlock()
+	v.updateK8sState(ctx)
+}
+
+// updateK8sState collects the K8s state. The caller must hold updateMu.
+func (v *K8sVizierInfo) updateK8sState(ctx context.Context) {
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+
+	start := time.Now()
+	success := false
+	var updateErr error
+	callsAtStart := v.apiBudget.startUpdate()
+	defer func() {
+		recordK8sStateUpdate(start, success)
+		k8sStateUpdateAPICalls.Observe(float64(v.apiBudget.callsSince(callsAtStart)))
+		v.setLastUpdateError(updateErr, start)
+	}()
+
+	v.refreshCapabilities(ctx, start)
+	v.refreshClusterVersion(start)
+	v.refreshClusterStats(ctx, start)
+	v.refreshCertExpiries(ctx, start)
+	v.refreshSecretStatuses(ctx, start)
+	v.refreshMetricsServerStatus(start)
+
+	listedPods := make(map[string]*corev1.Pod)
+	controlPlanePods, err := v.getControlPlanePodStatuses(ctx, listedPods)
 	if err != nil {
 		log.WithError(err).Error("Error fetching control plane pod statuses")
+		updateErr = err
 		return
 	}
 
-	numNodes, numInstrumentedNodes, unhealthyDataPlanePods, err := v.getDataPlaneState()
+	listedNodes := make(map[string]*corev1.Node)
+	numNodes, numInstrumentedNodes, unhealthyDataPlanePods, err := v.getDataPlaneState(ctx, listedPods, listedNodes)
 	if err != nil {
 		log.WithError(err).Error("Error fetching data plane pod information")
+		updateErr = err
 		return
 	}
 
-	clusterVersion, err := getK8sVersion()
-	if err != nil {
-		log.WithError(err).Error("Failed to get Kubernetes version for cluster")
+	jobStatuses := v.getJobStatuses(ctx)
+	v.mu.Lock()
+	previousStatefulSetStatuses := v.statefulSetStatuses
+	v.mu.Unlock()
+	statefulSetStatuses := v.getStatefulSetStatuses(ctx, previousStatefulSetStatuses, start)
+	serviceEndpoints := v.getServiceEndpoints(ctx)
+	resourceQuotaUsages := v.getResourceQuotaUsages(ctx)
+	limitRangeItems := v.getLimitRangeItems(ctx)
+	pemTolerations, checkTaints := v.getPEMTolerations(ctx)
+	podOwners, replicaSetOwners := v.getPodOwners(ctx, listedPods, v.replicaSetOwners)
+
+	// The optional collectors leave out what they fail to collect, which would wrongly drop that state if the
+	// failure was due to the context.
+	if ctx.Err() != nil {
+		log.WithError(ctx.Err()).Error("K8s state update did not complete")
+		updateErr = ctx.Err()
 		return
 	}
 
-	now := time.Now()
+	podUIDs := make(map[string]string, len(listedPods))
+	podImages := make(map[string][]ContainerImage, len(listedPods))
+	for name, p := range listedPods {
+		podUIDs[name] = string(p.UID)
+		podImages[name] = getPodImages(p)
+	}
+	podStatuses := mergePodStatuses(controlPlanePods, unhealthyDataPlanePods)
+	vizierControlPlanePods, vizierDataPlanePods := v.getVizierPodStatuses(listedPods)
+	podReadiness := getPodReadinesses(listedPods, podStatuses)
+	unschedulableSince := getUnschedulableSince(listedPods)
+	podUptimes := getPodUptimes(listedPods)
+	imagePullFailures := getImagePullFailures(listedPods)
+	nodeStatuses := getNodeStatuses(listedNodes, listedPods, pemTolerations, checkTaints)
+	podPlacement := getPodPlacement(listedPods, podOwners)
+
 	v.mu.Lock()
-	defer v.mu.Unlock()
+	certExpiries := copyCertExpiries(v.certExpiries)
+	metricsServer := v.getMetricsServerStatus()
+	unavailableCollectors := v.getUnavailableCollectors()
+	secretStatuses := copySecretStatuses(v.secretStatuses)
+	containerTerminations := mergeContainerTerminations(v.containerTerminations, listedPods, start, v.getContainerTerminationRetention())
+	v.mu.Unlock()
 
+	now := time.Now()
+	state := &K8sState{
+		ControlPlanePodStatuses:       controlPlanePods,
+		UnhealthyDataPlanePodStatuses: unhealthyDataPlanePods,
+		ControlPlanePods:              vizierControlPlanePods,
+		DataPlanePods:                 vizierDataPlanePods,
+		NumNodes:                      numNodes,
+		NumInstrumentedNodes:          numInstrumentedNodes,
+		LastUpdated:                   now,
+		JobStatuses:                   jobStatuses,
+		StatefulSetStatuses:           statefulSetStatuses,
+		ServiceEndpoints:              serviceEndpoints,
+		PodImages:                     podImages,
+		PodReadiness:                  podReadiness,
+		UnschedulableSince:            unschedulableSince,
+		PodUptimes:                    podUptimes,
+		PodOwners:                     podOwners,
+		PodPlacement:                  podPlacement,
+		ContainerTerminations:         sortedContainerTerminations(containerTerminations),
+		ImagePullFailures:             imagePullFailures,
+		UnavailableCollectors:         unavailableCollectors,
+		NodeStatuses:                  nodeStatuses,
+		SecretStatuses:                secretStatuses,
+		VersionSkew:                   len(getPixieImageVersions(podImages)) > 1,
+		CertExpiries:                  certExpiries,
+		MetricsServer:                 metricsServer,
+		ResourceQuotaUsages:           resourceQuotaUsages,
+		LimitRangeItems:               limitRangeItems,
+	}
+	state.VizierState = computeVizierState(state)
+
+	v.mu.Lock()
+	oldPodStatuses := mergePodStatuses(v.controlPlanePodStatuses, v.unhealthyDataPlanePodStatuses)
+	changes := diffPodStatuses(oldPodStatuses, podStatuses)
+	if v.podHistories == nil {
+		v.podHistories = make(podStatusHistories)
+	}
+	v.podHistories.update(now, podStatuses, podUIDs)
+	v.podStatusRevisions.update(v.controlPlanePodStatuses, controlPlanePods)
 	v.k8sStateLastUpdated = now
 	v.controlPlanePodStatuses = controlPlanePods
 	v.unhealthyDataPlanePodStatuses = unhealthyDataPlanePods
+	v.controlPlanePods = vizierControlPlanePods
+	v.dataPlanePods = vizierDataPlanePods
 	v.numNodes = numNodes
 	v.numInstrumentedNodes = numInstrumentedNodes
-	v.clusterVersion = clusterVersion
+	v.jobStatuses = jobStatuses
+	v.statefulSetStatuses = statefulSetStatuses
+	v.serviceEndpoints = serviceEndpoints
+	v.podImages = podImages
+	v.podReadiness = podReadiness
+	v.unschedulableSince = unschedulableSince
+	v.podUptimes = podUptimes
+	v.podOwners = podOwners
+	v.podPlacement = podPlacement
+	v.replicaSetOwners = replicaSetOwners
+	v.containerTerminations = containerTerminations
+	v.imagePullFailures = imagePullFailures
+	v.nodeStatuses = nodeStatuses
+	v.versionSkew = state.VersionSkew
+	v.resourceQuotaUsages = resourceQuotaUsages
+	v.limitRangeItems = limitRangeItems
+	v.vizierState = state.VizierState
+	v.mu.Unlock()
+
+	v.podWatchers.publish(changes)
+	recordTrackedPods(podStatuses)
+	if v.writeCRDStatus {
+		v.writeVizierCRDStatus(ctx, state)
+	}
+	success = true
 }
 
 // Function to copy pod statuses since maps are a reference type and we return
@@ -477,6 +1223,19 @@ func copyPodStatus(podStatuses map[string]*cvmsgspb.PodStatus) map[string]*cvmsg
 	return clone
 }
 
+// isK8sStateStale returns whether the K8s state has gone without a successful update for longer than the
+// staleness threshold. State that has never been updated has nothing to be stale. The caller must hold the lock.
+func (v *K8sVizierInfo) isK8sStateStale(now time.Time) bool {
+	if v.k8sStateLastUpdated.IsZero() {
+		return false
+	}
+	staleAfter := v.staleAfter
+	if staleAfter <= 0 {
+		staleAfter = defaultK8sStateStaleUpdatePeriods * k8sStateUpdatePeriod
+	}
+	return now.Sub(v.k8sStateLastUpdated) > staleAfter
+}
+
 // GetK8sState gets the pod statuses and the last time they were updated.
 func (v *K8sVizierInfo) GetK8sState() *K8sState {
 	v.mu.Lock()
@@ -485,10 +1244,54 @@ func (v *K8sVizierInfo) GetK8sState() *K8sState {
 	return &K8sState{
 		ControlPlanePodStatuses:       copyPodStatus(v.controlPlanePodStatuses),
 		UnhealthyDataPlanePodStatuses: copyPodStatus(v.unhealthyDataPlanePodStatuses),
+		ControlPlanePods:              copyVizierPodStatuses(v.controlPlanePods),
+		DataPlanePods:                 copyVizierPodStatuses(v.dataPlanePods),
 		NumNodes:                      v.numNodes,
 		NumInstrumentedNodes:          v.numInstrumentedNodes,
 		LastUpdated:                   v.k8sStateLastUpdated,
+		Stale:                         v.isK8sStateStale(time.Now()),
 		K8sClusterVersion:             v.clusterVersion,
+		VizierState:                   v.getVizierState(),
+		ClusterStats:                  v.getClusterStats(),
+		InstallInfo:                   copyInstallInfo(v.installInfo),
+		JobStatuses:                   copyJobStatuses(v.jobStatuses),
+		StatefulSetStatuses:           copyStatefulSetStatuses(v.statefulSetStatuses),
+		ServiceEndpoints:              copyServiceEndpoints(v.serviceEndpoints),
+		PodImages:                     copyPodImages(v.podImages),
+		PodReadiness:                  copyPodReadinesses(v.podReadiness),
+		UnschedulableSince:            copyUnschedulableSince(v.unschedulableSince),
+		PodUptimes:                    copyPodUptimes(v.podUptimes),
+		PodOwners:                     copyPodOwners(v.podOwners),
+		PodPlacement:                  copyPodPlacement(v.podPlacement),
+		ContainerTerminations:         sortedContainerTerminations(v.containerTerminations),
+		ImagePullFailures:             copyImagePullFailures(v.imagePullFailures),
+		UnavailableCollectors:         v.getUnavailableCollectors(),
+		NodeStatuses:                  copyNodeStatuses(v.nodeStatuses),
+		SecretStatuses:                copySecretStatuses(v.secretStatuses),
+		VersionSkew:                   v.versionSkew,
+		CertExpiries:                  copyCertExpiries(v.certExpiries),
+		MetricsServer:                 v.getMetricsServerStatus(),
+		ResourceQuotaUsages:           copyResourceQuotaUsages(v.resourceQuotaUsages),
+		LimitRangeItems:               copyLimitRangeItems(v.limitRangeItems),
+	}
+}
+
+// GetVizierState gets the aggregate health of Vizier, as of the last time the K8s state was updated.
+func (v *K8sVizierInfo) GetVizierState() *VizierState {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.getVizierState()
+}
+
+// getVizierState returns a copy of the current Vizier state. The caller must hold the lock.
+func (v *K8sVizierInfo) getVizierState() *VizierState {
+	if v.vizierState == nil {
+		return computeVizierState(nil)
+	}
+	return &VizierState{
+		Health:  v.vizierState.Health,
+		Reasons: append([]string{}, v.vizierState.Reasons...),
 	}
 }
 
@@ -0,0 +1,120 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"px.dev/pixie/src/shared/cvmsgspb"
+	"px.dev/pixie/src/shared/k8s/metadatapb"
+)
+
+var (
+	k8sStateLastSuccessGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cloud_connector_k8s_state_last_success_timestamp_seconds",
+		Help: "The time of the last successful update of the K8s state, as a Unix timestamp.",
+	})
+	k8sStateUpdateDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "cloud_connector_k8s_state_update_duration_seconds",
+		Help:    "The time taken to update the K8s state, including failed updates.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+	})
+	k8sStateConsecutiveFailuresGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cloud_connector_k8s_state_consecutive_failures",
+		Help: "The number of K8s state updates that have failed since the last successful update.",
+	})
+	k8sStatePodsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cloud_connector_k8s_state_pods",
+		Help: "The number of pods tracked in the K8s state, by phase.",
+	}, []string{"phase"})
+	k8sStateUpdateSkipsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_connector_k8s_state_update_skips_total",
+		Help: "The number of K8s state updates that were skipped because the previous update was still running.",
+	})
+	k8sStateWatchdogRestartsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_connector_k8s_state_watchdog_restarts_total",
+		Help: "The number of times the connections to the API server were reset because the K8s state stopped updating.",
+	})
+	k8sStateUpdateAPICalls = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "cloud_connector_k8s_state_update_api_calls",
+		Help:    "The number of K8s API calls made by each K8s state update.",
+		Buckets: prometheus.ExponentialBuckets(4, 2, 8),
+	})
+	k8sStateCollectorSkipsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_connector_k8s_state_collector_skips_total",
+		Help: "The number of times an optional collector was skipped because the K8s API calls were being throttled, by collector.",
+	}, []string{"collector"})
+	statusLastDeliveredGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cloud_connector_status_last_delivered_timestamp_seconds",
+		Help: "The time that cloud last acknowledged a status heartbeat, as a Unix timestamp.",
+	})
+	k8sAPIErrorsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_connector_k8s_api_errors_total",
+		Help: "The number of failed K8s API calls made while collecting the K8s state, by resource.",
+	}, []string{"resource"})
+)
+
+func init() {
+	prometheus.MustRegister(k8sStateLastSuccessGauge)
+	prometheus.MustRegister(k8sStateUpdateDuration)
+	prometheus.MustRegister(k8sStateConsecutiveFailuresGauge)
+	prometheus.MustRegister(k8sStatePodsGauge)
+	prometheus.MustRegister(k8sStateUpdateSkipsCounter)
+	prometheus.MustRegister(k8sStateWatchdogRestartsCounter)
+	prometheus.MustRegister(k8sStateUpdateAPICalls)
+	prometheus.MustRegister(k8sStateCollectorSkipsCounter)
+	prometheus.MustRegister(statusLastDeliveredGauge)
+	prometheus.MustRegister(k8sAPIErrorsCounter)
+}
+
+// recordK8sAPIError counts a failed K8s API call for the given resource.
+func recordK8sAPIError(resource string) {
+	k8sAPIErrorsCounter.WithLabelValues(resource).Inc()
+}
+
+// recordK8sStateUpdate records the outcome of a K8s state update that started at the given time. Updates that
+// take longer than the update period are logged, since the next update is skipped while they run.
+func recordK8sStateUpdate(start time.Time, success bool) {
+	duration := time.Since(start)
+	k8sStateUpdateDuration.Observe(duration.Seconds())
+	if duration > k8sStateUpdatePeriod {
+		log.WithField("duration", duration).WithField("period", k8sStateUpdatePeriod).Warn("K8s state update took longer than the update period")
+	}
+	if !success {
+		k8sStateConsecutiveFailuresGauge.Inc()
+		return
+	}
+	k8sStateConsecutiveFailuresGauge.Set(0)
+	k8sStateLastSuccessGauge.Set(float64(time.Now().Unix()))
+}
+
+// recordTrackedPods records the number of pods tracked in the K8s state, by phase.
+func recordTrackedPods(podStatuses map[string]*cvmsgspb.PodStatus) {
+	counts := make(map[metadatapb.PodPhase]int)
+	for _, p := range podStatuses {
+		counts[p.Status]++
+	}
+	// Report every phase, so that a phase with no pods reads as zero rather than its last value.
+	for phase, name := range metadatapb.PodPhase_name {
+		k8sStatePodsGauge.WithLabelValues(name).Set(float64(counts[metadatapb.PodPhase(phase)]))
+	}
+}
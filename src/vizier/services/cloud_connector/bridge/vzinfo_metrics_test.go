@@ -0,0 +1,138 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// Dashboards and alerts depend on these names, so they should not change without updating them.
+func TestK8sStateMetricNames(t *testing.T) {
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: fake.NewSimpleClientset(makePod("vizier-metadata-0", map[string]string{"plane": "control"})),
+	}
+	vzInfo.UpdateK8sState(context.Background())
+	recordK8sAPIError("pods")
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	names := make([]string, 0, len(families))
+	for _, f := range families {
+		names = append(names, f.GetName())
+	}
+
+	for _, name := range []string{
+		"cloud_connector_k8s_state_last_success_timestamp_seconds",
+		"cloud_connector_k8s_state_update_duration_seconds",
+		"cloud_connector_k8s_state_consecutive_failures",
+		"cloud_connector_k8s_state_pods",
+		"cloud_connector_k8s_state_update_skips_total",
+		"cloud_connector_k8s_state_watchdog_restarts_total",
+		"cloud_connector_status_last_delivered_timestamp_seconds",
+		"cloud_connector_k8s_state_update_api_calls",
+		"cloud_connector_k8s_api_errors_total",
+	} {
+		assert.Contains(t, names, name)
+	}
+}
+
+func TestUpdateK8sState_Metrics(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		makePod("vizier-metadata-0", map[string]string{"plane": "control"}),
+		makePod("vizier-query-broker-0", map[string]string{"plane": "control"}),
+	)
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	vzInfo.UpdateK8sState(context.Background())
+	assert.Equal(t, float64(0), testutil.ToFloat64(k8sStateConsecutiveFailuresGauge))
+	assert.Equal(t, float64(2), testutil.ToFloat64(k8sStatePodsGauge.WithLabelValues("RUNNING")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(k8sStatePodsGauge.WithLabelValues("PENDING")))
+	assert.NotZero(t, testutil.ToFloat64(k8sStateLastSuccessGauge))
+
+	podErrors := testutil.ToFloat64(k8sAPIErrorsCounter.WithLabelValues("pods"))
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewInternalError(errors.New("etcd unavailable"))
+	})
+	vzInfo.UpdateK8sState(context.Background())
+	vzInfo.UpdateK8sState(context.Background())
+	assert.Equal(t, float64(2), testutil.ToFloat64(k8sStateConsecutiveFailuresGauge))
+	assert.Equal(t, podErrors+2, testutil.ToFloat64(k8sAPIErrorsCounter.WithLabelValues("pods")))
+}
+
+func TestUpdateK8sState_SkipsOverlappingUpdates(t *testing.T) {
+	clientset := fake.NewSimpleClientset(makePod("vizier-metadata-0", map[string]string{"plane": "control"}))
+	// Simulate a slow API server, which holds up the first update until it is released.
+	listing := make(chan struct{}, 1)
+	release := make(chan struct{})
+	var running, maxRunning int32
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		if n > atomic.LoadInt32(&maxRunning) {
+			atomic.StoreInt32(&maxRunning, n)
+		}
+		select {
+		case listing <- struct{}{}:
+			<-release
+		default:
+		}
+		return false, nil, nil
+	})
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	done := make(chan struct{})
+	go func() {
+		vzInfo.UpdateK8sState(context.Background())
+		close(done)
+	}()
+	<-listing
+
+	skips := testutil.ToFloat64(k8sStateUpdateSkipsCounter)
+	vzInfo.UpdateK8sState(context.Background())
+	vzInfo.UpdateK8sState(context.Background())
+	assert.Equal(t, skips+2, testutil.ToFloat64(k8sStateUpdateSkipsCounter))
+
+	close(release)
+	<-done
+	assert.Equal(t, int32(1), atomic.LoadInt32(&maxRunning))
+	require.Contains(t, vzInfo.GetK8sState().ControlPlanePodStatuses, "vizier-metadata-0")
+
+	// Updates run again once the slow update has finished.
+	vzInfo.UpdateK8sState(context.Background())
+	assert.Equal(t, skips+2, testutil.ToFloat64(k8sStateUpdateSkipsCounter))
+}
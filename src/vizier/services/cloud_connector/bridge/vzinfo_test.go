@@ -0,0 +1,721 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"px.dev/pixie/src/utils/shared/k8s"
+)
+
+const testNamespace = "pl"
+
+func makePod(name string, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+			Labels:    labels,
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+		},
+	}
+}
+
+func TestGetPodSelector(t *testing.T) {
+	vls := k8s.VizierLabelSelector()
+	defaultSelector := metav1.FormatLabelSelector(&vls)
+
+	tests := []struct {
+		name          string
+		labelSelector string
+		selectAll     bool
+		expectedErr   bool
+		expected      string
+	}{
+		{
+			name:          "default",
+			labelSelector: defaultSelector,
+			expected:      "app in (pixie-operator,pl-monitoring)",
+		},
+		{
+			name:          "invalid",
+			labelSelector: "app in (pl-monitoring",
+			expectedErr:   true,
+		},
+		{
+			name:          "select all ignores the label selector",
+			labelSelector: "app in (pl-monitoring",
+			selectAll:     true,
+			expected:      "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			selector, err := getPodSelector(test.labelSelector, test.selectAll)
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, selector.String())
+		})
+	}
+}
+
+func TestUpdateK8sState_PodSelector(t *testing.T) {
+	selector, err := getPodSelector("app=pl-monitoring", false)
+	require.NoError(t, err)
+
+	clientset := fake.NewSimpleClientset(
+		makePod("vizier-metadata-0", map[string]string{"app": "pl-monitoring", "plane": "control"}),
+		makePod("unrelated-0", map[string]string{"app": "unrelated", "plane": "control"}),
+	)
+	vzInfo := &K8sVizierInfo{
+		ns:          testNamespace,
+		clientset:   clientset,
+		podSelector: selector,
+	}
+
+	vzInfo.UpdateK8sState(context.Background())
+	state := vzInfo.GetK8sState()
+	assert.Contains(t, state.ControlPlanePodStatuses, "vizier-metadata-0")
+	assert.NotContains(t, state.ControlPlanePodStatuses, "unrelated-0")
+
+	// A pod that stops matching the selector should be dropped on the next update.
+	_, err = clientset.CoreV1().Pods(testNamespace).Update(context.Background(),
+		makePod("vizier-metadata-0", map[string]string{"app": "other", "plane": "control"}), metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	vzInfo.UpdateK8sState(context.Background())
+	state = vzInfo.GetK8sState()
+	assert.Empty(t, state.ControlPlanePodStatuses)
+}
+
+func TestRefreshClusterVersion(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	fakeDiscovery := clientset.Discovery().(*fakediscovery.FakeDiscovery)
+	fakeDiscovery.FakedServerVersion = &version.Info{GitVersion: "v1.23.4"}
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+
+	now := time.Now()
+	vzInfo.refreshClusterVersion(now)
+	assert.Equal(t, "v1.23.4", vzInfo.GetK8sState().K8sClusterVersion)
+
+	// The version should not be refetched until the refresh period has passed.
+	fakeDiscovery.FakedServerVersion = &version.Info{GitVersion: "v1.24.0"}
+	vzInfo.refreshClusterVersion(now.Add(time.Minute))
+	assert.Equal(t, "v1.23.4", vzInfo.GetK8sState().K8sClusterVersion)
+
+	vzInfo.refreshClusterVersion(now.Add(clusterVersionRefreshPeriod))
+	assert.Equal(t, "v1.24.0", vzInfo.GetK8sState().K8sClusterVersion)
+}
+
+func TestGetPodLogs(t *testing.T) {
+	vzInfo := &K8sVizierInfo{
+		ns:              testNamespace,
+		extraNamespaces: []string{"px-operator"},
+		clientset:       fake.NewSimpleClientset(makePod("vizier-metadata-0", nil)),
+	}
+
+	tests := []struct {
+		name         string
+		podName      string
+		expectedErr  bool
+		expectedLogs string
+	}{
+		{
+			name:         "pod name",
+			podName:      "vizier-metadata-0",
+			expectedLogs: "fake logs",
+		},
+		{
+			name:         "namespaced pod name",
+			podName:      "pl/vizier-metadata-0",
+			expectedLogs: "fake logs",
+		},
+		{
+			name:         "pod in a monitored namespace",
+			podName:      "px-operator/vizier-operator-0",
+			expectedLogs: "fake logs",
+		},
+		{
+			name:        "pod outside of the monitored namespaces",
+			podName:     "kube-system/kube-proxy-abcde",
+			expectedErr: true,
+		},
+		{
+			name:        "empty pod name",
+			podName:     "pl/",
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logs, err := vzInfo.GetPodLogs(test.podName, "app", 100, false)
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedLogs, logs)
+		})
+	}
+}
+
+func TestDeletePod(t *testing.T) {
+	metadataPod := makePod("vizier-metadata-0", map[string]string{"app": "pl-monitoring"})
+	metadataPod.UID = "metadata-uid"
+
+	tests := []struct {
+		name            string
+		podName         string
+		expectedErr     bool
+		expectedUID     string
+		expectedOutcome string
+	}{
+		{
+			name:            "pixie pod",
+			podName:         "vizier-metadata-0",
+			expectedUID:     "metadata-uid",
+			expectedOutcome: deletePodDeleted,
+		},
+		{
+			name:            "pod without pixie labels",
+			podName:         "unrelated-0",
+			expectedErr:     true,
+			expectedUID:     "unrelated-uid",
+			expectedOutcome: deletePodRefused,
+		},
+		{
+			name:            "missing pod",
+			podName:         "vizier-query-broker-0",
+			expectedErr:     true,
+			expectedOutcome: deletePodFailed,
+		},
+	}
+	unrelatedPod := makePod("unrelated-0", map[string]string{"app": "unrelated"})
+	unrelatedPod.UID = "unrelated-uid"
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(metadataPod, unrelatedPod)
+			vzInfo := &K8sVizierInfo{
+				ns:        testNamespace,
+				clientset: clientset,
+			}
+			hook := logtest.NewGlobal()
+			defer hook.Reset()
+
+			uid, err := vzInfo.DeletePod(context.Background(), test.podName, 5*time.Second)
+
+			// Every attempt is audit logged, whatever its outcome.
+			require.Len(t, hook.AllEntries(), 1)
+			entry := hook.LastEntry()
+			assert.Equal(t, log.WarnLevel, entry.Level)
+			assert.Equal(t, "delete_pod", entry.Data["audit"])
+			assert.Equal(t, test.podName, entry.Data["pod"])
+			assert.Equal(t, 5*time.Second, entry.Data["gracePeriod"])
+			assert.Equal(t, test.expectedOutcome, entry.Data["outcome"])
+			if test.expectedUID != "" {
+				assert.Equal(t, test.expectedUID, entry.Data["uid"])
+			} else {
+				assert.NotContains(t, entry.Data, "uid")
+			}
+
+			if test.expectedErr {
+				assert.Error(t, err)
+				// Nothing should have been deleted.
+				pods, err := clientset.CoreV1().Pods(testNamespace).List(context.Background(), metav1.ListOptions{})
+				require.NoError(t, err)
+				assert.Len(t, pods.Items, 2)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedUID, uid)
+			_, err = clientset.CoreV1().Pods(testNamespace).Get(context.Background(), test.podName, metav1.GetOptions{})
+			assert.Error(t, err)
+		})
+	}
+}
+
+// pagedPodsReactor serves the given pages of pods in order, one page per list call. A nil page returns
+// the given error instead.
+func pagedPodsReactor(pages [][]corev1.Pod, pageErr error) k8stesting.ReactionFunc {
+	call := 0
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if call >= len(pages) {
+			return true, &corev1.PodList{}, nil
+		}
+		page := pages[call]
+		call++
+		if page == nil {
+			return true, nil, pageErr
+		}
+		list := &corev1.PodList{Items: page}
+		if call < len(pages) {
+			list.Continue = fmt.Sprintf("page-%d", call)
+		}
+		return true, list, nil
+	}
+}
+
+func TestListPods_Paginated(t *testing.T) {
+	pemLabels := map[string]string{"name": "vizier-pem"}
+	page1 := []corev1.Pod{*makePod("vizier-pem-1", pemLabels), *makePod("vizier-pem-2", pemLabels)}
+	page2 := []corev1.Pod{*makePod("vizier-pem-3", pemLabels)}
+	expiredErr := k8serrors.NewResourceExpired("continue token expired")
+
+	tests := []struct {
+		name         string
+		pages        [][]corev1.Pod
+		pageErr      error
+		expectedErr  bool
+		expectedPods int
+	}{
+		{
+			name:         "multiple pages",
+			pages:        [][]corev1.Pod{page1, page2},
+			expectedPods: 3,
+		},
+		{
+			name:         "expired continue token is retried",
+			pages:        [][]corev1.Pod{page1, nil, page1, page2},
+			pageErr:      expiredErr,
+			expectedPods: 3,
+		},
+		{
+			name:        "expired continue token is only retried once",
+			pages:       [][]corev1.Pod{page1, nil, page1, nil},
+			pageErr:     expiredErr,
+			expectedErr: true,
+		},
+		{
+			name:        "other errors are not retried",
+			pages:       [][]corev1.Pod{page1, nil, page1, page2},
+			pageErr:     k8serrors.NewInternalError(errors.New("etcd unavailable")),
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset()
+			clientset.PrependReactor("list", "pods", pagedPodsReactor(test.pages, test.pageErr))
+			vzInfo := &K8sVizierInfo{
+				ns:        testNamespace,
+				clientset: clientset,
+			}
+
+			pods, err := vzInfo.listPods(context.Background(), testNamespace, "name=vizier-pem")
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, pods, test.expectedPods)
+		})
+	}
+}
+
+func TestUpdateK8sState_PaginationErrorKeepsState(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		makePod("vizier-metadata-0", map[string]string{"plane": "control"}),
+	)
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: clientset,
+	}
+	vzInfo.UpdateK8sState(context.Background())
+	require.Contains(t, vzInfo.GetK8sState().ControlPlanePodStatuses, "vizier-metadata-0")
+
+	clientset.PrependReactor("list", "pods", pagedPodsReactor(
+		[][]corev1.Pod{{*makePod("vizier-query-broker-0", nil)}, nil},
+		k8serrors.NewInternalError(errors.New("etcd unavailable")),
+	))
+	vzInfo.UpdateK8sState(context.Background())
+	// A failure partway through the listing should leave the previous state in place.
+	state := vzInfo.GetK8sState()
+	assert.Len(t, state.ControlPlanePodStatuses, 1)
+	assert.Contains(t, state.ControlPlanePodStatuses, "vizier-metadata-0")
+}
+
+func TestGetPodStatuses_WaitingReasons(t *testing.T) {
+	tests := []struct {
+		name              string
+		phase             corev1.PodPhase
+		containers        []corev1.ContainerStatus
+		wantReason        string
+		wantStatusMessage string
+		wantRunning       bool
+	}{
+		{
+			name:  "running",
+			phase: corev1.PodRunning,
+			containers: []corev1.ContainerStatus{
+				{Name: "app", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+			},
+			wantRunning: true,
+		},
+		{
+			name:  "crash loop in running pod",
+			phase: corev1.PodRunning,
+			containers: []corev1.ContainerStatus{
+				{Name: "app", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+				{
+					Name:         "pem",
+					RestartCount: 12,
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+						Reason:  "CrashLoopBackOff",
+						Message: "back-off 5m0s restarting failed container=pem pod=vizier-pem-abcde_pl",
+					}},
+				},
+			},
+			wantReason:        "CrashLoopBackOff",
+			wantStatusMessage: "back-off 5m0s restarting failed container=pem pod=vizier-pem-abcde_pl",
+		},
+		{
+			name:  "worst container has the most restarts",
+			phase: corev1.PodRunning,
+			containers: []corev1.ContainerStatus{
+				{
+					Name:         "sidecar",
+					RestartCount: 1,
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+						Reason:  "RunContainerError",
+						Message: "failed to start sidecar",
+					}},
+				},
+				{
+					Name:         "app",
+					RestartCount: 4,
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+						Reason:  "CrashLoopBackOff",
+						Message: "back-off 40s restarting failed container=app",
+					}},
+				},
+			},
+			wantReason:        "CrashLoopBackOff",
+			wantStatusMessage: "back-off 40s restarting failed container=app",
+		},
+		{
+			name:  "config error in pending pod",
+			phase: corev1.PodPending,
+			containers: []corev1.ContainerStatus{
+				{
+					Name: "app",
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+						Reason:  "CreateContainerConfigError",
+						Message: `secret "pl-cluster-secrets" not found`,
+					}},
+				},
+			},
+			wantReason:        "CreateContainerConfigError",
+			wantStatusMessage: `secret "pl-cluster-secrets" not found`,
+		},
+		{
+			name:  "pulling image",
+			phase: corev1.PodPending,
+			containers: []corev1.ContainerStatus{
+				{
+					Name:  "app",
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := makePod("vizier-pem-abcde", map[string]string{})
+			pod.Status.Phase = test.phase
+			pod.Status.ContainerStatuses = test.containers
+			vzInfo := &K8sVizierInfo{
+				ns:        testNamespace,
+				clientset: fake.NewSimpleClientset(),
+			}
+
+			statuses, err := vzInfo.getPodStatuses(context.Background(), []corev1.Pod{*pod})
+			require.NoError(t, err)
+			status := statuses["vizier-pem-abcde"]
+			require.NotNil(t, status)
+			assert.Equal(t, test.wantReason, status.Reason)
+			assert.Equal(t, test.wantStatusMessage, status.StatusMessage)
+			assert.Equal(t, test.wantRunning, isPodRunning(pod))
+		})
+	}
+}
+
+func TestUpdateK8sState_CrashLoopingKelvin(t *testing.T) {
+	kelvin := makePod("kelvin-abcde", map[string]string{"app": "pl-monitoring", "name": "kelvin"})
+	kelvin.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{
+			Name:         "app",
+			RestartCount: 7,
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+				Reason:  "CrashLoopBackOff",
+				Message: "back-off 2m40s restarting failed container=app",
+			}},
+		},
+	}
+	selector, err := getPodSelector("app=pl-monitoring", false)
+	require.NoError(t, err)
+	vzInfo := &K8sVizierInfo{
+		ns:          testNamespace,
+		clientset:   fake.NewSimpleClientset(kelvin),
+		podSelector: selector,
+	}
+
+	vzInfo.UpdateK8sState(context.Background())
+
+	state := vzInfo.GetK8sState()
+	require.Contains(t, state.UnhealthyDataPlanePodStatuses, "kelvin-abcde")
+	assert.Equal(t, "CrashLoopBackOff", state.UnhealthyDataPlanePodStatuses["kelvin-abcde"].Reason)
+	assert.Equal(t, VizierHealthUnhealthy, state.VizierState.Health)
+	assert.Contains(t, state.VizierState.Reasons, "kelvin-abcde CrashLoopBackOff")
+}
+
+func crashLoopingPod(name string, labels map[string]string) *corev1.Pod {
+	pod := makePod(name, labels)
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{
+			Name:         "pem",
+			RestartCount: 4,
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+				Reason: "CrashLoopBackOff",
+			}},
+		},
+	}
+	return pod
+}
+
+func TestGetDataPlaneState_UnhealthyPEM(t *testing.T) {
+	pem := crashLoopingPod("vizier-pem-abcde", map[string]string{"name": "vizier-pem"})
+	pem.Spec.NodeName = "node-1"
+	healthyPEM := makePod("vizier-pem-fghij", map[string]string{"name": "vizier-pem"})
+	healthyPEM.Spec.NodeName = "node-2"
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: fake.NewSimpleClientset(pem, healthyPEM),
+	}
+
+	_, numInstrumentedNodes, unhealthy, err := vzInfo.getDataPlaneState(context.Background(),
+		make(map[string]*corev1.Pod), make(map[string]*corev1.Node))
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), numInstrumentedNodes)
+	require.Contains(t, unhealthy, "vizier-pem-abcde")
+	assert.Len(t, unhealthy, 1)
+
+	// The unhealthy PEM makes it into the K8s state, and degrades the Vizier.
+	vzInfo.UpdateK8sState(context.Background())
+	state := vzInfo.GetK8sState()
+	require.Contains(t, state.UnhealthyDataPlanePodStatuses, "vizier-pem-abcde")
+	health, reasons := checkDataPlanePods(state)
+	assert.Equal(t, VizierHealthDegraded, health)
+	assert.Equal(t, []string{"vizier-pem-abcde CrashLoopBackOff"}, reasons)
+	assert.Contains(t, state.VizierState.Reasons, "vizier-pem-abcde CrashLoopBackOff")
+}
+
+func TestGetDataPlaneState_UnhealthyPEMLimit(t *testing.T) {
+	var objects []runtime.Object
+	for i := 0; i < 12; i++ {
+		objects = append(objects, crashLoopingPod(fmt.Sprintf("vizier-pem-%02d", i), map[string]string{"name": "vizier-pem"}))
+	}
+	vzInfo := &K8sVizierInfo{
+		ns:        testNamespace,
+		clientset: fake.NewSimpleClientset(objects...),
+	}
+
+	_, _, unhealthy, err := vzInfo.getDataPlaneState(context.Background(),
+		make(map[string]*corev1.Pod), make(map[string]*corev1.Node))
+	require.NoError(t, err)
+	// Only the first 10 unhealthy PEMs, by name, are sent.
+	assert.Len(t, unhealthy, 10)
+	assert.Contains(t, unhealthy, "vizier-pem-00")
+	assert.NotContains(t, unhealthy, "vizier-pem-11")
+}
+
+func TestGetExtraNamespaces(t *testing.T) {
+	assert.Equal(t, []string{"px-operator"}, getExtraNamespaces("pl", nil))
+	assert.Equal(t, []string{"px-operator", "olm"}, getExtraNamespaces("pl", []string{"pl", "px-operator", " olm", "olm", ""}))
+	assert.Nil(t, getExtraNamespaces("pl", []string{"pl"}))
+}
+
+func TestUpdateK8sState_ExtraNamespaces(t *testing.T) {
+	operator := makePod("vizier-operator-abcde", map[string]string{"app": "pixie-operator"})
+	operator.Namespace = "px-operator"
+	operator.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{
+			Name: "app",
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+				Reason:  "CrashLoopBackOff",
+				Message: "back-off 5m0s restarting failed container=app",
+			}},
+		},
+	}
+	clientset := fake.NewSimpleClientset(expectedSecretObjects(
+		makePod("vizier-metadata-0", map[string]string{"app": "pl-monitoring", "plane": "control"}),
+		operator,
+	)...)
+	listCalls := 0
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() != "olm" {
+			return false, nil, nil
+		}
+		listCalls++
+		return true, nil, k8serrors.NewForbidden(corev1.Resource("pods"), "", errors.New("forbidden"))
+	})
+	selector, err := getPodSelector("app=pl-monitoring", false)
+	require.NoError(t, err)
+	vzInfo := &K8sVizierInfo{
+		ns:              testNamespace,
+		extraNamespaces: []string{"px-operator", "olm"},
+		clientset:       clientset,
+		podSelector:     selector,
+	}
+
+	vzInfo.UpdateK8sState(context.Background())
+	vzInfo.UpdateK8sState(context.Background())
+
+	state := vzInfo.GetK8sState()
+	assert.Contains(t, state.ControlPlanePodStatuses, "vizier-metadata-0")
+	require.Contains(t, state.ControlPlanePodStatuses, "px-operator/vizier-operator-abcde")
+	assert.Equal(t, "px-operator/vizier-operator-abcde", state.ControlPlanePodStatuses["px-operator/vizier-operator-abcde"].Name)
+	// The unreadable namespace is skipped, but still retried on each update.
+	assert.Equal(t, 2, listCalls)
+	assert.True(t, vzInfo.skippedNamespaces["olm"])
+
+	// A failing operator doesn't stop queries, so it only degrades the Vizier.
+	assert.Equal(t, VizierHealthDegraded, state.VizierState.Health, state.VizierState.Reasons)
+	assert.Contains(t, state.VizierState.Reasons, "px-operator/vizier-operator-abcde CrashLoopBackOff")
+}
+
+func TestGetK8sState_Stale(t *testing.T) {
+	selector, err := getPodSelector("app=pl-monitoring", false)
+	require.NoError(t, err)
+	clientset := fake.NewSimpleClientset(
+		makePod("vizier-metadata-0", map[string]string{"app": "pl-monitoring", "plane": "control"}),
+	)
+	vzInfo := &K8sVizierInfo{
+		ns:          testNamespace,
+		clientset:   clientset,
+		podSelector: selector,
+		staleAfter:  30 * time.Second,
+	}
+
+	// State that was never collected isn't stale, it's missing.
+	assert.False(t, vzInfo.GetK8sState().Stale)
+
+	vzInfo.UpdateK8sState(context.Background())
+	assert.False(t, vzInfo.GetK8sState().Stale)
+
+	// Simulate the updater stopping, by winding back the last update.
+	vzInfo.mu.Lock()
+	vzInfo.k8sStateLastUpdated = time.Now().Add(-time.Minute)
+	vzInfo.mu.Unlock()
+	state := vzInfo.GetK8sState()
+	assert.True(t, state.Stale)
+	assert.Contains(t, state.ControlPlanePodStatuses, "vizier-metadata-0")
+
+	// A failed update leaves the state stale.
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("API server unavailable")
+	})
+	vzInfo.UpdateK8sState(context.Background())
+	assert.True(t, vzInfo.GetK8sState().Stale)
+}
+
+func TestStaleK8sStateMessage(t *testing.T) {
+	assert.Equal(t, "K8s state is stale, last updated 1m30s ago", staleK8sStateMessage("", 90*time.Second+300*time.Millisecond))
+	assert.Equal(t, "K8s state is stale, last updated 1m30s ago: kelvin-abcde CrashLoopBackOff",
+		staleK8sStateMessage("kelvin-abcde CrashLoopBackOff", 90*time.Second))
+}
+
+func TestWithDefaultTimeout(t *testing.T) {
+	ctx, cancel := withDefaultTimeout(context.Background())
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(defaultK8sAPITimeout), deadline, time.Second)
+
+	// The caller's deadline is kept.
+	parent, parentCancel := context.WithTimeout(context.Background(), time.Minute)
+	defer parentCancel()
+	ctx, cancel = withDefaultTimeout(parent)
+	defer cancel()
+	parentDeadline, _ := parent.Deadline()
+	deadline, ok = ctx.Deadline()
+	require.True(t, ok)
+	assert.Equal(t, parentDeadline, deadline)
+}
+
+func TestUpdateK8sState_CanceledKeepsState(t *testing.T) {
+	selector, err := getPodSelector("app=pl-monitoring", false)
+	require.NoError(t, err)
+	clientset := fake.NewSimpleClientset(
+		makePod("vizier-metadata-0", map[string]string{"app": "pl-monitoring", "plane": "control"}),
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "vizier-upgrade-job", Namespace: testNamespace}},
+	)
+	vzInfo := &K8sVizierInfo{
+		ns:          testNamespace,
+		clientset:   clientset,
+		podSelector: selector,
+	}
+	vzInfo.UpdateK8sState(context.Background())
+	state := vzInfo.GetK8sState()
+	require.Len(t, state.JobStatuses, 1)
+	lastUpdated := state.LastUpdated
+
+	// Cancel the update partway through, after the pods are listed but while the jobs are being listed.
+	ctx, cancel := context.WithCancel(context.Background())
+	clientset.PrependReactor("list", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		cancel()
+		return true, nil, context.Canceled
+	})
+	vzInfo.UpdateK8sState(ctx)
+
+	state = vzInfo.GetK8sState()
+	assert.Equal(t, lastUpdated, state.LastUpdated)
+	assert.Len(t, state.JobStatuses, 1)
+	assert.Contains(t, state.ControlPlanePodStatuses, "vizier-metadata-0")
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The number of update periods without a successful K8s state update, after which the watchdog steps in.
+const watchdogStaleUpdatePeriods = 6
+
+// How often the watchdog checks whether the K8s state updates have stalled.
+const watchdogCheckPeriod = k8sStateUpdatePeriod
+
+// checkWatchdog checks whether the K8s state has stopped updating, which can happen when the connections to the
+// API server silently die, for example after it restarts. A stalled state is first given a forced relist,
+// since it may just have been a quiet period of failing updates. Only if that also fails are the connections
+// to the API server reset, so that the next update reconnects. Returns whether the connections were reset.
+func (v *K8sVizierInfo) checkWatchdog(ctx context.Context, now time.Time) bool {
+	v.mu.Lock()
+	lastUpdated := v.k8sStateLastUpdated
+	v.mu.Unlock()
+	// State that has never been updated has nothing to have stalled.
+	if lastUpdated.IsZero() || now.Sub(lastUpdated) < watchdogStaleUpdatePeriods*k8sStateUpdatePeriod {
+		return false
+	}
+
+	log.WithField("lastUpdated", lastUpdated).Warn("K8s state has not updated recently, forcing a relist")
+	v.UpdateK8sState(ctx)
+	v.mu.Lock()
+	relisted := v.k8sStateLastUpdated.After(lastUpdated)
+	v.mu.Unlock()
+	if relisted {
+		log.Info("Forced relist of the K8s state succeeded")
+		return false
+	}
+
+	log.Warn("Forced relist of the K8s state failed, resetting the connections to the API server")
+	if v.resetConnections != nil {
+		v.resetConnections()
+	}
+	k8sStateWatchdogRestartsCounter.Inc()
+	return true
+}
+
+// runWatchdog periodically checks whether the K8s state updates have stalled. This runs separately from the
+// updates, so that it can still step in if an update hangs.
+func (v *K8sVizierInfo) runWatchdog() {
+	t := time.NewTicker(watchdogCheckPeriod)
+	defer t.Stop()
+	for range t.C {
+		ctx, cancel := context.WithTimeout(context.Background(), k8sStateUpdatePeriod)
+		v.checkWatchdog(ctx, time.Now())
+		cancel()
+	}
+}
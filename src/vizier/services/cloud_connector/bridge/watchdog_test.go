@@ -0,0 +1,107 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package bridge
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCheckWatchdog(t *testing.T) {
+	tests := []struct {
+		name          string
+		lastUpdated   time.Duration
+		listFails     bool
+		expectedReset bool
+	}{
+		{
+			name:        "fresh",
+			lastUpdated: -time.Duration(watchdogStaleUpdatePeriods-1) * k8sStateUpdatePeriod,
+			listFails:   true,
+		},
+		{
+			name:        "stale, relist succeeds",
+			lastUpdated: -time.Duration(watchdogStaleUpdatePeriods+1) * k8sStateUpdatePeriod,
+		},
+		{
+			name:          "stale, relist fails",
+			lastUpdated:   -time.Duration(watchdogStaleUpdatePeriods+1) * k8sStateUpdatePeriod,
+			listFails:     true,
+			expectedReset: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(makePod("vizier-metadata-0", map[string]string{"plane": "control"}))
+			if test.listFails {
+				clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, k8serrors.NewInternalError(errors.New("connection reset"))
+				})
+			}
+			resets := 0
+			now := time.Now()
+			lastUpdated := now.Add(test.lastUpdated)
+			vzInfo := &K8sVizierInfo{
+				ns:                  testNamespace,
+				clientset:           clientset,
+				k8sStateLastUpdated: lastUpdated,
+				resetConnections:    func() { resets++ },
+			}
+			restarts := testutil.ToFloat64(k8sStateWatchdogRestartsCounter)
+
+			reset := vzInfo.checkWatchdog(context.Background(), now)
+			assert.Equal(t, test.expectedReset, reset)
+			if test.expectedReset {
+				assert.Equal(t, 1, resets)
+				assert.Equal(t, restarts+1, testutil.ToFloat64(k8sStateWatchdogRestartsCounter))
+			} else {
+				assert.Equal(t, 0, resets)
+				assert.Equal(t, restarts, testutil.ToFloat64(k8sStateWatchdogRestartsCounter))
+			}
+			// Only a stale state is relisted.
+			if test.listFails {
+				assert.Equal(t, lastUpdated, vzInfo.GetK8sState().LastUpdated)
+			} else {
+				assert.True(t, vzInfo.GetK8sState().LastUpdated.After(lastUpdated))
+			}
+		})
+	}
+}
+
+func TestCheckWatchdog_NeverUpdated(t *testing.T) {
+	resets := 0
+	vzInfo := &K8sVizierInfo{
+		ns:               testNamespace,
+		clientset:        fake.NewSimpleClientset(),
+		resetConnections: func() { resets++ },
+	}
+	assert.False(t, vzInfo.checkWatchdog(context.Background(), time.Now()))
+	assert.Equal(t, 0, resets)
+	assert.True(t, vzInfo.GetK8sState().LastUpdated.IsZero())
+}
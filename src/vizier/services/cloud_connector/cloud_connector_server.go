@@ -38,6 +38,7 @@ import (
 	"px.dev/pixie/src/shared/services/env"
 	"px.dev/pixie/src/shared/services/healthz"
 	"px.dev/pixie/src/shared/services/httpmiddleware"
+	"px.dev/pixie/src/shared/services/metrics"
 	"px.dev/pixie/src/shared/services/server"
 	"px.dev/pixie/src/shared/services/statusz"
 	"px.dev/pixie/src/shared/status"
@@ -59,6 +60,7 @@ func init() {
 	pflag.String("deploy_key", "", "The deploy key for the cluster")
 	pflag.Bool("disable_auto_update", false, "Whether auto-update should be disabled")
 	pflag.Duration("metrics_scrape_period", time.Minute, "Period that the metrics scraper should run at.")
+	pflag.String("debug_status_addr", "127.0.0.1:50801", "The local address serving the collector's /statusz and /healthz debug endpoints. Empty to disable")
 }
 func newVzServiceClient() (vizierpb.VizierServiceClient, error) {
 	dialOpts, err := services.GetGRPCClientDialOpts()
@@ -173,11 +175,21 @@ func main() {
 	go svr.RunStream()
 	defer svr.Stop()
 
+	// Serve the collector's debug status on a separate listener, which is only reachable from inside the pod
+	// by default, so that it can be read with kubectl exec without a bearer token.
+	if addr := viper.GetString("debug_status_addr"); addr != "" {
+		go func() {
+			err := http.ListenAndServe(addr, controllers.NewDebugStatusHandler(vzInfo))
+			log.WithError(err).Error("Debug status server stopped")
+		}()
+	}
+
 	mux := http.NewServeMux()
 	// Set up healthz endpoint.
 	healthz.RegisterDefaultChecks(mux)
 	// Set up readyz endpoint.
 	healthz.InstallPathHandler(mux, "/readyz", &readinessCheck{svr})
+	metrics.MustRegisterMetricsHandlerNoDefaultMetrics(mux)
 
 	statusz.InstallPathHandler(mux, "/statusz", func() string {
 		// Check state of the bridge.